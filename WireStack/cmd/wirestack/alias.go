@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// aliasCommand groups the commands that manage command aliases.
+func aliasCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alias",
+		Short: "Define short names for command lines you run often",
+		Long: `Define short names for command lines you run often.
+
+Aliases are stored under "aliases" in ~/.wirestack/config.json. When the
+first argument to wirestack is an alias, it is replaced by the alias's
+command line and any further arguments are appended, so with
+
+  wirestack alias set lc "list-clients --output json"
+
+"wirestack lc --server home" runs "wirestack list-clients --output json
+--server home". Quotes in the command line group arguments as in a shell.
+Aliases cannot shadow built-in commands or refer to other aliases.`,
+	}
+	cmd.AddCommand(
+		aliasListCommand(),
+		aliasSetCommand(),
+		aliasRemoveCommand(),
+	)
+	return cmd
+}
+
+// aliasListCommand prints every alias.
+func aliasListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List aliases",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			aliases := settings.Aliases
+			if aliases == nil {
+				aliases = map[string]string{}
+			}
+			if structuredOutput() {
+				return printStructured(aliases)
+			}
+			if len(aliases) == 0 {
+				fmt.Println("no aliases defined")
+				return nil
+			}
+			names := make([]string, 0, len(aliases))
+			for name := range aliases {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%s = %s\n", name, aliases[name])
+			}
+			return nil
+		},
+	}
+}
+
+// aliasSetCommand defines or replaces an alias.
+func aliasSetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <name> <command line>",
+		Short: "Define an alias",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, line := args[0], strings.Join(args[1:], " ")
+			if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t") {
+				return fmt.Errorf("alias name %q must be a single word that does not start with -", name)
+			}
+			if isBuiltinCommand(cmd.Root(), name) {
+				return fmt.Errorf("%s is a built-in command and cannot be an alias", name)
+			}
+			expansion, err := splitCommandLine(line)
+			if err != nil {
+				return fmt.Errorf("alias %s: %w", name, err)
+			}
+			if len(expansion) == 0 {
+				return fmt.Errorf("alias %s has an empty command line", name)
+			}
+			if !isBuiltinCommand(cmd.Root(), expansion[0]) {
+				return fmt.Errorf("alias %s must start with a built-in command, not %s", name, expansion[0])
+			}
+			cmd.SilenceUsage = true
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			if settings.Aliases == nil {
+				settings.Aliases = map[string]string{}
+			}
+			settings.Aliases[name] = line
+			if err := core.SaveSettings(settings); err != nil {
+				return err
+			}
+			fmt.Printf("Alias %s = %s\n", name, line)
+			return nil
+		},
+	}
+	// Flags after the name belong to the aliased command line.
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+// aliasRemoveCommand deletes an alias.
+func aliasRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove an alias",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			if _, ok := settings.Aliases[args[0]]; !ok {
+				return fmt.Errorf("alias %s not found", args[0])
+			}
+			delete(settings.Aliases, args[0])
+			if err := core.SaveSettings(settings); err != nil {
+				return err
+			}
+			fmt.Printf("Alias %s removed\n", args[0])
+			return nil
+		},
+	}
+}
+
+// isBuiltinCommand reports whether name is a command or alias of one under root.
+func isBuiltinCommand(root *cobra.Command, name string) bool {
+	for _, child := range root.Commands() {
+		if child.Name() == name || child.HasAlias(name) {
+			return true
+		}
+	}
+	// Cobra adds help and its completion helpers when it executes.
+	return name == "help" || strings.HasPrefix(name, "__complete")
+}
+
+// expandAlias replaces an alias in the first argument with its command line.
+// Built-in commands always win, so an alias added before a command of the
+// same name stops applying instead of hiding it.
+func expandAlias(root *cobra.Command, args []string, aliases map[string]string) ([]string, error) {
+	if len(args) == 0 || isBuiltinCommand(root, args[0]) {
+		return args, nil
+	}
+	line, ok := aliases[args[0]]
+	if !ok {
+		return args, nil
+	}
+	expansion, err := splitCommandLine(line)
+	if err != nil {
+		return nil, fmt.Errorf("alias %s: %w", args[0], err)
+	}
+	return append(expansion, args[1:]...), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"wirestack/internal/core"
+)
+
+func TestExpandAlias(t *testing.T) {
+	root := newRootCommand()
+	aliases := map[string]string{
+		"lc":     `list-clients --output json`,
+		"note":   `annotate "owner=Jane Doe"`,
+		"status": `list-servers`,
+	}
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"lc", "--server", "home"}, []string{"list-clients", "--output", "json", "--server", "home"}},
+		{[]string{"note", "--server", "home"}, []string{"annotate", "owner=Jane Doe", "--server", "home"}},
+		// Built-in commands win over an alias of the same name.
+		{[]string{"status"}, []string{"status"}},
+		{[]string{"--output", "json", "lc"}, []string{"--output", "json", "lc"}},
+		{nil, nil},
+	} {
+		got, err := expandAlias(root, tc.args, aliases)
+		if err != nil {
+			t.Fatalf("expandAlias(%q): %v", tc.args, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("expandAlias(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestApplyCurrentServer(t *testing.T) {
+	settings := &core.Settings{CurrentServer: "home"}
+	for _, tc := range []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"add-client", []string{"--client", "nas"}, "home"},
+		{"add-client", []string{"--server", "lab", "--client", "nas"}, "lab"},
+		{"list-clients", nil, "home"},
+		{"list-clients", []string{"--all"}, ""},
+		{"expire-check", nil, ""},
+	} {
+		root := newRootCommand()
+		cmd, args, err := root.Find(append([]string{tc.command}, tc.args...))
+		if err != nil {
+			t.Fatalf("find %s: %v", tc.command, err)
+		}
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatalf("parse %q: %v", tc.args, err)
+		}
+		if err := applyCurrentServer(cmd, settings); err != nil {
+			t.Fatalf("applyCurrentServer: %v", err)
+		}
+		if got, _ := cmd.Flags().GetString("server"); got != tc.want {
+			t.Errorf("%s %q: --server = %q, want %q", tc.command, tc.args, got, tc.want)
+		}
+	}
+}
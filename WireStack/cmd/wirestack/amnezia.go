@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// setAmneziaCommand stores AmneziaWG obfuscation parameters on a server.
+func setAmneziaCommand() *cobra.Command {
+	var serverName string
+	var junkOnly bool
+	var regenerate bool
+	var remove bool
+	var jc, jmin, jmax, s1, s2 int
+	var headers [4]uint32
+
+	cmd := &cobra.Command{
+		Use:   "set-amnezia",
+		Short: "Set AmneziaWG obfuscation parameters for clients in censored networks",
+		Long: `Store AmneziaWG parameters (Jc, Jmin, Jmax, S1, S2, H1-H4) on a server, for
+clients on networks that block WireGuard by its traffic pattern.
+
+The first run generates random parameters; individual flags override them.
+--junk-only only sends junk packets (Jc, Jmin, Jmax) before each handshake,
+which a stock WireGuard server ignores, so only the clients need the
+AmneziaWG app: export them with export-client --amnezia. Otherwise S1, S2,
+and H1-H4 change the wire format, so the server has to run AmneziaWG too.
+Its config then carries the parameters and is brought up with awg-quick,
+and every client config carries them as well. --regenerate picks new
+random values and --clear goes back to plain WireGuard. Runtime configs
+that already exist are re-rendered.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+
+			var params *core.AmneziaParams
+			if !remove {
+				if profile.Amnezia != nil && !regenerate && !cmd.Flags().Changed("junk-only") {
+					copied := *profile.Amnezia
+					params = &copied
+				} else if params, err = core.GenerateAmneziaParams(junkOnly); err != nil {
+					return err
+				}
+				flags := cmd.Flags()
+				for _, override := range []struct {
+					flag  string
+					value int
+					field *int
+				}{
+					{"jc", jc, &params.Jc},
+					{"jmin", jmin, &params.Jmin},
+					{"jmax", jmax, &params.Jmax},
+					{"s1", s1, &params.S1},
+					{"s2", s2, &params.S2},
+				} {
+					if flags.Changed(override.flag) {
+						*override.field = override.value
+					}
+				}
+				for idx, field := range []*uint32{&params.H1, &params.H2, &params.H3, &params.H4} {
+					if flags.Changed(fmt.Sprintf("h%d", idx+1)) {
+						*field = headers[idx]
+					}
+				}
+			}
+			if err := core.SetAmnezia(profile, params); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+			if err := rerenderRuntimeConfigs(profile, ""); err != nil {
+				return err
+			}
+
+			if params == nil {
+				fmt.Printf("AmneziaWG parameters removed from server %s\n", serverName)
+				return nil
+			}
+			fmt.Printf("AmneziaWG parameters for server %s:\n  %s\n", serverName, strings.Join(params.Lines(), "\n  "))
+			if params.NeedsServer() {
+				fmt.Println("The server must run AmneziaWG (awg-quick) with these values, and every client needs the AmneziaWG app.")
+			} else {
+				fmt.Println("Junk packets only: the server can stay on WireGuard; export AmneziaWG clients with export-client --amnezia.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().BoolVar(&junkOnly, "junk-only", false, "Generate junk packet parameters only, keeping the server on stock WireGuard")
+	cmd.Flags().BoolVar(&regenerate, "regenerate", false, "Replace the stored parameters with new random ones")
+	cmd.Flags().BoolVar(&remove, "clear", false, "Remove the AmneziaWG parameters")
+	cmd.Flags().IntVar(&jc, "jc", 0, "Number of junk packets sent before each handshake (1-128)")
+	cmd.Flags().IntVar(&jmin, "jmin", 0, "Minimum junk packet size in bytes")
+	cmd.Flags().IntVar(&jmax, "jmax", 0, "Maximum junk packet size in bytes (at most 1280)")
+	cmd.Flags().IntVar(&s1, "s1", 0, "Padding added to handshake initiations in bytes")
+	cmd.Flags().IntVar(&s2, "s2", 0, "Padding added to handshake responses in bytes")
+	for idx := range headers {
+		cmd.Flags().Uint32Var(&headers[idx], fmt.Sprintf("h%d", idx+1), 0, fmt.Sprintf("Message type header H%d (unique, not 1-4 unless all are stock)", idx+1))
+	}
+	cmd.MarkFlagsMutuallyExclusive("clear", "regenerate")
+	return cmd
+}
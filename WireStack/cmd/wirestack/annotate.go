@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// annotateCommand sets or removes key/value annotations on a server or client.
+func annotateCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+
+	cmd := &cobra.Command{
+		Use:   "annotate key=value... | key-...",
+		Short: "Attach metadata such as ticket IDs or owners to a server or client",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			set, remove, err := core.ParseAnnotationArgs(args)
+			if err != nil {
+				return err
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			target := &profile.Annotations
+			subject := "server " + serverName
+			if clientName != "" {
+				client, err := core.FindClient(profile, clientName)
+				if err != nil {
+					return err
+				}
+				target = &client.Annotations
+				subject = "client " + clientName
+			}
+
+			if len(args) == 0 {
+				if structuredOutput() {
+					annotations := *target
+					if annotations == nil {
+						annotations = map[string]string{}
+					}
+					return printStructured(annotations)
+				}
+				keys := make([]string, 0, len(*target))
+				for key := range *target {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					fmt.Printf("%s=%s\n", key, (*target)[key])
+				}
+				return nil
+			}
+
+			if *target, err = core.UpdateAnnotations(*target, set, remove); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+			fmt.Printf("Annotations updated on %s\n", subject)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Annotate this client instead of the server")
+	return cmd
+}
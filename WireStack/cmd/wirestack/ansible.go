@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// exportAnsibleCommand writes an inventory and playbook that deploy a server.
+func exportAnsibleCommand() *cobra.Command {
+	var dir string
+	var options core.AnsibleOptions
+
+	cmd := &cobra.Command{
+		Use:               "export-ansible <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Export an Ansible inventory and playbook that deploy a server",
+		Long: `Write inventory.yml, playbook.yml, and files/<iface>.conf into a directory,
+so "ansible-playbook -i inventory.yml playbook.yml" there deploys the server.
+
+The inventory puts the server in the "wirestack" group, reached at --host (the
+endpoint's host by default) as --user. The playbook installs wireguard-tools
+(and iptables or nftables for --nat), enables forwarding in /etc/sysctl.d,
+copies the config to /etc/wireguard, and enables wg-quick@<iface>, restarting
+it when the config changes. Only ansible.builtin modules are used.
+
+files/<iface>.conf holds the server private key. It can be encrypted with
+"ansible-vault encrypt"; the copy task decrypts it on the way.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dir == "" {
+				return fmt.Errorf("--dir is required")
+			}
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			resolvedDir, err := utils.ExpandPath(dir)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			export, err := core.ExportAnsible(profile, resolvedDir, options)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Inventory written to %s\n", export.InventoryPath)
+			fmt.Printf("Playbook written to %s\n", export.PlaybookPath)
+			fmt.Printf("Server configuration written to %s\n", export.ConfigPath)
+			fmt.Printf("Deploy it with: cd %s && ansible-playbook -i inventory.yml playbook.yml\n", resolvedDir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "", "Directory to write the inventory and playbook into (created if missing)")
+	cmd.Flags().StringVar(&options.Host, "host", "", "Address Ansible connects to (defaults to the endpoint's host)")
+	cmd.Flags().StringVar(&options.User, "user", "", "Remote user Ansible connects as")
+	return cmd
+}
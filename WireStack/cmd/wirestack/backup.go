@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// backupPassphraseEnv supplies the backup passphrase when --passphrase-file is not set.
+const backupPassphraseEnv = "WIRESTACK_BACKUP_PASSPHRASE"
+
+// backupCommand archives the whole config root into a single file.
+func backupCommand() *cobra.Command {
+	var output string
+	var passphraseFile string
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Archive profiles, runtime configs, and settings",
+		Long: `Archive the whole config root (profiles, runtime configs, settings, and the
+sqlite store) into a tar.gz with a SHA-256 manifest.
+
+The archive is encrypted with AES-256-GCM when a passphrase is given through
+--passphrase-file or the ` + backupPassphraseEnv + ` environment variable.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				return fmt.Errorf("--out is required")
+			}
+			passphrase, err := readBackupPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+
+			buffer := &bytes.Buffer{}
+			manifest, err := core.CreateBackup(buffer, version, passphrase)
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteFile(output, buffer.Bytes(), 0o600); err != nil {
+				return err
+			}
+
+			note := "unencrypted"
+			if passphrase != "" {
+				note = "encrypted"
+			}
+			fmt.Printf("Backed up %d files to %s (%s)\n", len(manifest.Files), output, note)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "out", "", "Path of the backup archive to write")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "Encrypt with the passphrase in this file (default: $"+backupPassphraseEnv+")")
+	return cmd
+}
+
+// restoreCommand verifies a backup and writes it back into the config root.
+func restoreCommand() *cobra.Command {
+	var passphraseFile string
+	var force bool
+	var verifyOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Restore profiles and runtime configs from a backup",
+		Args:  cobra.ExactArgs(1),
+		// The sqlite store may be one of the files being restored, so do not open it.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			passphrase, err := readBackupPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+			file, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("open backup: %w", err)
+			}
+			defer file.Close()
+
+			if verifyOnly {
+				manifest, err := core.VerifyBackup(file, passphrase)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Backup is intact: %d files, created %s by wirestack %s\n", len(manifest.Files), manifest.CreatedAt.Local().Format("2006-01-02 15:04"), manifest.Version)
+				return nil
+			}
+
+			manifest, err := core.RestoreBackup(file, passphrase, force)
+			if err != nil {
+				return err
+			}
+			root, err := core.ConfigRoot()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Restored %d files into %s\n", len(manifest.Files), root)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "Decrypt with the passphrase in this file (default: $"+backupPassphraseEnv+")")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite files that already exist")
+	cmd.Flags().BoolVar(&verifyOnly, "verify", false, "Only check the archive checksums; restore nothing")
+	return cmd
+}
+
+// readBackupPassphrase reads the passphrase from path, falling back to the environment.
+func readBackupPassphrase(path string) (string, error) {
+	if path == "" {
+		return os.Getenv(backupPassphraseEnv), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read passphrase file: %w", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// benchCommand measures latency and throughput against a server's bench endpoints.
+func benchCommand() *cobra.Command {
+	var duration time.Duration
+	var pings int
+	var direction string
+	var save string
+	var baselineFile string
+
+	cmd := &cobra.Command{
+		Use:   "bench <address>",
+		Short: "Measure tunnel latency and throughput against wirestack serve",
+		Long: `Measure latency and throughput to a server running
+"wirestack serve --bench-listen", without iperf3 on either end.
+
+<address> is the bench listener as seen through the tunnel, e.g.
+10.0.0.1:8081 or http://10.0.0.1:8081.
+
+To measure a tuning change, save a run with --save before it and compare a
+run after it with --baseline, which prints the change against the saved run.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options := core.BenchOptions{Duration: duration, Pings: pings}
+			switch direction {
+			case "both":
+				options.Download, options.Upload = true, true
+			case "download":
+				options.Download = true
+			case "upload":
+				options.Upload = true
+			default:
+				return fmt.Errorf("unsupported --direction %q (want both, download, or upload)", direction)
+			}
+			if pings < 0 {
+				return fmt.Errorf("--pings must not be negative")
+			}
+
+			var baseline *benchView
+			if baselineFile != "" {
+				path, err := utils.ExpandPath(baselineFile)
+				if err != nil {
+					return err
+				}
+				baseline = &benchView{}
+				if err := utils.ReadJSON(path, baseline); err != nil {
+					return fmt.Errorf("read baseline: %w", err)
+				}
+			}
+
+			baseURL := args[0]
+			if !strings.Contains(baseURL, "://") {
+				baseURL = "http://" + baseURL
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), 2*duration+time.Minute)
+			defer cancel()
+			if !structuredOutput() {
+				fmt.Printf("Benchmarking %s ...\n", baseURL)
+			}
+			result, err := core.RunBench(ctx, &http.Client{}, baseURL, options)
+			if err != nil {
+				return err
+			}
+
+			view := newBenchView(baseURL, result)
+			if save != "" {
+				path, err := utils.ExpandPath(save)
+				if err != nil {
+					return err
+				}
+				if err := utils.WriteJSON(path, view, 0o644); err != nil {
+					return err
+				}
+			}
+			view.Baseline = baseline
+			if structuredOutput() {
+				return printStructured(view)
+			}
+			if pings > 0 {
+				fmt.Printf("Latency:  min %s  avg %s  max %s%s\n", formatRTT(result.LatencyMin), formatRTT(result.LatencyAvg), formatRTT(result.LatencyMax),
+					compareBench(view.LatencyAvgMs, baseline, func(b *benchView) float64 { return b.LatencyAvgMs }))
+			}
+			if options.Download {
+				fmt.Printf("Download: %s  (%s in %s)%s\n", formatBitRate(view.DownloadBitsPerSecond), utils.FormatBytes(result.DownloadBytes), result.DownloadElapsed.Round(time.Millisecond),
+					compareBench(view.DownloadBitsPerSecond, baseline, func(b *benchView) float64 { return b.DownloadBitsPerSecond }))
+			}
+			if options.Upload {
+				fmt.Printf("Upload:   %s  (%s in %s)%s\n", formatBitRate(view.UploadBitsPerSecond), utils.FormatBytes(result.UploadBytes), result.UploadElapsed.Round(time.Millisecond),
+					compareBench(view.UploadBitsPerSecond, baseline, func(b *benchView) float64 { return b.UploadBitsPerSecond }))
+			}
+			if save != "" {
+				fmt.Printf("Saved results to %s\n", save)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&duration, "duration", 10*time.Second, "How long each transfer direction runs (at most 60s)")
+	cmd.Flags().IntVar(&pings, "pings", 10, "Number of latency echoes (0 skips the latency test)")
+	cmd.Flags().StringVar(&direction, "direction", "both", "Transfer directions to test: both, download, or upload")
+	cmd.Flags().StringVar(&save, "save", "", "Write the results as JSON to this file, for a later --baseline")
+	cmd.Flags().StringVar(&baselineFile, "baseline", "", "Compare with results saved by an earlier --save")
+	return cmd
+}
+
+// compareBench describes how value changed from the baseline's, or returns
+// nothing without a baseline or when the baseline did not measure it.
+func compareBench(value float64, baseline *benchView, field func(*benchView) float64) string {
+	if baseline == nil || field(baseline) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  %+.1f%% vs baseline", (value-field(baseline))/field(baseline)*100)
+}
+
+// formatRTT renders a round-trip time in milliseconds.
+func formatRTT(d time.Duration) string {
+	return fmt.Sprintf("%.2f ms", float64(d)/float64(time.Millisecond))
+}
+
+// formatBitRate renders a throughput in decimal bit units, as iperf3 does.
+func formatBitRate(bps float64) string {
+	switch {
+	case bps >= 1e9:
+		return fmt.Sprintf("%.2f Gbit/s", bps/1e9)
+	case bps >= 1e6:
+		return fmt.Sprintf("%.2f Mbit/s", bps/1e6)
+	default:
+		return fmt.Sprintf("%.2f kbit/s", bps/1e3)
+	}
+}
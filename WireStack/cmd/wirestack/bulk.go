@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// bulkCommand applies one action to every client carrying the given tags.
+func bulkCommand() *cobra.Command {
+	var serverName string
+	var tags []string
+	var options core.BulkOptions
+
+	cmd := &cobra.Command{
+		Use:       "bulk <" + strings.Join(core.BulkActions, "|") + ">",
+		Short:     "Rotate keys of, disable, delete, or export every client with a tag",
+		ValidArgs: core.BulkActions,
+		Long: `Apply one action to every client of a server carrying all of the --tag values.
+
+rotate-keys, disable, and delete change the profile as one transaction under
+the profile lock: if any client fails, for example because its new key pair
+could not be generated, no client is changed. A running interface is then
+updated to match. export writes each client's config into --dir and reports
+failures per client. New key pairs and exported files are prepared --workers
+clients at a time. A summary of successes and failures ends the output, and
+the command exits non-zero when any client failed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || len(tags) == 0 {
+				return fmt.Errorf("both --server and --tag are required")
+			}
+			options.Action = args[0]
+			if options.Action == core.BulkExport {
+				if options.Dir == "" {
+					return fmt.Errorf("export requires --dir")
+				}
+				dir, err := utils.ExpandPath(options.Dir)
+				if err != nil {
+					return err
+				}
+				options.Dir = dir
+			}
+			cmd.SilenceUsage = true
+			return runBulkAction(serverName, tags, options)
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Act on clients with this tag (repeatable; clients need all of them)")
+	cmd.Flags().IntVar(&options.Workers, "workers", core.DefaultBulkWorkers, "Clients prepared at once")
+	cmd.Flags().StringVar(&options.Dir, "dir", "", "Directory export writes the configs into (created if missing)")
+	cmd.Flags().BoolVar(&options.Export.QR, "qr", false, "With export, also write a PNG QR code of each config")
+	cmd.Flags().BoolVar(&options.Export.IncludeDisabled, "include-disabled", false, "With export, export disabled clients too")
+	cmd.Flags().StringVar(&options.Export.Render.Target, "target", core.TargetLinux, "With export, the client platform: "+strings.Join(core.ClientTargets, ", "))
+	return cmd
+}
+
+// runBulkAction applies options.Action to every client of serverName carrying
+// all of tags. bulk and the --tag forms of rotate-key, delete-client, and
+// export-client all go through it, so they share one behaviour.
+func runBulkAction(serverName string, tags []string, options core.BulkOptions) error {
+	if options.Action != core.BulkExport {
+		unlock, err := core.LockServerProfile(serverName)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	names, err := core.ClientsWithTags(profile, tags)
+	if err != nil {
+		return err
+	}
+	result, err := core.RunBulk(context.Background(), profile, names, options)
+	if err != nil {
+		return err
+	}
+	if result.Changed() {
+		if err := core.SaveServerProfile(profile); err != nil {
+			return err
+		}
+		applyBulkResult(profile, result)
+	}
+
+	for _, outcome := range result.Outcomes {
+		switch {
+		case outcome.Err != nil:
+			fmt.Fprintf(os.Stderr, "failed: %s: %v\n", outcome.Client, outcome.Err)
+		case result.Applied:
+			fmt.Printf("%s: %s\n", outcome.Client, outcome.Detail)
+		}
+	}
+	failed := result.Failed()
+	if !result.Applied {
+		return fmt.Errorf("%s: %d of %d clients failed; no client was changed", result.Action, failed, len(result.Outcomes))
+	}
+	fmt.Printf("%s: %d succeeded, %d failed\n", result.Action, len(result.Outcomes)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d clients failed", failed, len(result.Outcomes))
+	}
+	return nil
+}
+
+// applyBulkResult brings runtime configs and a running interface in line
+// with a saved bulk change. Failures are warnings: the profile is already saved.
+func applyBulkResult(profile *core.ServerProfile, result *core.BulkResult) {
+	warn := func(err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	switch result.Action {
+	case core.BulkRotateKeys, core.BulkDisable:
+		// The server config lists every enabled client's public key.
+		warn(rerenderRuntimeConfigs(profile, ""))
+	case core.BulkDelete:
+		for _, outcome := range result.Outcomes {
+			if runtimePath, err := core.ClientRuntimeConfigPath(profile.Name, outcome.Client); err == nil {
+				_ = os.Remove(runtimePath)
+			}
+		}
+	}
+
+	iface := core.InterfaceName(profile)
+	if !core.InterfaceIsUp(iface) {
+		return
+	}
+	for _, outcome := range result.Outcomes {
+		if outcome.Retired == "" {
+			continue
+		}
+		warn(core.RemoveLivePeer(iface, outcome.Retired))
+		if result.Action == core.BulkRotateKeys {
+			if client, err := core.FindClient(profile, outcome.Client); err == nil && !client.Disabled {
+				warn(core.ApplyLivePeer(iface, *client))
+			}
+		}
+	}
+	fmt.Printf("Running interface %s updated\n", iface)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wirestack/internal/core"
+)
+
+func TestTagFlagsRunAsBulkActions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	core.SetStore(core.FileStore{})
+
+	profile, err := core.NewServerProfile(core.ServerOptions{Name: "lab", Endpoint: "203.0.113.1:51820"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		tags := []string{"team"}
+		if name == "carol" {
+			tags = nil
+		}
+		if _, err := core.AddClient(profile, core.ClientOptions{Name: name, Tags: tags}); err != nil {
+			t.Fatalf("AddClient: %v", err)
+		}
+	}
+	profile.Clients[1].Disabled = true
+	if err := core.SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	run := func(args ...string) error {
+		root := newRootCommand()
+		root.SetArgs(args)
+		root.SilenceErrors = true
+		root.SetOut(io.Discard)
+		return root.Execute()
+	}
+
+	// Like bulk export, the disabled client is skipped.
+	dir := filepath.Join(t.TempDir(), "configs")
+	if err := run("export-client", "--server", "lab", "--tag", "team", "--out", dir); err != nil {
+		t.Fatalf("export-client --tag: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one exported config, got %v (%v)", entries, err)
+	}
+	if err := run("export-client", "--server", "lab", "--tag", "team", "--out", dir, "--mode", "full"); err == nil {
+		t.Fatalf("expected --mode to be refused with --tag")
+	}
+	if err := run("rotate-key", "--server", "lab", "--tag", "team", "--rollback"); err == nil {
+		t.Fatalf("expected --rollback to be refused with --tag")
+	}
+
+	if err := run("delete-client", "--server", "lab", "--tag", "team"); err != nil {
+		t.Fatalf("delete-client --tag: %v", err)
+	}
+	profile, err = core.LoadServerProfile("lab")
+	if err != nil {
+		t.Fatalf("LoadServerProfile: %v", err)
+	}
+	if len(profile.Clients) != 1 || profile.Clients[0].Name != "carol" {
+		t.Fatalf("expected only carol to remain, got %+v", profile.Clients)
+	}
+}
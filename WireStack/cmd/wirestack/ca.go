@@ -0,0 +1,461 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// day is the unit certificate lifetimes are given in.
+const day = 24 * time.Hour
+
+// caCommand groups the commands that manage the control-plane certificate authority.
+func caCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Manage the certificate authority used for mutual TLS with the daemon",
+	}
+	cmd.AddCommand(caInitCommand(), caIssueAgentCommand(), caRenewCommand(), caRevokeCommand(), caStatusCommand(), caRotateCommand(), caCRLCommand())
+	return cmd
+}
+
+// caInitCommand creates the built-in CA.
+func caInitCommand() *cobra.Command {
+	var name string
+	var days int
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the certificate authority under ~/.wirestack/ca",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+			ca, err := core.InitCA(name, time.Duration(days)*day, force, time.Now())
+			if err != nil {
+				return err
+			}
+			certPath, _, err := core.CAPaths()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Created CA %q valid until %s (%s)\n", ca.Cert.Subject.CommonName, ca.Cert.NotAfter.Format("2006-01-02"), certPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "WireStack CA", "CA common name")
+	cmd.Flags().IntVar(&days, "days", 3650, "CA lifetime in days")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing CA, invalidating every certificate it issued")
+	return cmd
+}
+
+// caIssueAgentCommand issues a client certificate for an agent or remote CLI.
+func caIssueAgentCommand() *cobra.Command {
+	var days int
+	var outDir string
+
+	cmd := &cobra.Command{
+		Use:   "issue-agent <name>",
+		Short: "Issue a client certificate that authenticates to wirestack serve --tls",
+		Long: `Issue a client certificate for an agent or remote CLI. The certificate,
+its private key, and the CA certificate are written to --out-dir as
+<name>.crt, <name>.key, and ca.crt, e.g. for
+curl --cacert ca.crt --cert <name>.crt --key <name>.key https://host:8080/api/v1/servers
+
+Name the certificate of a client's agent <server>/<client> (written as
+<server>-<client>.crt). It can then report that client's platform without
+the API token, and no other client's.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+			ca, err := core.LoadCA()
+			if err != nil {
+				return err
+			}
+			issued, err := ca.IssueAgent(args[0], time.Duration(days)*day, time.Now())
+			if err != nil {
+				return err
+			}
+			return writeAgentFiles(ca, args[0], issued, outDir)
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 365, "Certificate lifetime in days (capped at the CA's)")
+	cmd.Flags().StringVar(&outDir, "out-dir", ".", "Directory the certificate and key are written to")
+	return cmd
+}
+
+// writeAgentFiles writes an agent certificate, its key, and the CA trust
+// bundle into outDir.
+func writeAgentFiles(ca *core.CA, name string, issued *core.IssuedCert, outDir string) error {
+	dir, err := utils.ExpandPath(outDir)
+	if err != nil {
+		return err
+	}
+	// Agent names for a client are <server>/<client>; keep the files in dir.
+	base := strings.ReplaceAll(name, "/", "-")
+	certPath := filepath.Join(dir, base+".crt")
+	keyPath := filepath.Join(dir, base+".key")
+	if err := utils.WriteFile(keyPath, issued.KeyPEM, 0o600); err != nil {
+		return err
+	}
+	if err := utils.WriteFile(certPath, issued.CertPEM, 0o644); err != nil {
+		return err
+	}
+	if err := utils.WriteFile(filepath.Join(dir, "ca.crt"), ca.TrustBundle(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Issued %s valid until %s: %s, %s\n", name, issued.Cert.NotAfter.Format("2006-01-02"), certPath, keyPath)
+	return nil
+}
+
+// caRenewCommand reissues an agent certificate that is close to expiry.
+func caRenewCommand() *cobra.Command {
+	var days int
+	var outDir string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "renew <name>",
+		Short: "Reissue an agent certificate that expires within 30 days",
+		Long: `Reissue an agent certificate that expires within 30 days, or has expired,
+been revoked, or was signed by a CA that is being rotated out. The old
+certificate stays valid until it expires. Agents can also renew themselves
+with POST /api/v1/ca/renew while their current certificate is valid.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+			ca, err := core.LoadCA()
+			if err != nil {
+				return err
+			}
+			registry, err := core.LoadCARegistry()
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			if latest, ok := registry.Latest(args[0]); ok && !force {
+				onCurrentCA := latest.Issuer == ca.Cert.SerialNumber.Text(16)
+				if ca.CertificateState(latest, now) == core.CertValid && onCurrentCA {
+					return fmt.Errorf("certificate %s is valid until %s and not due for renewal; use --force", args[0], latest.NotAfter.Format("2006-01-02"))
+				}
+			}
+			issued, err := ca.IssueAgent(args[0], time.Duration(days)*day, now)
+			if err != nil {
+				return err
+			}
+			return writeAgentFiles(ca, args[0], issued, outDir)
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 365, "Certificate lifetime in days (capped at the CA's)")
+	cmd.Flags().StringVar(&outDir, "out-dir", ".", "Directory the certificate and key are written to")
+	cmd.Flags().BoolVar(&force, "force", false, "Renew even if the certificate is not due")
+	return cmd
+}
+
+// caRevokeCommand adds agent certificates to the denylist.
+func caRevokeCommand() *cobra.Command {
+	var serial string
+
+	cmd := &cobra.Command{
+		Use:   "revoke [name]",
+		Short: "Revoke every certificate issued to an agent, or one by --serial",
+		Long: `Revoke every certificate issued to an agent, or a single one by --serial.
+The daemon checks revocations on every request, so no restart is needed.
+Distribute the updated CRL (wirestack ca crl, or GET /api/v1/ca/crl) to any
+other service that trusts the CA.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			registry, err := core.LoadCARegistry()
+			if err != nil {
+				return err
+			}
+			revoked, err := registry.Revoke(name, serial, time.Now())
+			if err != nil {
+				return err
+			}
+			if err := core.SaveCARegistry(registry); err != nil {
+				return err
+			}
+			fmt.Printf("Revoked %d certificate(s)\n", revoked)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serial, "serial", "", "Revoke only the certificate with this serial (hex, as shown by ca status)")
+	return cmd
+}
+
+// caStatusCommand shows the CA, any rotation in progress, and issued certificates.
+func caStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the CA, rotation state, and issued agent certificates",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ca, err := core.LoadCA()
+			if err != nil {
+				return err
+			}
+			registry, err := core.LoadCARegistry()
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			view := caStatusView{
+				Name:         ca.Cert.Subject.CommonName,
+				Serial:       ca.Cert.SerialNumber.Text(16),
+				NotAfter:     ca.Cert.NotAfter.UTC(),
+				Certificates: []caCertView{},
+			}
+			if ca.Previous != nil {
+				previous := ca.Previous.NotAfter.UTC()
+				view.PreviousNotAfter = &previous
+			}
+			for _, record := range registry.Records() {
+				view.Certificates = append(view.Certificates, caCertView{
+					Name:     record.Name,
+					Serial:   record.Serial,
+					IssuedAt: record.IssuedAt,
+					NotAfter: record.NotAfter,
+					State:    ca.CertificateState(record, now),
+				})
+			}
+			if structuredOutput() {
+				return printStructured(view)
+			}
+
+			fmt.Printf("CA: %s (serial %s)\n", view.Name, view.Serial)
+			fmt.Printf("Expires: %s (%d days)\n", view.NotAfter.Format("2006-01-02"), int(time.Until(view.NotAfter)/day))
+			if view.PreviousNotAfter != nil {
+				fmt.Println("Rotation: in progress; the previous CA is still trusted (finish with wirestack ca rotate --finish)")
+			}
+			if len(view.Certificates) == 0 {
+				fmt.Println("No agent certificates issued")
+				return nil
+			}
+			fmt.Println()
+			writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "NAME\tSERIAL\tISSUED\tEXPIRES\tSTATE")
+			for _, cert := range view.Certificates {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", cert.Name, shortKey(cert.Serial), cert.IssuedAt.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"), cert.State)
+			}
+			return writer.Flush()
+		},
+	}
+}
+
+// caRotateCommand starts or finishes a CA rotation.
+func caRotateCommand() *cobra.Command {
+	var days int
+	var finish bool
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Replace the CA in two steps without cutting off agents",
+		Long: `Replace the CA in two steps without cutting off agents.
+
+"ca rotate" creates a new CA. The old one stays trusted, and the daemon keeps
+presenting a certificate from it, so existing agents carry on. New and
+renewed agent certificates come from the new CA, and the ca.crt written with
+them trusts both. Once every agent has renewed (see ca status),
+"ca rotate --finish" drops the old CA. Send the daemon SIGHUP after each step.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if finish {
+				if err := core.FinishCARotation(); err != nil {
+					return err
+				}
+				fmt.Println("Rotation finished; only the current CA is trusted")
+				return nil
+			}
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+			ca, err := core.RotateCA(time.Duration(days)*day, time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("New CA valid until %s; renew agent certificates, then run wirestack ca rotate --finish\n", ca.Cert.NotAfter.Format("2006-01-02"))
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 3650, "New CA lifetime in days")
+	cmd.Flags().BoolVar(&finish, "finish", false, "Stop trusting the previous CA")
+	return cmd
+}
+
+// caCRLCommand writes the certificate revocation list.
+func caCRLCommand() *cobra.Command {
+	var days int
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "crl",
+		Short: "Write a signed certificate revocation list for other services that trust the CA",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+			ca, err := core.LoadCA()
+			if err != nil {
+				return err
+			}
+			registry, err := core.LoadCARegistry()
+			if err != nil {
+				return err
+			}
+			crl, err := ca.CRL(registry, time.Duration(days)*day, time.Now())
+			if err != nil {
+				return err
+			}
+			if outputPath == "" {
+				fmt.Print(string(crl))
+				return nil
+			}
+			resolvedPath, err := utils.ExpandPath(outputPath)
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteFile(resolvedPath, crl, 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("CRL written to %s\n", resolvedPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 7, "Days until the CRL's next update")
+	cmd.Flags().StringVar(&outputPath, "out", "", "Path to write the CRL (defaults to stdout)")
+	return cmd
+}
+
+// caRenewResponse is the body returned by POST /api/v1/ca/renew.
+type caRenewResponse struct {
+	Name        string    `json:"name"`
+	Certificate string    `json:"certificate"`
+	PrivateKey  string    `json:"private_key"`
+	CABundle    string    `json:"ca_bundle"`
+	NotAfter    time.Time `json:"not_after"`
+}
+
+// caRenewRequest is the optional body of POST /api/v1/ca/renew.
+type caRenewRequest struct {
+	Days int `json:"days"`
+}
+
+// validClientCert reports whether the request presented a certificate that
+// chains to the CA and has not been revoked.
+func (h *apiHandler) validClientCert(r *http.Request) bool {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return false
+	}
+	registry, err := core.LoadCARegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: CA registry: %v\n", err)
+		return false
+	}
+	return !registry.IsRevoked(r.TLS.VerifiedChains[0][0].SerialNumber)
+}
+
+// agentName is the certificate name of the agent running on a client, which
+// lets it check in that client's platform.
+func agentName(server, client string) string {
+	return server + "/" + client
+}
+
+// presentedAgent returns the name of the agent certificate the request
+// presented, or "" for none or another role.
+func presentedAgent(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if len(cert.Subject.OrganizationalUnit) == 0 || cert.Subject.OrganizationalUnit[0] != core.CertAgent {
+		return ""
+	}
+	return cert.Subject.CommonName
+}
+
+// caCRLPath serves the certificate revocation list without authentication.
+const caCRLPath = apiPrefix + "ca/crl"
+
+// serveCRL writes a freshly signed CRL.
+func (h *apiHandler) serveCRL(w http.ResponseWriter, r *http.Request) error {
+	if err := allowMethods(r, http.MethodGet); err != nil {
+		return err
+	}
+	ca, err := core.LoadCA()
+	if err != nil {
+		return err
+	}
+	registry, err := core.LoadCARegistry()
+	if err != nil {
+		return err
+	}
+	crl, err := ca.CRL(registry, 7*day, time.Now())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	_, _ = w.Write(crl)
+	return nil
+}
+
+// renewAgent issues a fresh certificate to the agent presenting a valid one.
+func (h *apiHandler) renewAgent(w http.ResponseWriter, r *http.Request) error {
+	if !h.clientCerts {
+		return badRequest(fmt.Errorf("certificate renewal requires wirestack serve --tls"))
+	}
+	name := presentedAgent(r)
+	if name == "" {
+		return badRequest(fmt.Errorf("only agent certificates can be renewed"))
+	}
+	request := caRenewRequest{Days: 365}
+	if r.ContentLength != 0 {
+		if err := decodeAPIJSON(w, r, &request); err != nil {
+			return err
+		}
+	}
+	if request.Days <= 0 {
+		return badRequest(fmt.Errorf("days must be positive"))
+	}
+
+	h.caMu.Lock()
+	defer h.caMu.Unlock()
+	ca, err := core.LoadCA()
+	if err != nil {
+		return err
+	}
+	issued, err := ca.IssueAgent(name, time.Duration(request.Days)*day, time.Now())
+	if err != nil {
+		return err
+	}
+	writeAPIJSON(w, http.StatusCreated, caRenewResponse{
+		Name:        name,
+		Certificate: string(issued.CertPEM),
+		PrivateKey:  string(issued.KeyPEM),
+		CABundle:    string(ca.TrustBundle()),
+		NotAfter:    issued.Cert.NotAfter.UTC(),
+	})
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	defaultNTPServer    = "pool.ntp.org"
+	defaultMaxClockSkew = 2 * time.Minute
+	clockCheckTimeout   = 2 * time.Second
+)
+
+// clockCheckCommand compares the local clock against an NTP server.
+func clockCheckCommand() *cobra.Command {
+	var ntpServer string
+	var maxSkew time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "clock-check",
+		Short: "Check local clock skew against NTP",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			offset, err := utils.ClockOffset(ntpServer, clockCheckTimeout)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Clock offset from %s: %s\n", ntpServer, offset.Round(time.Millisecond))
+			if absDuration(offset) > maxSkew {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("clock skew %s exceeds %s; WireGuard handshakes may fail", offset.Round(time.Second), maxSkew)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&ntpServer, "ntp-server", defaultNTPServer, "NTP server to compare against")
+	cmd.Flags().DurationVar(&maxSkew, "max-clock-skew", defaultMaxClockSkew, "Largest acceptable clock offset")
+	return cmd
+}
+
+// warnOnClockSkew prints a warning when the local clock drifts past maxSkew. Lookup
+// failures are ignored so an unreachable NTP server never blocks connecting.
+func warnOnClockSkew(ntpServer string, maxSkew time.Duration) {
+	if maxSkew <= 0 {
+		return
+	}
+	offset, err := utils.ClockOffset(ntpServer, clockCheckTimeout)
+	if err != nil {
+		return
+	}
+	if absDuration(offset) > maxSkew {
+		fmt.Fprintf(os.Stderr, "warning: local clock is off by %s from %s; WireGuard handshakes may fail\n", offset.Round(time.Second), ntpServer)
+	}
+}
+
+// absDuration returns the absolute value of d.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
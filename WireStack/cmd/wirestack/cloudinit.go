@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// exportCloudInitCommand prints cloud-init user-data that sets up a server on
+// a fresh VM.
+func exportCloudInitCommand() *cobra.Command {
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:               "export-cloudinit <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Export cloud-init user-data that sets up a server on first boot",
+		Long: `Print cloud-init user-data for a fresh cloud VM. On first boot it installs
+wireguard-tools (and iptables or nftables for --nat), writes the server config
+to /etc/wireguard, enables forwarding in /etc/sysctl.d, and enables
+wg-quick@<iface> so the tunnel is up now and after every reboot.
+
+Pass the output as the VM's user data, for example with
+"--user-data-file" or "--user-data". The endpoint must be the VM's public
+address, and the server's --nat interface the VM's egress interface, such as
+eth0 or ens5. The user data holds the server private key; --out writes it
+to a file with mode 0600.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			userData, err := core.BuildCloudInit(profile)
+			if err != nil {
+				return err
+			}
+			if outputPath == "" {
+				fmt.Print(userData)
+				return nil
+			}
+			resolvedPath, err := utils.ExpandPath(outputPath)
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteFile(resolvedPath, []byte(userData), 0o600); err != nil {
+				return err
+			}
+			fmt.Printf("cloud-init user-data written to %s\n", resolvedPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outputPath, "out", "", "Path to write the user-data (defaults to stdout)")
+	return cmd
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// completionCommand writes shell completion scripts. Server and client names
+// complete dynamically from the profile store.
+func completionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate a shell completion script",
+		Long: `Generate a shell completion script for wirestack.
+
+  bash:       source <(wirestack completion bash)
+  zsh:        wirestack completion zsh > "${fpath[1]}/_wirestack"
+  fish:       wirestack completion fish > ~/.config/fish/completions/wirestack.fish
+  powershell: wirestack completion powershell | Out-String | Invoke-Expression
+
+Server and client names are completed from the profile store for --server,
+--client, and commands that take a server argument.`,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		DisableFlagsInUseLine: true,
+		// Generating a script never touches profiles, so skip opening the store.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(os.Stdout)
+			default:
+				return fmt.Errorf("unsupported shell %q (want bash, zsh, fish, or powershell)", args[0])
+			}
+		},
+	}
+}
+
+// registerNameCompletions attaches store-backed completion to every --server
+// and --client flag in the command tree.
+func registerNameCompletions(cmd *cobra.Command) {
+	if cmd.Flags().Lookup("server") != nil {
+		_ = cmd.RegisterFlagCompletionFunc("server", completeServerFlag)
+	}
+	if cmd.Flags().Lookup("client") != nil {
+		_ = cmd.RegisterFlagCompletionFunc("client", completeClientFlag)
+	}
+	for _, child := range cmd.Commands() {
+		registerNameCompletions(child)
+	}
+}
+
+// completeServerArg completes a single positional server name.
+func completeServerArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeServerFlag(cmd, args, toComplete)
+}
+
+// completeServerClientArgs completes "<server> <client>" positional arguments.
+func completeServerClientArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeServerFlag(cmd, args, toComplete)
+	case 1:
+		return clientNames(args[0], toComplete), cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeServerFlag lists stored server names.
+func completeServerFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Completion runs without the root pre-run hook, so the store is opened here.
+	if err := openStore(); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, err := core.ListServerProfiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeClientFlag lists the clients of the server named by --server.
+func completeClientFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	serverName, _ := cmd.Flags().GetString("server")
+	if serverName == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return clientNames(serverName, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// clientNames returns the client names of a server that start with prefix.
+func clientNames(serverName, prefix string) []string {
+	if err := openStore(); err != nil {
+		return nil
+	}
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(profile.Clients))
+	for _, client := range profile.Clients {
+		names = append(names, client.Name)
+	}
+	return filterPrefix(names, prefix)
+}
+
+// filterPrefix keeps the values that start with prefix.
+func filterPrefix(values []string, prefix string) []string {
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+// completeMeshFlag completes --mesh with stored mesh names.
+func completeMeshFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, err := core.ListMeshProfiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
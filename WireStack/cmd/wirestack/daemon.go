@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// serveConfig is the daemon configuration that SIGHUP reloads.
+type serveConfig struct {
+	listen        string
+	benchListen   string
+	eventInterval time.Duration
+	// requestTimeout bounds each API request other than the event stream.
+	requestTimeout time.Duration
+	// store is the profile store kind and path, compared to tell whether a
+	// reload has to reopen it.
+	store string
+	// notify is the webhook configuration, nil when notifications are off.
+	notify *core.NotifySettings
+}
+
+// resolveServeConfig merges flags set on the command line over the "serve"
+// section of the global settings, which in turn wins over the flag defaults
+// in flagValues.
+func resolveServeConfig(cmd *cobra.Command, flagValues serveConfig) (serveConfig, *core.Settings, error) {
+	settings, err := core.LoadSettings()
+	if err != nil {
+		return serveConfig{}, nil, err
+	}
+	config := flagValues
+	if serve := settings.Serve; serve != nil {
+		if serve.Listen != "" && !cmd.Flags().Changed("listen") {
+			config.listen = serve.Listen
+		}
+		if serve.BenchListen != "" && !cmd.Flags().Changed("bench-listen") {
+			config.benchListen = serve.BenchListen
+		}
+		interval, err := serve.Interval()
+		if err != nil {
+			return serveConfig{}, nil, err
+		}
+		if interval != 0 && !cmd.Flags().Changed("event-interval") {
+			config.eventInterval = interval
+		}
+		timeout, err := serve.Timeout()
+		if err != nil {
+			return serveConfig{}, nil, err
+		}
+		if timeout != 0 && !cmd.Flags().Changed("request-timeout") {
+			config.requestTimeout = timeout
+		}
+	}
+	if notify := settings.Notify; notify != nil {
+		if err := notify.Validate(); err != nil {
+			return serveConfig{}, nil, err
+		}
+		config.notify = notify
+	}
+	if config.eventInterval <= 0 {
+		return serveConfig{}, nil, fmt.Errorf("--event-interval must be positive")
+	}
+	if config.requestTimeout <= 0 {
+		return serveConfig{}, nil, fmt.Errorf("--request-timeout must be positive")
+	}
+	kind := storeName
+	if kind == "" {
+		kind = settings.Store
+	}
+	config.store = kind + "\x00" + settings.StorePath
+	return config, settings, nil
+}
+
+// daemonCertValidity is how long the daemon certificate issued at startup lasts.
+const daemonCertValidity = 365 * 24 * time.Hour
+
+// daemonCertCheckInterval is how often the daemon checks whether its own
+// certificate is due for renewal.
+const daemonCertCheckInterval = time.Hour
+
+// daemonTLSOptions configures mutual TLS on the API listener.
+type daemonTLSOptions struct {
+	enabled bool
+	// hosts are extra names and addresses for the daemon certificate.
+	hosts []string
+}
+
+// daemon runs the API and bench listeners of wirestack serve and swaps them
+// out when the configuration is reloaded.
+type daemon struct {
+	cmd        *cobra.Command
+	flagValues serveConfig
+	config     serveConfig
+
+	handler  *apiHandler
+	events   *eventBroker
+	notifier *notifier
+	timeout  time.Duration
+	tls      daemonTLSOptions
+	// tlsConfig is swapped when the daemon certificate is reissued; the
+	// listener picks it up on the next handshake.
+	tlsConfig  atomic.Pointer[tls.Config]
+	certExpiry time.Time
+
+	api   *http.Server
+	bench *http.Server
+}
+
+// newDaemon resolves the configuration and starts the event broker and the
+// webhook notifier; call run to open the listeners.
+func newDaemon(cmd *cobra.Command, flagValues serveConfig, token string, tlsOptions daemonTLSOptions, timeout time.Duration) (*daemon, error) {
+	config, _, err := resolveServeConfig(cmd, flagValues)
+	if err != nil {
+		return nil, err
+	}
+	events := newEventBroker(config.eventInterval)
+	notifier := startNotifier(events, config.notify)
+	go events.run()
+	handler := newAPIHandler(token, events)
+	handler.clientCerts = tlsOptions.enabled
+	handler.requestTimeout.Store(int64(config.requestTimeout))
+	return &daemon{
+		cmd:        cmd,
+		flagValues: flagValues,
+		config:     config,
+		handler:    handler,
+		events:     events,
+		notifier:   notifier,
+		timeout:    timeout,
+		tls:        tlsOptions,
+	}, nil
+}
+
+// run serves until SIGINT or SIGTERM, reloading the configuration on SIGHUP.
+// Listener errors at startup are returned; after a reload they are logged and
+// the previous listener keeps serving.
+func (d *daemon) run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var err error
+	if d.api, err = d.startAPI(d.config.listen); err != nil {
+		d.notifier.stop()
+		d.events.stop()
+		return err
+	}
+	fmt.Printf("Listening on %s%s\n", d.config.listen, d.tlsNote())
+	if d.config.benchListen != "" {
+		if d.bench, err = d.startServer(d.config.benchListen, core.NewBenchHandler(), nil); err != nil {
+			d.shutdown()
+			return fmt.Errorf("bench listener: %w", err)
+		}
+		fmt.Printf("Bench endpoints on %s%s\n", d.config.benchListen, core.BenchPath)
+	}
+
+	renew := time.NewTicker(daemonCertCheckInterval)
+	defer renew.Stop()
+	portMaps := time.NewTicker(portMapRenewInterval)
+	defer portMaps.Stop()
+	for {
+		select {
+		case <-portMaps.C:
+			// Routers can take seconds to answer; signals must not wait on them.
+			go renewPortMappings()
+			continue
+		case <-renew.C:
+			if d.tls.enabled && time.Until(d.certExpiry) < core.CARenewWindow {
+				if err := d.refreshTLS(d.config.listen); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: renew daemon certificate: %v\n", err)
+				}
+			}
+			continue
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				fmt.Printf("Received %s, shutting down\n", sig)
+				d.shutdown()
+				return nil
+			}
+		}
+		if err := d.reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: reload failed, keeping the previous configuration: %v\n", err)
+			continue
+		}
+		fmt.Println("Configuration reloaded")
+	}
+}
+
+// startAPI starts the API listener on addr. With TLS, a daemon certificate
+// covering addr is issued from the CA first, so a reload that moves the
+// listener gets a matching certificate.
+func (d *daemon) startAPI(addr string) (*http.Server, error) {
+	if !d.tls.enabled {
+		return d.startServer(addr, d.handler, nil)
+	}
+	if err := d.refreshTLS(addr); err != nil {
+		return nil, err
+	}
+	return d.startServer(addr, d.handler, &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return d.tlsConfig.Load(), nil
+		},
+	})
+}
+
+// refreshTLS re-reads the CA, which picks up rotations, and issues a new
+// daemon certificate covering addr.
+func (d *daemon) refreshTLS(addr string) error {
+	ca, err := core.LoadCA()
+	if err != nil {
+		return err
+	}
+	issued, err := ca.Issue(core.CertDaemon, core.CertDaemon, daemonCertHosts(addr, d.tls.hosts), daemonCertValidity, time.Now())
+	if err != nil {
+		return err
+	}
+	config, err := ca.DaemonTLSConfig(issued)
+	if err != nil {
+		return err
+	}
+	d.tlsConfig.Store(config)
+	d.certExpiry = issued.Cert.NotAfter
+	return nil
+}
+
+// tlsNote describes the API transport for startup messages.
+func (d *daemon) tlsNote() string {
+	if d.tls.enabled {
+		return " (TLS, client certificates required)"
+	}
+	return ""
+}
+
+// daemonCertHosts lists the names and addresses the daemon certificate covers:
+// the listen host (or this machine's name for a wildcard address), loopback,
+// and any extra hosts.
+func daemonCertHosts(addr string, extra []string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	host, _, err := net.SplitHostPort(addr)
+	if err == nil {
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			if name, err := os.Hostname(); err == nil {
+				hosts = append(hosts, name)
+			}
+		} else {
+			hosts = append(hosts, host)
+		}
+	}
+	return append(hosts, extra...)
+}
+
+// systemdListenPrefix marks listen addresses that take a socket passed by
+// systemd socket activation, optionally by its FileDescriptorName, as in
+// "systemd:" or "systemd:api".
+const systemdListenPrefix = "systemd:"
+
+// listen opens a TCP listener on addr, or takes a socket-activated one.
+func listen(addr string) (net.Listener, error) {
+	if name, ok := strings.CutPrefix(addr, systemdListenPrefix); ok {
+		return utils.ActivatedListener(name)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// startServer listens on addr before returning, so a bad address is reported
+// to the caller instead of a background goroutine.
+func (d *daemon) startServer(addr string, handler http.Handler, tlsConfig *tls.Config) (*http.Server, error) {
+	listener, err := listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	// Long-lived requests such as event streams watch the base context, which
+	// is cancelled when Shutdown starts so they do not hold it up.
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	server.RegisterOnShutdown(cancel)
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "warning: listener %s: %v\n", addr, err)
+		}
+	}()
+	return server, nil
+}
+
+// stopServer closes the listener and waits up to the shutdown timeout for
+// requests in flight, then drops whatever is left.
+func (d *daemon) stopServer(server *http.Server) {
+	if server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: requests still running after %s were cut off\n", d.timeout)
+		server.Close()
+	}
+}
+
+// reload re-reads the settings and applies what changed. New listeners are
+// opened before old ones are closed, so a bad address leaves the daemon as it
+// was. With TLS the CA is re-read too, so a rotation takes effect.
+func (d *daemon) reload() error {
+	next, settings, err := resolveServeConfig(d.cmd, d.flagValues)
+	if err != nil {
+		return err
+	}
+
+	var api, bench *http.Server
+	if d.tls.enabled && next.listen == d.config.listen {
+		if err := d.refreshTLS(next.listen); err != nil {
+			return err
+		}
+	}
+	if next.listen != d.config.listen {
+		if api, err = d.startAPI(next.listen); err != nil {
+			return err
+		}
+	}
+	if next.benchListen != d.config.benchListen && next.benchListen != "" {
+		if bench, err = d.startServer(next.benchListen, core.NewBenchHandler(), nil); err != nil {
+			d.stopServer(api)
+			return fmt.Errorf("bench listener: %w", err)
+		}
+	}
+	if next.store != d.config.store {
+		if err := d.reopenStore(settings); err != nil {
+			d.stopServer(api)
+			d.stopServer(bench)
+			return err
+		}
+	}
+
+	if api != nil {
+		d.stopServer(d.api)
+		d.api = api
+		fmt.Printf("Listening on %s%s\n", next.listen, d.tlsNote())
+	}
+	if next.benchListen != d.config.benchListen {
+		d.stopServer(d.bench)
+		d.bench = bench
+		if bench != nil {
+			fmt.Printf("Bench endpoints on %s%s\n", next.benchListen, core.BenchPath)
+		}
+	}
+	if next.eventInterval != d.config.eventInterval {
+		d.events.setInterval(next.eventInterval)
+	}
+	d.handler.requestTimeout.Store(int64(next.requestTimeout))
+	d.notifier.setSettings(next.notify)
+	d.config = next
+	return nil
+}
+
+// reopenStore switches the profile store, waiting for API writes in progress
+// so none of them straddles the old and the new store.
+func (d *daemon) reopenStore(settings *core.Settings) error {
+	kind := storeName
+	if kind == "" {
+		kind = settings.Store
+	}
+	store, err := core.OpenStore(kind, settings)
+	if err != nil {
+		return err
+	}
+	d.handler.storeMu.Lock()
+	previous := core.CurrentStore()
+	core.SetStore(store)
+	d.handler.storeMu.Unlock()
+	if closer, ok := previous.(io.Closer); ok {
+		closer.Close()
+	}
+	return nil
+}
+
+// shutdown stops accepting requests, lets in-flight ones finish, and waits
+// for a running event poll before returning.
+func (d *daemon) shutdown() {
+	d.stopServer(d.api)
+	d.stopServer(d.bench)
+	d.notifier.stop()
+	d.events.stop()
+	if closer, ok := core.CurrentStore().(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// decryptCommand decrypts a config exported with export-client --encrypt-to.
+func decryptCommand() *cobra.Command {
+	var identityPath string
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "decrypt <file>",
+		Short: "Decrypt a client configuration exported with --encrypt-to",
+		Long: `Decrypt an age-encrypted client configuration with the identity file
+holding the recipient's private key (created with age-keygen), using the age
+CLI. The config is printed to stdout, or written with mode 0600 to --out.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if identityPath == "" {
+				return fmt.Errorf("--identity is required")
+			}
+			cmd.SilenceUsage = true
+			path, err := utils.ExpandPath(args[0])
+			if err != nil {
+				return err
+			}
+			if identityPath, err = utils.ExpandPath(identityPath); err != nil {
+				return err
+			}
+			config, err := core.DecryptConfig(path, identityPath)
+			if err != nil {
+				return err
+			}
+
+			if outputPath == "" {
+				fmt.Print(config)
+				return nil
+			}
+			if outputPath, err = utils.ExpandPath(outputPath); err != nil {
+				return err
+			}
+			if err := utils.WriteFile(outputPath, []byte(config), 0o600); err != nil {
+				return err
+			}
+			fmt.Printf("Client configuration written to %s\n", outputPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&identityPath, "identity", "i", "", "age identity file with the private key")
+	cmd.Flags().StringVar(&outputPath, "out", "", "Write the decrypted configuration here instead of stdout")
+	return cmd
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// demoStore is the throwaway store used while a demo session runs; openStore
+// keeps it in place instead of opening the configured store.
+var demoStore core.Store
+
+// demoAllowed lists the commands the demo runs. They only read or change
+// the session's profiles and files, or inspect this host without changing it.
+// Anything else, including commands added later, is refused unless it is a
+// dry run.
+var demoAllowed = map[string]bool{
+	"add-client": true, "add-mesh": true, "add-node": true, "add-server": true,
+	"alias": true, "annotate": true, "backup": true, "bulk": true, "ca": true,
+	"completion": true, "decrypt": true, "delete-client": true, "delete-dns-route": true,
+	"delete-group": true, "delete-policy": true, "delete-server": true,
+	"delete-version-policy": true, "diff": true, "diff-config": true, "download-token": true,
+	"edit-client": true, "edit-server": true, "expire-check": true, "export-all": true,
+	"export-ansible": true, "export-client": true, "export-cloudinit": true,
+	"export-docker": true, "export-k8s": true, "export-node": true, "export-server": true,
+	"features": true, "firewall": true, "fsck": true, "genkey": true, "graph": true,
+	"health": true, "help": true, "list-clients": true, "list-groups": true,
+	"list-nodes": true, "list-servers": true, "migrate": true, "migrate-openvpn": true,
+	"migration-bundle": true, "migration-status": true, "quality": true, "restore": true,
+	"rotate-key": true, "set-amnezia": true, "set-dns-route": true, "set-group": true,
+	"set-policy": true, "set-version-policy": true, "show": true, "stats": true,
+	"status": true, "tune": true, "use-server": true, "validate": true, "version": true,
+}
+
+// demoCommand runs an interactive session against a throwaway store.
+func demoCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "demo",
+		Short: "Explore Wirestack in a throwaway session",
+		Long: `Start an interactive session with an in-memory profile store and a
+temporary home directory, seeded with a "demo" server and a few clients.
+Type commands without the "wirestack" prefix, e.g. "show server demo" or
+"list-clients --server demo". Nothing touches your real ~/.wirestack and
+everything is discarded on exit. Only commands that work on the session's
+profiles and files or inspect this host are available, so root is never
+needed; anything that changes the host or reaches the network, such as up,
+sync, serve, or failover, is refused. up, down, connect, and disconnect
+still run with --dry-run. Keys are generated in-process when wg is not
+installed. The demo server is not brought up, so there is no live tunnel to
+connect a client to.`,
+		Args: cobra.NoArgs,
+		// Skip opening the configured store so the real home is never touched.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateOutputFormat(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if demoStore != nil {
+				return fmt.Errorf("already in a demo session")
+			}
+			home, err := os.MkdirTemp("", "wirestack-demo-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(home)
+			previousHome := os.Getenv("HOME")
+			if err := os.Setenv("HOME", home); err != nil {
+				return err
+			}
+			defer os.Setenv("HOME", previousHome)
+
+			demoStore = core.NewMemoryStore()
+			defer func() { demoStore = nil }()
+			core.SetStore(demoStore)
+			profile, err := core.SeedDemo(time.Now())
+			if err != nil {
+				return fmt.Errorf("seed demo server: %w", err)
+			}
+
+			fmt.Printf("Demo session with server %s and %d clients (home %s).\n", profile.Name, len(profile.Clients), home)
+			fmt.Println(`Try "show server demo", "list-clients --server demo", or "help". Type "exit" to quit.`)
+			runDemoSession(bufio.NewScanner(os.Stdin))
+			return nil
+		},
+	}
+}
+
+// runDemoSession reads command lines until EOF or exit and runs each one.
+func runDemoSession(scanner *bufio.Scanner) {
+	for {
+		fmt.Print("demo> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		args, err := splitCommandLine(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+		if len(args) > 0 && args[0] == "wirestack" {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			return
+		}
+		if !demoAllowed[args[0]] && (!dryRunCommands[args[0]] || !hasArg(args, "--dry-run")) {
+			fmt.Fprintf(os.Stderr, "Error: %s is not available in the demo because it can change this host or reach the network", args[0])
+			if dryRunCommands[args[0]] {
+				fmt.Fprint(os.Stderr, "; add --dry-run to see what it would do")
+			}
+			fmt.Fprintln(os.Stderr)
+			continue
+		}
+		root := newRootCommand()
+		root.SetArgs(args)
+		// Cobra has already printed the error.
+		_ = root.Execute()
+		utils.SetDryRun(nil)
+	}
+}
+
+// hasArg reports whether arg appears in args.
+func hasArg(args []string, arg string) bool {
+	for _, candidate := range args {
+		if candidate == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommandLine splits a line into arguments, honouring single and double
+// quotes and backslash escapes outside single quotes.
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg, escaped := false, false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestDemoAllowsOnlyKnownCommands(t *testing.T) {
+	root := newRootCommand()
+	root.InitDefaultHelpCmd()
+	commands := map[string]bool{}
+	for _, cmd := range root.Commands() {
+		commands[cmd.Name()] = true
+	}
+	for name := range demoAllowed {
+		if !commands[name] {
+			t.Errorf("demo allows unknown command %s", name)
+		}
+	}
+	for _, name := range []string{"up", "connect", "sync", "failover", "watch-endpoint", "serve", "systemd", "teardown", "demo"} {
+		if demoAllowed[name] {
+			t.Errorf("demo must not allow %s", name)
+		}
+	}
+}
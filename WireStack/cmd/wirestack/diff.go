@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// stateComparisonView is the machine-readable form of a core.StateComparison.
+type stateComparisonView struct {
+	Source   string          `json:"source" yaml:"source"`
+	Location string          `json:"location" yaml:"location"`
+	Missing  bool            `json:"missing" yaml:"missing"`
+	Diff     *configDiffView `json:"diff,omitempty" yaml:"diff,omitempty"`
+}
+
+// diffCommand shows where a server's runtime config and running interface
+// have drifted from its profile.
+func diffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "diff <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Compare a server's profile with its runtime config and running interface",
+		Long: `Compare the saved profile with the last-rendered runtime config and with the
+running interface (wg show dump): the interface public key and listen port,
+and each peer's public key and AllowedIPs. Each section lists how that place
+differs from the profile, so a change only in the profile shows up in both,
+and one made by hand with wg set only in the interface.
+
+Peers are matched by public key. "+" marks a peer only the compared place
+has, "-" one only the profile has, and "~" a setting that differs. An
+external interface has no runtime config and its listen port is not
+compared. Exits non-zero when anything differs.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			comparisons, err := core.CompareServerState(profile)
+			if err != nil {
+				return err
+			}
+
+			differ := false
+			views := make([]stateComparisonView, 0, len(comparisons))
+			for _, comparison := range comparisons {
+				view := stateComparisonView{Source: comparison.Source, Location: comparison.Location, Missing: comparison.Missing}
+				if comparison.Diff != nil {
+					diff := newConfigDiffView(comparison.Diff)
+					view.Diff = &diff
+					differ = differ || !comparison.Diff.Empty()
+				}
+				views = append(views, view)
+			}
+			if structuredOutput() {
+				if err := printStructured(views); err != nil {
+					return err
+				}
+			} else {
+				for idx, comparison := range comparisons {
+					if idx > 0 {
+						fmt.Println()
+					}
+					printStateComparison(comparison)
+				}
+			}
+			if differ {
+				return fmt.Errorf("server %s differs from its profile", profile.Name)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+// printStateComparison writes one comparison for humans.
+func printStateComparison(comparison core.StateComparison) {
+	switch comparison.Source {
+	case core.StateRuntimeConfig:
+		fmt.Printf("Runtime config %s:\n", comparison.Location)
+		if comparison.Missing {
+			fmt.Println("not rendered yet")
+			return
+		}
+	case core.StateInterface:
+		fmt.Printf("Interface %s:\n", comparison.Location)
+		if comparison.Missing {
+			fmt.Println("not running")
+			return
+		}
+	}
+	printConfigDiff(comparison.Diff)
+}
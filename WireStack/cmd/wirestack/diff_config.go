@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// diffConfigCommand compares a stored profile's rendered config with a file.
+func diffConfigCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "diff-config",
+		Short: "Compare a profile's rendered config with a WireGuard config file",
+		Long: `Render the server's config (or a client's, with --client) and compare it
+with a WireGuard config file, such as /etc/wireguard/wg0.conf or one edited
+by hand. Comments, whitespace, key case, and the order of peers and of
+Address, AllowedIPs, and DNS entries are ignored. PostUp and similar hooks
+are compared in order.
+
+Peers are matched by public key. "+" marks what only the file has, "-" what
+only the profile renders, and "~" a setting that differs. Private and
+preshared keys are compared but never printed. Exits non-zero when the
+configs differ. --file - reads the config from standard input.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || file == "" {
+				return fmt.Errorf("both --server and --file are required")
+			}
+			cmd.SilenceUsage = true
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			var data []byte
+			if file == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				var path string
+				if path, err = utils.ExpandPath(file); err == nil {
+					data, err = utils.ReadFile(path)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			diff, err := core.DiffProfileConfig(profile, clientName, string(data))
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+
+			if structuredOutput() {
+				if err := printStructured(newConfigDiffView(diff)); err != nil {
+					return err
+				}
+			} else {
+				printConfigDiff(diff)
+			}
+			if !diff.Empty() {
+				return fmt.Errorf("configs differ")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Compare this client's config instead of the server's")
+	cmd.Flags().StringVar(&file, "file", "", "WireGuard config file to compare against, or - for stdin")
+	return cmd
+}
+
+// printConfigDiff writes the diff for humans.
+func printConfigDiff(diff *core.ConfigDiff) {
+	if diff.Empty() {
+		fmt.Println("no differences")
+		return
+	}
+	if len(diff.Interface) > 0 {
+		fmt.Println("[Interface]")
+		printSettingChanges(diff.Interface, "  ")
+	}
+	for _, group := range []struct {
+		mark  string
+		peers []core.PeerDiff
+	}{
+		{"-", diff.RemovedPeers},
+		{"+", diff.AddedPeers},
+		{"~", diff.ChangedPeers},
+	} {
+		for _, peer := range group.peers {
+			label := peer.PublicKey
+			if peer.Name != "" {
+				label = fmt.Sprintf("%s (%s)", peer.Name, peer.PublicKey)
+			}
+			fmt.Printf("%s [Peer] %s\n", group.mark, label)
+			if group.mark == "~" {
+				printSettingChanges(peer.Changes, "    ")
+			}
+		}
+	}
+}
+
+// printSettingChanges lists changed keys with their profile and file values.
+func printSettingChanges(changes []core.SettingChange, indent string) {
+	for _, change := range changes {
+		switch {
+		case change.From == "":
+			fmt.Printf("%s+ %s = %s\n", indent, change.Key, change.To)
+		case change.To == "":
+			fmt.Printf("%s- %s = %s\n", indent, change.Key, change.From)
+		default:
+			fmt.Printf("%s~ %s: %s -> %s\n", indent, change.Key, change.From, change.To)
+		}
+	}
+}
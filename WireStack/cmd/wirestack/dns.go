@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// setDNSRouteCommand routes a domain to a resolver inside the tunnel.
+func setDNSRouteCommand() *cobra.Command {
+	var serverName string
+	var domain string
+	var dnsServer string
+	var resolver string
+
+	cmd := &cobra.Command{
+		Use:   "set-dns-route",
+		Short: "Resolve a domain through a DNS server inside the tunnel (split DNS)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || domain == "" || dnsServer == "" {
+				return fmt.Errorf("--server, --domain, and --dns are required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if err := core.SetDNSRoute(profile, domain, dnsServer); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("resolver") {
+				if resolver != core.DNSResolverResolved && resolver != core.DNSResolverDnsmasq {
+					return fmt.Errorf("unsupported resolver %q (want %s or %s)", resolver, core.DNSResolverResolved, core.DNSResolverDnsmasq)
+				}
+				profile.DNSResolver = resolver
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+
+			fmt.Printf("Queries for %s on server %s now resolve via %s\n", core.NormalizeDomain(domain), serverName, dnsServer)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&domain, "domain", "", "Domain to route, e.g. corp.example or *.corp.example")
+	cmd.Flags().StringVar(&dnsServer, "dns", "", "DNS server reachable through the tunnel")
+	cmd.Flags().StringVar(&resolver, "resolver", core.DNSResolverResolved, "Client resolver integration: resolved or dnsmasq")
+	return cmd
+}
+
+// deleteDNSRouteCommand removes a split DNS route.
+func deleteDNSRouteCommand() *cobra.Command {
+	var serverName string
+	var domain string
+
+	cmd := &cobra.Command{
+		Use:   "delete-dns-route",
+		Short: "Remove a split DNS route",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || domain == "" {
+				return fmt.Errorf("both --server and --domain are required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if err := core.RemoveDNSRoute(profile, domain); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+
+			fmt.Printf("DNS route for %s removed from server %s\n", core.NormalizeDomain(domain), serverName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&domain, "domain", "", "Routed domain to remove")
+	return cmd
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// exportDockerCommand writes a compose project that runs a server in a container.
+func exportDockerCommand() *cobra.Command {
+	var dir string
+	var options core.DockerOptions
+
+	cmd := &cobra.Command{
+		Use:               "export-docker <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Export a docker-compose.yml that runs a server in a container",
+		Long: `Write docker-compose.yml and wg_confs/<iface>.conf into a directory, so
+"docker compose up -d" there runs the server in a container.
+
+--flavor linuxserver (the default) uses the linuxserver/wireguard image;
+--flavor native runs wg-quick in a plain Alpine image. --image replaces either
+image. The container gets NET_ADMIN and the sysctls for forwarding, and
+publishes the listen port over UDP. NAT rules run inside the container, so
+the server's --nat interface should be the container's, usually eth0.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dir == "" {
+				return fmt.Errorf("--dir is required")
+			}
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			resolvedDir, err := utils.ExpandPath(dir)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			export, err := core.ExportDocker(profile, resolvedDir, options)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Compose file written to %s\n", export.ComposePath)
+			fmt.Printf("Server configuration written to %s\n", export.ConfigPath)
+			fmt.Printf("Start it with: cd %s && docker compose up -d\n", resolvedDir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "", "Directory to write the compose project into (created if missing)")
+	cmd.Flags().StringVar(&options.Flavor, "flavor", core.DockerLinuxServer, "Container setup: linuxserver or native")
+	cmd.Flags().StringVar(&options.Image, "image", "", "Image to use instead of the flavor's default")
+	return cmd
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// downloadPath is where signed config download links are served. It is the
+// only API path that does not require the bearer token.
+const downloadPath = apiPrefix + "download/"
+
+// downloadTokenCommand mints a signed, expiring link to one client config.
+func downloadTokenCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var ttl time.Duration
+	var target string
+	var killSwitch bool
+	var baseURL string
+
+	cmd := &cobra.Command{
+		Use:   "download-token",
+		Short: "Create an expiring download link for a client config",
+		Long: `Create a signed, expiring token that downloads one client config from
+"wirestack serve" without the API bearer token.
+
+The token only grants that client's config. It stops working when it
+expires, when the client is deleted, disabled, or re-keyed with rotate-key,
+or when ~/.wirestack/download-token.key is deleted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || clientName == "" {
+				return fmt.Errorf("both --server and --client are required")
+			}
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			client, err := core.FindClient(profile, clientName)
+			if err != nil {
+				return err
+			}
+			token, claims, err := issueDownloadToken(profile, *client, core.ClientRenderOptions{Target: target, KillSwitch: killSwitch}, ttl)
+			if err != nil {
+				return err
+			}
+
+			expires := time.Unix(claims.ExpiresAt, 0).Local().Format(time.RFC3339)
+			if baseURL == "" {
+				fmt.Println(token)
+				fmt.Printf("Expires %s; serve it at %s<token>\n", expires, downloadPath)
+				return nil
+			}
+			fmt.Println(strings.TrimSuffix(baseURL, "/") + downloadPath + token)
+			fmt.Printf("Expires %s\n", expires)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Client name")
+	cmd.Flags().DurationVar(&ttl, "ttl", time.Hour, "How long the link stays valid (at most 168h)")
+	cmd.Flags().StringVar(&target, "target", core.TargetLinux, "Client platform the config is rendered for")
+	cmd.Flags().BoolVar(&killSwitch, "kill-switch", false, "Render the config with the kill switch enabled")
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "Public URL of the API server (e.g. https://vpn.example.com); prints a full link")
+	return cmd
+}
+
+// issueDownloadToken renders the config once to validate the options, then signs a token for it.
+func issueDownloadToken(profile *core.ServerProfile, client core.ClientProfile, options core.ClientRenderOptions, ttl time.Duration) (string, core.DownloadClaims, error) {
+	if client.Disabled {
+		return "", core.DownloadClaims{}, fmt.Errorf("client %s is disabled", client.Name)
+	}
+	if _, err := core.BuildClientConfigFor(profile, client, options); err != nil {
+		return "", core.DownloadClaims{}, err
+	}
+	key, err := core.DownloadSigningKey()
+	if err != nil {
+		return "", core.DownloadClaims{}, err
+	}
+	return core.IssueDownloadToken(key, profile, client, options, ttl, time.Now())
+}
+
+// downloadTokenRequest is the body accepted by POST .../download-token.
+type downloadTokenRequest struct {
+	TTL        string `json:"ttl"`
+	Target     string `json:"target"`
+	KillSwitch bool   `json:"kill_switch"`
+}
+
+// downloadTokenResponse is returned by POST .../download-token.
+type downloadTokenResponse struct {
+	Token     string    `json:"token"`
+	Path      string    `json:"path"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// createDownloadToken issues a download token for an authenticated caller.
+func (h *apiHandler) createDownloadToken(w http.ResponseWriter, r *http.Request, serverName, clientName string) error {
+	request := downloadTokenRequest{TTL: "1h"}
+	if r.ContentLength != 0 {
+		if err := decodeAPIJSON(w, r, &request); err != nil {
+			return err
+		}
+	}
+	ttl, err := time.ParseDuration(request.TTL)
+	if err != nil {
+		return badRequest(fmt.Errorf("invalid ttl: %w", err))
+	}
+
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	client, err := core.FindClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	token, claims, err := issueDownloadToken(profile, *client, core.ClientRenderOptions{Target: request.Target, KillSwitch: request.KillSwitch}, ttl)
+	if err != nil {
+		return badRequest(err)
+	}
+	writeAPIJSON(w, http.StatusCreated, downloadTokenResponse{
+		Token:     token,
+		Path:      downloadPath + token,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	})
+	return nil
+}
+
+// serveDownload returns the config a download token grants, without bearer auth.
+func (h *apiHandler) serveDownload(w http.ResponseWriter, r *http.Request, token string) error {
+	if err := allowMethods(r, http.MethodGet); err != nil {
+		return err
+	}
+	key, err := core.DownloadSigningKey()
+	if err != nil {
+		return err
+	}
+	claims, err := core.VerifyDownloadToken(key, token, time.Now())
+	if err != nil {
+		return err
+	}
+	config, err := core.RenderDownload(claims)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", core.ClientConfigFileName(claims.Server, claims.Client, claims.Target)))
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	writeAPIText(w, config)
+	return nil
+}
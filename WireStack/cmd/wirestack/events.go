@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"wirestack/internal/core"
+)
+
+// eventBufferSize is how many events a slow subscriber may fall behind
+// before further events are dropped for it.
+const eventBufferSize = 64
+
+// eventKeepAlive is how often an idle event stream gets a comment line so
+// proxies do not close it.
+const eventKeepAlive = 30 * time.Second
+
+// eventBroker polls profiles and interface status and fans the resulting
+// events out to stream subscribers. Polling picks up changes made by the CLI
+// as well as by the API; API writes call poke so their events are immediate.
+type eventBroker struct {
+	interval time.Duration
+	wake     chan struct{}
+	retime   chan time.Duration
+	quit     chan struct{}
+	done     chan struct{}
+
+	mu          sync.Mutex
+	subscribers map[chan core.Event]struct{}
+}
+
+// newEventBroker returns a broker that polls every interval once run.
+func newEventBroker(interval time.Duration) *eventBroker {
+	return &eventBroker{
+		interval:    interval,
+		wake:        make(chan struct{}, 1),
+		retime:      make(chan time.Duration),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+		subscribers: map[chan core.Event]struct{}{},
+	}
+}
+
+// run polls until stop is called. Errors are logged and polling continues.
+func (b *eventBroker) run() {
+	defer close(b.done)
+	previous, err := takeEventSnapshot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: events: %v\n", err)
+	}
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.quit:
+			return
+		case interval := <-b.retime:
+			ticker.Reset(interval)
+			continue
+		case <-ticker.C:
+		case <-b.wake:
+		}
+		current, err := takeEventSnapshot()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: events: %v\n", err)
+			continue
+		}
+		for _, event := range core.DiffSnapshots(previous, current, time.Now().UTC()) {
+			b.publish(event)
+		}
+		previous = current
+	}
+}
+
+// setInterval changes how often the poll loop runs.
+func (b *eventBroker) setInterval(interval time.Duration) {
+	select {
+	case b.retime <- interval:
+	case <-b.done:
+	}
+}
+
+// stop ends the poll loop, waiting for a poll in progress to finish.
+func (b *eventBroker) stop() {
+	close(b.quit)
+	<-b.done
+}
+
+// poke asks the poll loop to check for changes now.
+func (b *eventBroker) poke() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// subscribe registers a new event channel; call the returned func to unsubscribe.
+func (b *eventBroker) subscribe() (<-chan core.Event, func()) {
+	ch := make(chan core.Event, eventBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// publish delivers event to every subscriber without blocking on slow ones.
+func (b *eventBroker) publish(event core.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// takeEventSnapshot loads every profile and the status of interfaces that are
+// up, recording a quality sample for each running interface on the way.
+func takeEventSnapshot() (core.Snapshot, error) {
+	names, err := core.ListServerProfiles()
+	if err != nil {
+		return core.Snapshot{}, err
+	}
+	profiles := make([]*core.ServerProfile, 0, len(names))
+	statuses := map[string]*core.InterfaceStatus{}
+	for _, name := range names {
+		profile, err := core.LoadServerProfile(name)
+		if err != nil {
+			return core.Snapshot{}, err
+		}
+		profiles = append(profiles, profile)
+		if iface := core.InterfaceName(profile); core.InterfaceIsUp(iface) {
+			if status, err := core.ReadInterfaceStatus(iface); err == nil {
+				statuses[name] = status
+				// The poll doubles as the quality sampler; RecordQuality
+				// throttles itself to one sample per client per minute.
+				if _, err := core.RecordQuality(profile, status, nil, time.Now()); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: quality history: %v\n", err)
+				}
+			}
+		}
+	}
+	return core.TakeSnapshot(profiles, statuses, time.Now()), nil
+}
+
+// streamEvents serves events as Server-Sent Events until the client goes away.
+// ?server=<name> limits the stream to one server.
+func (h *apiHandler) streamEvents(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming is not supported by this connection")
+	}
+	server := r.URL.Query().Get("server")
+
+	events, unsubscribe := h.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-keepAlive.C:
+			fmt.Fprintf(w, ": keep-alive\n\n")
+		case event := <-events:
+			if server != "" && event.Server != server {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
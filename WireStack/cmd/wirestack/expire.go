@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// expireCheckCommand revokes clients whose expiry has passed. It is safe to
+// run from cron: clients already handled are skipped on later runs.
+func expireCheckCommand() *cobra.Command {
+	var serverName string
+	var remove bool
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "expire-check",
+		Short: "Disable or remove clients whose expiry has passed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := []string{serverName}
+			if serverName == "" {
+				var err error
+				names, err = core.ListServerProfiles()
+				if err != nil {
+					return err
+				}
+			}
+
+			now := time.Now()
+			revoked := 0
+			for _, name := range names {
+				unlock, err := core.LockServerProfile(name)
+				if err != nil {
+					return err
+				}
+				defer unlock()
+				profile, err := core.LoadServerProfile(name)
+				if err != nil {
+					return err
+				}
+				expired := core.ExpiredClients(profile, now)
+				if len(expired) == 0 {
+					continue
+				}
+
+				action := "disabled"
+				if remove {
+					action = "removed"
+				}
+				if dryRun {
+					for _, client := range expired {
+						fmt.Printf("%s/%s expired %s; would be %s\n", name, client.Name, client.ExpiresAt.Local().Format(time.RFC3339), action)
+					}
+					revoked += len(expired)
+					continue
+				}
+
+				for _, client := range expired {
+					if remove {
+						if _, err := core.RemoveClient(profile, client.Name); err != nil {
+							return err
+						}
+						if runtimePath, err := core.ClientRuntimeConfigPath(name, client.Name); err == nil {
+							_ = os.Remove(runtimePath)
+						}
+					} else if err := core.DisableClient(profile, client.Name); err != nil {
+						return err
+					}
+				}
+				if err := core.SaveServerProfile(profile); err != nil {
+					return err
+				}
+				if err := rerenderRuntimeConfigs(profile, ""); err != nil {
+					return err
+				}
+
+				iface := core.InterfaceName(profile)
+				live := core.InterfaceIsUp(iface)
+				for _, client := range expired {
+					if live {
+						if err := core.RemoveLivePeer(iface, client.PublicKey); err != nil {
+							return err
+						}
+					}
+					fmt.Printf("%s/%s expired %s; %s\n", name, client.Name, client.ExpiresAt.Local().Format(time.RFC3339), action)
+				}
+				revoked += len(expired)
+			}
+
+			if revoked == 0 {
+				fmt.Println("no expired clients")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Only check this server (default: every server)")
+	cmd.Flags().BoolVar(&remove, "remove", false, "Delete expired clients instead of disabling them")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report expired clients without changing anything")
+	return cmd
+}
+
+// clientStateNote returns a tab-prefixed note for list-clients about a
+// client's expiry or disabled state, or "" when there is nothing to say.
+func clientStateNote(client core.ClientProfile, now time.Time) string {
+	switch {
+	case client.Disabled && client.DisabledReason == core.DisabledOutdated:
+		return "\tdisabled (outdated)"
+	case client.Disabled:
+		return "\tdisabled"
+	case core.ClientExpired(client, now):
+		return "\texpired"
+	case client.ExpiresAt != nil:
+		return "\texpires " + client.ExpiresAt.Local().Format("2006-01-02 15:04")
+	default:
+		return ""
+	}
+}
+
+// warnExpiredClients prints stderr warnings for enabled clients that have
+// expired or will expire within core.ExpiryWarningWindow.
+func warnExpiredClients(server string, clients []core.ClientProfile, now time.Time) {
+	for _, client := range clients {
+		if client.Disabled || client.ExpiresAt == nil {
+			continue
+		}
+		switch {
+		case core.ClientExpired(client, now):
+			fmt.Fprintf(os.Stderr, "warning: client %s/%s expired on %s; run expire-check to revoke it\n", server, client.Name, client.ExpiresAt.Local().Format("2006-01-02"))
+		case client.ExpiresAt.Sub(now) <= core.ExpiryWarningWindow:
+			fmt.Fprintf(os.Stderr, "warning: client %s/%s expires on %s\n", server, client.Name, client.ExpiresAt.Local().Format("2006-01-02"))
+		}
+	}
+}
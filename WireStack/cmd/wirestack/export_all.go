@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// exportAllCommand writes every client configuration of a server into a directory.
+func exportAllCommand() *cobra.Command {
+	var serverName string
+	var dir string
+	var options core.ExportOptions
+
+	cmd := &cobra.Command{
+		Use:   "export-all",
+		Short: "Export every client configuration of a server into a directory",
+		Long: `Export every client configuration of a server into a directory, one .conf
+per client, so configs can be handed out after provisioning a fleet. Files are
+named like export-client names them: a valid tunnel name for the target,
+with a numeric suffix when two clients would otherwise share one. --qr also
+writes a PNG QR code per client for the mobile apps. Disabled clients are
+skipped unless --include-disabled is given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || dir == "" {
+				return fmt.Errorf("--server and --dir are required")
+			}
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			resolvedDir, err := utils.ExpandPath(dir)
+			if err != nil {
+				return err
+			}
+
+			exported, err := core.ExportClients(profile, resolvedDir, options)
+			if err != nil {
+				return err
+			}
+			for _, entry := range exported {
+				if entry.QRPath != "" {
+					fmt.Printf("%s: %s, %s\n", entry.Client, entry.ConfigPath, entry.QRPath)
+				} else {
+					fmt.Printf("%s: %s\n", entry.Client, entry.ConfigPath)
+				}
+			}
+			fmt.Printf("Exported %d client configuration(s) to %s\n", len(exported), resolvedDir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&dir, "dir", "", "Directory to write the client configurations into (created if missing)")
+	cmd.Flags().BoolVar(&options.QR, "qr", false, "Also write a PNG QR code of each configuration")
+	cmd.Flags().BoolVar(&options.IncludeDisabled, "include-disabled", false, "Export disabled clients too")
+	cmd.Flags().StringVar(&options.Render.Target, "target", core.TargetLinux, "Client platform: "+strings.Join(core.ClientTargets, ", "))
+	cmd.Flags().BoolVar(&options.Render.KillSwitch, "kill-switch", false, "Block traffic outside the tunnel (rules on Linux, instructions elsewhere)")
+	return cmd
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// failoverCommand moves a connected client to a server's alternate endpoint
+// when the one it uses stops answering.
+func failoverCommand() *cobra.Command {
+	var clientName string
+	var iface string
+	var interval time.Duration
+	var after time.Duration
+	var once bool
+
+	cmd := &cobra.Command{
+		Use:               "failover <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Switch a connected client to an alternate endpoint when the server is unreachable",
+		Long: `Client configs point at the server's primary endpoint. When the server
+lists alternate endpoints (add-server or edit-server --alternate-endpoint),
+failover checks the peer's latest handshake every --interval and, once it is
+older than --after, points the peer at the next endpoint in order, wrapping
+back to the primary, with "wg set <iface> peer <key> endpoint <host:port>".
+Each new endpoint gets --after to complete a handshake before the next one
+is tried.
+
+Handshakes only happen while there is traffic, so give the client a
+PersistentKeepalive or an idle tunnel looks unreachable. Run it on the
+client next to "wirestack connect". The interface is the one connect brings
+up for --client, or --interface for a config brought up some other way. It
+runs until interrupted; --once checks a single time.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if clientName == "" && iface == "" {
+				return fmt.Errorf("--client or --interface is required")
+			}
+			if interval <= 0 || after <= 0 {
+				return fmt.Errorf("--interval and --after must be positive")
+			}
+			cmd.SilenceUsage = true
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			if len(profile.AlternateEndpoints) == 0 {
+				return fmt.Errorf("server %s has no alternate endpoints; add them with wirestack edit-server --server %s --alternate-endpoint host:port", profile.Name, profile.Name)
+			}
+			if iface == "" {
+				if _, err := core.FindClient(profile, clientName); err != nil {
+					return err
+				}
+				configPath, err := core.ClientRuntimeConfigPath(profile.Name, clientName)
+				if err != nil {
+					return err
+				}
+				iface = core.ConfigInterfaceName(configPath)
+			}
+			endpoints := core.ServerEndpoints(profile)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			var switchedAt time.Time
+			check := func() error {
+				lookupCtx, cancel := context.WithTimeout(ctx, interval)
+				defer cancel()
+				now := time.Now()
+				update, err := core.FailoverPeerEndpoint(lookupCtx, iface, profile.ServerPublicKey, endpoints, after, now, switchedAt)
+				if err != nil {
+					return err
+				}
+				if update.Changed {
+					switchedAt = now
+					previous := update.Previous
+					if previous == "" {
+						previous = "(none)"
+					}
+					handshake := "no handshake yet"
+					if !update.LatestHandshake.IsZero() {
+						handshake = fmt.Sprintf("no handshake for %s", now.Sub(update.LatestHandshake).Round(time.Second))
+					}
+					fmt.Printf("%s %s: %s on %s; switched to %s\n", now.Format(time.RFC3339), iface, handshake, previous, update.Current)
+				}
+				return nil
+			}
+			if once {
+				return check()
+			}
+
+			fmt.Printf("Watching interface %s every %s; failing over between %d endpoints\n", iface, interval, len(endpoints))
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				// A missing interface or failed wg call is transient; keep watching.
+				if err := check(); err != nil && ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&clientName, "client", "", "Client whose connected interface to update")
+	cmd.Flags().StringVar(&iface, "interface", "", "WireGuard interface to update instead of the one connect uses for --client")
+	cmd.Flags().DurationVar(&interval, "interval", 30*time.Second, "How often to check the latest handshake")
+	cmd.Flags().DurationVar(&after, "after", core.DefaultFailoverAfter, "How old the latest handshake may get before the next endpoint is tried")
+	cmd.Flags().BoolVar(&once, "once", false, "Check once and exit instead of watching")
+	return cmd
+}
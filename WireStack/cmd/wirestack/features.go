@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// featuresCommand groups the commands that inspect and toggle feature flags.
+func featuresCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "features",
+		Short: "List and toggle feature flags for this installation",
+		Long: `List and toggle feature flags that gate new or risky subsystems.
+
+Flags are stored in ~/.wirestack/config.json. The ` + core.FeaturesEnv + `
+environment variable overrides them for a single run, e.g.
+` + core.FeaturesEnv + `=-native-backend disables the native backend.`,
+	}
+	cmd.AddCommand(
+		featuresListCommand(),
+		featuresSetCommand("enable", "Enable a feature for this installation", true),
+		featuresSetCommand("disable", "Disable a feature for this installation", false),
+	)
+	return cmd
+}
+
+// featuresListCommand shows every flag with its effective value.
+func featuresListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Show every feature flag and whether it is enabled",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			states, err := core.FeatureStates(settings)
+			if err != nil {
+				return err
+			}
+			if structuredOutput() {
+				views := make([]featureView, 0, len(states))
+				for _, state := range states {
+					views = append(views, featureView{Name: state.Name, Enabled: state.Enabled, Source: state.Source, Default: state.Default, Description: state.Description})
+				}
+				return printStructured(views)
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "FEATURE\tSTATE\tSOURCE\tDESCRIPTION")
+			for _, state := range states {
+				value := "disabled"
+				if state.Enabled {
+					value = "enabled"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", state.Name, value, state.Source, state.Description)
+			}
+			return writer.Flush()
+		},
+	}
+}
+
+// featuresSetCommand stores a flag value in the global settings.
+func featuresSetCommand(verb, short string, enabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   verb + " <feature>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			var names []string
+			for _, feature := range core.Features() {
+				names = append(names, feature.Name)
+			}
+			return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			feature, err := core.LookupFeature(args[0])
+			if err != nil {
+				return err
+			}
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			if settings.Features == nil {
+				settings.Features = map[string]bool{}
+			}
+			settings.Features[feature.Name] = enabled
+			if err := core.SaveSettings(settings); err != nil {
+				return err
+			}
+			fmt.Printf("Feature %s %sd\n", feature.Name, verb)
+			if os.Getenv(core.FeaturesEnv) != "" {
+				fmt.Fprintf(os.Stderr, "warning: %s is set and may override this setting\n", core.FeaturesEnv)
+			}
+			return nil
+		},
+	}
+}
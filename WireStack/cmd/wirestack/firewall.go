@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// firewallCommand renders a firewall ruleset for a server.
+func firewallCommand() *cobra.Command {
+	var options core.FirewallOptions
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "firewall <server>",
+		Short: "Render an nftables or iptables ruleset for a server",
+		Long: `Render a firewall ruleset for a server: the WireGuard port is accepted,
+clients may reach the internet through the egress interface (masqueraded),
+and other traffic forwarded to or from the tunnel is dropped. Without an
+egress interface (--egress, or the server's --nat interface) clients can only
+reach the server and, unless --isolate-clients is given, each other.
+
+Load nftables output with "nft -f <file>", or reference it from a hook with
+add-server --post-up "nft -f <file>". Load iptables output with
+"iptables-restore --noflush < <file>"; render IPv6 rules with --ipv6.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeServerArg,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			rules, err := core.BuildFirewallRules(profile, options)
+			if err != nil {
+				return err
+			}
+
+			if outputPath == "" {
+				fmt.Print(rules)
+				return nil
+			}
+			resolvedPath, err := utils.ExpandPath(outputPath)
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteFile(resolvedPath, []byte(rules), 0o600); err != nil {
+				return err
+			}
+			fmt.Printf("Firewall rules written to %s\n", resolvedPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&options.Format, "format", core.FirewallNftables, "Ruleset format: nftables or iptables")
+	cmd.Flags().StringVar(&options.Egress, "egress", "", "Interface clients reach the internet through (defaults to the server's NAT interface)")
+	cmd.Flags().BoolVar(&options.IsolateClients, "isolate-clients", false, "Drop traffic between clients")
+	cmd.Flags().BoolVar(&options.IPv6, "ipv6", false, "Render ip6tables rules for the IPv6 subnet (iptables format only)")
+	cmd.Flags().StringVar(&outputPath, "out", "", "Path to write the rules (defaults to stdout)")
+	return cmd
+}
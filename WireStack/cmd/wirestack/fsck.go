@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// fsckCommand checks the profile store and the files around it for damage.
+func fsckCommand() *cobra.Command {
+	var repair bool
+
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Check the profile store for corruption, orphaned files, and permission problems",
+		Long: `Scan the profile store and ~/.wirestack for:
+
+  corrupt        profiles or state files that cannot be parsed
+  name-mismatch  profiles stored under a different name than their own
+  duplicate      client names or public keys used more than once
+  orphan         runtime configs, peer sync checkpoints, and quality history
+                 of servers or clients that no longer exist
+  permissions    files other users can access, shared files the permissions
+                 group cannot read, or files that cannot be read
+
+--repair applies the safe fixes: it removes orphaned files and corrupt
+checkpoints or history, which are recreated as needed, and resets modes to
+0600 for files and 0700 for directories, plus what the policy set with
+"wirestack permissions set" shares with its group. Profiles are never changed; restore
+a corrupt one from a backup and fix duplicates with edit-client or
+delete-client. The command exits non-zero while issues remain.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			issues, err := core.CheckStore(repair)
+			if err != nil {
+				return err
+			}
+			view := fsckView{Issues: issues}
+			if view.Issues == nil {
+				view.Issues = []core.FsckIssue{}
+			}
+			for _, issue := range issues {
+				if !issue.Repaired {
+					view.Remaining++
+				}
+			}
+
+			if structuredOutput() {
+				if err := printStructured(view); err != nil {
+					return err
+				}
+			} else if err := printFsck(view, repair); err != nil {
+				return err
+			}
+			if view.Remaining > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d issue(s) remain", view.Remaining)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&repair, "repair", false, "Apply safe fixes: remove orphaned and corrupt derived files and tighten permissions")
+	return cmd
+}
+
+// printFsck renders fsck issues as a table followed by a summary line.
+func printFsck(view fsckView, repair bool) error {
+	if len(view.Issues) == 0 {
+		fmt.Println("no problems found")
+		return nil
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "KIND\tPATH\tPROBLEM\tSTATUS")
+	repairable := 0
+	for _, issue := range view.Issues {
+		status := "manual"
+		switch {
+		case issue.Repaired:
+			status = "repaired"
+		case issue.Repairable:
+			status = "repairable"
+			repairable++
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", issue.Kind, issue.Path, issue.Message, status)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if !repair && repairable > 0 {
+		fmt.Printf("%d issue(s) can be fixed with --repair\n", repairable)
+	}
+	return nil
+}
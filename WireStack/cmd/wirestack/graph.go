@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// graphCommand renders the server/client topology for documentation.
+func graphCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:               "graph [server...]",
+		Short:             "Render the network topology as Graphviz, Mermaid, or JSON",
+		ValidArgsFunction: completeServerFlag,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := args
+			if len(names) == 0 {
+				var err error
+				names, err = core.ListServerProfiles()
+				if err != nil {
+					return err
+				}
+			}
+			profiles := make([]*core.ServerProfile, 0, len(names))
+			for _, name := range names {
+				profile, err := core.LoadServerProfile(name)
+				if err != nil {
+					return err
+				}
+				profiles = append(profiles, profile)
+			}
+
+			topology := core.BuildTopology(profiles)
+			switch format {
+			case "dot":
+				fmt.Print(core.RenderDOT(topology))
+			case "mermaid":
+				fmt.Print(core.RenderMermaid(topology))
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(topology)
+			default:
+				return fmt.Errorf("unsupported format %q (want dot, mermaid, or json)", format)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "dot", "Output format: dot, mermaid, or json")
+	return cmd
+}
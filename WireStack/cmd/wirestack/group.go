@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// groupView is the machine-readable form of a client group.
+type groupView struct {
+	Name       string   `json:"name" yaml:"name"`
+	AllowedIPs []string `json:"allowed_ips,omitempty" yaml:"allowed_ips,omitempty"`
+	DNS        []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	Members    []string `json:"members" yaml:"members"`
+}
+
+// setGroupCommand creates or replaces a client group's shared policy.
+func setGroupCommand() *cobra.Command {
+	var serverName string
+	var groupName string
+	var allowedIPs []string
+	var dns []string
+
+	cmd := &cobra.Command{
+		Use:   "set-group",
+		Short: "Create or change a group of clients sharing AllowedIPs and DNS",
+		Long: `Create a client group, or replace the AllowedIPs and DNS of an existing one.
+
+Clients join a group with add-client or edit-client --group. A member's own
+--allowed-ips and --dns overrides still win; otherwise the group's apply,
+ahead of tag policies and the server's DNS. Changing a group re-renders the
+runtime configs of its members that already exist.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || groupName == "" {
+				return fmt.Errorf("both --server and --name are required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if err := core.SetGroup(profile, groupName, nonEmpty(allowedIPs), nonEmpty(dns)); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+			members := core.GroupMembers(profile, groupName)
+			for _, member := range members {
+				if err := rerenderRuntimeConfigs(profile, member); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("Group %s saved on server %s\n", groupName, serverName)
+			if len(members) > 0 {
+				fmt.Printf("Re-export the configs of its %d members: %s\n", len(members), strings.Join(members, ", "))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&groupName, "name", "", "Group name")
+	cmd.Flags().StringSliceVar(&allowedIPs, "allowed-ips", nil, "AllowedIPs for the group's clients (comma-separated CIDRs)")
+	cmd.Flags().StringSliceVar(&dns, "dns", nil, "DNS servers for the group's clients (comma-separated IPs)")
+	return cmd
+}
+
+// deleteGroupCommand removes a client group without members.
+func deleteGroupCommand() *cobra.Command {
+	var serverName string
+	var groupName string
+
+	cmd := &cobra.Command{
+		Use:   "delete-group",
+		Short: "Remove a client group that no client is in",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || groupName == "" {
+				return fmt.Errorf("both --server and --name are required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if err := core.RemoveGroup(profile, groupName); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+
+			fmt.Printf("Group %s removed from server %s\n", groupName, serverName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&groupName, "name", "", "Group name")
+	return cmd
+}
+
+// listGroupsCommand lists a server's client groups and their members.
+func listGroupsCommand() *cobra.Command {
+	var serverName string
+
+	cmd := &cobra.Command{
+		Use:   "list-groups",
+		Short: "List a server's client groups and their members",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+
+			views := make([]groupView, 0, len(profile.Groups))
+			for _, group := range profile.Groups {
+				views = append(views, groupView{
+					Name:       group.Name,
+					AllowedIPs: group.AllowedIPs,
+					DNS:        group.DNS,
+					Members:    core.GroupMembers(profile, group.Name),
+				})
+			}
+			if structuredOutput() {
+				return printStructured(views)
+			}
+			if len(views) == 0 {
+				fmt.Printf("No groups on server %s\n", serverName)
+				return nil
+			}
+			for _, view := range views {
+				fmt.Printf("%s (%d members)\n", view.Name, len(view.Members))
+				if len(view.AllowedIPs) > 0 {
+					fmt.Printf("  AllowedIPs: %s\n", strings.Join(view.AllowedIPs, ", "))
+				}
+				if len(view.DNS) > 0 {
+					fmt.Printf("  DNS: %s\n", strings.Join(view.DNS, ", "))
+				}
+				if len(view.Members) > 0 {
+					fmt.Printf("  Members: %s\n", strings.Join(view.Members, ", "))
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	return cmd
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// healthCommand checks peer handshakes against a threshold for monitoring scripts.
+func healthCommand() *cobra.Command {
+	var threshold time.Duration
+	var ignoreNever bool
+	var watch bool
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Use:               "health <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Check peer handshakes and exit non-zero when peers are stale",
+		Long: `Check each peer's latest handshake against --threshold. Peers are "ok",
+"stale" (handshake too old), "never" (no handshake yet), or "missing" (an
+enabled client that is not configured on the running interface).
+
+The command exits non-zero when the interface is down or any peer is not ok,
+so it can be used from monitoring scripts. --ignore-never treats peers that
+have never connected as healthy. --watch refreshes every --interval until
+interrupted instead of exiting.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if threshold <= 0 {
+				return fmt.Errorf("--threshold must be positive")
+			}
+			if watch && interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			for {
+				view, err := checkHealth(args[0], threshold, ignoreNever)
+				if err != nil {
+					return err
+				}
+				if watch && !structuredOutput() {
+					// Clear the screen so the table refreshes in place.
+					fmt.Print("\033[H\033[2J")
+				}
+				if structuredOutput() {
+					if err := printStructured(view); err != nil {
+						return err
+					}
+				} else if err := printHealth(view); err != nil {
+					return err
+				}
+				if !watch {
+					if !view.Healthy {
+						cmd.SilenceUsage = true
+						return fmt.Errorf("server %s is unhealthy", view.Server)
+					}
+					return nil
+				}
+				time.Sleep(interval)
+			}
+		},
+	}
+
+	cmd.Flags().DurationVar(&threshold, "threshold", core.PeerOnlineWindow, "Largest acceptable age of a peer's latest handshake")
+	cmd.Flags().BoolVar(&ignoreNever, "ignore-never", false, "Do not count peers that have never connected as unhealthy")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Refresh continuously instead of exiting")
+	cmd.Flags().DurationVar(&interval, "interval", 5*time.Second, "Refresh interval with --watch")
+	return cmd
+}
+
+// checkHealth reads the server's interface and evaluates every peer.
+func checkHealth(name string, threshold time.Duration, ignoreNever bool) (healthView, error) {
+	profile, err := core.LoadServerProfile(name)
+	if err != nil {
+		return healthView{}, err
+	}
+	view := healthView{
+		Server:    profile.Name,
+		Interface: core.InterfaceName(profile),
+		Threshold: threshold.String(),
+		Peers:     []peerHealthView{},
+	}
+	if !core.InterfaceIsUp(view.Interface) {
+		return view, nil
+	}
+	status, err := core.ReadInterfaceStatus(view.Interface)
+	if err != nil {
+		return healthView{}, err
+	}
+	view.Up = true
+	view.Healthy = true
+	for _, peer := range core.EvaluateHealth(profile, status, threshold, time.Now()) {
+		entry := peerHealthView{Client: peer.Client, PublicKey: peer.PublicKey, Endpoint: peer.Endpoint, State: peer.State, Healthy: peer.Healthy(ignoreNever)}
+		if !peer.LatestHandshake.IsZero() {
+			handshake := peer.LatestHandshake
+			entry.LatestHandshake = &handshake
+		}
+		if !entry.Healthy {
+			view.Healthy = false
+		}
+		view.Peers = append(view.Peers, entry)
+	}
+	return view, nil
+}
+
+// printHealth renders a health check as a table followed by a summary line.
+func printHealth(view healthView) error {
+	if !view.Up {
+		fmt.Printf("Server: %s (interface %s down)\n", view.Server, view.Interface)
+		return nil
+	}
+
+	fmt.Printf("Server: %s (threshold %s, checked %s)\n", view.Server, view.Threshold, time.Now().Format("15:04:05"))
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "CLIENT\tSTATE\tLATEST HANDSHAKE\tENDPOINT")
+	healthy := 0
+	for _, peer := range view.Peers {
+		clientName := peer.Client
+		if clientName == "" {
+			clientName = "(unknown " + shortKey(peer.PublicKey) + ")"
+		}
+		endpoint := peer.Endpoint
+		if endpoint == "" {
+			endpoint = "-"
+		}
+		handshake := time.Time{}
+		if peer.LatestHandshake != nil {
+			handshake = *peer.LatestHandshake
+		}
+		if peer.Healthy {
+			healthy++
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", clientName, peer.State, formatHandshake(handshake), endpoint)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d of %d peers healthy\n", healthy, len(view.Peers))
+	return nil
+}
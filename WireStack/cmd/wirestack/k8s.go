@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// exportK8sCommand prints Kubernetes manifests that run a server in a cluster.
+func exportK8sCommand() *cobra.Command {
+	var outputPath string
+	var options core.K8sOptions
+
+	cmd := &cobra.Command{
+		Use:               "export-k8s <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Export Kubernetes manifests that run a server in a cluster",
+		Long: `Print a Secret holding the server config, a Deployment (or --kind daemonset)
+that runs it with NET_ADMIN, and a Service exposing the listen port over UDP,
+ready for "kubectl apply -f -".
+
+The container image is picked as for export-docker with --flavor and --image.
+A privileged init container enables forwarding, since those sysctls are not
+in Kubernetes' safe set. NAT rules run inside the pod, so the server's --nat
+interface should be the pod's, usually eth0. The Secret holds the server
+private key; --out writes the manifests to a file with mode 0600.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			manifests, err := core.BuildK8sManifests(profile, options)
+			if err != nil {
+				return err
+			}
+			if outputPath == "" {
+				fmt.Print(manifests)
+				return nil
+			}
+			resolvedPath, err := utils.ExpandPath(outputPath)
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteFile(resolvedPath, []byte(manifests), 0o600); err != nil {
+				return err
+			}
+			fmt.Printf("Kubernetes manifests written to %s\n", resolvedPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&options.Namespace, "namespace", "", "Namespace for every object (defaults to the kubectl context's)")
+	cmd.Flags().StringVar(&options.Kind, "kind", core.K8sDeployment, "Workload to run the server: deployment or daemonset")
+	cmd.Flags().StringVar(&options.ServiceType, "service-type", "LoadBalancer", "Service type: LoadBalancer, NodePort, or ClusterIP")
+	cmd.Flags().StringVar(&options.Flavor, "flavor", core.DockerLinuxServer, "Container setup: linuxserver or native")
+	cmd.Flags().StringVar(&options.Image, "image", "", "Image to use instead of the flavor's default")
+	cmd.Flags().StringVar(&outputPath, "out", "", "Path to write the manifests (defaults to stdout)")
+	return cmd
+}
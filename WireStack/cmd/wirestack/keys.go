@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// rotateKeyCommand replaces server or client keys and applies them live.
+func rotateKeyCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var tags []string
+	var rollback bool
+
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Regenerate the key pair of a server or client",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			if clientName != "" && len(tags) > 0 {
+				return fmt.Errorf("--client and --tag cannot be combined")
+			}
+			if len(tags) > 0 {
+				if rollback {
+					return fmt.Errorf("--rollback cannot be combined with --tag")
+				}
+				return runBulkAction(serverName, tags, core.BulkOptions{Action: core.BulkRotateKeys})
+			}
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if clientName == "" {
+				return rotateServerKey(profile, rollback)
+			}
+			return rotateClientKey(profile, clientName, rollback)
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Rotate this client's key instead of the server's")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Rotate the key of every client with this tag, as bulk rotate-keys does (repeatable; clients need all of them)")
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "Restore the previous key pair from the key history")
+	return cmd
+}
+
+// rotateServerKey rotates (or rolls back) the server key and updates the running interface.
+func rotateServerKey(profile *core.ServerProfile, rollback bool) error {
+	var err error
+	if rollback {
+		err = core.RollbackServerKey(profile)
+	} else {
+		err = core.RotateServerKey(profile)
+	}
+	if err != nil {
+		return err
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		return err
+	}
+
+	if err := rerenderRuntimeConfigs(profile, ""); err != nil {
+		return err
+	}
+	if iface := core.InterfaceName(profile); core.InterfaceIsUp(iface) {
+		if err := core.SetLivePrivateKey(iface, profile.ServerPrivateKey); err != nil {
+			return err
+		}
+		fmt.Printf("New key applied to running interface %s\n", iface)
+	}
+
+	fmt.Printf("Server %s public key is now %s\n", profile.Name, profile.ServerPublicKey)
+	if len(profile.Clients) > 0 {
+		fmt.Printf("Client configs embed the server public key; re-export them for all %d clients\n", len(profile.Clients))
+	}
+	return nil
+}
+
+// rotateClientKey rotates (or rolls back) a client key and swaps the live peer.
+func rotateClientKey(profile *core.ServerProfile, clientName string, rollback bool) error {
+	var replaced string
+	var err error
+	if rollback {
+		replaced, err = core.RollbackClientKey(profile, clientName)
+	} else {
+		replaced, err = core.RotateClientKey(profile, clientName)
+	}
+	if err != nil {
+		return err
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		return err
+	}
+
+	client, err := core.FindClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	if err := rerenderRuntimeConfigs(profile, clientName); err != nil {
+		return err
+	}
+	if iface := core.InterfaceName(profile); core.InterfaceIsUp(iface) {
+		if err := core.RemoveLivePeer(iface, replaced); err != nil {
+			return err
+		}
+		// A disabled client was revoked; a new key must not bring it back.
+		if client.Disabled {
+			fmt.Printf("Client %s is disabled, so its peer stays off running interface %s\n", clientName, iface)
+		} else {
+			if err := core.ApplyLivePeer(iface, *client); err != nil {
+				return err
+			}
+			fmt.Printf("Peer updated on running interface %s\n", iface)
+		}
+	}
+
+	fmt.Printf("Client %s public key is now %s\n", clientName, client.PublicKey)
+	fmt.Println("Re-export the client config and install it on the device")
+	return nil
+}
+
+// rerenderRuntimeConfigs rewrites runtime configs that already exist so the
+// next up/connect uses the new keys. An empty clientName re-renders the server
+// config and every client config.
+func rerenderRuntimeConfigs(profile *core.ServerProfile, clientName string) error {
+	if clientName == "" && profile.ExternalInterface == "" {
+		if path, err := core.ServerRuntimeConfigPath(profile.Name); err == nil && fileExists(path) {
+			if _, err := core.WriteServerConfig(profile); err != nil {
+				return err
+			}
+		}
+	}
+	for _, client := range profile.Clients {
+		if clientName != "" && client.Name != clientName {
+			continue
+		}
+		if path, err := core.ClientRuntimeConfigPath(profile.Name, client.Name); err == nil && fileExists(path) {
+			if _, err := core.WriteClientConfig(profile, client); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fileExists reports whether path names an existing file.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
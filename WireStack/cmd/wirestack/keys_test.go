@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wirestack/internal/core"
+)
+
+func TestRotateClientKeyKeepsDisabledPeerOffline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	core.SetStore(core.FileStore{})
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "wg.log")
+	script := `#!/bin/sh
+case "$1" in
+genkey) echo new-priv ;;
+pubkey) echo new-pub ;;
+show) exit 0 ;;
+set) echo "$*" >> ` + logPath + ` ;;
+*) exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake wg: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	profile := core.DefaultServerProfile("lab", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []core.ClientProfile{
+		{Name: "alice", PrivateKey: "alice-priv", PublicKey: "alice-pub", Address: "10.0.0.2/32", Disabled: true},
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+
+	if err := rotateClientKey(profile, "alice", false); err != nil {
+		t.Fatalf("rotateClientKey: %v", err)
+	}
+	if err := rotateClientKey(profile, "alice", true); err != nil {
+		t.Fatalf("rotateClientKey --rollback: %v", err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read wg log: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if !strings.HasSuffix(line, " remove") {
+			t.Fatalf("a disabled client was put back on the interface:\n%s", data)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// lintPluginCommand groups the commands that manage custom validation plugins.
+func lintPluginCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint-plugin",
+		Short: "Manage executables that add custom validation rules",
+	}
+	cmd.AddCommand(lintPluginAddCommand(), lintPluginRemoveCommand(), lintPluginListCommand())
+	return cmd
+}
+
+// lintPluginAddCommand registers a plugin executable in the global settings.
+func lintPluginAddCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <path>",
+		Short: "Register a lint plugin run by validate and up",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := utils.ExpandPath(args[0])
+			if err != nil {
+				return err
+			}
+			if path, err = filepath.Abs(path); err != nil {
+				return err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || info.Mode()&0o111 == 0 {
+				return fmt.Errorf("%s is not an executable file", path)
+			}
+
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			for _, existing := range settings.LintPlugins {
+				if existing == path {
+					return fmt.Errorf("lint plugin %s is already registered", path)
+				}
+			}
+			settings.LintPlugins = append(settings.LintPlugins, path)
+			if err := core.SaveSettings(settings); err != nil {
+				return err
+			}
+			fmt.Printf("Lint plugin %s registered\n", path)
+			return nil
+		},
+	}
+}
+
+// lintPluginRemoveCommand unregisters a plugin executable.
+func lintPluginRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <path>",
+		Short: "Unregister a lint plugin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			path, err := utils.ExpandPath(args[0])
+			if err != nil {
+				return err
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			for idx, existing := range settings.LintPlugins {
+				if existing == path {
+					settings.LintPlugins = append(settings.LintPlugins[:idx], settings.LintPlugins[idx+1:]...)
+					if err := core.SaveSettings(settings); err != nil {
+						return err
+					}
+					fmt.Printf("Lint plugin %s removed\n", path)
+					return nil
+				}
+			}
+			return fmt.Errorf("lint plugin %s is not registered", path)
+		},
+	}
+}
+
+// lintPluginListCommand prints the registered plugins.
+func lintPluginListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List registered lint plugins",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			if structuredOutput() {
+				plugins := settings.LintPlugins
+				if plugins == nil {
+					plugins = []string{}
+				}
+				return printStructured(plugins)
+			}
+			if len(settings.LintPlugins) == 0 {
+				fmt.Println("no lint plugins registered")
+				return nil
+			}
+			for _, plugin := range settings.LintPlugins {
+				fmt.Println(plugin)
+			}
+			return nil
+		},
+	}
+}
+
+// lintBeforeApply runs registered plugins against a profile and refuses to
+// continue when any of them reports an error.
+func lintBeforeApply(profile *core.ServerProfile) error {
+	settings, err := core.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if len(settings.LintPlugins) == 0 {
+		return nil
+	}
+	findings := core.RunLintPlugins(settings.LintPlugins, []*core.ServerProfile{profile})
+	if len(findings) > 0 {
+		if err := writeFindings(outputTable, findings); err != nil {
+			return err
+		}
+	}
+	if core.HasErrors(findings) {
+		return fmt.Errorf("lint plugins reported errors for server %s (use --skip-lint to override)", profile.Name)
+	}
+	return nil
+}
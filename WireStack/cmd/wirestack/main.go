@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -14,9 +17,33 @@ import (
 
 const version = "0.1.0"
 
+// backendName selects how interfaces are brought up and down (see core.NewBackend).
+var backendName string
+
+// dryRun prints what up, down, connect, and disconnect would do instead of doing it.
+var dryRun bool
+
+// dryRunCommands lists the commands that honour --dry-run.
+var dryRunCommands = map[string]bool{"up": true, "down": true, "connect": true, "disconnect": true}
+
+// storeName selects the profile store, overriding the "store" setting (see core.OpenStore).
+var storeName string
+
 // main runs the CLI entrypoint.
 func main() {
-	if err := newRootCommand().Execute(); err != nil {
+	saveRecording := startCommandRecording()
+	root := newRootCommand()
+	// A broken settings file is reported by the pre-run hook instead.
+	if settings, err := core.LoadSettings(); err == nil && len(settings.Aliases) > 0 {
+		args, err := expandAlias(root, os.Args[1:], settings.Aliases)
+		if err != nil {
+			log.Fatal(err)
+		}
+		root.SetArgs(args)
+	}
+	err := root.Execute()
+	saveRecording()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
@@ -26,6 +53,35 @@ func newRootCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "wirestack",
 		Short: "Wirestack controls local WireGuard configurations",
+		// completionCommand replaces Cobra's default to document installation.
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+	}
+
+	cmd.PersistentFlags().StringVar(&backendName, "backend", core.DefaultBackend(), "Interface backend: wg-quick, native (Linux), windows, or darwin (macOS without wg-quick)")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format for read commands: table, json, or yaml (validate also takes sarif)")
+	cmd.PersistentFlags().StringVar(&storeName, "store", "", "Profile store: file or sqlite (defaults to the store setting, then file)")
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the config files and commands up, down, connect, and disconnect would use without applying them")
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(cmd); err != nil {
+			return err
+		}
+		if dryRun {
+			if !dryRunCommands[cmd.Name()] {
+				return fmt.Errorf("--dry-run is only supported by up, down, connect, and disconnect")
+			}
+			utils.SetDryRun(os.Stdout)
+		}
+		if err := openStore(); err != nil {
+			return err
+		}
+		settings, err := core.LoadSettings()
+		if err != nil {
+			return err
+		}
+		if err := applyCurrentServer(cmd, settings); err != nil {
+			return err
+		}
+		return pickMissingNames(cmd)
 	}
 
 	cmd.AddCommand(
@@ -33,21 +89,113 @@ func newRootCommand() *cobra.Command {
 		genKeyCommand(),
 		addServerCommand(),
 		listServersCommand(),
+		editServerCommand(),
 		deleteServerCommand(),
 		addClientCommand(),
+		editClientCommand(),
+		deleteClientCommand(),
 		listClientsCommand(),
 		exportClientCommand(),
-		showServerCommand(),
-		showClientCommand(),
+		decryptCommand(),
+		exportAllCommand(),
+		exportServerCommand(),
+		exportDockerCommand(),
+		exportK8sCommand(),
+		exportCloudInitCommand(),
+		exportAnsibleCommand(),
+		showCommand(),
 		upCommand(),
 		downCommand(),
+		syncCommand(),
+		diffCommand(),
+		whatIsMyIPCommand(),
 		connectCommand(),
 		disconnectCommand(),
+		watchEndpointCommand(),
+		failoverCommand(),
+		statusCommand(),
+		healthCommand(),
+		statsCommand(),
+		setPolicyCommand(),
+		setAmneziaCommand(),
+		deletePolicyCommand(),
+		setGroupCommand(),
+		deleteGroupCommand(),
+		listGroupsCommand(),
+		bulkCommand(),
+		setVersionPolicyCommand(),
+		deleteVersionPolicyCommand(),
+		validateCommand(),
+		fsckCommand(),
+		diffConfigCommand(),
+		clockCheckCommand(),
+		setDNSRouteCommand(),
+		deleteDNSRouteCommand(),
+		serveCommand(),
+		lintPluginCommand(),
+		migrateOpenVPNCommand(),
+		migrationBundleCommand(),
+		migrationStatusCommand(),
+		rotateKeyCommand(),
+		expireCheckCommand(),
+		annotateCommand(),
+		graphCommand(),
+		backupCommand(),
+		restoreCommand(),
+		migrateCommand(),
+		addMeshCommand(),
+		addNodeCommand(),
+		listNodesCommand(),
+		exportNodeCommand(),
+		downloadTokenCommand(),
+		benchCommand(),
+		qualityCommand(),
+		mtuProbeCommand(),
+		tuneCommand(),
+		firewallCommand(),
+		systemdCommand(),
+		teardownCommand(),
+		caCommand(),
+		featuresCommand(),
+		permissionsCommand(),
+		useServerCommand(),
+		aliasCommand(),
+		demoCommand(),
+		completionCommand(),
 	)
+	registerNameCompletions(cmd)
 
 	return cmd
 }
 
+// openStore selects the profile store from --store or the global settings.
+func openStore() error {
+	if demoStore != nil {
+		core.SetStore(demoStore)
+		return nil
+	}
+	if _, err := core.RecoverTempFiles(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	settings, err := core.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if err := core.ApplyPermissionSettings(settings); err != nil {
+		return err
+	}
+	kind := storeName
+	if kind == "" {
+		kind = settings.Store
+	}
+	store, err := core.OpenStore(kind, settings)
+	if err != nil {
+		return err
+	}
+	core.SetStore(store)
+	return nil
+}
+
 // versionCommand prints the CLI version.
 func versionCommand() *cobra.Command {
 	return &cobra.Command{
@@ -80,6 +228,21 @@ func genKeyCommand() *cobra.Command {
 func addServerCommand() *cobra.Command {
 	var name string
 	var endpoint string
+	var clientExtra string
+	var subnet string
+	var subnet6 string
+	var externalInterface string
+	var description string
+	var alias string
+	var mtu int
+	var nat string
+	var natBackend string
+	var portMapping string
+	var postUp []string
+	var postDown []string
+	var networks []string
+	var searchDomains []string
+	var alternateEndpoints []string
 
 	cmd := &cobra.Command{
 		Use:   "add-server",
@@ -89,31 +252,166 @@ func addServerCommand() *cobra.Command {
 				return fmt.Errorf("both --name and --endpoint are required")
 			}
 
-			exists, err := core.ProfileExists(name)
+			unlock, err := core.LockServerProfile(name)
 			if err != nil {
 				return err
 			}
-			if exists {
-				return fmt.Errorf("server %s already exists", name)
+			defer unlock()
+			profile, err := core.NewServerProfile(core.ServerOptions{
+				Name:               name,
+				Endpoint:           endpoint,
+				Subnet:             subnet,
+				Subnet6:            subnet6,
+				ExternalInterface:  externalInterface,
+				ClientExtra:        clientExtra,
+				Description:        description,
+				Alias:              alias,
+				MTU:                mtu,
+				NATInterface:       nat,
+				NATBackend:         natBackend,
+				PortMapping:        portMapping,
+				PostUp:             postUp,
+				PostDown:           postDown,
+				Networks:           networks,
+				SearchDomains:      searchDomains,
+				AlternateEndpoints: alternateEndpoints,
+			})
+			if err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
 			}
 
-			privateKey, publicKey, err := core.GenerateKeyPair()
+			if _, ok := core.CurrentStore().(core.FileStore); ok {
+				fmt.Printf("Server %s created at %s\n", name, mustPath(core.ServerProfilePath(name)))
+			} else {
+				fmt.Printf("Server %s created\n", name)
+			}
+			if core.IsAutoEndpoint(endpoint) {
+				fmt.Printf("Detected endpoint %s\n", profile.Endpoint)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Server name")
+	cmd.Flags().StringVar(&endpoint, "endpoint", "", "Endpoint in the form ip:port, or auto[:port] to detect the public address (see whatismyip)")
+	cmd.Flags().StringVar(&subnet, "subnet", core.DefaultSubnet, "IPv4 CIDR clients are allocated from; the server takes the first host")
+	cmd.Flags().StringVar(&subnet6, "subnet6", "", "Optional IPv6 CIDR for dual-stack client addressing")
+	cmd.Flags().StringVar(&externalInterface, "external-interface", "", "Manage only the peers of an existing interface owned by another tool")
+	cmd.Flags().StringVar(&clientExtra, "client-extra", "", "Lines appended verbatim to the [Interface] section of client configs")
+	cmd.Flags().StringVar(&description, "description", "", "Human-readable description rendered as a comment in configs")
+	cmd.Flags().StringVar(&alias, "alias", "", "Interface alias set on up, shown by `ip -d link` (e.g. wirestack:prod)")
+	cmd.Flags().IntVar(&mtu, "mtu", 0, "Interface MTU rendered into the server config (0 lets wg-quick choose; see mtu-probe)")
+	cmd.Flags().StringVar(&nat, "nat", "", "Egress interface to masquerade client traffic out of (e.g. eth0); adds forwarding and NAT rules")
+	cmd.Flags().StringVar(&natBackend, "nat-backend", core.NATIptables, "Firewall tool used for --nat rules: iptables or nftables")
+	cmd.Flags().StringVar(&portMapping, "port-mapping", "", "Ask the router to forward the listen port on up: "+strings.Join(core.PortMappingMethods, ", "))
+	cmd.Flags().StringArrayVar(&postUp, "post-up", nil, "Command run after the interface comes up (repeatable; %i is the interface)")
+	cmd.Flags().StringArrayVar(&postDown, "post-down", nil, "Command run after the interface goes down (repeatable; %i is the interface)")
+	cmd.Flags().StringSliceVar(&networks, "network", nil, "Network behind the server routed to split-tunnel clients, e.g. an office LAN (repeatable)")
+	cmd.Flags().StringSliceVar(&searchDomains, "search-domain", nil, "DNS search domain rendered after the DNS servers in client configs (repeatable)")
+	cmd.Flags().StringSliceVar(&alternateEndpoints, "alternate-endpoint", nil, "Endpoint clients fail over to when the primary is unreachable, e.g. a DNS name or second port (repeatable; see failover)")
+	return cmd
+}
+
+// editServerCommand changes settings of an existing server profile.
+func editServerCommand() *cobra.Command {
+	var serverName string
+	var networks []string
+	var searchDomains []string
+	var portMapping string
+	var alternateEndpoints []string
+
+	cmd := &cobra.Command{
+		Use:   "edit-server",
+		Short: "Change a server's routed networks, DNS search domains, port mapping, or alternate endpoints",
+		Long: `Change settings of an existing server.
+
+--network replaces the networks behind the server, such as office LANs, that
+split-tunnel clients route through the tunnel; pass an empty value
+(--network "") to remove them all. Split-tunnel clients without custom
+AllowedIPs pick up the change.
+
+--search-domain replaces the search domains appended to the DNS line of
+client configs, so internal short names resolve over the tunnel; pass an
+empty value to remove them. Runtime configs that already exist are
+re-rendered.
+
+--port-mapping sets how up asks the router to forward the listen port
+(auto, upnp, or natpmp); pass an empty value to stop. It takes effect on the
+next up.
+
+--alternate-endpoint replaces the endpoints "wirestack failover" moves a
+connected client to when the primary is unreachable; pass an empty value to
+remove them. Client configs keep using the primary endpoint.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			flags := cmd.Flags()
+			if !flags.Changed("network") && !flags.Changed("search-domain") && !flags.Changed("port-mapping") && !flags.Changed("alternate-endpoint") {
+				return fmt.Errorf("nothing to change; set --network, --search-domain, --port-mapping, or --alternate-endpoint")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
 			if err != nil {
 				return err
 			}
-
-			profile := core.DefaultServerProfile(name, endpoint, privateKey, publicKey)
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if flags.Changed("network") {
+				if err := core.SetServerNetworks(profile, nonEmpty(networks)); err != nil {
+					return err
+				}
+			}
+			if flags.Changed("search-domain") {
+				if err := core.SetSearchDomains(profile, nonEmpty(searchDomains)); err != nil {
+					return err
+				}
+			}
+			if flags.Changed("port-mapping") {
+				if err := core.SetPortMapping(profile, portMapping); err != nil {
+					return err
+				}
+			}
+			if flags.Changed("alternate-endpoint") {
+				if err := core.SetAlternateEndpoints(profile, nonEmpty(alternateEndpoints)); err != nil {
+					return err
+				}
+			}
 			if err := core.SaveServerProfile(profile); err != nil {
 				return err
 			}
+			if err := rerenderRuntimeConfigs(profile, ""); err != nil {
+				return err
+			}
 
-			fmt.Printf("Server %s created at %s\n", name, mustPath(core.ServerProfilePath(name)))
+			fmt.Printf("Server %s updated\n", serverName)
+			if len(profile.Networks) > 0 {
+				fmt.Printf("Networks: %s\n", strings.Join(profile.Networks, ", "))
+			}
+			if len(profile.SearchDomains) > 0 {
+				fmt.Printf("Search domains: %s\n", strings.Join(profile.SearchDomains, ", "))
+			}
+			if profile.PortMapping != "" {
+				fmt.Printf("Port mapping: %s\n", profile.PortMapping)
+			}
+			if len(profile.AlternateEndpoints) > 0 {
+				fmt.Printf("Alternate endpoints: %s\n", strings.Join(profile.AlternateEndpoints, ", "))
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&name, "name", "", "Server name")
-	cmd.Flags().StringVar(&endpoint, "endpoint", "", "Endpoint in the form ip:port")
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringSliceVar(&networks, "network", nil, "Networks behind the server routed to split-tunnel clients (comma-separated CIDRs; empty removes them)")
+	cmd.Flags().StringSliceVar(&searchDomains, "search-domain", nil, "DNS search domains for client configs (comma-separated; empty removes them)")
+	cmd.Flags().StringVar(&portMapping, "port-mapping", "", "Router port forwarding on up: "+strings.Join(core.PortMappingMethods, ", ")+" (empty turns it off)")
+	cmd.Flags().StringSliceVar(&alternateEndpoints, "alternate-endpoint", nil, "Endpoints clients fail over to, in order (comma-separated host:port; empty removes them)")
 	return cmd
 }
 
@@ -127,6 +425,17 @@ func listServersCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if structuredOutput() {
+				views := make([]serverView, 0, len(names))
+				for _, name := range names {
+					profile, err := core.LoadServerProfile(name)
+					if err != nil {
+						return err
+					}
+					views = append(views, newServerView(profile))
+				}
+				return printStructured(views)
+			}
 			if len(names) == 0 {
 				fmt.Println("no servers found")
 				return nil
@@ -142,15 +451,24 @@ func listServersCommand() *cobra.Command {
 // deleteServerCommand removes a server profile by name.
 func deleteServerCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "delete-server <name>",
-		Short: "Delete a server profile",
-		Args:  cobra.ExactArgs(1),
+		Use:               "delete-server <name>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Delete a server profile",
+		Args:              cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
 			if name == "" {
 				return fmt.Errorf("server name is required")
 			}
-			return core.DeleteServerProfile(name)
+			unlock, err := core.LockServerProfile(name)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			if err := core.DeleteServerProfile(name); err != nil {
+				return err
+			}
+			return forgetCurrentServer(name)
 		},
 	}
 }
@@ -159,6 +477,16 @@ func deleteServerCommand() *cobra.Command {
 func addClientCommand() *cobra.Command {
 	var serverName string
 	var clientName string
+	var extra string
+	var description string
+	var expires string
+	var mode string
+	var routes []string
+	var tags []string
+	var allowedIPs []string
+	var dns []string
+	var mtu int
+	var group string
 
 	cmd := &cobra.Command{
 		Use:   "add-client",
@@ -168,146 +496,635 @@ func addClientCommand() *cobra.Command {
 				return fmt.Errorf("both --server and --client are required")
 			}
 
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
 			profile, err := core.LoadServerProfile(serverName)
 			if err != nil {
 				return err
 			}
 
-			if _, err := core.FindClient(profile, clientName); err == nil {
-				return fmt.Errorf("client %s already exists on server %s", clientName, serverName)
+			options := core.ClientOptions{Name: clientName, Tags: tags, Extra: extra, Description: description, Mode: mode, SplitRoutes: routes, AllowedIPs: allowedIPs, DNS: dns, MTU: mtu, Group: group}
+			if expires != "" {
+				expiresAt, err := core.ParseExpiry(expires, time.Now())
+				if err != nil {
+					return err
+				}
+				options.ExpiresAt = &expiresAt
 			}
 
-			privateKey, publicKey, err := core.GenerateKeyPair()
+			client, err := core.AddClient(profile, options)
 			if err != nil {
 				return err
 			}
 
-			address, err := core.NextClientAddress(profile)
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+
+			if profile.ExternalInterface != "" && core.InterfaceIsUp(profile.ExternalInterface) {
+				if err := core.ApplyLivePeer(profile.ExternalInterface, client); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("Client %s added to server %s\n", clientName, serverName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Client name")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Tag applied to the client (repeatable)")
+	cmd.Flags().StringVar(&extra, "extra", "", "Lines appended to this client's [Interface] section, overriding the server default")
+	cmd.Flags().StringVar(&description, "description", "", "Human-readable description rendered as a comment in configs")
+	cmd.Flags().StringVar(&expires, "expires", "", "Expiry as RFC 3339 time, YYYY-MM-DD, or duration (e.g. 30d); enforced by expire-check")
+	cmd.Flags().StringVar(&mode, "mode", core.ClientModeFull, "Tunnel mode: full (all traffic) or split (VPN subnet and --route networks only)")
+	cmd.Flags().StringSliceVar(&routes, "route", nil, "Extra network routed through the tunnel in split mode (repeatable)")
+	cmd.Flags().StringSliceVar(&allowedIPs, "allowed-ips", nil, "AllowedIPs for this client, overriding --mode and tag policies (comma-separated CIDRs)")
+	cmd.Flags().StringSliceVar(&dns, "dns", nil, "DNS servers for this client, overriding the server's (comma-separated IPs)")
+	cmd.Flags().IntVar(&mtu, "mtu", 0, "Interface MTU rendered into this client's config (0 uses the export target's default)")
+	cmd.Flags().StringVar(&group, "group", "", "Group whose AllowedIPs and DNS the client uses (see set-group)")
+	return cmd
+}
+
+// editClientCommand changes the routing and DNS settings of an existing client.
+func editClientCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var mode string
+	var routes []string
+	var allowedIPs []string
+	var dns []string
+	var forwards []string
+	var tags []string
+	var mtu int
+	var group string
+
+	cmd := &cobra.Command{
+		Use:   "edit-client",
+		Short: "Change a client's tunnel mode, AllowedIPs, DNS servers, MTU, port forwards, tags, or group",
+		Long: `Change a client's tunnel mode, AllowedIPs, DNS servers, MTU, port forwards, tags, or group.
+
+--allowed-ips and --dns override the server defaults for this client only;
+pass an empty value (--dns "") to go back to the server default. Setting
+--mode replaces any custom AllowedIPs. Runtime configs that already exist
+are re-rendered.
+
+--forward exposes a service on the client through the server, written as
+[tcp|udp:]public[:client], e.g. --forward tcp:8080:80. The DNAT rules are
+rendered next to the server's NAT rules, so the server needs --nat. The
+flag replaces the client's forwards; --forward "" removes them.
+
+--tag replaces the client's tags in the same way; --tag "" removes them.
+Tags select clients for policies and for --tag on list-clients,
+export-client, delete-client, and rotate-key.
+
+--group moves the client into a group made with set-group, whose AllowedIPs
+and DNS apply unless the client overrides them; --group "" takes it out.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || clientName == "" {
+				return fmt.Errorf("both --server and --client are required")
+			}
+			flags := cmd.Flags()
+			if !flags.Changed("mode") && !flags.Changed("allowed-ips") && !flags.Changed("dns") && !flags.Changed("mtu") && !flags.Changed("forward") && !flags.Changed("tag") && !flags.Changed("group") {
+				return fmt.Errorf("nothing to change; set --mode, --allowed-ips, --dns, --mtu, --forward, --tag, or --group")
+			}
+			if len(routes) > 0 && !flags.Changed("mode") {
+				return fmt.Errorf("--route requires --mode split")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			client, err := core.FindClient(profile, clientName)
 			if err != nil {
 				return err
 			}
+			if flags.Changed("mode") {
+				if err := core.ApplyClientMode(profile, client, mode, routes); err != nil {
+					return err
+				}
+			}
+			if flags.Changed("allowed-ips") {
+				if err := core.SetClientAllowedIPs(client, nonEmpty(allowedIPs)); err != nil {
+					return err
+				}
+			}
+			if flags.Changed("dns") {
+				if err := core.SetClientDNS(client, nonEmpty(dns)); err != nil {
+					return err
+				}
+			}
+			if flags.Changed("mtu") {
+				if err := core.ValidateMTU(mtu); err != nil {
+					return err
+				}
+				client.MTU = mtu
+			}
+			if flags.Changed("tag") {
+				// Tag policies can change the AllowedIPs rendered for the client.
+				core.SetClientTags(client, tags)
+			}
+			if flags.Changed("group") {
+				if err := core.SetClientGroup(profile, client, strings.TrimSpace(group)); err != nil {
+					return err
+				}
+			}
+			rerender := clientName
+			if flags.Changed("forward") {
+				var parsed []core.PortForward
+				for _, spec := range nonEmpty(forwards) {
+					forward, err := core.ParsePortForward(spec)
+					if err != nil {
+						return err
+					}
+					parsed = append(parsed, forward)
+				}
+				if err := core.SetClientPortForwards(profile, client, parsed); err != nil {
+					return err
+				}
+				// The forwards live in the server config's NAT rules.
+				rerender = ""
+			}
+			if _, err := core.BuildClientConfig(profile, *client); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+			if err := rerenderRuntimeConfigs(profile, rerender); err != nil {
+				return err
+			}
+
+			fmt.Printf("Client %s updated on server %s\n", clientName, serverName)
+			fmt.Printf("AllowedIPs: %s\n", strings.Join(core.EffectiveAllowedIPs(profile, *client), ", "))
+			if servers := core.ClientDNS(profile, *client); len(servers) > 0 {
+				fmt.Printf("DNS: %s\n", strings.Join(servers, ", "))
+			}
+			printPortForwards(client.PortForwards)
+			if len(client.Tags) > 0 {
+				fmt.Printf("Tags: %s\n", strings.Join(client.Tags, ", "))
+			}
+			if client.Group != "" {
+				fmt.Printf("Group: %s\n", client.Group)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Client name")
+	cmd.Flags().StringVar(&mode, "mode", "", "Tunnel mode: full or split; replaces any custom AllowedIPs")
+	cmd.Flags().StringSliceVar(&routes, "route", nil, "Extra network routed through the tunnel in split mode (repeatable)")
+	cmd.Flags().StringSliceVar(&allowedIPs, "allowed-ips", nil, "AllowedIPs for this client (comma-separated CIDRs; empty restores the mode's)")
+	cmd.Flags().StringSliceVar(&dns, "dns", nil, "DNS servers for this client (comma-separated IPs; empty restores the server's)")
+	cmd.Flags().IntVar(&mtu, "mtu", 0, "Interface MTU for this client (0 restores the export target's default)")
+	cmd.Flags().StringSliceVar(&forwards, "forward", nil, "Port forward [tcp|udp:]public[:client] from the server to this client (repeatable; empty removes all)")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Tags for this client, replacing its current ones (repeatable; empty removes all)")
+	cmd.Flags().StringVar(&group, "group", "", "Group for this client (empty takes it out of its group)")
+	return cmd
+}
+
+// printPortForwards lists a client's port forwards, if any.
+func printPortForwards(forwards []core.PortForward) {
+	if len(forwards) > 0 {
+		fmt.Printf("Port forwards: %s\n", strings.Join(portForwardSpecs(forwards), ", "))
+	}
+}
+
+// checkClientSelector requires exactly one of --client and --tag.
+func checkClientSelector(clientName string, tags []string) error {
+	switch {
+	case clientName != "" && len(tags) > 0:
+		return fmt.Errorf("--client and --tag cannot be combined")
+	case clientName == "" && len(tags) == 0:
+		return fmt.Errorf("--client or --tag is required")
+	}
+	return nil
+}
+
+// nonEmpty drops empty entries, so that --flag "" yields an empty list.
+func nonEmpty(values []string) []string {
+	var kept []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// deleteClientCommand removes a client from a server profile and its rendered config.
+func deleteClientCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var tags []string
+	var live bool
+
+	cmd := &cobra.Command{
+		Use:   "delete-client",
+		Short: "Remove a client, or every client with a tag, from a server profile",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			if err := checkClientSelector(clientName, tags); err != nil {
+				return err
+			}
+			if len(tags) > 0 {
+				return runBulkAction(serverName, tags, core.BulkOptions{Action: core.BulkDelete})
+			}
 
-			client := core.ClientProfile{
-				Name:       clientName,
-				PrivateKey: privateKey,
-				PublicKey:  publicKey,
-				Address:    address,
-				AllowedIPs: core.ClientAllowedIPs(),
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
 			}
 
-			profile.Clients = append(profile.Clients, client)
+			removed, err := core.RemoveClient(profile, clientName)
+			if err != nil {
+				return err
+			}
 
 			if err := core.SaveServerProfile(profile); err != nil {
 				return err
 			}
 
-			fmt.Printf("Client %s added to server %s\n", clientName, serverName)
+			if runtimePath, err := core.ClientRuntimeConfigPath(serverName, clientName); err == nil {
+				_ = os.Remove(runtimePath)
+			}
+
+			// External interfaces are only ever managed through their peers, so always sync removals.
+			if live || profile.ExternalInterface != "" {
+				iface := core.InterfaceName(profile)
+				if core.InterfaceIsUp(iface) {
+					if err := core.RemoveLivePeer(iface, removed.PublicKey); err != nil {
+						return err
+					}
+					fmt.Printf("Peer removed from running interface %s\n", iface)
+				}
+			}
+
+			fmt.Printf("Client %s removed from server %s\n", clientName, serverName)
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
 	cmd.Flags().StringVar(&clientName, "client", "", "Client name")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Remove every client with this tag instead of --client, as bulk delete does (repeatable; clients need all of them)")
+	cmd.Flags().BoolVar(&live, "live", false, "Also remove the peer from the running interface if it is up (always done with --tag)")
 	return cmd
 }
 
 // listClientsCommand prints clients for a specific server.
 func listClientsCommand() *cobra.Command {
 	var serverName string
+	var all bool
+	var platform bool
+	var tags []string
 
 	cmd := &cobra.Command{
 		Use:   "list-clients",
 		Short: "List clients for a server",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if all {
+				return listAllClients(platform, tags)
+			}
 			if serverName == "" {
-				return fmt.Errorf("--server is required")
+				return fmt.Errorf("--server or --all is required")
 			}
 			profile, err := core.LoadServerProfile(serverName)
 			if err != nil {
 				return err
 			}
+			if len(tags) > 0 {
+				var tagged []core.ClientProfile
+				for _, client := range profile.Clients {
+					if core.HasAllTags(client, tags) {
+						tagged = append(tagged, client)
+					}
+				}
+				profile.Clients = tagged
+			}
+			if structuredOutput() {
+				return printStructured(newServerView(profile).Clients)
+			}
 			if len(profile.Clients) == 0 {
 				fmt.Println("no clients found")
 				return nil
 			}
+			now := time.Now()
 			for _, client := range profile.Clients {
-				fmt.Printf("%s\t%s\n", client.Name, client.Address)
+				fmt.Printf("%s\t%s%s%s\n", client.Name, strings.Join(core.ClientAddresses(client), ", "), clientStateNote(client, now), platformNote(client, platform))
 			}
+			warnExpiredClients(profile.Name, profile.Clients, now)
+			warnOutdatedClients(profile)
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().BoolVar(&all, "all", false, "List clients across every server")
+	cmd.Flags().BoolVar(&platform, "platform", false, "Show the device platform each client's agent last reported")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Only list clients with this tag (repeatable; clients need all of them)")
 	return cmd
 }
 
+// platformNote returns the client's reported platform as an extra column
+// when show is set.
+func platformNote(client core.ClientProfile, show bool) string {
+	if !show {
+		return ""
+	}
+	if client.Platform == nil {
+		return "\tplatform unknown"
+	}
+	return fmt.Sprintf("\t%s (reported %s)", client.Platform.Summary(), client.Platform.ReportedAt.Local().Format("2006-01-02"))
+}
+
+// listAllClients prints every client of every server that carries all of tags.
+func listAllClients(platform bool, tags []string) error {
+	all, err := core.AllClients()
+	if err != nil {
+		return err
+	}
+	records := all[:0]
+	for _, record := range all {
+		if core.HasAllTags(record.Client, tags) {
+			records = append(records, record)
+		}
+	}
+	if structuredOutput() {
+		views := make([]clientView, 0, len(records))
+		profiles := map[string]*core.ServerProfile{}
+		for _, record := range records {
+			profile, ok := profiles[record.Server]
+			if !ok {
+				if profile, err = core.LoadServerProfile(record.Server); err != nil {
+					return err
+				}
+				profiles[record.Server] = profile
+			}
+			views = append(views, newClientView(profile, record.Client))
+		}
+		return printStructured(views)
+	}
+	if len(records) == 0 {
+		fmt.Println("no clients found")
+		return nil
+	}
+	now := time.Now()
+	for _, record := range records {
+		fmt.Printf("%s\t%s\t%s%s%s\n", record.Server, record.Client.Name, strings.Join(core.ClientAddresses(record.Client), ", "), clientStateNote(record.Client, now), platformNote(record.Client, platform))
+		warnExpiredClients(record.Server, []core.ClientProfile{record.Client}, now)
+	}
+	warned := map[string]bool{}
+	for _, record := range records {
+		if warned[record.Server] {
+			continue
+		}
+		warned[record.Server] = true
+		profile, err := core.LoadServerProfile(record.Server)
+		if err != nil {
+			return err
+		}
+		warnOutdatedClients(profile)
+	}
+	return nil
+}
+
+// clientExportOptions are the export-client flags that shape each config.
+type clientExportOptions struct {
+	target     string
+	killSwitch bool
+	amnezia    bool
+	encryptTo  []string
+	mode       string
+	routes     []string
+}
+
 // exportClientCommand writes a WireGuard client configuration to a given path.
 func exportClientCommand() *cobra.Command {
 	var serverName string
 	var clientName string
+	var tags []string
 	var outputPath string
+	var options clientExportOptions
 
 	cmd := &cobra.Command{
 		Use:   "export-client",
 		Short: "Export a WireGuard client configuration",
+		Long: `Export a WireGuard client configuration.
+
+With --tag instead of --client, the config of every enabled client carrying
+the tags is written into the --out directory, as bulk export does.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if serverName == "" || clientName == "" || outputPath == "" {
-				return fmt.Errorf("--server, --client, and --output are required")
+			if serverName == "" || outputPath == "" {
+				return fmt.Errorf("--server, --client, and --out are required")
+			}
+			if err := checkClientSelector(clientName, tags); err != nil {
+				return err
+			}
+			if len(options.routes) > 0 && options.mode == "" {
+				return fmt.Errorf("--route requires --mode %s", core.ClientModeSplit)
+			}
+
+			resolvedPath, err := utils.ExpandPath(outputPath)
+			if err != nil {
+				return err
+			}
+			if len(tags) > 0 {
+				if len(options.encryptTo) > 0 || options.mode != "" {
+					return fmt.Errorf("--encrypt-to and --mode apply to a single --client, not --tag")
+				}
+				return runBulkAction(serverName, tags, core.BulkOptions{
+					Action: core.BulkExport,
+					Dir:    resolvedPath,
+					Export: core.ExportOptions{Render: core.ClientRenderOptions{Target: options.target, KillSwitch: options.killSwitch, Amnezia: options.amnezia}},
+				})
 			}
 
 			profile, err := core.LoadServerProfile(serverName)
 			if err != nil {
 				return err
 			}
+			return exportClient(profile, clientName, resolvedPath, options)
+		},
+	}
 
-			client, err := core.FindClient(profile, clientName)
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Client name")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Export every client with this tag instead of --client (repeatable; clients need all of them)")
+	cmd.Flags().StringVar(&outputPath, "out", "", "Path (or directory) to write the client configuration; a directory with --tag")
+	cmd.Flags().StringVar(&options.target, "target", core.TargetLinux, "Client platform: "+strings.Join(core.ClientTargets, ", "))
+	cmd.Flags().BoolVar(&options.killSwitch, "kill-switch", false, "Block traffic outside the tunnel (rules on Linux, instructions elsewhere)")
+	cmd.Flags().BoolVar(&options.amnezia, "amnezia", false, "Render the server's AmneziaWG parameters for the AmneziaWG app (see set-amnezia)")
+	cmd.Flags().StringSliceVar(&options.encryptTo, "encrypt-to", nil, "Encrypt the file to this age public key (age1...) with the age CLI (repeatable)")
+	cmd.Flags().StringVar(&options.mode, "mode", "", "Override the client's tunnel mode for this export: full or split")
+	cmd.Flags().StringSliceVar(&options.routes, "route", nil, "Extra network routed through the tunnel with --mode split (repeatable)")
+	return cmd
+}
+
+// exportClient renders one client's config and writes it to path, or into it
+// when path is a directory.
+func exportClient(profile *core.ServerProfile, clientName, path string, options clientExportOptions) error {
+	client, err := core.FindClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	// --mode renders this export only; the stored mode is set by add-client.
+	if options.mode != "" {
+		if err := core.ApplyClientMode(profile, client, options.mode, options.routes); err != nil {
+			return err
+		}
+	}
+
+	config, err := core.BuildClientConfigFor(profile, *client, core.ClientRenderOptions{Target: options.target, KillSwitch: options.killSwitch, Amnezia: options.amnezia})
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, core.ClientConfigFileName(profile.Name, clientName, options.target))
+		if len(options.encryptTo) > 0 {
+			path += ".age"
+		}
+	}
+	if len(options.encryptTo) > 0 {
+		if config, err = core.EncryptConfig(config, options.encryptTo); err != nil {
+			return err
+		}
+	}
+
+	if err := utils.WriteFile(path, []byte(config), 0o600); err != nil {
+		return err
+	}
+
+	if len(options.encryptTo) > 0 {
+		fmt.Printf("Encrypted client configuration written to %s (decrypt with: wirestack decrypt %s --identity <key file>)\n", path, filepath.Base(path))
+		return nil
+	}
+	fmt.Printf("Client configuration written to %s\n", path)
+	return nil
+}
+
+// exportServerCommand renders a server configuration to stdout or a file.
+func exportServerCommand() *cobra.Command {
+	var serverName string
+	var format string
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "export-server",
+		Short: "Export a WireGuard server configuration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+
+			profile, err := core.LoadServerProfile(serverName)
 			if err != nil {
 				return err
 			}
 
-			config, err := core.BuildClientConfig(profile, *client)
+			var config string
+			switch format {
+			case "wg-quick":
+				config, err = core.BuildServerConfig(profile)
+			case "wg-syncconf":
+				config, err = core.BuildServerSyncConfig(profile)
+			default:
+				return fmt.Errorf("unsupported format %q (want wg-quick or wg-syncconf)", format)
+			}
 			if err != nil {
 				return err
 			}
 
+			if outputPath == "" {
+				fmt.Print(config)
+				return nil
+			}
+
 			resolvedPath, err := utils.ExpandPath(outputPath)
 			if err != nil {
 				return err
 			}
-
 			if err := utils.WriteFile(resolvedPath, []byte(config), 0o600); err != nil {
 				return err
 			}
 
-			fmt.Printf("Client configuration written to %s\n", resolvedPath)
+			fmt.Printf("Server configuration written to %s\n", resolvedPath)
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
-	cmd.Flags().StringVar(&clientName, "client", "", "Client name")
-	cmd.Flags().StringVar(&outputPath, "output", "", "Path to write the client configuration")
+	cmd.Flags().StringVar(&format, "format", "wg-quick", "Output format: wg-quick or wg-syncconf")
+	cmd.Flags().StringVar(&outputPath, "out", "", "Path to write the server configuration (defaults to stdout)")
+	return cmd
+}
+
+// showCommand groups the show subcommands for servers and clients.
+func showCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show server or client details",
+	}
+	cmd.AddCommand(showServerCommand(), showClientCommand())
 	return cmd
 }
 
 // showServerCommand displays the stored server profile.
 func showServerCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "show server <name>",
-		Short: "Show server profile details",
-		Args:  cobra.ExactArgs(2),
+		Use:               "server <name>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Show server profile details",
+		Args:              cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if args[0] != "server" {
-				return fmt.Errorf("usage: wirestack show server <name>")
-			}
-			name := args[1]
+			name := args[0]
 			profile, err := core.LoadServerProfile(name)
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Name: %s\nEndpoint: %s\nAddress: %s\nClients: %d\n", profile.Name, profile.Endpoint, profile.Address, len(profile.Clients))
+			if structuredOutput() {
+				return printStructured(newServerView(profile))
+			}
+			fmt.Printf("Name: %s\nEndpoint: %s\nAddress: %s\nClients: %d\n", profile.Name, profile.Endpoint, strings.Join(core.ServerAddresses(profile), ", "), len(profile.Clients))
+			if len(profile.AlternateEndpoints) > 0 {
+				fmt.Printf("Alternate endpoints: %s\n", strings.Join(profile.AlternateEndpoints, ", "))
+			}
+			if len(profile.Networks) > 0 {
+				fmt.Printf("Networks: %s\n", strings.Join(profile.Networks, ", "))
+			}
+			if len(profile.SearchDomains) > 0 {
+				fmt.Printf("Search domains: %s\n", strings.Join(profile.SearchDomains, ", "))
+			}
+			if profile.Amnezia != nil {
+				fmt.Printf("AmneziaWG: %s\n", strings.Join(profile.Amnezia.Lines(), ", "))
+			}
 			for _, client := range profile.Clients {
-				fmt.Printf("- %s (%s)\n", client.Name, client.Address)
+				fmt.Printf("- %s (%s)\n", client.Name, strings.Join(core.ClientAddresses(client), ", "))
+			}
+			for _, policy := range profile.Policies {
+				fmt.Printf("Policy: tag %s -> %s\n", policy.Tag, strings.Join(policy.AllowedIPs, ", "))
+			}
+			for _, group := range profile.Groups {
+				fmt.Printf("Group: %s (%d members)\n", group.Name, len(core.GroupMembers(profile, group.Name)))
 			}
 			return nil
 		},
@@ -317,15 +1134,13 @@ func showServerCommand() *cobra.Command {
 // showClientCommand displays client details from a server.
 func showClientCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "show client <server> <client>",
-		Short: "Show client details",
-		Args:  cobra.ExactArgs(3),
+		Use:               "client <server> <client>",
+		ValidArgsFunction: completeServerClientArgs,
+		Short:             "Show client details",
+		Args:              cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if args[0] != "client" {
-				return fmt.Errorf("usage: wirestack show client <server> <client>")
-			}
-			serverName := args[1]
-			clientName := args[2]
+			serverName := args[0]
+			clientName := args[1]
 			profile, err := core.LoadServerProfile(serverName)
 			if err != nil {
 				return err
@@ -334,7 +1149,20 @@ func showClientCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Server: %s\nClient: %s\nAddress: %s\nPublicKey: %s\nAllowedIPs: %s\n", serverName, client.Name, client.Address, client.PublicKey, strings.Join(client.AllowedIPs, ", "))
+			if structuredOutput() {
+				return printStructured(newClientView(profile, *client))
+			}
+			fmt.Printf("Server: %s\nClient: %s\nAddress: %s\nPublicKey: %s\nAllowedIPs: %s\n", serverName, client.Name, strings.Join(core.ClientAddresses(*client), ", "), client.PublicKey, strings.Join(core.EffectiveAllowedIPs(profile, *client), ", "))
+			if servers := core.ClientDNS(profile, *client); len(servers) > 0 {
+				fmt.Printf("DNS: %s\n", strings.Join(servers, ", "))
+			}
+			if len(client.Tags) > 0 {
+				fmt.Printf("Tags: %s\n", strings.Join(client.Tags, ", "))
+			}
+			if client.Group != "" {
+				fmt.Printf("Group: %s\n", client.Group)
+			}
+			printPortForwards(client.PortForwards)
 			return nil
 		},
 	}
@@ -342,52 +1170,141 @@ func showClientCommand() *cobra.Command {
 
 // upCommand generates and brings up a WireGuard interface for a server profile.
 func upCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "up <server>",
-		Short: "Bring up the WireGuard interface for a server",
-		Args:  cobra.ExactArgs(1),
+	var skipLint bool
+	var chunkSize int
+	var resume bool
+
+	cmd := &cobra.Command{
+		Use:               "up <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Bring up the WireGuard interface for a server",
+		Args:              cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			serverName := args[0]
 			profile, err := core.LoadServerProfile(serverName)
 			if err != nil {
 				return err
 			}
+			if !skipLint {
+				if err := lintBeforeApply(profile); err != nil {
+					return err
+				}
+			}
+			if profile.ExternalInterface != "" {
+				result, err := core.SyncLivePeers(profile, core.PeerSyncOptions{
+					ChunkSize: chunkSize,
+					Resume:    resume,
+					Progress:  peerSyncProgress(chunkSize),
+				})
+				var syncErr *core.PeerSyncError
+				if errors.As(err, &syncErr) {
+					for _, failure := range syncErr.Result.Failed {
+						fmt.Fprintf(os.Stderr, "failed: %s (%s): %v\n", failure.Client, failure.PublicKey, failure.Err)
+					}
+				}
+				if err != nil {
+					cmd.SilenceUsage = true
+					return err
+				}
+				if profile.Alias != "" {
+					if err := core.SetInterfaceAlias(profile.ExternalInterface, profile.Alias); err != nil {
+						return err
+					}
+				}
+				if !dryRun {
+					fmt.Printf("Applied %d peers to external interface %s", result.Applied, profile.ExternalInterface)
+					if result.Skipped > 0 {
+						fmt.Printf(" (%d already applied before resuming)", result.Skipped)
+					}
+					fmt.Println()
+				}
+				return nil
+			}
 			configPath, err := core.WriteServerConfig(profile)
 			if err != nil {
 				return err
 			}
-			output, err := utils.RunCommand("wg-quick", "up", configPath)
+			backend, err := core.NewBackend(backendName)
 			if err != nil {
 				return err
 			}
-			if output != "" {
-				fmt.Println(output)
+			output, err := backend.Up(configPath)
+			if err != nil {
+				return err
+			}
+			printBackendOutput(output)
+			if err := core.RecordArtifacts(profile.Name, core.ServerArtifacts(profile)...); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+			if profile.PortMapping != "" && !dryRun {
+				if err := mapServerPort(profile); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: port mapping: %v\n", err)
+				}
 			}
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&skipLint, "skip-lint", false, "Bring the interface up even if lint plugins report errors")
+	cmd.Flags().IntVar(&chunkSize, "chunk-size", core.DefaultPeerChunkSize, "Peers applied per wg invocation on an external interface")
+	cmd.Flags().BoolVar(&resume, "resume", false, "Skip peers an interrupted or partially failed sync already applied to the external interface")
+	return cmd
+}
+
+// peerSyncProgress reports sync progress on stderr for syncs that span more
+// than one chunk.
+func peerSyncProgress(chunkSize int) func(done, total int) {
+	if chunkSize <= 0 {
+		chunkSize = core.DefaultPeerChunkSize
+	}
+	return func(done, total int) {
+		if total > chunkSize && !dryRun {
+			fmt.Fprintf(os.Stderr, "applied %d/%d peers\n", done, total)
+		}
+	}
 }
 
 // downCommand brings down a WireGuard interface for a server profile.
 func downCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "down <server>",
-		Short: "Bring down the WireGuard interface for a server",
-		Args:  cobra.ExactArgs(1),
+		Use:               "down <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Bring down the WireGuard interface for a server",
+		Args:              cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			serverName := args[0]
+			if profile, err := core.LoadServerProfile(serverName); err == nil && profile.ExternalInterface != "" {
+				if err := core.RemoveLivePeers(profile); err != nil {
+					return err
+				}
+				if !dryRun {
+					fmt.Printf("Removed %d peers from external interface %s\n", len(profile.Clients), profile.ExternalInterface)
+				}
+				return nil
+			}
 			configPath, err := core.ServerRuntimeConfigPath(serverName)
 			if err != nil {
 				return err
 			}
-			output, err := utils.RunCommand("wg-quick", "down", configPath)
+			backend, err := core.NewBackend(backendName)
 			if err != nil {
 				return err
 			}
-			if output != "" {
-				fmt.Println(output)
+			output, err := backend.Down(configPath)
+			if err != nil {
+				return err
+			}
+			printBackendOutput(output)
+			if !dryRun {
+				_ = os.Remove(configPath)
+			}
+			// The PostDown hooks removed the NAT rules; qdiscs on other interfaces stay.
+			if err := core.ForgetArtifacts(serverName, core.ArtifactNAT, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+			if !dryRun {
+				releasePortMappings(serverName)
 			}
-			_ = os.Remove(configPath)
 			return nil
 		},
 	}
@@ -397,6 +1314,8 @@ func downCommand() *cobra.Command {
 func connectCommand() *cobra.Command {
 	var serverName string
 	var clientName string
+	var ntpServer string
+	var maxClockSkew time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "connect",
@@ -419,19 +1338,25 @@ func connectCommand() *cobra.Command {
 				return err
 			}
 
-			output, err := utils.RunCommand("wg-quick", "up", configPath)
+			warnOnClockSkew(ntpServer, maxClockSkew)
+
+			backend, err := core.NewBackend(backendName)
 			if err != nil {
 				return err
 			}
-			if output != "" {
-				fmt.Println(output)
+			output, err := backend.Up(configPath)
+			if err != nil {
+				return err
 			}
+			printBackendOutput(output)
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
 	cmd.Flags().StringVar(&clientName, "client", "", "Client name to connect with")
+	cmd.Flags().StringVar(&ntpServer, "ntp-server", defaultNTPServer, "NTP server used for the clock skew check")
+	cmd.Flags().DurationVar(&maxClockSkew, "max-clock-skew", defaultMaxClockSkew, "Warn when the local clock is off by more than this (0 disables the check)")
 	return cmd
 }
 
@@ -462,14 +1387,18 @@ func disconnectCommand() *cobra.Command {
 				return err
 			}
 
-			output, err := utils.RunCommand("wg-quick", "down", configPath)
+			backend, err := core.NewBackend(backendName)
 			if err != nil {
 				return err
 			}
-			if output != "" {
-				fmt.Println(output)
+			output, err := backend.Down(configPath)
+			if err != nil {
+				return err
+			}
+			printBackendOutput(output)
+			if !dryRun {
+				_ = os.Remove(configPath)
 			}
-			_ = os.Remove(configPath)
 			return nil
 		},
 	}
@@ -479,6 +1408,14 @@ func disconnectCommand() *cobra.Command {
 	return cmd
 }
 
+// printBackendOutput shows what a backend reported, which only describes real
+// changes and is skipped in a dry run.
+func printBackendOutput(output string) {
+	if output != "" && !dryRun {
+		fmt.Println(output)
+	}
+}
+
 // mustPath resolves a path helper while ignoring errors that have already been validated.
 func mustPath(path string, err error) string {
 	if err != nil {
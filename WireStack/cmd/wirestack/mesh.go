@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// addMeshCommand creates an empty full-mesh network.
+func addMeshCommand() *cobra.Command {
+	var name string
+	var subnet string
+	var listenPort int
+
+	cmd := &cobra.Command{
+		Use:   "add-mesh",
+		Short: "Create a full-mesh network where every node peers with every other",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name == "" {
+				return fmt.Errorf("--name is required")
+			}
+			mesh, err := core.NewMeshProfile(name, subnet, listenPort)
+			if err != nil {
+				return err
+			}
+			if err := core.SaveMeshProfile(mesh); err != nil {
+				return err
+			}
+			fmt.Printf("Mesh %s created with subnet %s\n", mesh.Name, mesh.Subnet)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Mesh name")
+	cmd.Flags().StringVar(&subnet, "subnet", core.DefaultMeshSubnet, "IPv4 network node addresses are allocated from")
+	cmd.Flags().IntVar(&listenPort, "listen-port", core.DefaultMeshListenPort, "Port nodes listen on when their endpoint does not set one")
+	return cmd
+}
+
+// addNodeCommand adds a node to a mesh.
+func addNodeCommand() *cobra.Command {
+	var meshName string
+	var nodeName string
+	var endpoint string
+	var routes []string
+
+	cmd := &cobra.Command{
+		Use:   "add-node",
+		Short: "Add a node to a mesh network",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if meshName == "" || nodeName == "" {
+				return fmt.Errorf("both --mesh and --node are required")
+			}
+			mesh, err := core.LoadMeshProfile(meshName)
+			if err != nil {
+				return err
+			}
+			node, err := core.AddMeshNode(mesh, core.MeshNodeOptions{Name: nodeName, Endpoint: endpoint, Routes: routes})
+			if err != nil {
+				return err
+			}
+			if err := core.SaveMeshProfile(mesh); err != nil {
+				return err
+			}
+
+			fmt.Printf("Node %s added to mesh %s with address %s\n", node.Name, mesh.Name, node.Address)
+			if others := len(mesh.Nodes) - 1; others > 0 {
+				fmt.Printf("Re-export the configs of the other %d node(s) so they peer with %s\n", others, node.Name)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&meshName, "mesh", "", "Mesh name")
+	cmd.Flags().StringVar(&nodeName, "node", "", "Node name")
+	cmd.Flags().StringVar(&endpoint, "endpoint", "", "Public host:port other nodes dial; omit for nodes behind NAT")
+	cmd.Flags().StringSliceVar(&routes, "route", nil, "Network behind this node that other nodes route through it (repeatable)")
+	_ = cmd.RegisterFlagCompletionFunc("mesh", completeMeshFlag)
+	return cmd
+}
+
+// listNodesCommand lists the nodes of a mesh.
+func listNodesCommand() *cobra.Command {
+	var meshName string
+
+	cmd := &cobra.Command{
+		Use:   "list-nodes",
+		Short: "List the nodes of a mesh network",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if meshName == "" {
+				return fmt.Errorf("--mesh is required")
+			}
+			mesh, err := core.LoadMeshProfile(meshName)
+			if err != nil {
+				return err
+			}
+			if structuredOutput() {
+				return printStructured(newMeshView(mesh))
+			}
+			if len(mesh.Nodes) == 0 {
+				fmt.Println("no nodes found")
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "NODE\tADDRESS\tENDPOINT\tROUTES")
+			for _, node := range mesh.Nodes {
+				endpoint := node.Endpoint
+				if endpoint == "" {
+					endpoint = "-"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", node.Name, node.Address, endpoint, strings.Join(node.Routes, ", "))
+			}
+			return writer.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&meshName, "mesh", "", "Mesh name")
+	_ = cmd.RegisterFlagCompletionFunc("mesh", completeMeshFlag)
+	return cmd
+}
+
+// exportNodeCommand writes the wg-quick config for one mesh node.
+func exportNodeCommand() *cobra.Command {
+	var meshName string
+	var nodeName string
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "export-node",
+		Short: "Export the WireGuard config of a mesh node",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if meshName == "" || nodeName == "" || outputPath == "" {
+				return fmt.Errorf("--mesh, --node, and --out are required")
+			}
+			mesh, err := core.LoadMeshProfile(meshName)
+			if err != nil {
+				return err
+			}
+			config, err := core.BuildMeshNodeConfig(mesh, nodeName)
+			if err != nil {
+				return err
+			}
+
+			resolvedPath, err := utils.ExpandPath(outputPath)
+			if err != nil {
+				return err
+			}
+			if info, err := os.Stat(resolvedPath); err == nil && info.IsDir() {
+				// wg-quick names the interface after the file, so use the mesh name.
+				resolvedPath = filepath.Join(resolvedPath, mesh.Name+".conf")
+			}
+			if err := utils.WriteFile(resolvedPath, []byte(config), 0o600); err != nil {
+				return err
+			}
+			fmt.Printf("Node configuration written to %s\n", resolvedPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&meshName, "mesh", "", "Mesh name")
+	cmd.Flags().StringVar(&nodeName, "node", "", "Node name")
+	cmd.Flags().StringVar(&outputPath, "out", "", "Path (or directory) to write the node configuration")
+	_ = cmd.RegisterFlagCompletionFunc("mesh", completeMeshFlag)
+	return cmd
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// migrateOpenVPNCommand recreates an OpenVPN client roster as WireStack clients.
+func migrateOpenVPNCommand() *cobra.Command {
+	var serverName string
+	var ccdDir string
+	var statusFile string
+	var reportPath string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate-openvpn",
+		Short: "Create WireStack clients from an OpenVPN ccd directory or status file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			if ccdDir == "" && statusFile == "" {
+				return fmt.Errorf("at least one of --ccd-dir or --status-file is required")
+			}
+
+			var rosters [][]core.OpenVPNClient
+			if ccdDir != "" {
+				clients, err := core.ReadOpenVPNCCD(ccdDir)
+				if err != nil {
+					return err
+				}
+				rosters = append(rosters, clients)
+			}
+			if statusFile != "" {
+				clients, err := core.ReadOpenVPNStatus(statusFile)
+				if err != nil {
+					return err
+				}
+				rosters = append(rosters, clients)
+			}
+			clients := core.MergeOpenVPNClients(rosters...)
+			if len(clients) == 0 {
+				return fmt.Errorf("no OpenVPN clients found")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			before := len(profile.Clients)
+			report, err := core.MigrateOpenVPNClients(profile, clients)
+			if err != nil {
+				return err
+			}
+
+			if !dryRun {
+				if err := core.SaveServerProfile(profile); err != nil {
+					return err
+				}
+				if profile.ExternalInterface != "" && core.InterfaceIsUp(profile.ExternalInterface) {
+					for _, client := range profile.Clients[before:] {
+						if err := core.ApplyLivePeer(profile.ExternalInterface, client); err != nil {
+							return err
+						}
+					}
+				}
+			}
+
+			if reportPath != "" {
+				if err := writeMigrationCSV(reportPath, report); err != nil {
+					return err
+				}
+			}
+			if structuredOutput() {
+				return printStructured(report)
+			}
+			if err := printMigrationReport(report); err != nil {
+				return err
+			}
+			if dryRun {
+				fmt.Printf("Dry run: %d clients would be created on server %s\n", len(profile.Clients)-before, serverName)
+				return nil
+			}
+			fmt.Printf("Created %d clients on server %s\n", len(profile.Clients)-before, serverName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "WireStack server that receives the clients")
+	cmd.Flags().StringVar(&ccdDir, "ccd-dir", "", "OpenVPN client-config-dir with ifconfig-push addresses")
+	cmd.Flags().StringVar(&statusFile, "status-file", "", "OpenVPN status file listing connected clients")
+	cmd.Flags().StringVar(&reportPath, "report", "", "Also write the old-to-new address mapping as CSV to this path")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the migration report without saving any clients")
+	return cmd
+}
+
+// printMigrationReport renders the migration mapping as a table.
+func printMigrationReport(report []core.MigrationEntry) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CLIENT\tOPENVPN ADDRESS\tWIRESTACK ADDRESS\tSTATUS")
+	for _, entry := range report {
+		old := entry.OldAddress
+		if old == "" {
+			old = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", entry.Client, old, entry.NewAddress, entry.Status)
+	}
+	return writer.Flush()
+}
+
+// writeMigrationCSV saves the migration mapping for firewall and DNS updates.
+func writeMigrationCSV(path string, report []core.MigrationEntry) error {
+	resolved, err := utils.ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(resolved, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	rows := [][]string{{"client", "openvpn_address", "wirestack_address", "status"}}
+	for _, entry := range report {
+		rows = append(rows, []string{entry.Client, entry.OldAddress, entry.NewAddress, entry.Status})
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return file.Close()
+}
+
+// migrationBundleCommand writes hybrid bundles pairing each migrated client's
+// OpenVPN profile with its new WireGuard config and a cutover checklist.
+func migrationBundleCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var outputDir string
+	var openvpnDir string
+	var target string
+
+	cmd := &cobra.Command{
+		Use:   "migration-bundle",
+		Short: "Export OpenVPN-to-WireGuard bundles for migrated clients",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || outputDir == "" {
+				return fmt.Errorf("both --server and --out are required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			root, err := utils.ExpandPath(outputDir)
+			if err != nil {
+				return err
+			}
+
+			written := 0
+			for idx := range profile.Clients {
+				client := &profile.Clients[idx]
+				if clientName != "" && client.Name != clientName {
+					continue
+				}
+				if client.Migration == nil {
+					if clientName != "" {
+						return fmt.Errorf("client %s was not migrated from another VPN", clientName)
+					}
+					continue
+				}
+
+				bundleDir := filepath.Join(root, client.Name)
+				config, err := core.BuildClientConfigFor(profile, *client, core.ClientRenderOptions{Target: target})
+				if err != nil {
+					return err
+				}
+				configName := core.ClientConfigFileName(serverName, client.Name, target)
+				if err := utils.WriteFile(filepath.Join(bundleDir, configName), []byte(config), 0o600); err != nil {
+					return err
+				}
+
+				openvpnProfile := ""
+				if openvpnDir != "" {
+					source := filepath.Join(openvpnDir, client.Name+".ovpn")
+					if data, err := os.ReadFile(source); err == nil {
+						openvpnProfile = client.Name + ".ovpn"
+						if err := utils.WriteFile(filepath.Join(bundleDir, openvpnProfile), data, 0o600); err != nil {
+							return err
+						}
+					} else if !errors.Is(err, fs.ErrNotExist) {
+						return err
+					}
+				}
+
+				checklist := core.MigrationChecklist(profile, *client, configName, openvpnProfile)
+				if err := utils.WriteFile(filepath.Join(bundleDir, "CUTOVER.txt"), []byte(checklist), 0o644); err != nil {
+					return err
+				}
+				if client.Migration.Status == core.MigrationPending {
+					if err := core.SetMigrationStatus(profile, client.Name, core.MigrationBundled); err != nil {
+						return err
+					}
+				}
+				written++
+				fmt.Printf("Bundle for %s written to %s\n", client.Name, bundleDir)
+			}
+			if clientName != "" && written == 0 {
+				return fmt.Errorf("client %s: %w", clientName, core.ErrClientNotFound)
+			}
+			if written == 0 {
+				fmt.Println("no migrated clients found")
+				return nil
+			}
+			return core.SaveServerProfile(profile)
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Only export this client (default: every migrated client)")
+	cmd.Flags().StringVar(&outputDir, "out", "", "Directory that receives one bundle directory per client")
+	cmd.Flags().StringVar(&openvpnDir, "openvpn-profile-dir", "", "Directory of existing <client>.ovpn profiles to include for rollback")
+	cmd.Flags().StringVar(&target, "target", core.TargetLinux, "Client platform: "+strings.Join(core.ClientTargets, ", "))
+	return cmd
+}
+
+// migrationStatusCommand shows or updates per-client migration progress.
+func migrationStatusCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var status string
+
+	cmd := &cobra.Command{
+		Use:   "migration-status",
+		Short: "Show or set the migration status of migrated clients",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+
+			if status != "" {
+				if clientName == "" {
+					return fmt.Errorf("--client is required with --set")
+				}
+				if err := core.SetMigrationStatus(profile, clientName, status); err != nil {
+					return err
+				}
+				if err := core.SaveServerProfile(profile); err != nil {
+					return err
+				}
+				fmt.Printf("Client %s migration status set to %s\n", clientName, status)
+				return nil
+			}
+
+			views := []migrationView{}
+			for _, client := range profile.Clients {
+				if client.Migration == nil || (clientName != "" && client.Name != clientName) {
+					continue
+				}
+				views = append(views, migrationView{
+					Client:     client.Name,
+					Source:     client.Migration.Source,
+					OldAddress: client.Migration.OldAddress,
+					NewAddress: client.Address,
+					Status:     client.Migration.Status,
+					UpdatedAt:  client.Migration.UpdatedAt,
+				})
+			}
+			if structuredOutput() {
+				return printStructured(views)
+			}
+			if len(views) == 0 {
+				fmt.Println("no migrated clients found")
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "CLIENT\tSOURCE\tOLD ADDRESS\tNEW ADDRESS\tSTATUS\tUPDATED")
+			for _, view := range views {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", view.Client, view.Source, view.OldAddress, view.NewAddress, view.Status, view.UpdatedAt.Local().Format("2006-01-02 15:04"))
+			}
+			return writer.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&clientName, "client", "", "Client name")
+	cmd.Flags().StringVar(&status, "set", "", "New status: "+strings.Join(core.MigrationStatuses, ", "))
+	return cmd
+}
+
+// migrationView is the machine-readable form of a client's migration progress.
+type migrationView struct {
+	Client     string    `json:"client" yaml:"client"`
+	Source     string    `json:"source" yaml:"source"`
+	OldAddress string    `json:"old_address,omitempty" yaml:"old_address,omitempty"`
+	NewAddress string    `json:"new_address" yaml:"new_address"`
+	Status     string    `json:"status" yaml:"status"`
+	UpdatedAt  time.Time `json:"updated_at" yaml:"updated_at"`
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// mtuProbeCommand discovers the path MTU toward a server endpoint and the tunnel MTU that fits it.
+func mtuProbeCommand() *cobra.Command {
+	var serverName string
+	var clientName string
+	var host string
+	var maxPath int
+	var save bool
+
+	cmd := &cobra.Command{
+		Use:   "mtu-probe",
+		Short: "Discover the best tunnel MTU by path MTU testing toward the endpoint",
+		Long: `Discover the path MTU toward a server's endpoint (or --host) with
+unfragmentable pings, and print the largest tunnel MTU that fits after
+WireGuard's encapsulation overhead.
+
+Run it on the client machine, toward the server. With --save, the result is
+stored on the client given by --client, or on the server otherwise.
+Requires Linux ping(8); hosts that drop ICMP cannot be probed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" && host == "" {
+				return fmt.Errorf("--server or --host is required")
+			}
+			if save && serverName == "" {
+				return fmt.Errorf("--save requires --server")
+			}
+			if clientName != "" && !save {
+				return fmt.Errorf("--client only applies with --save")
+			}
+
+			var profile *core.ServerProfile
+			if serverName != "" {
+				var err error
+				if save {
+					unlock, err := core.LockServerProfile(serverName)
+					if err != nil {
+						return err
+					}
+					defer unlock()
+				}
+				if profile, err = core.LoadServerProfile(serverName); err != nil {
+					return err
+				}
+				if host == "" {
+					if host, _, err = net.SplitHostPort(profile.Endpoint); err != nil {
+						return fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
+					}
+				}
+			}
+
+			result, err := core.ProbeMTU(host, maxPath)
+			if err != nil {
+				return err
+			}
+			family := "IPv4"
+			if result.IPv6 {
+				family = "IPv6"
+			}
+			fmt.Printf("Path MTU to %s (%s): %d\n", result.Host, family, result.PathMTU)
+			fmt.Printf("Recommended tunnel MTU: %d\n", result.TunnelMTU)
+			if !save {
+				return nil
+			}
+
+			if clientName != "" {
+				client, err := core.FindClient(profile, clientName)
+				if err != nil {
+					return err
+				}
+				client.MTU = result.TunnelMTU
+			} else {
+				profile.MTU = result.TunnelMTU
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+			if err := rerenderRuntimeConfigs(profile, clientName); err != nil {
+				return err
+			}
+			if clientName != "" {
+				fmt.Printf("Saved MTU %d on client %s; re-export its config\n", result.TunnelMTU, clientName)
+			} else {
+				fmt.Printf("Saved MTU %d on server %s; it applies the next time the interface comes up\n", result.TunnelMTU, serverName)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server whose endpoint is probed")
+	cmd.Flags().StringVar(&clientName, "client", "", "Client the result is saved on (with --save)")
+	cmd.Flags().StringVar(&host, "host", "", "Probe this host instead of the server endpoint")
+	cmd.Flags().IntVar(&maxPath, "max", 1500, "Largest path MTU to try (e.g. 9000 on jumbo-frame networks)")
+	cmd.Flags().BoolVar(&save, "save", false, "Store the recommended MTU in the profile")
+	return cmd
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"wirestack/internal/core"
+)
+
+// notifier posts broker events to the configured webhook: critical events
+// right away and, in digest mode, everything else as one summary per interval.
+type notifier struct {
+	client      *http.Client
+	events      <-chan core.Event
+	unsubscribe func()
+	configure   chan *core.NotifySettings
+	quit        chan struct{}
+	done        chan struct{}
+}
+
+// startNotifier subscribes to broker and starts delivering with settings,
+// which may be nil to stay idle until a reload configures a webhook.
+func startNotifier(broker *eventBroker, settings *core.NotifySettings) *notifier {
+	events, unsubscribe := broker.subscribe()
+	n := &notifier{
+		client:      &http.Client{},
+		events:      events,
+		unsubscribe: unsubscribe,
+		configure:   make(chan *core.NotifySettings),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go n.run(settings)
+	return n
+}
+
+// run delivers events until stop is called. Delivery errors are logged and
+// the notification is dropped.
+func (n *notifier) run(settings *core.NotifySettings) {
+	defer close(n.done)
+	var pending []core.Event
+	since := time.Now().UTC()
+	var interval time.Duration
+	handle := func(event core.Event) {
+		switch {
+		case settings == nil:
+		case interval == 0 || settings.IsCritical(event.Type):
+			n.send(settings, core.AlertNotification(event))
+		default:
+			pending = append(pending, event)
+		}
+	}
+	// drain handles events already queued, so a reload or stop does not
+	// leave them for the next settings or drop them.
+	drain := func() {
+		for {
+			select {
+			case event := <-n.events:
+				handle(event)
+			default:
+				return
+			}
+		}
+	}
+	flush := func() {
+		if len(pending) > 0 && settings != nil {
+			n.send(settings, core.DigestNotification(pending, since, time.Now().UTC()))
+		}
+		pending = nil
+		since = time.Now().UTC()
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	interval = n.retime(ticker, settings)
+	for {
+		select {
+		case <-n.quit:
+			drain()
+			flush()
+			return
+		case next := <-n.configure:
+			// Events collected so far go out under the settings they were
+			// collected with.
+			drain()
+			flush()
+			settings = next
+			interval = n.retime(ticker, settings)
+		case <-ticker.C:
+			flush()
+		case event := <-n.events:
+			handle(event)
+		}
+	}
+}
+
+// retime resets ticker to the digest interval of settings and returns it;
+// zero means digests are off and the ticker is left idling.
+func (n *notifier) retime(ticker *time.Ticker, settings *core.NotifySettings) time.Duration {
+	interval, _ := settings.DigestInterval()
+	if interval > 0 {
+		ticker.Reset(interval)
+	}
+	return interval
+}
+
+// send posts one notification, logging failures.
+func (n *notifier) send(settings *core.NotifySettings, notification core.Notification) {
+	if err := core.SendNotification(context.Background(), n.client, settings.Webhook, notification); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// setSettings switches to new settings, first sending any pending digest.
+func (n *notifier) setSettings(settings *core.NotifySettings) {
+	select {
+	case n.configure <- settings:
+	case <-n.done:
+	}
+}
+
+// stop sends any pending digest and ends delivery.
+func (n *notifier) stop() {
+	close(n.quit)
+	<-n.done
+	n.unsubscribe()
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"wirestack/internal/core"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	// outputSARIF is only accepted by validate, for CI annotations.
+	outputSARIF = "sarif"
+)
+
+// outputFormat selects how read commands render their results.
+var outputFormat string
+
+// validateOutputFormat rejects unknown --output values before cmd runs.
+// Commands that write a file used to take its path with --output; on those,
+// a value that is not a format is still accepted as the --out path.
+func validateOutputFormat(cmd *cobra.Command) error {
+	if out := cmd.Flags().Lookup("out"); out != nil && cmd.Flags().Changed("output") && !knownOutputFormat(outputFormat) {
+		if out.Changed {
+			return fmt.Errorf("--output takes a format (table, json, or yaml); give the file path with --out")
+		}
+		fmt.Fprintf(os.Stderr, "warning: --output %s: file paths are now given with --out; --output selects the format\n", outputFormat)
+		if err := out.Value.Set(outputFormat); err != nil {
+			return err
+		}
+		outputFormat = outputTable
+		return nil
+	}
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	case outputSARIF:
+		if cmd.Name() == "validate" {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q (want table, json, or yaml)", outputFormat)
+}
+
+// knownOutputFormat reports whether format is one --output accepts on any command.
+func knownOutputFormat(format string) bool {
+	switch format {
+	case outputTable, outputJSON, outputYAML, outputSARIF:
+		return true
+	}
+	return false
+}
+
+// structuredOutput reports whether the user asked for machine-readable output.
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// printStructured writes v to stdout as JSON or YAML according to --output.
+func printStructured(v any) error {
+	switch outputFormat {
+	case outputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("output format %s is not structured", outputFormat)
+	}
+}
+
+// serverView is the machine-readable form of a server profile. Private keys are
+// deliberately left out.
+type serverView struct {
+	Name               string              `json:"name" yaml:"name"`
+	Endpoint           string              `json:"endpoint" yaml:"endpoint"`
+	AlternateEndpoints []string            `json:"alternate_endpoints,omitempty" yaml:"alternate_endpoints,omitempty"`
+	Description        string              `json:"description,omitempty" yaml:"description,omitempty"`
+	Alias              string              `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Annotations        map[string]string   `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Addresses          []string            `json:"addresses" yaml:"addresses"`
+	Subnet             string              `json:"subnet,omitempty" yaml:"subnet,omitempty"`
+	Subnet6            string              `json:"subnet6,omitempty" yaml:"subnet6,omitempty"`
+	PublicKey          string              `json:"public_key" yaml:"public_key"`
+	ExternalInterface  string              `json:"external_interface,omitempty" yaml:"external_interface,omitempty"`
+	MTU                int                 `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	NATInterface       string              `json:"nat_interface,omitempty" yaml:"nat_interface,omitempty"`
+	NATBackend         string              `json:"nat_backend,omitempty" yaml:"nat_backend,omitempty"`
+	PortMapping        string              `json:"port_mapping,omitempty" yaml:"port_mapping,omitempty"`
+	PostUp             []string            `json:"post_up,omitempty" yaml:"post_up,omitempty"`
+	PostDown           []string            `json:"post_down,omitempty" yaml:"post_down,omitempty"`
+	DNS                []string            `json:"dns,omitempty" yaml:"dns,omitempty"`
+	SearchDomains      []string            `json:"search_domains,omitempty" yaml:"search_domains,omitempty"`
+	Amnezia            *core.AmneziaParams `json:"amnezia,omitempty" yaml:"amnezia,omitempty"`
+	Policies           []core.AccessPolicy `json:"policies,omitempty" yaml:"policies,omitempty"`
+	Groups             []core.ClientGroup  `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Networks           []string            `json:"networks,omitempty" yaml:"networks,omitempty"`
+	Clients            []clientView        `json:"clients" yaml:"clients"`
+}
+
+// clientView is the machine-readable form of a client profile.
+type clientView struct {
+	Server      string            `json:"server" yaml:"server"`
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Addresses   []string          `json:"addresses" yaml:"addresses"`
+	PublicKey   string            `json:"public_key" yaml:"public_key"`
+	AllowedIPs  []string          `json:"allowed_ips" yaml:"allowed_ips"`
+	Mode        string            `json:"mode" yaml:"mode"`
+	DNS         []string          `json:"dns,omitempty" yaml:"dns,omitempty"`
+	MTU         int               `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Group       string            `json:"group,omitempty" yaml:"group,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Disabled    bool              `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// PortForwards are written as protocol:public:client, e.g. tcp:8080:80.
+	PortForwards []string `json:"port_forwards,omitempty" yaml:"port_forwards,omitempty"`
+	// Platform is set once the client's agent has reported its device.
+	Platform *platformView `json:"platform,omitempty" yaml:"platform,omitempty"`
+	// VersionWarning is set when the platform is below the server's version policy.
+	VersionWarning string `json:"version_warning,omitempty" yaml:"version_warning,omitempty"`
+}
+
+// platformView is the device a client last reported.
+type platformView struct {
+	OS             string    `json:"os,omitempty" yaml:"os,omitempty"`
+	Kernel         string    `json:"kernel,omitempty" yaml:"kernel,omitempty"`
+	Implementation string    `json:"implementation,omitempty" yaml:"implementation,omitempty"`
+	AppVersion     string    `json:"app_version,omitempty" yaml:"app_version,omitempty"`
+	ReportedAt     time.Time `json:"reported_at" yaml:"reported_at"`
+}
+
+// statusView is the machine-readable form of a server's runtime state.
+type statusView struct {
+	Server     string     `json:"server" yaml:"server"`
+	Interface  string     `json:"interface" yaml:"interface"`
+	Up         bool       `json:"up" yaml:"up"`
+	ListenPort int        `json:"listen_port,omitempty" yaml:"listen_port,omitempty"`
+	Peers      []peerView `json:"peers" yaml:"peers"`
+	// Warnings lists clients below the server's minimum app version.
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// peerView is the machine-readable form of a running peer.
+type peerView struct {
+	Client          string     `json:"client,omitempty" yaml:"client,omitempty"`
+	PublicKey       string     `json:"public_key" yaml:"public_key"`
+	Endpoint        string     `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	LatestHandshake *time.Time `json:"latest_handshake,omitempty" yaml:"latest_handshake,omitempty"`
+	RxBytes         int64      `json:"rx_bytes" yaml:"rx_bytes"`
+	TxBytes         int64      `json:"tx_bytes" yaml:"tx_bytes"`
+	// Quality is the connection quality level; QualityScore is omitted until it is known.
+	Quality      string `json:"quality,omitempty" yaml:"quality,omitempty"`
+	QualityScore *int   `json:"quality_score,omitempty" yaml:"quality_score,omitempty"`
+}
+
+// newServerView converts a profile into its public view.
+func newServerView(profile *core.ServerProfile) serverView {
+	view := serverView{
+		Name:               profile.Name,
+		Endpoint:           profile.Endpoint,
+		Description:        profile.Description,
+		Alias:              profile.Alias,
+		Annotations:        profile.Annotations,
+		AlternateEndpoints: profile.AlternateEndpoints,
+		Addresses:          core.ServerAddresses(profile),
+		Subnet:             profile.Subnet,
+		Subnet6:            profile.Subnet6,
+		PublicKey:          profile.ServerPublicKey,
+		ExternalInterface:  profile.ExternalInterface,
+		MTU:                profile.MTU,
+		NATInterface:       profile.NATInterface,
+		NATBackend:         profile.NATBackend,
+		PortMapping:        profile.PortMapping,
+		PostUp:             profile.PostUp,
+		PostDown:           profile.PostDown,
+		DNS:                profile.DNS,
+		SearchDomains:      profile.SearchDomains,
+		Amnezia:            profile.Amnezia,
+		Policies:           profile.Policies,
+		Groups:             profile.Groups,
+		Networks:           profile.Networks,
+		Clients:            make([]clientView, 0, len(profile.Clients)),
+	}
+	for _, client := range profile.Clients {
+		view.Clients = append(view.Clients, newClientView(profile, client))
+	}
+	return view
+}
+
+// newClientView converts a client into its public view.
+func newClientView(profile *core.ServerProfile, client core.ClientProfile) clientView {
+	return clientView{
+		Server:       profile.Name,
+		Name:         client.Name,
+		Description:  client.Description,
+		Addresses:    core.ClientAddresses(client),
+		PublicKey:    client.PublicKey,
+		AllowedIPs:   core.EffectiveAllowedIPs(profile, client),
+		Mode:         core.ClientMode(client),
+		DNS:          core.ClientDNS(profile, client),
+		MTU:          client.MTU,
+		Tags:         client.Tags,
+		Group:        client.Group,
+		Annotations:  client.Annotations,
+		ExpiresAt:    client.ExpiresAt,
+		Disabled:     client.Disabled,
+		Platform:     newPlatformView(client.Platform),
+		PortForwards: portForwardSpecs(client.PortForwards),
+		// Only set while the client is below the server's version policy.
+		VersionWarning: core.OutdatedReason(profile, client),
+	}
+}
+
+// portForwardSpecs formats port forwards as edit-client --forward takes them.
+func portForwardSpecs(forwards []core.PortForward) []string {
+	var specs []string
+	for _, forward := range forwards {
+		specs = append(specs, forward.String())
+	}
+	return specs
+}
+
+// newPlatformView converts a platform report, returning nil when there is none.
+func newPlatformView(platform *core.ClientPlatform) *platformView {
+	if platform == nil {
+		return nil
+	}
+	return &platformView{
+		OS:             platform.OS,
+		Kernel:         platform.Kernel,
+		Implementation: platform.Implementation,
+		AppVersion:     platform.AppVersion,
+		ReportedAt:     platform.ReportedAt,
+	}
+}
+
+// meshView is the structured representation of a mesh. Private keys are omitted.
+type meshView struct {
+	Name       string         `json:"name" yaml:"name"`
+	Subnet     string         `json:"subnet" yaml:"subnet"`
+	ListenPort int            `json:"listen_port" yaml:"listen_port"`
+	Nodes      []meshNodeView `json:"nodes" yaml:"nodes"`
+}
+
+// meshNodeView is the structured representation of a mesh node.
+type meshNodeView struct {
+	Name      string   `json:"name" yaml:"name"`
+	Address   string   `json:"address" yaml:"address"`
+	Endpoint  string   `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	PublicKey string   `json:"public_key" yaml:"public_key"`
+	Routes    []string `json:"routes,omitempty" yaml:"routes,omitempty"`
+}
+
+// newMeshView converts a mesh profile into its structured view.
+func newMeshView(mesh *core.MeshProfile) meshView {
+	view := meshView{Name: mesh.Name, Subnet: mesh.Subnet, ListenPort: mesh.ListenPort, Nodes: []meshNodeView{}}
+	for _, node := range mesh.Nodes {
+		view.Nodes = append(view.Nodes, meshNodeView{
+			Name:      node.Name,
+			Address:   node.Address,
+			Endpoint:  node.Endpoint,
+			PublicKey: node.PublicKey,
+			Routes:    node.Routes,
+		})
+	}
+	return view
+}
+
+// featureView is the structured representation of a feature flag.
+type featureView struct {
+	Name        string `json:"name" yaml:"name"`
+	Enabled     bool   `json:"enabled" yaml:"enabled"`
+	Source      string `json:"source" yaml:"source"`
+	Default     bool   `json:"default" yaml:"default"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// permissionsView is the structured form of the permissions policy.
+type permissionsView struct {
+	// Group is empty when everything is owner-only.
+	Group  string   `json:"group" yaml:"group"`
+	Shared []string `json:"shared" yaml:"shared"`
+}
+
+// benchView is the structured result of a bench run. Latencies are in
+// milliseconds; skipped tests are zero.
+type benchView struct {
+	URL                   string  `json:"url" yaml:"url"`
+	LatencyMinMs          float64 `json:"latency_min_ms" yaml:"latency_min_ms"`
+	LatencyAvgMs          float64 `json:"latency_avg_ms" yaml:"latency_avg_ms"`
+	LatencyMaxMs          float64 `json:"latency_max_ms" yaml:"latency_max_ms"`
+	DownloadBytes         int64   `json:"download_bytes" yaml:"download_bytes"`
+	DownloadBitsPerSecond float64 `json:"download_bits_per_second" yaml:"download_bits_per_second"`
+	UploadBytes           int64   `json:"upload_bytes" yaml:"upload_bytes"`
+	UploadBitsPerSecond   float64 `json:"upload_bits_per_second" yaml:"upload_bits_per_second"`
+	// Baseline is the run given with --baseline.
+	Baseline *benchView `json:"baseline,omitempty" yaml:"baseline,omitempty"`
+}
+
+// newBenchView converts a bench result into its structured view.
+func newBenchView(url string, result *core.BenchResult) benchView {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return benchView{
+		URL:                   url,
+		LatencyMinMs:          ms(result.LatencyMin),
+		LatencyAvgMs:          ms(result.LatencyAvg),
+		LatencyMaxMs:          ms(result.LatencyMax),
+		DownloadBytes:         result.DownloadBytes,
+		DownloadBitsPerSecond: result.DownloadBitsPerSecond(),
+		UploadBytes:           result.UploadBytes,
+		UploadBitsPerSecond:   result.UploadBitsPerSecond(),
+	}
+}
+
+// qualityView is the structured form of a client's connection quality score.
+type qualityView struct {
+	Server  string   `json:"server" yaml:"server"`
+	Client  string   `json:"client" yaml:"client"`
+	Quality string   `json:"quality" yaml:"quality"`
+	Score   *int     `json:"score,omitempty" yaml:"score,omitempty"`
+	Samples int      `json:"samples" yaml:"samples"`
+	Reasons []string `json:"reasons,omitempty" yaml:"reasons,omitempty"`
+}
+
+// newQualityView converts a quality score into its structured view.
+func newQualityView(server, client string, score core.QualityScore) qualityView {
+	view := qualityView{Server: server, Client: client, Quality: score.Level, Samples: score.Samples, Reasons: score.Reasons}
+	if score.Level != core.QualityUnknown {
+		value := score.Score
+		view.Score = &value
+	}
+	return view
+}
+
+// systemdView is the structured form of a server's installed systemd unit.
+type systemdView struct {
+	Server  string `json:"server" yaml:"server"`
+	Unit    string `json:"unit" yaml:"unit"`
+	Mode    string `json:"mode" yaml:"mode"`
+	Path    string `json:"path" yaml:"path"`
+	Enabled string `json:"enabled" yaml:"enabled"`
+	Active  string `json:"active" yaml:"active"`
+}
+
+// healthView is the structured form of a server's peer health check.
+type healthView struct {
+	Server    string           `json:"server" yaml:"server"`
+	Interface string           `json:"interface" yaml:"interface"`
+	Up        bool             `json:"up" yaml:"up"`
+	Threshold string           `json:"threshold" yaml:"threshold"`
+	Healthy   bool             `json:"healthy" yaml:"healthy"`
+	Peers     []peerHealthView `json:"peers" yaml:"peers"`
+}
+
+// peerHealthView is one peer in a health check.
+type peerHealthView struct {
+	Client          string     `json:"client,omitempty" yaml:"client,omitempty"`
+	PublicKey       string     `json:"public_key" yaml:"public_key"`
+	Endpoint        string     `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	LatestHandshake *time.Time `json:"latest_handshake,omitempty" yaml:"latest_handshake,omitempty"`
+	State           string     `json:"state" yaml:"state"`
+	Healthy         bool       `json:"healthy" yaml:"healthy"`
+}
+
+// caStatusView is the structured form of the certificate authority's state.
+type caStatusView struct {
+	Name     string    `json:"name" yaml:"name"`
+	Serial   string    `json:"serial" yaml:"serial"`
+	NotAfter time.Time `json:"not_after" yaml:"not_after"`
+	// PreviousNotAfter is set while a rotation is in progress.
+	PreviousNotAfter *time.Time   `json:"previous_not_after,omitempty" yaml:"previous_not_after,omitempty"`
+	Certificates     []caCertView `json:"certificates" yaml:"certificates"`
+}
+
+// caCertView is one issued agent certificate.
+type caCertView struct {
+	Name     string    `json:"name" yaml:"name"`
+	Serial   string    `json:"serial" yaml:"serial"`
+	IssuedAt time.Time `json:"issued_at" yaml:"issued_at"`
+	NotAfter time.Time `json:"not_after" yaml:"not_after"`
+	State    string    `json:"state" yaml:"state"`
+}
+
+// statsView is the structured form of a server's per-client transfer rates.
+type statsView struct {
+	Server    string          `json:"server" yaml:"server"`
+	Interface string          `json:"interface" yaml:"interface"`
+	Up        bool            `json:"up" yaml:"up"`
+	Interval  string          `json:"interval" yaml:"interval"`
+	Peers     []peerStatsView `json:"peers" yaml:"peers"`
+}
+
+// peerStatsView is one peer's transfer totals and rates in bytes per second.
+type peerStatsView struct {
+	Client    string  `json:"client,omitempty" yaml:"client,omitempty"`
+	PublicKey string  `json:"public_key" yaml:"public_key"`
+	RxBytes   int64   `json:"rx_bytes" yaml:"rx_bytes"`
+	TxBytes   int64   `json:"tx_bytes" yaml:"tx_bytes"`
+	RxRate    float64 `json:"rx_bytes_per_second" yaml:"rx_bytes_per_second"`
+	TxRate    float64 `json:"tx_bytes_per_second" yaml:"tx_bytes_per_second"`
+}
+
+// configDiffView is the machine-readable form of a config diff. "from" is the
+// profile's rendered config and "to" the compared file.
+type configDiffView struct {
+	Equal        bool                `json:"equal" yaml:"equal"`
+	Interface    []settingChangeView `json:"interface" yaml:"interface"`
+	AddedPeers   []peerDiffView      `json:"added_peers" yaml:"added_peers"`
+	RemovedPeers []peerDiffView      `json:"removed_peers" yaml:"removed_peers"`
+	ChangedPeers []peerDiffView      `json:"changed_peers" yaml:"changed_peers"`
+}
+
+// settingChangeView is one differing key; an empty side means it is missing there.
+type settingChangeView struct {
+	Key  string `json:"key" yaml:"key"`
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+	To   string `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// peerDiffView is a peer only on one side, or with differing settings.
+type peerDiffView struct {
+	PublicKey string              `json:"public_key" yaml:"public_key"`
+	Client    string              `json:"client,omitempty" yaml:"client,omitempty"`
+	Changes   []settingChangeView `json:"changes" yaml:"changes"`
+}
+
+// newConfigDiffView converts a config diff for structured output.
+func newConfigDiffView(diff *core.ConfigDiff) configDiffView {
+	return configDiffView{
+		Equal:        diff.Empty(),
+		Interface:    newSettingChangeViews(diff.Interface),
+		AddedPeers:   newPeerDiffViews(diff.AddedPeers),
+		RemovedPeers: newPeerDiffViews(diff.RemovedPeers),
+		ChangedPeers: newPeerDiffViews(diff.ChangedPeers),
+	}
+}
+
+// newSettingChangeViews converts setting changes, never returning nil.
+func newSettingChangeViews(changes []core.SettingChange) []settingChangeView {
+	views := make([]settingChangeView, 0, len(changes))
+	for _, change := range changes {
+		views = append(views, settingChangeView{Key: change.Key, From: change.From, To: change.To})
+	}
+	return views
+}
+
+// newPeerDiffViews converts peer diffs, never returning nil.
+func newPeerDiffViews(peers []core.PeerDiff) []peerDiffView {
+	views := make([]peerDiffView, 0, len(peers))
+	for _, peer := range peers {
+		views = append(views, peerDiffView{PublicKey: peer.PublicKey, Client: peer.Name, Changes: newSettingChangeViews(peer.Changes)})
+	}
+	return views
+}
+
+// tuneView is the machine-readable form of tune's findings.
+type tuneView struct {
+	Interface string            `json:"interface,omitempty" yaml:"interface,omitempty"`
+	Sysctls   map[string]string `json:"sysctls" yaml:"sysctls"`
+	Offloads  map[string]string `json:"offloads" yaml:"offloads"`
+	Qdisc     string            `json:"qdisc,omitempty" yaml:"qdisc,omitempty"`
+	Notes     []string          `json:"notes,omitempty" yaml:"notes,omitempty"`
+	// Queues is set with --multiqueue.
+	Queues          *queueView           `json:"queues,omitempty" yaml:"queues,omitempty"`
+	Recommendations []tuneRecommendation `json:"recommendations" yaml:"recommendations"`
+	// HooksAdded is how many PostUp hooks --apply added.
+	HooksAdded int `json:"hooks_added" yaml:"hooks_added"`
+}
+
+// tuneRecommendation is one suggested change and the command that makes it.
+type tuneRecommendation struct {
+	Setting     string `json:"setting" yaml:"setting"`
+	Current     string `json:"current" yaml:"current"`
+	Recommended string `json:"recommended" yaml:"recommended"`
+	Reason      string `json:"reason" yaml:"reason"`
+	Command     string `json:"command" yaml:"command"`
+}
+
+// queueView is how a NIC's queues and interrupts are spread over CPUs.
+type queueView struct {
+	CPUs     int               `json:"cpus" yaml:"cpus"`
+	RxQueues map[string]string `json:"rps_cpus" yaml:"rps_cpus"`
+	TxQueues map[string]string `json:"xps_cpus" yaml:"xps_cpus"`
+	IRQs     []irqView         `json:"irqs,omitempty" yaml:"irqs,omitempty"`
+}
+
+// irqView is one of the NIC's interrupts and its CPU affinity list.
+type irqView struct {
+	IRQ  int    `json:"irq" yaml:"irq"`
+	Name string `json:"name" yaml:"name"`
+	CPUs string `json:"cpus" yaml:"cpus"`
+}
+
+// newTuneView converts an inspection and its recommendations for structured
+// output; queues is nil without --multiqueue.
+func newTuneView(inspection *core.TuneInspection, queues *core.QueueInspection, recs []core.TuneRecommendation, added int) tuneView {
+	view := tuneView{
+		Interface:       inspection.Interface,
+		Sysctls:         inspection.Sysctls,
+		Offloads:        inspection.Offloads,
+		Qdisc:           inspection.Qdisc,
+		Notes:           inspection.Notes,
+		Recommendations: make([]tuneRecommendation, 0, len(recs)),
+		HooksAdded:      added,
+	}
+	if queues != nil {
+		view.Queues = &queueView{CPUs: queues.CPUs, RxQueues: queues.RxQueues, TxQueues: queues.TxQueues}
+		for _, irq := range queues.IRQs {
+			view.Queues.IRQs = append(view.Queues.IRQs, irqView{IRQ: irq.IRQ, Name: irq.Name, CPUs: irq.CPUs})
+		}
+	}
+	for _, rec := range recs {
+		view.Recommendations = append(view.Recommendations, tuneRecommendation{
+			Setting:     rec.Setting,
+			Current:     rec.Current,
+			Recommended: rec.Recommended,
+			Reason:      rec.Reason,
+			Command:     rec.Command,
+		})
+	}
+	return view
+}
+
+// fsckView is the machine-readable result of fsck.
+type fsckView struct {
+	Issues []core.FsckIssue `json:"issues" yaml:"issues"`
+	// Remaining counts the issues not repaired.
+	Remaining int `json:"remaining" yaml:"remaining"`
+}
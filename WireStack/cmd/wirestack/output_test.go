@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+func TestNoCommandShadowsOutputFlag(t *testing.T) {
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if cmd.LocalNonPersistentFlags().Lookup("output") != nil || cmd.LocalNonPersistentFlags().ShorthandLookup("o") != nil {
+			t.Errorf("%s defines its own --output/-o, hiding the global output format", cmd.CommandPath())
+		}
+		for _, child := range cmd.Commands() {
+			walk(child)
+		}
+	}
+	walk(newRootCommand())
+}
+
+func TestOutputPathStillWritesTheFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	core.SetStore(core.FileStore{})
+	t.Cleanup(func() { outputFormat = outputTable })
+
+	profile, err := core.NewServerProfile(core.ServerOptions{Name: "lab", Endpoint: "203.0.113.1:51820"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	if _, err := core.AddClient(profile, core.ClientOptions{Name: "alice"}); err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	run := func(args ...string) error {
+		root := newRootCommand()
+		root.SetArgs(args)
+		root.SilenceErrors = true
+		root.SetOut(io.Discard)
+		return root.Execute()
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"export-client", "--server", "lab", "--client", "alice", "--output", filepath.Join(dir, "alice.conf")},
+		{"backup", "--output", filepath.Join(dir, "backup.tar.gz")},
+	} {
+		if err := run(args...); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if _, err := os.Stat(args[len(args)-1]); err != nil {
+			t.Fatalf("%v did not write its file: %v", args, err)
+		}
+	}
+	if err := run("export-client", "--server", "lab", "--client", "alice", "--out", filepath.Join(dir, "b.conf"), "--output", "c.conf"); err == nil {
+		t.Fatalf("expected --out and a path in --output to be refused together")
+	}
+}
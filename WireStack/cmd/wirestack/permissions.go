@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// permissionsCommand groups the commands that manage group access to ~/.wirestack.
+func permissionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "permissions",
+		Short: "Share parts of ~/.wirestack with a group, such as a web UI's",
+		Long: `WireStack writes files 0600 and directories 0700. A permissions policy
+lets one group, e.g. "wirestack", read selected paths so a process running as
+another user, such as a web UI, can read what it needs and nothing more.
+
+Shared files become 0640 and shared directories 0750, owned by the group;
+the directories leading to them become traversable (g+x) but not listable.
+The group never gets write access. By default the policy shares
+` + strings.Join(core.DefaultSharedPaths, ", ") + `, which hold no private keys.
+Server profiles, meshes, and rendered configs (` + strings.Join(core.PrivateKeyPaths, ", ") + `)
+contain private keys and are only shared when listed with --share; the CA,
+download token key, and locks are never shared by default. The home
+directory itself must also be traversable by the group.
+
+The policy is stored in ~/.wirestack/config.json and applied to every file
+WireStack writes. Not supported on Windows.`,
+	}
+	cmd.AddCommand(
+		permissionsShowCommand(),
+		permissionsSetCommand(),
+		permissionsClearCommand(),
+	)
+	return cmd
+}
+
+// permissionsShowCommand prints the current policy.
+func permissionsShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show the group and the paths shared with it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			view := permissionsView{Shared: []string{}}
+			if settings.Permissions != nil && settings.Permissions.Group != "" {
+				view.Group = settings.Permissions.Group
+				if view.Shared, err = settings.Permissions.SharedPaths(); err != nil {
+					return err
+				}
+			}
+			if structuredOutput() {
+				return printStructured(view)
+			}
+			if view.Group == "" {
+				fmt.Println("Group: none (everything is owner-only)")
+				return nil
+			}
+			fmt.Printf("Group: %s\n", view.Group)
+			fmt.Printf("Shared: %s\n", strings.Join(view.Shared, ", "))
+			return nil
+		},
+	}
+}
+
+// permissionsSetCommand stores a policy and applies it to existing files.
+func permissionsSetCommand() *cobra.Command {
+	var group string
+	var shared []string
+
+	cmd := &cobra.Command{
+		Use:   "set --group <group>",
+		Short: "Give a group read access and apply it to existing files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if group == "" {
+				return fmt.Errorf("--group is required")
+			}
+			cmd.SilenceUsage = true
+			policy := &core.PermissionSettings{Group: group, Shared: shared}
+			paths, err := policy.SharedPaths()
+			if err != nil {
+				return err
+			}
+			if exposed := core.SharesPrivateKeys(paths); len(exposed) > 0 {
+				fmt.Fprintf(os.Stderr, "warning: group %s can read private keys in %s\n", group, strings.Join(exposed, ", "))
+			}
+			return savePermissions(policy)
+		},
+	}
+
+	cmd.Flags().StringVar(&group, "group", "", "Group to give read access")
+	cmd.Flags().StringSliceVar(&shared, "share", nil, "Path relative to ~/.wirestack to share (repeatable; default "+strings.Join(core.DefaultSharedPaths, ",")+")")
+	return cmd
+}
+
+// permissionsClearCommand removes the policy and makes everything owner-only again.
+func permissionsClearCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Remove group access and make everything owner-only again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return savePermissions(nil)
+		},
+	}
+}
+
+// savePermissions validates and stores permissions, then brings existing
+// files in line with it.
+func savePermissions(permissions *core.PermissionSettings) error {
+	settings, err := core.LoadSettings()
+	if err != nil {
+		return err
+	}
+	settings.Permissions = permissions
+	// Install the policy first so a bad group is rejected before it is saved.
+	if err := core.ApplyPermissionSettings(settings); err != nil {
+		return err
+	}
+	if err := core.SaveSettings(settings); err != nil {
+		return err
+	}
+	changed, err := core.ApplyPermissions()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Permissions updated on %d path(s)\n", len(changed))
+	return nil
+}
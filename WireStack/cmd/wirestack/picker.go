@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// pickedNameFlags lists, per command, the name flags a picker asks for when
+// they are omitted in a terminal, in the order they are asked. Commands not
+// listed either take a new name or treat a missing one as meaningful.
+var pickedNameFlags = map[string][]string{
+	"add-client":            {"server"},
+	"bulk":                  {"server"},
+	"annotate":              {"server"},
+	"delete-dns-route":      {"server"},
+	"delete-group":          {"server"},
+	"delete-policy":         {"server"},
+	"delete-version-policy": {"server"},
+	"diff-config":           {"server"},
+	"edit-server":           {"server"},
+	"export-all":            {"server"},
+	"export-server":         {"server"},
+	"list-clients":          {"server"},
+	"list-groups":           {"server"},
+	"migrate-openvpn":       {"server"},
+	"migration-bundle":      {"server"},
+	"migration-status":      {"server"},
+	"rotate-key":            {"server"},
+	"set-amnezia":           {"server"},
+	"set-dns-route":         {"server"},
+	"set-group":             {"server"},
+	"set-policy":            {"server"},
+	"set-version-policy":    {"server"},
+	"connect":               {"server", "client"},
+	"delete-client":         {"server", "client"},
+	"disconnect":            {"server", "client"},
+	"download-token":        {"server", "client"},
+	"edit-client":           {"server", "client"},
+	"export-client":         {"server", "client"},
+}
+
+// explicitPickCommands delete or re-key what is picked, so Enter alone does
+// not pick the first match; a number or an exact name is needed.
+var explicitPickCommands = map[string]bool{
+	"bulk":                  true,
+	"delete-client":         true,
+	"delete-dns-route":      true,
+	"delete-group":          true,
+	"delete-policy":         true,
+	"delete-version-policy": true,
+	"rotate-key":            true,
+}
+
+// pickerPageSize is how many matches the picker lists at once.
+const pickerPageSize = 10
+
+// pickMissingNames asks for the --server and --client names cmd needs but
+// was not given, when stdin and stderr are a terminal. Elsewhere the flags
+// stay empty and the command reports them missing as before.
+func pickMissingNames(cmd *cobra.Command) error {
+	flags := pickedNameFlags[cmd.Name()]
+	if len(flags) == 0 || demoStore != nil || !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		return nil
+	}
+	if skip, ok := currentServerSkip[cmd.Name()]; ok && (skip == "" || cmd.Flags().Changed(skip)) {
+		return nil
+	}
+	input := bufio.NewReader(os.Stdin)
+	for _, flag := range flags {
+		if value, _ := cmd.Flags().GetString(flag); value != "" {
+			continue
+		}
+		// --tag picks the clients itself.
+		if flag == "client" && cmd.Flags().Lookup("tag") != nil && cmd.Flags().Changed("tag") {
+			continue
+		}
+		var names []string
+		if flag == "server" {
+			var err error
+			if names, err = core.ListServerProfiles(); err != nil {
+				return err
+			}
+		} else {
+			serverName, _ := cmd.Flags().GetString("server")
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			for _, client := range profile.Clients {
+				names = append(names, client.Name)
+			}
+		}
+		choice, err := pickName(input, os.Stderr, flag, names, explicitPickCommands[cmd.Name()])
+		if err != nil {
+			return err
+		}
+		if err := cmd.Flags().Set(flag, choice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// pickName lets the user choose one of names. Each line typed narrows the
+// list with a fuzzy match; a number or an exact name picks that entry, and
+// an empty line picks the best match unless explicit is set.
+func pickName(input *bufio.Reader, output io.Writer, kind string, names []string, explicit bool) (string, error) {
+	if len(names) == 0 {
+		return "", fmt.Errorf("--%s is required and there is no %s to choose from", kind, kind)
+	}
+	matches := fuzzyFilter("", names)
+	if explicit {
+		fmt.Fprintf(output, "Choose a %s (type to filter, a number or the full name to pick):\n", kind)
+	} else {
+		fmt.Fprintf(output, "Choose a %s (type to filter, a number to pick, Enter for the first):\n", kind)
+	}
+	for {
+		for idx, name := range matches {
+			if idx == pickerPageSize {
+				fmt.Fprintf(output, "  ... and %d more\n", len(matches)-pickerPageSize)
+				break
+			}
+			fmt.Fprintf(output, "  %d) %s\n", idx+1, name)
+		}
+		if len(matches) == 0 {
+			fmt.Fprintln(output, "  no match")
+		}
+		fmt.Fprintf(output, "%s> ", kind)
+		line, err := input.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(output)
+			return "", fmt.Errorf("no %s chosen; pass --%s", kind, kind)
+		}
+		query := strings.TrimSpace(line)
+		for _, name := range names {
+			if name == query {
+				return name, nil
+			}
+		}
+		if n, err := strconv.Atoi(query); err == nil && n >= 1 && n <= len(matches) && n <= pickerPageSize {
+			return matches[n-1], nil
+		}
+		if query == "" && len(matches) > 0 && !explicit {
+			return matches[0], nil
+		}
+		matches = fuzzyFilter(query, names)
+	}
+}
+
+// fuzzyFilter returns the names containing the characters of query in order,
+// ignoring case, best matches first. An empty query keeps every name in order.
+func fuzzyFilter(query string, names []string) []string {
+	if query == "" {
+		return append([]string(nil), names...)
+	}
+	type match struct {
+		name  string
+		score int
+	}
+	var matches []match
+	for _, name := range names {
+		if score, ok := fuzzyScore(query, name); ok {
+			matches = append(matches, match{name, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].name < matches[j].name
+	})
+	filtered := make([]string, len(matches))
+	for idx, m := range matches {
+		filtered[idx] = m.name
+	}
+	return filtered
+}
+
+// fuzzyScore matches query against name as a subsequence. Characters that
+// follow the previous match or start a word score higher, and every skipped
+// character costs a little, so "nas" ranks "nas" over "n-a-s" and "guest-nas".
+func fuzzyScore(query, name string) (int, bool) {
+	target := []rune(strings.ToLower(name))
+	score, pos := 0, 0
+	previous := -2
+	for _, want := range strings.ToLower(query) {
+		for pos < len(target) && target[pos] != want {
+			pos++
+		}
+		if pos == len(target) {
+			return 0, false
+		}
+		switch {
+		case pos == previous+1:
+			score += 3
+		case pos == 0 || !unicode.IsLetter(target[pos-1]) && !unicode.IsDigit(target[pos-1]):
+			score += 2
+		default:
+			score++
+		}
+		previous = pos
+		pos++
+	}
+	return score*10 - (len(target) - len([]rune(query))), true
+}
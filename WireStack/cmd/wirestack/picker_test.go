@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyFilter(t *testing.T) {
+	names := []string{"guest-nas", "laptop", "n-a-s", "nas", "phone"}
+	if got, want := fuzzyFilter("nas", names), []string{"nas", "guest-nas", "n-a-s"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fuzzyFilter(nas) = %q, want %q", got, want)
+	}
+	if got, want := fuzzyFilter("PHN", names), []string{"phone"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fuzzyFilter(PHN) = %q, want %q", got, want)
+	}
+	if got := fuzzyFilter("", names); !reflect.DeepEqual(got, names) {
+		t.Fatalf("an empty query should keep every name, got %q", got)
+	}
+}
+
+func TestPickName(t *testing.T) {
+	names := []string{"home", "lab", "office"}
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"\n", "home"},
+		{"3\n", "office"},
+		{"of\n\n", "office"},
+		{"xyz\nl\n1\n", "lab"},
+		{"lab", "lab"},
+	} {
+		got, err := pickName(bufio.NewReader(strings.NewReader(tc.input)), io.Discard, "server", names, false)
+		if err != nil || got != tc.want {
+			t.Errorf("input %q: got %q (%v), want %q", tc.input, got, err, tc.want)
+		}
+	}
+	if _, err := pickName(bufio.NewReader(strings.NewReader("")), io.Discard, "server", names, false); err == nil {
+		t.Fatalf("expected an error when input ends without a choice")
+	}
+	if _, err := pickName(bufio.NewReader(strings.NewReader("\n")), io.Discard, "client", nil, false); err == nil {
+		t.Fatalf("expected an error with nothing to choose from")
+	}
+
+	// Destructive commands never take Enter as a choice.
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"\n\n2\n", "lab"},
+		{"of\n\noffice\n", "office"},
+	} {
+		got, err := pickName(bufio.NewReader(strings.NewReader(tc.input)), io.Discard, "client", names, true)
+		if err != nil || got != tc.want {
+			t.Errorf("explicit input %q: got %q (%v), want %q", tc.input, got, err, tc.want)
+		}
+	}
+	if _, err := pickName(bufio.NewReader(strings.NewReader("\n")), io.Discard, "client", names, true); err == nil {
+		t.Fatalf("expected Enter alone to pick nothing for a destructive command")
+	}
+	if !explicitPickCommands["delete-client"] || !explicitPickCommands["rotate-key"] {
+		t.Fatalf("delete-client and rotate-key must need an explicit choice")
+	}
+	for name := range explicitPickCommands {
+		if _, ok := pickedNameFlags[name]; !ok {
+			t.Errorf("%s needs an explicit choice but has no picker", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// setPolicyCommand creates or replaces a tag-based AllowedIPs policy.
+func setPolicyCommand() *cobra.Command {
+	var serverName string
+	var tag string
+	var allowedIPs []string
+
+	cmd := &cobra.Command{
+		Use:   "set-policy",
+		Short: "Grant clients with a tag a specific set of AllowedIPs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || tag == "" {
+				return fmt.Errorf("both --server and --tag are required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if err := core.SetPolicy(profile, tag, allowedIPs); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+
+			fmt.Printf("Policy for tag %s saved on server %s\n", tag, serverName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&tag, "tag", "", "Client tag the policy applies to")
+	cmd.Flags().StringSliceVar(&allowedIPs, "allowed-ips", nil, "Comma separated CIDRs rendered as the client's AllowedIPs")
+	return cmd
+}
+
+// deletePolicyCommand removes a tag-based AllowedIPs policy.
+func deletePolicyCommand() *cobra.Command {
+	var serverName string
+	var tag string
+
+	cmd := &cobra.Command{
+		Use:   "delete-policy",
+		Short: "Remove a tag-based AllowedIPs policy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || tag == "" {
+				return fmt.Errorf("both --server and --tag are required")
+			}
+
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if err := core.RemovePolicy(profile, tag); err != nil {
+				return err
+			}
+			if err := core.SaveServerProfile(profile); err != nil {
+				return err
+			}
+
+			fmt.Printf("Policy for tag %s removed from server %s\n", tag, serverName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&tag, "tag", "", "Client tag of the policy to remove")
+	return cmd
+}
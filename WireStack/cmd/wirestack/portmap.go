@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"wirestack/internal/core"
+)
+
+// portMapRenewInterval is how often the daemon renews router port mappings,
+// half of the lease up requests.
+const portMapRenewInterval = core.DefaultPortMappingLifetime / 2
+
+// mapServerPort asks the router to forward the server's listen port and
+// records the mapping so down and teardown release it.
+func mapServerPort(profile *core.ServerProfile) error {
+	mapping, err := core.MapServerPort(context.Background(), profile)
+	if err != nil {
+		return err
+	}
+	if err := core.RecordArtifacts(profile.Name, core.PortMappingArtifact(mapping)); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	fmt.Printf("Router forwards UDP port %d to port %d (%s)\n", mapping.ExternalPort, mapping.InternalPort, mapping.Method)
+	if _, port, err := net.SplitHostPort(profile.Endpoint); err == nil && port != strconv.Itoa(mapping.ExternalPort) {
+		fmt.Fprintf(os.Stderr, "warning: the router chose external port %d, but clients connect to port %s\n", mapping.ExternalPort, port)
+	}
+	return nil
+}
+
+// releasePortMappings removes the router port mappings recorded for server.
+// Failures are warnings; teardown can retry them.
+func releasePortMappings(server string) {
+	result, err := core.Teardown(server, []string{core.ArtifactPortMap}, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		return
+	}
+	for _, artifact := range result.Removed {
+		fmt.Printf("Released router port mapping %s\n", artifact.Target)
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "warning: release router port mapping %s: %v; retry with wirestack teardown %s --what portmap\n", failure.Artifact.Target, failure.Err, server)
+	}
+}
+
+// renewPortMappings renews the router port mapping of every server that has
+// one and whose interface is up, before the lease up requested runs out.
+func renewPortMappings() {
+	names, err := core.ListServerProfiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: renew port mappings: %v\n", err)
+		return
+	}
+	for _, name := range names {
+		profile, err := core.LoadServerProfile(name)
+		if err != nil || profile.PortMapping == "" || !core.InterfaceIsUp(core.InterfaceName(profile)) {
+			continue
+		}
+		mapping, err := core.MapServerPort(context.Background(), profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: renew port mapping of %s: %v\n", name, err)
+			continue
+		}
+		if err := core.RecordArtifacts(name, core.PortMappingArtifact(mapping)); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+}
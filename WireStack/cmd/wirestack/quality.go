@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// metricsPath serves Prometheus metrics. It sits outside apiPrefix, as
+// scrapers expect, but still requires the bearer token.
+const metricsPath = "/metrics"
+
+// qualityCommand shows connection quality scores and their sample history.
+func qualityCommand() *cobra.Command {
+	var clientName string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:               "quality <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Show connection quality scores and sample history",
+		Long: `Show per-client connection quality scores for a server.
+
+Samples are recorded at most once a minute by "wirestack status" and by
+"wirestack serve" while the interface is up; "status --probe" adds ping
+RTT and loss. With --client, the recent samples of one client are listed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			history, err := core.LoadQualityHistory(args[0])
+			if err != nil {
+				return err
+			}
+			if clientName != "" {
+				samples, ok := history.Clients[clientName]
+				if !ok {
+					return fmt.Errorf("no quality samples recorded for client %s", clientName)
+				}
+				if limit > 0 && len(samples) > limit {
+					samples = samples[len(samples)-limit:]
+				}
+				return printQualitySamples(samples)
+			}
+
+			scores := history.Scores()
+			names := make([]string, 0, len(scores))
+			for name := range scores {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if structuredOutput() {
+				views := make([]qualityView, 0, len(names))
+				for _, name := range names {
+					views = append(views, newQualityView(args[0], name, scores[name]))
+				}
+				return printStructured(views)
+			}
+			if len(names) == 0 {
+				fmt.Println("no quality samples recorded")
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "CLIENT\tQUALITY\tSCORE\tSAMPLES\tREASONS")
+			for _, name := range names {
+				score := scores[name]
+				value := "-"
+				if score.Level != core.QualityUnknown {
+					value = fmt.Sprint(score.Score)
+				}
+				reasons := strings.Join(score.Reasons, "; ")
+				if reasons == "" {
+					reasons = "-"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\n", name, score.Level, value, score.Samples, reasons)
+			}
+			return writer.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&clientName, "client", "", "List the recorded samples of this client")
+	cmd.Flags().IntVar(&limit, "limit", 20, "Number of most recent samples listed with --client (0 for all)")
+	return cmd
+}
+
+// printQualitySamples lists samples oldest first.
+func printQualitySamples(samples []core.QualitySample) error {
+	if structuredOutput() {
+		return printStructured(samples)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tHANDSHAKE AGE\tRX\tTX\tRTT\tLOSS")
+	for _, sample := range samples {
+		age := "never"
+		if sample.HandshakeAge >= 0 {
+			age = (time.Duration(sample.HandshakeAge) * time.Second).String()
+		}
+		rtt, loss := "-", "-"
+		if sample.Probed {
+			rtt = fmt.Sprintf("%.1f ms", sample.RTTMs)
+			loss = fmt.Sprintf("%.0f%%", sample.Loss*100)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			sample.At.Local().Format(time.DateTime),
+			age,
+			utils.FormatBytes(sample.RxBytes),
+			utils.FormatBytes(sample.TxBytes),
+			rtt,
+			loss,
+		)
+	}
+	return writer.Flush()
+}
+
+// serveMetrics writes the quality gauges in the Prometheus text format.
+// Clients without a known score are left out.
+func (h *apiHandler) serveMetrics(w http.ResponseWriter) error {
+	names, err := core.ListServerProfiles()
+	if err != nil {
+		return err
+	}
+	builder := &strings.Builder{}
+	fmt.Fprintln(builder, "# HELP wirestack_client_quality_score Connection quality score per client, from 0 (poor) to 100 (good).")
+	fmt.Fprintln(builder, "# TYPE wirestack_client_quality_score gauge")
+	for _, name := range names {
+		history, err := core.LoadQualityHistory(name)
+		if err != nil {
+			return err
+		}
+		scores := history.Scores()
+		clients := make([]string, 0, len(scores))
+		for client := range scores {
+			clients = append(clients, client)
+		}
+		sort.Strings(clients)
+		for _, client := range clients {
+			score := scores[client]
+			if score.Level == core.QualityUnknown {
+				continue
+			}
+			fmt.Fprintf(builder, "wirestack_client_quality_score{server=%s,client=%s} %d\n", promLabel(name), promLabel(client), score.Score)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(builder.String()))
+	return nil
+}
+
+// promLabel quotes a Prometheus label value.
+func promLabel(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"wirestack/internal/utils"
+)
+
+// recordCommandsEnv names a file that receives every wg, wg-quick, ip, and
+// other external command the CLI runs, for replay in tests.
+const recordCommandsEnv = "WIRESTACK_RECORD_COMMANDS"
+
+// startCommandRecording begins recording when recordCommandsEnv is set and
+// returns a function that saves the fixture. The home directory is saved as
+// ${HOME} so the fixture replays on other machines.
+func startCommandRecording() func() {
+	path := os.Getenv(recordCommandsEnv)
+	if path == "" {
+		return func() {}
+	}
+	stop := utils.RecordCommands()
+	return func() {
+		fixture := stop()
+		home, _ := os.UserHomeDir()
+		if err := utils.SaveCommandFixture(path, fixture, map[string]string{"HOME": home}); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save recorded commands: %v\n", err)
+		}
+	}
+}
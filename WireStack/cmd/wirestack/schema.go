@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// migrateCommand rewrites stored profiles in the current schema version.
+func migrateCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate [server...]",
+		Short: "Upgrade stored profiles to the current schema version",
+		Long: `Upgrade stored server profiles to the current schema version.
+
+Older profiles are already upgraded in memory whenever they are loaded; this
+command writes the upgraded form back so the store matches. Profiles written
+by a newer wirestack are rejected instead of being rewritten. Take a
+backup with "wirestack backup" first.`,
+		ValidArgsFunction: completeServerFlag,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := args
+			if len(names) == 0 {
+				var err error
+				names, err = core.ListServerProfiles()
+				if err != nil {
+					return err
+				}
+			}
+
+			upgraded := 0
+			for _, name := range names {
+				unlock, err := core.LockServerProfile(name)
+				if err != nil {
+					return err
+				}
+				defer unlock()
+				profile, err := core.LoadServerProfile(name)
+				if err != nil {
+					return err
+				}
+				pending := core.PendingMigrations(profile.SchemaVersion)
+				if len(pending) == 0 {
+					continue
+				}
+				for _, migration := range pending {
+					fmt.Printf("%s: v%d -> v%d: %s\n", name, migration.From, migration.From+1, migration.Description)
+				}
+				upgraded++
+				if dryRun {
+					continue
+				}
+				if err := core.SaveServerProfile(profile); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case upgraded == 0:
+				fmt.Printf("All profiles are at schema version %d\n", core.CurrentSchemaVersion)
+			case dryRun:
+				fmt.Printf("%d profile(s) would be upgraded to schema version %d\n", upgraded, core.CurrentSchemaVersion)
+			default:
+				fmt.Printf("Upgraded %d profile(s) to schema version %d\n", upgraded, core.CurrentSchemaVersion)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List pending migrations without saving")
+	return cmd
+}
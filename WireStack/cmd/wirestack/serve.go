@@ -0,0 +1,821 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// apiPrefix is the path every REST endpoint lives under.
+const apiPrefix = "/api/v1/"
+
+// maxRequestBody caps JSON request bodies accepted by the API.
+const maxRequestBody = 1 << 20
+
+// serveCommand exposes profile management over a JSON REST API.
+func serveCommand() *cobra.Command {
+	var flagValues serveConfig
+	var token string
+	var insecureNoAuth bool
+	var shutdownTimeout time.Duration
+	var tlsOptions daemonTLSOptions
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a REST API for managing servers and clients",
+		Long: `Serve a REST API for managing servers and clients.
+
+Listen addresses, the event interval, and the profile store can also be set
+in the "serve" section of ~/.wirestack/config.json; flags given on the
+command line take precedence. SIGHUP re-reads that file and applies changes
+without dropping the API: new listeners open before the old ones close.
+SIGINT and SIGTERM stop accepting requests, let requests in flight finish
+(up to --shutdown-timeout), and wait for a running event poll before exiting.
+
+Each API request except the event stream must finish within
+--request-timeout. When it runs out, commands the request started (wg, ip,
+and the like) are stopped and the API answers 504 Gateway Timeout.
+
+Under systemd socket activation, --listen systemd: (or --bench-listen) takes
+the next socket the socket unit passes, and systemd:<name> the one with that
+FileDescriptorName. systemd then owns the socket, so a unix socket's mode and
+group come from SocketMode= and SocketGroup=, and the daemon starts on the
+first connection. A socket that was replaced on reload cannot be taken again.
+
+Every request needs the bearer token from --token or WIRESTACK_API_TOKEN,
+and serve refuses to start without one unless --tls is used or
+--insecure-no-auth is given. Request bodies must be application/json.
+
+With --tls the API is served over HTTPS using a daemon certificate issued by
+the built-in CA (wirestack ca init), and every request must present a client
+certificate from "wirestack ca issue-agent". Signed download links are exempt.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if token == "" {
+				token = os.Getenv("WIRESTACK_API_TOKEN")
+			}
+			if token == "" && !tlsOptions.enabled {
+				if !insecureNoAuth {
+					return fmt.Errorf("--token or WIRESTACK_API_TOKEN is required (or --tls); pass --insecure-no-auth to serve the API without authentication")
+				}
+				fmt.Fprintln(os.Stderr, "warning: no API token set; anyone who can reach the listener can read private keys")
+			}
+			if shutdownTimeout <= 0 {
+				return fmt.Errorf("--shutdown-timeout must be positive")
+			}
+
+			daemon, err := newDaemon(cmd, flagValues, token, tlsOptions, shutdownTimeout)
+			if err != nil {
+				return err
+			}
+			return daemon.run()
+		},
+	}
+
+	cmd.Flags().StringVar(&flagValues.listen, "listen", "127.0.0.1:8080", "Address to listen on, or systemd:[name] for a socket passed by systemd")
+	cmd.Flags().StringVar(&token, "token", "", "Bearer token required on every request (default $WIRESTACK_API_TOKEN)")
+	cmd.Flags().BoolVar(&insecureNoAuth, "insecure-no-auth", false, "Serve the API without a token or client certificates; any local process or web page that reaches it can read private keys")
+	cmd.Flags().StringVar(&flagValues.benchListen, "bench-listen", "", "Also serve unauthenticated bandwidth test endpoints for wirestack bench on this address (use the tunnel address, e.g. 10.0.0.1:8081)")
+	cmd.Flags().DurationVar(&flagValues.eventInterval, "event-interval", 5*time.Second, "How often the event stream checks profiles and peer handshakes")
+	cmd.Flags().BoolVar(&tlsOptions.enabled, "tls", false, "Serve HTTPS with a certificate from the built-in CA and require client certificates it issued (see wirestack ca)")
+	cmd.Flags().StringArrayVar(&tlsOptions.hosts, "tls-host", nil, "Extra host name or IP address for the daemon certificate (repeatable)")
+	cmd.Flags().DurationVar(&flagValues.requestTimeout, "request-timeout", 30*time.Second, "How long an API request may run before its commands are stopped and it fails with 504")
+	cmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for requests in flight when stopping or moving a listener")
+	return cmd
+}
+
+// apiHandler routes REST requests to the same core functions the CLI uses.
+type apiHandler struct {
+	token  string
+	events *eventBroker
+	// clientCerts requires a client certificate verified against the built-in CA.
+	clientCerts bool
+	// caMu serialises CA registry updates. Profile changes take the per-server
+	// core.LockServerProfileContext instead, so different servers do not wait
+	// for each other.
+	caMu sync.Mutex
+	// storeMu is held shared by profile changes and exclusively while the
+	// daemon switches stores, so no change straddles the old and new store.
+	storeMu sync.RWMutex
+	// requestTimeout bounds every request but the event stream, in
+	// nanoseconds; zero leaves requests unbounded. Reloads update it.
+	requestTimeout atomic.Int64
+}
+
+// newAPIHandler builds the HTTP handler for the REST API.
+func newAPIHandler(token string, events *eventBroker) *apiHandler {
+	return &apiHandler{token: token, events: events}
+}
+
+// lockProfile takes the lock for a change to the named server profile. The
+// returned function releases it.
+func (h *apiHandler) lockProfile(r *http.Request, name string) (func(), error) {
+	h.storeMu.RLock()
+	unlock, err := core.LockServerProfileContext(r.Context(), name)
+	if err != nil {
+		h.storeMu.RUnlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		h.storeMu.RUnlock()
+	}, nil
+}
+
+// apiError is the JSON body returned for failed requests.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// httpError pairs an error with the status code it should be reported as.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+func (e *httpError) Unwrap() error { return e.err }
+
+// badRequest marks err as a client error.
+func badRequest(err error) error {
+	return &httpError{status: http.StatusBadRequest, err: err}
+}
+
+// forbidden marks err as a request the caller is not allowed to make.
+func forbidden(err error) error {
+	return &httpError{status: http.StatusForbidden, err: err}
+}
+
+// ServeHTTP authenticates the request and dispatches it by path.
+func (h *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Download links carry their own signed token instead of the bearer token.
+	if token, ok := strings.CutPrefix(r.URL.Path, downloadPath); ok {
+		if err := h.serveDownload(w, r, token); err != nil {
+			writeAPIError(w, err)
+		}
+		return
+	}
+	// The CRL is signed, and services that check it may hold no credentials.
+	if r.URL.Path == caCRLPath {
+		if err := h.serveCRL(w, r); err != nil {
+			writeAPIError(w, err)
+		}
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeAPIJSON(w, http.StatusUnauthorized, apiError{Error: "unauthorized"})
+		return
+	}
+	if r.URL.Path == metricsPath {
+		err := allowMethods(r, http.MethodGet)
+		if err == nil {
+			err = h.serveMetrics(w)
+		}
+		if err != nil {
+			writeAPIError(w, err)
+		}
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		writeAPIJSON(w, http.StatusNotFound, apiError{Error: "not found"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	if len(parts) == 1 && parts[0] == "events" {
+		err := allowMethods(r, http.MethodGet)
+		if err == nil {
+			err = h.streamEvents(w, r)
+		}
+		if err != nil {
+			writeAPIError(w, err)
+		}
+		return
+	}
+	if timeout := time.Duration(h.requestTimeout.Load()); timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	if len(parts) == 2 && parts[0] == "ca" && parts[1] == "renew" {
+		err := allowMethods(r, http.MethodPost)
+		if err == nil {
+			err = h.renewAgent(w, r)
+		}
+		if err != nil {
+			writeAPIError(w, err)
+		}
+		return
+	}
+	if len(parts) == 0 || parts[0] != "servers" {
+		writeAPIJSON(w, http.StatusNotFound, apiError{Error: "not found"})
+		return
+	}
+
+	var err error
+	switch {
+	case len(parts) == 1:
+		err = h.routeServers(w, r)
+	case len(parts) == 2:
+		err = h.routeServer(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "config":
+		err = allowMethods(r, http.MethodGet)
+		if err == nil {
+			err = h.exportServer(w, r, parts[1])
+		}
+	case len(parts) == 3 && parts[2] == "status":
+		err = allowMethods(r, http.MethodGet)
+		if err == nil {
+			err = h.serverStatus(w, r, parts[1])
+		}
+	case len(parts) == 3 && parts[2] == "clients":
+		err = h.routeClients(w, r, parts[1])
+	case len(parts) == 4 && parts[2] == "clients":
+		err = h.routeClient(w, r, parts[1], parts[3])
+	case len(parts) == 5 && parts[2] == "clients" && parts[4] == "config":
+		err = allowMethods(r, http.MethodGet)
+		if err == nil {
+			err = h.exportClient(w, r, parts[1], parts[3])
+		}
+	case len(parts) == 5 && parts[2] == "clients" && parts[4] == "platform":
+		err = allowMethods(r, http.MethodPut)
+		if err == nil {
+			err = h.reportPlatform(w, r, parts[1], parts[3])
+		}
+	case len(parts) == 5 && parts[2] == "clients" && parts[4] == "download-token":
+		err = allowMethods(r, http.MethodPost)
+		if err == nil {
+			err = h.createDownloadToken(w, r, parts[1], parts[3])
+		}
+	default:
+		writeAPIJSON(w, http.StatusNotFound, apiError{Error: "not found"})
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.events.poke()
+	}
+}
+
+// authorized checks the client certificate and the bearer token when they are required.
+func (h *apiHandler) authorized(r *http.Request) bool {
+	if h.clientCerts && !h.validClientCert(r) {
+		return false
+	}
+	if h.token != "" {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) == 1 {
+			return true
+		}
+	}
+	// An agent certificate alone may check in its own client, so agents never
+	// need the admin token, which can read every private key.
+	if h.clientCerts && ownPlatformCheckIn(r) {
+		return true
+	}
+	// Without a token (--tls alone, or --insecure-no-auth) any caller that got
+	// this far is trusted, but a client's agent certificate never becomes an
+	// admin credential.
+	return h.token == "" && !strings.Contains(presentedAgent(r), "/")
+}
+
+// ownPlatformCheckIn reports whether r is a platform report for the client
+// named by the agent certificate it presented.
+func ownPlatformCheckIn(r *http.Request) bool {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	return r.Method == http.MethodPut && len(parts) == 5 && parts[0] == "servers" && parts[2] == "clients" && parts[4] == "platform" &&
+		presentedAgent(r) == agentName(parts[1], parts[3])
+}
+
+func (h *apiHandler) routeServers(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		return h.listServers(w, r)
+	case http.MethodPost:
+		return h.createServer(w, r)
+	default:
+		return methodNotAllowed(http.MethodGet, http.MethodPost)
+	}
+}
+
+func (h *apiHandler) routeServer(w http.ResponseWriter, r *http.Request, name string) error {
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := core.LoadServerProfile(name)
+		if err != nil {
+			return err
+		}
+		writeAPIJSON(w, http.StatusOK, newServerView(profile))
+		return nil
+	case http.MethodPatch:
+		return h.patchAnnotations(w, r, name, "")
+	case http.MethodDelete:
+		unlock, err := h.lockProfile(r, name)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := core.DeleteServerProfile(name); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return methodNotAllowed(http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (h *apiHandler) routeClients(w http.ResponseWriter, r *http.Request, serverName string) error {
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := core.LoadServerProfile(serverName)
+		if err != nil {
+			return err
+		}
+		selectors := r.URL.Query()["annotation"]
+		views := make([]clientView, 0, len(profile.Clients))
+		for _, client := range profile.Clients {
+			matched, err := core.MatchAnnotations(client.Annotations, selectors)
+			if err != nil {
+				return badRequest(err)
+			}
+			if matched {
+				views = append(views, newClientView(profile, client))
+			}
+		}
+		writeAPIJSON(w, http.StatusOK, views)
+		return nil
+	case http.MethodPost:
+		return h.createClient(w, r, serverName)
+	default:
+		return methodNotAllowed(http.MethodGet, http.MethodPost)
+	}
+}
+
+func (h *apiHandler) routeClient(w http.ResponseWriter, r *http.Request, serverName, clientName string) error {
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := core.LoadServerProfile(serverName)
+		if err != nil {
+			return err
+		}
+		client, err := core.FindClient(profile, clientName)
+		if err != nil {
+			return err
+		}
+		writeAPIJSON(w, http.StatusOK, newClientView(profile, *client))
+		return nil
+	case http.MethodPatch:
+		return h.patchAnnotations(w, r, serverName, clientName)
+	case http.MethodDelete:
+		return h.deleteClient(w, r, serverName, clientName)
+	default:
+		return methodNotAllowed(http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+// listServers returns every stored server profile, filtered by ?annotation=.
+func (h *apiHandler) listServers(w http.ResponseWriter, r *http.Request) error {
+	selectors := r.URL.Query()["annotation"]
+	names, err := core.ListServerProfiles()
+	if err != nil {
+		return err
+	}
+	views := make([]serverView, 0, len(names))
+	for _, name := range names {
+		profile, err := core.LoadServerProfile(name)
+		if err != nil {
+			return err
+		}
+		matched, err := core.MatchAnnotations(profile.Annotations, selectors)
+		if err != nil {
+			return badRequest(err)
+		}
+		if matched {
+			views = append(views, newServerView(profile))
+		}
+	}
+	writeAPIJSON(w, http.StatusOK, views)
+	return nil
+}
+
+// serverRequest is the body accepted by POST /servers.
+type serverRequest struct {
+	Name               string            `json:"name"`
+	Endpoint           string            `json:"endpoint"`
+	Subnet             string            `json:"subnet"`
+	Subnet6            string            `json:"subnet6"`
+	ExternalInterface  string            `json:"external_interface"`
+	ClientExtra        string            `json:"client_extra"`
+	Description        string            `json:"description"`
+	Alias              string            `json:"alias"`
+	MTU                int               `json:"mtu"`
+	NATInterface       string            `json:"nat_interface"`
+	NATBackend         string            `json:"nat_backend"`
+	PortMapping        string            `json:"port_mapping"`
+	PostUp             []string          `json:"post_up"`
+	PostDown           []string          `json:"post_down"`
+	Annotations        map[string]string `json:"annotations"`
+	Networks           []string          `json:"networks"`
+	SearchDomains      []string          `json:"search_domains"`
+	AlternateEndpoints []string          `json:"alternate_endpoints"`
+}
+
+// createServer creates a server profile from a JSON body.
+func (h *apiHandler) createServer(w http.ResponseWriter, r *http.Request) error {
+	var req serverRequest
+	if err := decodeAPIJSON(w, r, &req); err != nil {
+		return err
+	}
+	if req.Name == "" || req.Endpoint == "" {
+		return badRequest(fmt.Errorf("name and endpoint are required"))
+	}
+
+	unlock, err := h.lockProfile(r, req.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	profile, err := core.NewServerProfileContext(r.Context(), core.ServerOptions{
+		Name:               req.Name,
+		Endpoint:           req.Endpoint,
+		Subnet:             req.Subnet,
+		Subnet6:            req.Subnet6,
+		ExternalInterface:  req.ExternalInterface,
+		ClientExtra:        req.ClientExtra,
+		Description:        req.Description,
+		Alias:              req.Alias,
+		MTU:                req.MTU,
+		NATInterface:       req.NATInterface,
+		NATBackend:         req.NATBackend,
+		PortMapping:        req.PortMapping,
+		PostUp:             req.PostUp,
+		PostDown:           req.PostDown,
+		Networks:           req.Networks,
+		SearchDomains:      req.SearchDomains,
+		AlternateEndpoints: req.AlternateEndpoints,
+	})
+	if err != nil {
+		return badRequest(err)
+	}
+	if profile.Annotations, err = core.UpdateAnnotations(nil, req.Annotations, nil); err != nil {
+		return badRequest(err)
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		return err
+	}
+	writeAPIJSON(w, http.StatusCreated, newServerView(profile))
+	return nil
+}
+
+// clientRequest is the body accepted by POST /servers/{server}/clients.
+type clientRequest struct {
+	Name        string            `json:"name"`
+	Tags        []string          `json:"tags"`
+	Extra       string            `json:"extra"`
+	Description string            `json:"description"`
+	ExpiresAt   *time.Time        `json:"expires_at"`
+	Annotations map[string]string `json:"annotations"`
+	Mode        string            `json:"mode"`
+	Routes      []string          `json:"routes"`
+	AllowedIPs  []string          `json:"allowed_ips"`
+	DNS         []string          `json:"dns"`
+	MTU         int               `json:"mtu"`
+	Group       string            `json:"group"`
+}
+
+// createClient adds a client to a server from a JSON body.
+func (h *apiHandler) createClient(w http.ResponseWriter, r *http.Request, serverName string) error {
+	var req clientRequest
+	if err := decodeAPIJSON(w, r, &req); err != nil {
+		return err
+	}
+	if req.Name == "" {
+		return badRequest(fmt.Errorf("name is required"))
+	}
+	annotations, err := core.UpdateAnnotations(nil, req.Annotations, nil)
+	if err != nil {
+		return badRequest(err)
+	}
+
+	unlock, err := h.lockProfile(r, serverName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	client, err := core.AddClientContext(r.Context(), profile, core.ClientOptions{
+		Name:        req.Name,
+		Tags:        req.Tags,
+		Extra:       req.Extra,
+		Description: req.Description,
+		ExpiresAt:   req.ExpiresAt,
+		Mode:        req.Mode,
+		SplitRoutes: req.Routes,
+		AllowedIPs:  req.AllowedIPs,
+		DNS:         req.DNS,
+		MTU:         req.MTU,
+		Group:       req.Group,
+	})
+	if err != nil {
+		return badRequest(err)
+	}
+	client.Annotations = annotations
+	profile.Clients[len(profile.Clients)-1].Annotations = annotations
+	if err := core.SaveServerProfile(profile); err != nil {
+		return err
+	}
+	if err := syncLivePeer(r.Context(), profile, func(ctx context.Context, iface string) error {
+		return core.ApplyLivePeerContext(ctx, iface, client)
+	}); err != nil {
+		return fmt.Errorf("client %s was saved but not added to %s: %w", client.Name, profile.ExternalInterface, err)
+	}
+	writeAPIJSON(w, http.StatusCreated, newClientView(profile, client))
+	return nil
+}
+
+// annotationPatch is the JSON merge patch accepted by PATCH on servers and
+// clients: a string sets an annotation and null removes it.
+type annotationPatch struct {
+	Annotations map[string]*string `json:"annotations"`
+}
+
+// patchAnnotations updates the annotations of a server, or of a client when clientName is set.
+func (h *apiHandler) patchAnnotations(w http.ResponseWriter, r *http.Request, serverName, clientName string) error {
+	var patch annotationPatch
+	if err := decodeAPIJSON(w, r, &patch); err != nil {
+		return err
+	}
+	set := map[string]string{}
+	var remove []string
+	for key, value := range patch.Annotations {
+		if value == nil {
+			remove = append(remove, key)
+		} else {
+			set[key] = *value
+		}
+	}
+
+	unlock, err := h.lockProfile(r, serverName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	target := &profile.Annotations
+	var client *core.ClientProfile
+	if clientName != "" {
+		if client, err = core.FindClient(profile, clientName); err != nil {
+			return err
+		}
+		target = &client.Annotations
+	}
+	if *target, err = core.UpdateAnnotations(*target, set, remove); err != nil {
+		return badRequest(err)
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		return err
+	}
+	if client != nil {
+		writeAPIJSON(w, http.StatusOK, newClientView(profile, *client))
+	} else {
+		writeAPIJSON(w, http.StatusOK, newServerView(profile))
+	}
+	return nil
+}
+
+// reportPlatform records the device details a client's agent checks in with.
+func (h *apiHandler) reportPlatform(w http.ResponseWriter, r *http.Request, serverName, clientName string) error {
+	if h.clientCerts && presentedAgent(r) != agentName(serverName, clientName) {
+		return forbidden(fmt.Errorf("only the agent certificate %s may report this client's platform", agentName(serverName, clientName)))
+	}
+	var report core.ClientPlatform
+	if err := decodeAPIJSON(w, r, &report); err != nil {
+		return err
+	}
+
+	unlock, err := h.lockProfile(r, serverName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	client, err := core.FindClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	if err := core.SetClientPlatform(client, report, time.Now()); err != nil {
+		return badRequest(err)
+	}
+	// The report may move the client across the server's minimum app version.
+	if err := applyVersionPolicy(profile); err != nil {
+		return err
+	}
+	writeAPIJSON(w, http.StatusOK, newClientView(profile, *client))
+	return nil
+}
+
+// deleteClient removes a client, syncing the live peer for external interfaces.
+func (h *apiHandler) deleteClient(w http.ResponseWriter, r *http.Request, serverName, clientName string) error {
+	unlock, err := h.lockProfile(r, serverName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	removed, err := core.RemoveClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		return err
+	}
+	if runtimePath, err := core.ClientRuntimeConfigPath(serverName, clientName); err == nil {
+		_ = os.Remove(runtimePath)
+	}
+	if err := syncLivePeer(r.Context(), profile, func(ctx context.Context, iface string) error {
+		return core.RemoveLivePeerContext(ctx, iface, removed.PublicKey)
+	}); err != nil {
+		return fmt.Errorf("client %s was deleted but not removed from %s: %w", clientName, profile.ExternalInterface, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// syncLivePeer runs apply on the profile's external interface when it is up,
+// so the running peers match the saved profile. A check cut off by the
+// request deadline is reported rather than taken for a down interface.
+func syncLivePeer(ctx context.Context, profile *core.ServerProfile, apply func(context.Context, string) error) error {
+	if profile.ExternalInterface == "" {
+		return nil
+	}
+	if !core.InterfaceIsUpContext(ctx, profile.ExternalInterface) {
+		return ctx.Err()
+	}
+	return apply(ctx, profile.ExternalInterface)
+}
+
+// exportServer renders the server configuration as text.
+func (h *apiHandler) exportServer(w http.ResponseWriter, r *http.Request, serverName string) error {
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	var config string
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "wg-quick":
+		config, err = core.BuildServerConfig(profile)
+	case "wg-syncconf":
+		config, err = core.BuildServerSyncConfig(profile)
+	default:
+		return badRequest(fmt.Errorf("unsupported format %q (want wg-quick or wg-syncconf)", format))
+	}
+	if err != nil {
+		return badRequest(err)
+	}
+	writeAPIText(w, config)
+	return nil
+}
+
+// exportClient renders a client configuration, honouring ?target= and ?kill_switch=.
+func (h *apiHandler) exportClient(w http.ResponseWriter, r *http.Request, serverName, clientName string) error {
+	profile, err := core.LoadServerProfile(serverName)
+	if err != nil {
+		return err
+	}
+	client, err := core.FindClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	if mode := query.Get("mode"); mode != "" {
+		if err := core.ApplyClientMode(profile, client, mode, query["route"]); err != nil {
+			return badRequest(err)
+		}
+	}
+	options := core.ClientRenderOptions{Target: query.Get("target"), KillSwitch: query.Get("kill_switch") == "true"}
+	if options.Target == "" {
+		options.Target = core.TargetLinux
+	}
+	config, err := core.BuildClientConfigFor(profile, *client, options)
+	if err != nil {
+		return badRequest(err)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", core.ClientConfigFileName(serverName, clientName, options.Target)))
+	writeAPIText(w, config)
+	return nil
+}
+
+// serverStatus reports the live peer state of a server.
+func (h *apiHandler) serverStatus(w http.ResponseWriter, r *http.Request, serverName string) error {
+	view, err := collectServerStatus(r.Context(), serverName, false)
+	if err != nil {
+		return err
+	}
+	writeAPIJSON(w, http.StatusOK, view)
+	return nil
+}
+
+// allowMethods rejects requests whose method is not listed.
+func allowMethods(r *http.Request, methods ...string) error {
+	for _, method := range methods {
+		if r.Method == method {
+			return nil
+		}
+	}
+	return methodNotAllowed(methods...)
+}
+
+// methodNotAllowed builds a 405 error naming the accepted methods.
+func methodNotAllowed(methods ...string) error {
+	return &httpError{status: http.StatusMethodNotAllowed, err: fmt.Errorf("method not allowed (want %s)", strings.Join(methods, ", "))}
+}
+
+// decodeAPIJSON reads a size-limited JSON body, rejecting unknown fields.
+func decodeAPIJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	// Browsers send text/plain and form bodies cross-site without a preflight,
+	// so only application/json is accepted.
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return &httpError{status: http.StatusUnsupportedMediaType, err: fmt.Errorf("request body must be application/json")}
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest(fmt.Errorf("invalid request body: %w", err))
+	}
+	return nil
+}
+
+// writeAPIError maps core errors onto HTTP status codes.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var httpErr *httpError
+	switch {
+	case errors.Is(err, core.ErrProfileNotFound), errors.Is(err, core.ErrClientNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, core.ErrInvalidName):
+		status = http.StatusBadRequest
+	case errors.Is(err, core.ErrDownloadTokenInvalid), errors.Is(err, core.ErrDownloadTokenExpired):
+		status = http.StatusForbidden
+	case errors.Is(err, utils.ErrLocked):
+		w.Header().Set("Retry-After", "1")
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		// Checked before httpError, which may wrap a timed-out command.
+		err = fmt.Errorf("request did not finish in time: %w", err)
+		status = http.StatusGatewayTimeout
+	case errors.As(err, &httpErr):
+		status = httpErr.status
+	}
+	writeAPIJSON(w, status, apiError{Error: err.Error()})
+}
+
+// writeAPIJSON writes v as an indented JSON response.
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+// writeAPIText writes a configuration file body.
+func writeAPIText(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(body))
+}
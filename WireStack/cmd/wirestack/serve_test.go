@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wirestack/internal/core"
+)
+
+func TestAPIConcurrentMutationsKeepEveryChange(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	// Without wg on PATH keys are generated in-process and no interface is up.
+	t.Setenv("PATH", t.TempDir())
+	core.SetStore(core.FileStore{})
+
+	profile, err := core.NewServerProfile(core.ServerOptions{Name: "lab", Endpoint: "203.0.113.1:51820", Subnet: "10.20.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	if _, err := core.AddClient(profile, core.ClientOptions{Name: "seed"}); err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+
+	server := httptest.NewServer(newAPIHandler("secret", newEventBroker(time.Second)))
+	defer server.Close()
+
+	const clients = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*clients)
+	send := func(method, path, body string) {
+		defer wg.Done()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			errs <- err
+			return
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			errs <- err
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			errs <- fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+	}
+	for idx := 0; idx < clients; idx++ {
+		wg.Add(2)
+		go send(http.MethodPost, "/api/v1/servers/lab/clients", fmt.Sprintf(`{"name":"client-%d"}`, idx))
+		go send(http.MethodPatch, "/api/v1/servers/lab/clients/seed", fmt.Sprintf(`{"annotations":{"k%d":"v"}}`, idx))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	profile, err = core.LoadServerProfile("lab")
+	if err != nil {
+		t.Fatalf("LoadServerProfile: %v", err)
+	}
+	if len(profile.Clients) != clients+1 {
+		t.Fatalf("expected %d clients, got %d", clients+1, len(profile.Clients))
+	}
+	seed, err := core.FindClient(profile, "seed")
+	if err != nil {
+		t.Fatalf("FindClient: %v", err)
+	}
+	if len(seed.Annotations) != clients {
+		t.Fatalf("expected %d annotations on seed, got %v", clients, seed.Annotations)
+	}
+	addresses := map[string]bool{}
+	for _, client := range profile.Clients {
+		if addresses[client.Address] {
+			t.Fatalf("address %s assigned twice", client.Address)
+		}
+		addresses[client.Address] = true
+	}
+}
+
+func TestAPIRequestTimeoutStopsCommands(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if runtime.GOOS == "windows" || err != nil {
+		t.Skip("fake wg needs sh and sleep")
+	}
+	t.Setenv("HOME", t.TempDir())
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	core.SetStore(core.FileStore{})
+
+	profile, err := core.NewServerProfile(core.ServerOptions{Name: "lab", Endpoint: "203.0.113.1:51820", Subnet: "10.20.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	// A wg that hangs, as it can when the kernel module is wedged.
+	if err := os.WriteFile(filepath.Join(bin, "wg"), []byte("#!/bin/sh\nexec "+sleep+" 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := newAPIHandler("secret", newEventBroker(time.Second))
+	handler.requestTimeout.Store(int64(200 * time.Millisecond))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/servers/lab/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET status: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %s", resp.Status)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("request took %s despite the timeout", elapsed)
+	}
+}
+
+func TestAPIRejectsTraversalNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", t.TempDir())
+	core.SetStore(core.FileStore{})
+
+	profile, err := core.NewServerProfile(core.ServerOptions{Name: "lab", Endpoint: "203.0.113.1:51820"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	server := httptest.NewServer(newAPIHandler("secret", newEventBroker(time.Second)))
+	defer server.Close()
+
+	for _, tc := range []struct{ path, body string }{
+		{"/api/v1/servers", `{"name":"../../escaped","endpoint":"203.0.113.1:51820"}`},
+		{"/api/v1/servers", `{"name":"..","endpoint":"203.0.113.1:51820"}`},
+		{"/api/v1/servers/lab/clients", `{"name":"../escaped"}`},
+	} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s %s: expected 400, got %s", tc.path, tc.body, resp.Status)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, "escaped.json")); !os.IsNotExist(err) {
+		t.Fatalf("a profile was written outside the store: %v", err)
+	}
+}
+
+func TestAPIRejectsBodiesThatAreNotJSON(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", t.TempDir())
+	core.SetStore(core.FileStore{})
+	server := httptest.NewServer(newAPIHandler("", newEventBroker(time.Second)))
+	defer server.Close()
+
+	// A cross-site form or text/plain POST must not create a server.
+	for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/servers", strings.NewReader(`{"name":"lab","endpoint":"203.0.113.1:51820"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("Content-Type %q: expected 415, got %s", contentType, resp.Status)
+		}
+	}
+	if _, err := core.LoadServerProfile("lab"); err == nil {
+		t.Fatalf("a server was created from a body that is not JSON")
+	}
+}
+
+func TestAPIPlatformReportsNeedTheClientsAgentCertificate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	core.SetStore(core.FileStore{})
+
+	profile, err := core.NewServerProfile(core.ServerOptions{Name: "lab", Endpoint: "203.0.113.1:51820"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := core.AddClient(profile, core.ClientOptions{Name: name}); err != nil {
+			t.Fatalf("AddClient: %v", err)
+		}
+	}
+	if err := core.SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	handler := newAPIHandler("secret", newEventBroker(time.Second))
+	handler.clientCerts = true
+
+	send := func(handler *apiHandler, method, path, agent, token string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"os":"android 14"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		cert := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: agent, OrganizationalUnit: []string{core.CertAgent}},
+		}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	for _, tc := range []struct {
+		method, path, agent, token string
+		want                       int
+	}{
+		// The agent checks in its own client without the admin token.
+		{http.MethodPut, "/api/v1/servers/lab/clients/alice/platform", "lab/alice", "", http.StatusOK},
+		{http.MethodPut, "/api/v1/servers/lab/clients/bob/platform", "lab/alice", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/servers", "lab/alice", "", http.StatusUnauthorized},
+		// The admin token does not let another certificate report for bob.
+		{http.MethodPut, "/api/v1/servers/lab/clients/bob/platform", "lab/alice", "secret", http.StatusForbidden},
+		{http.MethodPut, "/api/v1/servers/lab/clients/bob/platform", "lab/bob", "secret", http.StatusOK},
+	} {
+		if got := send(handler, tc.method, tc.path, tc.agent, tc.token); got != tc.want {
+			t.Errorf("%s %s as %s: got %d, want %d", tc.method, tc.path, tc.agent, got, tc.want)
+		}
+	}
+
+	// Without a token, a client's agent certificate still only checks in.
+	tokenless := newAPIHandler("", newEventBroker(time.Second))
+	tokenless.clientCerts = true
+	for _, tc := range []struct {
+		method, path, agent string
+		want                int
+	}{
+		{http.MethodGet, "/api/v1/servers", "lab/alice", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/servers/lab/clients/bob/config", "lab/alice", http.StatusUnauthorized},
+		{http.MethodPut, "/api/v1/servers/lab/clients/alice/platform", "lab/alice", http.StatusOK},
+		{http.MethodGet, "/api/v1/servers", "laptop", http.StatusOK},
+	} {
+		if got := send(tokenless, tc.method, tc.path, tc.agent, ""); got != tc.want {
+			t.Errorf("without a token, %s %s as %s: got %d, want %d", tc.method, tc.path, tc.agent, got, tc.want)
+		}
+	}
+}
+
+func TestNotifierSendsCriticalEventsAndDigests(t *testing.T) {
+	received := make(chan core.Notification, 4)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification core.Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("decode notification: %v", err)
+		}
+		received <- notification
+	}))
+	defer webhook.Close()
+
+	broker := newEventBroker(time.Second)
+	n := startNotifier(broker, &core.NotifySettings{Webhook: webhook.URL, Digest: "1h"})
+	now := time.Now().UTC()
+	broker.publish(core.Event{Type: core.EventClientAdded, Server: "lab", Client: "alice", Time: now})
+	broker.publish(core.Event{Type: core.EventPeerOffline, Server: "lab", Client: "bob", Time: now})
+	broker.publish(core.Event{Type: core.EventClientChanged, Server: "lab", Client: "bob", Time: now})
+
+	select {
+	case alert := <-received:
+		if alert.Kind != core.NotificationAlert || len(alert.Events) != 1 || alert.Events[0].Type != core.EventPeerOffline {
+			t.Fatalf("expected the peer_offline alert first, got %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an immediate alert")
+	}
+
+	// A reload sends what was collected under the previous settings.
+	n.setSettings(nil)
+	select {
+	case digest := <-received:
+		if digest.Kind != core.NotificationDigest || len(digest.Events) != 2 {
+			t.Fatalf("expected a digest of two events, got %+v", digest)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the digest on reload")
+	}
+
+	broker.publish(core.Event{Type: core.EventPeerOffline, Server: "lab", Client: "bob", Time: now})
+	n.stop()
+	if len(received) != 0 {
+		t.Fatalf("expected nothing sent without settings, got %+v", <-received)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// statsSampleInterval is how far apart the two samples of a one-shot stats run are.
+const statsSampleInterval = time.Second
+
+// statsCommand shows per-client transfer rates on a running interface.
+func statsCommand() *cobra.Command {
+	var watch time.Duration
+
+	cmd := &cobra.Command{
+		Use:               "stats <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Show per-client receive and transmit rates",
+		Long: `Show each client's receive and transmit rate, computed from successive
+"wg show" samples, busiest first. RX is traffic the server received from the
+client and TX is traffic it sent to the client.
+
+Without --watch the rates cover one second. --watch 2s refreshes every two
+seconds until interrupted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if watch < 0 {
+				return fmt.Errorf("--watch must be positive")
+			}
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			iface := core.InterfaceName(profile)
+
+			interval := watch
+			if interval == 0 {
+				interval = statsSampleInterval
+			}
+			var previous *core.InterfaceStatus
+			var previousAt time.Time
+			for {
+				view := statsView{Server: profile.Name, Interface: iface, Interval: interval.String(), Peers: []peerStatsView{}}
+				if core.InterfaceIsUp(iface) {
+					status, err := core.ReadInterfaceStatus(iface)
+					if err != nil {
+						return err
+					}
+					now := time.Now()
+					view.Up = true
+					if previous != nil {
+						for _, peer := range core.ComputeTraffic(profile, previous, status, now.Sub(previousAt)) {
+							view.Peers = append(view.Peers, peerStatsView{
+								Client:    peer.Client,
+								PublicKey: peer.PublicKey,
+								RxBytes:   peer.RxBytes,
+								TxBytes:   peer.TxBytes,
+								RxRate:    peer.RxRate,
+								TxRate:    peer.TxRate,
+							})
+						}
+					}
+					first := previous == nil
+					previous, previousAt = status, now
+					if first {
+						// Rates need a second sample.
+						time.Sleep(interval)
+						continue
+					}
+				} else {
+					previous = nil
+				}
+
+				if watch > 0 && !structuredOutput() {
+					fmt.Print("\033[H\033[2J")
+				}
+				if structuredOutput() {
+					if err := printStructured(view); err != nil {
+						return err
+					}
+				} else if err := printStats(view); err != nil {
+					return err
+				}
+				if watch == 0 {
+					return nil
+				}
+				time.Sleep(interval)
+			}
+		},
+	}
+
+	cmd.Flags().DurationVar(&watch, "watch", 0, "Refresh at this interval (e.g. 2s) until interrupted")
+	return cmd
+}
+
+// printStats renders per-client rates as a table.
+func printStats(view statsView) error {
+	if !view.Up {
+		fmt.Printf("Server: %s (interface %s down)\n", view.Server, view.Interface)
+		return nil
+	}
+
+	fmt.Printf("Server: %s (rates over %s, sampled %s)\n", view.Server, view.Interval, time.Now().Format("15:04:05"))
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "CLIENT\tRX RATE\tTX RATE\tRX TOTAL\tTX TOTAL")
+	var rx, tx float64
+	for _, peer := range view.Peers {
+		clientName := peer.Client
+		if clientName == "" {
+			clientName = "(unknown " + shortKey(peer.PublicKey) + ")"
+		}
+		rx += peer.RxRate
+		tx += peer.TxRate
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", clientName, formatByteRate(peer.RxRate), formatByteRate(peer.TxRate), utils.FormatBytes(peer.RxBytes), utils.FormatBytes(peer.TxBytes))
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Total: %s received, %s sent\n", formatByteRate(rx), formatByteRate(tx))
+	return nil
+}
+
+// formatByteRate renders bytes per second with binary units.
+func formatByteRate(bytesPerSecond float64) string {
+	return utils.FormatBytes(int64(bytesPerSecond)) + "/s"
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+	"wirestack/internal/utils"
+)
+
+// statusCommand prints runtime peer state for one or all servers.
+func statusCommand() *cobra.Command {
+	var probe bool
+
+	cmd := &cobra.Command{
+		Use:               "status [server]",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Show live peer status from wg show",
+		Args:              cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := args
+			if len(names) == 0 {
+				var err error
+				names, err = core.ListServerProfiles()
+				if err != nil {
+					return err
+				}
+				if len(names) == 0 && !structuredOutput() {
+					fmt.Println("no servers found")
+					return nil
+				}
+			}
+
+			views := make([]statusView, 0, len(names))
+			for _, name := range names {
+				view, err := collectServerStatus(cmd.Context(), name, probe)
+				if err != nil {
+					return err
+				}
+				views = append(views, view)
+			}
+			if structuredOutput() {
+				return printStructured(views)
+			}
+			for idx, view := range views {
+				if idx > 0 {
+					fmt.Println()
+				}
+				if err := printServerStatus(view); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&probe, "probe", false, "Ping each connected client through the tunnel to add RTT and loss to its quality score")
+	return cmd
+}
+
+// collectServerStatus gathers the runtime state for a single server and
+// records a connection quality sample for each client. With probe, clients
+// with a recent handshake are pinged first.
+func collectServerStatus(ctx context.Context, name string, probe bool) (statusView, error) {
+	profile, err := core.LoadServerProfile(name)
+	if err != nil {
+		return statusView{}, err
+	}
+	view := statusView{Server: profile.Name, Interface: core.InterfaceName(profile), Peers: []peerView{}}
+	view.Warnings = outdatedWarnings(profile)
+	if !core.InterfaceIsUpContext(ctx, view.Interface) {
+		// A cancelled check says nothing about the interface.
+		return view, ctx.Err()
+	}
+	status, err := core.ReadInterfaceStatusContext(ctx, view.Interface)
+	if err != nil {
+		return statusView{}, err
+	}
+	view.Up = true
+	view.ListenPort = status.ListenPort
+
+	now := time.Now()
+	probes := map[string]core.ProbeResult{}
+	if probe {
+		probes = probeClients(profile, status, now)
+	}
+	scores, err := core.RecordQuality(profile, status, probes, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: quality history: %v\n", err)
+	}
+	for _, entry := range core.MatchClients(profile, status) {
+		peer := peerView{
+			Client:    entry.ClientName,
+			PublicKey: entry.Peer.PublicKey,
+			Endpoint:  entry.Peer.Endpoint,
+			RxBytes:   entry.Peer.TransferRx,
+			TxBytes:   entry.Peer.TransferTx,
+		}
+		if !entry.Peer.LatestHandshake.IsZero() {
+			handshake := entry.Peer.LatestHandshake
+			peer.LatestHandshake = &handshake
+		}
+		if score, ok := scores[entry.ClientName]; ok && entry.ClientName != "" {
+			peer.Quality = score.Level
+			if score.Level != core.QualityUnknown {
+				value := score.Score
+				peer.QualityScore = &value
+			}
+		}
+		view.Peers = append(view.Peers, peer)
+	}
+	return view, nil
+}
+
+// probeClients pings the clients whose handshake is recent enough for them to
+// be reachable. Failed probes are reported as warnings and skipped.
+func probeClients(profile *core.ServerProfile, status *core.InterfaceStatus, now time.Time) map[string]core.ProbeResult {
+	probes := map[string]core.ProbeResult{}
+	for _, entry := range core.MatchClients(profile, status) {
+		if entry.ClientName == "" || entry.Peer.LatestHandshake.IsZero() || now.Sub(entry.Peer.LatestHandshake) > 3*time.Minute {
+			continue
+		}
+		client, err := core.FindClient(profile, entry.ClientName)
+		if err != nil {
+			continue
+		}
+		result, err := core.ProbeClient(*client)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: probe %s: %v\n", entry.ClientName, err)
+			continue
+		}
+		probes[entry.ClientName] = result
+	}
+	return probes
+}
+
+// printServerStatus renders the status table for a single server.
+func printServerStatus(view statusView) error {
+	if !view.Up {
+		fmt.Printf("Server: %s (interface %s down)\n", view.Server, view.Interface)
+		printStatusWarnings(view)
+		return nil
+	}
+
+	fmt.Printf("Server: %s (interface %s up, port %d)\n", view.Server, view.Interface, view.ListenPort)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "CLIENT\tENDPOINT\tLATEST HANDSHAKE\tRX\tTX\tQUALITY")
+	for _, peer := range view.Peers {
+		clientName := peer.Client
+		if clientName == "" {
+			clientName = "(unknown " + shortKey(peer.PublicKey) + ")"
+		}
+		endpoint := peer.Endpoint
+		if endpoint == "" {
+			endpoint = "-"
+		}
+		handshake := time.Time{}
+		if peer.LatestHandshake != nil {
+			handshake = *peer.LatestHandshake
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			clientName,
+			endpoint,
+			formatHandshake(handshake),
+			utils.FormatBytes(peer.RxBytes),
+			utils.FormatBytes(peer.TxBytes),
+			formatQuality(peer),
+		)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	printStatusWarnings(view)
+	return nil
+}
+
+// printStatusWarnings lists the server's version policy warnings.
+func printStatusWarnings(view statusView) {
+	for _, warning := range view.Warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
+}
+
+// formatQuality renders a peer's quality level and score, e.g. "good (92)".
+func formatQuality(peer peerView) string {
+	switch {
+	case peer.Quality == "":
+		return "-"
+	case peer.QualityScore == nil:
+		return peer.Quality
+	default:
+		return fmt.Sprintf("%s (%d)", peer.Quality, *peer.QualityScore)
+	}
+}
+
+// formatHandshake renders a handshake time relative to now.
+func formatHandshake(at time.Time) string {
+	if at.IsZero() {
+		return "never"
+	}
+	return time.Since(at).Truncate(time.Second).String() + " ago"
+}
+
+// shortKey abbreviates a public key for display.
+func shortKey(key string) string {
+	if len(key) <= 8 {
+		return key
+	}
+	return key[:8] + "…"
+}
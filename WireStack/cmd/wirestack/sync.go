@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// peerChangeView is the machine-readable form of a core.PeerChange.
+type peerChangeView struct {
+	Action     string   `json:"action" yaml:"action"`
+	Client     string   `json:"client,omitempty" yaml:"client,omitempty"`
+	PublicKey  string   `json:"public_key" yaml:"public_key"`
+	AllowedIPs []string `json:"allowed_ips,omitempty" yaml:"allowed_ips,omitempty"`
+	Current    []string `json:"current,omitempty" yaml:"current,omitempty"`
+}
+
+// syncCommand brings a running interface's peers in line with the profile.
+func syncCommand() *cobra.Command {
+	var check bool
+
+	cmd := &cobra.Command{
+		Use:               "sync <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Apply only the peer changes a running interface is missing",
+		Long: `Compare the server's profile with its running interface (wg show dump) and
+apply only the differences with wg set: clients missing from the interface
+are added, clients whose AllowedIPs changed are updated, and peers the
+profile no longer renders, such as deleted or disabled clients, are removed.
+Unlike down and up, established sessions of unchanged peers are untouched.
+On an external interface only peers using a key of one of the profile's
+clients are removed, since other tools may own the rest.
+
+"+" marks a peer to add, "-" one to remove, and "~" one to update. --check
+only prints the differences and exits non-zero when there are any.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			iface := core.InterfaceName(profile)
+			status, err := core.ReadInterfaceStatus(iface)
+			if err != nil {
+				return fmt.Errorf("interface %s of server %s is not running; bring it up with wirestack up: %w", iface, profile.Name, err)
+			}
+			changes := core.DiffLivePeers(profile, status)
+
+			if structuredOutput() {
+				views := make([]peerChangeView, 0, len(changes))
+				for _, change := range changes {
+					views = append(views, peerChangeView(change))
+				}
+				if err := printStructured(views); err != nil {
+					return err
+				}
+			} else {
+				printPeerChanges(changes)
+			}
+			if len(changes) == 0 {
+				if !structuredOutput() {
+					fmt.Printf("Interface %s already matches server %s\n", iface, profile.Name)
+				}
+				return nil
+			}
+			if check {
+				return fmt.Errorf("interface %s differs from server %s in %d peers", iface, profile.Name, len(changes))
+			}
+
+			if err := core.ApplyPeerChanges(iface, changes); err != nil {
+				return err
+			}
+			// Keep the runtime config in step for the next wg-quick up or reload.
+			if err := rerenderRuntimeConfigs(profile, ""); err != nil {
+				return err
+			}
+			if !structuredOutput() {
+				fmt.Printf("Applied %d peer changes to %s\n", len(changes), iface)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&check, "check", false, "Only show the differences; exit non-zero when there are any")
+	return cmd
+}
+
+// printPeerChanges lists peer changes for humans.
+func printPeerChanges(changes []core.PeerChange) {
+	for _, change := range changes {
+		name := change.Client
+		if name == "" {
+			name = "(unknown)"
+		}
+		switch change.Action {
+		case core.PeerAdd:
+			fmt.Printf("+ %s %s allowed-ips %s\n", name, change.PublicKey, strings.Join(change.AllowedIPs, ","))
+		case core.PeerRemove:
+			fmt.Printf("- %s %s\n", name, change.PublicKey)
+		case core.PeerUpdate:
+			fmt.Printf("~ %s %s allowed-ips %s -> %s\n", name, change.PublicKey, strings.Join(change.Current, ","), strings.Join(change.AllowedIPs, ","))
+		}
+	}
+}
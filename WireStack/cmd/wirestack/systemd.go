@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// systemdCommand groups the commands that manage systemd units for servers.
+func systemdCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "systemd",
+		Short: "Start server interfaces at boot with systemd",
+	}
+	cmd.AddCommand(systemdInstallCommand(), systemdStatusCommand(), systemdUninstallCommand())
+	return cmd
+}
+
+// systemdInstallCommand writes and enables a unit for a server.
+func systemdInstallCommand() *cobra.Command {
+	var options core.SystemdOptions
+	var noEnable bool
+
+	cmd := &cobra.Command{
+		Use:               "install <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Install and enable a systemd unit that brings the interface up at boot",
+		Long: `Install a systemd unit that brings the server's interface up at boot.
+
+--mode wg-quick (the default) writes a drop-in for the stock wg-quick@<iface>
+unit that points it at the rendered runtime config, so "systemctl reload"
+applies peer changes with wg syncconf. --mode service writes a dedicated
+wirestack-<server>.service that runs "wirestack up" and "wirestack down",
+which also runs lint plugins and honours --backend and --store.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			existing, err := core.InstalledSystemdUnit(profile, options.UnitDir)
+			if err != nil {
+				return err
+			}
+			if existing != nil && existing.Mode != options.Mode {
+				return fmt.Errorf("server %s already has %s installed; uninstall it first", profile.Name, existing.Name)
+			}
+
+			if options.Mode == core.SystemdService {
+				if options.Executable, err = os.Executable(); err != nil {
+					return err
+				}
+				if options.Home, err = os.UserHomeDir(); err != nil {
+					return err
+				}
+				if backendName != core.BackendWGQuick {
+					options.Args = append(options.Args, "--backend", backendName)
+				}
+				if storeName != "" {
+					options.Args = append(options.Args, "--store", storeName)
+				}
+			}
+			unit, err := core.BuildSystemdUnit(profile, options)
+			if err != nil {
+				return err
+			}
+			if unit.Mode == core.SystemdWGQuick {
+				// The drop-in points at the runtime config, so it has to exist before boot.
+				if _, err := core.WriteServerConfig(profile); err != nil {
+					return err
+				}
+			}
+			if err := core.InstallSystemdUnit(unit, !noEnable); err != nil {
+				return err
+			}
+			if err := core.RecordArtifacts(profile.Name, core.SystemdArtifact(unit)); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+
+			fmt.Printf("Installed %s (%s)\n", unit.Name, unit.Path)
+			if noEnable {
+				fmt.Printf("Enable it with: systemctl enable --now %s\n", unit.Name)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&options.Mode, "mode", core.SystemdWGQuick, "Unit to install: wg-quick (drop-in for wg-quick@) or service (dedicated wirestack unit)")
+	cmd.Flags().StringVar(&options.UnitDir, "unit-dir", core.DefaultSystemdUnitDir, "Directory units are written to")
+	cmd.Flags().BoolVar(&noEnable, "no-enable", false, "Write the unit without enabling or starting it")
+	return cmd
+}
+
+// systemdStatusCommand reports the unit installed for a server.
+func systemdStatusCommand() *cobra.Command {
+	var unitDir string
+
+	cmd := &cobra.Command{
+		Use:               "status <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Show the systemd unit installed for a server",
+		Args:              cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			unit, err := core.InstalledSystemdUnit(profile, unitDir)
+			if err != nil {
+				return err
+			}
+			if unit == nil {
+				return fmt.Errorf("no systemd unit installed for server %s in %s", profile.Name, unitDir)
+			}
+			state, err := core.QuerySystemdUnit(unit.Name)
+			if err != nil {
+				return err
+			}
+
+			view := systemdView{Server: profile.Name, Unit: unit.Name, Mode: unit.Mode, Path: unit.Path, Enabled: state.Enabled, Active: state.Active}
+			if structuredOutput() {
+				return printStructured(view)
+			}
+			fmt.Printf("Unit: %s\n", view.Unit)
+			fmt.Printf("Mode: %s\n", view.Mode)
+			fmt.Printf("File: %s\n", view.Path)
+			fmt.Printf("Enabled: %s\n", view.Enabled)
+			fmt.Printf("Active: %s\n", view.Active)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&unitDir, "unit-dir", core.DefaultSystemdUnitDir, "Directory units are installed in")
+	return cmd
+}
+
+// systemdUninstallCommand disables and removes the unit installed for a server.
+func systemdUninstallCommand() *cobra.Command {
+	var unitDir string
+
+	cmd := &cobra.Command{
+		Use:               "uninstall <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Stop, disable, and remove the systemd unit installed for a server",
+		Args:              cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			unit, err := core.InstalledSystemdUnit(profile, unitDir)
+			if err != nil {
+				return err
+			}
+			if unit == nil {
+				return fmt.Errorf("no systemd unit installed for server %s in %s", profile.Name, unitDir)
+			}
+			if err := core.UninstallSystemdUnit(unit); err != nil {
+				return err
+			}
+			if err := core.ForgetArtifacts(profile.Name, core.ArtifactUnits, unit.Name); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+			fmt.Printf("Removed %s (%s)\n", unit.Name, unit.Path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&unitDir, "unit-dir", core.DefaultSystemdUnitDir, "Directory units are installed in")
+	return cmd
+}
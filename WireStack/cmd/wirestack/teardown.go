@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// teardownCommand removes the system artifacts recorded for a server.
+func teardownCommand() *cobra.Command {
+	var what []string
+	var forget bool
+
+	cmd := &cobra.Command{
+		Use:               "teardown <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Remove NAT rules, systemd units, qdiscs, and router port mappings a server left behind",
+		Long: `Remove the system artifacts WireStack recorded for a server.
+
+up records the NAT rules, the root qdiscs its hooks install on other
+interfaces, and the port mapping it requests from the router, and systemd
+install records its unit. down and systemd uninstall drop what they remove,
+so teardown cleans up what is left after a crash, a failed down, or
+delete-server. --what limits it to some kinds: nat, units, tc, portmap.
+The server profile does not have to exist any more.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kinds, err := core.ParseArtifactKinds(what)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			result, err := core.Teardown(args[0], kinds, forget)
+			if err != nil {
+				return err
+			}
+
+			verb := "Removed"
+			if forget {
+				verb = "Forgot"
+			}
+			for _, artifact := range result.Removed {
+				fmt.Printf("%s %s %s\n", verb, artifact.Kind, artifact.Target)
+			}
+			for _, failure := range result.Failed {
+				fmt.Fprintf(os.Stderr, "failed: %s %s: %v\n", failure.Artifact.Kind, failure.Artifact.Target, failure.Err)
+			}
+			if len(result.Failed) > 0 {
+				return fmt.Errorf("%d artifacts were not removed; use --forget for ones already removed by hand", len(result.Failed))
+			}
+			if len(result.Removed) == 0 {
+				fmt.Printf("No artifacts recorded for server %s\n", args[0])
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&what, "what", nil, "Kinds of artifacts to remove: nat, units, tc, portmap (default all)")
+	cmd.Flags().BoolVar(&forget, "forget", false, "Drop the records without touching the host")
+	return cmd
+}
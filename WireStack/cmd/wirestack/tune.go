@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// tuneCommand recommends host settings for high-throughput WireGuard.
+func tuneCommand() *cobra.Command {
+	var iface string
+	var apply bool
+	var multiqueue bool
+
+	cmd := &cobra.Command{
+		Use:               "tune <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Recommend sysctl, NIC offload, and qdisc settings for throughput",
+		Long: `Inspect the socket buffer and backlog sysctls, the egress NIC's GRO
+offloads (ethtool -k), and its root qdisc (tc), and recommend changes that
+help high-throughput WireGuard. The egress interface is the server's --nat
+interface or the default route's device unless --interface is given.
+
+--multiqueue also checks how the NIC's queues and interrupts are spread over
+CPUs, for servers pushing multiple gigabits: RPS over every CPU when the NIC
+has fewer receive queues than CPUs, XPS pinning transmit queues to CPUs, and
+one CPU per queue interrupt. Benchmark before and after with
+"wirestack bench --save" and "wirestack bench --baseline".
+
+Nothing is changed on the host. --apply adds the recommended commands to the
+server's PostUp hooks, so they take effect on the next "wirestack up". Run
+it on the server host itself.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if apply {
+				unlock, err := core.LockServerProfile(args[0])
+				if err != nil {
+					return err
+				}
+				defer unlock()
+			}
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			inspection := core.InspectTuning(profile, iface)
+			recs := core.TuneRecommendations(inspection)
+			var queues *core.QueueInspection
+			if multiqueue {
+				if inspection.Interface == "" {
+					return fmt.Errorf("--multiqueue needs an egress interface; pass --interface")
+				}
+				queues = core.InspectQueues(inspection.Interface)
+				inspection.Notes = append(inspection.Notes, queues.Notes...)
+				recs = append(recs, core.QueueRecommendations(queues)...)
+			}
+
+			added := 0
+			if apply {
+				if added, err = core.AddTuneHooks(profile, recs); err != nil {
+					return err
+				}
+				if added > 0 {
+					if err := core.SaveServerProfile(profile); err != nil {
+						return err
+					}
+				}
+			}
+
+			if structuredOutput() {
+				return printStructured(newTuneView(inspection, queues, recs, added))
+			}
+			for _, note := range inspection.Notes {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", note)
+			}
+			if len(recs) == 0 {
+				fmt.Println("no tuning recommended")
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "SETTING\tCURRENT\tRECOMMENDED\tWHY")
+			for _, rec := range recs {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", rec.Setting, rec.Current, rec.Recommended, rec.Reason)
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			switch {
+			case !apply:
+				fmt.Printf("\nRun \"wirestack tune %s --apply\" to add these as PostUp hooks, or now:\n", profile.Name)
+				for _, rec := range recs {
+					fmt.Printf("  %s\n", rec.Command)
+				}
+			case added > 0:
+				fmt.Printf("\nAdded %d PostUp hooks to server %s; they apply on the next up\n", added, profile.Name)
+			default:
+				fmt.Printf("\nServer %s already has these PostUp hooks\n", profile.Name)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&iface, "interface", "", "Egress interface to inspect (default: the --nat interface, then the default route's)")
+	cmd.Flags().BoolVar(&apply, "apply", false, "Add the recommended commands to the server's PostUp hooks")
+	cmd.Flags().BoolVar(&multiqueue, "multiqueue", false, "Also recommend RPS, XPS, and IRQ affinity for the NIC's queues")
+	return cmd
+}
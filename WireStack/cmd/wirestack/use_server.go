@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// currentServerSkip names, per command, a flag that stands in for --server,
+// so the current server is not filled in when it is set. An empty name means
+// the command never uses the current server: its --server narrows a command
+// that otherwise covers every server.
+var currentServerSkip = map[string]string{
+	"expire-check": "",
+	"list-clients": "all",
+	"mtu-probe":    "host",
+}
+
+// useServerCommand sets or shows the server commands use without --server.
+func useServerCommand() *cobra.Command {
+	var clear bool
+
+	cmd := &cobra.Command{
+		Use:               "use-server [name]",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Set the server commands use when --server is omitted",
+		Long: `Set the server commands use when --server is omitted.
+
+The name is stored as current_server in ~/.wirestack/config.json. Commands
+that take --server use it unless the flag is given; commands that name the
+server as an argument, such as up and down, still need it. Without a name,
+use-server prints the current server.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if clear && len(args) > 0 {
+				return fmt.Errorf("--clear takes no server name")
+			}
+			cmd.SilenceUsage = true
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			switch {
+			case clear:
+				settings.CurrentServer = ""
+			case len(args) == 1:
+				// Only existing servers, so a typo is caught now.
+				if _, err := core.LoadServerProfile(args[0]); err != nil {
+					return err
+				}
+				settings.CurrentServer = args[0]
+			default:
+				if settings.CurrentServer == "" {
+					fmt.Println("No current server; commands need --server")
+				} else {
+					fmt.Println(settings.CurrentServer)
+				}
+				return nil
+			}
+			if err := core.SaveSettings(settings); err != nil {
+				return err
+			}
+			if clear {
+				fmt.Println("Current server cleared")
+			} else {
+				fmt.Printf("Using server %s\n", settings.CurrentServer)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&clear, "clear", false, "Forget the current server")
+	return cmd
+}
+
+// applyCurrentServer fills in --server from the current_server setting when
+// cmd takes the flag and it was not given.
+func applyCurrentServer(cmd *cobra.Command, settings *core.Settings) error {
+	flag := cmd.Flags().Lookup("server")
+	if settings.CurrentServer == "" || flag == nil || flag.Changed {
+		return nil
+	}
+	if skip, ok := currentServerSkip[cmd.Name()]; ok {
+		if skip == "" || cmd.Flags().Changed(skip) {
+			return nil
+		}
+	}
+	return cmd.Flags().Set("server", settings.CurrentServer)
+}
+
+// forgetCurrentServer clears the current_server setting if it names server.
+func forgetCurrentServer(server string) error {
+	settings, err := core.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if settings.CurrentServer != server {
+		return nil
+	}
+	settings.CurrentServer = ""
+	return core.SaveSettings(settings)
+}
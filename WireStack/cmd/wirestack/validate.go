@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// validateCommand checks stored profiles and reports problems for humans or CI.
+func validateCommand() *cobra.Command {
+	var all bool
+	var plugins []string
+	var noPlugins bool
+
+	cmd := &cobra.Command{
+		Use:               "validate [server]",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Check server profiles for misconfiguration",
+		Args:              cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return fmt.Errorf("pass either a server name or --all")
+			}
+
+			names := args
+			if all {
+				var err error
+				names, err = core.ListServerProfiles()
+				if err != nil {
+					return err
+				}
+			}
+			profiles := make([]*core.ServerProfile, 0, len(names))
+			for _, name := range names {
+				profile, err := core.LoadServerProfile(name)
+				if err != nil {
+					return err
+				}
+				profiles = append(profiles, profile)
+			}
+
+			findings := core.ValidateProfiles(profiles)
+			if !noPlugins {
+				settings, err := core.LoadSettings()
+				if err != nil {
+					return err
+				}
+				if enabled := append(settings.LintPlugins, plugins...); len(enabled) > 0 {
+					findings = append(findings, core.RunLintPlugins(enabled, profiles)...)
+				}
+			}
+			if err := writeFindings(outputFormat, findings); err != nil {
+				return err
+			}
+			if core.HasErrors(findings) {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("validation failed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Validate every stored server and check for conflicts between them")
+	cmd.Flags().StringArrayVar(&plugins, "plugin", nil, "Additional lint plugin executable to run (repeatable)")
+	cmd.Flags().BoolVar(&noPlugins, "no-plugins", false, "Skip registered and --plugin lint plugins")
+	return cmd
+}
+
+// writeFindings prints the validation report in the requested format.
+func writeFindings(format string, findings []core.Finding) error {
+	switch format {
+	case outputTable:
+		if len(findings) == 0 {
+			fmt.Println("no problems found")
+			return nil
+		}
+		for _, finding := range findings {
+			subject := finding.Server
+			if finding.Client != "" {
+				subject += "/" + finding.Client
+			}
+			fmt.Printf("%s: %s: %s [%s]\n", finding.Severity, subject, finding.Message, finding.Rule)
+		}
+		return nil
+	case outputJSON:
+		if findings == nil {
+			findings = []core.Finding{}
+		}
+		return writeJSON(findings)
+	case outputYAML:
+		return printStructured(findings)
+	case outputSARIF:
+		return writeJSON(sarifReport(findings))
+	default:
+		return fmt.Errorf("unsupported report format %q (want table, json, yaml, or sarif)", format)
+	}
+}
+
+// writeJSON encodes v as indented JSON on stdout.
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// sarifReport converts findings into a SARIF 2.1.0 log so CI systems can annotate them.
+func sarifReport(findings []core.Finding) map[string]any {
+	rules := make([]map[string]any, 0, len(core.ValidationRules))
+	for _, rule := range core.ValidationRules {
+		rules = append(rules, map[string]any{
+			"id":               rule.ID,
+			"shortDescription": map[string]string{"text": rule.Description},
+		})
+	}
+
+	results := make([]map[string]any, 0, len(findings))
+	for _, finding := range findings {
+		message := finding.Message
+		if finding.Client != "" {
+			message = fmt.Sprintf("client %s: %s", finding.Client, message)
+		}
+		result := map[string]any{
+			"ruleId":  finding.Rule,
+			"level":   finding.Severity,
+			"message": map[string]string{"text": message},
+		}
+		if path, err := core.ServerProfilePath(finding.Server); err == nil {
+			result["locations"] = []map[string]any{{
+				"physicalLocation": map[string]any{
+					"artifactLocation": map[string]string{"uri": "file://" + path},
+				},
+			}}
+		}
+		results = append(results, result)
+	}
+
+	return map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "wirestack",
+					"version":        version,
+					"informationUri": "https://github.com/MajdKZ1/WireStack",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// setVersionPolicyCommand sets the minimum app version a server's clients must report.
+func setVersionPolicyCommand() *cobra.Command {
+	var serverName string
+	var minAppVersion string
+	var action string
+
+	cmd := &cobra.Command{
+		Use:   "set-version-policy",
+		Short: "Require clients to report a minimum app version",
+		Long: `Require a server's clients to report at least --min-app-version when their
+agent checks in (PUT /api/v1/servers/<server>/clients/<client>/platform).
+
+With --action warn, outdated clients are listed as warnings by status and
+list-clients and flagged in API responses. With --action disable they are
+also disabled, and re-enabled automatically once they report a compliant
+version. Clients that never reported a version are not affected.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" || minAppVersion == "" {
+				return fmt.Errorf("both --server and --min-app-version are required")
+			}
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if err := core.SetVersionPolicy(profile, minAppVersion, action); err != nil {
+				return err
+			}
+			if err := applyVersionPolicy(profile); err != nil {
+				return err
+			}
+			fmt.Printf("Version policy saved on server %s: minimum app version %s (%s)\n", serverName, profile.VersionPolicy.MinAppVersion, profile.VersionPolicy.Action)
+			warnOutdatedClients(profile)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	cmd.Flags().StringVar(&minAppVersion, "min-app-version", "", "Lowest app version clients may report, e.g. 1.0.20231018")
+	cmd.Flags().StringVar(&action, "action", core.VersionPolicyWarn, "What happens to outdated clients: warn or disable")
+	return cmd
+}
+
+// deleteVersionPolicyCommand removes a server's version policy.
+func deleteVersionPolicyCommand() *cobra.Command {
+	var serverName string
+
+	cmd := &cobra.Command{
+		Use:   "delete-version-policy",
+		Short: "Remove the minimum app version and re-enable clients it disabled",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serverName == "" {
+				return fmt.Errorf("--server is required")
+			}
+			unlock, err := core.LockServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			profile, err := core.LoadServerProfile(serverName)
+			if err != nil {
+				return err
+			}
+			if profile.VersionPolicy == nil {
+				return fmt.Errorf("server %s has no version policy", serverName)
+			}
+			profile.VersionPolicy = nil
+			if err := applyVersionPolicy(profile); err != nil {
+				return err
+			}
+			fmt.Printf("Version policy removed from server %s\n", serverName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serverName, "server", "", "Server name")
+	return cmd
+}
+
+// applyVersionPolicy enforces the profile's version policy, saves it, and
+// syncs runtime configs and the running interface with the clients it
+// disabled or re-enabled.
+func applyVersionPolicy(profile *core.ServerProfile) error {
+	disabled, enabled := core.EnforceVersionPolicy(profile, time.Now())
+	if err := core.SaveServerProfile(profile); err != nil {
+		return err
+	}
+	if len(disabled) == 0 && len(enabled) == 0 {
+		return nil
+	}
+	if err := rerenderRuntimeConfigs(profile, ""); err != nil {
+		return err
+	}
+
+	iface := core.InterfaceName(profile)
+	live := core.InterfaceIsUp(iface)
+	for _, name := range disabled {
+		client, err := core.FindClient(profile, name)
+		if err != nil {
+			return err
+		}
+		if live {
+			if err := core.RemoveLivePeer(iface, client.PublicKey); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s/%s disabled: %s\n", profile.Name, name, core.OutdatedReason(profile, *client))
+	}
+	for _, name := range enabled {
+		client, err := core.FindClient(profile, name)
+		if err != nil {
+			return err
+		}
+		if live {
+			if err := core.ApplyLivePeer(iface, *client); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s/%s re-enabled\n", profile.Name, name)
+	}
+	return nil
+}
+
+// outdatedWarnings describes each client below the server's minimum app version.
+func outdatedWarnings(profile *core.ServerProfile) []string {
+	var warnings []string
+	for _, client := range profile.Clients {
+		reason := core.OutdatedReason(profile, client)
+		if reason == "" {
+			continue
+		}
+		warning := fmt.Sprintf("client %s/%s: %s", profile.Name, client.Name, reason)
+		if client.DisabledReason == core.DisabledOutdated {
+			warning += " (disabled until it upgrades)"
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings
+}
+
+// warnOutdatedClients prints stderr warnings for clients below the server's
+// minimum app version.
+func warnOutdatedClients(profile *core.ServerProfile) {
+	for _, warning := range outdatedWarnings(profile) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+}
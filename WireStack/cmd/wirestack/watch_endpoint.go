@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// watchEndpointCommand keeps a connected client's server endpoint current
+// when the server's host name moves to a new address.
+func watchEndpointCommand() *cobra.Command {
+	var clientName string
+	var iface string
+	var interval time.Duration
+	var once bool
+
+	cmd := &cobra.Command{
+		Use:               "watch-endpoint <server>",
+		ValidArgsFunction: completeServerArg,
+		Short:             "Re-resolve a server's endpoint host name and update the running peer",
+		Long: `WireGuard resolves a peer's endpoint host name only when the interface
+comes up, so a client connected to a server on a dynamic IP loses it when
+the address changes. watch-endpoint re-resolves the server's endpoint every
+--interval and, when the peer's current address no longer matches, points it
+at the new one with "wg set <iface> peer <key> endpoint <ip:port>".
+
+Run it on the client next to "wirestack connect". The interface is the one
+connect brings up for --client, or --interface for a config brought up some
+other way. It runs until interrupted; --once checks a single time.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if clientName == "" && iface == "" {
+				return fmt.Errorf("--client or --interface is required")
+			}
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			cmd.SilenceUsage = true
+			profile, err := core.LoadServerProfile(args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := core.EndpointHost(profile.Endpoint); err != nil {
+				return err
+			}
+			if iface == "" {
+				if _, err := core.FindClient(profile, clientName); err != nil {
+					return err
+				}
+				configPath, err := core.ClientRuntimeConfigPath(profile.Name, clientName)
+				if err != nil {
+					return err
+				}
+				iface = core.ConfigInterfaceName(configPath)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			check := func() error {
+				lookupCtx, cancel := context.WithTimeout(ctx, interval)
+				defer cancel()
+				update, err := core.RefreshPeerEndpoint(lookupCtx, iface, profile.ServerPublicKey, profile.Endpoint)
+				if err != nil {
+					return err
+				}
+				if update.Changed {
+					previous := update.Previous
+					if previous == "" {
+						previous = "(none)"
+					}
+					fmt.Printf("%s %s: endpoint %s changed from %s to %s\n", time.Now().Format(time.RFC3339), iface, profile.Endpoint, previous, update.Current)
+				}
+				return nil
+			}
+			if once {
+				return check()
+			}
+
+			fmt.Printf("Watching %s for interface %s every %s\n", profile.Endpoint, iface, interval)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				// A failed lookup or a missing interface is transient; keep watching.
+				if err := check(); err != nil && ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&clientName, "client", "", "Client whose connected interface to update")
+	cmd.Flags().StringVar(&iface, "interface", "", "WireGuard interface to update instead of the one connect uses for --client")
+	cmd.Flags().DurationVar(&interval, "interval", time.Minute, "How often to re-resolve the endpoint")
+	cmd.Flags().BoolVar(&once, "once", false, "Check once and exit instead of watching")
+	return cmd
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"wirestack/internal/core"
+)
+
+// publicIPAttemptView is the machine-readable form of a core.PublicIPAttempt.
+type publicIPAttemptView struct {
+	Provider  string `json:"provider" yaml:"provider"`
+	Address   string `json:"address,omitempty" yaml:"address,omitempty"`
+	Error     string `json:"error,omitempty" yaml:"error,omitempty"`
+	ElapsedMS int64  `json:"elapsed_ms" yaml:"elapsed_ms"`
+}
+
+// publicIPView is what whatismyip prints with --output.
+type publicIPView struct {
+	Address  string                `json:"address,omitempty" yaml:"address,omitempty"`
+	Attempts []publicIPAttemptView `json:"attempts" yaml:"attempts"`
+}
+
+// whatIsMyIPCommand shows what each public IP provider reports.
+func whatIsMyIPCommand() *cobra.Command {
+	var providers []string
+	var timeout time.Duration
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "whatismyip",
+		Short: "Detect this host's public address and show what each provider answered",
+		Long: `Ask the public IP providers used for an "auto" endpoint, in order, and show
+each answer, error, and how long it took. Providers come from the public_ip
+section of ~/.wirestack/config.json, or --provider (repeatable):
+
+  stun[:host:port]          STUN binding request (default stun.l.google.com:19302)
+  https://api.ipify.org     any http(s) URL answering with the address as text
+  upnp                      the router's external address over UPnP IGD
+  metadata[:aws|gcp|azure]  the cloud instance metadata service
+
+Private and carrier-grade NAT addresses count as failures, so the next
+provider is tried. --all asks every provider even after one succeeded.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			settings, err := core.LoadSettings()
+			if err != nil {
+				return err
+			}
+			if len(providers) == 0 {
+				providers = settings.PublicIP.ProviderList()
+			}
+			if !cmd.Flags().Changed("timeout") {
+				if timeout, err = settings.PublicIP.LookupTimeout(); err != nil {
+					return err
+				}
+			}
+			if timeout <= 0 {
+				return fmt.Errorf("--timeout must be positive")
+			}
+
+			address, attempts, detectErr := core.DetectPublicIP(context.Background(), providers, timeout, all)
+			if attempts == nil && detectErr != nil {
+				return detectErr
+			}
+			if structuredOutput() {
+				view := publicIPView{Address: address, Attempts: make([]publicIPAttemptView, 0, len(attempts))}
+				for _, attempt := range attempts {
+					entry := publicIPAttemptView{Provider: attempt.Provider, Address: attempt.Address, ElapsedMS: attempt.Elapsed.Milliseconds()}
+					if attempt.Err != nil {
+						entry.Error = attempt.Err.Error()
+					}
+					view.Attempts = append(view.Attempts, entry)
+				}
+				if err := printStructured(view); err != nil {
+					return err
+				}
+				return detectErr
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "PROVIDER\tRESULT\tTIME")
+			for _, attempt := range attempts {
+				result := attempt.Address
+				if attempt.Err != nil {
+					result = "error: " + attempt.Err.Error()
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\n", attempt.Provider, result, attempt.Elapsed.Round(time.Millisecond))
+			}
+			writer.Flush()
+			if detectErr != nil {
+				return detectErr
+			}
+			fmt.Printf("Public address: %s\n", address)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&providers, "provider", nil, "Provider to ask, in order (repeatable; replaces the configured list)")
+	cmd.Flags().DurationVar(&timeout, "timeout", core.DefaultIPLookupTimeout, "How long each provider may take")
+	cmd.Flags().BoolVar(&all, "all", false, "Ask every provider, not only until one succeeds")
+	return cmd
+}
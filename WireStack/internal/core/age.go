@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// ageRecipient matches age public keys: "age1" followed by bech32 data, or a
+// plugin recipient such as age1yubikey1... whose plugin name precedes the data.
+var ageRecipient = regexp.MustCompile(`^age1([a-z0-9-]+1)?[02-9ac-hj-np-z]+$`)
+
+// ageArmorHeader starts every ASCII-armored age file.
+const ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+
+// EncryptConfig encrypts a rendered config to age recipients with the age
+// CLI and returns it ASCII-armored, so it survives being pasted into chat or
+// email. Any one recipient's identity can decrypt it.
+func EncryptConfig(config string, recipients []string) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("at least one age recipient is required")
+	}
+	args := []string{"--encrypt", "--armor"}
+	for _, recipient := range recipients {
+		recipient = strings.TrimSpace(recipient)
+		if !ageRecipient.MatchString(recipient) {
+			return "", fmt.Errorf("invalid age recipient %q: expected a public key starting with age1", recipient)
+		}
+		args = append(args, "--recipient", recipient)
+	}
+	output, err := utils.RunCommandWithInput(config, "age", args...)
+	if err != nil {
+		return "", fmt.Errorf("age encrypt: %w", err)
+	}
+	if !strings.HasPrefix(output, ageArmorHeader) {
+		return "", fmt.Errorf("age encrypt: unexpected output")
+	}
+	return output + "\n", nil
+}
+
+// DecryptConfig decrypts an age-encrypted config file with the identity file
+// holding the matching private key.
+func DecryptConfig(path, identityPath string) (string, error) {
+	output, err := utils.RunCommand("age", "--decrypt", "--identity", identityPath, path)
+	if err != nil {
+		return "", fmt.Errorf("age decrypt %s: %w", path, err)
+	}
+	return output + "\n", nil
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeAge puts an age stand-in on PATH that "encrypts" by base64-encoding
+// stdin between armor lines and logs its arguments.
+func fakeAge(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "age.log")
+	script := "#!/bin/sh\necho \"$@\" >> " + logPath + "\ncase \"$1\" in\n" +
+		"--encrypt) echo '" + ageArmorHeader + "'; base64; echo '-----END AGE ENCRYPTED FILE-----' ;;\n" +
+		"--decrypt) sed '1d;$d' \"$4\" | base64 -d ;;\n*) exit 1 ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "age"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake age: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logPath
+}
+
+func TestEncryptConfigRoundTrip(t *testing.T) {
+	logPath := fakeAge(t)
+	config := "[Interface]\nPrivateKey = client-priv\nAddress = 10.0.0.2/32\n"
+	recipients := []string{
+		"age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p",
+		"age1yubikey1qwt50d05nh5vutpdzmlg5wn80xq5negm4uj9ghv0snvdd3yysf5yw3rhl3t",
+	}
+
+	encrypted, err := EncryptConfig(config, recipients)
+	if err != nil {
+		t.Fatalf("EncryptConfig: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, ageArmorHeader) || strings.Contains(encrypted, "client-priv") {
+		t.Fatalf("expected armored ciphertext, got:\n%s", encrypted)
+	}
+	log := strings.Join(readLog(t, logPath), "\n")
+	for _, recipient := range recipients {
+		if !strings.Contains(log, "--recipient "+recipient) {
+			t.Fatalf("expected recipient %s to be passed to age, got %s", recipient, log)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "laptop.conf.age")
+	if err := os.WriteFile(path, []byte(encrypted), 0o600); err != nil {
+		t.Fatalf("write encrypted config: %v", err)
+	}
+	decrypted, err := DecryptConfig(path, "/keys.txt")
+	if err != nil {
+		t.Fatalf("DecryptConfig: %v", err)
+	}
+	if decrypted != config {
+		t.Fatalf("expected the original config back, got:\n%s", decrypted)
+	}
+
+	for _, bad := range [][]string{nil, {"ssh-ed25519 AAAA"}, {"age1NOTBECH32"}, {""}} {
+		if _, err := EncryptConfig(config, bad); err == nil {
+			t.Fatalf("expected recipients %q to be rejected", bad)
+		}
+	}
+}
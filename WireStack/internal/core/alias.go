@@ -0,0 +1,46 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// maxAliasLength is the kernel limit for interface aliases (IFALIASZ minus the NUL).
+const maxAliasLength = 255
+
+// ValidateAlias checks that alias can be set with `ip link set ... alias` and
+// embedded safely in a wg-quick hook.
+func ValidateAlias(alias string) error {
+	if len(alias) > maxAliasLength {
+		return fmt.Errorf("alias is %d bytes; the kernel allows at most %d", len(alias), maxAliasLength)
+	}
+	if strings.ContainsAny(alias, "'\n\r") {
+		return fmt.Errorf("alias must not contain quotes or newlines")
+	}
+	return nil
+}
+
+// aliasHook returns the PostUp command that labels the interface with alias.
+func aliasHook(alias string) string {
+	return fmt.Sprintf("ip link set dev %%i alias '%s'", alias)
+}
+
+// SetInterfaceAlias labels a running interface so `ip -d link` shows alias.
+func SetInterfaceAlias(iface, alias string) error {
+	if _, err := utils.RunCommand("ip", "link", "set", "dev", iface, "alias", alias); err != nil {
+		return fmt.Errorf("set alias on %s: %w", iface, err)
+	}
+	return nil
+}
+
+// writeDescription renders a description as a config comment. Newlines are
+// folded so the text cannot escape the comment.
+func writeDescription(builder *strings.Builder, description string) {
+	description = strings.Join(strings.Fields(description), " ")
+	if description == "" {
+		return
+	}
+	fmt.Fprintf(builder, "# Description: %s\n", description)
+}
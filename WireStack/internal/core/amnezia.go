@@ -0,0 +1,148 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// amneziaMaxPacket bounds junk packet sizes and handshake padding so padded
+// packets still fit a 1280 byte path.
+const amneziaMaxPacket = 1280
+
+// AmneziaParams are the AmneziaWG obfuscation settings. Jc junk packets of
+// Jmin to Jmax bytes precede each handshake; a stock WireGuard server ignores
+// them. S1 and S2 pad the handshake messages and H1-H4 replace the message
+// type headers; those change the wire format, so the server has to run
+// AmneziaWG with the same values (see NeedsServer).
+type AmneziaParams struct {
+	Jc   int    `json:"jc"`
+	Jmin int    `json:"jmin"`
+	Jmax int    `json:"jmax"`
+	S1   int    `json:"s1"`
+	S2   int    `json:"s2"`
+	H1   uint32 `json:"h1"`
+	H2   uint32 `json:"h2"`
+	H3   uint32 `json:"h3"`
+	H4   uint32 `json:"h4"`
+}
+
+// GenerateAmneziaParams picks random parameters in the ranges AmneziaWG
+// recommends. With junkOnly only the junk packets are set, so clients stay
+// compatible with a stock WireGuard server.
+func GenerateAmneziaParams(junkOnly bool) (*AmneziaParams, error) {
+	var seed [28]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	jmin := 40 + int(seed[1])%40
+	params := &AmneziaParams{
+		Jc:   3 + int(seed[0])%8,
+		Jmin: jmin,
+		Jmax: jmin + 200 + int(binary.BigEndian.Uint16(seed[2:4]))%600,
+		H1:   1, H2: 2, H3: 3, H4: 4,
+	}
+	if junkOnly {
+		return params, nil
+	}
+	params.S1 = 15 + int(seed[4])%136
+	params.S2 = 15 + int(seed[5])%136
+	if params.S1+56 == params.S2 {
+		params.S2++
+	}
+	headers := map[uint32]bool{}
+	for idx, field := range []*uint32{&params.H1, &params.H2, &params.H3, &params.H4} {
+		value := binary.BigEndian.Uint32(seed[12+idx*4:])
+		// Values 1-4 are the stock headers, and every header must differ.
+		for value < 5 || headers[value] {
+			value = value*2654435761 + 5
+		}
+		headers[value] = true
+		*field = value
+	}
+	return params, nil
+}
+
+// ValidateAmneziaParams checks the parameters against AmneziaWG's limits.
+func ValidateAmneziaParams(params *AmneziaParams) error {
+	if params.Jc < 1 || params.Jc > 128 {
+		return fmt.Errorf("Jc must be between 1 and 128, got %d", params.Jc)
+	}
+	if params.Jmin < 0 || params.Jmin > params.Jmax || params.Jmax > amneziaMaxPacket {
+		return fmt.Errorf("need 0 <= Jmin <= Jmax <= %d, got Jmin %d and Jmax %d", amneziaMaxPacket, params.Jmin, params.Jmax)
+	}
+	// 148 and 92 bytes are the handshake initiation and response sizes.
+	if params.S1 < 0 || params.S1 > amneziaMaxPacket-148 {
+		return fmt.Errorf("S1 must be between 0 and %d, got %d", amneziaMaxPacket-148, params.S1)
+	}
+	if params.S2 < 0 || params.S2 > amneziaMaxPacket-92 {
+		return fmt.Errorf("S2 must be between 0 and %d, got %d", amneziaMaxPacket-92, params.S2)
+	}
+	if params.S1+56 == params.S2 {
+		return fmt.Errorf("S1 + 56 must not equal S2, or padded initiations and responses have the same size")
+	}
+	headers := map[uint32]bool{}
+	for _, header := range []uint32{params.H1, params.H2, params.H3, params.H4} {
+		if header == 0 {
+			return fmt.Errorf("H1-H4 must be set")
+		}
+		if headers[header] {
+			return fmt.Errorf("H1-H4 must all differ, %d is repeated", header)
+		}
+		headers[header] = true
+	}
+	return nil
+}
+
+// NeedsServer reports whether the parameters change the wire format, so the
+// server has to run AmneziaWG with them too.
+func (p *AmneziaParams) NeedsServer() bool {
+	return p.S1 != 0 || p.S2 != 0 || p.H1 != 1 || p.H2 != 2 || p.H3 != 3 || p.H4 != 4
+}
+
+// Lines renders the parameters as "Key = value" lines for an [Interface] section.
+func (p *AmneziaParams) Lines() []string {
+	return []string{
+		fmt.Sprintf("Jc = %d", p.Jc),
+		fmt.Sprintf("Jmin = %d", p.Jmin),
+		fmt.Sprintf("Jmax = %d", p.Jmax),
+		fmt.Sprintf("S1 = %d", p.S1),
+		fmt.Sprintf("S2 = %d", p.S2),
+		fmt.Sprintf("H1 = %d", p.H1),
+		fmt.Sprintf("H2 = %d", p.H2),
+		fmt.Sprintf("H3 = %d", p.H3),
+		fmt.Sprintf("H4 = %d", p.H4),
+	}
+}
+
+// SetAmnezia stores obfuscation parameters on the server, or removes them
+// when params is nil.
+func SetAmnezia(profile *ServerProfile, params *AmneziaParams) error {
+	if params == nil {
+		profile.Amnezia = nil
+		return nil
+	}
+	if profile.ExternalInterface != "" && params.NeedsServer() {
+		return fmt.Errorf("S1, S2, and H1-H4 must be configured on the AmneziaWG server, whose config is not rendered with --external-interface; use junk packets only")
+	}
+	if err := ValidateAmneziaParams(params); err != nil {
+		return err
+	}
+	copied := *params
+	profile.Amnezia = &copied
+	return nil
+}
+
+// amneziaKeys are the [Interface] keys that only AmneziaWG understands.
+var amneziaKeys = []string{"Jc", "Jmin", "Jmax", "S1", "S2", "H1", "H2", "H3", "H4"}
+
+// UsesAmnezia reports whether a parsed config carries AmneziaWG parameters
+// and so has to be brought up with awg-quick instead of wg-quick.
+func UsesAmnezia(config *WGConfig) bool {
+	for _, key := range amneziaKeys {
+		if config.Interface.Get(key) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAmneziaParams(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		params, err := GenerateAmneziaParams(false)
+		if err != nil {
+			t.Fatalf("GenerateAmneziaParams: %v", err)
+		}
+		if err := ValidateAmneziaParams(params); err != nil {
+			t.Fatalf("generated invalid params %+v: %v", params, err)
+		}
+		if !params.NeedsServer() {
+			t.Fatalf("expected full params to need an AmneziaWG server: %+v", params)
+		}
+	}
+
+	params, err := GenerateAmneziaParams(true)
+	if err != nil {
+		t.Fatalf("GenerateAmneziaParams: %v", err)
+	}
+	if err := ValidateAmneziaParams(params); err != nil {
+		t.Fatalf("generated invalid junk-only params %+v: %v", params, err)
+	}
+	if params.NeedsServer() {
+		t.Fatalf("expected junk-only params to work with a stock server: %+v", params)
+	}
+
+	for _, bad := range []AmneziaParams{
+		{Jc: 0, Jmin: 40, Jmax: 70, H1: 1, H2: 2, H3: 3, H4: 4},
+		{Jc: 4, Jmin: 80, Jmax: 70, H1: 1, H2: 2, H3: 3, H4: 4},
+		{Jc: 4, Jmin: 40, Jmax: 2000, H1: 1, H2: 2, H3: 3, H4: 4},
+		{Jc: 4, Jmin: 40, Jmax: 70, S1: 20, S2: 76, H1: 5, H2: 6, H3: 7, H4: 8},
+		{Jc: 4, Jmin: 40, Jmax: 70, H1: 5, H2: 5, H3: 7, H4: 8},
+		{Jc: 4, Jmin: 40, Jmax: 70, H1: 5, H2: 6, H3: 7},
+	} {
+		if err := ValidateAmneziaParams(&bad); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestAmneziaConfigs(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	client, err := AddClient(profile, ClientOptions{Name: "laptop"})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if _, err := BuildClientConfigFor(profile, client, ClientRenderOptions{Amnezia: true}); err == nil {
+		t.Fatalf("expected an AmneziaWG export without parameters to fail")
+	}
+
+	junk := &AmneziaParams{Jc: 4, Jmin: 40, Jmax: 70, H1: 1, H2: 2, H3: 3, H4: 4}
+	if err := SetAmnezia(profile, junk); err != nil {
+		t.Fatalf("SetAmnezia: %v", err)
+	}
+	server, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	if strings.Contains(server, "Jc =") {
+		t.Fatalf("did not expect junk-only params in the server config:\n%s", server)
+	}
+	plain, err := BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if strings.Contains(plain, "Jc =") {
+		t.Fatalf("did not expect junk-only params in a plain client config:\n%s", plain)
+	}
+	amnezia, err := BuildClientConfigFor(profile, client, ClientRenderOptions{Amnezia: true})
+	if err != nil {
+		t.Fatalf("BuildClientConfigFor: %v", err)
+	}
+	if !strings.Contains(amnezia, "Jc = 4\nJmin = 40\nJmax = 70\nS1 = 0\n") {
+		t.Fatalf("expected the AmneziaWG flavor to carry the params:\n%s", amnezia)
+	}
+
+	full := &AmneziaParams{Jc: 4, Jmin: 40, Jmax: 70, S1: 20, S2: 30, H1: 5, H2: 6, H3: 7, H4: 8}
+	if err := SetAmnezia(profile, full); err != nil {
+		t.Fatalf("SetAmnezia: %v", err)
+	}
+	if server, err = BuildServerConfig(profile); err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	if !strings.Contains(server, "H4 = 8\n") {
+		t.Fatalf("expected wire format params in the server config:\n%s", server)
+	}
+	if plain, err = BuildClientConfig(profile, client); err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(plain, "S1 = 20\n") {
+		t.Fatalf("expected every client to carry wire format params:\n%s", plain)
+	}
+
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	if err := os.WriteFile(path, []byte(server), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if tool := quickTool(path); tool != "awg-quick" {
+		t.Fatalf("expected awg-quick for an AmneziaWG config, got %s", tool)
+	}
+	if _, err := (nativeBackend{}).Up(path); err == nil || !strings.Contains(err.Error(), "AmneziaWG") {
+		t.Fatalf("expected the native backend to reject AmneziaWG configs, got %v", err)
+	}
+
+	profile.ExternalInterface = "wg0"
+	if err := SetAmnezia(profile, full); err == nil {
+		t.Fatalf("expected wire format params to be rejected with an external interface")
+	}
+	if err := SetAmnezia(profile, nil); err != nil || profile.Amnezia != nil {
+		t.Fatalf("expected nil to clear the params, got %+v (%v)", profile.Amnezia, err)
+	}
+	if err := os.WriteFile(path, []byte(plain[:strings.Index(plain, "Jc =")]), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if tool := quickTool(path); tool != "wg-quick" {
+		t.Fatalf("expected wg-quick for a plain config, got %s", tool)
+	}
+}
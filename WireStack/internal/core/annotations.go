@@ -0,0 +1,101 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// annotationName matches the name part of an annotation key, following the
+// Kubernetes rules: alphanumeric at both ends with '-', '_' and '.' between.
+var annotationName = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+
+// annotationPrefix matches an optional DNS-subdomain prefix such as example.com.
+var annotationPrefix = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// ValidateAnnotationKey checks a key of the form [prefix/]name.
+func ValidateAnnotationKey(key string) error {
+	prefix, name, hasPrefix := strings.Cut(key, "/")
+	if !hasPrefix {
+		name, prefix = prefix, ""
+	}
+	if hasPrefix && (len(prefix) > 253 || !annotationPrefix.MatchString(prefix)) {
+		return fmt.Errorf("invalid annotation key %q: prefix must be a lowercase DNS subdomain", key)
+	}
+	if !annotationName.MatchString(name) {
+		return fmt.Errorf("invalid annotation key %q: name must be 1-63 alphanumeric characters, '-', '_' or '.'", key)
+	}
+	return nil
+}
+
+// ParseAnnotationArgs parses kubectl-style arguments: key=value sets an
+// annotation and key- removes it.
+func ParseAnnotationArgs(args []string) (map[string]string, []string, error) {
+	set := map[string]string{}
+	var remove []string
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			if err := ValidateAnnotationKey(key); err != nil {
+				return nil, nil, err
+			}
+			set[key] = value
+			continue
+		}
+		if key, ok := strings.CutSuffix(arg, "-"); ok {
+			if err := ValidateAnnotationKey(key); err != nil {
+				return nil, nil, err
+			}
+			remove = append(remove, key)
+			continue
+		}
+		return nil, nil, fmt.Errorf("invalid annotation %q (want key=value or key-)", arg)
+	}
+	return set, remove, nil
+}
+
+// UpdateAnnotations applies additions and removals to an annotation map,
+// returning nil when no annotations remain so the field is omitted on save.
+func UpdateAnnotations(annotations map[string]string, set map[string]string, remove []string) (map[string]string, error) {
+	updated := make(map[string]string, len(annotations)+len(set))
+	for key, value := range annotations {
+		updated[key] = value
+	}
+	for key, value := range set {
+		if err := ValidateAnnotationKey(key); err != nil {
+			return nil, err
+		}
+		updated[key] = value
+	}
+	for _, key := range remove {
+		delete(updated, key)
+	}
+	if len(updated) == 0 {
+		return nil, nil
+	}
+	return updated, nil
+}
+
+// ParseAnnotationSelector parses a filter such as "team=net" (exact value) or
+// "ticket" (key present).
+func ParseAnnotationSelector(selector string) (key, value string, hasValue bool, err error) {
+	key, value, hasValue = strings.Cut(selector, "=")
+	if err := ValidateAnnotationKey(key); err != nil {
+		return "", "", false, err
+	}
+	return key, value, hasValue, nil
+}
+
+// MatchAnnotations reports whether annotations satisfy every selector.
+func MatchAnnotations(annotations map[string]string, selectors []string) (bool, error) {
+	for _, selector := range selectors {
+		key, value, hasValue, err := ParseAnnotationSelector(selector)
+		if err != nil {
+			return false, err
+		}
+		actual, ok := annotations[key]
+		if !ok || (hasValue && actual != value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotationKeys(t *testing.T) {
+	for _, key := range []string{"team", "cost-center", "example.com/ticket", "a", "Owner_1"} {
+		if err := ValidateAnnotationKey(key); err != nil {
+			t.Fatalf("expected %q to be valid: %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "-team", "has space", "Example.com/ticket", "example.com/", "a/b/c"} {
+		if err := ValidateAnnotationKey(key); err == nil {
+			t.Fatalf("expected %q to be rejected", key)
+		}
+	}
+}
+
+func TestAnnotationUpdatesAndSelectors(t *testing.T) {
+	set, remove, err := ParseAnnotationArgs([]string{"team=net", "ticket=", "old-"})
+	if err != nil {
+		t.Fatalf("ParseAnnotationArgs: %v", err)
+	}
+	if len(set) != 2 || set["ticket"] != "" || len(remove) != 1 || remove[0] != "old" {
+		t.Fatalf("unexpected parse result %v %v", set, remove)
+	}
+	if _, _, err := ParseAnnotationArgs([]string{"team"}); err == nil {
+		t.Fatalf("expected bare key to be rejected")
+	}
+
+	original := map[string]string{"old": "1", "keep": "yes"}
+	updated, err := UpdateAnnotations(original, set, remove)
+	if err != nil {
+		t.Fatalf("UpdateAnnotations: %v", err)
+	}
+	if len(updated) != 3 || updated["team"] != "net" || updated["keep"] != "yes" {
+		t.Fatalf("unexpected annotations %v", updated)
+	}
+	if _, ok := original["team"]; ok {
+		t.Fatalf("UpdateAnnotations must not modify its input")
+	}
+	if cleared, _ := UpdateAnnotations(map[string]string{"a": "1"}, nil, []string{"a"}); cleared != nil {
+		t.Fatalf("expected empty annotations to collapse to nil, got %v", cleared)
+	}
+
+	for selectors, want := range map[string]bool{
+		"team=net":        true,
+		"team=ops":        false,
+		"ticket":          true,
+		"missing":         false,
+		"team=net,ticket": true,
+	} {
+		got, err := MatchAnnotations(updated, strings.Split(selectors, ","))
+		if err != nil || got != want {
+			t.Fatalf("MatchAnnotations(%q) = %v, %v; want %v", selectors, got, err, want)
+		}
+	}
+	if _, err := MatchAnnotations(updated, []string{"bad key=x"}); err == nil {
+		t.Fatalf("expected invalid selector to be rejected")
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"wirestack/internal/utils"
+)
+
+// ansibleGroup is the inventory group export-ansible puts servers in, so
+// inventories exported for several servers can be merged.
+const ansibleGroup = "wirestack"
+
+// AnsibleOptions configures BuildAnsible.
+type AnsibleOptions struct {
+	// Host is the inventory ansible_host; the endpoint host unless set.
+	Host string
+	// User is the ansible_user, left to Ansible's defaults when empty.
+	User string
+}
+
+// AnsibleExport lists the files ExportAnsible wrote.
+type AnsibleExport struct {
+	InventoryPath string
+	PlaybookPath  string
+	ConfigPath    string
+}
+
+// ansiblePlay is one play of a playbook.
+type ansiblePlay struct {
+	Name     string        `yaml:"name"`
+	Hosts    string        `yaml:"hosts"`
+	Become   bool          `yaml:"become"`
+	Tasks    []ansibleTask `yaml:"tasks"`
+	Handlers []ansibleTask `yaml:"handlers"`
+}
+
+// ansibleTask is a task or handler. Module holds the module with its
+// arguments and keywords such as notify; inlining it puts the name first, and
+// sorted keys put "ansible.builtin.*" before "notify".
+type ansibleTask struct {
+	Name   string         `yaml:"name"`
+	Module map[string]any `yaml:",inline"`
+}
+
+// BuildAnsible renders an inventory entry for the server host, a playbook
+// that deploys the server config with wg-quick, and the config the playbook
+// copies from files/<iface>.conf.
+func BuildAnsible(profile *ServerProfile, options AnsibleOptions) (string, string, string, error) {
+	config, err := hostServerConfig(profile)
+	if err != nil {
+		return "", "", "", err
+	}
+	host := options.Host
+	if host == "" {
+		if host, _, err = net.SplitHostPort(profile.Endpoint); err != nil {
+			return "", "", "", fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
+		}
+	}
+	vars := map[string]string{"ansible_host": host}
+	if options.User != "" {
+		vars["ansible_user"] = options.User
+	}
+	inventory := map[string]any{
+		"all": map[string]any{"children": map[string]any{
+			ansibleGroup: map[string]any{"hosts": map[string]any{profile.Name: vars}},
+		}},
+	}
+
+	iface := InterfaceName(profile)
+	service := "wg-quick@" + iface
+	play := ansiblePlay{
+		Name:   "Deploy WireGuard server " + profile.Name,
+		Hosts:  profile.Name,
+		Become: true,
+		Tasks: []ansibleTask{
+			{Name: "Install WireGuard tools", Module: map[string]any{"ansible.builtin.package": map[string]any{
+				"name": hostPackages(profile), "state": "present",
+			}}},
+			{Name: "Enable forwarding", Module: map[string]any{"ansible.builtin.copy": map[string]any{
+				"content": forwardingSysctlConf(profile), "dest": sysctlConfPath(profile), "owner": "root", "group": "root", "mode": "0644",
+			}, "notify": "Reload sysctls"}},
+			{Name: "Write the server config", Module: map[string]any{"ansible.builtin.copy": map[string]any{
+				"src": "files/" + iface + ".conf", "dest": "/etc/wireguard/" + iface + ".conf", "owner": "root", "group": "root", "mode": "0600",
+			}, "notify": "Restart WireGuard"}},
+			{Name: "Start WireGuard at boot", Module: map[string]any{"ansible.builtin.systemd": map[string]any{
+				"name": service, "enabled": true, "state": "started",
+			}}},
+		},
+		// Handlers run in the order listed, so forwarding is on before a restart.
+		Handlers: []ansibleTask{
+			{Name: "Reload sysctls", Module: map[string]any{"ansible.builtin.command": "sysctl --system"}},
+			{Name: "Restart WireGuard", Module: map[string]any{"ansible.builtin.systemd": map[string]any{
+				"name": service, "state": "restarted",
+			}}},
+		},
+	}
+
+	inventoryYAML, err := renderAnsibleYAML(fmt.Sprintf("# Generated by wirestack export-ansible %s.\n", profile.Name), inventory)
+	if err != nil {
+		return "", "", "", err
+	}
+	playbookYAML, err := renderAnsibleYAML(fmt.Sprintf("# Generated by wirestack export-ansible %s. Run: ansible-playbook -i inventory.yml playbook.yml\n", profile.Name), []ansiblePlay{play})
+	if err != nil {
+		return "", "", "", err
+	}
+	return inventoryYAML, playbookYAML, config, nil
+}
+
+// renderAnsibleYAML encodes value as a YAML document after a header comment.
+func renderAnsibleYAML(header string, value any) (string, error) {
+	builder := &bytes.Buffer{}
+	builder.WriteString("---\n" + header)
+	encoder := yaml.NewEncoder(builder)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return "", fmt.Errorf("render ansible files: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("render ansible files: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// ExportAnsible writes inventory.yml, playbook.yml, and files/<iface>.conf
+// into dir, ready for `ansible-playbook -i inventory.yml playbook.yml` there.
+func ExportAnsible(profile *ServerProfile, dir string, options AnsibleOptions) (*AnsibleExport, error) {
+	inventory, playbook, config, err := BuildAnsible(profile, options)
+	if err != nil {
+		return nil, err
+	}
+	export := &AnsibleExport{
+		InventoryPath: filepath.Join(dir, "inventory.yml"),
+		PlaybookPath:  filepath.Join(dir, "playbook.yml"),
+		ConfigPath:    filepath.Join(dir, "files", InterfaceName(profile)+".conf"),
+	}
+	if err := utils.WriteFile(export.ConfigPath, []byte(config), 0o600); err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFile(export.InventoryPath, []byte(inventory), 0o644); err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFile(export.PlaybookPath, []byte(playbook), 0o644); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExportAnsible(t *testing.T) {
+	setupTempHome(t)
+	profile := DefaultServerProfile("edge", "198.51.100.7:51820", "server-priv", "server-pub")
+	if err := SetNAT(profile, "ens5", NATIptables); err != nil {
+		t.Fatalf("SetNAT: %v", err)
+	}
+
+	export, err := ExportAnsible(profile, t.TempDir(), AnsibleOptions{User: "admin"})
+	if err != nil {
+		t.Fatalf("ExportAnsible: %v", err)
+	}
+	inventory, err := os.ReadFile(export.InventoryPath)
+	if err != nil {
+		t.Fatalf("read inventory: %v", err)
+	}
+	var parsed struct {
+		All struct {
+			Children map[string]struct {
+				Hosts map[string]map[string]string `yaml:"hosts"`
+			} `yaml:"children"`
+		} `yaml:"all"`
+	}
+	if err := yaml.Unmarshal(inventory, &parsed); err != nil {
+		t.Fatalf("inventory is not valid YAML: %v", err)
+	}
+	vars := parsed.All.Children["wirestack"].Hosts["edge"]
+	if vars["ansible_host"] != "198.51.100.7" || vars["ansible_user"] != "admin" {
+		t.Fatalf("unexpected inventory:\n%s", inventory)
+	}
+
+	playbook, err := os.ReadFile(export.PlaybookPath)
+	if err != nil {
+		t.Fatalf("read playbook: %v", err)
+	}
+	var plays []map[string]any
+	if err := yaml.Unmarshal(playbook, &plays); err != nil || len(plays) != 1 || plays[0]["hosts"] != "edge" {
+		t.Fatalf("unexpected playbook (%v):\n%s", err, playbook)
+	}
+	for _, want := range []string{
+		"- wireguard-tools\n          - iptables",
+		"src: files/edge.conf",
+		"dest: /etc/wireguard/edge.conf",
+		"notify: Restart WireGuard",
+		"name: wg-quick@edge",
+	} {
+		if !strings.Contains(string(playbook), want) {
+			t.Fatalf("playbook misses %q:\n%s", want, playbook)
+		}
+	}
+	config, err := os.ReadFile(export.ConfigPath)
+	if err != nil || !strings.Contains(string(config), "PrivateKey = server-priv") {
+		t.Fatalf("unexpected config (%v):\n%s", err, config)
+	}
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// Kinds of system artifacts WireStack records for a server.
+const (
+	// ArtifactNAT is the forwarding and MASQUERADE rules of the NAT hooks.
+	ArtifactNAT = "nat"
+	// ArtifactUnits is a unit file written by systemd install.
+	ArtifactUnits = "units"
+	// ArtifactTC is a root qdisc replaced on another interface by a PostUp
+	// hook, such as the one tune --apply adds for the egress interface.
+	ArtifactTC = "tc"
+	// ArtifactPortMap is a UDP port forward up requested from the router.
+	ArtifactPortMap = "portmap"
+)
+
+// ArtifactKinds lists every artifact kind, in the order teardown removes them.
+var ArtifactKinds = []string{ArtifactUnits, ArtifactNAT, ArtifactTC, ArtifactPortMap}
+
+// Artifact is something WireStack created on the host outside ~/.wirestack
+// that can outlive the interface, or the server profile itself.
+type Artifact struct {
+	Kind string `json:"kind"`
+	// Target identifies the artifact within its kind: the nft table or egress
+	// interface for nat, the unit name for units, the device for tc, and the
+	// external port for portmap.
+	Target string `json:"target"`
+	// Path is the file holding the artifact, if it has one.
+	Path string `json:"path,omitempty"`
+	// Undo are the shell commands that remove the artifact, run in order.
+	Undo []string `json:"undo,omitempty"`
+	// Mapping is the router's port forward for portmap.
+	Mapping   *PortMapping `json:"mapping,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// ArtifactRegistry holds the artifacts recorded for one server.
+type ArtifactRegistry struct {
+	Server    string     `json:"server"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// TeardownResult reports what Teardown removed and what it could not.
+type TeardownResult struct {
+	Removed []Artifact
+	Failed  []TeardownFailure
+}
+
+// TeardownFailure is an artifact Teardown could not remove. It stays recorded.
+type TeardownFailure struct {
+	Artifact Artifact
+	Err      error
+}
+
+// rootQdiscHook matches PostUp hooks that install a root qdisc on a device.
+var rootQdiscHook = regexp.MustCompile(`^tc\s+qdisc\s+(?:add|replace)\s+dev\s+(\S+)\s+root\b`)
+
+// LoadArtifacts reads a server's artifact registry, returning an empty one
+// when nothing was recorded.
+func LoadArtifacts(server string) (*ArtifactRegistry, error) {
+	path, err := ArtifactsPath(server)
+	if err != nil {
+		return nil, err
+	}
+	registry := &ArtifactRegistry{Server: server}
+	if err := utils.ReadJSON(path, registry); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// SaveArtifacts writes a server's artifact registry, removing the file once
+// nothing is left in it.
+func SaveArtifacts(registry *ArtifactRegistry) error {
+	path, err := ArtifactsPath(registry.Server)
+	if err != nil {
+		return err
+	}
+	if len(registry.Artifacts) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return utils.WriteJSON(path, registry, 0o600)
+}
+
+// RecordArtifacts adds artifacts to the server's registry, replacing earlier
+// records of the same kind and target. Nothing is recorded in dry-run mode.
+func RecordArtifacts(server string, artifacts ...Artifact) error {
+	if len(artifacts) == 0 || utils.DryRun() {
+		return nil
+	}
+	return updateArtifacts(server, func(registry *ArtifactRegistry) {
+		now := time.Now().UTC()
+		for _, artifact := range artifacts {
+			if artifact.CreatedAt.IsZero() {
+				artifact.CreatedAt = now
+			}
+			registry.Artifacts = append(dropArtifacts(registry.Artifacts, artifact.Kind, artifact.Target), artifact)
+		}
+	})
+}
+
+// ForgetArtifacts drops the server's records of kind, or only the one for
+// target when it is set, after something other than Teardown removed them.
+func ForgetArtifacts(server, kind, target string) error {
+	if utils.DryRun() {
+		return nil
+	}
+	return updateArtifacts(server, func(registry *ArtifactRegistry) {
+		registry.Artifacts = dropArtifacts(registry.Artifacts, kind, target)
+	})
+}
+
+// updateArtifacts applies change to the server's registry under its lock.
+func updateArtifacts(server string, change func(*ArtifactRegistry)) error {
+	unlock, err := lockStateFile("artifacts-" + server)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	registry, err := LoadArtifacts(server)
+	if err != nil {
+		return err
+	}
+	change(registry)
+	return SaveArtifacts(registry)
+}
+
+// dropArtifacts returns artifacts without the ones of kind and, when set, target.
+func dropArtifacts(artifacts []Artifact, kind, target string) []Artifact {
+	kept := artifacts[:0]
+	for _, artifact := range artifacts {
+		if artifact.Kind == kind && (target == "" || artifact.Target == target) {
+			continue
+		}
+		kept = append(kept, artifact)
+	}
+	return kept
+}
+
+// ServerArtifacts returns the NAT rules and qdiscs that bringing the server's
+// interface up creates, with the commands that remove them.
+func ServerArtifacts(profile *ServerProfile) []Artifact {
+	iface := InterfaceName(profile)
+	var artifacts []Artifact
+	if _, natDown := NATHooks(profile); len(natDown) > 0 {
+		target := profile.NATInterface
+		if profile.NATBackend == NATNftables {
+			target = "inet " + nftTableName(profile)
+		}
+		undo := make([]string, 0, len(natDown))
+		for _, hook := range natDown {
+			undo = append(undo, strings.ReplaceAll(hook, "%i", iface))
+		}
+		artifacts = append(artifacts, Artifact{Kind: ArtifactNAT, Target: target, Undo: undo})
+	}
+	for _, hook := range profile.PostUp {
+		match := rootQdiscHook.FindStringSubmatch(strings.TrimSpace(hook))
+		// A qdisc on the WireGuard interface goes away with it.
+		if match == nil || match[1] == "%i" || match[1] == iface {
+			continue
+		}
+		artifacts = append(artifacts, Artifact{
+			Kind:   ArtifactTC,
+			Target: match[1],
+			Undo:   []string{fmt.Sprintf("tc qdisc del dev %s root", match[1])},
+		})
+	}
+	return artifacts
+}
+
+// SystemdArtifact returns the record of an installed unit.
+func SystemdArtifact(unit *SystemdUnit) Artifact {
+	return Artifact{Kind: ArtifactUnits, Target: unit.Name, Path: unit.Path}
+}
+
+// ParseArtifactKinds checks a teardown --what list, returning every kind when
+// it is empty.
+func ParseArtifactKinds(kinds []string) ([]string, error) {
+	if len(kinds) == 0 {
+		return ArtifactKinds, nil
+	}
+	for _, kind := range kinds {
+		if !containsString(ArtifactKinds, kind) {
+			return nil, fmt.Errorf("unknown artifact kind %q (want %s)", kind, strings.Join(ArtifactKinds, ", "))
+		}
+	}
+	return kinds, nil
+}
+
+// Teardown removes the server's recorded artifacts of the given kinds and
+// drops their records. forget drops the records without touching the host,
+// for artifacts already removed by hand. Artifacts that fail to come off stay
+// recorded so the teardown can be retried.
+func Teardown(server string, kinds []string, forget bool) (*TeardownResult, error) {
+	unlock, err := lockStateFile("artifacts-" + server)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	registry, err := LoadArtifacts(server)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TeardownResult{}
+	var kept []Artifact
+	for _, kind := range ArtifactKinds {
+		if !containsString(kinds, kind) {
+			continue
+		}
+		for _, artifact := range registry.Artifacts {
+			if artifact.Kind != kind {
+				continue
+			}
+			if !forget {
+				if err := removeArtifact(artifact); err != nil {
+					result.Failed = append(result.Failed, TeardownFailure{Artifact: artifact, Err: err})
+					kept = append(kept, artifact)
+					continue
+				}
+			}
+			result.Removed = append(result.Removed, artifact)
+		}
+	}
+	for _, artifact := range registry.Artifacts {
+		if !containsString(kinds, artifact.Kind) {
+			kept = append(kept, artifact)
+		}
+	}
+	registry.Artifacts = kept
+	if err := SaveArtifacts(registry); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// removeArtifact undoes one artifact on the host.
+func removeArtifact(artifact Artifact) error {
+	if artifact.Kind == ArtifactPortMap && artifact.Mapping != nil {
+		return ReleasePortMapping(context.Background(), artifact.Mapping)
+	}
+	if artifact.Kind == ArtifactUnits {
+		unit := &SystemdUnit{Mode: SystemdService, Name: artifact.Target, Path: artifact.Path}
+		if filepath.Base(artifact.Path) == systemdDropInName {
+			unit.Mode = SystemdWGQuick
+		}
+		return UninstallSystemdUnit(unit)
+	}
+	for _, command := range artifact.Undo {
+		if _, err := utils.RunCommand("sh", "-c", command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
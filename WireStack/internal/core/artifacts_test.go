@@ -0,0 +1,94 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServerArtifacts(t *testing.T) {
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetNAT(profile, "eth0", ""); err != nil {
+		t.Fatalf("SetNAT: %v", err)
+	}
+	profile.PostUp = []string{"tc qdisc replace dev eth0 root fq_codel", "tc qdisc replace dev %i root cake", "logger up"}
+
+	artifacts := ServerArtifacts(profile)
+	if len(artifacts) != 2 {
+		t.Fatalf("expected a nat and a tc artifact, got %+v", artifacts)
+	}
+	nat, tc := artifacts[0], artifacts[1]
+	if nat.Kind != ArtifactNAT || nat.Target != "eth0" || len(nat.Undo) != 1 {
+		t.Fatalf("unexpected nat artifact: %+v", nat)
+	}
+	if strings.Contains(nat.Undo[0], "%i") || !strings.Contains(nat.Undo[0], "iptables -D FORWARD -i "+InterfaceName(profile)) {
+		t.Fatalf("expected the interface substituted in the undo commands: %q", nat.Undo[0])
+	}
+	if tc.Kind != ArtifactTC || tc.Target != "eth0" || tc.Undo[0] != "tc qdisc del dev eth0 root" {
+		t.Fatalf("unexpected tc artifact: %+v", tc)
+	}
+}
+
+func TestTeardownRemovesRecordedArtifacts(t *testing.T) {
+	setupTempHome(t)
+
+	if err := RecordArtifacts("edge",
+		Artifact{Kind: ArtifactNAT, Target: "eth0", Undo: []string{"true"}},
+		Artifact{Kind: ArtifactTC, Target: "eth0", Undo: []string{"false"}},
+		Artifact{Kind: ArtifactUnits, Target: "wirestack-edge.service", Path: "/nonexistent/wirestack-edge.service"},
+	); err != nil {
+		t.Fatalf("RecordArtifacts: %v", err)
+	}
+	// Recording the same kind and target again replaces the earlier record.
+	if err := RecordArtifacts("edge", Artifact{Kind: ArtifactNAT, Target: "eth0", Undo: []string{"true"}}); err != nil {
+		t.Fatalf("RecordArtifacts: %v", err)
+	}
+	registry, err := LoadArtifacts("edge")
+	if err != nil {
+		t.Fatalf("LoadArtifacts: %v", err)
+	}
+	if len(registry.Artifacts) != 3 {
+		t.Fatalf("expected 3 records, got %+v", registry.Artifacts)
+	}
+
+	result, err := Teardown("edge", []string{ArtifactNAT, ArtifactTC}, false)
+	if err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Kind != ArtifactNAT {
+		t.Fatalf("expected the nat rules removed, got %+v", result.Removed)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Artifact.Kind != ArtifactTC {
+		t.Fatalf("expected the tc undo to fail, got %+v", result.Failed)
+	}
+	registry, err = LoadArtifacts("edge")
+	if err != nil {
+		t.Fatalf("LoadArtifacts: %v", err)
+	}
+	if len(registry.Artifacts) != 2 {
+		t.Fatalf("expected the failed tc and untouched unit records kept, got %+v", registry.Artifacts)
+	}
+
+	if err := ForgetArtifacts("edge", ArtifactUnits, "wirestack-edge.service"); err != nil {
+		t.Fatalf("ForgetArtifacts: %v", err)
+	}
+	if result, err = Teardown("edge", ArtifactKinds, true); err != nil || len(result.Removed) != 1 {
+		t.Fatalf("expected --forget to drop the tc record, got %+v, %v", result, err)
+	}
+	path, err := ArtifactsPath("edge")
+	if err != nil {
+		t.Fatalf("ArtifactsPath: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the empty registry removed, stat: %v", err)
+	}
+}
+
+func TestParseArtifactKinds(t *testing.T) {
+	if kinds, err := ParseArtifactKinds(nil); err != nil || len(kinds) != len(ArtifactKinds) {
+		t.Fatalf("expected every kind by default, got %v, %v", kinds, err)
+	}
+	if _, err := ParseArtifactKinds([]string{"nat", "ddns"}); err == nil {
+		t.Fatal("expected an unknown kind to be rejected")
+	}
+}
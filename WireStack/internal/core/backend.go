@@ -0,0 +1,356 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	// BackendWGQuick applies configs by shelling out to wg-quick.
+	BackendWGQuick = "wg-quick"
+	// BackendNative applies configs directly with ip(8) and wg(8), without wg-quick.
+	BackendNative = "native"
+	// BackendWindows installs configs as tunnel services with WireGuard for Windows.
+	BackendWindows = "windows"
+	// BackendDarwin runs configs on macOS with wireguard-go, without wg-quick.
+	BackendDarwin = "darwin"
+
+	// defaultRouteTable is the routing table and fwmark used for full-tunnel routes,
+	// matching the value wg-quick uses.
+	defaultRouteTable = 51820
+)
+
+// Backend brings interfaces described by rendered config files up and down.
+type Backend interface {
+	Up(configPath string) (string, error)
+	Down(configPath string) (string, error)
+}
+
+// DefaultBackend returns the platform's default backend: windows on Windows,
+// where wg-quick does not exist, darwin on macOS without wg-quick installed,
+// and wg-quick elsewhere.
+func DefaultBackend() string {
+	switch runtime.GOOS {
+	case "windows":
+		return BackendWindows
+	case "darwin":
+		if _, err := exec.LookPath("wg-quick"); err != nil {
+			return BackendDarwin
+		}
+	}
+	return BackendWGQuick
+}
+
+// NewBackend returns the backend registered under name, or the default
+// backend when name is empty.
+func NewBackend(name string) (Backend, error) {
+	if name == "" {
+		name = DefaultBackend()
+	}
+	switch name {
+	case BackendWGQuick:
+		if runtime.GOOS == "windows" {
+			return nil, fmt.Errorf("wg-quick is not available on Windows; use --backend %s", BackendWindows)
+		}
+		return wgQuickBackend{}, nil
+	case BackendNative:
+		if runtime.GOOS != "linux" {
+			return nil, fmt.Errorf("the native backend is only supported on Linux")
+		}
+		if err := RequireFeature(FeatureNativeBackend); err != nil {
+			return nil, err
+		}
+		return nativeBackend{}, nil
+	case BackendWindows:
+		if runtime.GOOS != "windows" {
+			return nil, fmt.Errorf("the windows backend is only supported on Windows")
+		}
+		return windowsBackend{}, nil
+	case BackendDarwin:
+		if runtime.GOOS != "darwin" {
+			return nil, fmt.Errorf("the darwin backend is only supported on macOS")
+		}
+		return darwinBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q (want %s, %s, %s, or %s)", name, BackendWGQuick, BackendNative, BackendWindows, BackendDarwin)
+	}
+}
+
+// ConfigInterfaceName returns the interface name derived from a config path, as wg-quick does.
+func ConfigInterfaceName(configPath string) string {
+	return strings.TrimSuffix(filepath.Base(configPath), ".conf")
+}
+
+type wgQuickBackend struct{}
+
+// Up runs `wg-quick up` (or `awg-quick up`, see quickTool) on the config.
+func (wgQuickBackend) Up(configPath string) (string, error) {
+	return utils.RunCommand(quickTool(configPath), "up", configPath)
+}
+
+// Down runs `wg-quick down` (or `awg-quick down`) on the config.
+func (wgQuickBackend) Down(configPath string) (string, error) {
+	return utils.RunCommand(quickTool(configPath), "down", configPath)
+}
+
+// quickTool returns awg-quick for configs with AmneziaWG parameters, which
+// wg-quick rejects, and wg-quick otherwise.
+func quickTool(configPath string) string {
+	if config, _, err := loadConfigFile(configPath); err == nil && UsesAmnezia(config) {
+		return "awg-quick"
+	}
+	return "wg-quick"
+}
+
+type nativeBackend struct{}
+
+// Up creates the interface, loads keys and peers with `wg setconf`, then assigns
+// addresses, MTU, DNS, and routes the same way wg-quick would.
+func (nativeBackend) Up(configPath string) (string, error) {
+	config, iface, err := loadConfigFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	if UsesAmnezia(config) {
+		return "", fmt.Errorf("%s has AmneziaWG parameters, which the native backend does not support; use --backend %s with awg-quick installed", configPath, BackendWGQuick)
+	}
+
+	if err := runHooks(config.Interface.All("PreUp"), iface); err != nil {
+		return "", err
+	}
+	if _, err := utils.RunCommand("ip", "link", "add", "dev", iface, "type", "wireguard"); err != nil {
+		return "", err
+	}
+	if err := nativeConfigure(configPath, config, iface); err != nil {
+		_, _ = utils.RunCommand("ip", "link", "delete", "dev", iface)
+		return "", err
+	}
+	if err := runHooks(config.Interface.All("PostUp"), iface); err != nil {
+		_, _ = utils.RunCommand("ip", "link", "delete", "dev", iface)
+		return "", err
+	}
+	return fmt.Sprintf("interface %s up", iface), nil
+}
+
+// Down removes full-tunnel routing rules and deletes the interface.
+func (nativeBackend) Down(configPath string) (string, error) {
+	config, iface, err := loadConfigFile(configPath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := runHooks(config.Interface.All("PreDown"), iface); err != nil {
+		return "", err
+	}
+	if usesDefaultRoute(config) {
+		removeDefaultRouteRules()
+	}
+	if _, err := utils.RunCommand("ip", "link", "delete", "dev", iface); err != nil {
+		return "", err
+	}
+	if err := runHooks(config.Interface.All("PostDown"), iface); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("interface %s down", iface), nil
+}
+
+// loadConfigFile reads and parses a rendered config and derives its interface name.
+func loadConfigFile(configPath string) (*WGConfig, string, error) {
+	data, err := utils.ReadFile(configPath)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := ParseConfig(string(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	return config, ConfigInterfaceName(configPath), nil
+}
+
+// nativeConfigure applies everything after the link exists.
+func nativeConfigure(configPath string, config *WGConfig, iface string) error {
+	strippedPath := configPath + ".stripped"
+	if err := utils.WriteFile(strippedPath, []byte(StripConfig(config)), 0o600); err != nil {
+		return err
+	}
+	_, err := utils.RunCommand("wg", "setconf", iface, strippedPath)
+	_ = os.Remove(strippedPath)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range config.Interface.List("Address") {
+		family := "-4"
+		if strings.Contains(address, ":") {
+			family = "-6"
+		}
+		if _, err := utils.RunCommand("ip", family, "address", "add", address, "dev", iface); err != nil {
+			return err
+		}
+	}
+
+	linkArgs := []string{"link", "set"}
+	if mtu := config.Interface.Get("MTU"); mtu != "" {
+		linkArgs = append(linkArgs, "mtu", mtu)
+	}
+	linkArgs = append(linkArgs, "up", "dev", iface)
+	if _, err := utils.RunCommand("ip", linkArgs...); err != nil {
+		return err
+	}
+
+	if dns := config.Interface.List("DNS"); len(dns) > 0 {
+		if err := applyDNS(iface, dns); err != nil {
+			return err
+		}
+	}
+
+	table := config.Interface.Get("Table")
+	if strings.EqualFold(table, "off") {
+		return nil
+	}
+	for _, peer := range config.Peers {
+		for _, cidr := range peer.List("AllowedIPs") {
+			if err := addRoute(iface, cidr, table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// StripConfig renders only the keys understood by `wg setconf`, dropping wg-quick extensions.
+func StripConfig(config *WGConfig) string {
+	wgKeys := map[string]bool{
+		"privatekey": true, "listenport": true, "fwmark": true,
+		"publickey": true, "presharedkey": true, "allowedips": true,
+		"endpoint": true, "persistentkeepalive": true,
+	}
+	builder := &strings.Builder{}
+	sections := append([]ConfigSection{config.Interface}, config.Peers...)
+	for idx, section := range sections {
+		if idx > 0 {
+			fmt.Fprintf(builder, "\n")
+		}
+		fmt.Fprintf(builder, "[%s]\n", section.Name)
+		for _, entry := range section.Entries {
+			if wgKeys[strings.ToLower(entry.Key)] {
+				fmt.Fprintf(builder, "%s = %s\n", entry.Key, entry.Value)
+			}
+		}
+	}
+	return builder.String()
+}
+
+// applyDNS points systemd-resolved at the tunnel's resolvers for all domains.
+func applyDNS(iface string, servers []string) error {
+	var resolvers, domains []string
+	for _, entry := range servers {
+		if net.ParseIP(entry) != nil {
+			resolvers = append(resolvers, entry)
+		} else {
+			domains = append(domains, entry)
+		}
+	}
+	if len(resolvers) > 0 {
+		args := append([]string{"dns", iface}, resolvers...)
+		if _, err := utils.RunCommand("resolvectl", args...); err != nil {
+			return fmt.Errorf("failed to configure DNS (native backend requires systemd-resolved): %w", err)
+		}
+	}
+	args := append([]string{"domain", iface, "~."}, domains...)
+	_, err := utils.RunCommand("resolvectl", args...)
+	return err
+}
+
+// addRoute routes a peer's AllowedIPs through the interface. Default routes use a
+// dedicated table and fwmark rules so the encrypted traffic itself is not looped.
+func addRoute(iface, cidr, table string) error {
+	family := "-4"
+	if strings.Contains(cidr, ":") {
+		family = "-6"
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid AllowedIPs entry %s: %w", cidr, err)
+	}
+	if ones, _ := network.Mask.Size(); ones == 0 && (table == "" || strings.EqualFold(table, "auto")) {
+		return addDefaultRoute(iface, family, cidr)
+	}
+	args := []string{family, "route", "replace", cidr, "dev", iface}
+	if table != "" && !strings.EqualFold(table, "auto") {
+		args = append(args, "table", table)
+	}
+	_, err = utils.RunCommand("ip", args...)
+	return err
+}
+
+// addDefaultRoute installs the fwmark based policy routing wg-quick uses for full tunnels.
+func addDefaultRoute(iface, family, cidr string) error {
+	mark := strconv.Itoa(defaultRouteTable)
+	commands := [][]string{
+		{"wg", "set", iface, "fwmark", mark},
+		{"ip", family, "route", "replace", cidr, "dev", iface, "table", mark},
+		{"ip", family, "rule", "add", "not", "fwmark", mark, "table", mark},
+		{"ip", family, "rule", "add", "table", "main", "suppress_prefixlength", "0"},
+	}
+	for _, command := range commands {
+		if _, err := utils.RunCommand(command[0], command[1:]...); err != nil {
+			return err
+		}
+	}
+	if family == "-4" {
+		_, _ = utils.RunCommand("sysctl", "-q", "net.ipv4.conf.all.src_valid_mark=1")
+	}
+	return nil
+}
+
+// removeDefaultRouteRules deletes the policy rules added by addDefaultRoute.
+// Each delete is repeated until it fails, except in a dry run where every
+// command succeeds and is shown once.
+func removeDefaultRouteRules() {
+	mark := strconv.Itoa(defaultRouteTable)
+	for _, family := range []string{"-4", "-6"} {
+		for {
+			if _, err := utils.RunCommand("ip", family, "rule", "delete", "table", mark); err != nil || utils.DryRun() {
+				break
+			}
+		}
+		for {
+			if _, err := utils.RunCommand("ip", family, "rule", "delete", "table", "main", "suppress_prefixlength", "0"); err != nil || utils.DryRun() {
+				break
+			}
+		}
+	}
+}
+
+// usesDefaultRoute reports whether any peer routes a /0 prefix.
+func usesDefaultRoute(config *WGConfig) bool {
+	for _, peer := range config.Peers {
+		for _, cidr := range peer.List("AllowedIPs") {
+			if _, network, err := net.ParseCIDR(cidr); err == nil {
+				if ones, _ := network.Mask.Size(); ones == 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// runHooks executes wg-quick style hook commands, substituting %i with the interface.
+func runHooks(hooks []string, iface string) error {
+	for _, hook := range hooks {
+		if _, err := utils.RunCommand("sh", "-c", strings.ReplaceAll(hook, "%i", iface)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	"wirestack/internal/utils"
+)
+
+// backupManifestName is the archive entry that lists every file and its checksum.
+const backupManifestName = "wirestack-backup.json"
+
+// backupMagic prefixes passphrase-encrypted backups.
+var backupMagic = []byte("WIRESTACK-BACKUP-AES256GCM-1\n")
+
+const (
+	backupSaltSize = 16
+	// scrypt parameters recommended for interactive use in the scrypt paper.
+	backupScryptN = 1 << 15
+	backupScryptR = 8
+	backupScryptP = 1
+)
+
+// ErrBackupPassphrase is returned when an encrypted backup cannot be decrypted.
+var ErrBackupPassphrase = errors.New("backup is encrypted and the passphrase is missing or wrong")
+
+// BackupManifest describes the contents of a backup archive.
+type BackupManifest struct {
+	Version   string            `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Files     map[string]string `json:"files"`
+}
+
+// CreateBackup archives the config root (profiles, runtime configs, settings,
+// and a sqlite database stored there) as tar.gz. When passphrase is set the
+// archive is encrypted with AES-256-GCM using a scrypt-derived key.
+func CreateBackup(w io.Writer, version, passphrase string) (*BackupManifest, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{Version: version, CreatedAt: time.Now().UTC(), Files: map[string]string{}}
+	type entry struct {
+		name string
+		mode fs.FileMode
+		data []byte
+	}
+	var entries []entry
+	err = filepath.WalkDir(root, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Temporary files belong to writes in progress or interrupted ones.
+		if d.IsDir() || !d.Type().IsRegular() || utils.IsTempFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(current)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		sum := sha256.Sum256(data)
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+		entries = append(entries, entry{name: name, mode: info.Mode().Perm(), data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read config root: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &bytes.Buffer{}
+	gz := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gz)
+	writeEntry := func(name string, mode fs.FileMode, data []byte) error {
+		header := &tar.Header{Name: name, Mode: int64(mode), Size: int64(len(data)), ModTime: manifest.CreatedAt, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := writeEntry(backupManifestName, 0o600, manifestData); err != nil {
+		return nil, fmt.Errorf("write backup: %w", err)
+	}
+	for _, item := range entries {
+		if err := writeEntry(item.name, item.mode, item.data); err != nil {
+			return nil, fmt.Errorf("write backup: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("write backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("write backup: %w", err)
+	}
+
+	payload := archive.Bytes()
+	if passphrase != "" {
+		if payload, err = encryptBackup(payload, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, fmt.Errorf("write backup: %w", err)
+	}
+	return manifest, nil
+}
+
+// backupFile is a verified file read from a backup archive.
+type backupFile struct {
+	mode fs.FileMode
+	data []byte
+}
+
+// VerifyBackup decrypts a backup if needed and checks every file against the
+// manifest checksums without restoring anything.
+func VerifyBackup(r io.Reader, passphrase string) (*BackupManifest, error) {
+	manifest, _, err := readBackup(r, passphrase)
+	return manifest, err
+}
+
+// readBackup decrypts, unpacks, and verifies a backup.
+func readBackup(r io.Reader, passphrase string) (*BackupManifest, map[string]backupFile, error) {
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read backup: %w", err)
+	}
+	if bytes.HasPrefix(payload, backupMagic) {
+		if passphrase == "" {
+			return nil, nil, ErrBackupPassphrase
+		}
+		if payload, err = decryptBackup(payload, passphrase); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read backup: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string]backupFile{}
+	var manifest *BackupManifest
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read backup: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("backup entry %s is not a regular file", header.Name)
+		}
+		if err := checkBackupPath(header.Name); err != nil {
+			return nil, nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read backup entry %s: %w", header.Name, err)
+		}
+		if header.Name == backupManifestName {
+			manifest = &BackupManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("read backup manifest: %w", err)
+			}
+			continue
+		}
+		files[header.Name] = backupFile{mode: fs.FileMode(header.Mode).Perm(), data: data}
+	}
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("backup has no %s manifest", backupManifestName)
+	}
+
+	for name, want := range manifest.Files {
+		file, ok := files[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("backup is missing %s", name)
+		}
+		sum := sha256.Sum256(file.data)
+		if hex.EncodeToString(sum[:]) != want {
+			return nil, nil, fmt.Errorf("checksum mismatch for %s; the backup is corrupt", name)
+		}
+	}
+	for name := range files {
+		if _, ok := manifest.Files[name]; !ok {
+			return nil, nil, fmt.Errorf("backup contains %s, which is not in the manifest", name)
+		}
+	}
+	return manifest, files, nil
+}
+
+// RestoreBackup verifies a backup and writes its files into the config root.
+// Existing profiles are only overwritten when force is set.
+func RestoreBackup(r io.Reader, passphrase string, force bool) (*BackupManifest, error) {
+	manifest, files, err := readBackup(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	root, err := ConfigRoot()
+	if err != nil {
+		return nil, err
+	}
+	if !force {
+		for name := range files {
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err == nil {
+				return nil, fmt.Errorf("%s already exists; use --force to overwrite", name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mode := files[name].mode
+		if mode == 0 {
+			mode = 0o600
+		}
+		if err := utils.WriteFile(filepath.Join(root, filepath.FromSlash(name)), files[name].data, mode); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// checkBackupPath rejects archive entries that would escape the config root.
+func checkBackupPath(name string) error {
+	clean := path.Clean(name)
+	if name == "" || path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(name, `\`) {
+		return fmt.Errorf("backup entry %q has an unsafe path", name)
+	}
+	return nil
+}
+
+// encryptBackup seals payload with a key derived from passphrase.
+func encryptBackup(payload []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := backupCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, backupMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, payload, backupMagic), nil
+}
+
+// decryptBackup opens a payload produced by encryptBackup.
+func decryptBackup(payload []byte, passphrase string) ([]byte, error) {
+	body := payload[len(backupMagic):]
+	if len(body) < backupSaltSize {
+		return nil, fmt.Errorf("encrypted backup is truncated")
+	}
+	salt, body := body[:backupSaltSize], body[backupSaltSize:]
+	aead, err := backupCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted backup is truncated")
+	}
+	nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, backupMagic)
+	if err != nil {
+		return nil, ErrBackupPassphrase
+	}
+	return plain, nil
+}
+
+// backupCipher derives the AES-256-GCM cipher for a passphrase and salt.
+func backupCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, backupScryptN, backupScryptR, backupScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	setupTempHome(t)
+
+	profile := DefaultServerProfile("backup-srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	if _, err := WriteServerConfig(profile); err != nil {
+		t.Fatalf("WriteServerConfig: %v", err)
+	}
+
+	for _, passphrase := range []string{"", "correct horse"} {
+		archive := &bytes.Buffer{}
+		manifest, err := CreateBackup(archive, "test", passphrase)
+		if err != nil {
+			t.Fatalf("CreateBackup: %v", err)
+		}
+		if len(manifest.Files) < 2 {
+			t.Fatalf("expected profile and runtime config in backup, got %v", manifest.Files)
+		}
+		if passphrase != "" && bytes.Contains(archive.Bytes(), []byte("server-priv")) {
+			t.Fatalf("encrypted backup leaks profile contents")
+		}
+
+		if _, err := RestoreBackup(bytes.NewReader(archive.Bytes()), passphrase, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("expected restore over existing files to be refused, got %v", err)
+		}
+		if err := DeleteServerProfile("backup-srv"); err != nil {
+			t.Fatalf("DeleteServerProfile: %v", err)
+		}
+		if _, err := RestoreBackup(bytes.NewReader(archive.Bytes()), passphrase, true); err != nil {
+			t.Fatalf("RestoreBackup: %v", err)
+		}
+		restored, err := LoadServerProfile("backup-srv")
+		if err != nil {
+			t.Fatalf("LoadServerProfile after restore: %v", err)
+		}
+		if restored.ServerPrivateKey != "server-priv" {
+			t.Fatalf("unexpected restored profile %+v", restored)
+		}
+	}
+}
+
+func TestBackupRejectsWrongPassphraseAndTampering(t *testing.T) {
+	setupTempHome(t)
+
+	if err := SaveServerProfile(DefaultServerProfile("backup-srv", "203.0.113.1:51820", "server-priv", "server-pub")); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	encrypted := &bytes.Buffer{}
+	if _, err := CreateBackup(encrypted, "test", "secret"); err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	for _, passphrase := range []string{"", "wrong"} {
+		if _, err := VerifyBackup(bytes.NewReader(encrypted.Bytes()), passphrase); !errors.Is(err, ErrBackupPassphrase) {
+			t.Fatalf("expected ErrBackupPassphrase for %q, got %v", passphrase, err)
+		}
+	}
+
+	plain := &bytes.Buffer{}
+	if _, err := CreateBackup(plain, "test", ""); err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	tampered := rewriteBackup(t, plain.Bytes(), func(name string, data []byte) []byte {
+		if strings.HasSuffix(name, ".json") && name != backupManifestName {
+			return bytes.Replace(data, []byte("server-priv"), []byte("attacker-key"), 1)
+		}
+		return data
+	})
+	if _, err := VerifyBackup(bytes.NewReader(tampered), ""); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+// rewriteBackup re-packs an unencrypted backup, passing each entry through edit.
+func rewriteBackup(t *testing.T, archive []byte, edit func(name string, data []byte) []byte) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	out := &bytes.Buffer{}
+	gzw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzw)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Next: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read entry: %v", err)
+		}
+		data = edit(header.Name, data)
+		header.Size = int64(len(data))
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestCheckBackupPath(t *testing.T) {
+	for _, name := range []string{"../evil", "/etc/passwd", "a/../../b", `a\b`} {
+		if err := checkBackupPath(name); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+	if err := checkBackupPath("profiles/lab.json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
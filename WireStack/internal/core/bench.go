@@ -0,0 +1,287 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BenchPath is the path prefix the bandwidth test endpoints are served under.
+const BenchPath = "/bench/"
+
+// MaxBenchDuration caps how long a single upload or download test may run.
+const MaxBenchDuration = 60 * time.Second
+
+// benchChunkSize is how much data each write of a transfer test carries.
+const benchChunkSize = 64 << 10
+
+// maxBenchEcho caps the body the latency echo sends back.
+const maxBenchEcho = 64 << 10
+
+// ErrBenchBusy is returned when the server is already running a transfer test.
+var ErrBenchBusy = errors.New("another benchmark is running on this server")
+
+// benchHandler serves the latency echo and the bulk transfer tests. Only one
+// transfer runs at a time so concurrent tests do not skew each other.
+type benchHandler struct {
+	busy chan struct{}
+}
+
+// NewBenchHandler returns the handler for the bench endpoints. It does no
+// authentication and is meant to listen on the tunnel address only.
+func NewBenchHandler() http.Handler {
+	return &benchHandler{busy: make(chan struct{}, 1)}
+}
+
+// benchUploadResult is the body returned by POST /bench/upload.
+type benchUploadResult struct {
+	Bytes int64 `json:"bytes"`
+}
+
+// ServeHTTP dispatches /bench/echo, /bench/download, and /bench/upload.
+func (h *benchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	switch strings.TrimPrefix(r.URL.Path, BenchPath) {
+	case "echo":
+		_, _ = io.Copy(w, io.LimitReader(r.Body, maxBenchEcho))
+	case "download":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		duration, err := parseBenchDuration(r.URL.Query().Get("duration"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !h.acquire(w) {
+			return
+		}
+		defer h.release()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		chunk := make([]byte, benchChunkSize)
+		deadline := time.Now().Add(duration)
+		for time.Now().Before(deadline) {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	case "upload":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !h.acquire(w) {
+			return
+		}
+		defer h.release()
+		// The client decides when to stop; the deadline only stops a client
+		// that never does.
+		_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(MaxBenchDuration + 5*time.Second))
+		n, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(benchUploadResult{Bytes: n})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// acquire claims the transfer slot, answering 503 when it is taken.
+func (h *benchHandler) acquire(w http.ResponseWriter) bool {
+	select {
+	case h.busy <- struct{}{}:
+		return true
+	default:
+		http.Error(w, ErrBenchBusy.Error(), http.StatusServiceUnavailable)
+		return false
+	}
+}
+
+func (h *benchHandler) release() { <-h.busy }
+
+// parseBenchDuration parses a ?duration= value, defaulting to 10s.
+func parseBenchDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 10 * time.Second, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %w", err)
+	}
+	if duration <= 0 || duration > MaxBenchDuration {
+		return 0, fmt.Errorf("duration must be positive and at most %s", MaxBenchDuration)
+	}
+	return duration, nil
+}
+
+// BenchOptions controls a client-side benchmark run.
+type BenchOptions struct {
+	// Duration is how long each transfer direction runs.
+	Duration time.Duration
+	// Pings is the number of latency echoes; zero skips the latency test.
+	Pings int
+	// Download and Upload select the transfer directions to test.
+	Download bool
+	Upload   bool
+}
+
+// BenchResult holds the measurements of one benchmark run. Skipped tests are zero.
+type BenchResult struct {
+	LatencyMin      time.Duration
+	LatencyAvg      time.Duration
+	LatencyMax      time.Duration
+	DownloadBytes   int64
+	DownloadElapsed time.Duration
+	UploadBytes     int64
+	UploadElapsed   time.Duration
+}
+
+// DownloadBitsPerSecond returns the measured download throughput.
+func (r *BenchResult) DownloadBitsPerSecond() float64 {
+	return bitsPerSecond(r.DownloadBytes, r.DownloadElapsed)
+}
+
+// UploadBitsPerSecond returns the measured upload throughput.
+func (r *BenchResult) UploadBitsPerSecond() float64 {
+	return bitsPerSecond(r.UploadBytes, r.UploadElapsed)
+}
+
+func bitsPerSecond(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) * 8 / elapsed.Seconds()
+}
+
+// RunBench measures latency and throughput against the bench endpoints at
+// baseURL, e.g. http://10.0.0.1:8081.
+func RunBench(ctx context.Context, client *http.Client, baseURL string, options BenchOptions) (*BenchResult, error) {
+	if options.Duration <= 0 || options.Duration > MaxBenchDuration {
+		return nil, fmt.Errorf("duration must be positive and at most %s", MaxBenchDuration)
+	}
+	base := strings.TrimSuffix(baseURL, "/") + BenchPath
+	result := &BenchResult{}
+
+	if options.Pings > 0 {
+		if err := benchLatency(ctx, client, base+"echo", options.Pings, result); err != nil {
+			return nil, fmt.Errorf("latency: %w", err)
+		}
+	}
+	if options.Download {
+		if err := benchDownload(ctx, client, base+"download?duration="+options.Duration.String(), result); err != nil {
+			return nil, fmt.Errorf("download: %w", err)
+		}
+	}
+	if options.Upload {
+		if err := benchUpload(ctx, client, base+"upload", options.Duration, result); err != nil {
+			return nil, fmt.Errorf("upload: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// benchLatency times echo round trips. A warm-up request opens the
+// connection first so the handshake is not counted.
+func benchLatency(ctx context.Context, client *http.Client, url string, pings int, result *BenchResult) error {
+	var total time.Duration
+	for i := 0; i <= pings; i++ {
+		start := time.Now()
+		if err := benchRequest(ctx, client, http.MethodPost, url, strings.NewReader("ping"), io.Discard); err != nil {
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		rtt := time.Since(start)
+		total += rtt
+		if result.LatencyMin == 0 || rtt < result.LatencyMin {
+			result.LatencyMin = rtt
+		}
+		if rtt > result.LatencyMax {
+			result.LatencyMax = rtt
+		}
+	}
+	result.LatencyAvg = total / time.Duration(pings)
+	return nil
+}
+
+// benchDownload reads the server's stream until it ends.
+func benchDownload(ctx context.Context, client *http.Client, url string, result *BenchResult) error {
+	counter := &countingWriter{}
+	start := time.Now()
+	if err := benchRequest(ctx, client, http.MethodGet, url, nil, counter); err != nil {
+		return err
+	}
+	result.DownloadElapsed = time.Since(start)
+	result.DownloadBytes = counter.n
+	return nil
+}
+
+// benchUpload streams data for duration and takes the byte count the server received.
+func benchUpload(ctx context.Context, client *http.Client, url string, duration time.Duration, result *BenchResult) error {
+	var response bytes.Buffer
+	start := time.Now()
+	body := &benchSource{deadline: start.Add(duration), chunk: make([]byte, benchChunkSize)}
+	if err := benchRequest(ctx, client, http.MethodPost, url, body, &response); err != nil {
+		return err
+	}
+	result.UploadElapsed = time.Since(start)
+	var uploaded benchUploadResult
+	if err := json.Unmarshal(response.Bytes(), &uploaded); err != nil {
+		return fmt.Errorf("invalid server response: %w", err)
+	}
+	result.UploadBytes = uploaded.Bytes
+	return nil
+}
+
+// benchRequest performs one request and copies a successful body into out.
+func benchRequest(ctx context.Context, client *http.Client, method, url string, body io.Reader, out io.Writer) error {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("server returned %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+	_, err = io.Copy(out, response.Body)
+	return err
+}
+
+// benchSource yields chunks of zeros until its deadline passes.
+type benchSource struct {
+	deadline time.Time
+	chunk    []byte
+}
+
+func (s *benchSource) Read(p []byte) (int, error) {
+	if !time.Now().Before(s.deadline) {
+		return 0, io.EOF
+	}
+	return copy(p, s.chunk), nil
+}
+
+// countingWriter discards data and counts it.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
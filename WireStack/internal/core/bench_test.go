@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunBenchAgainstHandler(t *testing.T) {
+	server := httptest.NewServer(NewBenchHandler())
+	defer server.Close()
+
+	result, err := RunBench(context.Background(), server.Client(), server.URL, BenchOptions{
+		Duration: 200 * time.Millisecond,
+		Pings:    3,
+		Download: true,
+		Upload:   true,
+	})
+	if err != nil {
+		t.Fatalf("RunBench: %v", err)
+	}
+	if result.LatencyMin <= 0 || result.LatencyMin > result.LatencyAvg || result.LatencyAvg > result.LatencyMax {
+		t.Fatalf("inconsistent latency min/avg/max: %s/%s/%s", result.LatencyMin, result.LatencyAvg, result.LatencyMax)
+	}
+	if result.DownloadBytes == 0 || result.DownloadBitsPerSecond() <= 0 {
+		t.Fatalf("expected download traffic, got %+v", result)
+	}
+	if result.UploadBytes == 0 || result.UploadBitsPerSecond() <= 0 {
+		t.Fatalf("expected upload traffic, got %+v", result)
+	}
+
+	if _, err := RunBench(context.Background(), server.Client(), server.URL, BenchOptions{Duration: 2 * MaxBenchDuration}); err == nil {
+		t.Fatalf("expected an over-long duration to be rejected")
+	}
+}
+
+func TestBenchHandlerRunsOneTransferAtATime(t *testing.T) {
+	handler := NewBenchHandler().(*benchHandler)
+	handler.busy <- struct{}{}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, BenchPath+"download?duration=1s", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while busy, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, BenchPath+"download?duration=5m", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an over-long duration, got %d", recorder.Code)
+	}
+}
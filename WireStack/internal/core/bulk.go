@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// Actions RunBulk applies to a set of clients.
+const (
+	BulkRotateKeys = "rotate-keys"
+	BulkDisable    = "disable"
+	BulkDelete     = "delete"
+	BulkExport     = "export"
+)
+
+// BulkActions lists every bulk action.
+var BulkActions = []string{BulkRotateKeys, BulkDisable, BulkDelete, BulkExport}
+
+// DefaultBulkWorkers is how many clients RunBulk prepares at once: key pairs
+// for rotate-keys, config files for export.
+const DefaultBulkWorkers = 4
+
+// BulkOptions configures RunBulk.
+type BulkOptions struct {
+	Action string
+	// Workers bounds the clients prepared at once; zero uses DefaultBulkWorkers.
+	Workers int
+	// Dir and Export configure BulkExport.
+	Dir    string
+	Export ExportOptions
+}
+
+// BulkOutcome is what a bulk action did to one client.
+type BulkOutcome struct {
+	Client string
+	// Detail describes the change, such as the new public key or the exported file.
+	Detail string
+	Err    error
+	// Retired is the public key the client had on the server before a
+	// rotate-keys, disable, or delete, for updating a running interface.
+	Retired string
+}
+
+// BulkResult reports a bulk action per client, in the order the clients were given.
+type BulkResult struct {
+	Action   string
+	Outcomes []BulkOutcome
+	// Applied is false when a failure kept every profile change from being
+	// made. Exports are written per client and are always applied.
+	Applied bool
+}
+
+// Failed returns how many clients the action failed for.
+func (r *BulkResult) Failed() int {
+	failed := 0
+	for _, outcome := range r.Outcomes {
+		if outcome.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Changed reports whether the profile was changed and needs saving.
+func (r *BulkResult) Changed() bool {
+	return r.Applied && r.Action != BulkExport
+}
+
+// RunBulk applies one action to the named clients. The slow per-client work
+// runs on a pool of options.Workers goroutines. rotate-keys, disable, and
+// delete change the profile as one transaction: when any client fails,
+// none is changed. The caller holds the profile lock and saves the profile
+// when the result reports Changed.
+func RunBulk(ctx context.Context, profile *ServerProfile, names []string, options BulkOptions) (*BulkResult, error) {
+	if !containsString(BulkActions, options.Action) {
+		return nil, fmt.Errorf("unknown bulk action %q (want %s)", options.Action, strings.Join(BulkActions, ", "))
+	}
+	workers := options.Workers
+	if workers == 0 {
+		workers = DefaultBulkWorkers
+	}
+	if workers < 0 {
+		return nil, fmt.Errorf("workers must be positive")
+	}
+	clients := make([]ClientProfile, len(names))
+	result := &BulkResult{Action: options.Action, Outcomes: make([]BulkOutcome, len(names))}
+	for idx, name := range names {
+		client, err := FindClient(profile, name)
+		if err != nil {
+			return nil, err
+		}
+		clients[idx] = *client
+		result.Outcomes[idx].Client = name
+	}
+
+	switch options.Action {
+	case BulkExport:
+		return result, bulkExport(ctx, profile, clients, workers, options, result)
+	case BulkRotateKeys:
+		type keyPair struct{ private, public string }
+		keys := make([]keyPair, len(clients))
+		errs := runPool(ctx, workers, len(clients), func(ctx context.Context, idx int) error {
+			private, public, err := GenerateKeyPairContext(ctx)
+			keys[idx] = keyPair{private, public}
+			return err
+		})
+		if !recordBulkErrors(result, errs) {
+			return result, nil
+		}
+		for idx := range clients {
+			client, err := FindClient(profile, names[idx])
+			if err != nil {
+				return nil, err
+			}
+			result.Outcomes[idx].Retired = client.PublicKey
+			client.KeyHistory = retireKey(client.KeyHistory, client.PrivateKey, client.PublicKey)
+			client.PrivateKey, client.PublicKey = keys[idx].private, keys[idx].public
+			result.Outcomes[idx].Detail = "public key " + client.PublicKey
+		}
+	case BulkDisable:
+		for idx, client := range clients {
+			if client.Disabled {
+				result.Outcomes[idx].Detail = "already disabled"
+				continue
+			}
+			if err := DisableClient(profile, client.Name); err != nil {
+				return nil, err
+			}
+			result.Outcomes[idx].Retired = client.PublicKey
+			result.Outcomes[idx].Detail = "disabled"
+		}
+	case BulkDelete:
+		for idx, client := range clients {
+			if _, err := RemoveClient(profile, client.Name); err != nil {
+				return nil, err
+			}
+			result.Outcomes[idx].Retired = client.PublicKey
+			result.Outcomes[idx].Detail = "deleted"
+		}
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// bulkExport writes the clients' configs into options.Dir. File names are
+// picked up front so clients whose names collide still get distinct files.
+func bulkExport(ctx context.Context, profile *ServerProfile, clients []ClientProfile, workers int, options BulkOptions, result *BulkResult) error {
+	if options.Dir == "" {
+		return fmt.Errorf("export needs a directory")
+	}
+	if err := utils.EnsureDir(options.Dir); err != nil {
+		return err
+	}
+	target := options.Export.Render.Target
+	taken := map[string]bool{}
+	stems := make([]string, len(clients))
+	for idx, client := range clients {
+		if client.Disabled && !options.Export.IncludeDisabled {
+			continue
+		}
+		stems[idx] = uniqueFileStem(ClientConfigFileName(profile.Name, client.Name, target), target, taken)
+	}
+	errs := runPool(ctx, workers, len(clients), func(ctx context.Context, idx int) error {
+		if stems[idx] == "" {
+			result.Outcomes[idx].Detail = "skipped: disabled"
+			return nil
+		}
+		entry, err := exportClientFile(profile, clients[idx], options.Dir, stems[idx], options.Export)
+		if err != nil {
+			return err
+		}
+		result.Outcomes[idx].Detail = entry.ConfigPath
+		if entry.QRPath != "" {
+			result.Outcomes[idx].Detail += ", " + entry.QRPath
+		}
+		return nil
+	})
+	recordBulkErrors(result, errs)
+	result.Applied = true
+	return nil
+}
+
+// recordBulkErrors stores per-client errors in the result and reports
+// whether every client succeeded.
+func recordBulkErrors(result *BulkResult, errs []error) bool {
+	ok := true
+	for idx, err := range errs {
+		if err != nil {
+			result.Outcomes[idx].Err = err
+			ok = false
+		}
+	}
+	return ok
+}
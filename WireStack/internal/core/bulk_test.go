@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBulk(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := AddClient(profile, ClientOptions{Name: name, Tags: []string{"contractors"}}); err != nil {
+			t.Fatalf("AddClient: %v", err)
+		}
+	}
+	if _, err := AddClient(profile, ClientOptions{Name: "staff"}); err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	names, err := ClientsWithTags(profile, []string{"contractors"})
+	if err != nil {
+		t.Fatalf("ClientsWithTags: %v", err)
+	}
+
+	before := profile.Clients[0].PublicKey
+	result, err := RunBulk(context.Background(), profile, names, BulkOptions{Action: BulkRotateKeys, Workers: 2})
+	if err != nil {
+		t.Fatalf("RunBulk: %v", err)
+	}
+	if !result.Changed() || result.Failed() != 0 || len(result.Outcomes) != 3 {
+		t.Fatalf("unexpected rotate result %+v", result)
+	}
+	if profile.Clients[0].PublicKey == before || result.Outcomes[0].Retired != before || len(profile.Clients[0].KeyHistory) != 1 {
+		t.Fatalf("expected client a rotated, got %+v", profile.Clients[0])
+	}
+
+	dir := t.TempDir()
+	result, err = RunBulk(context.Background(), profile, names, BulkOptions{Action: BulkExport, Dir: dir})
+	if err != nil {
+		t.Fatalf("RunBulk: %v", err)
+	}
+	if result.Changed() || result.Failed() != 0 {
+		t.Fatalf("unexpected export result %+v", result)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("expected 3 exported configs, got %v, %v", entries, err)
+	}
+	data, err := os.ReadFile(result.Outcomes[1].Detail)
+	if err != nil || !strings.Contains(string(data), profile.Clients[1].PrivateKey) {
+		t.Fatalf("expected b's config at %s: %v", result.Outcomes[1].Detail, err)
+	}
+
+	if _, err := RunBulk(context.Background(), profile, names, BulkOptions{Action: BulkDisable}); err != nil {
+		t.Fatalf("RunBulk: %v", err)
+	}
+	if !profile.Clients[2].Disabled || profile.Clients[3].Disabled {
+		t.Fatalf("expected only tagged clients disabled")
+	}
+	if _, err := RunBulk(context.Background(), profile, names, BulkOptions{Action: BulkDelete}); err != nil {
+		t.Fatalf("RunBulk: %v", err)
+	}
+	if len(profile.Clients) != 1 || profile.Clients[0].Name != "staff" {
+		t.Fatalf("expected only staff left, got %+v", profile.Clients)
+	}
+}
+
+func TestRunBulkRotateIsOneTransaction(t *testing.T) {
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{{Name: "a", PublicKey: "key-a"}, {Name: "b", PublicKey: "key-b"}}
+
+	// A wg that fails to derive public keys fails every key pair.
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$1\" in\ngenkey) echo private ;;\n*) exit 1 ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake wg: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	result, err := RunBulk(context.Background(), profile, []string{"a", "b"}, BulkOptions{Action: BulkRotateKeys})
+	if err != nil {
+		t.Fatalf("RunBulk: %v", err)
+	}
+	if result.Applied || result.Failed() != 2 {
+		t.Fatalf("expected the rotation abandoned, got %+v", result)
+	}
+	if profile.Clients[0].PublicKey != "key-a" || len(profile.Clients[0].KeyHistory) != 0 {
+		t.Fatalf("expected no client changed, got %+v", profile.Clients[0])
+	}
+	if _, err := RunBulk(context.Background(), profile, []string{"a"}, BulkOptions{Action: "suspend"}); err == nil {
+		t.Fatal("expected an unknown action to be rejected")
+	}
+}
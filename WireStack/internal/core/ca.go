@@ -0,0 +1,295 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+	// The previous CA stays trusted while a rotation is in progress.
+	caPreviousCertFile = "ca-previous.crt"
+	caPreviousKeyFile  = "ca-previous.key"
+)
+
+// Certificate roles issued by the CA.
+const (
+	// CertAgent is a client certificate presented to the daemon.
+	CertAgent = "agent"
+	// CertDaemon is the daemon's server certificate.
+	CertDaemon = "daemon"
+)
+
+// CA is the built-in certificate authority that authenticates the control
+// plane: agents and remote CLIs present certificates it issued to the daemon.
+type CA struct {
+	Cert    *x509.Certificate
+	Key     crypto.Signer
+	CertPEM []byte
+	// Previous is the CA being rotated out, or nil; see RotateCA.
+	Previous    *x509.Certificate
+	PreviousKey crypto.Signer
+	PreviousPEM []byte
+}
+
+// CAPaths returns where the CA certificate and private key are stored.
+func CAPaths() (string, string, error) {
+	root, err := CARoot()
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(root, caCertFile), filepath.Join(root, caKeyFile), nil
+}
+
+// InitCA creates a new self-signed CA valid for validity. An existing CA is
+// only replaced with force, since that invalidates every issued certificate.
+func InitCA(name string, validity time.Duration, force bool, now time.Time) (*CA, error) {
+	certPath, _, err := CAPaths()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(certPath); err == nil && !force {
+		return nil, fmt.Errorf("a CA already exists at %s; use --force to replace it", certPath)
+	}
+	if err := FinishCARotation(); err != nil {
+		return nil, err
+	}
+	return createCA(name, validity, now)
+}
+
+// createCA generates a CA and writes it over the current one.
+func createCA(name string, validity time.Duration, now time.Time) (*CA, error) {
+	certPath, keyPath, err := CAPaths()
+	if err != nil {
+		return nil, err
+	}
+	if validity <= 0 {
+		return nil, fmt.Errorf("CA validity must be positive")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"WireStack"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := utils.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key, CertPEM: certPEM}, nil
+}
+
+// LoadCA reads the CA created by InitCA, and the previous CA during a rotation.
+func LoadCA() (*CA, error) {
+	certPath, keyPath, err := CAPaths()
+	if err != nil {
+		return nil, err
+	}
+	cert, key, certPEM, err := loadCAPair(certPath, keyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no CA found; run wirestack ca init first")
+	}
+	if err != nil {
+		return nil, err
+	}
+	ca := &CA{Cert: cert, Key: key, CertPEM: certPEM}
+
+	root := filepath.Dir(certPath)
+	previous, previousKey, previousPEM, err := loadCAPair(filepath.Join(root, caPreviousCertFile), filepath.Join(root, caPreviousKeyFile))
+	if err == nil {
+		ca.Previous, ca.PreviousKey, ca.PreviousPEM = previous, previousKey, previousPEM
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// loadCAPair reads a CA certificate and its private key.
+func loadCAPair(certPath, keyPath string) (*x509.Certificate, crypto.Signer, []byte, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", certPath, err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read CA key: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, nil, fmt.Errorf("%s: no PEM key found", keyPath)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	return cert, key, certPEM, nil
+}
+
+// TrustBundle returns the CA certificates peers should trust: the current CA
+// and, during a rotation, the previous one.
+func (ca *CA) TrustBundle() []byte {
+	return append(append([]byte(nil), ca.CertPEM...), ca.PreviousPEM...)
+}
+
+// IssuedCert is a certificate and its private key in PEM form.
+type IssuedCert struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// Issue signs a new certificate for name. Agent certificates authenticate
+// clients; daemon certificates cover hosts, which may be names or IP addresses.
+// During a rotation the daemon certificate is still signed by the previous CA,
+// so agents that only trust it keep connecting until they renew.
+func (ca *CA) Issue(name, role string, hosts []string, validity time.Duration, now time.Time) (*IssuedCert, error) {
+	if name == "" {
+		return nil, fmt.Errorf("certificate name is empty")
+	}
+	if validity <= 0 {
+		return nil, fmt.Errorf("certificate validity must be positive")
+	}
+	template := &x509.Certificate{
+		Subject:   pkix.Name{CommonName: name, Organization: []string{"WireStack"}, OrganizationalUnit: []string{role}},
+		NotBefore: now.Add(-time.Minute),
+		NotAfter:  now.Add(validity),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}
+	issuer, signer := ca.Cert, ca.Key
+	if role == CertDaemon && ca.Previous != nil {
+		issuer, signer = ca.Previous, ca.PreviousKey
+	}
+	if template.NotAfter.After(issuer.NotAfter) {
+		template.NotAfter = issuer.NotAfter
+	}
+	switch role {
+	case CertAgent:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	case CertDaemon:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		for _, host := range hosts {
+			if ip := net.ParseIP(host); ip != nil {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			} else if host != "" {
+				template.DNSNames = append(template.DNSNames, host)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown certificate role %q", role)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if template.SerialNumber, err = randomSerial(); err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("issue certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &IssuedCert{Cert: cert, CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), KeyPEM: keyPEM}, nil
+}
+
+// DaemonTLSConfig returns a TLS config that serves the daemon certificate and
+// verifies any client certificate against the CA. Client certificates are
+// requested but not required at the TLS layer so that signed download links
+// keep working from browsers; the API enforces them per request.
+func (ca *CA) DaemonTLSConfig(daemon *IssuedCert) (*tls.Config, error) {
+	pair, err := tls.X509KeyPair(daemon.CertPEM, daemon.KeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	if ca.Previous != nil {
+		pool.AddCert(ca.Previous)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// parseCertificatePEM decodes the first certificate in data.
+func parseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// encodeECKey PEM-encodes an ECDSA private key.
+func encodeECKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// randomSerial returns a random 128-bit certificate serial number.
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// caRegistryFile records every agent certificate the CA issued.
+const caRegistryFile = "issued.json"
+
+// CARenewWindow is how close to expiry a certificate has to be before it is
+// due for renewal.
+const CARenewWindow = 30 * 24 * time.Hour
+
+// Certificate states reported by CertificateState.
+const (
+	CertValid    = "valid"
+	CertExpiring = "expiring"
+	CertExpired  = "expired"
+	CertRevoked  = "revoked"
+	// CertUntrusted means the issuing CA is no longer trusted, e.g. after a
+	// rotation finished or ca init --force.
+	CertUntrusted = "untrusted"
+)
+
+// IssuedRecord is one agent certificate in the CA registry.
+type IssuedRecord struct {
+	Name   string `json:"name"`
+	Serial string `json:"serial"`
+	// Issuer is the serial of the CA certificate that signed it.
+	Issuer    string     `json:"issuer"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	NotAfter  time.Time  `json:"not_after"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CARegistry lists the agent certificates the CA issued; the daemon checks
+// presented certificates against its revocations on every request.
+type CARegistry struct {
+	Certificates []IssuedRecord `json:"certificates"`
+}
+
+// LoadCARegistry reads the registry, returning an empty one when absent.
+func LoadCARegistry() (*CARegistry, error) {
+	path, err := caRegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	var registry CARegistry
+	if err := utils.ReadJSON(path, &registry); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &CARegistry{}, nil
+		}
+		return nil, err
+	}
+	return &registry, nil
+}
+
+// SaveCARegistry writes the registry.
+func SaveCARegistry(registry *CARegistry) error {
+	path, err := caRegistryPath()
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, registry, 0o600)
+}
+
+// caRegistryPath returns the location of the registry file.
+func caRegistryPath() (string, error) {
+	root, err := CARoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, caRegistryFile), nil
+}
+
+// IssueAgent issues an agent certificate and records it in the registry.
+func (ca *CA) IssueAgent(name string, validity time.Duration, now time.Time) (*IssuedCert, error) {
+	registry, err := LoadCARegistry()
+	if err != nil {
+		return nil, err
+	}
+	issued, err := ca.Issue(name, CertAgent, nil, validity, now)
+	if err != nil {
+		return nil, err
+	}
+	registry.Certificates = append(registry.Certificates, IssuedRecord{
+		Name:     name,
+		Serial:   certSerial(issued.Cert.SerialNumber),
+		Issuer:   certSerial(ca.Cert.SerialNumber),
+		IssuedAt: now.UTC(),
+		NotAfter: issued.Cert.NotAfter.UTC(),
+	})
+	if err := SaveCARegistry(registry); err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// Revoke marks the certificates matching name (every unrevoked one) or a
+// single serial as revoked, returning how many were revoked.
+func (r *CARegistry) Revoke(name, serial string, now time.Time) (int, error) {
+	if (name == "") == (serial == "") {
+		return 0, fmt.Errorf("give either a certificate name or a serial")
+	}
+	revoked := 0
+	for idx := range r.Certificates {
+		record := &r.Certificates[idx]
+		if record.RevokedAt != nil || (name != "" && record.Name != name) || (serial != "" && record.Serial != serial) {
+			continue
+		}
+		at := now.UTC()
+		record.RevokedAt = &at
+		revoked++
+	}
+	if revoked == 0 {
+		return 0, fmt.Errorf("no unrevoked certificate matches %s%s", name, serial)
+	}
+	return revoked, nil
+}
+
+// IsRevoked reports whether the certificate with serial was revoked.
+func (r *CARegistry) IsRevoked(serial *big.Int) bool {
+	value := certSerial(serial)
+	for _, record := range r.Certificates {
+		if record.Serial == value && record.RevokedAt != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Latest returns the most recently issued unrevoked certificate for name.
+func (r *CARegistry) Latest(name string) (IssuedRecord, bool) {
+	var latest IssuedRecord
+	found := false
+	for _, record := range r.Certificates {
+		if record.Name == name && record.RevokedAt == nil && (!found || record.IssuedAt.After(latest.IssuedAt)) {
+			latest, found = record, true
+		}
+	}
+	return latest, found
+}
+
+// CertificateState classifies a registry entry against the CA and now.
+func (ca *CA) CertificateState(record IssuedRecord, now time.Time) string {
+	switch {
+	case record.RevokedAt != nil:
+		return CertRevoked
+	case record.Issuer != certSerial(ca.Cert.SerialNumber) && (ca.Previous == nil || record.Issuer != certSerial(ca.Previous.SerialNumber)):
+		return CertUntrusted
+	case !now.Before(record.NotAfter):
+		return CertExpired
+	case record.NotAfter.Sub(now) < CARenewWindow:
+		return CertExpiring
+	default:
+		return CertValid
+	}
+}
+
+// Records returns the registry entries sorted by name, newest first per name.
+func (r *CARegistry) Records() []IssuedRecord {
+	records := append([]IssuedRecord(nil), r.Certificates...)
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].IssuedAt.After(records[j].IssuedAt)
+	})
+	return records
+}
+
+// CRL renders a PEM certificate revocation list, signed by the current CA,
+// for the revoked certificates it issued. It is valid for validity.
+func (ca *CA) CRL(registry *CARegistry, validity time.Duration, now time.Time) ([]byte, error) {
+	issuer := certSerial(ca.Cert.SerialNumber)
+	var entries []x509.RevocationListEntry
+	for _, record := range registry.Certificates {
+		if record.RevokedAt == nil || record.Issuer != issuer || now.After(record.NotAfter) {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(record.Serial, 16)
+		if !ok {
+			return nil, fmt.Errorf("registry entry %s has an invalid serial %q", record.Name, record.Serial)
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: *record.RevokedAt})
+	}
+	number, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(validity),
+		RevokedCertificateEntries: entries,
+	}, ca.Cert, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("create CRL: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
+
+// RotateCA starts a CA rotation: the current CA becomes the previous one and
+// a new CA with the same name is created. Both are trusted and the daemon
+// keeps a certificate from the previous CA until FinishCARotation, so agents
+// can renew onto the new CA without losing access.
+func RotateCA(validity time.Duration, now time.Time) (*CA, error) {
+	current, err := LoadCA()
+	if err != nil {
+		return nil, err
+	}
+	if current.Previous != nil {
+		return nil, fmt.Errorf("a CA rotation is already in progress; finish it with wirestack ca rotate --finish")
+	}
+	certPath, keyPath, err := CAPaths()
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Dir(certPath)
+	if err := os.Rename(keyPath, filepath.Join(root, caPreviousKeyFile)); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(certPath, filepath.Join(root, caPreviousCertFile)); err != nil {
+		return nil, err
+	}
+	if _, err := createCA(current.Cert.Subject.CommonName, validity, now); err != nil {
+		return nil, err
+	}
+	return LoadCA()
+}
+
+// FinishCARotation stops trusting the previous CA. It is a no-op when no
+// rotation is in progress.
+func FinishCARotation() error {
+	root, err := CARoot()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{caPreviousKeyFile, caPreviousCertFile} {
+		if err := os.Remove(filepath.Join(root, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// certSerial formats a certificate serial number as lowercase hex.
+func certSerial(serial *big.Int) string {
+	return serial.Text(16)
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestCARevocationAndCRL(t *testing.T) {
+	setupTempHome(t)
+	now := time.Now()
+	ca, err := InitCA("Test CA", 365*24*time.Hour, false, now)
+	if err != nil {
+		t.Fatalf("InitCA: %v", err)
+	}
+	laptop, err := ca.IssueAgent("laptop", 90*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("IssueAgent: %v", err)
+	}
+	if _, err := ca.IssueAgent("phone", 10*24*time.Hour, now); err != nil {
+		t.Fatalf("IssueAgent: %v", err)
+	}
+
+	registry, err := LoadCARegistry()
+	if err != nil {
+		t.Fatalf("LoadCARegistry: %v", err)
+	}
+	latest, ok := registry.Latest("phone")
+	if !ok || ca.CertificateState(latest, now) != CertExpiring {
+		t.Fatalf("expected phone to be due for renewal, got %+v", latest)
+	}
+	if _, err := registry.Revoke("", "", now); err == nil {
+		t.Fatalf("expected Revoke to require a name or serial")
+	}
+	if count, err := registry.Revoke("laptop", "", now); err != nil || count != 1 {
+		t.Fatalf("Revoke = %d, %v", count, err)
+	}
+	if _, err := registry.Revoke("laptop", "", now); err == nil {
+		t.Fatalf("expected revoking twice to fail")
+	}
+	if err := SaveCARegistry(registry); err != nil {
+		t.Fatalf("SaveCARegistry: %v", err)
+	}
+	registry, err = LoadCARegistry()
+	if err != nil {
+		t.Fatalf("LoadCARegistry: %v", err)
+	}
+	if !registry.IsRevoked(laptop.Cert.SerialNumber) {
+		t.Fatalf("expected the laptop certificate to be revoked")
+	}
+	if _, ok := registry.Latest("laptop"); ok {
+		t.Fatalf("expected revoked certificates to be skipped by Latest")
+	}
+
+	data, err := ca.CRL(registry, 7*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("CRL: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("expected a PEM CRL, got %q", data)
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseRevocationList: %v", err)
+	}
+	if err := crl.CheckSignatureFrom(ca.Cert); err != nil {
+		t.Fatalf("CRL signature: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Cmp(laptop.Cert.SerialNumber) != 0 {
+		t.Fatalf("unexpected CRL entries: %+v", crl.RevokedCertificateEntries)
+	}
+}
+
+func TestCARotation(t *testing.T) {
+	setupTempHome(t)
+	now := time.Now()
+	old, err := InitCA("Test CA", 365*24*time.Hour, false, now)
+	if err != nil {
+		t.Fatalf("InitCA: %v", err)
+	}
+	if _, err := old.IssueAgent("laptop", 90*24*time.Hour, now); err != nil {
+		t.Fatalf("IssueAgent: %v", err)
+	}
+
+	ca, err := RotateCA(365*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("RotateCA: %v", err)
+	}
+	if _, err := RotateCA(365*24*time.Hour, now); err == nil {
+		t.Fatalf("expected a second rotation to be refused until the first finishes")
+	}
+	if ca.Previous == nil || ca.Previous.SerialNumber.Cmp(old.Cert.SerialNumber) != 0 {
+		t.Fatalf("expected the old CA to be kept as the previous one")
+	}
+	if string(ca.TrustBundle()) != string(ca.CertPEM)+string(old.CertPEM) {
+		t.Fatalf("expected the trust bundle to contain both CAs")
+	}
+	daemon, err := ca.Issue(CertDaemon, CertDaemon, []string{"localhost"}, 24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Issue daemon: %v", err)
+	}
+	if err := daemon.Cert.CheckSignatureFrom(old.Cert); err != nil {
+		t.Fatalf("expected the daemon certificate to come from the previous CA during a rotation: %v", err)
+	}
+	agent, err := ca.IssueAgent("laptop", 90*24*time.Hour, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("IssueAgent: %v", err)
+	}
+	if err := agent.Cert.CheckSignatureFrom(ca.Cert); err != nil {
+		t.Fatalf("expected renewed agents to come from the new CA: %v", err)
+	}
+
+	registry, err := LoadCARegistry()
+	if err != nil {
+		t.Fatalf("LoadCARegistry: %v", err)
+	}
+	records := registry.Records()
+	if len(records) != 2 || ca.CertificateState(records[1], now) != CertValid {
+		t.Fatalf("expected the old agent certificate to stay valid during the rotation: %+v", records)
+	}
+
+	if err := FinishCARotation(); err != nil {
+		t.Fatalf("FinishCARotation: %v", err)
+	}
+	ca, err = LoadCA()
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+	if ca.Previous != nil {
+		t.Fatalf("expected the previous CA to be dropped")
+	}
+	if state := ca.CertificateState(records[1], now); state != CertUntrusted {
+		t.Fatalf("expected the old agent certificate to be untrusted, got %s", state)
+	}
+	if state := ca.CertificateState(records[0], now); state != CertValid {
+		t.Fatalf("expected the renewed certificate to be valid, got %s", state)
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestCertificateAuthority(t *testing.T) {
+	setupTempHome(t)
+	now := time.Now()
+	if _, err := LoadCA(); err == nil {
+		t.Fatalf("expected LoadCA to fail before ca init")
+	}
+	if _, err := InitCA("Test CA", 30*24*time.Hour, false, now); err != nil {
+		t.Fatalf("InitCA: %v", err)
+	}
+	if _, err := InitCA("Test CA", 30*24*time.Hour, false, now); err == nil {
+		t.Fatalf("expected an existing CA not to be replaced without force")
+	}
+	ca, err := LoadCA()
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+
+	agent, err := ca.Issue("laptop", CertAgent, nil, 365*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Issue agent: %v", err)
+	}
+	if agent.Cert.NotAfter.After(ca.Cert.NotAfter) {
+		t.Fatalf("expected the agent certificate to be capped at the CA lifetime")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	if _, err := agent.Cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatalf("agent certificate does not verify as a client: %v", err)
+	}
+	if _, err := agent.Cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err == nil {
+		t.Fatalf("expected an agent certificate not to be usable as a server certificate")
+	}
+
+	daemon, err := ca.Issue(CertDaemon, CertDaemon, []string{"vpn.example.com", "10.0.0.1"}, 24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Issue daemon: %v", err)
+	}
+	for _, host := range []string{"vpn.example.com", "10.0.0.1"} {
+		if _, err := daemon.Cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: host}); err != nil {
+			t.Fatalf("daemon certificate does not cover %s: %v", host, err)
+		}
+	}
+	if _, err := ca.DaemonTLSConfig(daemon); err != nil {
+		t.Fatalf("DaemonTLSConfig: %v", err)
+	}
+	if _, err := ca.Issue("x", "root", nil, time.Hour, now); err == nil {
+		t.Fatalf("expected an unknown role to be rejected")
+	}
+}
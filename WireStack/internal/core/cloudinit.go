@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cloudConfig is the subset of cloud-init's #cloud-config format
+// export-cloudinit writes.
+type cloudConfig struct {
+	PackageUpdate bool            `yaml:"package_update"`
+	Packages      []string        `yaml:"packages"`
+	WriteFiles    []cloudInitFile `yaml:"write_files"`
+	RunCmd        []string        `yaml:"runcmd"`
+}
+
+// cloudInitFile is one entry of write_files.
+type cloudInitFile struct {
+	Path        string `yaml:"path"`
+	Owner       string `yaml:"owner"`
+	Permissions string `yaml:"permissions"`
+	Content     string `yaml:"content"`
+}
+
+// BuildCloudInit renders cloud-init user-data that turns a fresh VM into the
+// server on first boot: it installs wireguard-tools (and the NAT backend's
+// tools), writes the config to /etc/wireguard, enables forwarding for good
+// in /etc/sysctl.d, and starts wg-quick@<iface> at boot.
+func BuildCloudInit(profile *ServerProfile) (string, error) {
+	config, err := hostServerConfig(profile)
+	if err != nil {
+		return "", err
+	}
+	iface := InterfaceName(profile)
+	user := cloudConfig{
+		PackageUpdate: true,
+		Packages:      hostPackages(profile),
+		WriteFiles: []cloudInitFile{
+			{Path: "/etc/wireguard/" + iface + ".conf", Owner: "root:root", Permissions: "0600", Content: config},
+			{Path: sysctlConfPath(profile), Owner: "root:root", Permissions: "0644", Content: forwardingSysctlConf(profile)},
+		},
+		RunCmd: []string{
+			"sysctl --system",
+			"systemctl enable --now wg-quick@" + iface,
+		},
+	}
+
+	builder := &bytes.Buffer{}
+	// cloud-init only treats user-data as cloud-config with this first line.
+	builder.WriteString("#cloud-config\n")
+	fmt.Fprintf(builder, "# Generated by wirestack export-cloudinit %s. It holds the server private key.\n", profile.Name)
+	encoder := yaml.NewEncoder(builder)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(user); err != nil {
+		return "", fmt.Errorf("render user-data: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("render user-data: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// hostServerConfig renders the server config for a host that runs it with
+// its distribution's wireguard-tools, as export-cloudinit and export-ansible
+// set up.
+func hostServerConfig(profile *ServerProfile) (string, error) {
+	if profile.Amnezia != nil && profile.Amnezia.NeedsServer() {
+		return "", fmt.Errorf("server %s has AmneziaWG parameters, which distribution wireguard-tools does not support", profile.Name)
+	}
+	return BuildServerConfig(profile)
+}
+
+// hostPackages are the distribution packages the server config needs.
+func hostPackages(profile *ServerProfile) []string {
+	packages := []string{"wireguard-tools"}
+	if profile.NATInterface != "" {
+		if profile.NATBackend == NATNftables {
+			packages = append(packages, "nftables")
+		} else {
+			packages = append(packages, "iptables")
+		}
+	}
+	return packages
+}
+
+// sysctlConfPath is the sysctl.d file that keeps forwarding on across reboots.
+func sysctlConfPath(profile *ServerProfile) string {
+	return "/etc/sysctl.d/99-wirestack-" + InterfaceName(profile) + ".conf"
+}
+
+// forwardingSysctlConf renders the forwarding sysctls in sysctl.d format.
+func forwardingSysctlConf(profile *ServerProfile) string {
+	var conf strings.Builder
+	for _, hook := range forwardingSysctls(profile) {
+		conf.WriteString(strings.TrimPrefix(hook, "sysctl -q -w ") + "\n")
+	}
+	return conf.String()
+}
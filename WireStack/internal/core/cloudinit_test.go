@@ -0,0 +1,46 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildCloudInit(t *testing.T) {
+	profile := DefaultServerProfile("edge", "198.51.100.7:51820", "server-priv", "server-pub")
+	profile.Subnet6 = "fd42:1::/64"
+	if err := SetNAT(profile, "ens5", NATNftables); err != nil {
+		t.Fatalf("SetNAT: %v", err)
+	}
+	userData, err := BuildCloudInit(profile)
+	if err != nil {
+		t.Fatalf("BuildCloudInit: %v", err)
+	}
+	if !strings.HasPrefix(userData, "#cloud-config\n") {
+		t.Fatalf("user-data must start with #cloud-config:\n%s", userData)
+	}
+
+	var parsed cloudConfig
+	if err := yaml.Unmarshal([]byte(userData), &parsed); err != nil {
+		t.Fatalf("user-data is not valid YAML: %v", err)
+	}
+	if strings.Join(parsed.Packages, " ") != "wireguard-tools nftables" {
+		t.Fatalf("unexpected packages %v", parsed.Packages)
+	}
+	files := map[string]cloudInitFile{}
+	for _, file := range parsed.WriteFiles {
+		files[file.Path] = file
+	}
+	config := files["/etc/wireguard/edge.conf"]
+	if config.Permissions != "0600" || !strings.Contains(config.Content, "PrivateKey = server-priv") {
+		t.Fatalf("unexpected server config entry %+v", config)
+	}
+	sysctls := files["/etc/sysctl.d/99-wirestack-edge.conf"].Content
+	if sysctls != "net.ipv4.ip_forward=1\nnet.ipv6.conf.all.forwarding=1\n" {
+		t.Fatalf("unexpected sysctls %q", sysctls)
+	}
+	if last := parsed.RunCmd[len(parsed.RunCmd)-1]; last != "systemctl enable --now wg-quick@edge" {
+		t.Fatalf("unexpected runcmd %v", parsed.RunCmd)
+	}
+}
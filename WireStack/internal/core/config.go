@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"wirestack/internal/utils"
 )
@@ -10,9 +11,19 @@ import (
 const (
 	defaultConfigDir = ".wirestack"
 	serversDir       = "servers"
+	meshesDir        = "meshes"
+	qualityDir       = "quality"
 	runtimeDir       = "runtime"
+	caDir            = "ca"
+	templatesDir     = "templates"
+	locksDir         = "locks"
+	artifactsDir     = "artifacts"
 )
 
+// staleTempAge is how old a leftover temporary file must be before
+// RecoverTempFiles treats its write as abandoned.
+const staleTempAge = time.Minute
+
 // ConfigRoot returns the base configuration directory (~/.wirestack) and ensures it exists.
 func ConfigRoot() (string, error) {
 	homePath, err := utils.ExpandPath("~/" + defaultConfigDir)
@@ -38,6 +49,46 @@ func ServersRoot() (string, error) {
 	return dir, nil
 }
 
+// MeshesRoot returns the directory used for storing mesh profiles.
+func MeshesRoot() (string, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, meshesDir)
+	if err := utils.EnsureDir(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// QualityRoot returns the directory used for connection quality history.
+func QualityRoot() (string, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, qualityDir)
+	if err := utils.EnsureDir(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// ArtifactsRoot returns the directory holding the registries of system
+// artifacts each server has left on this host.
+func ArtifactsRoot() (string, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, artifactsDir)
+	if err := utils.EnsureDir(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
 // RuntimeRoot returns the directory used for generated WireGuard config files.
 func RuntimeRoot() (string, error) {
 	root, err := ConfigRoot()
@@ -51,10 +102,29 @@ func RuntimeRoot() (string, error) {
 	return dir, nil
 }
 
+// TemplatesRoot returns the directory holding user config templates. Unlike
+// the other roots it is not created, since it only matters when users add it.
+func TemplatesRoot() (string, error) {
+	return utils.ExpandPath("~/" + filepath.Join(defaultConfigDir, templatesDir))
+}
+
+// CARoot returns the directory holding the control-plane certificate authority.
+func CARoot() (string, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, caDir)
+	if err := utils.EnsureDir(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
 // ServerProfilePath returns the expected JSON path for a server profile.
 func ServerProfilePath(name string) (string, error) {
-	if name == "" {
-		return "", fmt.Errorf("server name is empty")
+	if err := checkPathName("server", name); err != nil {
+		return "", err
 	}
 	root, err := ServersRoot()
 	if err != nil {
@@ -63,10 +133,58 @@ func ServerProfilePath(name string) (string, error) {
 	return filepath.Join(root, fmt.Sprintf("%s.json", name)), nil
 }
 
+// ServerLockPath returns the lock file guarding changes to a server profile.
+func ServerLockPath(name string) (string, error) {
+	if err := checkPathName("server", name); err != nil {
+		return "", err
+	}
+	root, err := ConfigRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, locksDir, fmt.Sprintf("%s.lock", name)), nil
+}
+
+// MeshProfilePath returns the expected JSON path for a mesh profile.
+func MeshProfilePath(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("mesh name is empty")
+	}
+	root, err := MeshesRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, fmt.Sprintf("%s.json", name)), nil
+}
+
+// QualityHistoryPath returns the JSON path holding a server's quality samples.
+func QualityHistoryPath(name string) (string, error) {
+	if err := checkPathName("server", name); err != nil {
+		return "", err
+	}
+	root, err := QualityRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, fmt.Sprintf("%s.json", name)), nil
+}
+
+// ArtifactsPath returns the JSON path of a server's artifact registry.
+func ArtifactsPath(name string) (string, error) {
+	if err := checkPathName("server", name); err != nil {
+		return "", err
+	}
+	root, err := ArtifactsRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, fmt.Sprintf("%s.json", name)), nil
+}
+
 // ServerRuntimeConfigPath returns the path where a server config file is rendered.
 func ServerRuntimeConfigPath(name string) (string, error) {
-	if name == "" {
-		return "", fmt.Errorf("server name is empty")
+	if err := checkPathName("server", name); err != nil {
+		return "", err
 	}
 	root, err := RuntimeRoot()
 	if err != nil {
@@ -75,13 +193,25 @@ func ServerRuntimeConfigPath(name string) (string, error) {
 	return filepath.Join(root, fmt.Sprintf("%s.conf", name)), nil
 }
 
+// PeerSyncStatePath returns where SyncLivePeers checkpoints an unfinished sync.
+func PeerSyncStatePath(name string) (string, error) {
+	if err := checkPathName("server", name); err != nil {
+		return "", err
+	}
+	root, err := RuntimeRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, fmt.Sprintf("%s.peer-sync.json", name)), nil
+}
+
 // ClientRuntimeConfigPath returns the path where a client config file is rendered.
 func ClientRuntimeConfigPath(serverName, clientName string) (string, error) {
-	if serverName == "" {
-		return "", fmt.Errorf("server name is empty")
+	if err := checkPathName("server", serverName); err != nil {
+		return "", err
 	}
-	if clientName == "" {
-		return "", fmt.Errorf("client name is empty")
+	if err := checkPathName("client", clientName); err != nil {
+		return "", err
 	}
 	root, err := RuntimeRoot()
 	if err != nil {
@@ -90,3 +220,14 @@ func ClientRuntimeConfigPath(serverName, clientName string) (string, error) {
 	file := fmt.Sprintf("client-%s-%s.conf", serverName, clientName)
 	return filepath.Join(root, file), nil
 }
+
+// RecoverTempFiles removes temporary files under ~/.wirestack left by writes
+// that were interrupted before their rename. The files they were replacing
+// still hold the last complete contents. It returns the paths removed.
+func RecoverTempFiles() ([]string, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return nil, err
+	}
+	return utils.RemoveStaleTempFiles(root, staleTempAge)
+}
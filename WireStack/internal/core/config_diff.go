@@ -0,0 +1,190 @@
+package core
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// SettingChange is one key whose value differs between two configs. An empty
+// side means the key is missing there. Secret values are never included.
+type SettingChange struct {
+	Key  string
+	From string
+	To   string
+}
+
+// PeerDiff describes a peer present on one side only, or on both with changes.
+// Name is the client the public key belongs to, when the profile knows it.
+type PeerDiff struct {
+	PublicKey string
+	Name      string
+	Changes   []SettingChange
+}
+
+// ConfigDiff is the semantic difference from one WireGuard config to another.
+// Peers are matched by public key, so their order in the file does not matter.
+type ConfigDiff struct {
+	Interface    []SettingChange
+	AddedPeers   []PeerDiff
+	RemovedPeers []PeerDiff
+	ChangedPeers []PeerDiff
+}
+
+// Empty reports whether the two configs are equivalent.
+func (d *ConfigDiff) Empty() bool {
+	return len(d.Interface) == 0 && len(d.AddedPeers) == 0 && len(d.RemovedPeers) == 0 && len(d.ChangedPeers) == 0
+}
+
+// secretConfigKeys are compared but never shown.
+var secretConfigKeys = map[string]bool{"privatekey": true, "presharedkey": true}
+
+// setConfigKeys hold comma separated lists whose order does not matter.
+var setConfigKeys = map[string]bool{"address": true, "allowedips": true, "dns": true}
+
+// orderedConfigKeys may repeat, and run in file order, so order matters.
+var orderedConfigKeys = map[string]bool{"preup": true, "postup": true, "predown": true, "postdown": true}
+
+// hiddenValue stands in for a secret value that is present.
+const hiddenValue = "(hidden)"
+
+// DiffConfigs compares two parsed configs, from and to. names maps public
+// keys to client names for labelling peers.
+func DiffConfigs(from, to *WGConfig, names map[string]string) *ConfigDiff {
+	diff := &ConfigDiff{Interface: diffSections(from.Interface, to.Interface, false)}
+
+	fromPeers := peersByKey(from.Peers)
+	toPeers := peersByKey(to.Peers)
+	for key, peer := range toPeers {
+		if _, ok := fromPeers[key]; !ok {
+			diff.AddedPeers = append(diff.AddedPeers, PeerDiff{PublicKey: key, Name: names[key], Changes: diffSections(ConfigSection{}, peer, true)})
+		}
+	}
+	for key, peer := range fromPeers {
+		other, ok := toPeers[key]
+		if !ok {
+			diff.RemovedPeers = append(diff.RemovedPeers, PeerDiff{PublicKey: key, Name: names[key], Changes: diffSections(peer, ConfigSection{}, true)})
+			continue
+		}
+		if changes := diffSections(peer, other, true); len(changes) > 0 {
+			diff.ChangedPeers = append(diff.ChangedPeers, PeerDiff{PublicKey: key, Name: names[key], Changes: changes})
+		}
+	}
+	for _, peers := range [][]PeerDiff{diff.AddedPeers, diff.RemovedPeers, diff.ChangedPeers} {
+		sort.Slice(peers, func(i, j int) bool {
+			if peers[i].Name != peers[j].Name {
+				return peers[i].Name < peers[j].Name
+			}
+			return peers[i].PublicKey < peers[j].PublicKey
+		})
+	}
+	return diff
+}
+
+// DiffProfileConfig compares the config rendered from profile against text,
+// such as the contents of /etc/wireguard/wg0.conf. With clientName set it
+// renders that client's config instead of the server's.
+func DiffProfileConfig(profile *ServerProfile, clientName, text string) (*ConfigDiff, error) {
+	var rendered string
+	var err error
+	if clientName == "" {
+		rendered, err = BuildServerConfig(profile)
+	} else {
+		var client *ClientProfile
+		if client, err = FindClient(profile, clientName); err != nil {
+			return nil, err
+		}
+		rendered, err = BuildClientConfig(profile, *client)
+	}
+	if err != nil {
+		return nil, err
+	}
+	from, err := ParseConfig(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("parse rendered config: %w", err)
+	}
+	to, err := ParseConfig(text)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{profile.ServerPublicKey: profile.Name}
+	for _, client := range profile.Clients {
+		names[client.PublicKey] = client.Name
+	}
+	return DiffConfigs(from, to, names), nil
+}
+
+// peersByKey indexes peer sections by public key. A repeated key keeps the
+// last section, as wg does.
+func peersByKey(peers []ConfigSection) map[string]ConfigSection {
+	byKey := make(map[string]ConfigSection, len(peers))
+	for _, peer := range peers {
+		byKey[peer.Get("PublicKey")] = peer
+	}
+	return byKey
+}
+
+// diffSections compares every key present in either section. Keys match
+// case-insensitively and are reported with the spelling first seen. PublicKey
+// is skipped in peer sections since peers are already matched on it.
+func diffSections(from, to ConfigSection, peer bool) []SettingChange {
+	var keys []string
+	spelling := map[string]string{}
+	for _, section := range []ConfigSection{from, to} {
+		for _, entry := range section.Entries {
+			lower := strings.ToLower(entry.Key)
+			if _, seen := spelling[lower]; seen || (peer && lower == "publickey") {
+				continue
+			}
+			spelling[lower] = entry.Key
+			keys = append(keys, lower)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []SettingChange
+	for _, lower := range keys {
+		key := spelling[lower]
+		fromValue, toValue := normalizedValue(from, key), normalizedValue(to, key)
+		if fromValue == toValue {
+			continue
+		}
+		if secretConfigKeys[lower] {
+			fromValue, toValue = hideSecret(fromValue), hideSecret(toValue)
+		}
+		changes = append(changes, SettingChange{Key: key, From: fromValue, To: toValue})
+	}
+	return changes
+}
+
+// normalizedValue returns the value of key in a form where equivalent
+// spellings compare equal.
+func normalizedValue(section ConfigSection, key string) string {
+	lower := strings.ToLower(key)
+	switch {
+	case setConfigKeys[lower]:
+		items := section.List(key)
+		for idx, item := range items {
+			if prefix, err := netip.ParsePrefix(item); err == nil {
+				items[idx] = prefix.String()
+			} else if addr, err := netip.ParseAddr(item); err == nil {
+				items[idx] = addr.String()
+			}
+		}
+		sort.Strings(items)
+		return strings.Join(items, ", ")
+	case orderedConfigKeys[lower]:
+		return strings.Join(section.All(key), "; ")
+	default:
+		return strings.Join(strings.Fields(section.Get(key)), " ")
+	}
+}
+
+// hideSecret replaces a present secret with a placeholder.
+func hideSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return hiddenValue
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffConfigsIgnoresOrderAndFormatting(t *testing.T) {
+	from, err := ParseConfig(`[Interface]
+Address = 10.0.0.1/24, fd00::1/64
+PrivateKey = secret-a
+ListenPort = 51820
+PostUp = iptables -A FORWARD -i %i -j ACCEPT
+PostUp = iptables -t nat -A POSTROUTING -j MASQUERADE
+
+[Peer]
+PublicKey = alice
+AllowedIPs = 10.0.0.2/32
+
+[Peer]
+PublicKey = bob
+AllowedIPs = 10.0.0.3/32
+`)
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	to, err := ParseConfig(`# edited by hand
+[interface]
+privatekey=secret-a
+address = fd00:0::1/64,10.0.0.1/24
+ListenPort =   51820
+PostUp = iptables -A FORWARD -i %i -j ACCEPT
+PostUp = iptables -t nat -A POSTROUTING -j MASQUERADE
+
+[Peer]
+PublicKey = bob
+AllowedIPs = 10.0.0.3/32
+
+[Peer]
+PublicKey = alice
+AllowedIPs = 10.0.0.2/32
+`)
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if diff := DiffConfigs(from, to, nil); !diff.Empty() {
+		t.Fatalf("expected equivalent configs, got %+v", diff)
+	}
+}
+
+func TestDiffConfigsReportsChanges(t *testing.T) {
+	from, _ := ParseConfig(`[Interface]
+PrivateKey = secret-a
+ListenPort = 51820
+PostUp = first
+PostUp = second
+
+[Peer]
+PublicKey = alice
+AllowedIPs = 10.0.0.2/32
+
+[Peer]
+PublicKey = bob
+AllowedIPs = 10.0.0.3/32
+`)
+	to, _ := ParseConfig(`[Interface]
+PrivateKey = secret-b
+ListenPort = 51821
+MTU = 1380
+PostUp = second
+PostUp = first
+
+[Peer]
+PublicKey = alice
+AllowedIPs = 10.0.0.2/32, 192.168.1.0/24
+PresharedKey = psk
+
+[Peer]
+PublicKey = carol
+AllowedIPs = 10.0.0.4/32
+`)
+	diff := DiffConfigs(from, to, map[string]string{"alice": "laptop", "bob": "phone"})
+
+	var keys []string
+	for _, change := range diff.Interface {
+		keys = append(keys, change.Key)
+		if change.Key == "PrivateKey" && (change.From != hiddenValue || change.To != hiddenValue) {
+			t.Fatalf("private keys must be hidden, got %+v", change)
+		}
+	}
+	if got := strings.Join(keys, ","); got != "ListenPort,MTU,PostUp,PrivateKey" {
+		t.Fatalf("unexpected interface changes %s", got)
+	}
+	if len(diff.AddedPeers) != 1 || diff.AddedPeers[0].PublicKey != "carol" || diff.AddedPeers[0].Name != "" {
+		t.Fatalf("expected carol added, got %+v", diff.AddedPeers)
+	}
+	if len(diff.RemovedPeers) != 1 || diff.RemovedPeers[0].Name != "phone" {
+		t.Fatalf("expected phone removed, got %+v", diff.RemovedPeers)
+	}
+	if len(diff.ChangedPeers) != 1 || diff.ChangedPeers[0].Name != "laptop" {
+		t.Fatalf("expected laptop changed, got %+v", diff.ChangedPeers)
+	}
+	changes := diff.ChangedPeers[0].Changes
+	if len(changes) != 2 || changes[0].Key != "AllowedIPs" || changes[1].Key != "PresharedKey" || changes[1].From != "" || changes[1].To != hiddenValue {
+		t.Fatalf("unexpected peer changes %+v", changes)
+	}
+}
+
+func TestDiffProfileConfigMatchesExport(t *testing.T) {
+	setupTempHome(t)
+	fakeWG(t)
+
+	profile, err := NewServerProfile(ServerOptions{Name: "office", Endpoint: "203.0.113.1:51820"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	if _, err := AddClient(profile, ClientOptions{Name: "alice"}); err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	rendered, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	diff, err := DiffProfileConfig(profile, "", rendered)
+	if err != nil || !diff.Empty() {
+		t.Fatalf("expected no differences against the export, got %+v (%v)", diff, err)
+	}
+
+	clientConfig, err := BuildClientConfig(profile, profile.Clients[0])
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	diff, err = DiffProfileConfig(profile, "alice", strings.Replace(clientConfig, "PersistentKeepalive = 25", "PersistentKeepalive = 10", 1))
+	if err != nil {
+		t.Fatalf("DiffProfileConfig: %v", err)
+	}
+	if len(diff.ChangedPeers) != 1 || diff.ChangedPeers[0].Name != "office" {
+		t.Fatalf("expected the server peer to differ, got %+v", diff)
+	}
+}
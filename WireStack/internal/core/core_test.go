@@ -1,10 +1,13 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setupTempHome(t *testing.T) string {
@@ -132,3 +135,557 @@ func expectDirPerm(path string, perm os.FileMode) error {
 	}
 	return nil
 }
+
+func TestClientExtraRendering(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.ClientExtra = "Table = off"
+	client := ClientProfile{Name: "bob", Address: "10.0.0.2/32", AllowedIPs: []string{"10.0.0.0/24"}}
+
+	cfg, err := BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	iface := strings.SplitN(cfg, "[Peer]", 2)[0]
+	if !strings.Contains(iface, "Table = off\n") {
+		t.Fatalf("server client extra missing from interface section: %s", cfg)
+	}
+
+	client.Extra = "PostUp = echo up"
+	cfg, err = BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if strings.Contains(cfg, "Table = off") || !strings.Contains(cfg, "PostUp = echo up\n") {
+		t.Fatalf("client extra did not override server default: %s", cfg)
+	}
+}
+
+func TestServerSyncConfigIsStripped(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = append(profile.Clients, ClientProfile{Name: "bob", PublicKey: "bob-pub", Address: "10.0.0.2/32"})
+
+	cfg, err := BuildServerSyncConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerSyncConfig: %v", err)
+	}
+	for _, key := range []string{"Address", "SaveConfig", "DNS"} {
+		if strings.Contains(cfg, key+" =") {
+			t.Fatalf("sync config contains wg-quick key %s: %s", key, cfg)
+		}
+	}
+	if !strings.Contains(cfg, "ListenPort = 51820\n") || !strings.Contains(cfg, "PublicKey = bob-pub\n") {
+		t.Fatalf("sync config missing interface or peer data: %s", cfg)
+	}
+}
+
+func TestRemoveClient(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{{Name: "alice"}, {Name: "bob"}, {Name: "carol"}}
+
+	removed, err := RemoveClient(profile, "bob")
+	if err != nil {
+		t.Fatalf("RemoveClient: %v", err)
+	}
+	if removed.Name != "bob" {
+		t.Fatalf("removed wrong client: %+v", removed)
+	}
+	if len(profile.Clients) != 2 || profile.Clients[0].Name != "alice" || profile.Clients[1].Name != "carol" {
+		t.Fatalf("unexpected remaining clients: %+v", profile.Clients)
+	}
+	if _, err := RemoveClient(profile, "bob"); err == nil {
+		t.Fatalf("expected error removing missing client")
+	}
+}
+
+func TestSubnetDrivesAddressAllocation(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := ApplySubnet(profile, "10.8.4.0/22"); err != nil {
+		t.Fatalf("ApplySubnet: %v", err)
+	}
+	if profile.Address != "10.8.4.1/22" || profile.Subnet != "10.8.4.0/22" {
+		t.Fatalf("unexpected server addressing: %s %s", profile.Address, profile.Subnet)
+	}
+	addr, err := NextClientAddress(profile)
+	if err != nil {
+		t.Fatalf("NextClientAddress: %v", err)
+	}
+	if addr != "10.8.4.2/32" {
+		t.Fatalf("unexpected client address %s", addr)
+	}
+
+	legacy := &ServerProfile{Address: "10.0.0.1/24"}
+	addr, err = NextClientAddress(legacy)
+	if err != nil {
+		t.Fatalf("NextClientAddress legacy: %v", err)
+	}
+	if addr != "10.0.0.2/32" {
+		t.Fatalf("legacy profile should fall back to its address network, got %s", addr)
+	}
+
+	if err := ApplySubnet(profile, "fd00::/64"); err == nil {
+		t.Fatalf("expected IPv6 subnet to be rejected")
+	}
+}
+
+func TestExternalInterfaceProfilesDoNotRenderServerConfig(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "", "iface-pub")
+	profile.ExternalInterface = "wg0"
+
+	if name := InterfaceName(profile); name != "wg0" {
+		t.Fatalf("expected external interface name, got %s", name)
+	}
+	if _, err := BuildServerConfig(profile); err == nil {
+		t.Fatalf("expected BuildServerConfig to refuse external interface profiles")
+	}
+	if _, err := BuildServerSyncConfig(profile); err == nil {
+		t.Fatalf("expected BuildServerSyncConfig to refuse external interface profiles")
+	}
+}
+
+func TestNextClientAddressReusesFreedAddresses(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{
+		{Name: "a", Address: "10.0.0.2/32"},
+		{Name: "c", Address: "10.0.0.4/32"},
+	}
+	addr, err := NextClientAddress(profile)
+	if err != nil {
+		t.Fatalf("NextClientAddress: %v", err)
+	}
+	if addr != "10.0.0.3/32" {
+		t.Fatalf("expected gap 10.0.0.3/32 to be reused, got %s", addr)
+	}
+
+	if err := ApplySubnet(profile, "192.168.7.0/30"); err != nil {
+		t.Fatalf("ApplySubnet: %v", err)
+	}
+	profile.Clients = nil
+	addr, err = NextClientAddress(profile)
+	if err != nil {
+		t.Fatalf("NextClientAddress /30: %v", err)
+	}
+	if addr != "192.168.7.2/32" {
+		t.Fatalf("unexpected /30 client address %s", addr)
+	}
+	profile.Clients = []ClientProfile{{Name: "only", Address: addr}}
+	if _, err := NextClientAddress(profile); err == nil {
+		t.Fatalf("expected /30 subnet to be exhausted")
+	}
+}
+
+func TestDualStackAllocationAndRendering(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := ApplySubnet6(profile, "fd42:1::/64"); err != nil {
+		t.Fatalf("ApplySubnet6: %v", err)
+	}
+	if profile.Address6 != "fd42:1::1/64" {
+		t.Fatalf("unexpected server IPv6 address %s", profile.Address6)
+	}
+
+	addr6, err := NextClientAddress6(profile)
+	if err != nil {
+		t.Fatalf("NextClientAddress6: %v", err)
+	}
+	if addr6 != "fd42:1::2/128" {
+		t.Fatalf("unexpected client IPv6 address %s", addr6)
+	}
+	client := ClientProfile{
+		Name:       "alice",
+		PublicKey:  "alice-pub",
+		Address:    "10.0.0.2/32",
+		Address6:   addr6,
+		AllowedIPs: ClientAllowedIPs(),
+	}
+	profile.Clients = append(profile.Clients, client)
+
+	next, err := NextClientAddress6(profile)
+	if err != nil {
+		t.Fatalf("NextClientAddress6: %v", err)
+	}
+	if next != "fd42:1::3/128" {
+		t.Fatalf("expected next IPv6 address fd42:1::3/128, got %s", next)
+	}
+
+	clientCfg, err := BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(clientCfg, "Address = 10.0.0.2/32, fd42:1::2/128\n") {
+		t.Fatalf("client config missing dual-stack address: %s", clientCfg)
+	}
+
+	serverCfg, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	if !strings.Contains(serverCfg, "Address = 10.0.0.1/24, fd42:1::1/64\n") {
+		t.Fatalf("server config missing dual-stack address: %s", serverCfg)
+	}
+	if !strings.Contains(serverCfg, "AllowedIPs = 10.0.0.2/32, fd42:1::2/128\n") {
+		t.Fatalf("server peer should route the client's own addresses: %s", serverCfg)
+	}
+
+	v4only := DefaultServerProfile("v4", "203.0.113.1:51820", "k", "p")
+	if addr, err := NextClientAddress6(v4only); err != nil || addr != "" {
+		t.Fatalf("expected no IPv6 allocation for IPv4-only server, got %q %v", addr, err)
+	}
+}
+
+func TestTagPoliciesGovernClientAllowedIPs(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetPolicy(profile, "office", []string{"172.16.0.0/12"}); err != nil {
+		t.Fatalf("SetPolicy office: %v", err)
+	}
+	if err := SetPolicy(profile, "admin", []string{"0.0.0.0/0"}); err != nil {
+		t.Fatalf("SetPolicy admin: %v", err)
+	}
+	if err := SetPolicy(profile, "bad", []string{"not-a-cidr"}); err == nil {
+		t.Fatalf("expected invalid CIDR to be rejected")
+	}
+
+	office := ClientProfile{Name: "o", Address: "10.0.0.2/32", AllowedIPs: ClientAllowedIPs(), Tags: []string{"office", "admin"}}
+	cfg, err := BuildClientConfig(profile, office)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(cfg, "AllowedIPs = 172.16.0.0/12\n") {
+		t.Fatalf("first matching policy should win: %s", cfg)
+	}
+
+	plain := ClientProfile{Name: "p", Address: "10.0.0.3/32", AllowedIPs: []string{"10.0.0.0/24"}}
+	if got := EffectiveAllowedIPs(profile, plain); len(got) != 1 || got[0] != "10.0.0.0/24" {
+		t.Fatalf("untagged client should keep its AllowedIPs, got %v", got)
+	}
+
+	if err := RemovePolicy(profile, "office"); err != nil {
+		t.Fatalf("RemovePolicy: %v", err)
+	}
+	if got := EffectiveAllowedIPs(profile, office); len(got) != 1 || got[0] != "0.0.0.0/0" {
+		t.Fatalf("expected admin policy after removing office, got %v", got)
+	}
+}
+
+func TestClientsWithTags(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{
+		{Name: "laptop", Tags: []string{"staff"}},
+		{Name: "phone", Tags: []string{"staff", "mobile"}},
+		{Name: "guest"},
+	}
+	SetClientTags(&profile.Clients[2], []string{" mobile", "", "mobile"})
+	if got := profile.Clients[2].Tags; len(got) != 1 || got[0] != "mobile" {
+		t.Fatalf("SetClientTags should drop blank and repeated tags, got %q", got)
+	}
+
+	names, err := ClientsWithTags(profile, []string{"staff"})
+	if err != nil || strings.Join(names, ",") != "laptop,phone" {
+		t.Fatalf("ClientsWithTags(staff) = %v, %v", names, err)
+	}
+	names, err = ClientsWithTags(profile, []string{"staff", "mobile"})
+	if err != nil || strings.Join(names, ",") != "phone" {
+		t.Fatalf("ClientsWithTags(staff, mobile) = %v, %v", names, err)
+	}
+	if _, err := ClientsWithTags(profile, []string{"contractor"}); err == nil {
+		t.Fatalf("expected an error when no client has the tag")
+	}
+}
+
+func TestSplitDNSRendering(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetDNSRoute(profile, "*.corp.example.", "10.0.0.53"); err != nil {
+		t.Fatalf("SetDNSRoute: %v", err)
+	}
+	if err := SetDNSRoute(profile, "lab.example", "10.0.0.54"); err != nil {
+		t.Fatalf("SetDNSRoute: %v", err)
+	}
+	for _, domain := range []string{"corp.example'; reboot; '", "a b.example", "$(id).example", "corp..example"} {
+		if err := SetDNSRoute(profile, domain, "10.0.0.53"); err == nil {
+			t.Fatalf("expected DNS route domain %q to be rejected", domain)
+		}
+	}
+	if err := SetDNSRoute(profile, "bad.example", "not-an-ip"); err == nil {
+		t.Fatalf("expected invalid DNS server to be rejected")
+	}
+	client := ClientProfile{Name: "alice", Address: "10.0.0.2/32", AllowedIPs: []string{"10.0.0.0/24"}}
+
+	cfg, err := BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if strings.Contains(cfg, "DNS =") {
+		t.Fatalf("split DNS should replace the global DNS line: %s", cfg)
+	}
+	for _, want := range []string{
+		"PostUp = resolvectl dns %i 10.0.0.53 10.0.0.54\n",
+		"PostUp = resolvectl domain %i ~corp.example ~lab.example\n",
+		"PostDown = resolvectl revert %i\n",
+	} {
+		if !strings.Contains(cfg, want) {
+			t.Fatalf("missing %q in %s", want, cfg)
+		}
+	}
+
+	profile.DNSResolver = DNSResolverDnsmasq
+	cfg, err = BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(cfg, `server=/corp.example/10.0.0.53\nserver=/lab.example/10.0.0.54\n`) {
+		t.Fatalf("dnsmasq drop-in missing: %s", cfg)
+	}
+
+	if err := RemoveDNSRoute(profile, "corp.example"); err != nil {
+		t.Fatalf("RemoveDNSRoute: %v", err)
+	}
+	if len(profile.DNSRoutes) != 1 {
+		t.Fatalf("unexpected routes after removal: %+v", profile.DNSRoutes)
+	}
+}
+
+func TestClientConfigTargets(t *testing.T) {
+	profile := DefaultServerProfile("homelab", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetDNSRoute(profile, "corp.example", "10.0.0.53"); err != nil {
+		t.Fatalf("SetDNSRoute: %v", err)
+	}
+	client := ClientProfile{Name: "alice", Address: "10.0.0.2/32", AllowedIPs: ClientAllowedIPs()}
+
+	linux, err := BuildClientConfigFor(profile, client, ClientRenderOptions{Target: TargetLinux, KillSwitch: true})
+	if err != nil {
+		t.Fatalf("linux: %v", err)
+	}
+	if !strings.Contains(linux, "PostUp = resolvectl") || !strings.Contains(linux, "iptables -I OUTPUT") {
+		t.Fatalf("linux config should keep hooks and render kill switch: %s", linux)
+	}
+
+	android, err := BuildClientConfigFor(profile, client, ClientRenderOptions{Target: TargetAndroid, KillSwitch: true})
+	if err != nil {
+		t.Fatalf("android: %v", err)
+	}
+	if strings.Contains(android, "PostUp") || strings.Contains(android, "PreDown") {
+		t.Fatalf("android config must not contain hooks: %s", android)
+	}
+	for _, want := range []string{"MTU = 1280\n", "# Split DNS is not applied", "# Kill switch: enable Always-on VPN"} {
+		if !strings.Contains(android, want) {
+			t.Fatalf("android config missing %q: %s", want, android)
+		}
+	}
+
+	if _, err := BuildClientConfigFor(profile, client, ClientRenderOptions{Target: "plan9"}); err == nil {
+		t.Fatalf("expected unsupported target error")
+	}
+
+	if name := ClientConfigFileName("homelab", "alice", TargetWindows); name != "homelab-alice.conf" {
+		t.Fatalf("unexpected windows file name %s", name)
+	}
+	if name := ClientConfigFileName("headquarters", "alice smith", TargetLinux); name != "alice-smith.conf" {
+		t.Fatalf("linux file name should fit an interface name, got %s", name)
+	}
+}
+
+// fakeWG puts a stub wg binary on PATH that can generate keys.
+func fakeWG(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$1\" in\ngenkey) head -c 32 /dev/urandom | base64 ;;\npubkey) read key; echo \"pub-$key\" ;;\n*) exit 1 ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake wg: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestNewServerProfileAndAddClient(t *testing.T) {
+	setupTempHome(t)
+	fakeWG(t)
+
+	profile, err := NewServerProfile(ServerOptions{Name: "office", Endpoint: "203.0.113.1:51820", Subnet: "10.9.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewServerProfile: %v", err)
+	}
+	if profile.Address != "10.9.0.1/24" || profile.ServerPrivateKey == "" {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	if _, err := NewServerProfile(ServerOptions{Name: "office", Endpoint: "203.0.113.1:51820"}); err == nil {
+		t.Fatalf("expected duplicate server error")
+	}
+	if _, err := NewServerProfile(ServerOptions{Name: "bad", Endpoint: "203.0.113.1:51820", Subnet: "nope"}); err == nil {
+		t.Fatalf("expected invalid subnet error")
+	}
+
+	client, err := AddClient(profile, ClientOptions{Name: "alice", Tags: []string{"staff"}})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if client.Address != "10.9.0.2/32" || client.PublicKey != "pub-"+client.PrivateKey || len(profile.Clients) != 1 {
+		t.Fatalf("unexpected client %+v", client)
+	}
+	if _, err := AddClient(profile, ClientOptions{Name: "alice"}); err == nil {
+		t.Fatalf("expected duplicate client error")
+	}
+	if _, err := FindClient(profile, "bob"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("expected ErrClientNotFound, got %v", err)
+	}
+}
+
+func TestAliasAndDescriptionRendering(t *testing.T) {
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Description = "Production\nedge"
+	profile.Alias = "wirestack:prod"
+	client := ClientProfile{Name: "alice", PublicKey: "alice-pub", Address: "10.0.0.2/32", Description: "Alice's laptop"}
+	profile.Clients = append(profile.Clients, client)
+
+	config, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	for _, want := range []string{
+		"[Interface]\n# Description: Production edge\n",
+		"PostUp = ip link set dev %i alias 'wirestack:prod'\n",
+		"[Peer]\n# Description: Alice's laptop\nPublicKey = alice-pub\n",
+	} {
+		if !strings.Contains(config, want) {
+			t.Fatalf("server config missing %q:\n%s", want, config)
+		}
+	}
+
+	sync, err := BuildServerSyncConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerSyncConfig: %v", err)
+	}
+	if strings.Contains(sync, "PostUp") {
+		t.Fatalf("sync config must not contain hooks:\n%s", sync)
+	}
+
+	clientConfig, err := BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(clientConfig, "[Peer]\n# Description: Production edge\n") {
+		t.Fatalf("client config missing server description:\n%s", clientConfig)
+	}
+
+	if err := ValidateAlias("it's"); err == nil {
+		t.Fatalf("expected quote in alias to be rejected")
+	}
+	if err := ValidateAlias(strings.Repeat("a", 256)); err == nil {
+		t.Fatalf("expected long alias to be rejected")
+	}
+}
+
+func TestRotateAndRollbackKeys(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{{Name: "alice", PrivateKey: "alice-priv", PublicKey: "alice-pub", Address: "10.0.0.2/32"}}
+
+	if err := RotateServerKey(profile); err != nil {
+		t.Fatalf("RotateServerKey: %v", err)
+	}
+	if profile.ServerPrivateKey == "server-priv" || len(profile.KeyHistory) != 1 || profile.KeyHistory[0].PublicKey != "server-pub" {
+		t.Fatalf("unexpected server keys after rotation: %+v", profile)
+	}
+	if err := RollbackServerKey(profile); err != nil {
+		t.Fatalf("RollbackServerKey: %v", err)
+	}
+	if profile.ServerPrivateKey != "server-priv" || len(profile.KeyHistory) != 0 {
+		t.Fatalf("rollback did not restore the server key: %+v", profile)
+	}
+	if err := RollbackServerKey(profile); err == nil {
+		t.Fatalf("expected rollback without history to fail")
+	}
+
+	for i := 0; i < maxKeyHistory+2; i++ {
+		retired, err := RotateClientKey(profile, "alice")
+		if err != nil {
+			t.Fatalf("RotateClientKey: %v", err)
+		}
+		if i == 0 && retired != "alice-pub" {
+			t.Fatalf("expected retired key alice-pub, got %s", retired)
+		}
+	}
+	alice := profile.Clients[0]
+	if len(alice.KeyHistory) != maxKeyHistory {
+		t.Fatalf("key history should be capped at %d, got %d", maxKeyHistory, len(alice.KeyHistory))
+	}
+	replaced, err := RollbackClientKey(profile, "alice")
+	if err != nil {
+		t.Fatalf("RollbackClientKey: %v", err)
+	}
+	if replaced != alice.PublicKey || profile.Clients[0].PublicKey != alice.KeyHistory[0].PublicKey {
+		t.Fatalf("rollback did not restore the previous client key")
+	}
+
+	profile.ExternalInterface = "wg0"
+	if err := RotateServerKey(profile); err == nil {
+		t.Fatalf("expected external interface rotation to be refused")
+	}
+}
+
+func TestClientExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Time{
+		"2025-07-01":           time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		"2025-07-01T08:00:00Z": time.Date(2025, 7, 1, 8, 0, 0, 0, time.UTC),
+		"30d":                  now.Add(30 * 24 * time.Hour),
+		"2h":                   now.Add(2 * time.Hour),
+	} {
+		got, err := ParseExpiry(value, now)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("ParseExpiry(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "tomorrow", "-1h", "0d"} {
+		if _, err := ParseExpiry(value, now); err == nil {
+			t.Fatalf("expected ParseExpiry(%q) to fail", value)
+		}
+	}
+
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "priv", "pub")
+	profile.Clients = []ClientProfile{
+		{Name: "expired", PublicKey: "expired-pub", Address: "10.0.0.2/32", ExpiresAt: &past},
+		{Name: "valid", PublicKey: "valid-pub", Address: "10.0.0.3/32", ExpiresAt: &future},
+		{Name: "forever", PublicKey: "forever-pub", Address: "10.0.0.4/32"},
+	}
+	expired := ExpiredClients(profile, now)
+	if len(expired) != 1 || expired[0].Name != "expired" {
+		t.Fatalf("unexpected expired clients %+v", expired)
+	}
+
+	if err := DisableClient(profile, "expired"); err != nil {
+		t.Fatalf("DisableClient: %v", err)
+	}
+	if len(ExpiredClients(profile, now)) != 0 {
+		t.Fatalf("disabled clients should not be reported again")
+	}
+	config, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	if strings.Contains(config, "expired-pub") || !strings.Contains(config, "valid-pub") {
+		t.Fatalf("disabled client should be left out of the server config:\n%s", config)
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	for _, name := range []string{"home", "lab-1", "a_b=c+d.e", "fifteen-chars-x"} {
+		if err := ValidateServerName(name); err != nil {
+			t.Errorf("ValidateServerName(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../x", "a/b", "a b", "sixteen-chars-xx"} {
+		if err := ValidateClientName(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("ValidateClientName(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+	for _, name := range []string{"../../x", "..", `a\b`} {
+		if _, err := ClientRuntimeConfigPath("home", name); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("expected the runtime path helper to reject %q, got %v", name, err)
+		}
+	}
+	// Path helpers accept long names that existing profiles may have.
+	if _, err := ServerProfilePath("sixteen-chars-xx"); err != nil {
+		t.Fatalf("ServerProfilePath: %v", err)
+	}
+}
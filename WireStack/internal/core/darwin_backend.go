@@ -0,0 +1,340 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// wireguardRunDir is where wireguard-go keeps its control sockets and where
+// wg(8) looks up the utun device behind an interface name; tests replace it.
+var wireguardRunDir = "/var/run/wireguard"
+
+// darwinState is what the darwin backend must undo when the tunnel goes down.
+type darwinState struct {
+	// Interface is the utun device wireguard-go created.
+	Interface string `json:"interface"`
+	// EndpointRoutes keep traffic to the endpoints off a full tunnel, as
+	// "-inet 203.0.113.1" style route arguments.
+	EndpointRoutes []string `json:"endpoint_routes,omitempty"`
+	// DNS holds each network service's resolvers before the tunnel replaced them.
+	DNS []darwinServiceDNS `json:"dns,omitempty"`
+}
+
+// darwinServiceDNS is the DNS setup of one macOS network service.
+type darwinServiceDNS struct {
+	Service       string   `json:"service"`
+	Servers       []string `json:"servers,omitempty"`
+	SearchDomains []string `json:"search_domains,omitempty"`
+}
+
+// darwinBackend runs tunnels on macOS with wireguard-go and the tools macOS
+// ships, without wg-quick, whose script needs a newer bash than macOS has.
+type darwinBackend struct{}
+
+// Up starts wireguard-go on a new utun device, loads keys and peers with
+// `wg setconf`, then assigns addresses with ifconfig, routes with route(8),
+// and DNS with networksetup, the way wg-quick does on macOS.
+func (darwinBackend) Up(configPath string) (string, error) {
+	config, iface, err := loadConfigFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	if UsesAmnezia(config) {
+		return "", fmt.Errorf("%s has AmneziaWG parameters, which the darwin backend does not support", configPath)
+	}
+
+	if err := runHooks(config.Interface.All("PreUp"), iface); err != nil {
+		return "", err
+	}
+	nameFile := filepath.Join(wireguardRunDir, iface+".name")
+	// wireguard-go reports the utun device the kernel picked in the name file.
+	if _, err := utils.RunCommand("env", "WG_TUN_NAME_FILE="+nameFile, "wireguard-go", "utun"); err != nil {
+		return "", err
+	}
+	state := &darwinState{Interface: "utun"}
+	if !utils.DryRun() {
+		data, err := os.ReadFile(nameFile)
+		if err != nil {
+			return "", fmt.Errorf("wireguard-go did not report its interface: %w", err)
+		}
+		state.Interface = strings.TrimSpace(string(data))
+	}
+	statePath := darwinStatePath(configPath)
+	if err := utils.WriteJSON(statePath, state, 0o600); err != nil {
+		darwinTeardown(state, iface)
+		return "", err
+	}
+	if err := darwinConfigure(configPath, config, state); err != nil {
+		darwinTeardown(state, iface)
+		_ = os.Remove(statePath)
+		return "", err
+	}
+	if err := utils.WriteJSON(statePath, state, 0o600); err != nil {
+		return "", err
+	}
+	if err := runHooks(config.Interface.All("PostUp"), state.Interface); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("interface %s up (%s)", iface, state.Interface), nil
+}
+
+// Down restores DNS, removes the endpoint routes, and stops wireguard-go by
+// removing its control socket, which also removes the utun device.
+func (darwinBackend) Down(configPath string) (string, error) {
+	config, iface, err := loadConfigFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	statePath := darwinStatePath(configPath)
+	state := &darwinState{}
+	if err := utils.ReadJSON(statePath, state); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("interface %s is not up (no state in %s)", iface, statePath)
+		}
+		return "", err
+	}
+
+	if err := runHooks(config.Interface.All("PreDown"), state.Interface); err != nil {
+		return "", err
+	}
+	darwinTeardown(state, iface)
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := runHooks(config.Interface.All("PostDown"), state.Interface); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("interface %s down", iface), nil
+}
+
+// darwinStatePath returns where the darwin backend keeps a tunnel's state.
+func darwinStatePath(configPath string) string {
+	return strings.TrimSuffix(configPath, ".conf") + ".darwin.json"
+}
+
+// darwinConfigure applies everything after wireguard-go created the device,
+// recording in state what Down has to undo.
+func darwinConfigure(configPath string, config *WGConfig, state *darwinState) error {
+	utun := state.Interface
+	strippedPath := configPath + ".stripped"
+	if err := utils.WriteFile(strippedPath, []byte(StripConfig(config)), 0o600); err != nil {
+		return err
+	}
+	_, err := utils.RunCommand("wg", "setconf", utun, strippedPath)
+	_ = os.Remove(strippedPath)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range config.Interface.List("Address") {
+		args := []string{utun, "inet6", address, "alias"}
+		if !strings.Contains(address, ":") {
+			args = []string{utun, "inet", address, strings.SplitN(address, "/", 2)[0], "alias"}
+		}
+		if _, err := utils.RunCommand("ifconfig", args...); err != nil {
+			return err
+		}
+	}
+	if mtu := config.Interface.Get("MTU"); mtu != "" {
+		if _, err := utils.RunCommand("ifconfig", utun, "mtu", mtu); err != nil {
+			return err
+		}
+	}
+	if _, err := utils.RunCommand("ifconfig", utun, "up"); err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(config.Interface.Get("Table"), "off") {
+		for _, peer := range config.Peers {
+			for _, cidr := range peer.List("AllowedIPs") {
+				if err := darwinAddRoute(utun, cidr); err != nil {
+					return err
+				}
+			}
+		}
+		if usesDefaultRoute(config) {
+			if err := darwinRouteEndpoints(state); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dns := config.Interface.List("DNS"); len(dns) > 0 {
+		return darwinApplyDNS(state, dns)
+	}
+	return nil
+}
+
+// darwinAddRoute routes a peer's AllowedIPs through the utun device. A /0 is
+// split into two /1 routes, which win over the default route without
+// replacing it.
+func darwinAddRoute(utun, cidr string) error {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid AllowedIPs entry %s: %w", cidr, err)
+	}
+	family, halves := "-inet", []string{"0.0.0.0/1", "128.0.0.0/1"}
+	if network.IP.To4() == nil {
+		family, halves = "-inet6", []string{"::/1", "8000::/1"}
+	}
+	routes := []string{network.String()}
+	if ones, _ := network.Mask.Size(); ones == 0 {
+		routes = halves
+	}
+	for _, route := range routes {
+		if _, err := utils.RunCommand("route", "-q", "-n", "add", family, route, "-interface", utun); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// darwinRouteEndpoints keeps the encrypted traffic to each peer on the current
+// default gateway, so a full tunnel does not route it into itself.
+func darwinRouteEndpoints(state *darwinState) error {
+	output, err := utils.RunCommand("wg", "show", state.Interface, "endpoints")
+	if err != nil {
+		return err
+	}
+	gateways := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		host, _, err := net.SplitHostPort(fields[1])
+		ip := net.ParseIP(host)
+		if err != nil || ip == nil {
+			continue
+		}
+		family := "-inet"
+		if ip.To4() == nil {
+			family = "-inet6"
+		}
+		gateway, ok := gateways[family]
+		if !ok {
+			gateway = darwinDefaultGateway(family)
+			gateways[family] = gateway
+		}
+		if gateway == "" {
+			continue
+		}
+		if _, err := utils.RunCommand("route", "-q", "-n", "add", family, host, "-gateway", gateway); err != nil {
+			return err
+		}
+		state.EndpointRoutes = append(state.EndpointRoutes, family+" "+host)
+	}
+	return nil
+}
+
+// darwinDefaultGateway returns the gateway of the family's default route, or
+// "" when there is none.
+func darwinDefaultGateway(family string) string {
+	output, err := utils.RunCommand("route", "-n", "get", family, "default")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && key == "gateway" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// darwinApplyDNS points every enabled network service at the tunnel's
+// resolvers and search domains, saving the previous settings in state.
+func darwinApplyDNS(state *darwinState, dns []string) error {
+	var servers, domains []string
+	for _, entry := range dns {
+		if net.ParseIP(entry) != nil {
+			servers = append(servers, entry)
+		} else {
+			domains = append(domains, entry)
+		}
+	}
+	services, err := darwinNetworkServices()
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		saved := darwinServiceDNS{Service: service}
+		if saved.Servers, err = darwinServiceList("-getdnsservers", service); err != nil {
+			return err
+		}
+		if saved.SearchDomains, err = darwinServiceList("-getsearchdomains", service); err != nil {
+			return err
+		}
+		state.DNS = append(state.DNS, saved)
+		if err := darwinSetServiceDNS(service, servers, domains); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// darwinNetworkServices lists the enabled network services, such as Wi-Fi.
+func darwinNetworkServices() ([]string, error) {
+	output, err := utils.RunCommand("networksetup", "-listallnetworkservices")
+	if err != nil {
+		return nil, err
+	}
+	var services []string
+	for idx, line := range strings.Split(output, "\n") {
+		// The first line explains that disabled services start with "*".
+		if idx == 0 || line == "" || strings.HasPrefix(line, "*") {
+			continue
+		}
+		services = append(services, line)
+	}
+	return services, nil
+}
+
+// darwinServiceList reads a service's DNS servers or search domains;
+// networksetup prints a sentence instead when none are set.
+func darwinServiceList(flag, service string) ([]string, error) {
+	output, err := utils.RunCommand("networksetup", flag, service)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(output, "There aren't any") {
+		return nil, nil
+	}
+	return strings.Fields(output), nil
+}
+
+// darwinSetServiceDNS replaces a service's DNS servers and search domains;
+// empty lists go back to the ones DHCP provides.
+func darwinSetServiceDNS(service string, servers, domains []string) error {
+	if len(servers) == 0 {
+		servers = []string{"Empty"}
+	}
+	if len(domains) == 0 {
+		domains = []string{"Empty"}
+	}
+	if _, err := utils.RunCommand("networksetup", append([]string{"-setdnsservers", service}, servers...)...); err != nil {
+		return err
+	}
+	_, err := utils.RunCommand("networksetup", append([]string{"-setsearchdomains", service}, domains...)...)
+	return err
+}
+
+// darwinTeardown undoes what state records and stops wireguard-go. It keeps
+// going after errors so one failure does not leave the rest behind.
+func darwinTeardown(state *darwinState, iface string) {
+	for _, saved := range state.DNS {
+		_ = darwinSetServiceDNS(saved.Service, saved.Servers, saved.SearchDomains)
+	}
+	for _, route := range state.EndpointRoutes {
+		args := append([]string{"-q", "-n", "delete"}, strings.Fields(route)...)
+		_, _ = utils.RunCommand("route", args...)
+	}
+	_, _ = utils.RunCommand("rm", "-f", filepath.Join(wireguardRunDir, state.Interface+".sock"), filepath.Join(wireguardRunDir, iface+".name"))
+}
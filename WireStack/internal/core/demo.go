@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// DemoServerName is the server profile created by SeedDemo.
+const DemoServerName = "demo"
+
+// demoClients are the example clients SeedDemo adds, covering the common modes.
+var demoClients = []ClientOptions{
+	{Name: "laptop", Tags: []string{"staff"}, Description: "Full tunnel laptop"},
+	{Name: "phone", Tags: []string{"staff", "mobile"}, Description: "Split tunnel phone", Mode: ClientModeSplit},
+	{Name: "contractor", Tags: []string{"guest"}, Description: "Temporary access"},
+}
+
+// SeedDemo saves an example server with a few clients to the current store,
+// which the demo command points at a throwaway MemoryStore. The contractor
+// client expires a week after now so expiry commands have something to show.
+func SeedDemo(now time.Time) (*ServerProfile, error) {
+	profile, err := NewServerProfile(ServerOptions{
+		Name:        DemoServerName,
+		Endpoint:    "203.0.113.10:51820",
+		Subnet6:     "fd00:d3::/64",
+		Description: "Demo server",
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, opts := range demoClients {
+		if opts.Name == "contractor" {
+			expires := now.Add(7 * 24 * time.Hour).UTC()
+			opts.ExpiresAt = &expires
+		}
+		if _, err := AddClient(profile, opts); err != nil {
+			return nil, fmt.Errorf("demo client %s: %w", opts.Name, err)
+		}
+	}
+	if err := SaveServerProfile(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
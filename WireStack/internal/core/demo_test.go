@@ -0,0 +1,67 @@
+package core
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestSeedDemo(t *testing.T) {
+	setupTempHome(t)
+	useMemoryStore(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, err := SeedDemo(now); err != nil {
+		t.Fatalf("SeedDemo: %v", err)
+	}
+	profile, err := LoadServerProfile(DemoServerName)
+	if err != nil {
+		t.Fatalf("LoadServerProfile: %v", err)
+	}
+	if len(profile.Clients) != len(demoClients) {
+		t.Fatalf("expected %d clients, got %d", len(demoClients), len(profile.Clients))
+	}
+	seen := map[string]bool{}
+	for _, client := range profile.Clients {
+		if seen[client.Address] {
+			t.Fatalf("address %s allocated twice", client.Address)
+		}
+		seen[client.Address] = true
+		if client.Address6 == "" {
+			t.Fatalf("client %s has no IPv6 address", client.Name)
+		}
+	}
+	contractor, err := FindClient(profile, "contractor")
+	if err != nil {
+		t.Fatalf("FindClient: %v", err)
+	}
+	if contractor.ExpiresAt == nil || !contractor.ExpiresAt.After(now) {
+		t.Fatalf("contractor should expire after now, got %v", contractor.ExpiresAt)
+	}
+	if _, err := SeedDemo(now); err == nil {
+		t.Fatalf("seeding twice should fail")
+	}
+}
+
+func TestGenerateKeyPairNative(t *testing.T) {
+	private, public, err := generateKeyPairNative()
+	if err != nil {
+		t.Fatalf("generateKeyPairNative: %v", err)
+	}
+	privateBytes, err := base64.StdEncoding.DecodeString(private)
+	if err != nil || len(privateBytes) != curve25519.ScalarSize {
+		t.Fatalf("private key %q is not a base64 curve25519 key (%v)", private, err)
+	}
+	if privateBytes[0]&7 != 0 || privateBytes[31]&128 != 0 || privateBytes[31]&64 == 0 {
+		t.Fatalf("private key is not clamped")
+	}
+	expected, err := curve25519.X25519(privateBytes, curve25519.Basepoint)
+	if err != nil {
+		t.Fatalf("X25519: %v", err)
+	}
+	if public != base64.StdEncoding.EncodeToString(expected) {
+		t.Fatalf("public key does not match the private key")
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DNSResolverResolved routes domains with systemd-resolved (resolvectl).
+	DNSResolverResolved = "resolved"
+	// DNSResolverDnsmasq routes domains with a dnsmasq drop-in file.
+	DNSResolverDnsmasq = "dnsmasq"
+)
+
+// DNSRoute sends queries for Domain (and its subdomains) to Server through the tunnel.
+type DNSRoute struct {
+	Domain string `json:"domain"`
+	Server string `json:"server"`
+}
+
+// NormalizeDomain strips wildcard prefixes and trailing dots from a routing domain.
+func NormalizeDomain(domain string) string {
+	domain = strings.TrimSpace(strings.ToLower(domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	domain = strings.TrimPrefix(domain, "~")
+	return strings.TrimSuffix(domain, ".")
+}
+
+// searchDomainName matches a DNS name of letters, digits, and hyphens per
+// label. Search domains and DNS route domains must match it.
+var searchDomainName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+// SetSearchDomains replaces the search domains appended to the DNS line of
+// client configs, so short names like "intranet" resolve as intranet.corp.example.
+// An empty list removes them.
+func SetSearchDomains(profile *ServerProfile, domains []string) error {
+	var normalized []string
+	for _, domain := range domains {
+		name := strings.TrimSuffix(strings.TrimSpace(strings.ToLower(domain)), ".")
+		if len(name) > 253 || !searchDomainName.MatchString(name) || net.ParseIP(name) != nil {
+			return fmt.Errorf("invalid search domain %q", domain)
+		}
+		if !containsString(normalized, name) {
+			normalized = append(normalized, name)
+		}
+	}
+	profile.SearchDomains = normalized
+	return nil
+}
+
+// SetDNSRoute adds or replaces the route for a domain.
+func SetDNSRoute(profile *ServerProfile, domain, server string) error {
+	name := NormalizeDomain(domain)
+	if name == "" {
+		return fmt.Errorf("DNS route domain is empty")
+	}
+	// The domain ends up in shell hooks that run as root on the client.
+	if len(name) > 253 || !searchDomainName.MatchString(name) {
+		return fmt.Errorf("invalid DNS route domain %q", domain)
+	}
+	domain = name
+	if net.ParseIP(server) == nil {
+		return fmt.Errorf("DNS server %q is not an IP address", server)
+	}
+	for idx := range profile.DNSRoutes {
+		if profile.DNSRoutes[idx].Domain == domain {
+			profile.DNSRoutes[idx].Server = server
+			return nil
+		}
+	}
+	profile.DNSRoutes = append(profile.DNSRoutes, DNSRoute{Domain: domain, Server: server})
+	return nil
+}
+
+// RemoveDNSRoute deletes the route for a domain.
+func RemoveDNSRoute(profile *ServerProfile, domain string) error {
+	domain = NormalizeDomain(domain)
+	for idx := range profile.DNSRoutes {
+		if profile.DNSRoutes[idx].Domain == domain {
+			profile.DNSRoutes = append(profile.DNSRoutes[:idx], profile.DNSRoutes[idx+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no DNS route for %s", domain)
+}
+
+// DNSRouteHooks renders the PostUp/PostDown commands that install the profile's
+// DNS routes on a Linux client. It returns nil slices when no routes are configured.
+func DNSRouteHooks(profile *ServerProfile) (postUp, postDown []string) {
+	if len(profile.DNSRoutes) == 0 {
+		return nil, nil
+	}
+	if profile.DNSResolver == DNSResolverDnsmasq {
+		var lines []string
+		for _, route := range profile.DNSRoutes {
+			lines = append(lines, fmt.Sprintf("server=/%s/%s", route.Domain, route.Server))
+		}
+		dropIn := "/etc/dnsmasq.d/wirestack-%i.conf"
+		postUp = []string{
+			fmt.Sprintf("printf '%s\\n' > %s", strings.Join(lines, "\\n"), dropIn),
+			"systemctl restart dnsmasq",
+		}
+		postDown = []string{
+			"rm -f " + dropIn,
+			"systemctl restart dnsmasq",
+		}
+		return postUp, postDown
+	}
+
+	// systemd-resolved has one server list per link; every routed domain uses all of them.
+	var servers, domains []string
+	seen := map[string]bool{}
+	for _, route := range profile.DNSRoutes {
+		if !seen[route.Server] {
+			servers = append(servers, route.Server)
+			seen[route.Server] = true
+		}
+		domains = append(domains, "~"+route.Domain)
+	}
+	postUp = []string{
+		"resolvectl dns %i " + strings.Join(servers, " "),
+		"resolvectl domain %i " + strings.Join(domains, " "),
+	}
+	postDown = []string{"resolvectl revert %i"}
+	return postUp, postDown
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wirestack/internal/utils"
+)
+
+// Container images export-docker can target.
+const (
+	// DockerLinuxServer runs the config with the linuxserver/wireguard image,
+	// which brings up every config in /config/wg_confs.
+	DockerLinuxServer = "linuxserver"
+	// DockerNative runs wg-quick directly in a minimal Alpine image.
+	DockerNative = "native"
+)
+
+// Default images for each flavor; DockerOptions.Image overrides them.
+const (
+	defaultLinuxServerImage = "lscr.io/linuxserver/wireguard:latest"
+	defaultNativeImage      = "alpine:3.19"
+)
+
+// dockerComposeFile is the file name docker compose looks for by default.
+const dockerComposeFile = "docker-compose.yml"
+
+// DockerOptions configures BuildDockerCompose.
+type DockerOptions struct {
+	// Flavor is DockerLinuxServer (the default) or DockerNative.
+	Flavor string
+	// Image replaces the flavor's default image.
+	Image string
+}
+
+// DockerExport lists the files ExportDocker wrote.
+type DockerExport struct {
+	ComposePath string
+	ConfigPath  string
+}
+
+// composeFile is the subset of the compose file format export-docker writes.
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+// composeService is one container of a compose file.
+type composeService struct {
+	Image         string   `yaml:"image"`
+	ContainerName string   `yaml:"container_name"`
+	CapAdd        []string `yaml:"cap_add"`
+	Sysctls       []string `yaml:"sysctls"`
+	Environment   []string `yaml:"environment,omitempty"`
+	Ports         []string `yaml:"ports"`
+	Volumes       []string `yaml:"volumes"`
+	Command       []string `yaml:"command,omitempty"`
+	Restart       string   `yaml:"restart"`
+}
+
+// BuildDockerCompose renders a compose file that runs the server in a
+// container, and the server config it mounts from ./wg_confs. Forwarding is
+// enabled with compose sysctls instead of the config's sysctl hooks, since
+// /proc/sys is read-only inside a container.
+func BuildDockerCompose(profile *ServerProfile, options DockerOptions) (string, string, error) {
+	config, port, err := containerServerConfig(profile)
+	if err != nil {
+		return "", "", err
+	}
+	if err := checkDockerFlavor(options.Flavor); err != nil {
+		return "", "", err
+	}
+
+	iface := InterfaceName(profile)
+	service := composeService{
+		Image:         containerImage(options),
+		ContainerName: "wirestack-" + profile.Name,
+		CapAdd:        []string{"NET_ADMIN"},
+		Sysctls:       containerSysctls(profile),
+		Ports:         []string{port + ":" + port + "/udp"},
+		Volumes:       []string{"./wg_confs/" + iface + ".conf:" + containerConfigDir(options.Flavor) + "/" + iface + ".conf:ro"},
+		Command:       containerCommand(profile, options.Flavor),
+		Restart:       "unless-stopped",
+	}
+	if options.Flavor != DockerNative {
+		service.Environment = []string{"TZ=Etc/UTC"}
+	}
+
+	builder := &bytes.Buffer{}
+	fmt.Fprintf(builder, "# Generated by wirestack export-docker %s.\n", profile.Name)
+	encoder := yaml.NewEncoder(builder)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(composeFile{Services: map[string]composeService{iface: service}}); err != nil {
+		return "", "", fmt.Errorf("render compose file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", "", fmt.Errorf("render compose file: %w", err)
+	}
+	return builder.String(), config, nil
+}
+
+// containerServerConfig renders the server config for a container and returns
+// it with the listen port. Forwarding sysctl hooks are dropped: /proc/sys is
+// read-only inside a container, so the container setup enables forwarding.
+func containerServerConfig(profile *ServerProfile) (string, string, error) {
+	if profile.Amnezia != nil && profile.Amnezia.NeedsServer() {
+		return "", "", fmt.Errorf("server %s has AmneziaWG parameters, which the container images do not support", profile.Name)
+	}
+	config, err := BuildServerConfig(profile)
+	if err != nil {
+		return "", "", err
+	}
+	for _, hook := range forwardingSysctls(profile) {
+		config = strings.ReplaceAll(config, "PostUp = "+hook+"\n", "")
+	}
+	_, port, err := net.SplitHostPort(profile.Endpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
+	}
+	return config, port, nil
+}
+
+// checkDockerFlavor rejects unknown container flavors.
+func checkDockerFlavor(flavor string) error {
+	switch flavor {
+	case "", DockerLinuxServer, DockerNative:
+		return nil
+	}
+	return fmt.Errorf("unsupported docker flavor %q (want %s or %s)", flavor, DockerLinuxServer, DockerNative)
+}
+
+// containerSysctls are the sysctls a server container needs.
+func containerSysctls(profile *ServerProfile) []string {
+	sysctls := []string{"net.ipv4.conf.all.src_valid_mark=1", "net.ipv4.ip_forward=1"}
+	if profile.Subnet6 != "" {
+		sysctls = append(sysctls, "net.ipv6.conf.all.disable_ipv6=0", "net.ipv6.conf.all.forwarding=1")
+	}
+	return sysctls
+}
+
+// containerImage returns the image for the flavor unless options name one.
+func containerImage(options DockerOptions) string {
+	switch {
+	case options.Image != "":
+		return options.Image
+	case options.Flavor == DockerNative:
+		return defaultNativeImage
+	default:
+		return defaultLinuxServerImage
+	}
+}
+
+// containerConfigDir is where the flavor's image expects the server config.
+func containerConfigDir(flavor string) string {
+	if flavor == DockerNative {
+		return "/etc/wireguard"
+	}
+	return "/config/wg_confs"
+}
+
+// containerCommand returns the command of a native container, which installs
+// wireguard-tools and runs wg-quick, or nil for images with their own.
+func containerCommand(profile *ServerProfile, flavor string) []string {
+	if flavor != DockerNative {
+		return nil
+	}
+	iface := InterfaceName(profile)
+	packages := "wireguard-tools iptables ip6tables"
+	if profile.NATBackend == NATNftables {
+		packages += " nftables"
+	}
+	// Bring the tunnel down cleanly on stop, which sends SIGTERM.
+	return []string{"sh", "-c", fmt.Sprintf("set -e; apk add --no-cache %s >/dev/null; wg-quick up %s; trap 'wg-quick down %s; exit 0' TERM INT; sleep infinity & wait", packages, iface, iface)}
+}
+
+// ExportDocker writes docker-compose.yml and wg_confs/<iface>.conf into dir,
+// ready for `docker compose up -d` there.
+func ExportDocker(profile *ServerProfile, dir string, options DockerOptions) (*DockerExport, error) {
+	compose, config, err := BuildDockerCompose(profile, options)
+	if err != nil {
+		return nil, err
+	}
+	export := &DockerExport{
+		ComposePath: filepath.Join(dir, dockerComposeFile),
+		ConfigPath:  filepath.Join(dir, "wg_confs", InterfaceName(profile)+".conf"),
+	}
+	if err := utils.WriteFile(export.ConfigPath, []byte(config), 0o600); err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFile(export.ComposePath, []byte(compose), 0o644); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportDocker(t *testing.T) {
+	setupTempHome(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51821", "server-priv", "server-pub")
+	profile.Subnet6 = "fd42:1::/64"
+	if err := SetNAT(profile, "eth0", NATIptables); err != nil {
+		t.Fatalf("SetNAT: %v", err)
+	}
+
+	dir := t.TempDir()
+	export, err := ExportDocker(profile, dir, DockerOptions{})
+	if err != nil {
+		t.Fatalf("ExportDocker: %v", err)
+	}
+	compose, err := os.ReadFile(export.ComposePath)
+	if err != nil {
+		t.Fatalf("read compose file: %v", err)
+	}
+	for _, want := range []string{
+		"image: " + defaultLinuxServerImage,
+		"- NET_ADMIN",
+		"- net.ipv4.ip_forward=1",
+		"- net.ipv6.conf.all.forwarding=1",
+		"- 51821:51821/udp",
+		"- ./wg_confs/prod.conf:/config/wg_confs/prod.conf:ro",
+	} {
+		if !strings.Contains(string(compose), want) {
+			t.Fatalf("compose file misses %q:\n%s", want, compose)
+		}
+	}
+	config, err := os.ReadFile(filepath.Join(dir, "wg_confs", "prod.conf"))
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if strings.Contains(string(config), "sysctl") || !strings.Contains(string(config), "MASQUERADE") {
+		t.Fatalf("expected NAT rules without sysctl hooks:\n%s", config)
+	}
+
+	compose2, _, err := BuildDockerCompose(profile, DockerOptions{Flavor: DockerNative, Image: "example/wg:1"})
+	if err != nil {
+		t.Fatalf("BuildDockerCompose native: %v", err)
+	}
+	if !strings.Contains(compose2, "image: example/wg:1") || !strings.Contains(compose2, "/etc/wireguard/prod.conf:ro") || !strings.Contains(compose2, "wg-quick up prod") {
+		t.Fatalf("unexpected native compose file:\n%s", compose2)
+	}
+	if _, _, err := BuildDockerCompose(profile, DockerOptions{Flavor: "podman"}); err == nil {
+		t.Fatalf("expected an unknown flavor to be rejected")
+	}
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// downloadKeyFile holds the HMAC key download tokens are signed with.
+const downloadKeyFile = "download-token.key"
+
+// MaxDownloadTokenTTL caps how long a config download token stays valid.
+const MaxDownloadTokenTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrDownloadTokenInvalid is returned for malformed or forged download tokens.
+	ErrDownloadTokenInvalid = errors.New("download token is invalid")
+	// ErrDownloadTokenExpired is returned for download tokens past their expiry.
+	ErrDownloadTokenExpired = errors.New("download token has expired")
+)
+
+// DownloadClaims scope a download token to one client config. PublicKey
+// pins the token to the client's current key, so rotating the key revokes
+// every outstanding token for that client.
+type DownloadClaims struct {
+	Server     string `json:"s"`
+	Client     string `json:"c"`
+	PublicKey  string `json:"k"`
+	Target     string `json:"t,omitempty"`
+	KillSwitch bool   `json:"ks,omitempty"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+// DownloadSigningKey returns the installation's token signing key, creating
+// it on first use. Deleting the key file revokes every issued token.
+func DownloadSigningKey() ([]byte, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return nil, err
+	}
+	// Concurrent first uses must agree on one key, or tokens signed with the
+	// losing key would never verify.
+	unlock, err := lockStateFile("download-token")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	path := filepath.Join(root, downloadKeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 32 {
+			return nil, fmt.Errorf("download token key %s is corrupt; delete it to generate a new one", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read download token key: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// IssueDownloadToken signs a token for the client that expires after ttl.
+func IssueDownloadToken(key []byte, profile *ServerProfile, client ClientProfile, options ClientRenderOptions, ttl time.Duration, now time.Time) (string, DownloadClaims, error) {
+	if ttl <= 0 || ttl > MaxDownloadTokenTTL {
+		return "", DownloadClaims{}, fmt.Errorf("token lifetime must be positive and at most %s", MaxDownloadTokenTTL)
+	}
+	if options.Target == "" {
+		options.Target = TargetLinux
+	}
+	claims := DownloadClaims{
+		Server:     profile.Name,
+		Client:     client.Name,
+		PublicKey:  client.PublicKey,
+		Target:     options.Target,
+		KillSwitch: options.KillSwitch,
+		ExpiresAt:  now.Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", DownloadClaims{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signDownloadPayload(key, encoded), claims, nil
+}
+
+// VerifyDownloadToken checks a token's signature and expiry and returns its claims.
+func VerifyDownloadToken(key []byte, token string, now time.Time) (*DownloadClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signDownloadPayload(key, encoded))) {
+		return nil, ErrDownloadTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrDownloadTokenInvalid
+	}
+	var claims DownloadClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrDownloadTokenInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrDownloadTokenExpired
+	}
+	return &claims, nil
+}
+
+// RenderDownload loads the client a token refers to and renders its config.
+// Tokens for deleted, disabled, or re-keyed clients are rejected.
+func RenderDownload(claims *DownloadClaims) (string, error) {
+	profile, err := LoadServerProfile(claims.Server)
+	if err != nil {
+		return "", err
+	}
+	client, err := FindClient(profile, claims.Client)
+	if err != nil {
+		return "", err
+	}
+	if client.PublicKey != claims.PublicKey || client.Disabled {
+		return "", ErrDownloadTokenInvalid
+	}
+	return BuildClientConfigFor(profile, *client, ClientRenderOptions{Target: claims.Target, KillSwitch: claims.KillSwitch})
+}
+
+// signDownloadPayload returns the base64url HMAC-SHA256 of an encoded payload.
+func signDownloadPayload(key []byte, encoded string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadTokens(t *testing.T) {
+	setupTempHome(t)
+	fakeWG(t)
+
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{{Name: "alice", PrivateKey: "alice-priv", PublicKey: "alice-pub", Address: "10.0.0.2/32"}}
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+
+	key, err := DownloadSigningKey()
+	if err != nil {
+		t.Fatalf("DownloadSigningKey: %v", err)
+	}
+	again, err := DownloadSigningKey()
+	if err != nil || string(again) != string(key) {
+		t.Fatalf("expected the signing key to persist: %v", err)
+	}
+
+	now := time.Now()
+	if _, _, err := IssueDownloadToken(key, profile, profile.Clients[0], ClientRenderOptions{}, MaxDownloadTokenTTL+time.Second, now); err == nil {
+		t.Fatalf("expected overly long ttl to be rejected")
+	}
+	token, claims, err := IssueDownloadToken(key, profile, profile.Clients[0], ClientRenderOptions{}, time.Hour, now)
+	if err != nil {
+		t.Fatalf("IssueDownloadToken: %v", err)
+	}
+	if claims.Target != TargetLinux {
+		t.Fatalf("expected default target, got %q", claims.Target)
+	}
+
+	verified, err := VerifyDownloadToken(key, token, now)
+	if err != nil {
+		t.Fatalf("VerifyDownloadToken: %v", err)
+	}
+	config, err := RenderDownload(verified)
+	if err != nil || !strings.Contains(config, "PrivateKey = alice-priv") {
+		t.Fatalf("RenderDownload: %v\n%s", err, config)
+	}
+
+	if _, err := VerifyDownloadToken(key, token, now.Add(2*time.Hour)); !errors.Is(err, ErrDownloadTokenExpired) {
+		t.Fatalf("expected expired token, got %v", err)
+	}
+	if _, err := VerifyDownloadToken([]byte("another key that is 32 bytes...."), token, now); !errors.Is(err, ErrDownloadTokenInvalid) {
+		t.Fatalf("expected token signed with another key to be rejected, got %v", err)
+	}
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := strings.Replace(payload, payload[:4], "AAAA", 1) + "." + signature
+	if _, err := VerifyDownloadToken(key, forged, now); !errors.Is(err, ErrDownloadTokenInvalid) {
+		t.Fatalf("expected tampered token to be rejected, got %v", err)
+	}
+
+	if _, err := RotateClientKey(profile, "alice"); err != nil {
+		t.Fatalf("RotateClientKey: %v", err)
+	}
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	if _, err := RenderDownload(verified); !errors.Is(err, ErrDownloadTokenInvalid) {
+		t.Fatalf("expected token for a re-keyed client to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"wirestack/internal/utils"
+)
+
+// lookupHost resolves endpoint host names; tests replace it.
+var lookupHost = func(ctx context.Context, host string) ([]string, error) {
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+// EndpointUpdate is the result of one RefreshPeerEndpoint check.
+type EndpointUpdate struct {
+	// Previous is the peer's endpoint on the running interface, if any.
+	Previous string
+	// Current is the endpoint the peer uses after the check.
+	Current string
+	Changed bool
+}
+
+// EndpointHost returns the host of a host:port endpoint, or an error when
+// the endpoint is an IP address and there is nothing to re-resolve.
+func EndpointHost(endpoint string) (string, error) {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %s: %w", endpoint, err)
+	}
+	if net.ParseIP(host) != nil {
+		return "", fmt.Errorf("endpoint %s is an IP address; only host names need re-resolving", endpoint)
+	}
+	return host, nil
+}
+
+// RefreshPeerEndpoint re-resolves endpoint, a host:port, and points the
+// peer with publicKey on iface at the new address with `wg set` when its
+// current address is no longer among those the name resolves to. Keeping an
+// address that still resolves avoids flapping between round-robin records.
+func RefreshPeerEndpoint(ctx context.Context, iface, publicKey, endpoint string) (EndpointUpdate, error) {
+	host, err := EndpointHost(endpoint)
+	if err != nil {
+		return EndpointUpdate{}, err
+	}
+	_, port, _ := net.SplitHostPort(endpoint)
+
+	status, err := ReadInterfaceStatus(iface)
+	if err != nil {
+		return EndpointUpdate{}, fmt.Errorf("read interface %s: %w", iface, err)
+	}
+	update := EndpointUpdate{}
+	found := false
+	for _, peer := range status.Peers {
+		if peer.PublicKey == publicKey {
+			update.Previous, found = peer.Endpoint, true
+			break
+		}
+	}
+	if !found {
+		return EndpointUpdate{}, fmt.Errorf("interface %s has no peer %s", iface, publicKey)
+	}
+	update.Current = update.Previous
+
+	addresses, err := lookupHost(ctx, host)
+	if err != nil {
+		return update, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	if len(addresses) == 0 {
+		return update, fmt.Errorf("resolve %s: no addresses", host)
+	}
+	if previousHost, previousPort, err := net.SplitHostPort(update.Previous); err == nil && previousPort == port {
+		for _, address := range addresses {
+			if ip := net.ParseIP(address); ip != nil && ip.Equal(net.ParseIP(previousHost)) {
+				return update, nil
+			}
+		}
+	}
+
+	next := net.JoinHostPort(addresses[0], port)
+	if _, err := utils.RunCommand("wg", "set", iface, "peer", publicKey, "endpoint", next); err != nil {
+		return update, err
+	}
+	update.Current, update.Changed = next, true
+	return update, nil
+}
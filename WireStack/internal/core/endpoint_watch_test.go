@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// endpointWG installs a fake wg whose dump reports the server peer at
+// endpoint with the given latest handshake (zero for none), and whose `set`
+// logs its arguments and moves the peer.
+func endpointWG(t *testing.T, endpoint string, handshake time.Time) (logPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	endpointPath := filepath.Join(dir, "endpoint")
+	logPath = filepath.Join(dir, "wg.log")
+	var seconds int64
+	if !handshake.IsZero() {
+		seconds = handshake.Unix()
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+case "$1" in
+show) printf 'priv\tpub\t0\toff\nserver-pub\t(none)\t%%s\t0.0.0.0/0\t%[3]d\t0\t0\t25\n' "$(cat %[1]s)" ;;
+set) echo "$*" >> %[2]s; echo "$6" > %[1]s ;;
+*) exit 1 ;;
+esac
+`, endpointPath, logPath, seconds)
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake wg: %v", err)
+	}
+	if err := os.WriteFile(endpointPath, []byte(endpoint+"\n"), 0o600); err != nil {
+		t.Fatalf("write endpoint: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logPath
+}
+
+func TestRefreshPeerEndpoint(t *testing.T) {
+	logPath := endpointWG(t, "198.51.100.7:51820", time.Time{})
+	resolved := []string{"198.51.100.9", "198.51.100.7"}
+	original := lookupHost
+	t.Cleanup(func() { lookupHost = original })
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host != "vpn.example.com" {
+			return nil, fmt.Errorf("unexpected host %s", host)
+		}
+		return resolved, nil
+	}
+
+	update, err := RefreshPeerEndpoint(context.Background(), "wg-client", "server-pub", "vpn.example.com:51820")
+	if err != nil || update.Changed {
+		t.Fatalf("expected an endpoint that still resolves to be kept, got %+v (%v)", update, err)
+	}
+
+	resolved = []string{"203.0.113.4"}
+	update, err = RefreshPeerEndpoint(context.Background(), "wg-client", "server-pub", "vpn.example.com:51820")
+	if err != nil {
+		t.Fatalf("RefreshPeerEndpoint: %v", err)
+	}
+	if !update.Changed || update.Previous != "198.51.100.7:51820" || update.Current != "203.0.113.4:51820" {
+		t.Fatalf("unexpected update %+v", update)
+	}
+	if log := readLog(t, logPath); len(log) != 1 || log[0] != "set wg-client peer server-pub endpoint 203.0.113.4:51820" {
+		t.Fatalf("unexpected wg calls %v", log)
+	}
+	if update, err = RefreshPeerEndpoint(context.Background(), "wg-client", "server-pub", "vpn.example.com:51820"); err != nil || update.Changed {
+		t.Fatalf("expected no change once the peer follows the name, got %+v (%v)", update, err)
+	}
+
+	if _, err := RefreshPeerEndpoint(context.Background(), "wg-client", "other-pub", "vpn.example.com:51820"); err == nil || !strings.Contains(err.Error(), "no peer") {
+		t.Fatalf("expected a missing peer to be reported, got %v", err)
+	}
+	if _, err := EndpointHost("203.0.113.4:51820"); err == nil {
+		t.Fatalf("expected an IP endpoint to be rejected")
+	}
+}
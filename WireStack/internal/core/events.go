@@ -0,0 +1,162 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Event types reported by DiffSnapshots.
+const (
+	EventServerAdded   = "server_added"
+	EventServerRemoved = "server_removed"
+	EventConfigChanged = "config_changed"
+	EventClientAdded   = "client_added"
+	EventClientRemoved = "client_removed"
+	EventClientChanged = "client_changed"
+	EventPeerOnline    = "peer_online"
+	EventPeerOffline   = "peer_offline"
+	EventInterfaceUp   = "interface_up"
+	EventInterfaceDown = "interface_down"
+)
+
+// PeerOnlineWindow is how recent a handshake must be for a peer to count as
+// online. WireGuard re-handshakes every two minutes while traffic flows.
+const PeerOnlineWindow = 3 * time.Minute
+
+// Event is a change between two snapshots of the stored and running state.
+type Event struct {
+	Type   string    `json:"type"`
+	Server string    `json:"server"`
+	Client string    `json:"client,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Snapshot records fingerprints of every profile and which peers are online,
+// so two snapshots can be compared without keeping whole profiles around.
+type Snapshot struct {
+	Servers map[string]ServerSnapshot
+}
+
+// ServerSnapshot is the per-server part of a Snapshot.
+type ServerSnapshot struct {
+	Fingerprint string
+	Clients     map[string]string
+	Online      map[string]bool
+	// Up is set when the server's interface was running.
+	Up bool
+}
+
+// TakeSnapshot fingerprints profiles and marks clients whose latest handshake
+// in statuses (keyed by server name) is within PeerOnlineWindow of now.
+func TakeSnapshot(profiles []*ServerProfile, statuses map[string]*InterfaceStatus, now time.Time) Snapshot {
+	snapshot := Snapshot{Servers: map[string]ServerSnapshot{}}
+	for _, profile := range profiles {
+		server := *profile
+		server.Clients = nil
+		entry := ServerSnapshot{Fingerprint: fingerprint(server), Clients: map[string]string{}, Online: map[string]bool{}}
+		for _, client := range profile.Clients {
+			entry.Clients[client.Name] = fingerprint(client)
+		}
+		if status := statuses[profile.Name]; status != nil {
+			entry.Up = true
+			for _, match := range MatchClients(profile, status) {
+				if match.ClientName != "" && !match.Peer.LatestHandshake.IsZero() && now.Sub(match.Peer.LatestHandshake) <= PeerOnlineWindow {
+					entry.Online[match.ClientName] = true
+				}
+			}
+		}
+		snapshot.Servers[profile.Name] = entry
+	}
+	return snapshot
+}
+
+// DiffSnapshots returns the events that turn before into after, ordered by
+// server and client name.
+func DiffSnapshots(before, after Snapshot, now time.Time) []Event {
+	var events []Event
+	emit := func(kind, server, client string) {
+		events = append(events, Event{Type: kind, Server: server, Client: client, Time: now})
+	}
+
+	for _, name := range snapshotServerNames(before, after) {
+		old, hadOld := before.Servers[name]
+		current, hasCurrent := after.Servers[name]
+		switch {
+		case !hadOld:
+			emit(EventServerAdded, name, "")
+		case !hasCurrent:
+			emit(EventServerRemoved, name, "")
+			continue
+		case old.Fingerprint != current.Fingerprint:
+			emit(EventConfigChanged, name, "")
+		}
+		switch {
+		case current.Up && !old.Up:
+			emit(EventInterfaceUp, name, "")
+		case old.Up && !current.Up:
+			emit(EventInterfaceDown, name, "")
+		}
+
+		for _, client := range sortedKeys(old.Clients, current.Clients) {
+			oldPrint, hadClient := old.Clients[client]
+			newPrint, hasClient := current.Clients[client]
+			switch {
+			case !hadClient:
+				emit(EventClientAdded, name, client)
+			case !hasClient:
+				emit(EventClientRemoved, name, client)
+			case oldPrint != newPrint:
+				emit(EventClientChanged, name, client)
+			}
+			switch {
+			case current.Online[client] && !old.Online[client]:
+				emit(EventPeerOnline, name, client)
+			case old.Online[client] && !current.Online[client] && hasClient:
+				emit(EventPeerOffline, name, client)
+			}
+		}
+	}
+	return events
+}
+
+// fingerprint hashes the JSON encoding of v.
+func fingerprint(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// snapshotServerNames returns the union of server names in both snapshots, sorted.
+func snapshotServerNames(before, after Snapshot) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, snapshot := range []Snapshot{before, after} {
+		for name := range snapshot.Servers {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedKeys returns the union of the maps' keys, sorted.
+func sortedKeys(maps ...map[string]string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
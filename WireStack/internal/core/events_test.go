@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	profile := DefaultServerProfile("lab", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{
+		{Name: "alice", PublicKey: "alice-pub", Address: "10.8.0.2/32"},
+		{Name: "bob", PublicKey: "bob-pub", Address: "10.8.0.3/32"},
+	}
+	status := &InterfaceStatus{Peers: []PeerStatus{
+		{PublicKey: "alice-pub", LatestHandshake: now.Add(-30 * time.Second)},
+		{PublicKey: "bob-pub", LatestHandshake: now.Add(-10 * time.Minute)},
+	}}
+
+	before := TakeSnapshot(nil, nil, now)
+	first := TakeSnapshot([]*ServerProfile{profile}, map[string]*InterfaceStatus{"lab": status}, now)
+	assertEvents(t, DiffSnapshots(before, first, now), []string{
+		EventServerAdded + " lab",
+		EventInterfaceUp + " lab",
+		EventClientAdded + " lab/alice",
+		EventPeerOnline + " lab/alice",
+		EventClientAdded + " lab/bob",
+	})
+
+	changed := *profile
+	changed.Endpoint = "198.51.100.1:51820"
+	changed.Clients = []ClientProfile{
+		{Name: "bob", PublicKey: "bob-pub", Address: "10.8.0.3/32", Disabled: true},
+		{Name: "carol", PublicKey: "carol-pub", Address: "10.8.0.4/32"},
+	}
+	status.Peers[1].LatestHandshake = now.Add(-time.Second)
+	second := TakeSnapshot([]*ServerProfile{&changed}, map[string]*InterfaceStatus{"lab": status}, now)
+	assertEvents(t, DiffSnapshots(first, second, now), []string{
+		EventConfigChanged + " lab",
+		EventClientRemoved + " lab/alice",
+		EventClientChanged + " lab/bob",
+		EventPeerOnline + " lab/bob",
+		EventClientAdded + " lab/carol",
+	})
+
+	assertEvents(t, DiffSnapshots(second, TakeSnapshot([]*ServerProfile{&changed}, nil, now), now), []string{
+		EventInterfaceDown + " lab",
+		EventPeerOffline + " lab/bob",
+	})
+	assertEvents(t, DiffSnapshots(second, before, now), []string{EventServerRemoved + " lab"})
+}
+
+func assertEvents(t *testing.T, events []Event, want []string) {
+	t.Helper()
+	got := make([]string, 0, len(events))
+	for _, event := range events {
+		entry := event.Type + " " + event.Server
+		if event.Client != "" {
+			entry += "/" + event.Client
+		}
+		got = append(got, entry)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for idx := range want {
+		if got[idx] != want[idx] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+}
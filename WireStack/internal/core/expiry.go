@@ -0,0 +1,59 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryWarningWindow is how far ahead list-clients warns about upcoming expiry.
+const ExpiryWarningWindow = 7 * 24 * time.Hour
+
+// ParseExpiry accepts an RFC 3339 timestamp, a YYYY-MM-DD date (expiring at the
+// start of that day, UTC), or a duration from now such as 720h or 30d.
+func ParseExpiry(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at.UTC(), nil
+	}
+	if at, err := time.Parse("2006-01-02", value); err == nil {
+		return at.UTC(), nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if count, err := strconv.Atoi(days); err == nil && count > 0 {
+			return now.Add(time.Duration(count) * 24 * time.Hour).UTC(), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return now.Add(duration).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q (want RFC 3339 time, YYYY-MM-DD, or a duration like 720h or 30d)", value)
+}
+
+// ClientExpired reports whether the client has an expiry at or before now.
+func ClientExpired(client ClientProfile, now time.Time) bool {
+	return client.ExpiresAt != nil && !client.ExpiresAt.After(now)
+}
+
+// ExpiredClients returns the enabled clients of a profile whose expiry has passed.
+func ExpiredClients(profile *ServerProfile, now time.Time) []ClientProfile {
+	var expired []ClientProfile
+	for _, client := range profile.Clients {
+		if !client.Disabled && ClientExpired(client, now) {
+			expired = append(expired, client)
+		}
+	}
+	return expired
+}
+
+// DisableClient keeps a client in the profile but leaves it out of rendered
+// server configs and live peer syncs.
+func DisableClient(profile *ServerProfile, clientName string) error {
+	client, err := FindClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	client.Disabled = true
+	return nil
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+
+	"wirestack/internal/utils"
+)
+
+// exportQRSize is the width and height in pixels of exported QR codes.
+const exportQRSize = 512
+
+// ExportOptions configures ExportClients.
+type ExportOptions struct {
+	Render ClientRenderOptions
+	// QR also writes a PNG QR code of each config for mobile clients.
+	QR              bool
+	IncludeDisabled bool
+}
+
+// ExportedClient is one client config written by ExportClients.
+type ExportedClient struct {
+	Client     string
+	ConfigPath string
+	// QRPath is empty unless ExportOptions.QR is set.
+	QRPath string
+}
+
+// ExportClients renders every client of the server into dir, one .conf per
+// client named as by ClientConfigFileName. Names that collide after being
+// shortened for the target get a numeric suffix. Disabled clients are skipped
+// unless options.IncludeDisabled is set.
+func ExportClients(profile *ServerProfile, dir string, options ExportOptions) ([]ExportedClient, error) {
+	if err := utils.EnsureDir(dir); err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	exported := []ExportedClient{}
+	for _, client := range profile.Clients {
+		if client.Disabled && !options.IncludeDisabled {
+			continue
+		}
+		stem := uniqueFileStem(ClientConfigFileName(profile.Name, client.Name, options.Render.Target), options.Render.Target, taken)
+		entry, err := exportClientFile(profile, client, dir, stem, options)
+		if err != nil {
+			return nil, err
+		}
+		exported = append(exported, entry)
+	}
+	return exported, nil
+}
+
+// exportClientFile writes one client's config, and its QR code when asked
+// for, to dir under the file stem.
+func exportClientFile(profile *ServerProfile, client ClientProfile, dir, stem string, options ExportOptions) (ExportedClient, error) {
+	config, err := BuildClientConfigFor(profile, client, options.Render)
+	if err != nil {
+		return ExportedClient{}, fmt.Errorf("client %s: %w", client.Name, err)
+	}
+	entry := ExportedClient{Client: client.Name, ConfigPath: filepath.Join(dir, stem+".conf")}
+	if err := utils.WriteFile(entry.ConfigPath, []byte(config), 0o600); err != nil {
+		return ExportedClient{}, err
+	}
+	if options.QR {
+		png, err := qrcode.Encode(config, qrcode.Medium, exportQRSize)
+		if err != nil {
+			return ExportedClient{}, fmt.Errorf("client %s: QR code: %w", client.Name, err)
+		}
+		entry.QRPath = filepath.Join(dir, stem+".png")
+		if err := utils.WriteFile(entry.QRPath, png, 0o600); err != nil {
+			return ExportedClient{}, err
+		}
+	}
+	return entry, nil
+}
+
+// uniqueFileStem strips the extension from fileName and, if the stem is
+// already taken, appends -2, -3, ..., shortening the stem so the result stays
+// within the target's tunnel name limit.
+func uniqueFileStem(fileName, target string, taken map[string]bool) string {
+	stem := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	limit := targetNameLimit(target)
+	candidate := stem
+	for n := 2; taken[strings.ToLower(candidate)]; n++ {
+		suffix := fmt.Sprintf("-%d", n)
+		base := stem
+		if len(base)+len(suffix) > limit {
+			base = base[:limit-len(suffix)]
+		}
+		candidate = base + suffix
+	}
+	// Compare case-insensitively for case-insensitive file systems.
+	taken[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportClients(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	profile := DefaultServerProfile("office", "203.0.113.1:51820", "server-priv", "server-pub")
+	for _, name := range []string{"alice", "sales laptop 01", "sales laptop 02", "old"} {
+		profile.Clients = append(profile.Clients, ClientProfile{
+			Name:       name,
+			PrivateKey: name + "-priv",
+			PublicKey:  name + "-pub",
+			Address:    "10.0.0.2/32",
+			Disabled:   name == "old",
+		})
+	}
+
+	exported, err := ExportClients(profile, dir, ExportOptions{QR: true})
+	if err != nil {
+		t.Fatalf("ExportClients: %v", err)
+	}
+	if len(exported) != 3 {
+		t.Fatalf("expected the disabled client to be skipped, got %+v", exported)
+	}
+	seen := map[string]bool{}
+	for _, entry := range exported {
+		name := filepath.Base(entry.ConfigPath)
+		if seen[name] || len(strings.TrimSuffix(name, ".conf")) > 15 || strings.Contains(name, " ") {
+			t.Fatalf("unsafe or duplicate file name %q", name)
+		}
+		seen[name] = true
+		config, err := os.ReadFile(entry.ConfigPath)
+		if err != nil || !strings.Contains(string(config), entry.Client+"-priv") {
+			t.Fatalf("config for %s: %v", entry.Client, err)
+		}
+		png, err := os.ReadFile(entry.QRPath)
+		if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+			t.Fatalf("QR code for %s: %v", entry.Client, err)
+		}
+	}
+	if filepath.Base(exported[0].ConfigPath) != "office-alice.conf" {
+		t.Fatalf("unexpected name %s", exported[0].ConfigPath)
+	}
+
+	exported, err = ExportClients(profile, dir, ExportOptions{IncludeDisabled: true})
+	if err != nil {
+		t.Fatalf("ExportClients: %v", err)
+	}
+	if len(exported) != 4 || exported[3].QRPath != "" {
+		t.Fatalf("expected every client without QR codes, got %+v", exported)
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// DefaultFailoverAfter is how long a client may go without a handshake before
+// its server endpoint counts as unreachable.
+const DefaultFailoverAfter = PeerOnlineWindow
+
+// SetAlternateEndpoints replaces the endpoints clients fall back to when the
+// primary one is unreachable, such as a DNS name next to an IP address or a
+// second port. An empty list removes them.
+func SetAlternateEndpoints(profile *ServerProfile, endpoints []string) error {
+	var normalized []string
+	for _, endpoint := range endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil || host == "" || port == "" {
+			return fmt.Errorf("invalid alternate endpoint %q (want host:port)", endpoint)
+		}
+		if endpoint == profile.Endpoint {
+			return fmt.Errorf("alternate endpoint %s is the primary endpoint", endpoint)
+		}
+		if !containsString(normalized, endpoint) {
+			normalized = append(normalized, endpoint)
+		}
+	}
+	profile.AlternateEndpoints = normalized
+	return nil
+}
+
+// ServerEndpoints returns the primary endpoint followed by the alternates,
+// the order failover tries them in.
+func ServerEndpoints(profile *ServerProfile) []string {
+	return append([]string{profile.Endpoint}, profile.AlternateEndpoints...)
+}
+
+// FailoverUpdate is the result of one FailoverPeerEndpoint check.
+type FailoverUpdate struct {
+	// Previous is the peer's endpoint on the running interface, if any.
+	Previous string
+	// Current is the endpoint the peer uses after the check.
+	Current string
+	// LatestHandshake is zero when the peer has never completed one.
+	LatestHandshake time.Time
+	Changed         bool
+}
+
+// FailoverPeerEndpoint points the peer with publicKey on iface at the next of
+// endpoints, in order and wrapping around, with `wg set` when its latest
+// handshake is older than after. switchedAt is when the previous failover
+// happened, if any; the new endpoint gets the same time to complete a
+// handshake before it is given up on too.
+func FailoverPeerEndpoint(ctx context.Context, iface, publicKey string, endpoints []string, after time.Duration, now, switchedAt time.Time) (FailoverUpdate, error) {
+	if len(endpoints) < 2 {
+		return FailoverUpdate{}, fmt.Errorf("no alternate endpoint to fail over to")
+	}
+	status, err := ReadInterfaceStatus(iface)
+	if err != nil {
+		return FailoverUpdate{}, fmt.Errorf("read interface %s: %w", iface, err)
+	}
+	update := FailoverUpdate{}
+	found := false
+	for _, peer := range status.Peers {
+		if peer.PublicKey == publicKey {
+			update.Previous, update.LatestHandshake, found = peer.Endpoint, peer.LatestHandshake, true
+			break
+		}
+	}
+	if !found {
+		return FailoverUpdate{}, fmt.Errorf("interface %s has no peer %s", iface, publicKey)
+	}
+	update.Current = update.Previous
+	if !update.LatestHandshake.IsZero() && now.Sub(update.LatestHandshake) <= after {
+		return update, nil
+	}
+	if !switchedAt.IsZero() && now.Sub(switchedAt) <= after {
+		return update, nil
+	}
+
+	next := endpoints[0]
+	if idx := endpointIndex(ctx, endpoints, update.Previous); idx >= 0 {
+		next = endpoints[(idx+1)%len(endpoints)]
+	}
+	// wg resolves a host name itself when the endpoint is set.
+	if _, err := utils.RunCommand("wg", "set", iface, "peer", publicKey, "endpoint", next); err != nil {
+		return update, err
+	}
+	update.Current, update.Changed = next, true
+	return update, nil
+}
+
+// endpointIndex returns which of endpoints the running peer address current
+// belongs to, resolving host names, or -1.
+func endpointIndex(ctx context.Context, endpoints []string, current string) int {
+	currentHost, currentPort, err := net.SplitHostPort(current)
+	if err != nil {
+		return -1
+	}
+	currentIP := net.ParseIP(currentHost)
+	for idx, endpoint := range endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil || port != currentPort {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if ip.Equal(currentIP) {
+				return idx
+			}
+			continue
+		}
+		addresses, err := lookupHost(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			if ip := net.ParseIP(address); ip != nil && ip.Equal(currentIP) {
+				return idx
+			}
+		}
+	}
+	return -1
+}
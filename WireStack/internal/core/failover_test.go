@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetAlternateEndpoints(t *testing.T) {
+	profile := DefaultServerProfile("edge", "198.51.100.7:51820", "priv", "pub")
+	if err := SetAlternateEndpoints(profile, []string{"vpn.example.com:51820", "198.51.100.7:443", "vpn.example.com:51820"}); err != nil {
+		t.Fatalf("SetAlternateEndpoints: %v", err)
+	}
+	if got := strings.Join(ServerEndpoints(profile), ","); got != "198.51.100.7:51820,vpn.example.com:51820,198.51.100.7:443" {
+		t.Fatalf("unexpected endpoints %s", got)
+	}
+	for _, bad := range []string{"vpn.example.com", ":51820", "198.51.100.7:51820"} {
+		if err := SetAlternateEndpoints(profile, []string{bad}); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+	if err := SetAlternateEndpoints(profile, nil); err != nil || profile.AlternateEndpoints != nil {
+		t.Fatalf("expected alternates to be removed, got %v (%v)", profile.AlternateEndpoints, err)
+	}
+}
+
+func TestFailoverPeerEndpoint(t *testing.T) {
+	original := lookupHost
+	t.Cleanup(func() { lookupHost = original })
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host != "vpn.example.com" {
+			return nil, fmt.Errorf("unexpected host %s", host)
+		}
+		return []string{"203.0.113.4"}, nil
+	}
+	endpoints := []string{"198.51.100.7:51820", "vpn.example.com:51820", "198.51.100.7:443"}
+	now := time.Unix(1_700_000_000, 0)
+
+	endpointWG(t, "198.51.100.7:51820", now.Add(-time.Minute))
+	update, err := FailoverPeerEndpoint(context.Background(), "wg-client", "server-pub", endpoints, 3*time.Minute, now, time.Time{})
+	if err != nil || update.Changed {
+		t.Fatalf("expected a recent handshake to keep the endpoint, got %+v (%v)", update, err)
+	}
+
+	// A resolved host name is matched back to its endpoint, so the next one is tried.
+	logPath := endpointWG(t, "203.0.113.4:51820", now.Add(-10*time.Minute))
+	update, err = FailoverPeerEndpoint(context.Background(), "wg-client", "server-pub", endpoints, 3*time.Minute, now, time.Time{})
+	if err != nil {
+		t.Fatalf("FailoverPeerEndpoint: %v", err)
+	}
+	if !update.Changed || update.Previous != "203.0.113.4:51820" || update.Current != "198.51.100.7:443" {
+		t.Fatalf("unexpected update %+v", update)
+	}
+	if got := strings.Join(readLog(t, logPath), "\n"); !strings.Contains(got, "set wg-client peer server-pub endpoint 198.51.100.7:443") {
+		t.Fatalf("unexpected wg calls:\n%s", got)
+	}
+
+	// The new endpoint gets time to complete a handshake.
+	update, err = FailoverPeerEndpoint(context.Background(), "wg-client", "server-pub", endpoints, 3*time.Minute, now.Add(time.Minute), now)
+	if err != nil || update.Changed {
+		t.Fatalf("expected the new endpoint to be given time, got %+v (%v)", update, err)
+	}
+
+	// After the last alternate, failover wraps back to the primary.
+	update, err = FailoverPeerEndpoint(context.Background(), "wg-client", "server-pub", endpoints, 3*time.Minute, now.Add(5*time.Minute), now)
+	if err != nil || !update.Changed || update.Current != "198.51.100.7:51820" {
+		t.Fatalf("expected a wrap to the primary, got %+v (%v)", update, err)
+	}
+
+	if _, err := FailoverPeerEndpoint(context.Background(), "wg-client", "server-pub", endpoints[:1], 3*time.Minute, now, time.Time{}); err == nil {
+		t.Fatalf("expected an error without alternates")
+	}
+}
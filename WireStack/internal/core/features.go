@@ -0,0 +1,121 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// FeaturesEnv overrides feature flags for one process, e.g.
+// WIRESTACK_FEATURES="native-backend,-some-feature". A leading "-" disables.
+const FeaturesEnv = "WIRESTACK_FEATURES"
+
+// Feature names.
+const (
+	// FeatureNativeBackend gates the --backend native interface backend.
+	FeatureNativeBackend = "native-backend"
+	// FeatureConfigTemplates gates user templates in TemplatesRoot.
+	FeatureConfigTemplates = "config-templates"
+)
+
+// Feature is a flag that gates a subsystem per installation.
+type Feature struct {
+	Name        string
+	Description string
+	// Default applies when neither the environment nor the settings file set the flag.
+	Default bool
+}
+
+// features lists every known flag. New subsystems start with Default false
+// and flip to true once they are considered stable.
+var features = []Feature{
+	{Name: FeatureConfigTemplates, Description: "Render configs from templates in ~/.wirestack/templates"},
+	{Name: FeatureNativeBackend, Description: "Bring interfaces up with ip(8) and wg(8) instead of wg-quick (--backend native)"},
+}
+
+// FeatureState is a feature together with its effective value and where that value came from.
+type FeatureState struct {
+	Feature
+	Enabled bool
+	// Source is "default", "settings", or "env".
+	Source string
+}
+
+// Features returns every known feature, sorted by name.
+func Features() []Feature {
+	list := append([]Feature(nil), features...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// LookupFeature returns the named feature.
+func LookupFeature(name string) (Feature, error) {
+	for _, feature := range features {
+		if feature.Name == name {
+			return feature, nil
+		}
+	}
+	return Feature{}, fmt.Errorf("unknown feature %q", name)
+}
+
+// FeatureStates resolves every feature: the environment wins over the
+// settings file, which wins over the built-in default.
+func FeatureStates(settings *Settings) ([]FeatureState, error) {
+	overrides, err := parseFeatureEnv(os.Getenv(FeaturesEnv))
+	if err != nil {
+		return nil, err
+	}
+	var states []FeatureState
+	for _, feature := range Features() {
+		state := FeatureState{Feature: feature, Enabled: feature.Default, Source: "default"}
+		if enabled, ok := settings.Features[feature.Name]; ok {
+			state.Enabled, state.Source = enabled, "settings"
+		}
+		if enabled, ok := overrides[feature.Name]; ok {
+			state.Enabled, state.Source = enabled, "env"
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// RequireFeature returns an error explaining how to enable name when it is off.
+func RequireFeature(name string) error {
+	settings, err := LoadSettings()
+	if err != nil {
+		return err
+	}
+	states, err := FeatureStates(settings)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if state.Name != name {
+			continue
+		}
+		if !state.Enabled {
+			return fmt.Errorf("feature %s is disabled (%s); enable it with `wirestack features enable %s`", name, state.Source, name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown feature %q", name)
+}
+
+// parseFeatureEnv parses the comma-separated FeaturesEnv value.
+func parseFeatureEnv(value string) (map[string]bool, error) {
+	overrides := map[string]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, disabled := strings.CutPrefix(item, "-")
+		name = strings.TrimPrefix(name, "+")
+		if _, err := LookupFeature(name); err != nil {
+			return nil, fmt.Errorf("%s: %w", FeaturesEnv, err)
+		}
+		overrides[name] = !disabled
+	}
+	return overrides, nil
+}
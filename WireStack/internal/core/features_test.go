@@ -0,0 +1,47 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFeatureResolution(t *testing.T) {
+	setupTempHome(t)
+	t.Setenv(FeaturesEnv, "")
+
+	stateOf := func(settings *Settings) FeatureState {
+		t.Helper()
+		states, err := FeatureStates(settings)
+		if err != nil {
+			t.Fatalf("FeatureStates: %v", err)
+		}
+		for _, state := range states {
+			if state.Name == FeatureNativeBackend {
+				return state
+			}
+		}
+		t.Fatalf("feature %s not listed", FeatureNativeBackend)
+		return FeatureState{}
+	}
+
+	if state := stateOf(&Settings{}); state.Enabled || state.Source != "default" {
+		t.Fatalf("unexpected default state %+v", state)
+	}
+	settings := &Settings{Features: map[string]bool{FeatureNativeBackend: true}}
+	if state := stateOf(settings); !state.Enabled || state.Source != "settings" {
+		t.Fatalf("unexpected settings state %+v", state)
+	}
+	t.Setenv(FeaturesEnv, " -native-backend ")
+	if state := stateOf(settings); state.Enabled || state.Source != "env" {
+		t.Fatalf("unexpected env state %+v", state)
+	}
+
+	t.Setenv(FeaturesEnv, "-native-backend")
+	if err := RequireFeature(FeatureNativeBackend); err == nil || !strings.Contains(err.Error(), "features enable native-backend") {
+		t.Fatalf("expected disabled feature error, got %v", err)
+	}
+	t.Setenv(FeaturesEnv, "warp-drive")
+	if _, err := FeatureStates(&Settings{}); err == nil {
+		t.Fatalf("expected unknown feature in %s to be rejected", FeaturesEnv)
+	}
+}
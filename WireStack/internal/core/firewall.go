@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Firewall ruleset formats.
+const (
+	FirewallNftables = "nftables"
+	FirewallIptables = "iptables"
+)
+
+// FirewallOptions selects what BuildFirewallRules renders.
+type FirewallOptions struct {
+	// Format is FirewallNftables (the default) or FirewallIptables.
+	Format string
+	// Egress is the interface clients reach the internet through; it
+	// defaults to the server's NAT interface. Without one, clients can only
+	// reach the server and each other.
+	Egress string
+	// IsolateClients drops traffic between clients of the server.
+	IsolateClients bool
+	// IPv6 renders an ip6tables-restore file; nftables rules always cover both families.
+	IPv6 bool
+}
+
+// BuildFirewallRules renders a ruleset for the server: the WireGuard port is
+// accepted on input, tunnel traffic may leave through the egress interface
+// (with replies let back in) and is masqueraded there, and any other traffic
+// forwarded to or from the tunnel is dropped. nftables output is a complete
+// table for nft -f that replaces itself when loaded again; iptables output is
+// for iptables-restore --noflush.
+func BuildFirewallRules(profile *ServerProfile, options FirewallOptions) (string, error) {
+	_, port, err := net.SplitHostPort(profile.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
+	}
+	egress := options.Egress
+	if egress == "" {
+		egress = profile.NATInterface
+	}
+	if egress != "" && !validInterfaceName.MatchString(egress) {
+		return "", fmt.Errorf("invalid egress interface name %q", egress)
+	}
+	subnet := profile.Subnet
+	if subnet == "" {
+		subnet = DefaultSubnet
+	}
+
+	switch options.Format {
+	case "", FirewallNftables:
+		if options.IPv6 {
+			return "", fmt.Errorf("nftables rules already cover IPv6; --ipv6 only applies to iptables")
+		}
+		return buildNftablesRules(profile, port, egress, subnet, options.IsolateClients), nil
+	case FirewallIptables:
+		if options.IPv6 {
+			if profile.Subnet6 == "" {
+				return "", fmt.Errorf("server %s has no IPv6 subnet", profile.Name)
+			}
+			subnet = profile.Subnet6
+		}
+		return buildIptablesRules(profile, port, egress, subnet, options.IsolateClients, options.IPv6), nil
+	default:
+		return "", fmt.Errorf("unsupported firewall format %q (want %s or %s)", options.Format, FirewallNftables, FirewallIptables)
+	}
+}
+
+// buildNftablesRules renders the ruleset as one inet table.
+func buildNftablesRules(profile *ServerProfile, port, egress, subnet string, isolate bool) string {
+	iface := InterfaceName(profile)
+	table := "inet " + nftTableName(profile)
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "#!/usr/sbin/nft -f\n")
+	fmt.Fprintf(builder, "# WireStack firewall for server %s (interface %s).\n", profile.Name, iface)
+	fmt.Fprintf(builder, "# Load with nft -f; remove with: nft delete table %s\n\n", table)
+	// Declaring the table first makes the delete succeed on the first load.
+	fmt.Fprintf(builder, "table %s\ndelete table %s\n\n", table, table)
+
+	fmt.Fprintf(builder, "table %s {\n", table)
+	fmt.Fprintf(builder, "\tchain input {\n\t\ttype filter hook input priority filter; policy accept;\n")
+	fmt.Fprintf(builder, "\t\tudp dport %s accept\n\t}\n\n", port)
+
+	fmt.Fprintf(builder, "\tchain forward {\n\t\ttype filter hook forward priority filter; policy accept;\n")
+	if !isolate {
+		fmt.Fprintf(builder, "\t\tiifname %q oifname %q accept\n", iface, iface)
+	}
+	if egress != "" {
+		fmt.Fprintf(builder, "\t\tiifname %q oifname %q accept\n", iface, egress)
+		fmt.Fprintf(builder, "\t\tiifname %q oifname %q ct state related,established accept\n", egress, iface)
+	}
+	fmt.Fprintf(builder, "\t\tiifname %q drop\n\t\toifname %q drop\n\t}\n", iface, iface)
+
+	if egress != "" {
+		fmt.Fprintf(builder, "\n\tchain postrouting {\n\t\ttype nat hook postrouting priority srcnat; policy accept;\n")
+		fmt.Fprintf(builder, "\t\tip saddr %s oifname %q masquerade\n", subnet, egress)
+		if profile.Subnet6 != "" {
+			fmt.Fprintf(builder, "\t\tip6 saddr %s oifname %q masquerade\n", profile.Subnet6, egress)
+		}
+		fmt.Fprintf(builder, "\t}\n")
+	}
+	fmt.Fprintf(builder, "}\n")
+	return builder.String()
+}
+
+// buildIptablesRules renders the ruleset for one address family in
+// iptables-restore format.
+func buildIptablesRules(profile *ServerProfile, port, egress, subnet string, isolate, ipv6 bool) string {
+	iface := InterfaceName(profile)
+	tool := "iptables-restore"
+	if ipv6 {
+		tool = "ip6tables-restore"
+	}
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "# WireStack firewall for server %s (interface %s).\n", profile.Name, iface)
+	fmt.Fprintf(builder, "# Load once with: %s --noflush < <file>\n", tool)
+
+	fmt.Fprintf(builder, "*filter\n")
+	fmt.Fprintf(builder, "-A INPUT -p udp --dport %s -j ACCEPT\n", port)
+	if !isolate {
+		fmt.Fprintf(builder, "-A FORWARD -i %s -o %s -j ACCEPT\n", iface, iface)
+	}
+	if egress != "" {
+		fmt.Fprintf(builder, "-A FORWARD -i %s -o %s -j ACCEPT\n", iface, egress)
+		fmt.Fprintf(builder, "-A FORWARD -i %s -o %s -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT\n", egress, iface)
+	}
+	fmt.Fprintf(builder, "-A FORWARD -i %s -j DROP\n-A FORWARD -o %s -j DROP\n", iface, iface)
+	fmt.Fprintf(builder, "COMMIT\n")
+
+	if egress != "" {
+		fmt.Fprintf(builder, "*nat\n")
+		fmt.Fprintf(builder, "-A POSTROUTING -s %s -o %s -j MASQUERADE\n", subnet, egress)
+		fmt.Fprintf(builder, "COMMIT\n")
+	}
+	return builder.String()
+}
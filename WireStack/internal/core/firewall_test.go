@@ -0,0 +1,66 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildFirewallRules(t *testing.T) {
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Subnet6 = "fd00:9::/64"
+	profile.NATInterface = "eth0"
+
+	rules, err := BuildFirewallRules(profile, FirewallOptions{})
+	if err != nil {
+		t.Fatalf("BuildFirewallRules: %v", err)
+	}
+	for _, want := range []string{
+		"table inet wirestack_edge\ndelete table inet wirestack_edge\n",
+		"udp dport 51820 accept\n",
+		"iifname \"edge\" oifname \"edge\" accept\n",
+		"iifname \"edge\" oifname \"eth0\" accept\n",
+		"iifname \"eth0\" oifname \"edge\" ct state related,established accept\n",
+		"ip saddr 10.0.0.0/24 oifname \"eth0\" masquerade\n",
+		"ip6 saddr fd00:9::/64 oifname \"eth0\" masquerade\n",
+	} {
+		if !strings.Contains(rules, want) {
+			t.Fatalf("expected %q in nftables rules:\n%s", want, rules)
+		}
+	}
+	if strings.Index(rules, "iifname \"edge\" drop") < strings.Index(rules, "oifname \"eth0\" accept") {
+		t.Fatalf("expected the drop rules after the accepts:\n%s", rules)
+	}
+
+	rules, err = BuildFirewallRules(profile, FirewallOptions{Format: FirewallIptables, IsolateClients: true, IPv6: true})
+	if err != nil {
+		t.Fatalf("BuildFirewallRules iptables: %v", err)
+	}
+	for _, want := range []string{
+		"-A INPUT -p udp --dport 51820 -j ACCEPT\n",
+		"-A FORWARD -i eth0 -o edge -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT\n",
+		"-A POSTROUTING -s fd00:9::/64 -o eth0 -j MASQUERADE\n",
+	} {
+		if !strings.Contains(rules, want) {
+			t.Fatalf("expected %q in ip6tables rules:\n%s", want, rules)
+		}
+	}
+	if strings.Contains(rules, "-i edge -o edge -j ACCEPT") {
+		t.Fatalf("expected isolated clients not to reach each other:\n%s", rules)
+	}
+
+	profile.NATInterface = ""
+	rules, err = BuildFirewallRules(profile, FirewallOptions{Format: FirewallIptables})
+	if err != nil {
+		t.Fatalf("BuildFirewallRules without egress: %v", err)
+	}
+	if strings.Contains(rules, "*nat") || strings.Contains(rules, "eth0") {
+		t.Fatalf("expected no NAT without an egress interface:\n%s", rules)
+	}
+
+	if _, err := BuildFirewallRules(profile, FirewallOptions{Format: "pf"}); err == nil {
+		t.Fatalf("expected an unknown format to be rejected")
+	}
+	if _, err := BuildFirewallRules(profile, FirewallOptions{Egress: "eth0 accept"}); err == nil {
+		t.Fatalf("expected an invalid egress interface to be rejected")
+	}
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Protocols a port forward can use.
+const (
+	ForwardTCP = "tcp"
+	ForwardUDP = "udp"
+)
+
+// PortForward exposes a port on a client through the server: traffic for
+// PublicPort arriving on the server's NAT interface is sent to ClientPort on
+// the client's tunnel address.
+type PortForward struct {
+	Protocol   string `json:"protocol"`
+	PublicPort int    `json:"public_port"`
+	ClientPort int    `json:"client_port"`
+}
+
+// String formats the forward the way ParsePortForward reads it.
+func (f PortForward) String() string {
+	return fmt.Sprintf("%s:%d:%d", f.Protocol, f.PublicPort, f.ClientPort)
+}
+
+// ParsePortForward reads a forward written as [protocol:]public[:client],
+// e.g. "tcp:8080:80", "8080:80", or "udp:27015". The protocol defaults to
+// tcp and the client port to the public port.
+func ParsePortForward(spec string) (PortForward, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	forward := PortForward{Protocol: ForwardTCP}
+	if proto := strings.ToLower(parts[0]); proto == ForwardTCP || proto == ForwardUDP {
+		forward.Protocol = proto
+		parts = parts[1:]
+	}
+	if len(parts) == 0 || len(parts) > 2 {
+		return PortForward{}, fmt.Errorf("invalid port forward %q (want [tcp|udp:]public[:client])", spec)
+	}
+	ports := make([]int, len(parts))
+	for idx, part := range parts {
+		port, err := strconv.Atoi(part)
+		if err != nil || port < 1 || port > 65535 {
+			return PortForward{}, fmt.Errorf("invalid port %q in port forward %q", part, spec)
+		}
+		ports[idx] = port
+	}
+	forward.PublicPort, forward.ClientPort = ports[0], ports[len(ports)-1]
+	return forward, nil
+}
+
+// SetClientPortForwards replaces the client's port forwards. They are
+// rendered alongside the server's NAT rules, so NAT must be enabled. An
+// empty list removes them.
+func SetClientPortForwards(profile *ServerProfile, client *ClientProfile, forwards []PortForward) error {
+	previous := client.PortForwards
+	client.PortForwards = nil
+	if len(forwards) > 0 {
+		client.PortForwards = append([]PortForward(nil), forwards...)
+	}
+	if err := checkPortForwards(profile); err != nil {
+		client.PortForwards = previous
+		return err
+	}
+	return nil
+}
+
+// checkPortForwards reports forwards that cannot be rendered or that claim
+// a public port already taken by another forward or the server itself.
+func checkPortForwards(profile *ServerProfile) error {
+	taken := map[string]string{}
+	if _, port, err := net.SplitHostPort(profile.Endpoint); err == nil && profile.ExternalInterface == "" {
+		taken[ForwardUDP+"/"+port] = "the server's listen port"
+	}
+	for _, client := range profile.Clients {
+		if len(client.PortForwards) == 0 {
+			continue
+		}
+		if profile.NATInterface == "" {
+			return fmt.Errorf("client %s has port forwards but NAT is not enabled on the server; set an egress interface with --nat", client.Name)
+		}
+		if ip := parseAddress(client.Address); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("client %s has port forwards but no IPv4 tunnel address", client.Name)
+		}
+		for _, forward := range client.PortForwards {
+			if forward.Protocol != ForwardTCP && forward.Protocol != ForwardUDP {
+				return fmt.Errorf("client %s: unsupported port forward protocol %q", client.Name, forward.Protocol)
+			}
+			for _, port := range []int{forward.PublicPort, forward.ClientPort} {
+				if port < 1 || port > 65535 {
+					return fmt.Errorf("client %s: port forward %s has an invalid port", client.Name, forward)
+				}
+			}
+			key := fmt.Sprintf("%s/%d", forward.Protocol, forward.PublicPort)
+			if owner, ok := taken[key]; ok {
+				return fmt.Errorf("client %s: public port %s is already used by %s", client.Name, key, owner)
+			}
+			taken[key] = "client " + client.Name
+		}
+	}
+	return nil
+}
+
+// portForwardRules renders the DNAT rules for every enabled client's port
+// forwards, in the same form as the NAT rules they are rendered with. Replies
+// from clients that do not route everything through the tunnel would bypass
+// the server, so forwarded connections to them are masqueraded as well.
+func portForwardRules(profile *ServerProfile, nft bool) (up, down []string) {
+	ingress := profile.NATInterface
+	for _, client := range profile.Clients {
+		if client.Disabled || len(client.PortForwards) == 0 {
+			continue
+		}
+		address := parseAddress(client.Address).String()
+		masquerade := !routesEverything(EffectiveAllowedIPs(profile, client))
+		for _, forward := range client.PortForwards {
+			target := fmt.Sprintf("%s:%d", address, forward.ClientPort)
+			if nft {
+				table := "inet " + nftTableName(profile)
+				up = append(up, fmt.Sprintf("nft add rule %s prerouting iifname %s %s dport %d dnat ip to %s", table, ingress, forward.Protocol, forward.PublicPort, target))
+				if masquerade {
+					up = append(up, fmt.Sprintf("nft add rule %s postrouting oifname %%i ip daddr %s %s dport %d masquerade", table, address, forward.Protocol, forward.ClientPort))
+				}
+				continue
+			}
+			rules := []string{
+				fmt.Sprintf("PREROUTING -i %s -p %s --dport %d -j DNAT --to-destination %s", ingress, forward.Protocol, forward.PublicPort, target),
+				fmt.Sprintf("FORWARD -i %s -o %%i -p %s -d %s --dport %d -j ACCEPT", ingress, forward.Protocol, address, forward.ClientPort),
+			}
+			if masquerade {
+				rules = append(rules, fmt.Sprintf("POSTROUTING -o %%i -p %s -d %s --dport %d -j MASQUERADE", forward.Protocol, address, forward.ClientPort))
+			}
+			for _, rule := range rules {
+				table := "-t nat "
+				if strings.HasPrefix(rule, "FORWARD") {
+					table = ""
+				}
+				up = append(up, "iptables "+table+"-A "+rule)
+				down = append(down, "iptables "+table+"-D "+rule)
+			}
+		}
+	}
+	return up, down
+}
+
+// routesEverything reports whether AllowedIPs include an IPv4 default route.
+func routesEverything(allowedIPs []string) bool {
+	for _, cidr := range allowedIPs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.IP.To4() != nil {
+			if ones, _ := network.Mask.Size(); ones == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	// FsckCorrupt marks files or stored profiles that cannot be parsed.
+	FsckCorrupt = "corrupt"
+	// FsckNameMismatch marks profiles stored under a different name than their own.
+	FsckNameMismatch = "name-mismatch"
+	// FsckDuplicate marks client names or public keys used more than once.
+	FsckDuplicate = "duplicate"
+	// FsckOrphan marks files left behind for servers or clients that no longer exist.
+	FsckOrphan = "orphan"
+	// FsckPermissions marks files other users can access, or that WireStack cannot read.
+	FsckPermissions = "permissions"
+	// FsckInvalidName marks server or client names that cannot name a file.
+	FsckInvalidName = "invalid-name"
+)
+
+// FsckIssue is one problem CheckStore found.
+type FsckIssue struct {
+	Kind string `json:"kind"`
+	// Path is the affected file, or the server name for profiles kept in sqlite.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	// Repairable issues have a safe fix; Repaired is set once it was applied.
+	Repairable bool `json:"repairable"`
+	Repaired   bool `json:"repaired"`
+}
+
+// fsckChecker collects issues and applies their fixes when repairing.
+type fsckChecker struct {
+	repair bool
+	issues []FsckIssue
+}
+
+// add records an issue. A non-nil fix makes it repairable and runs when repairing.
+func (c *fsckChecker) add(kind, path string, fix func() error, format string, args ...any) {
+	issue := FsckIssue{Kind: kind, Path: path, Message: fmt.Sprintf(format, args...), Repairable: fix != nil}
+	if c.repair && fix != nil {
+		if err := fix(); err != nil {
+			issue.Message += fmt.Sprintf(" (repair failed: %v)", err)
+		} else {
+			issue.Repaired = true
+		}
+	}
+	c.issues = append(c.issues, issue)
+}
+
+// CheckStore scans the profile store and the files under ~/.wirestack for
+// unparsable profiles and state, profiles stored under the wrong name,
+// duplicate client names and public keys, runtime configs and state left for
+// servers or clients that no longer exist, and files with loose or broken
+// permissions. With repair it applies the safe fixes: removing orphaned or
+// corrupt derived files, which WireStack recreates, and tightening modes.
+// Profiles themselves are never changed.
+func CheckStore(repair bool) ([]FsckIssue, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return nil, err
+	}
+	c := &fsckChecker{repair: repair}
+
+	store := CurrentStore()
+	_, fileStore := store.(FileStore)
+	names, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	known := map[string]bool{}
+	var profiles []*ServerProfile
+	var unreadable []string
+	for _, name := range names {
+		known[name] = true
+		path := name
+		if fileStore {
+			if path, err = ServerProfilePath(name); err != nil {
+				unreadable = append(unreadable, name)
+				c.add(FsckInvalidName, name, nil, "%v", err)
+				continue
+			}
+		}
+		profile, err := store.Load(name)
+		switch {
+		case errors.Is(err, fs.ErrPermission):
+			unreadable = append(unreadable, name)
+			// checkPermissions reports unreadable files.
+			if !fileStore || runtime.GOOS == "windows" {
+				c.add(FsckPermissions, path, nil, "cannot be read: %v; it may be owned by root after a sudo run", err)
+			}
+			continue
+		case err != nil:
+			unreadable = append(unreadable, name)
+			c.add(FsckCorrupt, path, nil, "%v; restore it from a backup", err)
+			continue
+		}
+		if profile.Name != name {
+			c.add(FsckNameMismatch, path, nil, "stored as %s but named %s; saving it would write a second profile", name, profile.Name)
+		}
+		for _, client := range profile.Clients {
+			if err := checkPathName("client", client.Name); err != nil {
+				c.add(FsckInvalidName, path, nil, "%v; delete the client and add it again under another name", err)
+			}
+		}
+		profiles = append(profiles, profile)
+	}
+	for _, finding := range ValidateProfiles(profiles) {
+		if finding.Rule == "duplicate-client" || finding.Rule == "duplicate-public-key" {
+			c.add(FsckDuplicate, finding.Server, nil, "%s", finding.Message)
+		}
+	}
+
+	if err := c.checkRuntime(known, profiles, unreadable); err != nil {
+		return nil, err
+	}
+	if err := c.checkQuality(known); err != nil {
+		return nil, err
+	}
+	if runtime.GOOS != "windows" {
+		if err := c.checkPermissions(root); err != nil {
+			return nil, err
+		}
+	}
+	return c.issues, nil
+}
+
+// checkRuntime flags runtime configs and peer sync checkpoints that belong to
+// no stored server or client, and checkpoints that cannot be parsed. Client
+// configs of profiles that could not be loaded are left alone.
+func (c *fsckChecker) checkRuntime(known map[string]bool, profiles []*ServerProfile, unreadable []string) error {
+	dir, err := RuntimeRoot()
+	if err != nil {
+		return err
+	}
+	expected := map[string]bool{}
+	for name := range known {
+		expected[name+".conf"] = true
+		expected[name+".peer-sync.json"] = true
+	}
+	for _, profile := range profiles {
+		for _, client := range profile.Clients {
+			// Names that cannot name a file were reported with the profile.
+			if path, err := ClientRuntimeConfigPath(profile.Name, client.Name); err == nil {
+				expected[filepath.Base(path)] = true
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read runtime directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		isState := strings.HasSuffix(name, ".peer-sync.json")
+		if entry.IsDir() || utils.IsTempFile(name) || (!isState && filepath.Ext(name) != ".conf") {
+			continue
+		}
+		if !expected[name] && !hasClientPrefix(name, unreadable) {
+			c.add(FsckOrphan, path, removeFile(path), "no stored server or client uses this file")
+			continue
+		}
+		if isState {
+			c.checkJSON(path, "the interrupted peer sync starts over")
+		}
+	}
+	return nil
+}
+
+// checkQuality flags quality history for servers that no longer exist and
+// history that cannot be parsed.
+func (c *fsckChecker) checkQuality(known map[string]bool) error {
+	dir, err := QualityRoot()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read quality directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || utils.IsTempFile(name) || filepath.Ext(name) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if !known[strings.TrimSuffix(name, ".json")] {
+			c.add(FsckOrphan, path, removeFile(path), "quality history of a server that no longer exists")
+			continue
+		}
+		c.checkJSON(path, "history is recorded again from the next sample")
+	}
+	return nil
+}
+
+// checkJSON flags a derived state file that is not valid JSON. Removing it is
+// safe; consequence says what happens then.
+func (c *fsckChecker) checkJSON(path, consequence string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrPermission) {
+			c.add(FsckCorrupt, path, nil, "cannot be read: %v", err)
+		}
+		// checkPermissions reports unreadable files.
+		return
+	}
+	if !json.Valid(data) {
+		c.add(FsckCorrupt, path, removeFile(path), "not valid JSON; removing it is safe, %s", consequence)
+	}
+}
+
+// checkPermissions flags directories and secret files under root that other
+// users can access beyond what the permissions policy shares, shared paths
+// its group cannot read, and anything WireStack itself cannot read.
+// Certificates are public and user templates are left to their author.
+func (c *fsckChecker) checkPermissions(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				c.add(FsckPermissions, path, nil, "cannot be read: %v; it may be owned by root after a sudo run", err)
+				if entry != nil && entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() && path == filepath.Join(root, templatesDir) {
+			return fs.SkipDir
+		}
+		if !entry.IsDir() && (!entry.Type().IsRegular() || filepath.Ext(path) == ".crt" || utils.IsTempFile(entry.Name())) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		base := baseMode(path, entry.IsDir())
+		mode := info.Mode().Perm()
+		want, gid := utils.PolicyMode(path, base, entry.IsDir())
+		fix := func() error { return utils.ApplyPermissionPolicy(path, base, entry.IsDir()) }
+		switch {
+		case mode&0o077&^want != 0:
+			c.add(FsckPermissions, path, fix, "mode %04o gives other users access; want %04o", mode, want)
+		case want&^mode&0o070 != 0 || (gid >= 0 && utils.FileGroup(info) != gid):
+			c.add(FsckPermissions, path, fix, "the permissions group cannot read it; want mode %04o and group %d", want, gid)
+		}
+		if !entry.IsDir() {
+			if file, err := os.Open(path); err != nil {
+				c.add(FsckPermissions, path, nil, "cannot be read: %v; it may be owned by root after a sudo run", err)
+			} else {
+				file.Close()
+			}
+		}
+		return nil
+	})
+}
+
+// hasClientPrefix reports whether name is a client runtime config of one of servers.
+func hasClientPrefix(name string, servers []string) bool {
+	for _, server := range servers {
+		if strings.HasPrefix(name, "client-"+server+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFile returns a fix that deletes path.
+func removeFile(path string) func() error {
+	return func() error {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+}
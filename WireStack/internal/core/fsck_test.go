@@ -0,0 +1,117 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckStore(t *testing.T) {
+	home := setupTempHome(t)
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	client, err := AddClient(profile, ClientOptions{Name: "laptop"})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	if _, err := WriteClientConfig(profile, client); err != nil {
+		t.Fatalf("WriteClientConfig: %v", err)
+	}
+	if issues, err := CheckStore(false); err != nil || len(issues) != 0 {
+		t.Fatalf("expected a clean store, got %+v (%v)", issues, err)
+	}
+
+	root := filepath.Join(home, defaultConfigDir)
+	write := func(rel, data string) string {
+		path := filepath.Join(root, rel)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+		return path
+	}
+	orphan := write("runtime/client-prod-retired.conf", "[Interface]\n")
+	history := write("quality/prod.json", "{not json")
+	leaked := filepath.Join(root, "servers/prod.json")
+	if err := os.Chmod(leaked, 0o644); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	write("servers/broken.json", "{")
+	// The broken profile's clients are unknown, so its runtime configs are kept.
+	write("runtime/client-broken-desk.conf", "[Interface]\n")
+
+	issues, err := CheckStore(false)
+	if err != nil {
+		t.Fatalf("CheckStore: %v", err)
+	}
+	want := map[string]string{
+		filepath.Join(root, "servers/broken.json"): FsckCorrupt,
+		orphan:  FsckOrphan,
+		history: FsckCorrupt,
+	}
+	if runtime.GOOS != "windows" {
+		want[leaked] = FsckPermissions
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %+v", len(want), issues)
+	}
+	for _, issue := range issues {
+		if want[issue.Path] != issue.Kind || issue.Repaired {
+			t.Fatalf("unexpected issue %+v", issue)
+		}
+	}
+
+	issues, err = CheckStore(true)
+	if err != nil {
+		t.Fatalf("CheckStore repair: %v", err)
+	}
+	for _, issue := range issues {
+		if issue.Repaired != issue.Repairable || (issue.Kind == FsckCorrupt && issue.Path != history && issue.Repairable) {
+			t.Fatalf("unexpected repair result %+v", issue)
+		}
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Fatalf("expected the orphaned runtime config to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "servers/broken.json")); err != nil {
+		t.Fatalf("expected the corrupt profile to be kept: %v", err)
+	}
+	if info, err := os.Stat(leaked); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0o600) {
+		t.Fatalf("expected the profile mode to be tightened, got %v (%v)", info.Mode(), err)
+	}
+
+	issues, err = CheckStore(false)
+	if err != nil {
+		t.Fatalf("CheckStore: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Kind != FsckCorrupt || issues[0].Repairable {
+		t.Fatalf("expected only the corrupt profile to remain, got %+v", issues)
+	}
+}
+
+func TestCheckStoreReportsNamesThatCannotNameFiles(t *testing.T) {
+	setupTempHome(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	// Long names predate name validation and stay usable.
+	profile.Clients = []ClientProfile{
+		{Name: "alice-laptop-home", PrivateKey: "a-priv", PublicKey: "a-pub", Address: "10.0.0.2/32"},
+		{Name: "../escape", PrivateKey: "b-priv", PublicKey: "b-pub", Address: "10.0.0.3/32"},
+	}
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	if _, err := ClientRuntimeConfigPath("prod", "alice-laptop-home"); err != nil {
+		t.Fatalf("expected a long client name to keep its runtime config path: %v", err)
+	}
+
+	issues, err := CheckStore(false)
+	if err != nil {
+		t.Fatalf("CheckStore: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Kind != FsckInvalidName {
+		t.Fatalf("expected one invalid-name issue, got %+v", issues)
+	}
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Node kinds used in a Topology.
+const (
+	NodeServer = "server"
+	NodeClient = "client"
+)
+
+// TopologyNode is a server or client in the rendered network map.
+type TopologyNode struct {
+	ID       string   `json:"id"`
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	Server   string   `json:"server,omitempty"`
+	Endpoint string   `json:"endpoint,omitempty"`
+	Subnets  []string `json:"subnets,omitempty"`
+	Address  []string `json:"addresses,omitempty"`
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+// TopologyEdge is a tunnel between a client and its server. AllowedIPs are
+// the routes the client sends through it.
+type TopologyEdge struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	AllowedIPs []string `json:"allowed_ips"`
+	Disabled   bool     `json:"disabled,omitempty"`
+}
+
+// Topology is the server/client graph across every profile.
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+// BuildTopology collects servers, clients, and their tunnels.
+func BuildTopology(profiles []*ServerProfile) Topology {
+	topology := Topology{Nodes: []TopologyNode{}, Edges: []TopologyEdge{}}
+	for _, profile := range profiles {
+		serverID := "server:" + profile.Name
+		var subnets []string
+		for _, subnet := range []string{profile.Subnet, profile.Subnet6} {
+			if subnet != "" {
+				subnets = append(subnets, subnet)
+			}
+		}
+		topology.Nodes = append(topology.Nodes, TopologyNode{
+			ID:       serverID,
+			Kind:     NodeServer,
+			Name:     profile.Name,
+			Endpoint: profile.Endpoint,
+			Subnets:  subnets,
+			Address:  ServerAddresses(profile),
+		})
+		for _, client := range profile.Clients {
+			clientID := "client:" + profile.Name + "/" + client.Name
+			topology.Nodes = append(topology.Nodes, TopologyNode{
+				ID:       clientID,
+				Kind:     NodeClient,
+				Name:     client.Name,
+				Server:   profile.Name,
+				Address:  ClientAddresses(client),
+				Disabled: client.Disabled,
+			})
+			topology.Edges = append(topology.Edges, TopologyEdge{
+				From:       clientID,
+				To:         serverID,
+				AllowedIPs: EffectiveAllowedIPs(profile, client),
+				Disabled:   client.Disabled,
+			})
+		}
+	}
+	return topology
+}
+
+// RenderDOT renders the topology as a Graphviz digraph with one cluster per server.
+func RenderDOT(topology Topology) string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "digraph wirestack {\n")
+	fmt.Fprintf(builder, "  rankdir=LR;\n")
+	fmt.Fprintf(builder, "  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, node := range topology.Nodes {
+		if node.Kind != NodeServer {
+			continue
+		}
+		fmt.Fprintf(builder, "  subgraph %s {\n", dotQuote("cluster_"+node.Name))
+		fmt.Fprintf(builder, "    label=%s;\n", dotQuote(node.Name))
+		fmt.Fprintf(builder, "    %s [label=%s, shape=box3d];\n", dotQuote(node.ID), dotQuote(nodeLabel(node)))
+		for _, client := range topology.Nodes {
+			if client.Kind != NodeClient || client.Server != node.Name {
+				continue
+			}
+			style := ""
+			if client.Disabled {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(builder, "    %s [label=%s%s];\n", dotQuote(client.ID), dotQuote(nodeLabel(client)), style)
+		}
+		fmt.Fprintf(builder, "  }\n")
+	}
+	for _, edge := range topology.Edges {
+		style := ""
+		if edge.Disabled {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(builder, "  %s -> %s [label=%s%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(strings.Join(edge.AllowedIPs, "\n")), style)
+	}
+	fmt.Fprintf(builder, "}\n")
+	return builder.String()
+}
+
+// mermaidUnsafe matches characters that are not allowed in Mermaid node IDs.
+var mermaidUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// RenderMermaid renders the topology as a Mermaid flowchart with one subgraph per server.
+func RenderMermaid(topology Topology) string {
+	ids := map[string]string{}
+	for idx, node := range topology.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d_%s", idx, mermaidUnsafe.ReplaceAllString(node.Name, "_"))
+	}
+
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "flowchart LR\n")
+	for _, node := range topology.Nodes {
+		if node.Kind != NodeServer {
+			continue
+		}
+		fmt.Fprintf(builder, "  subgraph %s_group[%s]\n", ids[node.ID], mermaidQuote(node.Name))
+		fmt.Fprintf(builder, "    %s[[%s]]\n", ids[node.ID], mermaidQuote(nodeLabel(node)))
+		for _, client := range topology.Nodes {
+			if client.Kind == NodeClient && client.Server == node.Name {
+				fmt.Fprintf(builder, "    %s[%s]\n", ids[client.ID], mermaidQuote(nodeLabel(client)))
+			}
+		}
+		fmt.Fprintf(builder, "  end\n")
+	}
+	for _, edge := range topology.Edges {
+		arrow := "-->"
+		if edge.Disabled {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(builder, "  %s %s|%s| %s\n", ids[edge.From], arrow, mermaidQuote(strings.Join(edge.AllowedIPs, ", ")), ids[edge.To])
+	}
+	return builder.String()
+}
+
+// nodeLabel returns the multi-line label shown for a node.
+func nodeLabel(node TopologyNode) string {
+	lines := []string{node.Name}
+	if node.Endpoint != "" {
+		lines = append(lines, node.Endpoint)
+	}
+	if node.Kind == NodeServer {
+		lines = append(lines, node.Subnets...)
+	} else {
+		lines = append(lines, node.Address...)
+	}
+	if node.Disabled {
+		lines = append(lines, "(disabled)")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dotQuote quotes a Graphviz ID or label.
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + strings.ReplaceAll(value, "\n", `\n`) + `"`
+}
+
+// mermaidQuote quotes a Mermaid label, using <br/> for line breaks.
+func mermaidQuote(value string) string {
+	value = strings.ReplaceAll(value, `"`, "#quot;")
+	return `"` + strings.ReplaceAll(value, "\n", "<br/>") + `"`
+}
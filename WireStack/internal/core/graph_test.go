@@ -0,0 +1,37 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTopologyRendering(t *testing.T) {
+	profile := DefaultServerProfile("hq", "203.0.113.1:51820", "priv", "pub")
+	profile.Policies = []AccessPolicy{{Tag: "office", AllowedIPs: []string{"10.20.0.0/16"}}}
+	profile.Clients = []ClientProfile{
+		{Name: "alice", Address: "10.0.0.2/32", AllowedIPs: ClientAllowedIPs(), Tags: []string{"office"}},
+		{Name: `bob "b"`, Address: "10.0.0.3/32", AllowedIPs: ClientAllowedIPs(), Disabled: true},
+	}
+
+	topology := BuildTopology([]*ServerProfile{profile})
+	if len(topology.Nodes) != 3 || len(topology.Edges) != 2 {
+		t.Fatalf("unexpected topology %+v", topology)
+	}
+	if edge := topology.Edges[0]; edge.From != "client:hq/alice" || edge.To != "server:hq" || strings.Join(edge.AllowedIPs, ",") != "10.20.0.0/16" {
+		t.Fatalf("edge should carry the effective AllowedIPs: %+v", edge)
+	}
+
+	dot := RenderDOT(topology)
+	for _, want := range []string{`subgraph "cluster_hq"`, `"client:hq/alice" -> "server:hq" [label="10.20.0.0/16"]`, `bob \"b\"`, "style=dashed"} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("dot output missing %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid := RenderMermaid(topology)
+	for _, want := range []string{"flowchart LR", `n1_alice -->|"10.20.0.0/16"| n0_hq`, "n2_bob__b_ -.->", "#quot;b#quot;"} {
+		if !strings.Contains(mermaid, want) {
+			t.Fatalf("mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+}
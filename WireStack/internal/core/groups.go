@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"net"
+)
+
+// ClientGroup is a named access policy shared by the clients that reference
+// it. Its AllowedIPs and DNS apply to members without their own override.
+type ClientGroup struct {
+	Name       string   `json:"name"`
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+	DNS        []string `json:"dns,omitempty"`
+}
+
+// FindGroup returns the server's group with the given name.
+func FindGroup(profile *ServerProfile, name string) (*ClientGroup, error) {
+	for idx := range profile.Groups {
+		if profile.Groups[idx].Name == name {
+			return &profile.Groups[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("group %s not found on server %s", name, profile.Name)
+}
+
+// clientGroup returns the group a client references, or nil when it has none
+// or the group no longer exists.
+func clientGroup(profile *ServerProfile, client ClientProfile) *ClientGroup {
+	if client.Group == "" {
+		return nil
+	}
+	group, err := FindGroup(profile, client.Group)
+	if err != nil {
+		return nil
+	}
+	return group
+}
+
+// SetGroup creates the group or replaces its AllowedIPs and DNS. A group
+// needs at least one of them to have any effect.
+func SetGroup(profile *ServerProfile, name string, allowedIPs, dns []string) error {
+	if name == "" {
+		return fmt.Errorf("group name is empty")
+	}
+	if len(allowedIPs) == 0 && len(dns) == 0 {
+		return fmt.Errorf("group %s sets neither AllowedIPs nor DNS", name)
+	}
+	group := ClientGroup{Name: name}
+	for _, cidr := range allowedIPs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid AllowedIPs entry %s: %w", cidr, err)
+		}
+		group.AllowedIPs = append(group.AllowedIPs, network.String())
+	}
+	for _, server := range dns {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("DNS server %q is not an IP address", server)
+		}
+	}
+	group.DNS = append([]string(nil), dns...)
+
+	if existing, err := FindGroup(profile, name); err == nil {
+		*existing = group
+		return nil
+	}
+	profile.Groups = append(profile.Groups, group)
+	return nil
+}
+
+// RemoveGroup deletes a group. A group with members is kept, so deleting it
+// cannot silently change their configs; move them first.
+func RemoveGroup(profile *ServerProfile, name string) error {
+	if members := GroupMembers(profile, name); len(members) > 0 {
+		return fmt.Errorf("group %s still has %d members; move them with edit-client --group first", name, len(members))
+	}
+	for idx := range profile.Groups {
+		if profile.Groups[idx].Name == name {
+			profile.Groups = append(profile.Groups[:idx], profile.Groups[idx+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("group %s not found on server %s", name, profile.Name)
+}
+
+// GroupMembers returns the names of the clients in a group, in profile order.
+func GroupMembers(profile *ServerProfile, name string) []string {
+	var members []string
+	for _, client := range profile.Clients {
+		if client.Group == name {
+			members = append(members, client.Name)
+		}
+	}
+	return members
+}
+
+// SetClientGroup puts the client in an existing group; an empty name takes
+// it out of its group.
+func SetClientGroup(profile *ServerProfile, client *ClientProfile, name string) error {
+	if name != "" {
+		if _, err := FindGroup(profile, name); err != nil {
+			return err
+		}
+	}
+	client.Group = name
+	return nil
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientGroups(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetPolicy(profile, "office", []string{"172.16.0.0/12"}); err != nil {
+		t.Fatalf("SetPolicy: %v", err)
+	}
+	if err := SetGroup(profile, "contractors", []string{"10.0.0.9/24"}, []string{"10.0.0.53"}); err != nil {
+		t.Fatalf("SetGroup: %v", err)
+	}
+	if _, err := AddClient(profile, ClientOptions{Name: "ghost", Group: "nope"}); err == nil {
+		t.Fatal("expected joining a missing group to fail")
+	}
+
+	member, err := AddClient(profile, ClientOptions{Name: "alice", Tags: []string{"office"}, Group: "contractors"})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	override, err := AddClient(profile, ClientOptions{Name: "bob", Group: "contractors", DNS: []string{"192.168.1.1"}})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if got := strings.Join(EffectiveAllowedIPs(profile, member), ","); got != "10.0.0.0/24" {
+		t.Fatalf("expected the group's AllowedIPs to beat the tag policy, got %s", got)
+	}
+	if got := strings.Join(ClientDNS(profile, override), ","); got != "192.168.1.1" {
+		t.Fatalf("expected the client's DNS to beat the group's, got %s", got)
+	}
+	if got := strings.Join(GroupMembers(profile, "contractors"), ","); got != "alice,bob" {
+		t.Fatalf("unexpected members %s", got)
+	}
+
+	// Changing the group changes every member's config.
+	if err := SetGroup(profile, "contractors", []string{"10.0.0.0/24", "192.168.50.0/24"}, []string{"10.0.0.54"}); err != nil {
+		t.Fatalf("SetGroup: %v", err)
+	}
+	config, err := BuildClientConfig(profile, member)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(config, "DNS = 10.0.0.54\n") || !strings.Contains(config, "AllowedIPs = 10.0.0.0/24, 192.168.50.0/24\n") {
+		t.Fatalf("group policy missing from config:\n%s", config)
+	}
+
+	if err := RemoveGroup(profile, "contractors"); err == nil {
+		t.Fatal("expected a group with members to be kept")
+	}
+	for idx := range profile.Clients {
+		if err := SetClientGroup(profile, &profile.Clients[idx], ""); err != nil {
+			t.Fatalf("SetClientGroup: %v", err)
+		}
+	}
+	if err := RemoveGroup(profile, "contractors"); err != nil {
+		t.Fatalf("RemoveGroup: %v", err)
+	}
+	if got := strings.Join(EffectiveAllowedIPs(profile, profile.Clients[0]), ","); got != "172.16.0.0/12" {
+		t.Fatalf("expected the tag policy once out of the group, got %s", got)
+	}
+	if err := SetGroup(profile, "empty", nil, nil); err == nil {
+		t.Fatal("expected a group without AllowedIPs or DNS to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// Peer health states reported by EvaluateHealth.
+const (
+	// HealthOK means the latest handshake is within the threshold.
+	HealthOK = "ok"
+	// HealthStale means the latest handshake is older than the threshold.
+	HealthStale = "stale"
+	// HealthNever means the peer is configured but has never completed a handshake.
+	HealthNever = "never"
+	// HealthMissing means an enabled client is not configured on the running interface.
+	HealthMissing = "missing"
+)
+
+// PeerHealth is the health of one peer on a running interface.
+type PeerHealth struct {
+	// Client is empty for peers that match no stored client.
+	Client    string
+	PublicKey string
+	Endpoint  string
+	// LatestHandshake is zero when there has been none.
+	LatestHandshake time.Time
+	State           string
+}
+
+// Healthy reports whether the peer counts as healthy. Peers that never
+// connected only count as unhealthy when ignoreNever is false.
+func (h PeerHealth) Healthy(ignoreNever bool) bool {
+	return h.State == HealthOK || (ignoreNever && h.State == HealthNever)
+}
+
+// EvaluateHealth compares each peer's latest handshake with threshold.
+// Enabled clients that are not running as peers are reported as missing, so
+// a profile that was changed without re-applying it shows up too. Peers are
+// sorted by client name, with unknown peers last.
+func EvaluateHealth(profile *ServerProfile, status *InterfaceStatus, threshold time.Duration, now time.Time) []PeerHealth {
+	var peers []PeerHealth
+	running := map[string]bool{}
+	for _, entry := range MatchClients(profile, status) {
+		running[entry.Peer.PublicKey] = true
+		health := PeerHealth{
+			Client:          entry.ClientName,
+			PublicKey:       entry.Peer.PublicKey,
+			Endpoint:        entry.Peer.Endpoint,
+			LatestHandshake: entry.Peer.LatestHandshake,
+			State:           HealthOK,
+		}
+		switch {
+		case entry.Peer.LatestHandshake.IsZero():
+			health.State = HealthNever
+		case now.Sub(entry.Peer.LatestHandshake) > threshold:
+			health.State = HealthStale
+		}
+		peers = append(peers, health)
+	}
+	for _, client := range profile.Clients {
+		if !client.Disabled && !running[client.PublicKey] {
+			peers = append(peers, PeerHealth{Client: client.Name, PublicKey: client.PublicKey, State: HealthMissing})
+		}
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		if (peers[i].Client == "") != (peers[j].Client == "") {
+			return peers[j].Client == ""
+		}
+		return peers[i].Client < peers[j].Client
+	})
+	return peers
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateHealth(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	profile := &ServerProfile{Name: "prod", Clients: []ClientProfile{
+		{Name: "phone", PublicKey: "phone-key"},
+		{Name: "laptop", PublicKey: "laptop-key"},
+		{Name: "tablet", PublicKey: "tablet-key"},
+		{Name: "router", PublicKey: "router-key"},
+		{Name: "old", PublicKey: "old-key", Disabled: true},
+	}}
+	status := &InterfaceStatus{Peers: []PeerStatus{
+		{PublicKey: "stray-key"},
+		{PublicKey: "phone-key", LatestHandshake: now.Add(-time.Minute)},
+		{PublicKey: "laptop-key", LatestHandshake: now.Add(-10 * time.Minute)},
+		{PublicKey: "tablet-key"},
+	}}
+
+	peers := EvaluateHealth(profile, status, 3*time.Minute, now)
+	want := []struct{ client, state string }{
+		{"laptop", HealthStale},
+		{"phone", HealthOK},
+		{"router", HealthMissing},
+		{"tablet", HealthNever},
+		{"", HealthNever},
+	}
+	if len(peers) != len(want) {
+		t.Fatalf("expected %d peers, got %+v", len(want), peers)
+	}
+	for idx, expected := range want {
+		if peers[idx].Client != expected.client || peers[idx].State != expected.state {
+			t.Fatalf("peer %d: expected %s %s, got %s %s", idx, expected.client, expected.state, peers[idx].Client, peers[idx].State)
+		}
+	}
+	if peers[3].Healthy(false) || !peers[3].Healthy(true) {
+		t.Fatalf("expected a never-connected peer to be healthy only with ignoreNever")
+	}
+	if peers[0].Healthy(true) || peers[2].Healthy(true) {
+		t.Fatalf("expected stale and missing peers to be unhealthy")
+	}
+}
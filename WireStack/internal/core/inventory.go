@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxPlatformField bounds each reported platform value, since they come from devices.
+const maxPlatformField = 128
+
+// ClientPlatform describes the device a client runs on, as reported by its agent.
+type ClientPlatform struct {
+	// OS is the operating system and version, e.g. "android 14" or "ubuntu 24.04".
+	OS     string `json:"os,omitempty"`
+	Kernel string `json:"kernel,omitempty"`
+	// Implementation is the WireGuard implementation, e.g. "kernel" or "wireguard-go".
+	Implementation string    `json:"implementation,omitempty"`
+	AppVersion     string    `json:"app_version,omitempty"`
+	ReportedAt     time.Time `json:"reported_at"`
+}
+
+// SetClientPlatform records a platform report on the client, trimming the
+// values and stamping ReportedAt with now. At least one value is required.
+func SetClientPlatform(client *ClientProfile, report ClientPlatform, now time.Time) error {
+	platform := ClientPlatform{ReportedAt: now.UTC()}
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  *string
+	}{
+		{"os", report.OS, &platform.OS},
+		{"kernel", report.Kernel, &platform.Kernel},
+		{"implementation", report.Implementation, &platform.Implementation},
+		{"app_version", report.AppVersion, &platform.AppVersion},
+	} {
+		value := strings.TrimSpace(field.value)
+		if len(value) > maxPlatformField {
+			return fmt.Errorf("%s is longer than %d characters", field.name, maxPlatformField)
+		}
+		if strings.ContainsAny(value, "\r\n\t") {
+			return fmt.Errorf("%s contains control characters", field.name)
+		}
+		*field.dest = value
+	}
+	if platform.OS == "" && platform.Kernel == "" && platform.Implementation == "" && platform.AppVersion == "" {
+		return fmt.Errorf("platform report is empty")
+	}
+	client.Platform = &platform
+	return nil
+}
+
+// Summary renders the reported values on one line, e.g.
+// "android 14, wireguard-go, app 1.0.20231018".
+func (p *ClientPlatform) Summary() string {
+	if p == nil {
+		return ""
+	}
+	var parts []string
+	for _, value := range []string{p.OS, p.Kernel, p.Implementation} {
+		if value != "" {
+			parts = append(parts, value)
+		}
+	}
+	if p.AppVersion != "" {
+		parts = append(parts, "app "+p.AppVersion)
+	}
+	return strings.Join(parts, ", ")
+}
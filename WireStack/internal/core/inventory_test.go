@@ -0,0 +1,40 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetClientPlatform(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := &ClientProfile{Name: "phone"}
+
+	if err := SetClientPlatform(client, ClientPlatform{OS: "  "}, now); err == nil {
+		t.Fatalf("expected an empty report to be rejected")
+	}
+	if err := SetClientPlatform(client, ClientPlatform{OS: "android\n14"}, now); err == nil {
+		t.Fatalf("expected control characters to be rejected")
+	}
+	if err := SetClientPlatform(client, ClientPlatform{AppVersion: strings.Repeat("1", maxPlatformField+1)}, now); err == nil {
+		t.Fatalf("expected an oversized value to be rejected")
+	}
+	if client.Platform != nil {
+		t.Fatalf("expected rejected reports not to be stored")
+	}
+
+	report := ClientPlatform{OS: " android 14 ", Implementation: "wireguard-go", AppVersion: "1.0.20231018", ReportedAt: now.Add(-time.Hour)}
+	if err := SetClientPlatform(client, report, now); err != nil {
+		t.Fatalf("SetClientPlatform: %v", err)
+	}
+	if !client.Platform.ReportedAt.Equal(now) {
+		t.Fatalf("expected ReportedAt to be set by the server, got %s", client.Platform.ReportedAt)
+	}
+	if summary := client.Platform.Summary(); summary != "android 14, wireguard-go, app 1.0.20231018" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+	var none *ClientPlatform
+	if none.Summary() != "" {
+		t.Fatalf("expected a nil platform to have an empty summary")
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workload kinds export-k8s can render.
+const (
+	K8sDeployment = "deployment"
+	K8sDaemonSet  = "daemonset"
+)
+
+// K8sOptions configures BuildK8sManifests.
+type K8sOptions struct {
+	// Namespace is set on every object; empty leaves it to kubectl.
+	Namespace string
+	// Kind is K8sDeployment (the default) or K8sDaemonSet.
+	Kind string
+	// ServiceType is the Service type, LoadBalancer unless set.
+	ServiceType string
+	// Flavor and Image pick the container image as for export-docker.
+	Flavor string
+	Image  string
+}
+
+// k8sObject is a Kubernetes manifest. yaml.v3 sorts map keys, which puts
+// apiVersion, kind, metadata, and spec in their customary order.
+type k8sObject map[string]any
+
+// BuildK8sManifests renders a Secret holding the server config, a Deployment
+// or DaemonSet running it with NET_ADMIN, and a Service exposing the listen
+// port over UDP, as one multi-document YAML stream for kubectl apply.
+// Forwarding sysctls are not in Kubernetes' safe set, so a privileged init
+// container enables them instead of the config's sysctl hooks.
+func BuildK8sManifests(profile *ServerProfile, options K8sOptions) (string, error) {
+	config, port, err := containerServerConfig(profile)
+	if err != nil {
+		return "", err
+	}
+	if err := checkDockerFlavor(options.Flavor); err != nil {
+		return "", err
+	}
+	listenPort, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen port %s", port)
+	}
+	kind := "Deployment"
+	switch options.Kind {
+	case "", K8sDeployment:
+	case K8sDaemonSet:
+		kind = "DaemonSet"
+	default:
+		return "", fmt.Errorf("unsupported workload kind %q (want %s or %s)", options.Kind, K8sDeployment, K8sDaemonSet)
+	}
+	serviceType := options.ServiceType
+	if serviceType == "" {
+		serviceType = "LoadBalancer"
+	}
+
+	iface := InterfaceName(profile)
+	name := "wirestack-" + profile.Name
+	labels := map[string]string{"app.kubernetes.io/name": "wirestack", "app.kubernetes.io/instance": profile.Name}
+	metadata := func() map[string]any {
+		meta := map[string]any{"name": name, "labels": labels}
+		if options.Namespace != "" {
+			meta["namespace"] = options.Namespace
+		}
+		return meta
+	}
+
+	var sysctls []string
+	for _, sysctl := range containerSysctls(profile) {
+		sysctls = append(sysctls, "sysctl -w "+sysctl)
+	}
+	container := map[string]any{
+		"name":            "wireguard",
+		"image":           containerImage(DockerOptions{Flavor: options.Flavor, Image: options.Image}),
+		"ports":           []any{map[string]any{"name": "wireguard", "containerPort": listenPort, "protocol": "UDP"}},
+		"securityContext": map[string]any{"capabilities": map[string]any{"add": []string{"NET_ADMIN"}}},
+		"volumeMounts":    []any{map[string]any{"name": "config", "mountPath": containerConfigDir(options.Flavor), "readOnly": true}},
+	}
+	if command := containerCommand(profile, options.Flavor); command != nil {
+		container["command"] = command
+	}
+	podSpec := map[string]any{
+		"initContainers": []any{map[string]any{
+			"name":            "sysctls",
+			"image":           "busybox:1.36",
+			"command":         []string{"sh", "-c", strings.Join(sysctls, " && ")},
+			"securityContext": map[string]any{"privileged": true},
+		}},
+		"containers": []any{container},
+		"volumes": []any{map[string]any{
+			"name":   "config",
+			"secret": map[string]any{"secretName": name, "defaultMode": 0o400},
+		}},
+	}
+	spec := map[string]any{
+		"selector": map[string]any{"matchLabels": labels},
+		"template": map[string]any{"metadata": map[string]any{"labels": labels}, "spec": podSpec},
+	}
+	if kind == "Deployment" {
+		// Two pods with the same key would fight over its peers.
+		spec["replicas"] = 1
+		spec["strategy"] = map[string]any{"type": "Recreate"}
+	}
+
+	objects := []k8sObject{
+		{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   metadata(),
+			"type":       "Opaque",
+			"stringData": map[string]string{iface + ".conf": config},
+		},
+		{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata":   metadata(),
+			"spec":       spec,
+		},
+		{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   metadata(),
+			"spec": map[string]any{
+				"type":     serviceType,
+				"selector": labels,
+				"ports":    []any{map[string]any{"name": "wireguard", "port": listenPort, "targetPort": listenPort, "protocol": "UDP"}},
+			},
+		},
+	}
+
+	builder := &bytes.Buffer{}
+	fmt.Fprintf(builder, "# Generated by wirestack export-k8s %s. The Secret holds the server private key.\n", profile.Name)
+	encoder := yaml.NewEncoder(builder)
+	encoder.SetIndent(2)
+	for _, object := range objects {
+		if err := encoder.Encode(object); err != nil {
+			return "", fmt.Errorf("render manifests: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("render manifests: %w", err)
+	}
+	return builder.String(), nil
+}
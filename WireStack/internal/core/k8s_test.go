@@ -0,0 +1,60 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildK8sManifests(t *testing.T) {
+	profile := DefaultServerProfile("prod", "203.0.113.1:51821", "server-priv", "server-pub")
+	if err := SetNAT(profile, "eth0", NATIptables); err != nil {
+		t.Fatalf("SetNAT: %v", err)
+	}
+	manifests, err := BuildK8sManifests(profile, K8sOptions{Namespace: "vpn", Kind: K8sDaemonSet})
+	if err != nil {
+		t.Fatalf("BuildK8sManifests: %v", err)
+	}
+
+	var kinds []string
+	decoder := yaml.NewDecoder(strings.NewReader(manifests))
+	for {
+		var object struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+			StringData map[string]string `yaml:"stringData"`
+		}
+		if err := decoder.Decode(&object); err != nil {
+			break
+		}
+		if object.Metadata.Name != "wirestack-prod" || object.Metadata.Namespace != "vpn" {
+			t.Fatalf("unexpected metadata %+v", object.Metadata)
+		}
+		if object.Kind == "Secret" {
+			config := object.StringData["prod.conf"]
+			if !strings.Contains(config, "PrivateKey = server-priv") || strings.Contains(config, "sysctl") {
+				t.Fatalf("unexpected config in the Secret:\n%s", config)
+			}
+		}
+		kinds = append(kinds, object.Kind)
+	}
+	if strings.Join(kinds, ",") != "Secret,DaemonSet,Service" {
+		t.Fatalf("expected a Secret, DaemonSet, and Service, got %v", kinds)
+	}
+	for _, want := range []string{"- NET_ADMIN", "containerPort: 51821", "protocol: UDP", "type: LoadBalancer", "sysctl -w net.ipv4.ip_forward=1"} {
+		if !strings.Contains(manifests, want) {
+			t.Fatalf("manifests miss %q:\n%s", want, manifests)
+		}
+	}
+	if strings.Contains(manifests, "replicas") {
+		t.Fatalf("expected a DaemonSet without replicas:\n%s", manifests)
+	}
+
+	if _, err := BuildK8sManifests(profile, K8sOptions{Kind: "statefulset"}); err == nil {
+		t.Fatalf("expected an unknown workload kind to be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// maxKeyHistory caps how many retired key pairs are kept per server or client.
+const maxKeyHistory = 5
+
+// RetiredKey is a key pair replaced by rotation, kept so it can be rolled back.
+type RetiredKey struct {
+	PrivateKey string    `json:"private_key"`
+	PublicKey  string    `json:"public_key"`
+	RetiredAt  time.Time `json:"retired_at"`
+}
+
+// retireKey prepends a key pair to history, trimming the oldest entries.
+func retireKey(history []RetiredKey, privateKey, publicKey string) []RetiredKey {
+	entry := RetiredKey{PrivateKey: privateKey, PublicKey: publicKey, RetiredAt: time.Now().UTC()}
+	history = append([]RetiredKey{entry}, history...)
+	if len(history) > maxKeyHistory {
+		history = history[:maxKeyHistory]
+	}
+	return history
+}
+
+// RotateServerKey replaces the server key pair and records the old pair in its history.
+func RotateServerKey(profile *ServerProfile) error {
+	if profile.ExternalInterface != "" {
+		return fmt.Errorf("server %s uses external interface %s; rotate its key with the owning tool", profile.Name, profile.ExternalInterface)
+	}
+	privateKey, publicKey, err := GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+	profile.KeyHistory = retireKey(profile.KeyHistory, profile.ServerPrivateKey, profile.ServerPublicKey)
+	profile.ServerPrivateKey, profile.ServerPublicKey = privateKey, publicKey
+	return nil
+}
+
+// RollbackServerKey restores the most recently retired server key pair.
+func RollbackServerKey(profile *ServerProfile) error {
+	if len(profile.KeyHistory) == 0 {
+		return fmt.Errorf("server %s has no previous key to roll back to", profile.Name)
+	}
+	previous := profile.KeyHistory[0]
+	profile.KeyHistory = profile.KeyHistory[1:]
+	profile.ServerPrivateKey, profile.ServerPublicKey = previous.PrivateKey, previous.PublicKey
+	return nil
+}
+
+// RotateClientKey replaces a client's key pair and returns the retired public key.
+func RotateClientKey(profile *ServerProfile, clientName string) (string, error) {
+	client, err := FindClient(profile, clientName)
+	if err != nil {
+		return "", err
+	}
+	privateKey, publicKey, err := GenerateKeyPair()
+	if err != nil {
+		return "", err
+	}
+	retired := client.PublicKey
+	client.KeyHistory = retireKey(client.KeyHistory, client.PrivateKey, client.PublicKey)
+	client.PrivateKey, client.PublicKey = privateKey, publicKey
+	return retired, nil
+}
+
+// RollbackClientKey restores a client's previous key pair and returns the
+// public key that was replaced.
+func RollbackClientKey(profile *ServerProfile, clientName string) (string, error) {
+	client, err := FindClient(profile, clientName)
+	if err != nil {
+		return "", err
+	}
+	if len(client.KeyHistory) == 0 {
+		return "", fmt.Errorf("client %s has no previous key to roll back to", clientName)
+	}
+	previous := client.KeyHistory[0]
+	replaced := client.PublicKey
+	client.KeyHistory = client.KeyHistory[1:]
+	client.PrivateKey, client.PublicKey = previous.PrivateKey, previous.PublicKey
+	return replaced, nil
+}
+
+// SetLivePrivateKey swaps the private key of a running interface using `wg set`.
+// The key is passed on stdin so it never appears in the process list.
+func SetLivePrivateKey(iface, privateKey string) error {
+	_, err := utils.RunCommandWithInput(privateKey, "wg", "set", iface, "private-key", "/dev/stdin")
+	return err
+}
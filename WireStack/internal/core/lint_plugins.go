@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// PluginTimeout bounds how long a single lint plugin may run for one profile.
+const PluginTimeout = 30 * time.Second
+
+// pluginFinding is the shape a lint plugin prints for each problem it finds.
+// The server name is filled in by WireStack.
+type pluginFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Client   string `json:"client,omitempty"`
+	Message  string `json:"message"`
+}
+
+// RunLintPlugins runs every plugin once per profile. Each plugin receives the
+// profile as JSON on stdin, with private keys removed, and prints a JSON array
+// of findings on stdout. A plugin that fails or prints invalid output is
+// reported as a plugin-failed finding rather than aborting the run.
+func RunLintPlugins(plugins []string, profiles []*ServerProfile) []Finding {
+	var findings []Finding
+	for _, profile := range profiles {
+		input, err := pluginInput(profile)
+		if err != nil {
+			findings = append(findings, Finding{Rule: "plugin-failed", Severity: SeverityError, Server: profile.Name, Message: err.Error()})
+			continue
+		}
+		for _, plugin := range plugins {
+			findings = append(findings, runLintPlugin(plugin, profile.Name, input)...)
+		}
+	}
+	return findings
+}
+
+// runLintPlugin executes one plugin against one serialized profile.
+func runLintPlugin(plugin, server, input string) []Finding {
+	name := filepath.Base(plugin)
+	failed := func(format string, args ...any) []Finding {
+		return []Finding{{
+			Rule:     "plugin-failed",
+			Severity: SeverityError,
+			Server:   server,
+			Message:  fmt.Sprintf("plugin %s: ", name) + fmt.Sprintf(format, args...),
+		}}
+	}
+
+	output, err := utils.RunCommandStdout(PluginTimeout, input, plugin)
+	if err != nil {
+		return failed("%v", err)
+	}
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return nil
+	}
+
+	var reported []pluginFinding
+	if err := json.Unmarshal([]byte(output), &reported); err != nil {
+		return failed("invalid output: %v", err)
+	}
+	findings := make([]Finding, 0, len(reported))
+	for _, item := range reported {
+		if item.Message == "" {
+			return failed("finding without a message")
+		}
+		rule := item.Rule
+		if rule == "" {
+			rule = name
+		}
+		severity := item.Severity
+		if severity != SeverityWarning {
+			severity = SeverityError
+		}
+		findings = append(findings, Finding{Rule: rule, Severity: severity, Server: server, Client: item.Client, Message: item.Message})
+	}
+	return findings
+}
+
+// pluginInput serializes a copy of the profile with private keys blanked so
+// plugins never see secrets.
+func pluginInput(profile *ServerProfile) (string, error) {
+	redacted := *profile
+	redacted.ServerPrivateKey = ""
+	redacted.KeyHistory = redactKeyHistory(profile.KeyHistory)
+	redacted.Clients = make([]ClientProfile, len(profile.Clients))
+	for idx, client := range profile.Clients {
+		client.PrivateKey = ""
+		client.KeyHistory = redactKeyHistory(client.KeyHistory)
+		redacted.Clients[idx] = client
+	}
+	data, err := json.Marshal(&redacted)
+	if err != nil {
+		return "", fmt.Errorf("encode profile %s: %w", profile.Name, err)
+	}
+	return string(data), nil
+}
+
+// redactKeyHistory copies history with the retired private keys blanked;
+// rotate-key --rollback can make them live again.
+func redactKeyHistory(history []RetiredKey) []RetiredKey {
+	if history == nil {
+		return nil
+	}
+	redacted := make([]RetiredKey, len(history))
+	for idx, entry := range history {
+		entry.PrivateKey = ""
+		redacted[idx] = entry
+	}
+	return redacted
+}
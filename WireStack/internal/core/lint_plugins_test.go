@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePlugin creates an executable shell script plugin in a temp directory.
+func writePlugin(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("write plugin: %v", err)
+	}
+	return path
+}
+
+func TestRunLintPlugins(t *testing.T) {
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-secret", "server-pub")
+	profile.Clients = []ClientProfile{{Name: "alice", PrivateKey: "alice-secret", PublicKey: "alice-pub", Address: "10.0.0.2/32"}}
+
+	seen := filepath.Join(t.TempDir(), "input.json")
+	plugin := writePlugin(t, "cat > "+seen+"\n"+`echo '[{"rule":"dns-internal","severity":"warning","message":"DNS must be internal"},{"client":"alice","message":"bad"}]'`+"\n")
+
+	findings := RunLintPlugins([]string{plugin}, []*ServerProfile{profile})
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+	if findings[0].Rule != "dns-internal" || findings[0].Severity != SeverityWarning || findings[0].Server != "prod" {
+		t.Fatalf("unexpected first finding %+v", findings[0])
+	}
+	if findings[1].Rule != "plugin.sh" || findings[1].Severity != SeverityError || findings[1].Client != "alice" {
+		t.Fatalf("unexpected second finding %+v", findings[1])
+	}
+
+	input, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatalf("read plugin input: %v", err)
+	}
+	if strings.Contains(string(input), "secret") || !strings.Contains(string(input), "alice-pub") {
+		t.Fatalf("plugin input should carry the profile without private keys: %s", input)
+	}
+	if profile.ServerPrivateKey != "server-secret" || profile.Clients[0].PrivateKey != "alice-secret" {
+		t.Fatalf("redaction must not modify the original profile")
+	}
+}
+
+func TestRunLintPluginsNeverSendPrivateKeys(t *testing.T) {
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-secret", "server-pub")
+	profile.KeyHistory = []RetiredKey{{PrivateKey: "old-server-secret", PublicKey: "old-server-pub"}}
+	profile.Clients = []ClientProfile{{
+		Name: "alice", PrivateKey: "alice-secret", PublicKey: "alice-pub", Address: "10.0.0.2/32",
+		KeyHistory: []RetiredKey{{PrivateKey: "old-alice-secret", PublicKey: "old-alice-pub"}},
+	}}
+
+	seen := filepath.Join(t.TempDir(), "input.json")
+	plugin := writePlugin(t, "cat > "+seen+"\necho '[]'\n")
+	if findings := RunLintPlugins([]string{plugin}, []*ServerProfile{profile}); len(findings) != 0 {
+		t.Fatalf("unexpected findings %+v", findings)
+	}
+
+	data, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatalf("read plugin input: %v", err)
+	}
+	var input any
+	if err := json.Unmarshal(data, &input); err != nil {
+		t.Fatalf("decode plugin input: %v", err)
+	}
+	var walk func(value any)
+	walk = func(value any) {
+		switch value := value.(type) {
+		case map[string]any:
+			for key, field := range value {
+				if strings.HasSuffix(key, "private_key") && field != "" {
+					t.Errorf("plugin received %s = %v", key, field)
+				}
+				walk(field)
+			}
+		case []any:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(input)
+	if !strings.Contains(string(data), "old-alice-pub") {
+		t.Fatalf("plugin input should keep retired public keys: %s", data)
+	}
+	if profile.KeyHistory[0].PrivateKey != "old-server-secret" || profile.Clients[0].KeyHistory[0].PrivateKey != "old-alice-secret" {
+		t.Fatalf("redaction must not modify the original key history")
+	}
+}
+
+func TestRunLintPluginsFailures(t *testing.T) {
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "", "")
+	plugins := []string{
+		writePlugin(t, "echo boom >&2\nexit 3\n"),
+		writePlugin(t, "echo not-json\n"),
+		writePlugin(t, "exit 0\n"),
+	}
+
+	findings := RunLintPlugins(plugins, []*ServerProfile{profile})
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 plugin failures, got %+v", findings)
+	}
+	for _, finding := range findings {
+		if finding.Rule != "plugin-failed" || finding.Severity != SeverityError {
+			t.Fatalf("unexpected finding %+v", finding)
+		}
+	}
+	if !strings.Contains(findings[0].Message, "boom") {
+		t.Fatalf("failure should include stderr, got %q", findings[0].Message)
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLivePeersKeepTheirOwnAddresses(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "wg.log")
+	script := "#!/bin/sh\necho \"$*\" >> " + logPath + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake wg: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// Full-tunnel clients route 0.0.0.0/0; pushing that as the server-side
+	// AllowedIPs would move the route from one peer to the next.
+	clients := []ClientProfile{
+		{Name: "alice", PublicKey: "alice-pub", Address: "10.0.0.2/32", AllowedIPs: ClientAllowedIPs()},
+		{Name: "bob", PublicKey: "bob-pub", Address: "10.0.0.3/32", AllowedIPs: ClientAllowedIPs()},
+	}
+	for _, client := range clients {
+		if err := ApplyLivePeer("wg0", client); err != nil {
+			t.Fatalf("ApplyLivePeer %s: %v", client.Name, err)
+		}
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read wg log: %v", err)
+	}
+	want := "set wg0 peer alice-pub allowed-ips 10.0.0.2/32\nset wg0 peer bob-pub allowed-ips 10.0.0.3/32\n"
+	if got := string(data); got != want {
+		t.Fatalf("unexpected wg calls:\n%s", got)
+	}
+}
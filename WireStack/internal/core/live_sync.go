@@ -0,0 +1,122 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Kinds of PeerChange.
+const (
+	PeerAdd    = "add"
+	PeerRemove = "remove"
+	PeerUpdate = "update"
+)
+
+// PeerChange is one difference between a profile and its running interface.
+type PeerChange struct {
+	Action string
+	// Client is empty for a running peer no client of the profile has.
+	Client    string
+	PublicKey string
+	// AllowedIPs is what the profile renders; Current what the interface has.
+	AllowedIPs []string
+	Current    []string
+}
+
+// DiffLivePeers compares the profile's enabled clients with the peers of its
+// running interface. Peers missing from the interface are added, peers whose
+// AllowedIPs differ are updated, and peers the profile does not render are
+// removed. On an external interface other tools may own peers too, so only
+// peers using a current or retired key of one of the profile's clients are
+// removed there.
+func DiffLivePeers(profile *ServerProfile, status *InterfaceStatus) []PeerChange {
+	running := make(map[string]PeerStatus, len(status.Peers))
+	for _, peer := range status.Peers {
+		running[peer.PublicKey] = peer
+	}
+	owned := map[string]string{}
+	for _, client := range profile.Clients {
+		owned[client.PublicKey] = client.Name
+		for _, retired := range client.KeyHistory {
+			owned[retired.PublicKey] = client.Name
+		}
+	}
+
+	var changes []PeerChange
+	wanted := map[string]bool{}
+	for _, client := range profile.Clients {
+		if client.Disabled || client.PublicKey == "" {
+			continue
+		}
+		wanted[client.PublicKey] = true
+		allowed := serverPeerAllowedIPs(client)
+		peer, ok := running[client.PublicKey]
+		switch {
+		case !ok:
+			changes = append(changes, PeerChange{Action: PeerAdd, Client: client.Name, PublicKey: client.PublicKey, AllowedIPs: allowed})
+		case !sameCIDRs(allowed, peer.AllowedIPs):
+			changes = append(changes, PeerChange{Action: PeerUpdate, Client: client.Name, PublicKey: client.PublicKey, AllowedIPs: allowed, Current: peer.AllowedIPs})
+		}
+	}
+	for _, peer := range status.Peers {
+		if wanted[peer.PublicKey] {
+			continue
+		}
+		name, known := owned[peer.PublicKey]
+		if profile.ExternalInterface != "" && !known {
+			continue
+		}
+		changes = append(changes, PeerChange{Action: PeerRemove, Client: name, PublicKey: peer.PublicKey, Current: peer.AllowedIPs})
+	}
+	return changes
+}
+
+// ApplyPeerChanges makes the changes on the running interface with `wg set`,
+// several peers per invocation. Removals go first, so an address moving from
+// a removed peer to another one is free when it is added.
+func ApplyPeerChanges(iface string, changes []PeerChange) error {
+	var peers []livePeer
+	for _, remove := range []bool{true, false} {
+		for _, change := range changes {
+			if (change.Action == PeerRemove) != remove {
+				continue
+			}
+			peers = append(peers, livePeer{client: change.Client, key: change.PublicKey, allowed: strings.Join(change.AllowedIPs, ","), remove: remove})
+		}
+	}
+	for _, chunk := range chunkPeers(peers, DefaultPeerChunkSize) {
+		if err := setPeers(iface, chunk); err != nil {
+			return fmt.Errorf("sync peers of %s: %w", iface, err)
+		}
+	}
+	return nil
+}
+
+// sameCIDRs reports whether two AllowedIPs lists hold the same networks,
+// ignoring order and how each network is written.
+func sameCIDRs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(cidrs []string) []string {
+		out := make([]string, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			cidr = strings.TrimSpace(cidr)
+			if _, network, err := net.ParseCIDR(cidr); err == nil {
+				cidr = network.String()
+			}
+			out = append(out, cidr)
+		}
+		sort.Strings(out)
+		return out
+	}
+	left, right := normalize(a), normalize(b)
+	for idx := range left {
+		if left[idx] != right[idx] {
+			return false
+		}
+	}
+	return true
+}
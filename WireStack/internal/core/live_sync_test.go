@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestDiffLivePeers(t *testing.T) {
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "", "server-pub")
+	profile.Clients = []ClientProfile{
+		{Name: "same", PublicKey: "same-pub", Address: "10.0.0.2/32"},
+		{Name: "moved", PublicKey: "moved-pub", Address: "10.0.0.3/32"},
+		{Name: "new", PublicKey: "new-pub", Address: "10.0.0.4/32"},
+		{Name: "off", PublicKey: "off-pub", Address: "10.0.0.5/32", Disabled: true},
+		{Name: "rotated", PublicKey: "rotated-pub", Address: "10.0.0.6/32", KeyHistory: []RetiredKey{{PublicKey: "old-pub"}}},
+	}
+	status := &InterfaceStatus{Peers: []PeerStatus{
+		{PublicKey: "same-pub", AllowedIPs: []string{"10.0.0.2/32"}},
+		{PublicKey: "moved-pub", AllowedIPs: []string{"10.0.0.9/32"}},
+		{PublicKey: "off-pub", AllowedIPs: []string{"10.0.0.5/32"}},
+		{PublicKey: "rotated-pub", AllowedIPs: []string{"10.0.0.6/32"}},
+		{PublicKey: "old-pub", AllowedIPs: []string{"10.0.0.6/32"}},
+		{PublicKey: "stranger-pub", AllowedIPs: []string{"10.0.0.7/32"}},
+	}}
+
+	summarize := func(changes []PeerChange) map[string]string {
+		got := map[string]string{}
+		for _, change := range changes {
+			got[change.PublicKey] = change.Action
+		}
+		return got
+	}
+	want := map[string]string{"moved-pub": PeerUpdate, "new-pub": PeerAdd, "off-pub": PeerRemove, "old-pub": PeerRemove, "stranger-pub": PeerRemove}
+	got := summarize(DiffLivePeers(profile, status))
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for key, action := range want {
+		if got[key] != action {
+			t.Fatalf("expected %s to %s, got %v", key, action, got)
+		}
+	}
+
+	// Peers of an external interface that no client ever used are left alone.
+	profile.ExternalInterface = "wg-ext0"
+	if action, ok := summarize(DiffLivePeers(profile, status))["stranger-pub"]; ok {
+		t.Fatalf("expected the unknown peer kept on an external interface, got %s", action)
+	}
+}
+
+func TestApplyPeerChangesRemovesFirst(t *testing.T) {
+	logPath, _ := loggingWG(t, "never")
+	changes := []PeerChange{
+		{Action: PeerAdd, Client: "b", PublicKey: "b-pub", AllowedIPs: []string{"10.0.0.3/32"}},
+		{Action: PeerRemove, Client: "a", PublicKey: "a-pub"},
+	}
+	if err := ApplyPeerChanges("wg0", changes); err != nil {
+		t.Fatalf("ApplyPeerChanges: %v", err)
+	}
+	lines := readLog(t, logPath)
+	if len(lines) != 1 || lines[0] != "set wg0 peer a-pub remove peer b-pub allowed-ips 10.0.0.3/32" {
+		t.Fatalf("expected one wg set removing first, got %q", lines)
+	}
+}
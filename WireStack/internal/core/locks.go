@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// ProfileLockTimeout is how long LockServerProfile waits for another process
+// or request to finish changing the same profile.
+const ProfileLockTimeout = 30 * time.Second
+
+var (
+	stateLocksMu sync.Mutex
+	// stateLocks holds one single-slot semaphore per lock file, so goroutines
+	// in one process queue on it before taking the file lock.
+	stateLocks = map[string]chan struct{}{}
+)
+
+// LockServerProfile takes the profile's lock, serializing changes from
+// concurrent CLI invocations, API requests, and daemon goroutines. Hold it
+// from LoadServerProfile through SaveServerProfile so neither side loses the
+// other's update, and call the returned function to release it. Changes to
+// different servers do not wait for each other.
+func LockServerProfile(name string) (func(), error) {
+	return LockServerProfileContext(context.Background(), name)
+}
+
+// LockServerProfileContext is LockServerProfile giving up when ctx is done,
+// such as when an API client disconnects. It waits at most ProfileLockTimeout.
+func LockServerProfileContext(ctx context.Context, name string) (func(), error) {
+	path, err := ServerLockPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return lockState(ctx, path, "server "+name)
+}
+
+// lockStateFile takes the lock guarding a state file other than a profile,
+// kept next to the profile locks under the given name.
+func lockStateFile(name string) (func(), error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return nil, err
+	}
+	return lockState(context.Background(), filepath.Join(root, locksDir, name+".lock"), name)
+}
+
+// lockState takes the in-process semaphore and then the file lock at path.
+func lockState(ctx context.Context, path, subject string) (func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, ProfileLockTimeout)
+	defer cancel()
+
+	slot := stateLock(path)
+	select {
+	case slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, stateLockError(subject, ctx.Err())
+	}
+	unlockFile, err := utils.LockFileContext(ctx, path)
+	if err != nil {
+		<-slot
+		if errors.Is(err, utils.ErrLocked) {
+			err = context.DeadlineExceeded
+		}
+		return nil, stateLockError(subject, err)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unlockFile()
+			<-slot
+		})
+	}, nil
+}
+
+// stateLock returns the in-process semaphore for the lock file at path.
+func stateLock(path string) chan struct{} {
+	stateLocksMu.Lock()
+	defer stateLocksMu.Unlock()
+	slot, ok := stateLocks[path]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		stateLocks[path] = slot
+	}
+	return slot
+}
+
+// stateLockError explains why the lock for subject could not be taken.
+func stateLockError(subject string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s is being changed by another wirestack process or request: %w", subject, utils.ErrLocked)
+	}
+	return fmt.Errorf("waiting for the lock on %s: %w", subject, err)
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockServerProfileContextCanceled(t *testing.T) {
+	setupTempHome(t)
+
+	unlock, err := LockServerProfile("lab")
+	if err != nil {
+		t.Fatalf("LockServerProfile: %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := LockServerProfileContext(ctx, "lab"); err == nil {
+		t.Fatalf("expected a held lock to block until the context ended")
+	} else if errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LockServerProfileContext(canceled, "lab"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	other, err := LockServerProfile("office")
+	if err != nil {
+		t.Fatalf("lock on another server should not wait: %v", err)
+	}
+	other()
+}
+
+func TestLockServerProfileReleaseIsIdempotent(t *testing.T) {
+	setupTempHome(t)
+
+	unlock, err := LockServerProfile("lab")
+	if err != nil {
+		t.Fatalf("LockServerProfile: %v", err)
+	}
+	unlock()
+	unlock()
+
+	again, err := LockServerProfile("lab")
+	if err != nil {
+		t.Fatalf("relock after release: %v", err)
+	}
+	again()
+}
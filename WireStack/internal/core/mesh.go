@@ -0,0 +1,266 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// ErrMeshNotFound is returned (wrapped) when a mesh profile does not exist.
+var ErrMeshNotFound = errors.New("mesh profile not found")
+
+// DefaultMeshSubnet is the node network used when a mesh does not specify one.
+const DefaultMeshSubnet = "10.9.0.0/24"
+
+// DefaultMeshListenPort is the port nodes listen on unless their endpoint says otherwise.
+const DefaultMeshListenPort = 51820
+
+// MeshNode is a member of a full-mesh network. Every node is both a peer and,
+// when it has an Endpoint, a server the other nodes dial.
+type MeshNode struct {
+	Name       string `json:"name"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	Address    string `json:"address"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	// Routes are extra networks behind the node that the other nodes reach through it.
+	Routes []string `json:"routes,omitempty"`
+}
+
+// MeshProfile describes a full-mesh network where every node peers with every other node.
+type MeshProfile struct {
+	Name       string     `json:"name"`
+	Subnet     string     `json:"subnet"`
+	ListenPort int        `json:"listen_port"`
+	Nodes      []MeshNode `json:"nodes"`
+}
+
+// MeshNodeOptions holds the user-supplied settings for a new mesh node.
+type MeshNodeOptions struct {
+	Name     string
+	Endpoint string
+	Routes   []string
+}
+
+// NewMeshProfile validates the settings for a new mesh. The caller saves it.
+func NewMeshProfile(name, subnet string, listenPort int) (*MeshProfile, error) {
+	if name == "" {
+		return nil, fmt.Errorf("mesh name is required")
+	}
+	if strings.ContainsAny(name, "/\\") {
+		return nil, fmt.Errorf("mesh name %q must not contain path separators", name)
+	}
+	if _, err := LoadMeshProfile(name); err == nil {
+		return nil, fmt.Errorf("mesh %s already exists", name)
+	} else if !errors.Is(err, ErrMeshNotFound) {
+		return nil, err
+	}
+	if subnet == "" {
+		subnet = DefaultMeshSubnet
+	}
+	network, err := ParseSubnet(subnet)
+	if err != nil {
+		return nil, err
+	}
+	if listenPort == 0 {
+		listenPort = DefaultMeshListenPort
+	}
+	if listenPort < 1 || listenPort > 65535 {
+		return nil, fmt.Errorf("listen port %d is out of range", listenPort)
+	}
+	return &MeshProfile{Name: name, Subnet: network.String(), ListenPort: listenPort, Nodes: []MeshNode{}}, nil
+}
+
+// AddMeshNode generates keys for a new node, assigns it the lowest free
+// address in the mesh subnet, and appends it to the mesh.
+func AddMeshNode(mesh *MeshProfile, opts MeshNodeOptions) (MeshNode, error) {
+	if opts.Name == "" {
+		return MeshNode{}, fmt.Errorf("node name is required")
+	}
+	if _, err := FindMeshNode(mesh, opts.Name); err == nil {
+		return MeshNode{}, fmt.Errorf("node %s already exists in mesh %s", opts.Name, mesh.Name)
+	}
+	if opts.Endpoint != "" {
+		if host, port, err := net.SplitHostPort(opts.Endpoint); err != nil || host == "" || port == "" {
+			return MeshNode{}, fmt.Errorf("invalid endpoint %s: must be host:port", opts.Endpoint)
+		}
+	}
+	routes := make([]string, 0, len(opts.Routes))
+	for _, route := range opts.Routes {
+		_, network, err := net.ParseCIDR(route)
+		if err != nil {
+			return MeshNode{}, fmt.Errorf("invalid route %s: %w", route, err)
+		}
+		routes = append(routes, network.String())
+	}
+
+	address, err := nextMeshAddress(mesh)
+	if err != nil {
+		return MeshNode{}, err
+	}
+	privateKey, publicKey, err := GenerateKeyPair()
+	if err != nil {
+		return MeshNode{}, err
+	}
+	node := MeshNode{
+		Name:       opts.Name,
+		Endpoint:   opts.Endpoint,
+		Address:    address,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}
+	if len(routes) > 0 {
+		node.Routes = routes
+	}
+	mesh.Nodes = append(mesh.Nodes, node)
+	return node, nil
+}
+
+// nextMeshAddress returns the lowest free host address in the mesh subnet.
+// Unlike a server subnet, the first host address is not reserved.
+func nextMeshAddress(mesh *MeshProfile) (string, error) {
+	network, err := ParseSubnet(mesh.Subnet)
+	if err != nil {
+		return "", err
+	}
+	used := make([]net.IP, 0, len(mesh.Nodes))
+	for _, node := range mesh.Nodes {
+		if ip := parseAddress(node.Address); ip != nil {
+			used = append(used, ip)
+		}
+	}
+	first := hostAddress(network, 1)
+	for _, ip := range used {
+		if ip.Equal(first) {
+			next, err := AllocateAddress(network, used)
+			if err != nil {
+				return "", fmt.Errorf("mesh %s is full: %w", mesh.Name, err)
+			}
+			return next.String() + "/32", nil
+		}
+	}
+	return first.String() + "/32", nil
+}
+
+// FindMeshNode returns a pointer to the named node within the mesh.
+func FindMeshNode(mesh *MeshProfile, name string) (*MeshNode, error) {
+	for idx := range mesh.Nodes {
+		if mesh.Nodes[idx].Name == name {
+			return &mesh.Nodes[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("node %s not found in mesh %s", name, mesh.Name)
+}
+
+// MeshNodeAllowedIPs returns what the other nodes route to node: its mesh
+// address and any networks behind it.
+func MeshNodeAllowedIPs(node MeshNode) []string {
+	return append([]string{node.Address}, node.Routes...)
+}
+
+// BuildMeshNodeConfig renders the wg-quick config for one node, listing every
+// other node as a peer. Nodes without an endpoint send keepalives so peers can
+// reach them through NAT.
+func BuildMeshNodeConfig(mesh *MeshProfile, nodeName string) (string, error) {
+	node, err := FindMeshNode(mesh, nodeName)
+	if err != nil {
+		return "", err
+	}
+	network, err := ParseSubnet(mesh.Subnet)
+	if err != nil {
+		return "", err
+	}
+	ones, _ := network.Mask.Size()
+	listenPort := mesh.ListenPort
+	if node.Endpoint != "" {
+		_, port, err := net.SplitHostPort(node.Endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid endpoint %s: %w", node.Endpoint, err)
+		}
+		if listenPort, err = strconv.Atoi(port); err != nil {
+			return "", fmt.Errorf("invalid endpoint port %s: %w", port, err)
+		}
+	}
+
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "[Interface]\n")
+	fmt.Fprintf(builder, "# Mesh: %s, node: %s\n", mesh.Name, node.Name)
+	fmt.Fprintf(builder, "Address = %s/%d\n", parseAddress(node.Address).String(), ones)
+	fmt.Fprintf(builder, "PrivateKey = %s\n", node.PrivateKey)
+	fmt.Fprintf(builder, "ListenPort = %d\n", listenPort)
+	fmt.Fprintf(builder, "\n")
+	for _, peer := range mesh.Nodes {
+		if peer.Name == node.Name {
+			continue
+		}
+		fmt.Fprintf(builder, "[Peer]\n")
+		fmt.Fprintf(builder, "# %s\n", peer.Name)
+		fmt.Fprintf(builder, "PublicKey = %s\n", peer.PublicKey)
+		fmt.Fprintf(builder, "AllowedIPs = %s\n", strings.Join(MeshNodeAllowedIPs(peer), ", "))
+		if peer.Endpoint != "" {
+			fmt.Fprintf(builder, "Endpoint = %s\n", peer.Endpoint)
+		}
+		if node.Endpoint == "" {
+			fmt.Fprintf(builder, "PersistentKeepalive = 25\n")
+		}
+		fmt.Fprintf(builder, "\n")
+	}
+	return builder.String(), nil
+}
+
+// SaveMeshProfile writes the mesh profile JSON with restrictive permissions.
+func SaveMeshProfile(mesh *MeshProfile) error {
+	if mesh == nil || mesh.Name == "" {
+		return fmt.Errorf("mesh name is empty")
+	}
+	path, err := MeshProfilePath(mesh.Name)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, mesh, 0o600)
+}
+
+// LoadMeshProfile reads a mesh profile from disk.
+func LoadMeshProfile(name string) (*MeshProfile, error) {
+	path, err := MeshProfilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	var mesh MeshProfile
+	if err := utils.ReadJSON(path, &mesh); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("mesh %s: %w", name, ErrMeshNotFound)
+		}
+		return nil, err
+	}
+	return &mesh, nil
+}
+
+// ListMeshProfiles returns the names of all stored mesh profiles, sorted.
+func ListMeshProfiles() ([]string, error) {
+	root, err := MeshesRoot()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meshes directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMeshNodesAndConfigRendering(t *testing.T) {
+	setupTempHome(t)
+	fakeWG(t)
+
+	mesh, err := NewMeshProfile("office", "10.9.0.0/24", 0)
+	if err != nil {
+		t.Fatalf("NewMeshProfile: %v", err)
+	}
+	if mesh.ListenPort != DefaultMeshListenPort {
+		t.Fatalf("expected default listen port, got %d", mesh.ListenPort)
+	}
+	hub, err := AddMeshNode(mesh, MeshNodeOptions{Name: "hub", Endpoint: "203.0.113.1:51900", Routes: []string{"192.168.10.7/24"}})
+	if err != nil {
+		t.Fatalf("AddMeshNode hub: %v", err)
+	}
+	laptop, err := AddMeshNode(mesh, MeshNodeOptions{Name: "laptop"})
+	if err != nil {
+		t.Fatalf("AddMeshNode laptop: %v", err)
+	}
+	if hub.Address != "10.9.0.1/32" || laptop.Address != "10.9.0.2/32" {
+		t.Fatalf("unexpected addresses %s %s", hub.Address, laptop.Address)
+	}
+	if _, err := AddMeshNode(mesh, MeshNodeOptions{Name: "hub"}); err == nil {
+		t.Fatalf("expected duplicate node to be rejected")
+	}
+	if _, err := AddMeshNode(mesh, MeshNodeOptions{Name: "bad", Endpoint: "no-port"}); err == nil {
+		t.Fatalf("expected endpoint without port to be rejected")
+	}
+
+	if err := SaveMeshProfile(mesh); err != nil {
+		t.Fatalf("SaveMeshProfile: %v", err)
+	}
+	if _, err := NewMeshProfile("office", "", 0); err == nil {
+		t.Fatalf("expected existing mesh to be rejected")
+	}
+	loaded, err := LoadMeshProfile("office")
+	if err != nil {
+		t.Fatalf("LoadMeshProfile: %v", err)
+	}
+
+	hubConfig, err := BuildMeshNodeConfig(loaded, "hub")
+	if err != nil {
+		t.Fatalf("BuildMeshNodeConfig hub: %v", err)
+	}
+	for _, want := range []string{"Address = 10.9.0.1/24", "ListenPort = 51900", "PublicKey = " + laptop.PublicKey, "AllowedIPs = 10.9.0.2/32"} {
+		if !strings.Contains(hubConfig, want) {
+			t.Fatalf("hub config missing %q:\n%s", want, hubConfig)
+		}
+	}
+	if strings.Contains(hubConfig, "Endpoint =") || strings.Contains(hubConfig, "PersistentKeepalive") || strings.Contains(hubConfig, hub.PublicKey) {
+		t.Fatalf("hub config should only list laptop without endpoint or keepalive:\n%s", hubConfig)
+	}
+
+	laptopConfig, err := BuildMeshNodeConfig(loaded, "laptop")
+	if err != nil {
+		t.Fatalf("BuildMeshNodeConfig laptop: %v", err)
+	}
+	for _, want := range []string{"ListenPort = 51820", "AllowedIPs = 10.9.0.1/32, 192.168.10.0/24", "Endpoint = 203.0.113.1:51900", "PersistentKeepalive = 25"} {
+		if !strings.Contains(laptopConfig, want) {
+			t.Fatalf("laptop config missing %q:\n%s", want, laptopConfig)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Migration statuses, in the order a client normally moves through them.
+const (
+	// MigrationPending marks a client created from OpenVPN that has not been handed its config.
+	MigrationPending = "pending"
+	// MigrationBundled marks a client whose hybrid bundle has been exported.
+	MigrationBundled = "bundled"
+	// MigrationCutover marks a client confirmed working over WireGuard while OpenVPN remains available.
+	MigrationCutover = "cutover"
+	// MigrationComplete marks a client whose OpenVPN access has been retired.
+	MigrationComplete = "complete"
+)
+
+// MigrationStatuses lists the valid migration statuses in order.
+var MigrationStatuses = []string{MigrationPending, MigrationBundled, MigrationCutover, MigrationComplete}
+
+// ClientMigration tracks a client that is moving to WireGuard from another VPN.
+type ClientMigration struct {
+	Source     string    `json:"source"`
+	OldAddress string    `json:"old_address,omitempty"`
+	Status     string    `json:"status"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SetMigrationStatus records a new migration status for a client.
+func SetMigrationStatus(profile *ServerProfile, clientName, status string) error {
+	client, err := FindClient(profile, clientName)
+	if err != nil {
+		return err
+	}
+	if client.Migration == nil {
+		return fmt.Errorf("client %s was not migrated from another VPN", clientName)
+	}
+	valid := false
+	for _, candidate := range MigrationStatuses {
+		valid = valid || candidate == status
+	}
+	if !valid {
+		return fmt.Errorf("unsupported migration status %q (want one of %s)", status, strings.Join(MigrationStatuses, ", "))
+	}
+	client.Migration.Status = status
+	client.Migration.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// MigrationChecklist renders the cutover steps shipped in a client's hybrid
+// bundle. openvpnProfile names the old OpenVPN profile and may be empty.
+func MigrationChecklist(profile *ServerProfile, client ClientProfile, configName, openvpnProfile string) string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "WireGuard migration for %s (server %s)\n\n", client.Name, profile.Name)
+	if openvpnProfile != "" {
+		fmt.Fprintf(builder, "Current OpenVPN profile: %s\n", openvpnProfile)
+	} else {
+		fmt.Fprintf(builder, "Current OpenVPN profile: keep using your existing profile\n")
+	}
+	fmt.Fprintf(builder, "New WireGuard config:    %s\n", configName)
+	if client.Migration != nil && client.Migration.OldAddress != "" {
+		fmt.Fprintf(builder, "Tunnel address:          %s (was %s)\n", strings.Join(ClientAddresses(client), ", "), client.Migration.OldAddress)
+	} else {
+		fmt.Fprintf(builder, "Tunnel address:          %s\n", strings.Join(ClientAddresses(client), ", "))
+	}
+	fmt.Fprintf(builder, "\nCutover checklist\n")
+	steps := []string{
+		"Install the WireGuard app for your platform.",
+		"Import " + configName + " into WireGuard. Do not remove the OpenVPN profile yet.",
+		"Disconnect OpenVPN, then activate the WireGuard tunnel.",
+		"Check that internal services and DNS names still resolve and respond.",
+		"Report success to your administrator so the migration can be marked as cut over.",
+		"If anything fails, deactivate WireGuard and reconnect with the OpenVPN profile.",
+		"Remove the OpenVPN profile once your administrator retires OpenVPN access.",
+	}
+	for idx, step := range steps {
+		fmt.Fprintf(builder, "  [ ] %d. %s\n", idx+1, step)
+	}
+	return builder.String()
+}
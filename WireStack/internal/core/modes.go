@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+	"net"
+)
+
+// Client tunnel modes.
+const (
+	// ClientModeFull sends all client traffic through the tunnel.
+	ClientModeFull = "full"
+	// ClientModeSplit only sends the VPN subnets and any extra routes through the tunnel.
+	ClientModeSplit = "split"
+)
+
+// ClientMode returns the client's tunnel mode. Clients created before modes
+// existed were always full tunnel.
+func ClientMode(client ClientProfile) string {
+	if client.Mode == "" {
+		return ClientModeFull
+	}
+	return client.Mode
+}
+
+// ApplyClientMode stores mode on the client and recomputes its AllowedIPs:
+// full tunnel routes everything, split tunnel routes the server subnets plus
+// routes. Extra routes are only accepted in split mode. Any CustomAllowedIPs
+// are dropped so the mode takes effect.
+func ApplyClientMode(profile *ServerProfile, client *ClientProfile, mode string, routes []string) error {
+	switch mode {
+	case "", ClientModeFull:
+		if len(routes) > 0 {
+			return fmt.Errorf("extra routes only apply to %s mode", ClientModeSplit)
+		}
+		client.Mode = ClientModeFull
+		client.SplitRoutes = nil
+		client.CustomAllowedIPs = nil
+		client.AllowedIPs = ClientAllowedIPs()
+		return nil
+	case ClientModeSplit:
+		allowed, err := SplitAllowedIPs(profile, routes)
+		if err != nil {
+			return err
+		}
+		client.Mode = ClientModeSplit
+		client.SplitRoutes = nil
+		client.CustomAllowedIPs = nil
+		if len(routes) > 0 {
+			// SplitAllowedIPs has already rejected invalid routes.
+			client.SplitRoutes, _ = normalizeCIDRs(routes, "route")
+		}
+		client.AllowedIPs = allowed
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q (want %s or %s)", mode, ClientModeFull, ClientModeSplit)
+	}
+}
+
+// SplitAllowedIPs returns the server's client subnets and routed networks
+// followed by routes, normalised to network addresses. Routes already covered
+// by an earlier entry are left out.
+func SplitAllowedIPs(profile *ServerProfile, routes []string) ([]string, error) {
+	network, err := ClientSubnet(profile)
+	if err != nil {
+		return nil, err
+	}
+	allowed := []string{network.String()}
+	if profile.Subnet6 != "" {
+		network6, err := ParseSubnet6(profile.Subnet6)
+		if err != nil {
+			return nil, err
+		}
+		allowed = append(allowed, network6.String())
+	}
+	extra, err := normalizeCIDRs(profile.Networks, "network")
+	if err != nil {
+		return nil, err
+	}
+	if extra, err = normalizeCIDRs(append(extra, routes...), "route"); err != nil {
+		return nil, err
+	}
+	for _, cidr := range extra {
+		if !containsString(allowed, cidr) {
+			allowed = append(allowed, cidr)
+		}
+	}
+	return allowed, nil
+}
+
+// normalizeCIDRs parses each value as a CIDR and returns its network address,
+// dropping duplicates. label names the values in errors.
+func normalizeCIDRs(values []string, label string) ([]string, error) {
+	var normalized []string
+	for _, value := range values {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s: %w", label, value, err)
+		}
+		if !containsString(normalized, network.String()) {
+			normalized = append(normalized, network.String())
+		}
+	}
+	return normalized, nil
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
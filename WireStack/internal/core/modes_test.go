@@ -0,0 +1,114 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientModes(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := ApplySubnet6(profile, "fd00:8::/64"); err != nil {
+		t.Fatalf("ApplySubnet6: %v", err)
+	}
+
+	full, err := AddClient(profile, ClientOptions{Name: "laptop"})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if ClientMode(full) != ClientModeFull || strings.Join(full.AllowedIPs, ",") != "0.0.0.0/0,::/0" {
+		t.Fatalf("unexpected full-tunnel client %+v", full)
+	}
+
+	split, err := AddClient(profile, ClientOptions{Name: "office", Mode: ClientModeSplit, SplitRoutes: []string{"192.168.5.9/24"}})
+	if err != nil {
+		t.Fatalf("AddClient split: %v", err)
+	}
+	if got := strings.Join(split.AllowedIPs, ","); got != "10.0.0.0/24,fd00:8::/64,192.168.5.0/24" {
+		t.Fatalf("unexpected split AllowedIPs %s", got)
+	}
+	if len(split.SplitRoutes) != 1 || split.SplitRoutes[0] != "192.168.5.0/24" {
+		t.Fatalf("unexpected split routes %v", split.SplitRoutes)
+	}
+	if _, err := BuildClientConfigFor(profile, split, ClientRenderOptions{KillSwitch: true}); err == nil {
+		t.Fatalf("expected kill switch to be rejected in split mode")
+	}
+
+	if _, err := AddClient(profile, ClientOptions{Name: "bad", Mode: "partial"}); err == nil {
+		t.Fatalf("expected unknown mode to be rejected")
+	}
+	if _, err := AddClient(profile, ClientOptions{Name: "bad", SplitRoutes: []string{"192.168.5.0/24"}}); err == nil {
+		t.Fatalf("expected routes without split mode to be rejected")
+	}
+
+	legacy := ClientProfile{Name: "old", AllowedIPs: ClientAllowedIPs()}
+	if ClientMode(legacy) != ClientModeFull {
+		t.Fatalf("expected clients without a mode to be full tunnel")
+	}
+	if err := ApplyClientMode(profile, &legacy, ClientModeSplit, nil); err != nil {
+		t.Fatalf("ApplyClientMode: %v", err)
+	}
+	if strings.Join(legacy.AllowedIPs, ",") != "10.0.0.0/24,fd00:8::/64" || legacy.SplitRoutes != nil {
+		t.Fatalf("unexpected split client %+v", legacy)
+	}
+}
+
+func TestServerNetworksRouteToSplitClients(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("office", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetServerNetworks(profile, []string{"192.168.10.7/24"}); err != nil {
+		t.Fatalf("SetServerNetworks: %v", err)
+	}
+
+	split, err := AddClient(profile, ClientOptions{Name: "laptop", Mode: ClientModeSplit, SplitRoutes: []string{"192.168.10.0/24", "172.16.0.0/16"}})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if got := strings.Join(split.AllowedIPs, ","); got != "10.0.0.0/24,192.168.10.0/24,172.16.0.0/16" {
+		t.Fatalf("unexpected split AllowedIPs %s", got)
+	}
+	full, err := AddClient(profile, ClientOptions{Name: "phone"})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	custom, err := AddClient(profile, ClientOptions{Name: "kiosk", Mode: ClientModeSplit, AllowedIPs: []string{"10.0.0.1/32"}})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+
+	if err := SetServerNetworks(profile, []string{"192.168.20.0/24", "fd10::/64"}); err != nil {
+		t.Fatalf("SetServerNetworks: %v", err)
+	}
+	if got := strings.Join(profile.Clients[0].AllowedIPs, ","); got != "10.0.0.0/24,192.168.20.0/24,fd10::/64,192.168.10.0/24,172.16.0.0/16" {
+		t.Fatalf("split client did not pick up the new networks: %s", got)
+	}
+	if strings.Join(profile.Clients[1].AllowedIPs, ",") != strings.Join(full.AllowedIPs, ",") {
+		t.Fatalf("full-tunnel client changed: %v", profile.Clients[1].AllowedIPs)
+	}
+	if strings.Join(EffectiveAllowedIPs(profile, profile.Clients[2]), ",") != strings.Join(custom.CustomAllowedIPs, ",") {
+		t.Fatalf("custom AllowedIPs were overridden: %v", EffectiveAllowedIPs(profile, profile.Clients[2]))
+	}
+
+	for _, networks := range [][]string{
+		{"not-a-cidr"},
+		{"0.0.0.0/0"},
+		{"10.0.0.128/25"},
+		{"192.168.0.0/16", "192.168.1.0/24"},
+	} {
+		if err := SetServerNetworks(profile, networks); err == nil {
+			t.Fatalf("expected %v to be rejected", networks)
+		}
+	}
+	if strings.Join(profile.Networks, ",") != "192.168.20.0/24,fd10::/64" {
+		t.Fatalf("rejected networks changed the profile: %v", profile.Networks)
+	}
+
+	profile.Networks = []string{"10.0.0.0/16"}
+	found := false
+	for _, finding := range ValidateProfile(profile) {
+		found = found || finding.Rule == "network-invalid"
+	}
+	if !found {
+		t.Fatalf("expected a network-invalid finding for a network overlapping the subnet")
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	// MinMTU is the smallest tunnel MTU accepted; IPv6 inside the tunnel needs 1280.
+	MinMTU = 1280
+	// MaxMTU is the largest tunnel MTU accepted (jumbo frames).
+	MaxMTU = 9000
+)
+
+// WireGuard encapsulation overhead: outer IP header, UDP header, and the
+// 32-byte WireGuard data header.
+const (
+	wireGuardOverhead4 = 20 + 8 + 32
+	wireGuardOverhead6 = 40 + 8 + 32
+)
+
+// ValidateMTU checks a tunnel MTU; zero means "use the default" and is accepted.
+func ValidateMTU(mtu int) error {
+	if mtu != 0 && (mtu < MinMTU || mtu > MaxMTU) {
+		return fmt.Errorf("MTU %d is out of range (want %d-%d, or 0 for the default)", mtu, MinMTU, MaxMTU)
+	}
+	return nil
+}
+
+// ClientMTU returns the MTU rendered into a client config for a target:
+// the client's own value, otherwise the target's default, or 0 for none.
+func ClientMTU(client ClientProfile, target string) int {
+	if client.MTU != 0 {
+		return client.MTU
+	}
+	return targetProfiles[target].mtu
+}
+
+// PathMTUProbe reports whether a packet of payload ICMP data bytes reaches
+// the destination without fragmentation.
+type PathMTUProbe func(payload int) (bool, error)
+
+// MTUProbeResult is the outcome of path MTU discovery.
+type MTUProbeResult struct {
+	Host    string
+	IPv6    bool
+	PathMTU int
+	// TunnelMTU is PathMTU minus WireGuard's overhead for the endpoint's address family.
+	TunnelMTU int
+}
+
+// ProbeMTU discovers the path MTU toward host by sending unfragmentable pings
+// of varying size, up to maxPath bytes, and derives the tunnel MTU from it.
+func ProbeMTU(host string, maxPath int) (*MTUProbeResult, error) {
+	ip, err := resolveProbeHost(host)
+	if err != nil {
+		return nil, err
+	}
+	ipv6 := ip.To4() == nil
+	probe := func(payload int) (bool, error) {
+		args := []string{"-M", "do", "-c", "1", "-W", "1", "-s", strconv.Itoa(payload), ip.String()}
+		if ipv6 {
+			args = append([]string{"-6"}, args...)
+		}
+		_, err := utils.RunCommand("ping", args...)
+		if errors.Is(err, exec.ErrNotFound) {
+			return false, err
+		}
+		return err == nil, nil
+	}
+	pathMTU, err := DiscoverPathMTU(probe, ipv6, maxPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ip, err)
+	}
+	return &MTUProbeResult{Host: ip.String(), IPv6: ipv6, PathMTU: pathMTU, TunnelMTU: TunnelMTU(pathMTU, ipv6)}, nil
+}
+
+// DiscoverPathMTU binary-searches the largest packet that passes probe. The
+// search starts from the IPv6 minimum of 1280 bytes, which every path must carry.
+func DiscoverPathMTU(probe PathMTUProbe, ipv6 bool, maxPath int) (int, error) {
+	header := 20 + 8
+	if ipv6 {
+		header = 40 + 8
+	}
+	low, high := MinMTU-header, maxPath-header
+	if high < low {
+		return 0, fmt.Errorf("maximum path MTU %d is below %d", maxPath, MinMTU)
+	}
+	ok, err := probe(low)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("no reply to %d-byte unfragmented pings; the host is unreachable or drops ICMP", MinMTU)
+	}
+	for low < high {
+		mid := (low + high + 1) / 2
+		ok, err := probe(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low + header, nil
+}
+
+// TunnelMTU returns the largest WireGuard MTU that fits into pathMTU, never
+// below MinMTU.
+func TunnelMTU(pathMTU int, ipv6 bool) int {
+	mtu := pathMTU - wireGuardOverhead4
+	if ipv6 {
+		mtu = pathMTU - wireGuardOverhead6
+	}
+	if mtu < MinMTU {
+		return MinMTU
+	}
+	return mtu
+}
+
+// resolveProbeHost turns a host name or address into the IP to probe,
+// preferring IPv4 like most WireGuard clients do.
+func resolveProbeHost(host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip, nil
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("resolve %s: no addresses", host)
+	}
+	return ips[0], nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiscoverPathMTU(t *testing.T) {
+	// A PPPoE link: 1492-byte packets pass, anything larger is dropped.
+	probes := 0
+	probe := func(payload int) (bool, error) {
+		probes++
+		return payload+28 <= 1492, nil
+	}
+	pathMTU, err := DiscoverPathMTU(probe, false, 1500)
+	if err != nil {
+		t.Fatalf("DiscoverPathMTU: %v", err)
+	}
+	if pathMTU != 1492 {
+		t.Fatalf("expected path MTU 1492, got %d", pathMTU)
+	}
+	if probes > 10 {
+		t.Fatalf("expected a binary search, took %d probes", probes)
+	}
+	if got := TunnelMTU(pathMTU, false); got != 1432 {
+		t.Fatalf("expected tunnel MTU 1432, got %d", got)
+	}
+	if got := TunnelMTU(1500, true); got != 1420 {
+		t.Fatalf("expected IPv6 tunnel MTU 1420, got %d", got)
+	}
+
+	unreachable := func(int) (bool, error) { return false, nil }
+	if _, err := DiscoverPathMTU(unreachable, false, 1500); err == nil {
+		t.Fatalf("expected an unreachable host to fail")
+	}
+}
+
+func TestMTURendering(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.MTU = 1412
+	config, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	if !strings.Contains(config, "MTU = 1412\n") {
+		t.Fatalf("server MTU missing:\n%s", config)
+	}
+
+	client, err := AddClient(profile, ClientOptions{Name: "phone", MTU: 1360})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	config, err = BuildClientConfigFor(profile, client, ClientRenderOptions{Target: TargetAndroid})
+	if err != nil {
+		t.Fatalf("BuildClientConfigFor: %v", err)
+	}
+	if !strings.Contains(config, "MTU = 1360\n") || strings.Contains(config, "MTU = 1280") {
+		t.Fatalf("expected the client MTU to replace the target default:\n%s", config)
+	}
+
+	client.MTU = 0
+	config, err = BuildClientConfigFor(profile, client, ClientRenderOptions{Target: TargetLinux})
+	if err != nil {
+		t.Fatalf("BuildClientConfigFor: %v", err)
+	}
+	if strings.Contains(config, "MTU =") {
+		t.Fatalf("expected no MTU line without a client or target MTU:\n%s", config)
+	}
+
+	if _, err := AddClient(profile, ClientOptions{Name: "bad", MTU: 576}); err == nil {
+		t.Fatalf("expected an MTU below %d to be rejected", MinMTU)
+	}
+}
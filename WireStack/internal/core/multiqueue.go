@@ -0,0 +1,252 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// Paths multi-queue settings are read from; tests point them elsewhere.
+var (
+	sysClassNetRoot = "/sys/class/net"
+	procIRQRoot     = "/proc/irq"
+	procInterrupts  = "/proc/interrupts"
+)
+
+// QueueInspection is how a NIC's queues and interrupts are spread over CPUs.
+type QueueInspection struct {
+	Interface string
+	// CPUs is the number of CPUs the host has online.
+	CPUs int
+	// RxQueues maps receive queues (rx-0, ...) to their rps_cpus mask.
+	RxQueues map[string]string
+	// TxQueues maps transmit queues (tx-0, ...) to their xps_cpus mask.
+	TxQueues map[string]string
+	// IRQs are the interrupts /proc/interrupts attributes to the interface.
+	IRQs []IRQAffinity
+	// Notes explain what could not be inspected or may undo the tuning.
+	Notes []string
+}
+
+// IRQAffinity is one of the interface's interrupts and the CPUs it may run on.
+type IRQAffinity struct {
+	IRQ  int
+	Name string
+	// CPUs is the smp_affinity_list, e.g. "0-3".
+	CPUs string
+}
+
+// InspectQueues reads the RPS and XPS masks of iface's queues and the CPU
+// affinity of its interrupts. Anything unreadable is reported in Notes.
+func InspectQueues(iface string) *QueueInspection {
+	inspection := &QueueInspection{Interface: iface, CPUs: runtime.NumCPU(), RxQueues: map[string]string{}, TxQueues: map[string]string{}}
+	entries, err := os.ReadDir(filepath.Join(sysClassNetRoot, iface, "queues"))
+	if err != nil {
+		inspection.Notes = append(inspection.Notes, fmt.Sprintf("queues of %s: %v", iface, err))
+	}
+	for _, entry := range entries {
+		file, queues := "rps_cpus", inspection.RxQueues
+		if strings.HasPrefix(entry.Name(), "tx-") {
+			file, queues = "xps_cpus", inspection.TxQueues
+		} else if !strings.HasPrefix(entry.Name(), "rx-") {
+			continue
+		}
+		// xps_cpus is missing or unreadable on devices without XPS support.
+		if data, err := os.ReadFile(filepath.Join(sysClassNetRoot, iface, "queues", entry.Name(), file)); err == nil {
+			queues[entry.Name()] = strings.TrimSpace(string(data))
+		}
+	}
+
+	data, err := os.ReadFile(procInterrupts)
+	if err != nil {
+		inspection.Notes = append(inspection.Notes, fmt.Sprintf("interrupts: %v", err))
+		return inspection
+	}
+	for _, irq := range ParseInterrupts(string(data), iface) {
+		affinity, err := os.ReadFile(filepath.Join(procIRQRoot, strconv.Itoa(irq.IRQ), "smp_affinity_list"))
+		if err != nil {
+			inspection.Notes = append(inspection.Notes, fmt.Sprintf("affinity of IRQ %d: %v", irq.IRQ, err))
+			continue
+		}
+		irq.CPUs = strings.TrimSpace(string(affinity))
+		inspection.IRQs = append(inspection.IRQs, irq)
+	}
+	if len(inspection.IRQs) > 0 {
+		if _, err := utils.RunCommand("pidof", "irqbalance"); err == nil {
+			inspection.Notes = append(inspection.Notes, "irqbalance is running and may move the interface's IRQs again; stop it or ban them with IRQBALANCE_BANNED_CPUS/--banirq")
+		}
+	}
+	return inspection
+}
+
+// QueueRecommendations suggests spreading receive processing over every CPU
+// with RPS when the NIC has fewer receive queues than CPUs, pinning transmit
+// queues to CPUs with XPS, and spreading the NIC's interrupts one CPU each.
+func QueueRecommendations(inspection *QueueInspection) []TuneRecommendation {
+	cpus := inspection.CPUs
+	if cpus < 2 {
+		return nil
+	}
+	iface := inspection.Interface
+	var recs []TuneRecommendation
+
+	all := make([]int, cpus)
+	for cpu := range all {
+		all[cpu] = cpu
+	}
+	rx := sortedQueues(inspection.RxQueues)
+	if len(rx) < cpus {
+		for _, queue := range rx {
+			if current := inspection.RxQueues[queue]; !sameCPUs(parseCPUMask(current), all) {
+				recs = append(recs, TuneRecommendation{
+					Setting:     fmt.Sprintf("%s %s rps_cpus", iface, queue),
+					Current:     current,
+					Recommended: FormatCPUMask(all),
+					Reason:      fmt.Sprintf("RPS spreads decryption over all CPUs when the NIC has %d receive queues for %d CPUs", len(rx), cpus),
+					Command:     fmt.Sprintf("echo %s > %s", FormatCPUMask(all), filepath.Join("/sys/class/net", iface, "queues", queue, "rps_cpus")),
+				})
+			}
+		}
+	}
+
+	tx := sortedQueues(inspection.TxQueues)
+	if len(tx) > 1 {
+		for idx, queue := range tx {
+			var want []int
+			for cpu := idx; cpu < cpus; cpu += len(tx) {
+				want = append(want, cpu)
+			}
+			current := inspection.TxQueues[queue]
+			if len(want) == 0 || sameCPUs(parseCPUMask(current), want) {
+				continue
+			}
+			recs = append(recs, TuneRecommendation{
+				Setting:     fmt.Sprintf("%s %s xps_cpus", iface, queue),
+				Current:     current,
+				Recommended: FormatCPUMask(want),
+				Reason:      "XPS keeps each CPU transmitting on its own queue, avoiding lock contention between CPUs",
+				Command:     fmt.Sprintf("echo %s > %s", FormatCPUMask(want), filepath.Join("/sys/class/net", iface, "queues", queue, "xps_cpus")),
+			})
+		}
+	}
+
+	if len(inspection.IRQs) > 1 {
+		for idx, irq := range inspection.IRQs {
+			want := idx % cpus
+			if sameCPUs(parseCPUList(irq.CPUs), []int{want}) {
+				continue
+			}
+			recs = append(recs, TuneRecommendation{
+				Setting:     fmt.Sprintf("irq %d (%s) affinity", irq.IRQ, irq.Name),
+				Current:     irq.CPUs,
+				Recommended: strconv.Itoa(want),
+				Reason:      "one CPU per queue interrupt keeps every queue's packets on a different CPU",
+				Command:     fmt.Sprintf("echo %d > %s", want, filepath.Join("/proc/irq", strconv.Itoa(irq.IRQ), "smp_affinity_list")),
+			})
+		}
+	}
+	return recs
+}
+
+// ParseInterrupts returns the interrupts in /proc/interrupts output whose
+// device name is iface or starts with iface followed by a dash, such as
+// eth0-TxRx-3, in IRQ order.
+func ParseInterrupts(output, iface string) []IRQAffinity {
+	var irqs []IRQAffinity
+	for _, line := range strings.Split(output, "\n") {
+		number, rest, ok := strings.Cut(strings.TrimSpace(line), ":")
+		irq, err := strconv.Atoi(number)
+		fields := strings.Fields(rest)
+		if !ok || err != nil || len(fields) == 0 {
+			continue
+		}
+		name := fields[len(fields)-1]
+		if name == iface || strings.HasPrefix(name, iface+"-") {
+			irqs = append(irqs, IRQAffinity{IRQ: irq, Name: name})
+		}
+	}
+	sort.Slice(irqs, func(i, j int) bool { return irqs[i].IRQ < irqs[j].IRQ })
+	return irqs
+}
+
+// FormatCPUMask renders CPUs as a hex bitmap in the kernel's format, with
+// 32-bit groups separated by commas.
+func FormatCPUMask(cpus []int) string {
+	mask := new(big.Int)
+	for _, cpu := range cpus {
+		mask.SetBit(mask, cpu, 1)
+	}
+	hex := mask.Text(16)
+	var groups []string
+	for len(hex) > 8 {
+		groups = append([]string{hex[len(hex)-8:]}, groups...)
+		hex = hex[:len(hex)-8]
+	}
+	return strings.Join(append([]string{hex}, groups...), ",")
+}
+
+// parseCPUMask reads a kernel hex CPU bitmap such as "00000000,0000000f".
+// Unparsable masks yield no CPUs.
+func parseCPUMask(mask string) []int {
+	mask = strings.ReplaceAll(strings.TrimSpace(mask), ",", "")
+	value, ok := new(big.Int).SetString(mask, 16)
+	if !ok {
+		return nil
+	}
+	var cpus []int
+	for cpu := 0; cpu < value.BitLen(); cpu++ {
+		if value.Bit(cpu) == 1 {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
+
+// parseCPUList reads a kernel CPU list such as "0-3,8". Unparsable entries are skipped.
+func parseCPUList(list string) []int {
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				continue
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
+
+// sameCPUs reports whether two CPU lists hold the same CPUs.
+func sameCPUs(a, b []int) bool {
+	return FormatCPUMask(a) == FormatCPUMask(b)
+}
+
+// sortedQueues returns queue names such as rx-10 in numeric order.
+func sortedQueues(queues map[string]string) []string {
+	names := make([]string, 0, len(queues))
+	for name := range queues {
+		names = append(names, name)
+	}
+	index := func(name string) int {
+		_, number, _ := strings.Cut(name, "-")
+		value, _ := strconv.Atoi(number)
+		return value
+	}
+	sort.Slice(names, func(i, j int) bool { return index(names[i]) < index(names[j]) })
+	return names
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCPUMasks(t *testing.T) {
+	if mask := FormatCPUMask([]int{0, 1, 2, 3}); mask != "f" {
+		t.Fatalf("unexpected mask %q", mask)
+	}
+	if mask := FormatCPUMask([]int{0, 33}); mask != "2,00000001" {
+		t.Fatalf("unexpected mask %q", mask)
+	}
+	if cpus := parseCPUMask("00000002,00000001"); !sameCPUs(cpus, []int{0, 33}) {
+		t.Fatalf("unexpected CPUs %v", cpus)
+	}
+	if cpus := parseCPUList("0-2,5"); !sameCPUs(cpus, []int{0, 1, 2, 5}) {
+		t.Fatalf("unexpected CPUs %v", cpus)
+	}
+}
+
+func TestQueueRecommendations(t *testing.T) {
+	fakeWG(t)
+	root := t.TempDir()
+	sysClassNetRoot = filepath.Join(root, "net")
+	procIRQRoot = filepath.Join(root, "irq")
+	procInterrupts = filepath.Join(root, "interrupts")
+	t.Cleanup(func() {
+		sysClassNetRoot, procIRQRoot, procInterrupts = "/sys/class/net", "/proc/irq", "/proc/interrupts"
+	})
+	for path, value := range map[string]string{
+		"net/eth0/queues/rx-0/rps_cpus": "0",
+		"net/eth0/queues/rx-1/rps_cpus": "f",
+		"net/eth0/queues/tx-0/xps_cpus": "0",
+		"net/eth0/queues/tx-1/xps_cpus": "a",
+		"irq/40/smp_affinity_list":      "0-3",
+		"irq/41/smp_affinity_list":      "1",
+		"interrupts":                    "           CPU0       CPU1\n  40:   10   0  IR-PCI-MSI 1-edge  eth0-TxRx-0\n  41:   0   12  IR-PCI-MSI 2-edge  eth0-TxRx-1\n  42:   5   0  IR-PCI-MSI 3-edge  eth10-TxRx-0\n",
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	inspection := InspectQueues("eth0")
+	if len(inspection.RxQueues) != 2 || len(inspection.TxQueues) != 2 || len(inspection.IRQs) != 2 {
+		t.Fatalf("unexpected inspection %+v", inspection)
+	}
+	if inspection.IRQs[0].Name != "eth0-TxRx-0" || inspection.IRQs[0].CPUs != "0-3" {
+		t.Fatalf("unexpected IRQ %+v", inspection.IRQs[0])
+	}
+
+	inspection.CPUs = 4
+	var commands []string
+	for _, rec := range QueueRecommendations(inspection) {
+		commands = append(commands, rec.Command)
+	}
+	want := []string{
+		"echo f > /sys/class/net/eth0/queues/rx-0/rps_cpus",
+		"echo 5 > /sys/class/net/eth0/queues/tx-0/xps_cpus",
+		"echo 0 > /proc/irq/40/smp_affinity_list",
+	}
+	if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected commands:\n%s", strings.Join(commands, "\n"))
+	}
+
+	inspection.CPUs = 1
+	if recs := QueueRecommendations(inspection); len(recs) != 0 {
+		t.Fatalf("expected no recommendations on a single CPU, got %v", recs)
+	}
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidName is returned (wrapped) for a server or client name that could
+// not safely name an interface or a file under ~/.wirestack.
+var ErrInvalidName = errors.New("invalid name")
+
+// profileName is wg-quick's rule for interface names. Server names become
+// interface names, and both server and client names become file names, so
+// the rule also keeps path separators out of them.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_=+.-]{1,15}$`)
+
+// ValidateServerName checks that name can be used for a new server profile.
+func ValidateServerName(name string) error {
+	return validateName("server", name)
+}
+
+// ValidateClientName checks that name can be used for a new client.
+func ValidateClientName(name string) error {
+	return validateName("client", name)
+}
+
+// validateName applies profileName and rejects the relative directory names
+// it would otherwise let through.
+func validateName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s name is empty: %w", kind, ErrInvalidName)
+	}
+	if name == "." || name == ".." || !profileName.MatchString(name) {
+		return fmt.Errorf("%s name %q: %w (want 1-15 letters, digits, or _=+.-)", kind, name, ErrInvalidName)
+	}
+	return nil
+}
+
+// checkPathName rejects names that would leave their directory when used in
+// a file name. Unlike validateName it accepts long names, which profiles
+// created before names were validated may have.
+func checkPathName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s name is empty: %w", kind, ErrInvalidName)
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("%s name %q cannot name a file: %w", kind, name, ErrInvalidName)
+	}
+	return nil
+}
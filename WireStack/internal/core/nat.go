@@ -0,0 +1,145 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Firewall tools NAT rules can be generated for.
+const (
+	NATIptables = "iptables"
+	NATNftables = "nftables"
+)
+
+// validInterfaceName matches Linux interface names (IFNAMSIZ minus the NUL).
+var validInterfaceName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,15}$`)
+
+// SetNAT enables masquerading of the server's client subnets out of egress
+// using tool (iptables by default). An empty egress disables NAT.
+func SetNAT(profile *ServerProfile, egress, tool string) error {
+	if egress == "" {
+		profile.NATInterface, profile.NATBackend = "", ""
+		return nil
+	}
+	if profile.ExternalInterface != "" {
+		return fmt.Errorf("NAT rules are rendered into the server config, which is not used with --external-interface")
+	}
+	if !validInterfaceName.MatchString(egress) {
+		return fmt.Errorf("invalid egress interface name %q", egress)
+	}
+	switch tool {
+	case "", NATIptables:
+		tool = NATIptables
+	case NATNftables:
+	default:
+		return fmt.Errorf("unsupported NAT backend %q (want %s or %s)", tool, NATIptables, NATNftables)
+	}
+	profile.NATInterface, profile.NATBackend = egress, tool
+	return nil
+}
+
+// SetServerHooks stores custom PostUp and PostDown commands for the server config.
+func SetServerHooks(profile *ServerProfile, postUp, postDown []string) error {
+	if profile.ExternalInterface != "" && len(postUp)+len(postDown) > 0 {
+		return fmt.Errorf("hooks are rendered into the server config, which is not used with --external-interface")
+	}
+	for _, hook := range append(append([]string(nil), postUp...), postDown...) {
+		if strings.ContainsAny(hook, "\r\n") {
+			return fmt.Errorf("hook %q must be a single line", hook)
+		}
+	}
+	profile.PostUp = postUp
+	profile.PostDown = postDown
+	return nil
+}
+
+// ServerHooks returns the PostUp and PostDown commands rendered into the
+// server config: the alias, then NAT rules, then custom hooks on the way up,
+// and the reverse on the way down.
+func ServerHooks(profile *ServerProfile) ([]string, []string) {
+	var postUp, postDown []string
+	if profile.Alias != "" {
+		postUp = append(postUp, aliasHook(profile.Alias))
+	}
+	natUp, natDown := NATHooks(profile)
+	postUp = append(postUp, natUp...)
+	postUp = append(postUp, profile.PostUp...)
+	postDown = append(postDown, profile.PostDown...)
+	postDown = append(postDown, natDown...)
+	return postUp, postDown
+}
+
+// NATHooks renders the forwarding and MASQUERADE rules for the profile's
+// client subnets and the clients' port forwards, or nothing when NAT is not
+// enabled. IP forwarding is
+// switched on when the interface comes up and left on when it goes down.
+func NATHooks(profile *ServerProfile) ([]string, []string) {
+	if profile.NATInterface == "" {
+		return nil, nil
+	}
+	subnet := profile.Subnet
+	if subnet == "" {
+		subnet = DefaultSubnet
+	}
+	egress := profile.NATInterface
+
+	if profile.NATBackend == NATNftables {
+		table := "inet " + nftTableName(profile)
+		up := []string{
+			"nft add table " + table,
+			"nft add chain " + table + " forward '{ type filter hook forward priority 0; policy accept; }'",
+			"nft add rule " + table + " forward iifname %i accept",
+			"nft add rule " + table + " forward oifname %i ct state related,established accept",
+			"nft add chain " + table + " postrouting '{ type nat hook postrouting priority 100; policy accept; }'",
+			fmt.Sprintf("nft add rule %s postrouting ip saddr %s oifname %s masquerade", table, subnet, egress),
+		}
+		if profile.Subnet6 != "" {
+			up = append(up, fmt.Sprintf("nft add rule %s postrouting ip6 saddr %s oifname %s masquerade", table, profile.Subnet6, egress))
+		}
+		if forwardUp, _ := portForwardRules(profile, true); len(forwardUp) > 0 {
+			up = append(up, "nft add chain "+table+" prerouting '{ type nat hook prerouting priority -100; policy accept; }'")
+			up = append(up, forwardUp...)
+		}
+		return append(forwardingSysctls(profile), strings.Join(up, "; ")), []string{"nft delete table " + table}
+	}
+
+	var up, down []string
+	add := func(tool, network string) {
+		for _, rule := range []string{
+			"FORWARD -i %i -j ACCEPT",
+			"FORWARD -o %i -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+			fmt.Sprintf("POSTROUTING -s %s -o %s -j MASQUERADE", network, egress),
+		} {
+			table := ""
+			if strings.HasPrefix(rule, "POSTROUTING") {
+				table = "-t nat "
+			}
+			up = append(up, tool+" "+table+"-A "+rule)
+			down = append(down, tool+" "+table+"-D "+rule)
+		}
+	}
+	add("iptables", subnet)
+	if profile.Subnet6 != "" {
+		add("ip6tables", profile.Subnet6)
+	}
+	forwardUp, forwardDown := portForwardRules(profile, false)
+	up = append(up, forwardUp...)
+	down = append(down, forwardDown...)
+	return append(forwardingSysctls(profile), strings.Join(up, "; ")), []string{strings.Join(down, "; ")}
+}
+
+// forwardingSysctls enables packet forwarding for the families the server routes.
+func forwardingSysctls(profile *ServerProfile) []string {
+	hooks := []string{"sysctl -q -w net.ipv4.ip_forward=1"}
+	if profile.Subnet6 != "" {
+		hooks = append(hooks, "sysctl -q -w net.ipv6.conf.all.forwarding=1")
+	}
+	return hooks
+}
+
+// nftTableName is the nftables table holding a server's rules, shared by the
+// NAT hooks and the firewall ruleset so either one replaces the other.
+func nftTableName(profile *ServerProfile) string {
+	return "wirestack_" + strings.NewReplacer("-", "_", ".", "_").Replace(InterfaceName(profile))
+}
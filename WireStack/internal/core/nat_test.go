@@ -0,0 +1,136 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNATHooksInServerConfig(t *testing.T) {
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Alias = "wirestack:edge"
+	if err := SetNAT(profile, "eth0", ""); err != nil {
+		t.Fatalf("SetNAT: %v", err)
+	}
+	if err := SetServerHooks(profile, []string{"logger up %i"}, []string{"logger down %i"}); err != nil {
+		t.Fatalf("SetServerHooks: %v", err)
+	}
+	config, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	for _, want := range []string{
+		"PostUp = ip link set dev %i alias 'wirestack:edge'\n",
+		"PostUp = sysctl -q -w net.ipv4.ip_forward=1\n",
+		"iptables -A FORWARD -i %i -j ACCEPT; ",
+		"iptables -t nat -A POSTROUTING -s 10.0.0.0/24 -o eth0 -j MASQUERADE\n",
+		"PostUp = logger up %i\n",
+		"PostDown = logger down %i\nPostDown = iptables -D FORWARD -i %i -j ACCEPT; ",
+		"iptables -t nat -D POSTROUTING -s 10.0.0.0/24 -o eth0 -j MASQUERADE\n",
+	} {
+		if !strings.Contains(config, want) {
+			t.Fatalf("expected %q in server config:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "ip6tables") {
+		t.Fatalf("did not expect IPv6 rules without --subnet6:\n%s", config)
+	}
+	if strings.Index(config, "logger up") < strings.Index(config, "MASQUERADE") {
+		t.Fatalf("expected custom hooks after the NAT rules:\n%s", config)
+	}
+
+	if err := ApplySubnet6(profile, "fd00:9::/64"); err != nil {
+		t.Fatalf("ApplySubnet6: %v", err)
+	}
+	if err := SetNAT(profile, "eth0", NATNftables); err != nil {
+		t.Fatalf("SetNAT nftables: %v", err)
+	}
+	up, down := NATHooks(profile)
+	joined := strings.Join(up, "\n")
+	if !strings.Contains(joined, "net.ipv6.conf.all.forwarding=1") || !strings.Contains(joined, "ip6 saddr fd00:9::/64 oifname eth0 masquerade") {
+		t.Fatalf("unexpected nftables hooks:\n%s", joined)
+	}
+	if len(down) != 1 || down[0] != "nft delete table inet wirestack_edge" {
+		t.Fatalf("unexpected nftables teardown %v", down)
+	}
+
+	if err := SetNAT(profile, "eth0; reboot", ""); err == nil {
+		t.Fatalf("expected an invalid interface name to be rejected")
+	}
+	if err := SetNAT(profile, "eth0", "pf"); err == nil {
+		t.Fatalf("expected an unknown NAT backend to be rejected")
+	}
+	if err := SetServerHooks(profile, []string{"echo a\nreboot"}, nil); err == nil {
+		t.Fatalf("expected a multi-line hook to be rejected")
+	}
+}
+
+func TestPortForwardHooks(t *testing.T) {
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{
+		{Name: "nas", Address: "10.0.0.2/32", AllowedIPs: ClientAllowedIPs()},
+		{Name: "laptop", Address: "10.0.0.3/32", Mode: ClientModeSplit, AllowedIPs: []string{"10.0.0.0/24"}},
+	}
+	forward, err := ParsePortForward("tcp:8080:80")
+	if err != nil {
+		t.Fatalf("ParsePortForward: %v", err)
+	}
+	if err := SetClientPortForwards(profile, &profile.Clients[0], []PortForward{forward}); err == nil {
+		t.Fatalf("expected port forwards without NAT to be rejected")
+	}
+	if err := SetNAT(profile, "eth0", ""); err != nil {
+		t.Fatalf("SetNAT: %v", err)
+	}
+	if err := SetClientPortForwards(profile, &profile.Clients[0], []PortForward{forward}); err != nil {
+		t.Fatalf("SetClientPortForwards: %v", err)
+	}
+	game, err := ParsePortForward("udp:27015")
+	if err != nil || game != (PortForward{Protocol: ForwardUDP, PublicPort: 27015, ClientPort: 27015}) {
+		t.Fatalf("unexpected forward %+v (%v)", game, err)
+	}
+	if err := SetClientPortForwards(profile, &profile.Clients[1], []PortForward{game}); err != nil {
+		t.Fatalf("SetClientPortForwards: %v", err)
+	}
+
+	up, down := NATHooks(profile)
+	joined := strings.Join(up, "\n")
+	for _, want := range []string{
+		"iptables -t nat -A PREROUTING -i eth0 -p tcp --dport 8080 -j DNAT --to-destination 10.0.0.2:80",
+		"iptables -A FORWARD -i eth0 -o %i -p tcp -d 10.0.0.2 --dport 80 -j ACCEPT",
+		"iptables -t nat -A POSTROUTING -o %i -p udp -d 10.0.0.3 --dport 27015 -j MASQUERADE",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in PostUp:\n%s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "-d 10.0.0.2 --dport 80 -j MASQUERADE") {
+		t.Fatalf("did not expect a full-tunnel client's forward to be masqueraded:\n%s", joined)
+	}
+	if !strings.Contains(strings.Join(down, "\n"), "iptables -t nat -D PREROUTING -i eth0 -p tcp --dport 8080 -j DNAT --to-destination 10.0.0.2:80") {
+		t.Fatalf("expected the DNAT rule to be removed in PostDown: %v", down)
+	}
+
+	if err := SetNAT(profile, "eth0", NATNftables); err != nil {
+		t.Fatalf("SetNAT nftables: %v", err)
+	}
+	up, _ = NATHooks(profile)
+	if joined := strings.Join(up, "\n"); !strings.Contains(joined, "prerouting iifname eth0 tcp dport 8080 dnat ip to 10.0.0.2:80") {
+		t.Fatalf("unexpected nftables hooks:\n%s", joined)
+	}
+
+	taken := PortForward{Protocol: ForwardTCP, PublicPort: 8080, ClientPort: 22}
+	if err := SetClientPortForwards(profile, &profile.Clients[1], []PortForward{taken}); err == nil {
+		t.Fatalf("expected a public port used by another client to be rejected")
+	}
+	if len(profile.Clients[1].PortForwards) != 1 || profile.Clients[1].PortForwards[0] != game {
+		t.Fatalf("expected a rejected change to keep the old forwards, got %v", profile.Clients[1].PortForwards)
+	}
+	listen := PortForward{Protocol: ForwardUDP, PublicPort: 51820, ClientPort: 51820}
+	if err := SetClientPortForwards(profile, &profile.Clients[1], []PortForward{listen}); err == nil {
+		t.Fatalf("expected the server's listen port to be rejected")
+	}
+	for _, spec := range []string{"", "tcp", "sctp:80", "0", "1:2:3", "tcp:80:99999"} {
+		if _, err := ParsePortForward(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// DefaultSubnet is the client network used when a profile does not specify one.
+const DefaultSubnet = "10.0.0.0/24"
+
+// ParseSubnet validates an IPv4 CIDR and returns its normalized network.
+func ParseSubnet(cidr string) (*net.IPNet, error) {
+	network, err := parseNetwork(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if network.IP.To4() == nil {
+		return nil, fmt.Errorf("subnet %s is not IPv4", cidr)
+	}
+	return network, nil
+}
+
+// ParseSubnet6 validates an IPv6 CIDR and returns its normalized network.
+func ParseSubnet6(cidr string) (*net.IPNet, error) {
+	network, err := parseNetwork(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if network.IP.To4() != nil {
+		return nil, fmt.Errorf("subnet %s is not IPv6", cidr)
+	}
+	return network, nil
+}
+
+// parseNetwork parses a CIDR and checks it leaves room for a server and clients.
+func parseNetwork(cidr string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %s: %w", cidr, err)
+	}
+	if ip4 := network.IP.To4(); ip4 != nil {
+		network.IP = ip4
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones < 2 {
+		return nil, fmt.Errorf("subnet %s is too small to hold a server and clients", cidr)
+	}
+	return network, nil
+}
+
+// ApplySubnet stores the IPv4 subnet on the profile and assigns the server the first host address.
+func ApplySubnet(profile *ServerProfile, cidr string) error {
+	network, err := ParseSubnet(cidr)
+	if err != nil {
+		return err
+	}
+	ones, _ := network.Mask.Size()
+	profile.Subnet = network.String()
+	profile.Address = fmt.Sprintf("%s/%d", hostAddress(network, 1).String(), ones)
+	return nil
+}
+
+// ApplySubnet6 stores the IPv6 subnet on the profile and assigns the server the first host address.
+func ApplySubnet6(profile *ServerProfile, cidr string) error {
+	network, err := ParseSubnet6(cidr)
+	if err != nil {
+		return err
+	}
+	ones, _ := network.Mask.Size()
+	profile.Subnet6 = network.String()
+	profile.Address6 = fmt.Sprintf("%s/%d", hostAddress(network, 1).String(), ones)
+	return nil
+}
+
+// ClientSubnet returns the network clients are allocated from. Profiles created
+// before subnets were configurable fall back to the network of the server address.
+func ClientSubnet(profile *ServerProfile) (*net.IPNet, error) {
+	if profile.Subnet != "" {
+		return ParseSubnet(profile.Subnet)
+	}
+	if profile.Address != "" {
+		if _, network, err := net.ParseCIDR(profile.Address); err == nil && network.IP.To4() != nil {
+			network.IP = network.IP.To4()
+			return network, nil
+		}
+	}
+	return ParseSubnet(DefaultSubnet)
+}
+
+// AllocateAddress returns the lowest free host address in the network, skipping the
+// network and last addresses, the first host (reserved for the server), and used.
+func AllocateAddress(network *net.IPNet, used []net.IP) (net.IP, error) {
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last := new(big.Int).Sub(size, big.NewInt(1))
+
+	base := ipToInt(network.IP)
+	taken := make(map[uint64]bool, len(used))
+	for _, ip := range used {
+		if !network.Contains(ip) {
+			continue
+		}
+		offset := new(big.Int).Sub(ipToInt(ip), base)
+		if offset.IsUint64() {
+			taken[offset.Uint64()] = true
+		}
+	}
+
+	for offset := uint64(2); new(big.Int).SetUint64(offset).Cmp(last) < 0; offset++ {
+		if !taken[offset] {
+			return hostAddress(network, offset), nil
+		}
+	}
+	return nil, fmt.Errorf("client capacity exceeded for network %s", network.String())
+}
+
+// parseAddress accepts either a bare IP or a CIDR-style interface address.
+func parseAddress(value string) net.IP {
+	if ip, _, err := net.ParseCIDR(value); err == nil {
+		return ip
+	}
+	return net.ParseIP(value)
+}
+
+// hostAddress returns the address at the given offset from the network base.
+func hostAddress(network *net.IPNet, offset uint64) net.IP {
+	value := new(big.Int).Add(ipToInt(network.IP), new(big.Int).SetUint64(offset))
+	size := len(network.IP)
+	raw := value.Bytes()
+	ip := make(net.IP, size)
+	copy(ip[size-len(raw):], raw)
+	return ip
+}
+
+// ipToInt converts an IP to an integer in its own family's width.
+func ipToInt(ip net.IP) *big.Int {
+	if ip4 := ip.To4(); ip4 != nil {
+		return new(big.Int).SetBytes(ip4)
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+// SetServerNetworks replaces the routed networks behind the server and
+// recomputes the AllowedIPs of split-tunnel clients so they reach them.
+// Networks must not overlap each other or the client subnets, and the
+// default route is rejected since full-tunnel clients already cover it.
+func SetServerNetworks(profile *ServerProfile, networks []string) error {
+	normalized, err := normalizeCIDRs(networks, "network")
+	if err != nil {
+		return err
+	}
+	if err := checkServerNetworks(profile, normalized); err != nil {
+		return err
+	}
+	profile.Networks = normalized
+	for idx := range profile.Clients {
+		client := &profile.Clients[idx]
+		if ClientMode(*client) != ClientModeSplit || len(client.CustomAllowedIPs) > 0 {
+			continue
+		}
+		allowed, err := SplitAllowedIPs(profile, client.SplitRoutes)
+		if err != nil {
+			return fmt.Errorf("client %s: %w", client.Name, err)
+		}
+		client.AllowedIPs = allowed
+	}
+	return nil
+}
+
+// checkServerNetworks reports the first routed network that is the default
+// route or overlaps a client subnet or another network.
+func checkServerNetworks(profile *ServerProfile, networks []string) error {
+	var subnets []*net.IPNet
+	if network, err := ClientSubnet(profile); err == nil {
+		subnets = append(subnets, network)
+	}
+	if profile.Subnet6 != "" {
+		if network6, err := ParseSubnet6(profile.Subnet6); err == nil {
+			subnets = append(subnets, network6)
+		}
+	}
+	var seen []*net.IPNet
+	for _, cidr := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid network %s: %w", cidr, err)
+		}
+		if ones, _ := network.Mask.Size(); ones == 0 {
+			return fmt.Errorf("network %s is the default route; use a full-tunnel client instead", cidr)
+		}
+		for _, subnet := range subnets {
+			if subnet.Contains(network.IP) || network.Contains(subnet.IP) {
+				return fmt.Errorf("network %s overlaps client subnet %s", cidr, subnet)
+			}
+		}
+		for _, other := range seen {
+			if other.Contains(network.IP) || network.Contains(other.IP) {
+				return fmt.Errorf("network %s overlaps network %s", cidr, other)
+			}
+		}
+		seen = append(seen, network)
+	}
+	return nil
+}
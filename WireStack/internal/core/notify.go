@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of Notification.
+const (
+	NotificationAlert  = "alert"
+	NotificationDigest = "digest"
+)
+
+// DefaultCriticalEvents are the event types sent at once in digest mode when
+// NotifySettings.Critical is unset.
+var DefaultCriticalEvents = []string{EventInterfaceDown, EventPeerOffline}
+
+// NotifyTimeout bounds one webhook delivery.
+const NotifyTimeout = 10 * time.Second
+
+// NotifySettings configures the webhook wirestack serve posts events to.
+type NotifySettings struct {
+	Webhook string `json:"webhook"`
+	// Digest is a Go duration such as "1h". When set, non-critical events are
+	// collected and sent as one summary per interval; otherwise every event
+	// is sent on its own.
+	Digest string `json:"digest,omitempty"`
+	// Critical lists the event types sent at once even in digest mode;
+	// DefaultCriticalEvents when unset.
+	Critical []string `json:"critical,omitempty"`
+}
+
+// Validate checks the webhook URL and the digest interval.
+func (s *NotifySettings) Validate() error {
+	parsed, err := url.Parse(s.Webhook)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("notify.webhook must be an http or https URL, got %q", s.Webhook)
+	}
+	_, err = s.DigestInterval()
+	return err
+}
+
+// DigestInterval parses Digest, returning zero when digests are off.
+func (s *NotifySettings) DigestInterval() (time.Duration, error) {
+	if s == nil {
+		return 0, nil
+	}
+	return parsePositiveDuration("notify.digest", s.Digest)
+}
+
+// IsCritical reports whether events of eventType skip the digest.
+func (s *NotifySettings) IsCritical(eventType string) bool {
+	critical := DefaultCriticalEvents
+	if s != nil && len(s.Critical) > 0 {
+		critical = s.Critical
+	}
+	for _, kind := range critical {
+		if kind == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification is the JSON body posted to the webhook. Text is a readable
+// summary, which chat services such as Slack and Mattermost display as is.
+type Notification struct {
+	Kind   string    `json:"kind"`
+	Text   string    `json:"text"`
+	Events []Event   `json:"events"`
+	Since  time.Time `json:"since,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
+}
+
+// AlertNotification wraps a single event.
+func AlertNotification(event Event) Notification {
+	return Notification{Kind: NotificationAlert, Text: "WireStack: " + describeEvent(event), Events: []Event{event}}
+}
+
+// DigestNotification summarizes the events collected between since and
+// until, one line per event type with the servers and clients involved.
+func DigestNotification(events []Event, since, until time.Time) Notification {
+	byType := map[string][]string{}
+	for _, event := range events {
+		subject := event.Server
+		if event.Client != "" {
+			subject += "/" + event.Client
+		}
+		byType[event.Type] = append(byType[event.Type], subject)
+	}
+	types := make([]string, 0, len(byType))
+	for kind := range byType {
+		types = append(types, kind)
+	}
+	sort.Strings(types)
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "WireStack digest: %d events since %s", len(events), since.UTC().Format(time.RFC3339))
+	for _, kind := range types {
+		fmt.Fprintf(&text, "\n%s (%d): %s", kind, len(byType[kind]), strings.Join(byType[kind], ", "))
+	}
+	return Notification{Kind: NotificationDigest, Text: text.String(), Events: events, Since: since, Until: until}
+}
+
+// describeEvent renders one event as a sentence fragment.
+func describeEvent(event Event) string {
+	if event.Client != "" {
+		return fmt.Sprintf("%s for client %s of server %s", event.Type, event.Client, event.Server)
+	}
+	return fmt.Sprintf("%s for server %s", event.Type, event.Server)
+}
+
+// SendNotification posts notification to webhook as JSON. Any 2xx response
+// counts as delivered.
+func SendNotification(ctx context.Context, client *http.Client, webhook string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, NotifyTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("notify webhook: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("notify webhook returned %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
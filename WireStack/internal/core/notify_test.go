@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifySettings(t *testing.T) {
+	settings := &NotifySettings{Webhook: "https://hooks.example.com/wirestack", Digest: "30m"}
+	if err := settings.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if interval, _ := settings.DigestInterval(); interval != 30*time.Minute {
+		t.Fatalf("expected a 30m digest, got %s", interval)
+	}
+	if !settings.IsCritical(EventInterfaceDown) || settings.IsCritical(EventClientAdded) {
+		t.Fatal("expected the default critical events")
+	}
+	settings.Critical = []string{EventServerRemoved}
+	if settings.IsCritical(EventPeerOffline) || !settings.IsCritical(EventServerRemoved) {
+		t.Fatal("expected Critical to replace the defaults")
+	}
+	for _, bad := range []NotifySettings{{Webhook: "hooks.example.com"}, {Webhook: "ftp://hooks.example.com"}, {Webhook: "https://hooks.example.com", Digest: "-1m"}} {
+		if err := bad.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestDigestNotification(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Type: EventClientChanged, Server: "lab", Client: "bob"},
+		{Type: EventClientAdded, Server: "lab", Client: "alice"},
+		{Type: EventClientAdded, Server: "edge", Client: "carol"},
+	}
+	digest := DigestNotification(events, since, since.Add(time.Hour))
+	want := "WireStack digest: 3 events since 2024-05-01T12:00:00Z\n" +
+		"client_added (2): lab/alice, edge/carol\n" +
+		"client_changed (1): lab/bob"
+	if digest.Kind != NotificationDigest || digest.Text != want || len(digest.Events) != 3 {
+		t.Fatalf("unexpected digest %q", digest.Text)
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	alert := AlertNotification(Event{Type: EventInterfaceDown, Server: "lab"})
+	if err := SendNotification(context.Background(), server.Client(), server.URL, alert); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+	status = http.StatusBadGateway
+	if err := SendNotification(context.Background(), server.Client(), server.URL, alert); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected the 502 reported, got %v", err)
+	}
+}
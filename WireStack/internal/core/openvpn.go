@@ -0,0 +1,215 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenVPNClient is a client discovered in an OpenVPN deployment. Address is
+// the static or last-known virtual IPv4 address and may be empty.
+type OpenVPNClient struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+}
+
+// MigrationEntry maps one OpenVPN client onto its WireStack replacement.
+type MigrationEntry struct {
+	Client     string `json:"client"`
+	OldAddress string `json:"old_address,omitempty"`
+	NewAddress string `json:"new_address,omitempty"`
+	Status     string `json:"status"`
+}
+
+const (
+	// MigrationCreated marks a client that was created with a fresh address.
+	MigrationCreated = "created"
+	// MigrationPreserved marks a client that kept its OpenVPN address.
+	MigrationPreserved = "created (address kept)"
+	// MigrationSkipped marks a client that already existed on the server.
+	MigrationSkipped = "skipped (client exists)"
+)
+
+// ReadOpenVPNCCD reads client names and `ifconfig-push` addresses from an
+// OpenVPN client-config-dir. Each file is named after the client's common name.
+func ReadOpenVPNCCD(dir string) ([]OpenVPNClient, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read ccd dir: %w", err)
+	}
+	var clients []OpenVPNClient
+	for _, entry := range entries {
+		name := entry.Name()
+		// DEFAULT applies to every client without its own file; it is not a client.
+		if entry.IsDir() || strings.HasPrefix(name, ".") || name == "DEFAULT" {
+			continue
+		}
+		client := OpenVPNClient{Name: name}
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read ccd file %s: %w", name, err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "ifconfig-push" {
+				client.Address = fields[1]
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read ccd file %s: %w", name, err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// ReadOpenVPNStatus reads connected clients and their virtual addresses from an
+// OpenVPN status file in any of the three status-version formats.
+func ReadOpenVPNStatus(path string) ([]OpenVPNClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read status file: %w", err)
+	}
+	return ParseOpenVPNStatus(string(data)), nil
+}
+
+// ParseOpenVPNStatus extracts clients from status file contents.
+func ParseOpenVPNStatus(text string) []OpenVPNClient {
+	seen := map[string]int{}
+	var clients []OpenVPNClient
+	record := func(name, address string) {
+		if name == "" || name == "UNDEF" {
+			return
+		}
+		if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+			address = ""
+		}
+		if idx, ok := seen[name]; ok {
+			if clients[idx].Address == "" {
+				clients[idx].Address = address
+			}
+			return
+		}
+		seen[name] = len(clients)
+		clients = append(clients, OpenVPNClient{Name: name, Address: address})
+	}
+
+	inRoutingTable := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		separator := ","
+		if strings.Contains(line, "\t") {
+			separator = "\t"
+		}
+		fields := strings.Split(line, separator)
+		switch {
+		case fields[0] == "CLIENT_LIST" && len(fields) > 3:
+			// status-version 2/3: CLIENT_LIST,CN,Real Address,Virtual Address,...
+			record(fields[1], fields[3])
+		case line == "ROUTING TABLE":
+			inRoutingTable = true
+		case line == "GLOBAL STATS":
+			inRoutingTable = false
+		case inRoutingTable && len(fields) >= 2 && fields[0] != "Virtual Address":
+			// status-version 1: Virtual Address,Common Name,Real Address,Last Ref
+			record(fields[1], fields[0])
+		}
+	}
+	return clients
+}
+
+// MergeOpenVPNClients combines ccd and status rosters. Static ccd addresses win
+// over addresses observed in the status file. The result is sorted by name.
+func MergeOpenVPNClients(lists ...[]OpenVPNClient) []OpenVPNClient {
+	merged := map[string]OpenVPNClient{}
+	for _, list := range lists {
+		for _, client := range list {
+			existing, ok := merged[client.Name]
+			if !ok || existing.Address == "" {
+				merged[client.Name] = client
+			}
+		}
+	}
+	clients := make([]OpenVPNClient, 0, len(merged))
+	for _, client := range merged {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Name < clients[j].Name })
+	return clients
+}
+
+// MigrateOpenVPNClients creates a WireStack client with new keys for every
+// OpenVPN client. A client keeps its old address when it falls inside the
+// server subnet and is free; otherwise it gets the next free address. The
+// caller is responsible for saving the profile.
+func MigrateOpenVPNClients(profile *ServerProfile, clients []OpenVPNClient) ([]MigrationEntry, error) {
+	network, err := ClientSubnet(profile)
+	if err != nil {
+		return nil, err
+	}
+	report := make([]MigrationEntry, 0, len(clients))
+	for _, openvpn := range clients {
+		entry := MigrationEntry{Client: openvpn.Name, OldAddress: openvpn.Address}
+		if existing, err := FindClient(profile, openvpn.Name); err == nil {
+			entry.NewAddress = existing.Address
+			entry.Status = MigrationSkipped
+			report = append(report, entry)
+			continue
+		}
+		if err := ValidateClientName(openvpn.Name); err != nil {
+			return nil, fmt.Errorf("OpenVPN client %q cannot be migrated: %w", openvpn.Name, err)
+		}
+
+		keep := addressAvailable(profile, network, openvpn.Address)
+		description := "Migrated from OpenVPN"
+		if openvpn.Address != "" {
+			description += " (" + openvpn.Address + ")"
+		}
+		if _, err := AddClient(profile, ClientOptions{Name: openvpn.Name, Description: description}); err != nil {
+			return nil, err
+		}
+		created := &profile.Clients[len(profile.Clients)-1]
+		created.Migration = &ClientMigration{Source: "openvpn", OldAddress: openvpn.Address, Status: MigrationPending, UpdatedAt: time.Now().UTC()}
+		entry.Status = MigrationCreated
+		if keep {
+			created.Address = openvpn.Address + "/32"
+			entry.Status = MigrationPreserved
+		}
+		entry.NewAddress = created.Address
+		report = append(report, entry)
+	}
+	return report, nil
+}
+
+// addressAvailable reports whether address is an assignable, unused host
+// address in network, using the same bounds as AllocateAddress.
+func addressAvailable(profile *ServerProfile, network *net.IPNet, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil || ip.To4() == nil || !network.Contains(ip) {
+		return false
+	}
+	ones, bits := network.Mask.Size()
+	last := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)), big.NewInt(1))
+	offset := new(big.Int).Sub(ipToInt(ip), ipToInt(network.IP))
+	if offset.Cmp(big.NewInt(2)) < 0 || offset.Cmp(last) >= 0 {
+		return false
+	}
+	if used := parseAddress(profile.Address); used != nil && used.Equal(ip) {
+		return false
+	}
+	for _, client := range profile.Clients {
+		if used := parseAddress(client.Address); used != nil && used.Equal(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOpenVPNCCD(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"alice":   "ifconfig-push 10.8.0.5 255.255.255.0\npush \"route 192.168.1.0 255.255.255.0\"\n",
+		"bob":     "# no static address\n",
+		"DEFAULT": "ifconfig-push 10.8.0.250 255.255.255.0\n",
+		".swp":    "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	clients, err := ReadOpenVPNCCD(dir)
+	if err != nil {
+		t.Fatalf("ReadOpenVPNCCD: %v", err)
+	}
+	if len(clients) != 2 || clients[0] != (OpenVPNClient{Name: "alice", Address: "10.8.0.5"}) || clients[1] != (OpenVPNClient{Name: "bob"}) {
+		t.Fatalf("unexpected clients %+v", clients)
+	}
+}
+
+func TestParseOpenVPNStatus(t *testing.T) {
+	v1 := `OpenVPN CLIENT LIST
+Updated,2024-01-01 10:00:00
+Common Name,Real Address,Bytes Received,Bytes Sent,Connected Since
+carol,198.51.100.7:50000,100,200,2024-01-01 09:00:00
+ROUTING TABLE
+Virtual Address,Common Name,Real Address,Last Ref
+10.8.0.9,carol,198.51.100.7:50000,2024-01-01 10:00:00
+GLOBAL STATS
+Max bcast/mcast queue length,0
+END
+`
+	v2 := "HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address\r\nCLIENT_LIST,dave,198.51.100.8:1194,10.8.0.10,,1,2\r\nCLIENT_LIST,UNDEF,198.51.100.9:1194,,,0,0\r\n"
+	v3 := "CLIENT_LIST\terin\t198.51.100.10:1194\t10.8.0.11\t\t1\t2\n"
+
+	for name, tc := range map[string]struct {
+		text string
+		want OpenVPNClient
+	}{
+		"v1": {v1, OpenVPNClient{Name: "carol", Address: "10.8.0.9"}},
+		"v2": {v2, OpenVPNClient{Name: "dave", Address: "10.8.0.10"}},
+		"v3": {v3, OpenVPNClient{Name: "erin", Address: "10.8.0.11"}},
+	} {
+		clients := ParseOpenVPNStatus(tc.text)
+		if len(clients) != 1 || clients[0] != tc.want {
+			t.Fatalf("%s: unexpected clients %+v", name, clients)
+		}
+	}
+}
+
+func TestMigrateOpenVPNClients(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("office", "203.0.113.1:51820", "priv", "pub")
+	if err := ApplySubnet(profile, "10.8.0.0/24"); err != nil {
+		t.Fatalf("ApplySubnet: %v", err)
+	}
+	profile.Clients = []ClientProfile{{Name: "existing", Address: "10.8.0.2/32"}}
+
+	roster := MergeOpenVPNClients(
+		[]OpenVPNClient{{Name: "alice", Address: "10.8.0.5"}, {Name: "bob"}, {Name: "existing", Address: "10.8.0.7"}},
+		[]OpenVPNClient{{Name: "bob", Address: "10.8.0.2"}, {Name: "carol", Address: "172.16.0.4"}},
+	)
+	report, err := MigrateOpenVPNClients(profile, roster)
+	if err != nil {
+		t.Fatalf("MigrateOpenVPNClients: %v", err)
+	}
+
+	want := []MigrationEntry{
+		{Client: "alice", OldAddress: "10.8.0.5", NewAddress: "10.8.0.5/32", Status: MigrationPreserved},
+		{Client: "bob", OldAddress: "10.8.0.2", NewAddress: "10.8.0.3/32", Status: MigrationCreated},
+		{Client: "carol", OldAddress: "172.16.0.4", NewAddress: "10.8.0.4/32", Status: MigrationCreated},
+		{Client: "existing", OldAddress: "10.8.0.7", NewAddress: "10.8.0.2/32", Status: MigrationSkipped},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for idx := range want {
+		if report[idx] != want[idx] {
+			t.Fatalf("entry %d: got %+v want %+v", idx, report[idx], want[idx])
+		}
+	}
+	if len(profile.Clients) != 4 {
+		t.Fatalf("expected 3 new clients, got %+v", profile.Clients)
+	}
+	if alice, _ := FindClient(profile, "alice"); alice.PrivateKey == "" || alice.Migration == nil || alice.Migration.Status != MigrationPending {
+		t.Fatalf("unexpected migrated client %+v", alice)
+	}
+}
+
+func TestMigrationStatusAndChecklist(t *testing.T) {
+	profile := DefaultServerProfile("office", "203.0.113.1:51820", "priv", "pub")
+	profile.Clients = []ClientProfile{
+		{Name: "alice", Address: "10.0.0.5/32", Migration: &ClientMigration{Source: "openvpn", OldAddress: "10.8.0.5", Status: MigrationPending}},
+		{Name: "bob", Address: "10.0.0.6/32"},
+	}
+
+	if err := SetMigrationStatus(profile, "alice", MigrationCutover); err != nil {
+		t.Fatalf("SetMigrationStatus: %v", err)
+	}
+	if migration := profile.Clients[0].Migration; migration.Status != MigrationCutover || migration.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected migration %+v", migration)
+	}
+	if err := SetMigrationStatus(profile, "alice", "finished"); err == nil {
+		t.Fatalf("expected unknown status to be rejected")
+	}
+	if err := SetMigrationStatus(profile, "bob", MigrationComplete); err == nil {
+		t.Fatalf("expected non-migrated client to be rejected")
+	}
+
+	checklist := MigrationChecklist(profile, profile.Clients[0], "office-alice.conf", "alice.ovpn")
+	for _, want := range []string{"Current OpenVPN profile: alice.ovpn", "10.0.0.5/32 (was 10.8.0.5)", "Import office-alice.conf"} {
+		if !strings.Contains(checklist, want) {
+			t.Fatalf("checklist missing %q:\n%s", want, checklist)
+		}
+	}
+}
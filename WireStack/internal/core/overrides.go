@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"net"
+)
+
+// SetClientAllowedIPs overrides the AllowedIPs rendered into the client's
+// config, taking precedence over its mode and over tag policies. An empty
+// list removes the override.
+func SetClientAllowedIPs(client *ClientProfile, cidrs []string) error {
+	if len(cidrs) == 0 {
+		client.CustomAllowedIPs = nil
+		return nil
+	}
+	normalized := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid AllowedIPs entry %s: %w", cidr, err)
+		}
+		normalized = append(normalized, network.String())
+	}
+	client.CustomAllowedIPs = normalized
+	return nil
+}
+
+// SetClientDNS overrides the server's DNS servers for the client. An empty
+// list removes the override.
+func SetClientDNS(client *ClientProfile, servers []string) error {
+	if len(servers) == 0 {
+		client.DNS = nil
+		return nil
+	}
+	for _, server := range servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("DNS server %q is not an IP address", server)
+		}
+	}
+	client.DNS = append([]string(nil), servers...)
+	return nil
+}
+
+// ClientDNS returns the DNS servers rendered into the client's config,
+// preferring the client's own override, then its group's, over the
+// server-wide default.
+func ClientDNS(profile *ServerProfile, client ClientProfile) []string {
+	if len(client.DNS) > 0 {
+		return client.DNS
+	}
+	if group := clientGroup(profile, client); group != nil && len(group.DNS) > 0 {
+		return group.DNS
+	}
+	return profile.DNS
+}
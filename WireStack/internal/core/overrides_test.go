@@ -0,0 +1,93 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientOverrides(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetPolicy(profile, "office", []string{"172.16.0.0/12"}); err != nil {
+		t.Fatalf("SetPolicy: %v", err)
+	}
+
+	client, err := AddClient(profile, ClientOptions{
+		Name:       "nas",
+		Tags:       []string{"office"},
+		AllowedIPs: []string{"10.0.0.9/24", "192.168.1.0/24"},
+		DNS:        []string{"10.0.0.53"},
+	})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	if got := strings.Join(EffectiveAllowedIPs(profile, client), ","); got != "10.0.0.0/24,192.168.1.0/24" {
+		t.Fatalf("expected custom AllowedIPs to beat the tag policy, got %s", got)
+	}
+	config, err := BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(config, "DNS = 10.0.0.53\n") || !strings.Contains(config, "AllowedIPs = 10.0.0.0/24, 192.168.1.0/24\n") {
+		t.Fatalf("overrides missing from config:\n%s", config)
+	}
+
+	if err := SetClientDNS(&client, nil); err != nil {
+		t.Fatalf("SetClientDNS: %v", err)
+	}
+	if got := strings.Join(ClientDNS(profile, client), ","); got != "1.1.1.1,9.9.9.9" {
+		t.Fatalf("expected server DNS after clearing the override, got %s", got)
+	}
+	if err := ApplyClientMode(profile, &client, ClientModeFull, nil); err != nil {
+		t.Fatalf("ApplyClientMode: %v", err)
+	}
+	if len(client.CustomAllowedIPs) != 0 {
+		t.Fatalf("expected a mode change to drop custom AllowedIPs, got %v", client.CustomAllowedIPs)
+	}
+
+	if _, err := AddClient(profile, ClientOptions{Name: "bad", AllowedIPs: []string{"10.0.0.300/24"}}); err == nil {
+		t.Fatalf("expected an invalid CIDR to be rejected")
+	}
+	if _, err := AddClient(profile, ClientOptions{Name: "bad", DNS: []string{"dns.example.com"}}); err == nil {
+		t.Fatalf("expected a non-IP DNS server to be rejected")
+	}
+}
+
+func TestSearchDomains(t *testing.T) {
+	fakeWG(t)
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SetSearchDomains(profile, []string{"Corp.Example.", "lab.internal", "corp.example"}); err != nil {
+		t.Fatalf("SetSearchDomains: %v", err)
+	}
+	if got := strings.Join(profile.SearchDomains, ","); got != "corp.example,lab.internal" {
+		t.Fatalf("expected normalized, deduplicated domains, got %s", got)
+	}
+	client, err := AddClient(profile, ClientOptions{Name: "laptop"})
+	if err != nil {
+		t.Fatalf("AddClient: %v", err)
+	}
+	config, err := BuildClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if !strings.Contains(config, "DNS = 1.1.1.1, 9.9.9.9, corp.example, lab.internal\n") {
+		t.Fatalf("expected search domains after the DNS servers:\n%s", config)
+	}
+
+	profile.DNS = nil
+	if config, err = BuildClientConfig(profile, client); err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if strings.Contains(config, "DNS =") {
+		t.Fatalf("did not expect a DNS line without DNS servers:\n%s", config)
+	}
+
+	for _, domain := range []string{"corp example", "-corp.example", "10.0.0.1", "corp,example"} {
+		if err := SetSearchDomains(profile, []string{domain}); err == nil {
+			t.Fatalf("expected %q to be rejected", domain)
+		}
+	}
+	if err := SetSearchDomains(profile, nil); err != nil || profile.SearchDomains != nil {
+		t.Fatalf("expected an empty list to remove the search domains, got %v (%v)", profile.SearchDomains, err)
+	}
+}
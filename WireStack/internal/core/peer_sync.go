@@ -0,0 +1,237 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	// DefaultPeerChunkSize is how many peers SyncLivePeers hands to one
+	// `wg set` invocation.
+	DefaultPeerChunkSize = 200
+	// maxPeerChunkBytes caps the argument bytes of one `wg set`, well below
+	// typical argv limits even for peers with many AllowedIPs.
+	maxPeerChunkBytes = 64 << 10
+)
+
+// PeerSyncOptions tunes SyncLivePeers.
+type PeerSyncOptions struct {
+	// ChunkSize is the number of peers per `wg set`; zero uses DefaultPeerChunkSize.
+	ChunkSize int
+	// Resume skips peers an interrupted or partially failed sync of the same
+	// peer set already applied.
+	Resume bool
+	// Progress, when set, is called after each chunk with the number of
+	// peers handled so far, including skipped and failed ones.
+	Progress func(done, total int)
+}
+
+// PeerSyncResult counts what SyncLivePeers did.
+type PeerSyncResult struct {
+	Total   int
+	Applied int
+	// Skipped peers were applied by the sync being resumed.
+	Skipped int
+	Failed  []PeerFailure
+}
+
+// PeerFailure is a peer `wg set` rejected.
+type PeerFailure struct {
+	Client    string
+	PublicKey string
+	Err       error
+}
+
+// PeerSyncError reports the peers that failed to apply; the rest were applied.
+type PeerSyncError struct {
+	Result PeerSyncResult
+}
+
+func (e *PeerSyncError) Error() string {
+	names := make([]string, 0, len(e.Result.Failed))
+	for _, failure := range e.Result.Failed {
+		names = append(names, failure.Client)
+	}
+	return fmt.Sprintf("%d of %d peers failed to apply (%s); first error: %v; rerun with --resume to retry them",
+		len(e.Result.Failed), e.Result.Total, strings.Join(names, ", "), e.Result.Failed[0].Err)
+}
+
+// peerSyncState is the checkpoint kept while a sync runs, so an interrupted
+// or partially failed sync can resume. Fingerprint identifies the peer set.
+type peerSyncState struct {
+	Interface   string   `json:"interface"`
+	Fingerprint string   `json:"fingerprint"`
+	Applied     []string `json:"applied"`
+}
+
+// livePeer is one enabled client as passed to `wg set`, or with remove set
+// a peer to take off the interface.
+type livePeer struct {
+	client  string
+	key     string
+	allowed string
+	remove  bool
+}
+
+// args returns the `wg set` arguments for the peer.
+func (p livePeer) args() []string {
+	if p.remove {
+		return []string{"peer", p.key, "remove"}
+	}
+	return []string{"peer", p.key, "allowed-ips", p.allowed}
+}
+
+// ApplyLivePeers pushes every enabled client of the profile to its running interface.
+func ApplyLivePeers(profile *ServerProfile) error {
+	_, err := SyncLivePeers(profile, PeerSyncOptions{})
+	return err
+}
+
+// SyncLivePeers pushes every enabled client of the profile to its running
+// interface, several peers per `wg set` so thousands of peers take a few
+// invocations instead of one each. A chunk that fails is retried one peer at
+// a time, so a bad peer only fails itself and the sync carries on. Progress
+// is checkpointed under the runtime directory until every peer is applied;
+// with opts.Resume a later sync of the same peers continues from there.
+func SyncLivePeers(profile *ServerProfile, opts PeerSyncOptions) (PeerSyncResult, error) {
+	iface := InterfaceName(profile)
+	var peers []livePeer
+	for _, client := range profile.Clients {
+		if client.Disabled {
+			continue
+		}
+		if client.PublicKey == "" {
+			return PeerSyncResult{}, fmt.Errorf("client %s has no public key", client.Name)
+		}
+		peers = append(peers, livePeer{client: client.Name, key: client.PublicKey, allowed: strings.Join(serverPeerAllowedIPs(client), ",")})
+	}
+	result := PeerSyncResult{Total: len(peers)}
+
+	statePath, err := PeerSyncStatePath(profile.Name)
+	if err != nil {
+		return result, err
+	}
+	state := peerSyncState{Interface: iface, Fingerprint: peerFingerprint(iface, peers)}
+	applied := map[string]bool{}
+	if opts.Resume {
+		var previous peerSyncState
+		if err := utils.ReadJSON(statePath, &previous); err == nil && previous.Fingerprint == state.Fingerprint {
+			for _, key := range previous.Applied {
+				applied[key] = true
+			}
+			state.Applied = previous.Applied
+		}
+	}
+
+	var pending []livePeer
+	for _, peer := range peers {
+		if applied[peer.key] {
+			result.Skipped++
+		} else {
+			pending = append(pending, peer)
+		}
+	}
+	if opts.Progress != nil && result.Skipped > 0 {
+		opts.Progress(result.Skipped, result.Total)
+	}
+
+	done := result.Skipped
+	for _, chunk := range chunkPeers(pending, opts.ChunkSize) {
+		if err := setPeers(iface, chunk); err != nil {
+			for _, peer := range chunk {
+				if err := setPeers(iface, []livePeer{peer}); err != nil {
+					result.Failed = append(result.Failed, PeerFailure{Client: peer.client, PublicKey: peer.key, Err: err})
+					continue
+				}
+				result.Applied++
+				state.Applied = append(state.Applied, peer.key)
+			}
+		} else {
+			result.Applied += len(chunk)
+			for _, peer := range chunk {
+				state.Applied = append(state.Applied, peer.key)
+			}
+		}
+		done += len(chunk)
+		if err := savePeerSyncState(statePath, state, done < result.Total); err != nil {
+			return result, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(done, result.Total)
+		}
+	}
+
+	if len(result.Failed) > 0 {
+		if err := savePeerSyncState(statePath, state, true); err != nil {
+			return result, err
+		}
+		return result, &PeerSyncError{Result: result}
+	}
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return result, err
+	}
+	return result, nil
+}
+
+// chunkPeers splits peers into groups of at most size peers and
+// maxPeerChunkBytes of arguments.
+func chunkPeers(peers []livePeer, size int) [][]livePeer {
+	if size <= 0 {
+		size = DefaultPeerChunkSize
+	}
+	var chunks [][]livePeer
+	var current []livePeer
+	bytes := 0
+	for _, peer := range peers {
+		peerBytes := 0
+		for _, arg := range peer.args() {
+			peerBytes += len(arg) + 1
+		}
+		if len(current) > 0 && (len(current) >= size || bytes+peerBytes > maxPeerChunkBytes) {
+			chunks = append(chunks, current)
+			current, bytes = nil, 0
+		}
+		current = append(current, peer)
+		bytes += peerBytes
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// setPeers adds, updates, or removes peers on iface with a single `wg set`.
+func setPeers(iface string, peers []livePeer) error {
+	args := []string{"set", iface}
+	for _, peer := range peers {
+		args = append(args, peer.args()...)
+	}
+	_, err := utils.RunCommand("wg", args...)
+	return err
+}
+
+// peerFingerprint identifies the interface and peer set being synced.
+func peerFingerprint(iface string, peers []livePeer) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n", iface)
+	for _, peer := range peers {
+		fmt.Fprintf(hash, "%s %s\n", peer.key, peer.allowed)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// savePeerSyncState checkpoints progress while peers remain. It is skipped in
+// a dry run, which applies nothing.
+func savePeerSyncState(path string, state peerSyncState, remaining bool) error {
+	if !remaining || utils.DryRun() {
+		return nil
+	}
+	return utils.WriteJSON(path, state, 0o600)
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loggingWG installs a fake wg that appends its arguments to a log and fails
+// any invocation mentioning failKey while the file fail exists.
+func loggingWG(t *testing.T, failKey string) (logPath, failPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath = filepath.Join(dir, "wg.log")
+	failPath = filepath.Join(dir, "fail")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$*\" >> %s\ncase \"$*\" in\n*%s*) [ -e %s ] && exit 1 ;;\nesac\nexit 0\n", logPath, failKey, failPath)
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake wg: %v", err)
+	}
+	if err := os.WriteFile(failPath, nil, 0o600); err != nil {
+		t.Fatalf("write fail marker: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logPath, failPath
+}
+
+func readLog(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("read wg log: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestSyncLivePeersChunksAndResumes(t *testing.T) {
+	setupTempHome(t)
+	logPath, failPath := loggingWG(t, "bad-pub")
+
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "", "server-pub")
+	profile.ExternalInterface = "wg-ext0"
+	for idx, name := range []string{"a", "b", "bad", "c", "d"} {
+		profile.Clients = append(profile.Clients, ClientProfile{Name: name, PublicKey: name + "-pub", Address: fmt.Sprintf("10.0.0.%d/32", idx+2)})
+	}
+	profile.Clients = append(profile.Clients, ClientProfile{Name: "off", PublicKey: "off-pub", Address: "10.0.0.9/32", Disabled: true})
+
+	var progress []string
+	result, err := SyncLivePeers(profile, PeerSyncOptions{ChunkSize: 2, Progress: func(done, total int) {
+		progress = append(progress, fmt.Sprintf("%d/%d", done, total))
+	}})
+	var syncErr *PeerSyncError
+	if !errors.As(err, &syncErr) {
+		t.Fatalf("expected a PeerSyncError, got %v", err)
+	}
+	if result.Applied != 4 || len(result.Failed) != 1 || result.Failed[0].Client != "bad" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	want := []string{
+		"set wg-ext0 peer a-pub allowed-ips 10.0.0.2/32 peer b-pub allowed-ips 10.0.0.3/32",
+		"set wg-ext0 peer bad-pub allowed-ips 10.0.0.4/32 peer c-pub allowed-ips 10.0.0.5/32",
+		"set wg-ext0 peer bad-pub allowed-ips 10.0.0.4/32",
+		"set wg-ext0 peer c-pub allowed-ips 10.0.0.5/32",
+		"set wg-ext0 peer d-pub allowed-ips 10.0.0.6/32",
+	}
+	if got := readLog(t, logPath); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected wg calls:\n%s", strings.Join(got, "\n"))
+	}
+	if strings.Join(progress, ",") != "2/5,4/5,5/5" {
+		t.Fatalf("unexpected progress %v", progress)
+	}
+	statePath, _ := PeerSyncStatePath("edge")
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("expected a checkpoint after a partial failure: %v", err)
+	}
+
+	if err := os.Remove(failPath); err != nil {
+		t.Fatalf("remove fail marker: %v", err)
+	}
+	if err := os.Remove(logPath); err != nil {
+		t.Fatalf("remove log: %v", err)
+	}
+	result, err = SyncLivePeers(profile, PeerSyncOptions{ChunkSize: 2, Resume: true})
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if result.Applied != 1 || result.Skipped != 4 {
+		t.Fatalf("unexpected resume result %+v", result)
+	}
+	if got := readLog(t, logPath); len(got) != 1 || got[0] != "set wg-ext0 peer bad-pub allowed-ips 10.0.0.4/32" {
+		t.Fatalf("resume should only retry the failed peer, got %v", got)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Fatalf("expected the checkpoint to be removed after a full sync (%v)", err)
+	}
+}
+
+func TestChunkPeersRespectsByteLimit(t *testing.T) {
+	wide := strings.Repeat("10.1.0.0/16,", 2000)
+	peers := []livePeer{
+		{client: "a", key: "a-pub", allowed: wide},
+		{client: "b", key: "b-pub", allowed: wide},
+		{client: "c", key: "c-pub", allowed: wide},
+		{client: "d", key: "d-pub", allowed: "10.0.0.5/32"},
+	}
+	chunks := chunkPeers(peers, 100)
+	if len(chunks) != 2 || len(chunks[0]) != 2 || len(chunks[1]) != 2 {
+		t.Fatalf("expected two chunks of two peers, got %d chunks", len(chunks))
+	}
+	if len(chunkPeers(peers[:1], 0)) != 1 || len(chunkPeers(nil, 0)) != 0 {
+		t.Fatalf("unexpected chunks for trivial input")
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// DefaultSharedPaths are shared when no paths are configured: quality history
+// and settings, which hold no private keys. Settings do include the notify
+// webhook URL.
+var DefaultSharedPaths = []string{qualityDir, settingsFile}
+
+// PrivateKeyPaths hold server, client, or mesh node private keys. They are
+// never shared by default; an operator has to list them explicitly.
+var PrivateKeyPaths = []string{serversDir, meshesDir, runtimeDir}
+
+// SharesPrivateKeys returns the shared paths that hold or contain private keys.
+func SharesPrivateKeys(shared []string) []string {
+	var exposed []string
+	for _, entry := range shared {
+		for _, private := range PrivateKeyPaths {
+			if entry == private || strings.HasPrefix(entry, private+"/") || strings.HasPrefix(private, entry+"/") {
+				exposed = append(exposed, entry)
+				break
+			}
+		}
+	}
+	return exposed
+}
+
+// PermissionSettings shares part of ~/.wirestack with a group.
+type PermissionSettings struct {
+	// Group gets read access to Shared; empty keeps everything owner-only.
+	Group string `json:"group,omitempty"`
+	// Shared are paths relative to ~/.wirestack; empty means DefaultSharedPaths.
+	Shared []string `json:"shared,omitempty"`
+}
+
+// SharedPaths returns the configured shared paths, cleaned and sorted, or the defaults.
+func (p *PermissionSettings) SharedPaths() ([]string, error) {
+	if p == nil || len(p.Shared) == 0 {
+		return DefaultSharedPaths, nil
+	}
+	var shared []string
+	for _, entry := range p.Shared {
+		clean := path.Clean(filepath.ToSlash(entry))
+		if entry == "" || clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("shared path %q must name something inside ~/.wirestack", entry)
+		}
+		shared = append(shared, clean)
+	}
+	sort.Strings(shared)
+	return shared, nil
+}
+
+// ApplyPermissionSettings installs the permission policy from settings, so
+// files written from now on get the group and modes it asks for. Without a
+// group every file stays owner-only.
+func ApplyPermissionSettings(settings *Settings) error {
+	if settings.Permissions == nil || settings.Permissions.Group == "" {
+		utils.SetPermissionPolicy(nil)
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("group permissions are not supported on Windows; remove permissions from the settings file")
+	}
+	group, err := user.LookupGroup(settings.Permissions.Group)
+	if err != nil {
+		return fmt.Errorf("permissions group: %w", err)
+	}
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		return fmt.Errorf("permissions group %s has non-numeric id %s", group.Name, group.Gid)
+	}
+	shared, err := settings.Permissions.SharedPaths()
+	if err != nil {
+		return err
+	}
+	root, err := utils.ExpandPath("~/" + defaultConfigDir)
+	if err != nil {
+		return err
+	}
+	utils.SetPermissionPolicy(&utils.PermissionPolicy{Root: root, GID: gid, Shared: shared})
+	return nil
+}
+
+// baseMode is the owner-only mode WireStack writes path with. Certificates are
+// public and keep their read bits.
+func baseMode(path string, dir bool) os.FileMode {
+	switch {
+	case dir:
+		return 0o700
+	case filepath.Ext(path) == ".crt":
+		return 0o644
+	default:
+		return 0o600
+	}
+}
+
+// ApplyPermissions brings the mode and group of every file and directory under
+// ~/.wirestack in line with the current policy, tightening what it does not
+// share. User templates and temporary files are left alone. It returns the
+// paths it changed.
+func ApplyPermissions() ([]string, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+	root, err := ConfigRoot()
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && path == filepath.Join(root, templatesDir) {
+			return fs.SkipDir
+		}
+		if !entry.IsDir() && (!entry.Type().IsRegular() || utils.IsTempFile(entry.Name())) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		want, gid := utils.PolicyMode(path, baseMode(path, entry.IsDir()), entry.IsDir())
+		if info.Mode().Perm() == want && (gid < 0 || utils.FileGroup(info) == gid) {
+			return nil
+		}
+		if err := utils.ApplyPermissionPolicy(path, baseMode(path, entry.IsDir()), entry.IsDir()); err != nil {
+			return fmt.Errorf("set permissions of %s: %w", path, err)
+		}
+		changed = append(changed, path)
+		return nil
+	})
+	return changed, err
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wirestack/internal/utils"
+)
+
+func TestApplyPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("group permissions are not supported on Windows")
+	}
+	home := setupTempHome(t)
+	group, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	if err != nil {
+		t.Skipf("primary group has no name: %v", err)
+	}
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	root := filepath.Join(home, defaultConfigDir)
+	if _, err := CARoot(); err != nil {
+		t.Fatalf("CARoot: %v", err)
+	}
+
+	settings := &Settings{Permissions: &PermissionSettings{Group: group.Name, Shared: []string{"../outside"}}}
+	if err := ApplyPermissionSettings(settings); err == nil {
+		t.Fatalf("expected a shared path outside ~/.wirestack to be rejected")
+	}
+	// Profiles hold private keys, so sharing them is an explicit choice.
+	settings.Permissions.Shared = []string{"servers", "quality", "config.json"}
+	if err := ApplyPermissionSettings(settings); err != nil {
+		t.Fatalf("ApplyPermissionSettings: %v", err)
+	}
+	t.Cleanup(func() { utils.SetPermissionPolicy(nil) })
+
+	// Files written before the policy still have owner-only modes.
+	issues, err := CheckStore(false)
+	if err != nil {
+		t.Fatalf("CheckStore: %v", err)
+	}
+	if len(issues) == 0 {
+		t.Fatalf("expected fsck to flag shared paths the group cannot read")
+	}
+	changed, err := ApplyPermissions()
+	if err != nil {
+		t.Fatalf("ApplyPermissions: %v", err)
+	}
+	if len(changed) == 0 {
+		t.Fatalf("expected ApplyPermissions to change existing paths")
+	}
+	want := map[string]os.FileMode{
+		".":                 0o710,
+		"servers":           0o750,
+		"servers/prod.json": 0o640,
+		"ca":                0o700,
+	}
+	for path, mode := range want {
+		info, err := os.Stat(filepath.Join(root, path))
+		if err != nil || info.Mode().Perm() != mode {
+			t.Fatalf("expected %s to have mode %04o, got %v (%v)", path, mode, info, err)
+		}
+	}
+	if issues, err := CheckStore(false); err != nil || len(issues) != 0 {
+		t.Fatalf("expected a clean store after applying the policy, got %+v (%v)", issues, err)
+	}
+
+	if err := ApplyPermissionSettings(&Settings{}); err != nil {
+		t.Fatalf("ApplyPermissionSettings: %v", err)
+	}
+	if _, err := ApplyPermissions(); err != nil {
+		t.Fatalf("ApplyPermissions: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "servers/prod.json")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the profile to be owner-only again, got %v (%v)", info, err)
+	}
+}
+
+func TestDefaultSharedPathsHoldNoPrivateKeys(t *testing.T) {
+	if exposed := SharesPrivateKeys(DefaultSharedPaths); len(exposed) != 0 {
+		t.Fatalf("default shared paths expose private keys in %v", exposed)
+	}
+	got := SharesPrivateKeys([]string{"quality", "servers/prod.json", "runtime", "meshes"})
+	if strings.Join(got, ",") != "servers/prod.json,runtime,meshes" {
+		t.Fatalf("unexpected key-bearing paths %v", got)
+	}
+}
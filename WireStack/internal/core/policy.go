@@ -0,0 +1,112 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// AccessPolicy grants clients carrying Tag the listed AllowedIPs.
+type AccessPolicy struct {
+	Tag        string   `json:"tag"`
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+// EffectiveAllowedIPs resolves the AllowedIPs rendered into a client config. A
+// client's CustomAllowedIPs win, then its group's AllowedIPs; otherwise the
+// first server policy matching one of the client's tags applies, and
+// remaining clients keep their own AllowedIPs.
+func EffectiveAllowedIPs(profile *ServerProfile, client ClientProfile) []string {
+	if len(client.CustomAllowedIPs) > 0 {
+		return client.CustomAllowedIPs
+	}
+	if group := clientGroup(profile, client); group != nil && len(group.AllowedIPs) > 0 {
+		return group.AllowedIPs
+	}
+	for _, policy := range profile.Policies {
+		if HasTag(client, policy.Tag) {
+			return policy.AllowedIPs
+		}
+	}
+	return client.AllowedIPs
+}
+
+// HasTag reports whether the client carries the given tag.
+func HasTag(client ClientProfile, tag string) bool {
+	for _, candidate := range client.Tags {
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllTags reports whether the client carries every one of tags.
+func HasAllTags(client ClientProfile, tags []string) bool {
+	for _, tag := range tags {
+		if !HasTag(client, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientsWithTags returns the names of the clients carrying every one of tags,
+// in profile order. Finding none is an error, so a bulk change with a
+// mistyped tag does not quietly do nothing.
+func ClientsWithTags(profile *ServerProfile, tags []string) ([]string, error) {
+	var names []string
+	for _, client := range profile.Clients {
+		if HasAllTags(client, tags) {
+			names = append(names, client.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no client of server %s has tag %s", profile.Name, strings.Join(tags, " and "))
+	}
+	return names, nil
+}
+
+// SetClientTags replaces the client's tags, dropping blank and repeated ones.
+func SetClientTags(client *ClientProfile, tags []string) {
+	client.Tags = nil
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !HasTag(*client, tag) {
+			client.Tags = append(client.Tags, tag)
+		}
+	}
+}
+
+// SetPolicy adds or replaces the policy for a tag, keeping its evaluation position.
+func SetPolicy(profile *ServerProfile, tag string, allowedIPs []string) error {
+	if tag == "" {
+		return fmt.Errorf("policy tag is empty")
+	}
+	if len(allowedIPs) == 0 {
+		return fmt.Errorf("policy for tag %s has no AllowedIPs", tag)
+	}
+	for _, cidr := range allowedIPs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid AllowedIPs entry %s: %w", cidr, err)
+		}
+	}
+	for idx := range profile.Policies {
+		if profile.Policies[idx].Tag == tag {
+			profile.Policies[idx].AllowedIPs = allowedIPs
+			return nil
+		}
+	}
+	profile.Policies = append(profile.Policies, AccessPolicy{Tag: tag, AllowedIPs: allowedIPs})
+	return nil
+}
+
+// RemovePolicy deletes the policy for a tag.
+func RemovePolicy(profile *ServerProfile, tag string) error {
+	for idx := range profile.Policies {
+		if profile.Policies[idx].Tag == tag {
+			profile.Policies = append(profile.Policies[:idx], profile.Policies[idx+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no policy for tag %s", tag)
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// runPool calls work for every index below count on at most workers
+// goroutines and returns each call's error by index. Indexes not yet started
+// when ctx is done fail with its error instead of running.
+func runPool(ctx context.Context, workers, count int, work func(ctx context.Context, index int) error) []error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > count {
+		workers = count
+	}
+	errs := make([]error, count)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[index] = err
+					continue
+				}
+				errs[index] = work(ctx, index)
+			}
+		}()
+	}
+	for index := 0; index < count; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,284 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// Port mapping methods a server can ask its router for.
+const (
+	PortMapUPnP   = "upnp"
+	PortMapNATPMP = "natpmp"
+	// PortMapAuto tries NAT-PMP and falls back to UPnP.
+	PortMapAuto = "auto"
+)
+
+// PortMappingMethods lists the accepted ServerProfile.PortMapping values.
+var PortMappingMethods = []string{PortMapAuto, PortMapUPnP, PortMapNATPMP}
+
+// DefaultPortMappingLifetime is the lease requested from the router. The
+// daemon renews mappings at half of it.
+const DefaultPortMappingLifetime = time.Hour
+
+// portMappingTimeout bounds one request to the router.
+const portMappingTimeout = 5 * time.Second
+
+// natpmpPort is where NAT-PMP gateways listen (RFC 6886).
+const natpmpPort = "5351"
+
+// upnpOnlyPermanentLeases is the UPnP error of routers that reject leases
+// with a duration.
+const upnpOnlyPermanentLeases = "725"
+
+// PortMapping is a UDP port forward a router granted, with what is needed to
+// renew or release it.
+type PortMapping struct {
+	Method string `json:"method"`
+	// Gateway is the NAT-PMP gateway address or the UPnP control URL.
+	Gateway string `json:"gateway"`
+	// ServiceType is the UPnP WAN connection service.
+	ServiceType  string `json:"service_type,omitempty"`
+	InternalPort int    `json:"internal_port"`
+	ExternalPort int    `json:"external_port"`
+	// Lifetime is the granted lease in seconds; 0 is a permanent UPnP lease.
+	Lifetime int `json:"lifetime"`
+}
+
+// SetPortMapping selects how the server's listen port is forwarded on the
+// router by up; an empty method turns it off.
+func SetPortMapping(profile *ServerProfile, method string) error {
+	if method != "" && !containsString(PortMappingMethods, method) {
+		return fmt.Errorf("unknown port mapping %q (want %s)", method, strings.Join(PortMappingMethods, ", "))
+	}
+	if method != "" && profile.ExternalInterface != "" {
+		return fmt.Errorf("server %s uses external interface %s; its listen port is managed elsewhere", profile.Name, profile.ExternalInterface)
+	}
+	profile.PortMapping = method
+	return nil
+}
+
+// MapServerPort asks the router to forward the server's listen port with the
+// profile's method, renewing the mapping when it already exists.
+func MapServerPort(ctx context.Context, profile *ServerProfile) (*PortMapping, error) {
+	_, portText, err := net.SplitHostPort(profile.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint port %q", portText)
+	}
+	return MapPort(ctx, profile.PortMapping, port, "wirestack "+profile.Name, DefaultPortMappingLifetime)
+}
+
+// MapPort asks the router to forward UDP port to this host for lifetime.
+// With PortMapAuto, NAT-PMP is tried first and UPnP second.
+func MapPort(ctx context.Context, method string, port int, description string, lifetime time.Duration) (*PortMapping, error) {
+	switch method {
+	case PortMapNATPMP:
+		return mapNATPMP(ctx, port, lifetime)
+	case PortMapUPnP:
+		return mapUPnP(ctx, port, description, lifetime)
+	case PortMapAuto:
+		mapping, natpmpErr := mapNATPMP(ctx, port, lifetime)
+		if natpmpErr == nil {
+			return mapping, nil
+		}
+		mapping, upnpErr := mapUPnP(ctx, port, description, lifetime)
+		if upnpErr == nil {
+			return mapping, nil
+		}
+		return nil, errors.Join(natpmpErr, upnpErr)
+	}
+	return nil, fmt.Errorf("unknown port mapping %q", method)
+}
+
+// ReleasePortMapping removes a mapping from the router.
+func ReleasePortMapping(ctx context.Context, mapping *PortMapping) error {
+	switch mapping.Method {
+	case PortMapNATPMP:
+		// A zero lifetime and external port deletes the mapping.
+		_, err := natpmpRequest(ctx, mapping.Gateway, mapping.InternalPort, 0, 0)
+		return err
+	case PortMapUPnP:
+		gateway := &UPnPGateway{ServiceType: mapping.ServiceType, ControlURL: mapping.Gateway}
+		return gateway.call(ctx, "DeletePortMapping", [][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", strconv.Itoa(mapping.ExternalPort)},
+			{"NewProtocol", "UDP"},
+		}, nil)
+	}
+	return fmt.Errorf("unknown port mapping %q", mapping.Method)
+}
+
+// PortMappingArtifact records a mapping so down and teardown can release it.
+func PortMappingArtifact(mapping *PortMapping) Artifact {
+	return Artifact{Kind: ArtifactPortMap, Target: fmt.Sprintf("udp/%d", mapping.ExternalPort), Mapping: mapping}
+}
+
+// mapNATPMP maps port through the NAT-PMP gateway, the default router.
+func mapNATPMP(ctx context.Context, port int, lifetime time.Duration) (*PortMapping, error) {
+	router, err := defaultGateway()
+	if err != nil {
+		return nil, fmt.Errorf("natpmp: %w", err)
+	}
+	mapping, err := natpmpRequest(ctx, net.JoinHostPort(router, natpmpPort), port, port, int(lifetime/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// natpmpRequest sends a UDP mapping request to gateway, retransmitting as
+// RFC 6886 describes until an answer arrives or ctx is done.
+func natpmpRequest(ctx context.Context, gateway string, internal, external, lifetime int) (*PortMapping, error) {
+	ctx, cancel := context.WithTimeout(ctx, portMappingTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp4", gateway)
+	if err != nil {
+		return nil, fmt.Errorf("natpmp: %w", err)
+	}
+	defer conn.Close()
+
+	request := make([]byte, 12)
+	request[1] = 1 // map UDP
+	binary.BigEndian.PutUint16(request[4:], uint16(internal))
+	binary.BigEndian.PutUint16(request[6:], uint16(external))
+	binary.BigEndian.PutUint32(request[8:], uint32(lifetime))
+
+	response := make([]byte, 16)
+	for wait := 250 * time.Millisecond; ; wait *= 2 {
+		if _, err := conn.Write(request); err != nil {
+			return nil, fmt.Errorf("natpmp: %w", err)
+		}
+		deadline := time.Now().Add(wait)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		conn.SetReadDeadline(deadline)
+		n, err := conn.Read(response)
+		if err == nil {
+			return parseNATPMPResponse(response[:n], gateway)
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("natpmp: no answer from %s: %w", gateway, ctx.Err())
+		}
+	}
+}
+
+// parseNATPMPResponse decodes the answer to a UDP mapping request.
+func parseNATPMPResponse(response []byte, gateway string) (*PortMapping, error) {
+	if len(response) < 16 || response[0] != 0 || response[1] != 129 {
+		return nil, fmt.Errorf("natpmp: malformed answer from %s", gateway)
+	}
+	if code := binary.BigEndian.Uint16(response[2:]); code != 0 {
+		return nil, fmt.Errorf("natpmp: %s refused the mapping with result code %d", gateway, code)
+	}
+	return &PortMapping{
+		Method:       PortMapNATPMP,
+		Gateway:      gateway,
+		InternalPort: int(binary.BigEndian.Uint16(response[8:])),
+		ExternalPort: int(binary.BigEndian.Uint16(response[10:])),
+		Lifetime:     int(binary.BigEndian.Uint32(response[12:])),
+	}, nil
+}
+
+// mapUPnP maps port through the UPnP gateway found on the local network.
+func mapUPnP(ctx context.Context, port int, description string, lifetime time.Duration) (*PortMapping, error) {
+	ctx, cancel := context.WithTimeout(ctx, portMappingTimeout)
+	defer cancel()
+	gateway, err := DiscoverUPnPGateway(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gateway.AddPortMapping(ctx, port, description, lifetime)
+}
+
+// AddPortMapping forwards UDP port on the gateway to this host. Routers that
+// only grant permanent leases are asked again without a duration.
+func (g *UPnPGateway) AddPortMapping(ctx context.Context, port int, description string, lifetime time.Duration) (*PortMapping, error) {
+	client, err := upnpInternalClient(g.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+	seconds := int(lifetime / time.Second)
+	args := func() [][2]string {
+		return [][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", strconv.Itoa(port)},
+			{"NewProtocol", "UDP"},
+			{"NewInternalPort", strconv.Itoa(port)},
+			{"NewInternalClient", client},
+			{"NewEnabled", "1"},
+			{"NewPortMappingDescription", description},
+			{"NewLeaseDuration", strconv.Itoa(seconds)},
+		}
+	}
+	err = g.call(ctx, "AddPortMapping", args(), nil)
+	if err != nil && seconds != 0 && strings.Contains(err.Error(), "error "+upnpOnlyPermanentLeases+":") {
+		seconds = 0
+		err = g.call(ctx, "AddPortMapping", args(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PortMapping{
+		Method:       PortMapUPnP,
+		Gateway:      g.ControlURL,
+		ServiceType:  g.ServiceType,
+		InternalPort: port,
+		ExternalPort: port,
+		Lifetime:     seconds,
+	}, nil
+}
+
+// upnpInternalClient returns this host's address on the way to the gateway.
+func upnpInternalClient(controlURL string) (string, error) {
+	parsed, err := url.Parse(controlURL)
+	if err != nil {
+		return "", err
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(parsed.Hostname(), "80")
+	}
+	// Connecting a UDP socket sends nothing; it only picks the route.
+	conn, err := net.Dial("udp4", host)
+	if err != nil {
+		return "", fmt.Errorf("upnp: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// defaultGateway returns the router of the IPv4 default route.
+func defaultGateway() (string, error) {
+	if runtime.GOOS == "darwin" {
+		if gateway := darwinDefaultGateway("-inet"); gateway != "" {
+			return gateway, nil
+		}
+		return "", fmt.Errorf("no default gateway")
+	}
+	output, err := utils.RunCommand("ip", "-4", "route", "show", "default")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(output)
+	for idx := 0; idx+1 < len(fields); idx++ {
+		if fields[idx] == "via" {
+			return fields[idx+1], nil
+		}
+	}
+	return "", fmt.Errorf("no default gateway")
+}
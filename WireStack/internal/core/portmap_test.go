@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNATPMPRequest(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buffer := make([]byte, 64)
+		for {
+			n, peer, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			if n != 12 || buffer[1] != 1 {
+				continue
+			}
+			// Grant the request, moving the external port up by one.
+			response := make([]byte, 16)
+			response[1] = 129
+			copy(response[8:10], buffer[4:6])
+			external := binary.BigEndian.Uint16(buffer[6:])
+			if external != 0 {
+				external++
+			}
+			binary.BigEndian.PutUint16(response[10:], external)
+			copy(response[12:16], buffer[8:12])
+			conn.WriteTo(response, peer)
+		}
+	}()
+
+	gateway := conn.LocalAddr().String()
+	mapping, err := natpmpRequest(context.Background(), gateway, 51820, 51820, 3600)
+	if err != nil {
+		t.Fatalf("natpmpRequest: %v", err)
+	}
+	if mapping.Method != PortMapNATPMP || mapping.Gateway != gateway || mapping.InternalPort != 51820 || mapping.ExternalPort != 51821 || mapping.Lifetime != 3600 {
+		t.Fatalf("unexpected mapping %+v", mapping)
+	}
+	if err := ReleasePortMapping(context.Background(), mapping); err != nil {
+		t.Fatalf("ReleasePortMapping: %v", err)
+	}
+
+	refused := make([]byte, 16)
+	refused[1] = 129
+	binary.BigEndian.PutUint16(refused[2:], 2)
+	if _, err := parseNATPMPResponse(refused, gateway); err == nil || !strings.Contains(err.Error(), "result code 2") {
+		t.Fatalf("expected the refusal reported, got %v", err)
+	}
+}
+
+func TestUPnPPortMappingAndTeardown(t *testing.T) {
+	setupTempHome(t)
+	var mu sync.Mutex
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		action := r.Header.Get("SOAPAction")
+		mu.Lock()
+		actions = append(actions, action)
+		mu.Unlock()
+		// Only permanent leases, like many consumer routers.
+		if strings.Contains(action, "#AddPortMapping") && !strings.Contains(string(body), "<NewLeaseDuration>0</NewLeaseDuration>") {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><detail><UPnPError><errorCode>725</errorCode><errorDescription>OnlyPermanentLeasesSupported</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+			return
+		}
+		fmt.Fprint(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body/></s:Envelope>`)
+	}))
+	defer server.Close()
+
+	gateway := &UPnPGateway{ServiceType: upnpServiceTypes[1], ControlURL: server.URL + "/ctl"}
+	mapping, err := gateway.AddPortMapping(context.Background(), 51820, "wirestack home", time.Hour)
+	if err != nil {
+		t.Fatalf("AddPortMapping: %v", err)
+	}
+	if mapping.Lifetime != 0 || mapping.ExternalPort != 51820 || mapping.Gateway != gateway.ControlURL {
+		t.Fatalf("expected a permanent lease after the 725 refusal, got %+v", mapping)
+	}
+
+	if err := RecordArtifacts("home", PortMappingArtifact(mapping)); err != nil {
+		t.Fatalf("RecordArtifacts: %v", err)
+	}
+	result, err := Teardown("home", []string{ArtifactPortMap}, false)
+	if err != nil || len(result.Removed) != 1 || len(result.Failed) != 0 {
+		t.Fatalf("expected the mapping released, got %+v, %v", result, err)
+	}
+	if last := actions[len(actions)-1]; !strings.HasSuffix(last, `#DeletePortMapping"`) {
+		t.Fatalf("expected DeletePortMapping last, got %v", actions)
+	}
+	registry, err := LoadArtifacts("home")
+	if err != nil || len(registry.Artifacts) != 0 {
+		t.Fatalf("expected nothing left recorded, got %+v, %v", registry, err)
+	}
+}
+
+func TestSetPortMapping(t *testing.T) {
+	profile := DefaultServerProfile("home", "203.0.113.1:51820", "priv", "pub")
+	if err := SetPortMapping(profile, PortMapAuto); err != nil || profile.PortMapping != PortMapAuto {
+		t.Fatalf("SetPortMapping: %v", err)
+	}
+	if err := SetPortMapping(profile, "pcp"); err == nil {
+		t.Fatal("expected an unknown method to be rejected")
+	}
+	profile.ExternalInterface = "wg0"
+	if err := SetPortMapping(profile, PortMapUPnP); err == nil {
+		t.Fatal("expected an external interface to be rejected")
+	}
+	if err := SetPortMapping(profile, ""); err != nil || profile.PortMapping != "" {
+		t.Fatalf("expected port mapping turned off, got %v", err)
+	}
+}
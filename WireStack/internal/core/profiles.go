@@ -1,94 +1,152 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
-	"path/filepath"
-
-	"wirestack/internal/utils"
+	"time"
 )
 
+// ErrClientNotFound is returned (wrapped) when a server has no client by the requested name.
+var ErrClientNotFound = errors.New("client not found")
+
 // ClientProfile captures a client and its WireGuard parameters.
 type ClientProfile struct {
-	Name        string   `json:"name"`
-	PrivateKey  string   `json:"private_key"`
-	PublicKey   string   `json:"public_key"`
-	Address     string   `json:"address"`
-	AllowedIPs  []string `json:"allowed_ips"`
+	Name       string   `json:"name"`
+	PrivateKey string   `json:"private_key"`
+	PublicKey  string   `json:"public_key"`
+	Address    string   `json:"address"`
+	Address6   string   `json:"address6,omitempty"`
+	AllowedIPs []string `json:"allowed_ips"`
+	// Mode is ClientModeFull or ClientModeSplit; empty means full (see ClientMode).
+	Mode string `json:"mode,omitempty"`
+	// SplitRoutes are the extra networks a split-tunnel client routes through the tunnel.
+	SplitRoutes []string `json:"split_routes,omitempty"`
+	// CustomAllowedIPs override the AllowedIPs from Mode and tag policies when set.
+	CustomAllowedIPs []string `json:"custom_allowed_ips,omitempty"`
+	// DNS overrides ServerProfile.DNS for this client when set.
+	DNS         []string `json:"dns,omitempty"`
 	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Group names a ServerProfile group whose AllowedIPs and DNS apply when
+	// the client has no override of its own.
+	Group string `json:"group,omitempty"`
+	// Extra overrides ServerProfile.ClientExtra for this client when set.
+	Extra string `json:"extra,omitempty"`
+	// MTU is rendered into the client config; zero uses the export target's default.
+	MTU int `json:"mtu,omitempty"`
+	// PortForwards expose services on the client through the server's NAT interface.
+	PortForwards []PortForward `json:"port_forwards,omitempty"`
+	// Annotations are free-form key/value metadata, as on ServerProfile.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// ExpiresAt is when the client stops being valid; expire-check revokes it after that.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Disabled clients stay in the profile but are not rendered or applied as peers.
+	Disabled bool `json:"disabled,omitempty"`
+	// DisabledReason is DisabledOutdated when the version policy disabled the
+	// client, so a later compliant report can re-enable it. Empty otherwise.
+	DisabledReason string `json:"disabled_reason,omitempty"`
+	// KeyHistory holds retired key pairs, newest first (see RotateClientKey).
+	KeyHistory []RetiredKey `json:"key_history,omitempty"`
+	// Migration is set for clients imported from another VPN (see migrate-openvpn).
+	Migration *ClientMigration `json:"migration,omitempty"`
+	// Platform is what the client's agent last reported about the device.
+	Platform *ClientPlatform `json:"platform,omitempty"`
 }
 
 // ServerProfile describes a WireGuard server and connected clients.
 type ServerProfile struct {
-	Name             string          `json:"name"`
-	Endpoint         string          `json:"endpoint"`
-	Address          string          `json:"address"`
-	DNS              []string        `json:"dns"`
+	// SchemaVersion is the profile format the profile was stored with; see
+	// CurrentSchemaVersion. SaveServerProfile always writes the current version.
+	SchemaVersion int      `json:"schema_version"`
+	Name          string   `json:"name"`
+	Endpoint      string   `json:"endpoint"`
+	Address       string   `json:"address"`
+	Subnet        string   `json:"subnet,omitempty"`
+	Address6      string   `json:"address6,omitempty"`
+	Subnet6       string   `json:"subnet6,omitempty"`
+	DNS           []string `json:"dns"`
+	// SearchDomains follow the DNS servers on the client DNS line.
+	SearchDomains    []string        `json:"search_domains,omitempty"`
 	ServerPrivateKey string          `json:"server_private_key"`
 	ServerPublicKey  string          `json:"server_public_key"`
+	KeyHistory       []RetiredKey    `json:"key_history,omitempty"`
 	Clients          []ClientProfile `json:"clients"`
+	Policies         []AccessPolicy  `json:"policies,omitempty"`
+	// Groups are shared AllowedIPs and DNS defaults clients opt into (see ClientGroup).
+	Groups []ClientGroup `json:"groups,omitempty"`
+	// DNSRoutes enables split DNS: only these domains resolve through the tunnel.
+	DNSRoutes   []DNSRoute `json:"dns_routes,omitempty"`
+	DNSResolver string     `json:"dns_resolver,omitempty"`
+	// Description and Alias label the interface for operators; Alias is applied
+	// with `ip link set ... alias` when the interface comes up.
+	Description string `json:"description,omitempty"`
+	Alias       string `json:"alias,omitempty"`
+	// Annotations carry arbitrary metadata for external systems (ticket IDs, cost centers).
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// ExternalInterface names an interface owned by another tool (e.g. systemd-networkd).
+	// When set WireStack only manages its peers and never touches addresses or routes.
+	ExternalInterface string `json:"external_interface,omitempty"`
+	// ClientExtra is appended verbatim to the [Interface] section of every client config.
+	ClientExtra string `json:"client_extra,omitempty"`
+	// MTU is rendered into the server config; zero leaves it to wg-quick.
+	MTU int `json:"mtu,omitempty"`
+	// PostUp and PostDown are custom hooks rendered into the server config.
+	PostUp   []string `json:"post_up,omitempty"`
+	PostDown []string `json:"post_down,omitempty"`
+	// NATInterface is the egress interface client traffic is masqueraded out of;
+	// NATBackend picks iptables or nftables rules (see NATHooks).
+	NATInterface string `json:"nat_interface,omitempty"`
+	NATBackend   string `json:"nat_backend,omitempty"`
+	// PortMapping asks the router to forward the listen port on up; see
+	// PortMappingMethods.
+	PortMapping string `json:"port_mapping,omitempty"`
+	// AlternateEndpoints are tried in order by failover when Endpoint is
+	// unreachable; client configs always use Endpoint.
+	AlternateEndpoints []string `json:"alternate_endpoints,omitempty"`
+	// VersionPolicy sets the minimum client app version; see EnforceVersionPolicy.
+	VersionPolicy *VersionPolicy `json:"version_policy,omitempty"`
+	// Networks are routed networks behind the server, such as office LANs.
+	// Split-tunnel clients include them in their AllowedIPs (see SetServerNetworks).
+	Networks []string `json:"networks,omitempty"`
+	// Amnezia holds AmneziaWG obfuscation parameters; nil for plain WireGuard.
+	Amnezia *AmneziaParams `json:"amnezia,omitempty"`
 }
 
-// SaveServerProfile writes the server profile JSON to disk with restrictive permissions.
+// SaveServerProfile persists the server profile in the current store.
 func SaveServerProfile(profile *ServerProfile) error {
 	if profile == nil {
 		return fmt.Errorf("profile is nil")
 	}
-	path, err := ServerProfilePath(profile.Name)
-	if err != nil {
-		return err
-	}
-	if err := utils.WriteJSON(path, profile, 0o600); err != nil {
-		return err
+	if profile.Name == "" {
+		return fmt.Errorf("server name is empty")
 	}
-	return nil
+	profile.SchemaVersion = CurrentSchemaVersion
+	return CurrentStore().Save(profile)
 }
 
-// LoadServerProfile reads a server profile from disk.
+// LoadServerProfile reads a server profile from the current store.
 func LoadServerProfile(name string) (*ServerProfile, error) {
-	path, err := ServerProfilePath(name)
-	if err != nil {
-		return nil, err
-	}
-	var profile ServerProfile
-	if err := utils.ReadJSON(path, &profile); err != nil {
-		return nil, err
+	if name == "" {
+		return nil, fmt.Errorf("server name is empty")
 	}
-	return &profile, nil
+	return CurrentStore().Load(name)
 }
 
 // ListServerProfiles returns the names of all stored server profiles.
 func ListServerProfiles() ([]string, error) {
-	root, err := ServersRoot()
-	if err != nil {
-		return nil, err
-	}
-	entries, err := os.ReadDir(root)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read servers directory: %w", err)
-	}
-	var names []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		if filepath.Ext(entry.Name()) != ".json" {
-			continue
-		}
-		names = append(names, entry.Name()[:len(entry.Name())-len(".json")])
-	}
-	return names, nil
+	return CurrentStore().List()
 }
 
-// DeleteServerProfile removes the stored server profile JSON.
+// DeleteServerProfile removes the stored server profile and its rendered runtime config.
 func DeleteServerProfile(name string) error {
-	path, err := ServerProfilePath(name)
-	if err != nil {
-		return err
+	if name == "" {
+		return fmt.Errorf("server name is empty")
 	}
-	if err := os.Remove(path); err != nil {
-		return fmt.Errorf("failed to delete server profile %s: %w", name, err)
+	if err := CurrentStore().Delete(name); err != nil {
+		return err
 	}
 	runtimePath, err := ServerRuntimeConfigPath(name)
 	if err == nil {
@@ -99,39 +157,83 @@ func DeleteServerProfile(name string) error {
 
 // ProfileExists reports whether a server profile already exists.
 func ProfileExists(name string) (bool, error) {
-	path, err := ServerProfilePath(name)
-	if err != nil {
-		return false, err
-	}
-	_, err = os.Stat(path)
+	_, err := LoadServerProfile(name)
 	if err == nil {
 		return true, nil
 	}
-	if os.IsNotExist(err) {
+	if errors.Is(err, ErrProfileNotFound) {
 		return false, nil
 	}
 	return false, err
 }
 
-// NextClientAddress computes the next available client address in the 10.0.0.0/24 range.
+// NextClientAddress allocates the lowest unused client address in the server's subnet,
+// reusing addresses freed by deleted clients.
 func NextClientAddress(profile *ServerProfile) (string, error) {
-	_, network, err := net.ParseCIDR("10.0.0.0/24")
+	network, err := ClientSubnet(profile)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse default client network: %w", err)
+		return "", err
 	}
-	// Start at .2 to leave .1 for the server address.
-	nextHost := 2 + len(profile.Clients)
-	if nextHost >= 255 {
-		return "", fmt.Errorf("client capacity exceeded for network %s", network.String())
+	used := make([]net.IP, 0, len(profile.Clients)+1)
+	if ip := parseAddress(profile.Address); ip != nil {
+		used = append(used, ip)
 	}
-	ip := network.IP.To4()
-	if ip == nil {
-		return "", fmt.Errorf("default network is not IPv4")
+	for _, client := range profile.Clients {
+		if ip := parseAddress(client.Address); ip != nil {
+			used = append(used, ip)
+		}
+	}
+	ip, err := AllocateAddress(network, used)
+	if err != nil {
+		return "", err
 	}
-	ip[3] = byte(nextHost)
 	return fmt.Sprintf("%s/32", ip.String()), nil
 }
 
+// NextClientAddress6 allocates the lowest unused IPv6 client address, or returns an
+// empty string when the server is not dual-stack.
+func NextClientAddress6(profile *ServerProfile) (string, error) {
+	if profile.Subnet6 == "" {
+		return "", nil
+	}
+	network, err := ParseSubnet6(profile.Subnet6)
+	if err != nil {
+		return "", err
+	}
+	used := make([]net.IP, 0, len(profile.Clients)+1)
+	if ip := parseAddress(profile.Address6); ip != nil {
+		used = append(used, ip)
+	}
+	for _, client := range profile.Clients {
+		if ip := parseAddress(client.Address6); ip != nil {
+			used = append(used, ip)
+		}
+	}
+	ip, err := AllocateAddress(network, used)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/128", ip.String()), nil
+}
+
+// ClientAddresses returns the client's interface addresses, IPv4 first.
+func ClientAddresses(client ClientProfile) []string {
+	addresses := []string{client.Address}
+	if client.Address6 != "" {
+		addresses = append(addresses, client.Address6)
+	}
+	return addresses
+}
+
+// ServerAddresses returns the server's interface addresses, IPv4 first.
+func ServerAddresses(profile *ServerProfile) []string {
+	addresses := []string{profile.Address}
+	if profile.Address6 != "" {
+		addresses = append(addresses, profile.Address6)
+	}
+	return addresses
+}
+
 // FindClient returns the client from the profile matching the provided name.
 func FindClient(profile *ServerProfile, clientName string) (*ClientProfile, error) {
 	for idx := range profile.Clients {
@@ -139,7 +241,19 @@ func FindClient(profile *ServerProfile, clientName string) (*ClientProfile, erro
 			return &profile.Clients[idx], nil
 		}
 	}
-	return nil, fmt.Errorf("client %s not found", clientName)
+	return nil, fmt.Errorf("client %s: %w", clientName, ErrClientNotFound)
+}
+
+// RemoveClient deletes the named client from the profile and returns the removed entry.
+func RemoveClient(profile *ServerProfile, clientName string) (ClientProfile, error) {
+	for idx := range profile.Clients {
+		if profile.Clients[idx].Name == clientName {
+			removed := profile.Clients[idx]
+			profile.Clients = append(profile.Clients[:idx], profile.Clients[idx+1:]...)
+			return removed, nil
+		}
+	}
+	return ClientProfile{}, fmt.Errorf("client %s: %w", clientName, ErrClientNotFound)
 }
 
 // DefaultServerProfile builds a base server profile with generated keys and defaults.
@@ -148,6 +262,7 @@ func DefaultServerProfile(name, endpoint, privateKey, publicKey string) *ServerP
 		Name:             name,
 		Endpoint:         endpoint,
 		Address:          "10.0.0.1/24",
+		Subnet:           DefaultSubnet,
 		DNS:              []string{"1.1.1.1", "9.9.9.9"},
 		ServerPrivateKey: privateKey,
 		ServerPublicKey:  publicKey,
@@ -155,7 +270,224 @@ func DefaultServerProfile(name, endpoint, privateKey, publicKey string) *ServerP
 	}
 }
 
-// ClientAllowedIPs returns default allowed IPs for clients.
+// ClientExtraFor returns the extra interface lines rendered for the client,
+// preferring the client's own override over the server-wide default.
+func ClientExtraFor(profile *ServerProfile, client ClientProfile) string {
+	if client.Extra != "" {
+		return client.Extra
+	}
+	return profile.ClientExtra
+}
+
+// ClientAllowedIPs returns the full-tunnel allowed IPs for clients.
 func ClientAllowedIPs() []string {
 	return []string{"0.0.0.0/0", "::/0"}
 }
+
+// ServerOptions holds the user-supplied settings for a new server profile.
+type ServerOptions struct {
+	Name              string
+	Endpoint          string
+	Subnet            string
+	Subnet6           string
+	ExternalInterface string
+	ClientExtra       string
+	Description       string
+	Alias             string
+	MTU               int
+	// NATInterface enables masquerading out of this interface with NATBackend.
+	NATInterface string
+	NATBackend   string
+	PostUp       []string
+	PostDown     []string
+	// Networks are LAN ranges behind the server routed to split-tunnel clients.
+	Networks []string
+	// SearchDomains are rendered after the DNS servers in client configs.
+	SearchDomains []string
+	// PortMapping is the router port forwarding method, if any.
+	PortMapping string
+	// AlternateEndpoints are where clients fail over to; see SetAlternateEndpoints.
+	AlternateEndpoints []string
+}
+
+// NewServerProfile validates opts, obtains server keys, and builds a profile
+// ready to be saved. It fails if a profile with the same name exists.
+func NewServerProfile(opts ServerOptions) (*ServerProfile, error) {
+	return NewServerProfileContext(context.Background(), opts)
+}
+
+// NewServerProfileContext is NewServerProfile stopping the wg commands it
+// runs when ctx is done.
+func NewServerProfileContext(ctx context.Context, opts ServerOptions) (*ServerProfile, error) {
+	if opts.Name == "" || opts.Endpoint == "" {
+		return nil, fmt.Errorf("server name and endpoint are required")
+	}
+	if err := ValidateServerName(opts.Name); err != nil {
+		return nil, err
+	}
+	exists, err := ProfileExists(opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("server %s already exists", opts.Name)
+	}
+
+	subnet := opts.Subnet
+	if subnet == "" {
+		subnet = DefaultSubnet
+	}
+	if _, err := ParseSubnet(subnet); err != nil {
+		return nil, err
+	}
+	if opts.Subnet6 != "" {
+		if _, err := ParseSubnet6(opts.Subnet6); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint := opts.Endpoint
+	if IsAutoEndpoint(endpoint) {
+		settings, err := LoadSettings()
+		if err != nil {
+			return nil, err
+		}
+		if endpoint, err = ResolveAutoEndpoint(ctx, endpoint, settings.PublicIP); err != nil {
+			return nil, fmt.Errorf("detect endpoint: %w; run wirestack whatismyip to see what each provider answered", err)
+		}
+	}
+
+	var privateKey, publicKey string
+	if opts.ExternalInterface != "" {
+		// The owning tool holds the private key; only the public half is needed for clients.
+		publicKey, err = InterfacePublicKeyContext(ctx, opts.ExternalInterface)
+	} else {
+		privateKey, publicKey, err = GenerateKeyPairContext(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	profile := DefaultServerProfile(opts.Name, endpoint, privateKey, publicKey)
+	profile.ExternalInterface = opts.ExternalInterface
+	profile.ClientExtra = opts.ClientExtra
+	profile.Description = opts.Description
+	if err := ValidateMTU(opts.MTU); err != nil {
+		return nil, err
+	}
+	profile.MTU = opts.MTU
+	if opts.Alias != "" {
+		if err := ValidateAlias(opts.Alias); err != nil {
+			return nil, err
+		}
+		profile.Alias = opts.Alias
+	}
+	if err := ApplySubnet(profile, subnet); err != nil {
+		return nil, err
+	}
+	if opts.Subnet6 != "" {
+		if err := ApplySubnet6(profile, opts.Subnet6); err != nil {
+			return nil, err
+		}
+	}
+	if err := SetNAT(profile, opts.NATInterface, opts.NATBackend); err != nil {
+		return nil, err
+	}
+	if err := SetServerHooks(profile, opts.PostUp, opts.PostDown); err != nil {
+		return nil, err
+	}
+	if err := SetServerNetworks(profile, opts.Networks); err != nil {
+		return nil, err
+	}
+	if err := SetPortMapping(profile, opts.PortMapping); err != nil {
+		return nil, err
+	}
+	if err := SetSearchDomains(profile, opts.SearchDomains); err != nil {
+		return nil, err
+	}
+	if err := SetAlternateEndpoints(profile, opts.AlternateEndpoints); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// ClientOptions holds the user-supplied settings for a new client.
+type ClientOptions struct {
+	Name        string
+	Tags        []string
+	Extra       string
+	Description string
+	ExpiresAt   *time.Time
+	// Mode is ClientModeFull (the default) or ClientModeSplit.
+	Mode string
+	// SplitRoutes are extra networks routed through the tunnel in split mode.
+	SplitRoutes []string
+	// AllowedIPs and DNS override the server defaults for this client when set.
+	AllowedIPs []string
+	DNS        []string
+	MTU        int
+	// Group is an existing group the client joins.
+	Group string
+}
+
+// AddClient generates keys and addresses for a new client and appends it to
+// the profile. The caller is responsible for saving the profile.
+func AddClient(profile *ServerProfile, opts ClientOptions) (ClientProfile, error) {
+	return AddClientContext(context.Background(), profile, opts)
+}
+
+// AddClientContext is AddClient stopping key generation when ctx is done.
+func AddClientContext(ctx context.Context, profile *ServerProfile, opts ClientOptions) (ClientProfile, error) {
+	if opts.Name == "" {
+		return ClientProfile{}, fmt.Errorf("client name is required")
+	}
+	if err := ValidateClientName(opts.Name); err != nil {
+		return ClientProfile{}, err
+	}
+	if _, err := FindClient(profile, opts.Name); err == nil {
+		return ClientProfile{}, fmt.Errorf("client %s already exists on server %s", opts.Name, profile.Name)
+	}
+
+	privateKey, publicKey, err := GenerateKeyPairContext(ctx)
+	if err != nil {
+		return ClientProfile{}, err
+	}
+	address, err := NextClientAddress(profile)
+	if err != nil {
+		return ClientProfile{}, err
+	}
+	address6, err := NextClientAddress6(profile)
+	if err != nil {
+		return ClientProfile{}, err
+	}
+
+	client := ClientProfile{
+		Name:        opts.Name,
+		PrivateKey:  privateKey,
+		PublicKey:   publicKey,
+		Address:     address,
+		Address6:    address6,
+		Tags:        opts.Tags,
+		Extra:       opts.Extra,
+		Description: opts.Description,
+		ExpiresAt:   opts.ExpiresAt,
+	}
+	if err := ApplyClientMode(profile, &client, opts.Mode, opts.SplitRoutes); err != nil {
+		return ClientProfile{}, err
+	}
+	if err := SetClientAllowedIPs(&client, opts.AllowedIPs); err != nil {
+		return ClientProfile{}, err
+	}
+	if err := SetClientDNS(&client, opts.DNS); err != nil {
+		return ClientProfile{}, err
+	}
+	if err := SetClientGroup(profile, &client, opts.Group); err != nil {
+		return ClientProfile{}, err
+	}
+	if err := ValidateMTU(opts.MTU); err != nil {
+		return ClientProfile{}, err
+	}
+	client.MTU = opts.MTU
+	profile.Clients = append(profile.Clients, client)
+	return client, nil
+}
@@ -0,0 +1,384 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Public IP provider names; see ParseIPProvider for the full spellings.
+const (
+	IPProviderSTUN     = "stun"
+	IPProviderUPnP     = "upnp"
+	IPProviderMetadata = "metadata"
+)
+
+// DefaultIPProviders are tried in order when public_ip.providers is unset.
+var DefaultIPProviders = []string{"stun:stun.l.google.com:19302", "https://api.ipify.org"}
+
+// DefaultIPLookupTimeout bounds each provider when public_ip.timeout is unset.
+const DefaultIPLookupTimeout = 3 * time.Second
+
+// AutoEndpointHost in an endpoint, as in "auto:51820", is replaced by the
+// detected public address.
+const AutoEndpointHost = "auto"
+
+// defaultAutoEndpointPort is used for a bare "auto" endpoint.
+const defaultAutoEndpointPort = "51820"
+
+// metadataServices are the cloud metadata lookups "metadata" tries, in order.
+var metadataServices = []string{"aws", "gcp", "azure"}
+
+// metadataBaseURL is the link-local metadata address shared by the clouds;
+// tests point it at a local server.
+var metadataBaseURL = "http://169.254.169.254"
+
+// PublicIPSettings configures public address detection.
+type PublicIPSettings struct {
+	// Providers are tried in order until one returns a public address:
+	// "stun[:host:port]", an http(s) URL answering with the address as
+	// text, "upnp", or "metadata[:aws|gcp|azure]".
+	Providers []string `json:"providers,omitempty"`
+	// Timeout is a Go duration bounding each provider, such as "3s".
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// ProviderList returns the configured providers, or DefaultIPProviders.
+func (s *PublicIPSettings) ProviderList() []string {
+	if s == nil || len(s.Providers) == 0 {
+		return DefaultIPProviders
+	}
+	return s.Providers
+}
+
+// LookupTimeout parses Timeout, returning DefaultIPLookupTimeout when unset.
+func (s *PublicIPSettings) LookupTimeout() (time.Duration, error) {
+	if s == nil {
+		return DefaultIPLookupTimeout, nil
+	}
+	timeout, err := parsePositiveDuration("public_ip.timeout", s.Timeout)
+	if err != nil || timeout != 0 {
+		return timeout, err
+	}
+	return DefaultIPLookupTimeout, nil
+}
+
+// IPLookup asks one provider for this host's public address.
+type IPLookup func(ctx context.Context) (string, error)
+
+// ParseIPProvider returns the lookup for a provider spec.
+func ParseIPProvider(spec string) (IPLookup, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	switch {
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return func(ctx context.Context) (string, error) { return httpPublicIP(ctx, spec) }, nil
+	case name == IPProviderSTUN:
+		server := arg
+		if server == "" {
+			server = "stun.l.google.com:19302"
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return nil, fmt.Errorf("provider %q: stun server must be host:port", spec)
+		}
+		return func(ctx context.Context) (string, error) { return stunPublicIP(ctx, server) }, nil
+	case name == IPProviderUPnP && arg == "":
+		return func(ctx context.Context) (string, error) {
+			gateway, err := DiscoverUPnPGateway(ctx)
+			if err != nil {
+				return "", err
+			}
+			return gateway.ExternalIP(ctx)
+		}, nil
+	case name == IPProviderMetadata:
+		services := metadataServices
+		if arg != "" {
+			if !containsString(metadataServices, arg) {
+				return nil, fmt.Errorf("provider %q: unknown cloud %q (want %s)", spec, arg, strings.Join(metadataServices, ", "))
+			}
+			services = []string{arg}
+		}
+		return func(ctx context.Context) (string, error) { return metadataPublicIP(ctx, services) }, nil
+	}
+	return nil, fmt.Errorf("unknown public IP provider %q (want stun[:host:port], an http(s) URL, upnp, or metadata[:cloud])", spec)
+}
+
+// PublicIPAttempt is the outcome of asking one provider.
+type PublicIPAttempt struct {
+	Provider string
+	Address  string
+	Err      error
+	Elapsed  time.Duration
+}
+
+// DetectPublicIP asks providers in order, each bounded by timeout, and returns
+// the first public address along with every attempt made. Answers that are
+// private, such as a router behind carrier-grade NAT reporting its WAN
+// address, count as failures so the next provider is tried. With all set,
+// every provider is asked even after one succeeded.
+func DetectPublicIP(ctx context.Context, providers []string, timeout time.Duration, all bool) (string, []PublicIPAttempt, error) {
+	lookups := make([]IPLookup, 0, len(providers))
+	for _, spec := range providers {
+		lookup, err := ParseIPProvider(spec)
+		if err != nil {
+			return "", nil, err
+		}
+		lookups = append(lookups, lookup)
+	}
+
+	var found string
+	var attempts []PublicIPAttempt
+	for idx, lookup := range lookups {
+		attempt := PublicIPAttempt{Provider: providers[idx]}
+		start := time.Now()
+		lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+		attempt.Address, attempt.Err = lookup(lookupCtx)
+		cancel()
+		attempt.Elapsed = time.Since(start)
+		if attempt.Err == nil {
+			attempt.Address, attempt.Err = publicAddress(attempt.Address)
+		}
+		attempts = append(attempts, attempt)
+		if attempt.Err == nil && found == "" {
+			found = attempt.Address
+			if !all {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if found == "" {
+		return "", attempts, fmt.Errorf("no provider found a public address")
+	}
+	return found, attempts, nil
+}
+
+// carrierGradeNAT is the shared address space of RFC 6598.
+var carrierGradeNAT = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress normalizes an answer, rejecting anything not routable on the
+// internet.
+func publicAddress(answer string) (string, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(answer))
+	if err != nil {
+		return "", fmt.Errorf("answer %q is not an IP address", strings.TrimSpace(answer))
+	}
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() || addr.IsMulticast() || carrierGradeNAT.Contains(addr) {
+		return "", fmt.Errorf("%s is not a public address", addr)
+	}
+	return addr.String(), nil
+}
+
+// IsAutoEndpoint reports whether endpoint is "auto" or "auto:<port>".
+func IsAutoEndpoint(endpoint string) bool {
+	host, _, err := net.SplitHostPort(endpoint)
+	return endpoint == AutoEndpointHost || (err == nil && host == AutoEndpointHost)
+}
+
+// ResolveAutoEndpoint replaces an "auto" or "auto:<port>" endpoint with the
+// detected public address. Other endpoints are returned unchanged.
+func ResolveAutoEndpoint(ctx context.Context, endpoint string, settings *PublicIPSettings) (string, error) {
+	if !IsAutoEndpoint(endpoint) {
+		return endpoint, nil
+	}
+	port := defaultAutoEndpointPort
+	if endpoint != AutoEndpointHost {
+		_, port, _ = net.SplitHostPort(endpoint)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", fmt.Errorf("invalid endpoint port %q", port)
+	}
+	timeout, err := settings.LookupTimeout()
+	if err != nil {
+		return "", err
+	}
+	address, _, err := DetectPublicIP(ctx, settings.ProviderList(), timeout, false)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(address, port), nil
+}
+
+// httpPublicIP fetches url, which answers with the address as plain text.
+func httpPublicIP(ctx context.Context, url string) (string, error) {
+	return textGet(ctx, http.MethodGet, url, nil)
+}
+
+// metadataPublicIP asks each cloud metadata service in turn.
+func metadataPublicIP(ctx context.Context, services []string) (string, error) {
+	var failures []string
+	for _, service := range services {
+		var address string
+		var err error
+		switch service {
+		case "aws":
+			// IMDSv2 needs a session token first.
+			var token string
+			token, err = textGet(ctx, http.MethodPut, metadataBaseURL+"/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+			if err == nil {
+				address, err = textGet(ctx, http.MethodGet, metadataBaseURL+"/latest/meta-data/public-ipv4", map[string]string{"X-aws-ec2-metadata-token": token})
+			}
+		case "gcp":
+			address, err = textGet(ctx, http.MethodGet, metadataBaseURL+"/computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip", map[string]string{"Metadata-Flavor": "Google"})
+		case "azure":
+			address, err = textGet(ctx, http.MethodGet, metadataBaseURL+"/metadata/instance/network/interface/0/ipv4/ipAddress/0/publicIpAddress?api-version=2021-02-01&format=text", map[string]string{"Metadata": "true"})
+		}
+		if err == nil && address != "" {
+			return address, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no public address assigned")
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", service, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", fmt.Errorf("%s", strings.Join(failures, "; "))
+}
+
+// textGet performs a small request and returns the trimmed body of a
+// successful response.
+func textGet(ctx context.Context, method, url string, headers map[string]string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", url, response.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// STUN message fields from RFC 5389.
+const (
+	stunBindingRequest   = 0x0001
+	stunBindingSuccess   = 0x0101
+	stunMagicCookie      = 0x2112A442
+	stunMappedAddress    = 0x0001
+	stunXORMappedAddress = 0x0020
+)
+
+// stunPublicIP sends a STUN binding request to server and returns the
+// address it saw the request come from.
+func stunPublicIP(ctx context.Context, server string) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := make([]byte, 20)
+	binary.BigEndian.PutUint16(request[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return "", fmt.Errorf("stun %s: %w", server, err)
+	}
+	response := make([]byte, 1024)
+	for {
+		n, err := conn.Read(response)
+		if err != nil {
+			return "", fmt.Errorf("stun %s: %w", server, err)
+		}
+		if n < 20 || !bytes.Equal(response[8:20], request[8:20]) {
+			continue
+		}
+		return parseSTUNResponse(response[:n])
+	}
+}
+
+// parseSTUNResponse extracts the mapped address from a binding response,
+// preferring XOR-MAPPED-ADDRESS.
+func parseSTUNResponse(message []byte) (string, error) {
+	if binary.BigEndian.Uint16(message[0:]) != stunBindingSuccess {
+		return "", fmt.Errorf("stun server answered with message type %#04x", binary.BigEndian.Uint16(message[0:]))
+	}
+	length := int(binary.BigEndian.Uint16(message[2:]))
+	if 20+length > len(message) {
+		return "", fmt.Errorf("truncated stun response")
+	}
+	var mapped string
+	attributes := message[20 : 20+length]
+	for len(attributes) >= 4 {
+		kind := binary.BigEndian.Uint16(attributes[0:])
+		size := int(binary.BigEndian.Uint16(attributes[2:]))
+		if 4+size > len(attributes) {
+			break
+		}
+		value := attributes[4 : 4+size]
+		switch kind {
+		case stunXORMappedAddress:
+			if address, ok := stunAddress(value, message[4:20]); ok {
+				return address, nil
+			}
+		case stunMappedAddress:
+			if address, ok := stunAddress(value, nil); ok {
+				mapped = address
+			}
+		}
+		// Attributes are padded to four bytes.
+		attributes = attributes[4+(size+3)&^3:]
+	}
+	if mapped == "" {
+		return "", fmt.Errorf("stun response has no mapped address")
+	}
+	return mapped, nil
+}
+
+// stunAddress decodes a (XOR-)MAPPED-ADDRESS value. key is the magic cookie
+// and transaction ID the address is XORed with, or nil for MAPPED-ADDRESS.
+func stunAddress(value, key []byte) (string, bool) {
+	if len(value) < 4 {
+		return "", false
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return "", false
+	}
+	if len(value) < 4+size {
+		return "", false
+	}
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if key != nil {
+		for idx := range ip {
+			ip[idx] ^= key[idx]
+		}
+	}
+	return ip.String(), true
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectPublicIPFallsBack(t *testing.T) {
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "192.168.1.20")
+	}))
+	defer private.Close()
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "198.51.100.7")
+	}))
+	defer public.Close()
+
+	address, attempts, err := DetectPublicIP(context.Background(), []string{private.URL, public.URL, "metadata"}, time.Second, false)
+	if err != nil {
+		t.Fatalf("DetectPublicIP: %v", err)
+	}
+	if address != "198.51.100.7" || len(attempts) != 2 {
+		t.Fatalf("expected the second provider to win after the first, got %s from %+v", address, attempts)
+	}
+	if attempts[0].Err == nil || !strings.Contains(attempts[0].Err.Error(), "not a public address") {
+		t.Fatalf("expected the private answer rejected, got %v", attempts[0].Err)
+	}
+
+	if _, _, err := DetectPublicIP(context.Background(), []string{"carrier-pigeon"}, time.Second, false); err == nil {
+		t.Fatal("expected an unknown provider to be rejected")
+	}
+	if _, attempts, err := DetectPublicIP(context.Background(), []string{private.URL}, time.Second, false); err == nil || len(attempts) != 1 {
+		t.Fatalf("expected no address, got %+v, %v", attempts, err)
+	}
+}
+
+func TestResolveAutoEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2001:db8::1")
+	}))
+	defer server.Close()
+	settings := &PublicIPSettings{Providers: []string{server.URL}}
+
+	for endpoint, want := range map[string]string{
+		"auto":              "[2001:db8::1]:51820",
+		"auto:443":          "[2001:db8::1]:443",
+		"vpn.example.com:1": "vpn.example.com:1",
+	} {
+		got, err := ResolveAutoEndpoint(context.Background(), endpoint, settings)
+		if err != nil || got != want {
+			t.Fatalf("ResolveAutoEndpoint(%q) = %q, %v; want %q", endpoint, got, err, want)
+		}
+	}
+	if _, err := ResolveAutoEndpoint(context.Background(), "auto:99999", settings); err == nil {
+		t.Fatal("expected an invalid port to be rejected")
+	}
+}
+
+func TestMetadataPublicIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/latest/api/token" && r.Method == http.MethodPut:
+			fmt.Fprint(w, "token")
+		case r.URL.Path == "/latest/meta-data/public-ipv4" && r.Header.Get("X-aws-ec2-metadata-token") == "token":
+			fmt.Fprint(w, "203.0.113.40")
+		case strings.HasPrefix(r.URL.Path, "/computeMetadata/") && r.Header.Get("Metadata-Flavor") == "Google":
+			fmt.Fprint(w, "203.0.113.41")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	previous := metadataBaseURL
+	metadataBaseURL = server.URL
+	t.Cleanup(func() { metadataBaseURL = previous })
+
+	for spec, want := range map[string]string{"metadata": "203.0.113.40", "metadata:gcp": "203.0.113.41"} {
+		lookup, err := ParseIPProvider(spec)
+		if err != nil {
+			t.Fatalf("ParseIPProvider(%q): %v", spec, err)
+		}
+		if got, err := lookup(context.Background()); err != nil || got != want {
+			t.Fatalf("%s: got %q, %v; want %s", spec, got, err, want)
+		}
+	}
+	lookup, _ := ParseIPProvider("metadata:azure")
+	if _, err := lookup(context.Background()); err == nil || !strings.Contains(err.Error(), "azure") {
+		t.Fatalf("expected the azure failure reported, got %v", err)
+	}
+	if _, err := ParseIPProvider("metadata:oracle"); err == nil {
+		t.Fatal("expected an unknown cloud to be rejected")
+	}
+}
+
+func TestSTUNPublicIP(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buffer := make([]byte, 512)
+		n, peer, err := conn.ReadFrom(buffer)
+		if err != nil || n < 20 {
+			return
+		}
+		// Answer with XOR-MAPPED-ADDRESS 203.0.113.9:40000.
+		response := make([]byte, 32)
+		binary.BigEndian.PutUint16(response[0:], stunBindingSuccess)
+		binary.BigEndian.PutUint16(response[2:], 12)
+		copy(response[4:20], buffer[4:20])
+		binary.BigEndian.PutUint16(response[20:], stunXORMappedAddress)
+		binary.BigEndian.PutUint16(response[22:], 8)
+		response[25] = 0x01
+		binary.BigEndian.PutUint16(response[26:], 40000^uint16(stunMagicCookie>>16))
+		ip := net.ParseIP("203.0.113.9").To4()
+		for idx := range ip {
+			response[28+idx] = ip[idx] ^ buffer[4+idx]
+		}
+		conn.WriteTo(response, peer)
+	}()
+
+	lookup, err := ParseIPProvider("stun:" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("ParseIPProvider: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if got, err := lookup(ctx); err != nil || got != "203.0.113.9" {
+		t.Fatalf("expected 203.0.113.9, got %q, %v", got, err)
+	}
+}
+
+func TestUPnPExternalIP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0"?><root xmlns="urn:schemas-upnp-org:device-1-0"><device>
+<deviceList><device><deviceList><device><serviceList><service>
+<serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+<controlURL>/ctl/IPConn</controlURL>
+</service></serviceList></device></deviceList></device></deviceList>
+</device></root>`)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("SOAPAction"), "#GetExternalIPAddress") {
+			http.Error(w, "unexpected action", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+<NewExternalIPAddress>203.0.113.77</NewExternalIPAddress>
+</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	gateway, err := upnpGatewayAt(context.Background(), server.URL+"/desc.xml")
+	if err != nil {
+		t.Fatalf("upnpGatewayAt: %v", err)
+	}
+	if gateway.ControlURL != server.URL+"/ctl/IPConn" {
+		t.Fatalf("expected the nested control URL resolved, got %s", gateway.ControlURL)
+	}
+	if address, err := gateway.ExternalIP(context.Background()); err != nil || address != "203.0.113.77" {
+		t.Fatalf("expected 203.0.113.77, got %q, %v", address, err)
+	}
+	if got := ssdpHeader("HTTP/1.1 200 OK\r\nLocation: http://192.168.1.1:5000/desc.xml\r\n\r\n", "LOCATION"); got != "http://192.168.1.1:5000/desc.xml" {
+		t.Fatalf("unexpected location %q", got)
+	}
+}
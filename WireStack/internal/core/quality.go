@@ -0,0 +1,318 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// Connection quality levels.
+const (
+	QualityGood     = "good"
+	QualityDegraded = "degraded"
+	QualityPoor     = "poor"
+	// QualityUnknown is reported until a client has enough samples to score.
+	QualityUnknown = "unknown"
+)
+
+// QualitySampleInterval is the minimum spacing between recorded samples, so
+// frequent status polls do not flood the history.
+const QualitySampleInterval = time.Minute
+
+// qualityHistoryLimit caps the samples kept per client: a day at one per minute.
+const qualityHistoryLimit = 1440
+
+// qualityWindow is how many recent samples the score is computed from.
+const qualityWindow = 15
+
+// staleHandshake is the handshake age after which an active session has
+// missed at least one rekey; WireGuard rekeys every two minutes under traffic.
+const staleHandshake = 3 * time.Minute
+
+// QualitySample is one observation of a client's connection.
+type QualitySample struct {
+	At time.Time `json:"at"`
+	// HandshakeAge is in seconds; -1 means the peer never completed a handshake.
+	HandshakeAge int64 `json:"handshake_age"`
+	RxBytes      int64 `json:"rx_bytes"`
+	TxBytes      int64 `json:"tx_bytes"`
+	// Probed is set when the sample includes a ping probe.
+	Probed bool    `json:"probed,omitempty"`
+	RTTMs  float64 `json:"rtt_ms,omitempty"`
+	Loss   float64 `json:"loss,omitempty"`
+}
+
+// QualityHistory holds the recorded samples of one server's clients, oldest first.
+type QualityHistory struct {
+	Server  string                     `json:"server"`
+	Clients map[string][]QualitySample `json:"clients"`
+}
+
+// QualityScore summarises the recent samples of a client. Score runs from 0
+// to 100; Reasons explains any deductions.
+type QualityScore struct {
+	Score   int
+	Level   string
+	Samples int
+	Reasons []string
+}
+
+// ProbeResult is the outcome of pinging a client through the tunnel.
+type ProbeResult struct {
+	RTT  time.Duration
+	Loss float64
+}
+
+// LoadQualityHistory reads a server's quality history, returning an empty
+// history when nothing has been recorded yet.
+func LoadQualityHistory(server string) (*QualityHistory, error) {
+	path, err := QualityHistoryPath(server)
+	if err != nil {
+		return nil, err
+	}
+	history := &QualityHistory{Server: server}
+	if err := utils.ReadJSON(path, history); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if history.Clients == nil {
+		history.Clients = map[string][]QualitySample{}
+	}
+	return history, nil
+}
+
+// SaveQualityHistory writes a server's quality history.
+func SaveQualityHistory(history *QualityHistory) error {
+	path, err := QualityHistoryPath(history.Server)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, history, 0o600)
+}
+
+// Record appends sample to the client's history unless the previous sample
+// is less than QualitySampleInterval old. It reports whether it recorded.
+func (h *QualityHistory) Record(client string, sample QualitySample) bool {
+	samples := h.Clients[client]
+	if n := len(samples); n > 0 && sample.At.Sub(samples[n-1].At) < QualitySampleInterval {
+		return false
+	}
+	samples = append(samples, sample)
+	if len(samples) > qualityHistoryLimit {
+		samples = samples[len(samples)-qualityHistoryLimit:]
+	}
+	h.Clients[client] = samples
+	return true
+}
+
+// NewQualitySample builds a sample from a running peer and an optional probe.
+func NewQualitySample(peer PeerStatus, probe *ProbeResult, now time.Time) QualitySample {
+	sample := QualitySample{At: now.UTC(), HandshakeAge: -1, RxBytes: peer.TransferRx, TxBytes: peer.TransferTx}
+	if !peer.LatestHandshake.IsZero() {
+		sample.HandshakeAge = int64(now.Sub(peer.LatestHandshake) / time.Second)
+	}
+	if probe != nil {
+		sample.Probed = true
+		sample.RTTMs = float64(probe.RTT) / float64(time.Millisecond)
+		sample.Loss = probe.Loss
+	}
+	return sample
+}
+
+// RecordQuality samples every known client of a running interface, saves the
+// history when anything was recorded, and returns each client's score.
+// probes holds optional ping results keyed by client name.
+func RecordQuality(profile *ServerProfile, status *InterfaceStatus, probes map[string]ProbeResult, now time.Time) (map[string]QualityScore, error) {
+	// status and the daemon's event poll may record for the same server at once.
+	unlock, err := lockStateFile("quality-" + profile.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	history, err := LoadQualityHistory(profile.Name)
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	for _, entry := range MatchClients(profile, status) {
+		if entry.ClientName == "" {
+			continue
+		}
+		var probe *ProbeResult
+		if result, ok := probes[entry.ClientName]; ok {
+			probe = &result
+		}
+		if history.Record(entry.ClientName, NewQualitySample(entry.Peer, probe, now)) {
+			changed = true
+		}
+	}
+	if changed {
+		if err := SaveQualityHistory(history); err != nil {
+			return nil, err
+		}
+	}
+	return history.Scores(), nil
+}
+
+// Scores scores every client in the history.
+func (h *QualityHistory) Scores() map[string]QualityScore {
+	scores := make(map[string]QualityScore, len(h.Clients))
+	for client, samples := range h.Clients {
+		scores[client] = ScoreQuality(samples)
+	}
+	return scores
+}
+
+// ScoreQuality combines the most recent samples into a score. Between two
+// samples, an interval where the server sent data but the handshake went stale
+// counts as a handshake gap, and one where the server sent data but received
+// nothing counts as a stall (the peer is not answering, so traffic is being
+// retransmitted). Ping probes add loss, RTT, and jitter deductions. Idle
+// clients are not penalised.
+func ScoreQuality(samples []QualitySample) QualityScore {
+	if len(samples) > qualityWindow {
+		samples = samples[len(samples)-qualityWindow:]
+	}
+	result := QualityScore{Score: 100, Level: QualityUnknown, Samples: len(samples)}
+
+	intervals, gaps, stalls := 0, 0, 0
+	for i := 1; i < len(samples); i++ {
+		previous, current := samples[i-1], samples[i]
+		rx, tx := current.RxBytes-previous.RxBytes, current.TxBytes-previous.TxBytes
+		if rx < 0 || tx < 0 {
+			// Counters reset when the interface restarts.
+			continue
+		}
+		intervals++
+		if tx == 0 {
+			continue
+		}
+		if current.HandshakeAge < 0 || time.Duration(current.HandshakeAge)*time.Second > staleHandshake {
+			gaps++
+		} else if rx == 0 {
+			stalls++
+		}
+	}
+
+	probed := 0
+	var rtts []float64
+	var loss float64
+	for _, sample := range samples {
+		if !sample.Probed {
+			continue
+		}
+		probed++
+		loss += sample.Loss
+		if sample.Loss < 1 {
+			rtts = append(rtts, sample.RTTMs)
+		}
+	}
+	if intervals == 0 && probed == 0 {
+		return result
+	}
+
+	deduct := func(points float64, reason string) {
+		if points <= 0 {
+			return
+		}
+		result.Score -= int(math.Round(points))
+		result.Reasons = append(result.Reasons, reason)
+	}
+	if intervals > 0 {
+		deduct(60*float64(gaps)/float64(intervals), fmt.Sprintf("handshake gaps in %d/%d intervals", gaps, intervals))
+		deduct(60*float64(stalls)/float64(intervals), fmt.Sprintf("no reply traffic in %d/%d intervals", stalls, intervals))
+	}
+	if probed > 0 {
+		loss /= float64(probed)
+		deduct(30*loss, fmt.Sprintf("%.0f%% ping loss", loss*100))
+	}
+	if len(rtts) > 0 {
+		mean, jitter := meanStddev(rtts)
+		switch {
+		case mean >= 300:
+			deduct(20, fmt.Sprintf("average RTT %.0f ms", mean))
+		case mean >= 150:
+			deduct(10, fmt.Sprintf("average RTT %.0f ms", mean))
+		}
+		if jitter >= 50 {
+			deduct(10, fmt.Sprintf("RTT jitter %.0f ms", jitter))
+		}
+	}
+
+	if result.Score < 0 {
+		result.Score = 0
+	}
+	switch {
+	case result.Score >= 80:
+		result.Level = QualityGood
+	case result.Score >= 50:
+		result.Level = QualityDegraded
+	default:
+		result.Level = QualityPoor
+	}
+	return result
+}
+
+// meanStddev returns the mean and population standard deviation of values.
+func meanStddev(values []float64) (float64, float64) {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// ProbeClient pings the client's tunnel address three times.
+func ProbeClient(client ClientProfile) (ProbeResult, error) {
+	ip, _, err := net.ParseCIDR(client.Address)
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("client %s has an invalid address %s: %w", client.Name, client.Address, err)
+	}
+	output, err := utils.RunCommand("ping", "-c", "3", "-W", "1", "-q", ip.String())
+	if err != nil && !strings.Contains(err.Error(), "packets transmitted") {
+		return ProbeResult{}, err
+	}
+	if err != nil {
+		// ping exits non-zero when every packet is lost.
+		output = err.Error()
+	}
+	return parsePingOutput(output)
+}
+
+var (
+	pingLoss = regexp.MustCompile(`([\d.]+)% packet loss`)
+	pingRTT  = regexp.MustCompile(`= [\d.]+/([\d.]+)/`)
+)
+
+// parsePingOutput extracts packet loss and average RTT from ping's summary.
+func parsePingOutput(output string) (ProbeResult, error) {
+	match := pingLoss.FindStringSubmatch(output)
+	if match == nil {
+		return ProbeResult{}, fmt.Errorf("unrecognised ping output: %q", output)
+	}
+	percent, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	result := ProbeResult{Loss: percent / 100}
+	if match := pingRTT.FindStringSubmatch(output); match != nil {
+		ms, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return ProbeResult{}, err
+		}
+		result.RTT = time.Duration(ms * float64(time.Millisecond))
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScoreQuality(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(minute int, age, rx, tx int64) QualitySample {
+		return QualitySample{At: start.Add(time.Duration(minute) * time.Minute), HandshakeAge: age, RxBytes: rx, TxBytes: tx}
+	}
+
+	if score := ScoreQuality([]QualitySample{sample(0, 10, 0, 0)}); score.Level != QualityUnknown {
+		t.Fatalf("expected a single sample to be unknown, got %+v", score)
+	}
+
+	healthy := []QualitySample{sample(0, 10, 100, 100), sample(1, 70, 200, 200), sample(2, 10, 300, 300)}
+	if score := ScoreQuality(healthy); score.Level != QualityGood || score.Score != 100 {
+		t.Fatalf("expected a healthy client to score 100, got %+v", score)
+	}
+
+	idle := []QualitySample{sample(0, 600, 100, 100), sample(1, 660, 100, 100), sample(2, 720, 100, 100)}
+	if score := ScoreQuality(idle); score.Level != QualityGood {
+		t.Fatalf("expected an idle client not to be penalised, got %+v", score)
+	}
+
+	// The server keeps sending while the handshake goes stale and nothing comes back.
+	broken := []QualitySample{sample(0, 10, 100, 100), sample(1, 70, 100, 200), sample(2, 250, 100, 300), sample(3, 310, 100, 400)}
+	score := ScoreQuality(broken)
+	if score.Level != QualityPoor || len(score.Reasons) != 2 {
+		t.Fatalf("expected stalls and handshake gaps to score poor, got %+v", score)
+	}
+
+	probed := healthy
+	for i := range probed {
+		probed[i].Probed, probed[i].RTTMs, probed[i].Loss = true, 320, 1.0/3
+	}
+	if score := ScoreQuality(probed); score.Level != QualityDegraded || score.Score != 70 {
+		t.Fatalf("expected loss and RTT to degrade the score to 70, got %+v", score)
+	}
+
+	reset := []QualitySample{sample(0, 10, 5000, 5000), sample(1, 10, 10, 10)}
+	if score := ScoreQuality(reset); score.Level != QualityUnknown {
+		t.Fatalf("expected a counter reset to be skipped, got %+v", score)
+	}
+}
+
+func TestRecordQualityThrottlesAndPersists(t *testing.T) {
+	setupTempHome(t)
+	profile := &ServerProfile{Name: "lab", Clients: []ClientProfile{{Name: "laptop", PublicKey: "laptop-pub"}}}
+	now := time.Now()
+	status := &InterfaceStatus{Peers: []PeerStatus{
+		{PublicKey: "laptop-pub", LatestHandshake: now.Add(-30 * time.Second), TransferRx: 10, TransferTx: 10},
+		{PublicKey: "stranger-pub", TransferRx: 1},
+	}}
+
+	for _, at := range []time.Time{now, now.Add(10 * time.Second), now.Add(2 * time.Minute)} {
+		if _, err := RecordQuality(profile, status, map[string]ProbeResult{"laptop": {RTT: 12 * time.Millisecond}}, at); err != nil {
+			t.Fatalf("RecordQuality: %v", err)
+		}
+	}
+	history, err := LoadQualityHistory("lab")
+	if err != nil {
+		t.Fatalf("LoadQualityHistory: %v", err)
+	}
+	samples := history.Clients["laptop"]
+	if len(samples) != 2 || len(history.Clients) != 1 {
+		t.Fatalf("expected two throttled samples for the known client only, got %+v", history.Clients)
+	}
+	if samples[0].HandshakeAge != 30 || !samples[0].Probed || samples[0].RTTMs != 12 {
+		t.Fatalf("unexpected sample %+v", samples[0])
+	}
+}
+
+func TestParsePingOutput(t *testing.T) {
+	output := `--- 10.0.0.2 ping statistics ---
+3 packets transmitted, 2 received, 33.3333% packet loss, time 2003ms
+rtt min/avg/max/mdev = 21.118/24.503/27.888/3.385 ms`
+	result, err := parsePingOutput(output)
+	if err != nil {
+		t.Fatalf("parsePingOutput: %v", err)
+	}
+	if result.RTT != 24503*time.Microsecond || result.Loss < 0.33 || result.Loss > 0.34 {
+		t.Fatalf("unexpected probe result %+v", result)
+	}
+	if _, err := parsePingOutput("ping: unknown host"); err == nil {
+		t.Fatalf("expected unrecognised output to fail")
+	}
+}
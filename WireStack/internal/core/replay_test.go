@@ -0,0 +1,157 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wirestack/internal/utils"
+)
+
+// replayFixture answers external commands from testdata/commands/<name>.json
+// until the returned function is called, which fails the test on any mismatch
+// or record that was not used.
+func replayFixture(t *testing.T, name, home string) func() {
+	t.Helper()
+	fixture, err := utils.LoadCommandFixture(filepath.Join("testdata", "commands", name+".json"), map[string]string{"HOME": home})
+	if err != nil {
+		t.Fatalf("load fixture %s: %v", name, err)
+	}
+	replay := utils.ReplayCommands(fixture)
+	return func() {
+		t.Helper()
+		if err := replay.Stop(); err != nil {
+			t.Fatalf("fixture %s: %v", name, err)
+		}
+	}
+}
+
+func TestReplayNativeBackendFullTunnel(t *testing.T) {
+	home := setupTempHome(t)
+	profile := DefaultServerProfile("lab", "203.0.113.1:51820", "server-priv", "server-pub")
+	client := ClientProfile{Name: "amy", PrivateKey: "amy-priv", PublicKey: "amy-pub", Address: "10.0.0.2/32", MTU: 1380, AllowedIPs: ClientAllowedIPs()}
+	profile.Clients = append(profile.Clients, client)
+	configPath, err := WriteClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("WriteClientConfig: %v", err)
+	}
+
+	done := replayFixture(t, "native_full_tunnel_up", home)
+	output, err := (nativeBackend{}).Up(configPath)
+	done()
+	if err != nil || output != "interface client-lab-amy up" {
+		t.Fatalf("Up returned %q, %v", output, err)
+	}
+
+	done = replayFixture(t, "native_full_tunnel_down", home)
+	output, err = (nativeBackend{}).Down(configPath)
+	done()
+	if err != nil || output != "interface client-lab-amy down" {
+		t.Fatalf("Down returned %q, %v", output, err)
+	}
+
+	done = replayFixture(t, "native_up_rollback", home)
+	_, err = (nativeBackend{}).Up(configPath)
+	done()
+	if err == nil || !strings.Contains(err.Error(), "Configuration parsing error") {
+		t.Fatalf("expected the wg setconf failure, got %v", err)
+	}
+}
+
+func TestReplayNativeBackendPostUpFailureDeletesLink(t *testing.T) {
+	home := setupTempHome(t)
+	configPath := filepath.Join(home, "wg-hook.conf")
+	config := "[Interface]\nPrivateKey = priv\nAddress = 10.0.0.2/32\nTable = off\nPostUp = iptables -A FORWARD -i %i -j ACCEPT # allow clients\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	done := replayFixture(t, "native_postup_rollback", home)
+	_, err := (nativeBackend{}).Up(configPath)
+	done()
+	if err == nil {
+		t.Fatalf("expected the PostUp failure")
+	}
+}
+
+func TestReplayDarwinBackendFullTunnel(t *testing.T) {
+	home := setupTempHome(t)
+	previous := wireguardRunDir
+	wireguardRunDir = filepath.Join(home, "run")
+	t.Cleanup(func() { wireguardRunDir = previous })
+	profile := DefaultServerProfile("lab", "203.0.113.1:51820", "server-priv", "server-pub")
+	client := ClientProfile{Name: "amy", PrivateKey: "amy-priv", PublicKey: "amy-pub", Address: "10.0.0.2/32", MTU: 1380, AllowedIPs: ClientAllowedIPs()}
+	profile.Clients = append(profile.Clients, client)
+	configPath, err := WriteClientConfig(profile, client)
+	if err != nil {
+		t.Fatalf("WriteClientConfig: %v", err)
+	}
+	// wireguard-go reports the utun device it created in the name file.
+	if err := utils.WriteFile(filepath.Join(wireguardRunDir, "client-lab-amy.name"), []byte("utun4\n"), 0o600); err != nil {
+		t.Fatalf("write name file: %v", err)
+	}
+
+	done := replayFixture(t, "darwin_full_tunnel_up", home)
+	output, err := (darwinBackend{}).Up(configPath)
+	done()
+	if err != nil || output != "interface client-lab-amy up (utun4)" {
+		t.Fatalf("Up returned %q, %v", output, err)
+	}
+
+	done = replayFixture(t, "darwin_full_tunnel_down", home)
+	output, err = (darwinBackend{}).Down(configPath)
+	done()
+	if err != nil || output != "interface client-lab-amy down" {
+		t.Fatalf("Down returned %q, %v", output, err)
+	}
+	if _, err := (darwinBackend{}).Down(configPath); err == nil || !strings.Contains(err.Error(), "is not up") {
+		t.Fatalf("expected Down without state to fail, got %v", err)
+	}
+
+	done = replayFixture(t, "darwin_up_rollback", home)
+	_, err = (darwinBackend{}).Up(configPath)
+	done()
+	if err == nil || !strings.Contains(err.Error(), "Configuration parsing error") {
+		t.Fatalf("expected the wg setconf failure, got %v", err)
+	}
+	if _, err := os.Stat(darwinStatePath(configPath)); !os.IsNotExist(err) {
+		t.Fatalf("expected the failed Up to remove its state, got %v", err)
+	}
+}
+
+func TestReplayExternalInterfaceSync(t *testing.T) {
+	home := setupTempHome(t)
+	done := replayFixture(t, "external_interface_sync", home)
+	defer done()
+
+	publicKey, err := InterfacePublicKey("wg-ext0")
+	if err != nil || publicKey != "server-pub" {
+		t.Fatalf("InterfacePublicKey returned %q, %v", publicKey, err)
+	}
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "", publicKey)
+	profile.ExternalInterface = "wg-ext0"
+	profile.Clients = []ClientProfile{
+		{Name: "amy", PublicKey: "amy-pub", Address: "10.0.0.2/32"},
+		{Name: "bob", PublicKey: "bob-pub", Address: "10.0.0.3/32", Disabled: true},
+	}
+	if err := ApplyLivePeers(profile); err != nil {
+		t.Fatalf("ApplyLivePeers: %v", err)
+	}
+
+	status, err := ReadInterfaceStatus(InterfaceName(profile))
+	if err != nil {
+		t.Fatalf("ReadInterfaceStatus: %v", err)
+	}
+	matched := MatchClients(profile, status)
+	if len(matched) != 2 || matched[0].ClientName != "amy" || matched[0].Peer.TransferRx != 5120 || matched[1].ClientName != "" {
+		t.Fatalf("unexpected matches %+v", matched)
+	}
+
+	if err := RemoveLivePeers(profile); err != nil {
+		t.Fatalf("RemoveLivePeers: %v", err)
+	}
+	if _, err := ReadInterfaceStatus("wg-ext0"); err == nil || !strings.Contains(err.Error(), "No such device") {
+		t.Fatalf("expected the interface to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentSchemaVersion is the profile format written by this build. Profiles
+// saved before schema_version existed are version 0.
+const CurrentSchemaVersion = 1
+
+// ProfileMigration upgrades a profile document from version From to From+1.
+// Apply edits the decoded JSON in place, so it can rename, split, or move
+// fields that the current ServerProfile struct would otherwise misparse.
+type ProfileMigration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]any) error
+}
+
+// profileMigrations holds one migration per schema version, in order. Adding a
+// format change means appending a migration and bumping CurrentSchemaVersion.
+var profileMigrations = []ProfileMigration{
+	{
+		From:        0,
+		Description: "record schema_version in the profile",
+		Apply:       func(doc map[string]any) error { return nil },
+	},
+}
+
+// PendingMigrations returns the migrations needed to bring a profile stored
+// at version up to CurrentSchemaVersion.
+func PendingMigrations(version int) []ProfileMigration {
+	if version >= len(profileMigrations) || version < 0 {
+		return nil
+	}
+	return profileMigrations[version:]
+}
+
+// decodeServerProfile parses stored profile JSON, upgrading older formats in
+// memory. The returned profile keeps the SchemaVersion it was stored with
+// until it is saved again.
+func decodeServerProfile(data []byte) (*ServerProfile, error) {
+	doc := map[string]any{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return decodeProfileDocument(doc)
+}
+
+// decodeProfileDocument migrates a decoded profile document and converts it to a ServerProfile.
+func decodeProfileDocument(doc map[string]any) (*ServerProfile, error) {
+	version, err := migrateProfileDocument(doc, profileMigrations)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var profile ServerProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	profile.SchemaVersion = version
+	return &profile, nil
+}
+
+// migrateProfileDocument applies migrations to doc and returns the version it
+// was stored with. Profiles from a newer build are rejected rather than
+// parsed with fields this build does not understand.
+func migrateProfileDocument(doc map[string]any, migrations []ProfileMigration) (int, error) {
+	version := 0
+	if raw, ok := doc["schema_version"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number < 0 || number != float64(int(number)) {
+			return 0, fmt.Errorf("invalid schema_version %v", raw)
+		}
+		version = int(number)
+	}
+	if version > len(migrations) {
+		return 0, fmt.Errorf("profile uses schema version %d, but this wirestack only supports up to %d; upgrade wirestack", version, len(migrations))
+	}
+	for _, migration := range migrations[version:] {
+		if err := migration.Apply(doc); err != nil {
+			return 0, fmt.Errorf("migrate profile from schema version %d: %w", migration.From, err)
+		}
+		doc["schema_version"] = migration.From + 1
+	}
+	return version, nil
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"wirestack/internal/utils"
+)
+
+func TestProfileMigrationsCoverEveryVersion(t *testing.T) {
+	if len(profileMigrations) != CurrentSchemaVersion {
+		t.Fatalf("have %d migrations for schema version %d", len(profileMigrations), CurrentSchemaVersion)
+	}
+	for idx, migration := range profileMigrations {
+		if migration.From != idx {
+			t.Fatalf("migration %d starts at version %d", idx, migration.From)
+		}
+	}
+}
+
+func TestMigrateProfileDocument(t *testing.T) {
+	migrations := []ProfileMigration{
+		{From: 0, Description: "noop", Apply: func(doc map[string]any) error { return nil }},
+		{From: 1, Description: "rename dns_servers", Apply: func(doc map[string]any) error {
+			doc["dns"] = doc["dns_servers"]
+			delete(doc, "dns_servers")
+			return nil
+		}},
+	}
+
+	doc := map[string]any{"name": "old", "dns_servers": []any{"1.1.1.1"}}
+	version, err := migrateProfileDocument(doc, migrations)
+	if err != nil {
+		t.Fatalf("migrateProfileDocument: %v", err)
+	}
+	if version != 0 || doc["schema_version"] != 2 || doc["dns"] == nil || doc["dns_servers"] != nil {
+		t.Fatalf("unexpected migration result %d %v", version, doc)
+	}
+
+	current := map[string]any{"schema_version": float64(2), "dns_servers": "kept"}
+	if _, err := migrateProfileDocument(current, migrations); err != nil || current["dns_servers"] != "kept" {
+		t.Fatalf("current profile should not be migrated: %v %v", err, current)
+	}
+
+	newer := map[string]any{"schema_version": float64(3)}
+	if _, err := migrateProfileDocument(newer, migrations); err == nil || !strings.Contains(err.Error(), "upgrade wirestack") {
+		t.Fatalf("expected newer schema to be rejected, got %v", err)
+	}
+}
+
+func TestLegacyProfileLoadsAndSavesCurrentVersion(t *testing.T) {
+	setupTempHome(t)
+
+	path, err := ServerProfilePath("legacy")
+	if err != nil {
+		t.Fatalf("ServerProfilePath: %v", err)
+	}
+	legacy := `{"name": "legacy", "endpoint": "203.0.113.1:51820", "address": "10.8.0.1/24", "dns": ["1.1.1.1"], "listen_port": 51820, "clients": []}`
+	if err := utils.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	profile, err := LoadServerProfile("legacy")
+	if err != nil {
+		t.Fatalf("LoadServerProfile: %v", err)
+	}
+	if profile.SchemaVersion != 0 || len(PendingMigrations(profile.SchemaVersion)) != CurrentSchemaVersion {
+		t.Fatalf("expected a version 0 profile, got %d", profile.SchemaVersion)
+	}
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	reloaded, err := LoadServerProfile("legacy")
+	if err != nil {
+		t.Fatalf("LoadServerProfile: %v", err)
+	}
+	if reloaded.SchemaVersion != CurrentSchemaVersion || len(PendingMigrations(reloaded.SchemaVersion)) != 0 {
+		t.Fatalf("expected current schema after save, got %d", reloaded.SchemaVersion)
+	}
+
+	future := `{"schema_version": 99, "name": "future"}`
+	futurePath, _ := ServerProfilePath("future")
+	if err := utils.WriteFile(futurePath, []byte(future), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := LoadServerProfile("future"); err == nil {
+		t.Fatalf("expected profile from a newer schema to be rejected")
+	}
+}
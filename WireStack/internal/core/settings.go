@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+const settingsFile = "config.json"
+
+// Settings holds installation-wide preferences stored in ~/.wirestack/config.json.
+type Settings struct {
+	// Store selects the profile store backend ("file" or "sqlite").
+	Store string `json:"store,omitempty"`
+	// StorePath overrides the database path used by the sqlite store.
+	StorePath string `json:"store_path,omitempty"`
+	// LintPlugins are executables run by validate and up; see RunLintPlugins.
+	LintPlugins []string `json:"lint_plugins,omitempty"`
+	// Features overrides feature flag defaults; see FeatureStates.
+	Features map[string]bool `json:"features,omitempty"`
+	// Serve configures wirestack serve; it is re-read when the daemon gets SIGHUP.
+	Serve *ServeSettings `json:"serve,omitempty"`
+	// Notify sends daemon events to a webhook; see NotifySettings.
+	Notify *NotifySettings `json:"notify,omitempty"`
+	// PublicIP orders the providers used to detect an "auto" endpoint.
+	PublicIP *PublicIPSettings `json:"public_ip,omitempty"`
+	// Permissions shares parts of ~/.wirestack with a group; see ApplyPermissionSettings.
+	Permissions *PermissionSettings `json:"permissions,omitempty"`
+	// CurrentServer is used for --server when a command is run without it.
+	CurrentServer string `json:"current_server,omitempty"`
+	// Aliases maps a name to the command line it stands for, as in
+	// "lc": "list-clients --output json".
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// ServeSettings holds daemon defaults. Command-line flags take precedence.
+type ServeSettings struct {
+	Listen      string `json:"listen,omitempty"`
+	BenchListen string `json:"bench_listen,omitempty"`
+	// EventInterval is a Go duration such as "5s".
+	EventInterval string `json:"event_interval,omitempty"`
+	// RequestTimeout is a Go duration such as "30s".
+	RequestTimeout string `json:"request_timeout,omitempty"`
+}
+
+// Interval parses EventInterval, returning zero when it is unset.
+func (s *ServeSettings) Interval() (time.Duration, error) {
+	if s == nil {
+		return 0, nil
+	}
+	return parsePositiveDuration("serve.event_interval", s.EventInterval)
+}
+
+// Timeout parses RequestTimeout, returning zero when it is unset.
+func (s *ServeSettings) Timeout() (time.Duration, error) {
+	if s == nil {
+		return 0, nil
+	}
+	return parsePositiveDuration("serve.request_timeout", s.RequestTimeout)
+}
+
+// parsePositiveDuration parses the setting key, returning zero when it is empty.
+func parsePositiveDuration(key, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return duration, nil
+}
+
+// SettingsPath returns the location of the global settings file.
+func SettingsPath() (string, error) {
+	root, err := ConfigRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, settingsFile), nil
+}
+
+// LoadSettings reads the global settings, returning defaults when the file is absent.
+func LoadSettings() (*Settings, error) {
+	path, err := SettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	var settings Settings
+	if err := utils.ReadJSON(path, &settings); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Settings{}, nil
+		}
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveSettings writes the global settings file.
+func SaveSettings(settings *Settings) error {
+	path, err := SettingsPath()
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, settings, 0o600)
+}
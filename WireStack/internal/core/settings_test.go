@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServeSettingsRoundTrip(t *testing.T) {
+	setupTempHome(t)
+	settings := &Settings{Serve: &ServeSettings{Listen: "127.0.0.1:9090", EventInterval: "2s"}}
+	if err := SaveSettings(settings); err != nil {
+		t.Fatalf("SaveSettings: %v", err)
+	}
+	loaded, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	if loaded.Serve == nil || loaded.Serve.Listen != "127.0.0.1:9090" {
+		t.Fatalf("serve settings not persisted: %+v", loaded.Serve)
+	}
+	interval, err := loaded.Serve.Interval()
+	if err != nil || interval != 2*time.Second {
+		t.Fatalf("expected a 2s interval, got %v (%v)", interval, err)
+	}
+
+	var unset *ServeSettings
+	if interval, err := unset.Interval(); err != nil || interval != 0 {
+		t.Fatalf("expected no interval without serve settings, got %v (%v)", interval, err)
+	}
+	for _, bad := range []string{"soon", "-1s"} {
+		if _, err := (&ServeSettings{EventInterval: bad}).Interval(); err == nil {
+			t.Fatalf("expected event_interval %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+
+	"wirestack/internal/utils"
+)
+
+// Places a server's WireGuard state is compared in.
+const (
+	StateRuntimeConfig = "runtime-config"
+	StateInterface     = "interface"
+)
+
+// StateComparison is how one place holding a server's WireGuard state differs
+// from the saved profile, in the interface key and listen port and in each
+// peer's key and AllowedIPs.
+type StateComparison struct {
+	Source string
+	// Location is the runtime config path or the interface name.
+	Location string
+	// Missing is set when there is nothing to compare, such as a runtime
+	// config that was never rendered or an interface that is down.
+	Missing bool
+	Diff    *ConfigDiff
+}
+
+// CompareServerState compares the profile with its last-rendered runtime
+// config and with its running interface. An external interface has no
+// runtime config, so only the interface is compared.
+func CompareServerState(profile *ServerProfile) ([]StateComparison, error) {
+	want, err := ProfileState(profile)
+	if err != nil {
+		return nil, err
+	}
+	names := stateKeyNames(profile)
+
+	var comparisons []StateComparison
+	if profile.ExternalInterface == "" {
+		path, err := ServerRuntimeConfigPath(profile.Name)
+		if err != nil {
+			return nil, err
+		}
+		comparison := StateComparison{Source: StateRuntimeConfig, Location: path}
+		data, err := utils.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			comparison.Missing = true
+		case err != nil:
+			return nil, err
+		default:
+			got, err := RuntimeConfigState(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			comparison.Diff = DiffConfigs(want, got, names)
+		}
+		comparisons = append(comparisons, comparison)
+	}
+
+	iface := InterfaceName(profile)
+	comparison := StateComparison{Source: StateInterface, Location: iface}
+	if !InterfaceIsUp(iface) {
+		comparison.Missing = true
+	} else {
+		status, err := ReadInterfaceStatus(iface)
+		if err != nil {
+			return nil, err
+		}
+		comparison.Diff = DiffConfigs(want, InterfaceState(status), names)
+	}
+	return append(comparisons, comparison), nil
+}
+
+// ProfileState reduces what the profile renders to the compared settings.
+// The listen port of an external interface is not managed, so it is left out.
+func ProfileState(profile *ServerProfile) (*WGConfig, error) {
+	state := &WGConfig{Interface: ConfigSection{Name: "Interface"}}
+	state.Interface.Entries = append(state.Interface.Entries, ConfigEntry{Key: "PublicKey", Value: profile.ServerPublicKey})
+	if profile.ExternalInterface == "" {
+		_, port, err := net.SplitHostPort(profile.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
+		}
+		state.Interface.Entries = append(state.Interface.Entries, ConfigEntry{Key: "ListenPort", Value: port})
+	}
+	for _, client := range profile.Clients {
+		if client.Disabled || client.PublicKey == "" {
+			continue
+		}
+		state.Peers = append(state.Peers, statePeer(client.PublicKey, serverPeerAllowedIPs(client)))
+	}
+	return state, nil
+}
+
+// RuntimeConfigState reduces a rendered server config to the compared
+// settings, deriving the interface public key from its private key.
+func RuntimeConfigState(text string) (*WGConfig, error) {
+	config, err := ParseConfig(text)
+	if err != nil {
+		return nil, err
+	}
+	state := &WGConfig{Interface: ConfigSection{Name: "Interface"}}
+	if private := config.Interface.Get("PrivateKey"); private != "" {
+		public, err := publicKeyFromPrivate(private)
+		if err != nil {
+			return nil, err
+		}
+		state.Interface.Entries = append(state.Interface.Entries, ConfigEntry{Key: "PublicKey", Value: public})
+	}
+	if port := config.Interface.Get("ListenPort"); port != "" {
+		state.Interface.Entries = append(state.Interface.Entries, ConfigEntry{Key: "ListenPort", Value: port})
+	}
+	for _, peer := range config.Peers {
+		state.Peers = append(state.Peers, statePeer(peer.Get("PublicKey"), peer.List("AllowedIPs")))
+	}
+	return state, nil
+}
+
+// InterfaceState reduces the running interface to the compared settings.
+func InterfaceState(status *InterfaceStatus) *WGConfig {
+	state := &WGConfig{Interface: ConfigSection{Name: "Interface", Entries: []ConfigEntry{
+		{Key: "PublicKey", Value: status.PublicKey},
+		{Key: "ListenPort", Value: strconv.Itoa(status.ListenPort)},
+	}}}
+	for _, peer := range status.Peers {
+		state.Peers = append(state.Peers, statePeer(peer.PublicKey, peer.AllowedIPs))
+	}
+	return state
+}
+
+// statePeer builds the compared part of one peer.
+func statePeer(key string, allowed []string) ConfigSection {
+	return ConfigSection{Name: "Peer", Entries: []ConfigEntry{
+		{Key: "PublicKey", Value: key},
+		{Key: "AllowedIPs", Value: strings.Join(allowed, ", ")},
+	}}
+}
+
+// stateKeyNames labels the server key and every current or retired client key.
+func stateKeyNames(profile *ServerProfile) map[string]string {
+	names := map[string]string{profile.ServerPublicKey: profile.Name}
+	for _, client := range profile.Clients {
+		for _, retired := range client.KeyHistory {
+			names[retired.PublicKey] = client.Name + " (retired key)"
+		}
+	}
+	for _, client := range profile.Clients {
+		names[client.PublicKey] = client.Name
+	}
+	return names
+}
+
+// publicKeyFromPrivate derives a WireGuard public key, as wg pubkey does.
+func publicKeyFromPrivate(private string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(private)
+	if err != nil || len(raw) != curve25519.ScalarSize {
+		return "", fmt.Errorf("invalid private key")
+	}
+	public, err := curve25519.X25519(raw, curve25519.Basepoint)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(public), nil
+}
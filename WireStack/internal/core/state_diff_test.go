@@ -0,0 +1,82 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompareServerState(t *testing.T) {
+	setupTempHome(t)
+	fakeWG(t)
+	private, public, err := generateKeyPairNative()
+	if err != nil {
+		t.Fatalf("generateKeyPairNative: %v", err)
+	}
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", private, public)
+	profile.Clients = []ClientProfile{
+		{Name: "alice", PublicKey: "alice-pub", Address: "10.0.0.2/32"},
+		{Name: "bob", PublicKey: "bob-pub", Address: "10.0.0.3/32"},
+	}
+
+	comparisons, err := CompareServerState(profile)
+	if err != nil {
+		t.Fatalf("CompareServerState: %v", err)
+	}
+	if len(comparisons) != 2 || !comparisons[0].Missing || !comparisons[1].Missing {
+		t.Fatalf("expected no runtime config and no interface, got %+v", comparisons)
+	}
+
+	path, err := WriteServerConfig(profile)
+	if err != nil {
+		t.Fatalf("WriteServerConfig: %v", err)
+	}
+	if comparisons, err = CompareServerState(profile); err != nil || !comparisons[0].Diff.Empty() {
+		t.Fatalf("expected the fresh runtime config to match, got %+v, %v", comparisons, err)
+	}
+
+	// A profile change not rendered yet: bob moved and carol is new.
+	profile.Clients[1].Address = "10.0.0.9/32"
+	profile.Clients = append(profile.Clients, ClientProfile{Name: "carol", PublicKey: "carol-pub", Address: "10.0.0.4/32"})
+	profile.Endpoint = "203.0.113.1:51821"
+	comparisons, err = CompareServerState(profile)
+	if err != nil {
+		t.Fatalf("CompareServerState: %v", err)
+	}
+	diff := comparisons[0].Diff
+	if len(diff.Interface) != 1 || diff.Interface[0].Key != "ListenPort" || diff.Interface[0].From != "51821" {
+		t.Fatalf("expected the listen port to differ, got %+v", diff.Interface)
+	}
+	if len(diff.RemovedPeers) != 1 || diff.RemovedPeers[0].Name != "carol" || len(diff.ChangedPeers) != 1 || diff.ChangedPeers[0].Name != "bob" {
+		t.Fatalf("expected carol missing and bob changed, got %+v", diff)
+	}
+	if err := os.WriteFile(path, []byte("[Interface]\nPrivateKey = not-a-key\n"), 0o600); err != nil {
+		t.Fatalf("write runtime config: %v", err)
+	}
+	if _, err := CompareServerState(profile); err == nil || !strings.Contains(err.Error(), "invalid private key") {
+		t.Fatalf("expected the bad key reported, got %v", err)
+	}
+}
+
+func TestInterfaceStateDiff(t *testing.T) {
+	profile := DefaultServerProfile("edge", "203.0.113.1:51820", "", "server-pub")
+	profile.Clients = []ClientProfile{
+		{Name: "alice", PublicKey: "alice-pub", Address: "10.0.0.2/32", KeyHistory: []RetiredKey{{PublicKey: "alice-old"}}},
+	}
+	status, err := ParseWGDump("priv\tother-pub\t51820\toff\n" +
+		"alice-old\t(none)\t(none)\t10.0.0.2/32\t0\t0\t0\toff\n")
+	if err != nil {
+		t.Fatalf("ParseWGDump: %v", err)
+	}
+	want, err := ProfileState(profile)
+	if err != nil {
+		t.Fatalf("ProfileState: %v", err)
+	}
+	diff := DiffConfigs(want, InterfaceState(status), stateKeyNames(profile))
+	if len(diff.Interface) != 1 || diff.Interface[0].Key != "PublicKey" || diff.Interface[0].To != "other-pub" {
+		t.Fatalf("expected the server key to differ, got %+v", diff.Interface)
+	}
+	if len(diff.AddedPeers) != 1 || diff.AddedPeers[0].Name != "alice (retired key)" || len(diff.RemovedPeers) != 1 {
+		t.Fatalf("expected the retired key still running, got %+v", diff)
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// PeerTraffic is a peer's transfer totals and the rate since the previous sample.
+type PeerTraffic struct {
+	// Client is empty for peers that match no stored client.
+	Client    string
+	PublicKey string
+	RxBytes   int64
+	TxBytes   int64
+	// RxRate and TxRate are in bytes per second; zero on the first sample.
+	RxRate float64
+	TxRate float64
+}
+
+// Throughput is the combined receive and transmit rate.
+func (p PeerTraffic) Throughput() float64 {
+	return p.RxRate + p.TxRate
+}
+
+// ComputeTraffic derives per-peer rates from two `wg show` samples taken
+// elapsed apart. previous may be nil for the first sample. Peers that are new
+// or whose counters went backwards (the interface restarted) get a zero rate.
+// Peers are sorted by throughput, busiest first, then by client name.
+func ComputeTraffic(profile *ServerProfile, previous, current *InterfaceStatus, elapsed time.Duration) []PeerTraffic {
+	before := map[string]PeerStatus{}
+	if previous != nil {
+		for _, peer := range previous.Peers {
+			before[peer.PublicKey] = peer
+		}
+	}
+	seconds := elapsed.Seconds()
+
+	traffic := make([]PeerTraffic, 0, len(current.Peers))
+	for _, entry := range MatchClients(profile, current) {
+		peer := PeerTraffic{
+			Client:    entry.ClientName,
+			PublicKey: entry.Peer.PublicKey,
+			RxBytes:   entry.Peer.TransferRx,
+			TxBytes:   entry.Peer.TransferTx,
+		}
+		if last, ok := before[peer.PublicKey]; ok && seconds > 0 {
+			rx, tx := peer.RxBytes-last.TransferRx, peer.TxBytes-last.TransferTx
+			if rx >= 0 && tx >= 0 {
+				peer.RxRate, peer.TxRate = float64(rx)/seconds, float64(tx)/seconds
+			}
+		}
+		traffic = append(traffic, peer)
+	}
+	sort.SliceStable(traffic, func(i, j int) bool {
+		if traffic[i].Throughput() != traffic[j].Throughput() {
+			return traffic[i].Throughput() > traffic[j].Throughput()
+		}
+		if (traffic[i].Client == "") != (traffic[j].Client == "") {
+			return traffic[j].Client == ""
+		}
+		return traffic[i].Client < traffic[j].Client
+	})
+	return traffic
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeTraffic(t *testing.T) {
+	profile := &ServerProfile{Clients: []ClientProfile{
+		{Name: "laptop", PublicKey: "laptop-pub"},
+		{Name: "phone", PublicKey: "phone-pub"},
+		{Name: "tablet", PublicKey: "tablet-pub"},
+	}}
+	previous := &InterfaceStatus{Peers: []PeerStatus{
+		{PublicKey: "laptop-pub", TransferRx: 1000, TransferTx: 1000},
+		{PublicKey: "phone-pub", TransferRx: 0, TransferTx: 0},
+		{PublicKey: "tablet-pub", TransferRx: 5000, TransferTx: 5000},
+	}}
+	current := &InterfaceStatus{Peers: []PeerStatus{
+		{PublicKey: "laptop-pub", TransferRx: 3000, TransferTx: 1000},
+		{PublicKey: "phone-pub", TransferRx: 8000, TransferTx: 4000},
+		// Counters reset, e.g. after the interface restarted.
+		{PublicKey: "tablet-pub", TransferRx: 10, TransferTx: 10},
+		{PublicKey: "stranger-pub", TransferRx: 100, TransferTx: 100},
+	}}
+
+	traffic := ComputeTraffic(profile, previous, current, 2*time.Second)
+	var order []string
+	for _, peer := range traffic {
+		order = append(order, peer.Client)
+	}
+	if len(order) != 4 || order[0] != "phone" || order[1] != "laptop" || order[2] != "tablet" || order[3] != "" {
+		t.Fatalf("unexpected order %q", order)
+	}
+	if traffic[0].RxRate != 4000 || traffic[0].TxRate != 2000 {
+		t.Fatalf("unexpected phone rates %+v", traffic[0])
+	}
+	if traffic[1].RxRate != 1000 || traffic[1].TxRate != 0 {
+		t.Fatalf("unexpected laptop rates %+v", traffic[1])
+	}
+	if traffic[2].Throughput() != 0 || traffic[2].RxBytes != 10 {
+		t.Fatalf("expected a counter reset to give a zero rate, got %+v", traffic[2])
+	}
+
+	for _, peer := range ComputeTraffic(profile, nil, current, 0) {
+		if peer.Throughput() != 0 {
+			t.Fatalf("expected zero rates without a previous sample, got %+v", peer)
+		}
+	}
+}
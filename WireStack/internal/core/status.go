@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wirestack/internal/utils"
+)
+
+// PeerStatus is the runtime state of a single peer as reported by `wg show dump`.
+type PeerStatus struct {
+	PublicKey           string
+	Endpoint            string
+	AllowedIPs          []string
+	LatestHandshake     time.Time
+	TransferRx          int64
+	TransferTx          int64
+	PersistentKeepalive int
+}
+
+// InterfaceStatus is the runtime state of a WireGuard interface and its peers.
+type InterfaceStatus struct {
+	Name       string
+	PublicKey  string
+	ListenPort int
+	Peers      []PeerStatus
+}
+
+// ClientStatus pairs a running peer with the stored client it belongs to.
+// ClientName is empty for peers that are not part of the profile.
+type ClientStatus struct {
+	ClientName string
+	Peer       PeerStatus
+}
+
+// ReadInterfaceStatus runs `wg show <iface> dump` and parses the result.
+func ReadInterfaceStatus(iface string) (*InterfaceStatus, error) {
+	return ReadInterfaceStatusContext(context.Background(), iface)
+}
+
+// ReadInterfaceStatusContext is ReadInterfaceStatus stopping wg when ctx is done.
+func ReadInterfaceStatusContext(ctx context.Context, iface string) (*InterfaceStatus, error) {
+	output, err := utils.RunCommandContext(ctx, "wg", "show", iface, "dump")
+	if err != nil {
+		return nil, err
+	}
+	status, err := ParseWGDump(output)
+	if err != nil {
+		return nil, err
+	}
+	status.Name = iface
+	return status, nil
+}
+
+// ParseWGDump parses the tab separated output of `wg show <iface> dump`.
+func ParseWGDump(output string) (*InterfaceStatus, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, fmt.Errorf("empty wg dump output")
+	}
+
+	header := strings.Split(lines[0], "\t")
+	if len(header) < 3 {
+		return nil, fmt.Errorf("malformed wg dump interface line: %q", lines[0])
+	}
+	status := &InterfaceStatus{PublicKey: header[1]}
+	if port, err := strconv.Atoi(header[2]); err == nil {
+		status.ListenPort = port
+	}
+
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("malformed wg dump peer line: %q", line)
+		}
+		peer := PeerStatus{
+			PublicKey: fields[0],
+			Endpoint:  noneToEmpty(fields[2]),
+		}
+		if allowed := noneToEmpty(fields[3]); allowed != "" {
+			peer.AllowedIPs = strings.Split(allowed, ",")
+		}
+		if seconds, err := strconv.ParseInt(fields[4], 10, 64); err == nil && seconds > 0 {
+			peer.LatestHandshake = time.Unix(seconds, 0)
+		}
+		peer.TransferRx, _ = strconv.ParseInt(fields[5], 10, 64)
+		peer.TransferTx, _ = strconv.ParseInt(fields[6], 10, 64)
+		if keepalive, err := strconv.Atoi(fields[7]); err == nil {
+			peer.PersistentKeepalive = keepalive
+		}
+		status.Peers = append(status.Peers, peer)
+	}
+	return status, nil
+}
+
+// MatchClients maps running peers back to stored clients by public key. Stored
+// clients without a running peer are omitted; unknown peers have no ClientName.
+func MatchClients(profile *ServerProfile, status *InterfaceStatus) []ClientStatus {
+	names := make(map[string]string, len(profile.Clients))
+	for _, client := range profile.Clients {
+		names[client.PublicKey] = client.Name
+	}
+	matched := make([]ClientStatus, 0, len(status.Peers))
+	for _, peer := range status.Peers {
+		matched = append(matched, ClientStatus{ClientName: names[peer.PublicKey], Peer: peer})
+	}
+	return matched
+}
+
+// noneToEmpty converts wg's "(none)" placeholder into an empty string.
+func noneToEmpty(value string) string {
+	if value == "(none)" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,35 @@
+package core
+
+import "testing"
+
+func TestParseWGDumpAndMatchClients(t *testing.T) {
+	dump := "priv\tsrv-pub\t51820\toff\n" +
+		"alice-pub\t(none)\t198.51.100.7:40000\t10.0.0.2/32\t1700000000\t1024\t2048\t25\n" +
+		"stranger-pub\t(none)\t(none)\t10.0.0.9/32\t0\t0\t0\toff\n"
+
+	status, err := ParseWGDump(dump)
+	if err != nil {
+		t.Fatalf("ParseWGDump: %v", err)
+	}
+	if status.PublicKey != "srv-pub" || status.ListenPort != 51820 || len(status.Peers) != 2 {
+		t.Fatalf("unexpected interface status: %+v", status)
+	}
+	alice := status.Peers[0]
+	if alice.Endpoint != "198.51.100.7:40000" || alice.TransferRx != 1024 || alice.TransferTx != 2048 || alice.LatestHandshake.Unix() != 1700000000 {
+		t.Fatalf("unexpected peer status: %+v", alice)
+	}
+	if !status.Peers[1].LatestHandshake.IsZero() || status.Peers[1].Endpoint != "" {
+		t.Fatalf("expected empty handshake and endpoint for idle peer: %+v", status.Peers[1])
+	}
+
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", "priv", "srv-pub")
+	profile.Clients = []ClientProfile{{Name: "alice", PublicKey: "alice-pub"}}
+	matched := MatchClients(profile, status)
+	if len(matched) != 2 || matched[0].ClientName != "alice" || matched[1].ClientName != "" {
+		t.Fatalf("unexpected client matching: %+v", matched)
+	}
+
+	if _, err := ParseWGDump(""); err == nil {
+		t.Fatalf("expected error for empty dump")
+	}
+}
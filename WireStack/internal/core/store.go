@@ -0,0 +1,177 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"wirestack/internal/utils"
+)
+
+// ErrProfileNotFound is returned (wrapped) by stores when a profile does not exist.
+var ErrProfileNotFound = errors.New("server profile not found")
+
+// Store persists server profiles. Implementations must return an error wrapping
+// ErrProfileNotFound from Load and Delete when the profile does not exist.
+type Store interface {
+	Load(name string) (*ServerProfile, error)
+	Save(profile *ServerProfile) error
+	List() ([]string, error)
+	Delete(name string) error
+}
+
+var (
+	storeMu      sync.RWMutex
+	currentStore Store = FileStore{}
+)
+
+// SetStore replaces the store used by the package level profile functions.
+func SetStore(store Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	currentStore = store
+}
+
+// CurrentStore returns the store used by the package level profile functions.
+func CurrentStore() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return currentStore
+}
+
+// FileStore keeps one JSON file per server under ~/.wirestack/servers.
+type FileStore struct{}
+
+// Load reads a server profile from disk.
+func (FileStore) Load(name string) (*ServerProfile, error) {
+	path, err := ServerProfilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("server %s: %w", name, ErrProfileNotFound)
+		}
+		return nil, err
+	}
+	profile, err := decodeServerProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server profile %s: %w", path, err)
+	}
+	return profile, nil
+}
+
+// Save writes the server profile JSON to disk with restrictive permissions.
+func (FileStore) Save(profile *ServerProfile) error {
+	path, err := ServerProfilePath(profile.Name)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, profile, 0o600)
+}
+
+// List returns the names of all stored server profiles.
+func (FileStore) List() ([]string, error) {
+	root, err := ServersRoot()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read servers directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return names, nil
+}
+
+// Delete removes the stored server profile JSON.
+func (FileStore) Delete(name string) error {
+	path, err := ServerProfilePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("server %s: %w", name, ErrProfileNotFound)
+		}
+		return fmt.Errorf("failed to delete server profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// MemoryStore keeps profiles in memory, for tests and throwaway sessions.
+type MemoryStore struct {
+	mu       sync.Mutex
+	profiles map[string][]byte
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{profiles: map[string][]byte{}}
+}
+
+// Load returns a copy of the stored profile.
+func (m *MemoryStore) Load(name string) (*ServerProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("server %s: %w", name, ErrProfileNotFound)
+	}
+	profile, err := decodeServerProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode server profile %s: %w", name, err)
+	}
+	return profile, nil
+}
+
+// Save stores a copy of the profile.
+func (m *MemoryStore) Save(profile *ServerProfile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to encode server profile %s: %w", profile.Name, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.profiles[profile.Name] = data
+	return nil
+}
+
+// List returns the stored profile names in sorted order.
+func (m *MemoryStore) List() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.profiles))
+	for name := range m.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete removes a stored profile.
+func (m *MemoryStore) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.profiles[name]; !ok {
+		return fmt.Errorf("server %s: %w", name, ErrProfileNotFound)
+	}
+	delete(m.profiles, name)
+	return nil
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	// StoreFile selects the one-JSON-file-per-server store.
+	StoreFile = "file"
+	// StoreSQLite selects the single-file SQLite store.
+	StoreSQLite = "sqlite"
+
+	defaultSQLiteFile = "wirestack.db"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS servers (
+	name    TEXT PRIMARY KEY,
+	profile TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS clients (
+	server     TEXT NOT NULL REFERENCES servers(name) ON DELETE CASCADE,
+	name       TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	public_key TEXT NOT NULL,
+	address    TEXT NOT NULL,
+	address6   TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	PRIMARY KEY (server, name)
+);
+CREATE TABLE IF NOT EXISTS allocations (
+	server  TEXT NOT NULL REFERENCES servers(name) ON DELETE CASCADE,
+	address TEXT NOT NULL,
+	owner   TEXT NOT NULL,
+	PRIMARY KEY (server, address)
+);
+CREATE INDEX IF NOT EXISTS clients_public_key ON clients(public_key);
+`
+
+// ClientRecord identifies a client together with the server it belongs to.
+type ClientRecord struct {
+	Server string
+	Client ClientProfile
+}
+
+// ClientQuerier is implemented by stores that can list clients across servers
+// without loading every profile.
+type ClientQuerier interface {
+	AllClients() ([]ClientRecord, error)
+}
+
+// OpenStore returns the store registered under kind, using settings for defaults.
+func OpenStore(kind string, settings *Settings) (Store, error) {
+	switch kind {
+	case "", StoreFile:
+		return FileStore{}, nil
+	case StoreSQLite:
+		path := settings.StorePath
+		if path == "" {
+			root, err := ConfigRoot()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(root, defaultSQLiteFile)
+		}
+		return NewSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store %q (want %s or %s)", kind, StoreFile, StoreSQLite)
+	}
+}
+
+// AllClients lists every client of every server, using the store's own query when available.
+func AllClients() ([]ClientRecord, error) {
+	if querier, ok := CurrentStore().(ClientQuerier); ok {
+		return querier.AllClients()
+	}
+	names, err := ListServerProfiles()
+	if err != nil {
+		return nil, err
+	}
+	var records []ClientRecord
+	for _, name := range names {
+		profile, err := LoadServerProfile(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, client := range profile.Clients {
+			records = append(records, ClientRecord{Server: name, Client: client})
+		}
+	}
+	return records, nil
+}
+
+// sqlStatement is a query with its arguments, executed in order inside a transaction.
+type sqlStatement struct {
+	query string
+	args  []any
+}
+
+// SQLiteStore keeps servers, clients, and address allocations in one database file.
+// Every Save runs in a transaction, and SQLite's locking serializes concurrent writers.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (and if needed creates) the database at path.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	// Create the file up front so it (and SQLite's -wal/-shm side files, which copy
+	// its mode) never become readable by other users: the database holds private keys.
+	if err := utils.EnsureDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqlite store %s: %w", path, err)
+	}
+	file.Close()
+	if _, gid := utils.PolicyMode(path, 0o600, false); gid >= 0 {
+		if err := utils.ApplyPermissionPolicy(path, 0o600, false); err != nil {
+			return nil, fmt.Errorf("failed to set permissions of sqlite store %s: %w", path, err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite store %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize sqlite store %s: %w", path, err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close releases the database handle.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Load assembles a profile from its server row and client rows.
+func (s *SQLiteStore) Load(name string) (*ServerProfile, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT profile FROM servers WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("server %s: %w", name, ErrProfileNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server %s: %w", name, err)
+	}
+	doc := map[string]any{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode server %s: %w", name, err)
+	}
+
+	rows, err := s.db.Query(`SELECT data FROM clients WHERE server = ? ORDER BY position`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load clients of %s: %w", name, err)
+	}
+	defer rows.Close()
+	// Clients are reassembled into the profile document so migrations see the
+	// same shape as a file-store profile.
+	clients := []any{}
+	for rows.Next() {
+		var clientData string
+		if err := rows.Scan(&clientData); err != nil {
+			return nil, fmt.Errorf("failed to read client row: %w", err)
+		}
+		var client any
+		if err := json.Unmarshal([]byte(clientData), &client); err != nil {
+			return nil, fmt.Errorf("failed to decode client of %s: %w", name, err)
+		}
+		clients = append(clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load clients of %s: %w", name, err)
+	}
+	doc["clients"] = clients
+
+	profile, err := decodeProfileDocument(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode server %s: %w", name, err)
+	}
+	return profile, nil
+}
+
+// Save replaces the server row, its clients, and its allocations in one transaction.
+func (s *SQLiteStore) Save(profile *ServerProfile) error {
+	server := *profile
+	server.Clients = nil
+	data, err := json.Marshal(server)
+	if err != nil {
+		return fmt.Errorf("failed to encode server %s: %w", profile.Name, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save server %s: %w", profile.Name, err)
+	}
+	defer tx.Rollback()
+
+	statements := []sqlStatement{
+		{`INSERT INTO servers (name, profile) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET profile = excluded.profile`, []any{profile.Name, string(data)}},
+		{`DELETE FROM clients WHERE server = ?`, []any{profile.Name}},
+		{`DELETE FROM allocations WHERE server = ?`, []any{profile.Name}},
+	}
+	for _, address := range ServerAddresses(profile) {
+		if ip := parseAddress(address); ip != nil {
+			statements = append(statements, sqlStatement{`INSERT INTO allocations (server, address, owner) VALUES (?, ?, '')`, []any{profile.Name, ip.String()}})
+		}
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+			return fmt.Errorf("failed to save server %s: %w", profile.Name, err)
+		}
+	}
+
+	for position, client := range profile.Clients {
+		clientData, err := json.Marshal(client)
+		if err != nil {
+			return fmt.Errorf("failed to encode client %s: %w", client.Name, err)
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO clients (server, name, position, public_key, address, address6, data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			profile.Name, client.Name, position, client.PublicKey, client.Address, client.Address6, string(clientData),
+		); err != nil {
+			return fmt.Errorf("failed to save client %s: %w", client.Name, err)
+		}
+		for _, address := range ClientAddresses(client) {
+			ip := parseAddress(address)
+			if ip == nil {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT INTO allocations (server, address, owner) VALUES (?, ?, ?)`, profile.Name, ip.String(), client.Name); err != nil {
+				return fmt.Errorf("address %s of client %s is already allocated: %w", ip, client.Name, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save server %s: %w", profile.Name, err)
+	}
+	return nil
+}
+
+// List returns server names in sorted order.
+func (s *SQLiteStore) List() ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM servers ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %w", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to list servers: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Delete removes a server; its clients and allocations cascade.
+func (s *SQLiteStore) Delete(name string) error {
+	result, err := s.db.Exec(`DELETE FROM servers WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete server %s: %w", name, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("server %s: %w", name, ErrProfileNotFound)
+	}
+	return nil
+}
+
+// AllClients returns every client across servers with a single query.
+func (s *SQLiteStore) AllClients() ([]ClientRecord, error) {
+	rows, err := s.db.Query(`SELECT server, data FROM clients ORDER BY server, position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clients: %w", err)
+	}
+	defer rows.Close()
+	var records []ClientRecord
+	for rows.Next() {
+		var server, data string
+		if err := rows.Scan(&server, &data); err != nil {
+			return nil, fmt.Errorf("failed to list clients: %w", err)
+		}
+		var client ClientProfile
+		if err := json.Unmarshal([]byte(data), &client); err != nil {
+			return nil, fmt.Errorf("failed to decode client of %s: %w", server, err)
+		}
+		records = append(records, ClientRecord{Server: server, Client: client})
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	if err := expectFilePerm(path, 0o600); err != nil {
+		t.Fatalf("database perms: %v", err)
+	}
+
+	profile := DefaultServerProfile("lab", "203.0.113.1:51820", "priv", "pub")
+	profile.Clients = []ClientProfile{
+		{Name: "zed", PublicKey: "zed-pub", Address: "10.0.0.2/32", Tags: []string{"staff"}},
+		{Name: "amy", PublicKey: "amy-pub", Address: "10.0.0.3/32"},
+	}
+	if err := store.Save(profile); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	other := DefaultServerProfile("edge", "203.0.113.2:51820", "priv2", "pub2")
+	other.Clients = []ClientProfile{{Name: "bob", PublicKey: "bob-pub", Address: "10.0.0.2/32"}}
+	if err := store.Save(other); err != nil {
+		t.Fatalf("Save other: %v", err)
+	}
+
+	loaded, err := store.Load("lab")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded.Clients) != 2 || loaded.Clients[0].Name != "zed" || loaded.Clients[0].Tags[0] != "staff" {
+		t.Fatalf("clients not preserved in order: %+v", loaded.Clients)
+	}
+
+	records, err := store.AllClients()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("AllClients: %v %+v", err, records)
+	}
+
+	profile.Clients = append(profile.Clients, ClientProfile{Name: "dup", PublicKey: "dup-pub", Address: "10.0.0.3/32"})
+	if err := store.Save(profile); err == nil {
+		t.Fatalf("expected duplicate allocation to be rejected")
+	}
+	reloaded, err := store.Load("lab")
+	if err != nil || len(reloaded.Clients) != 2 {
+		t.Fatalf("failed save should roll back, got %+v (%v)", reloaded, err)
+	}
+
+	if err := store.Delete("lab"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Load("lab"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	if err := store.Delete("lab"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound on second delete, got %v", err)
+	}
+	records, err = store.AllClients()
+	if err != nil || len(records) != 1 || records[0].Server != "edge" {
+		t.Fatalf("clients of deleted server should cascade: %+v (%v)", records, err)
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func useMemoryStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore()
+	previous := CurrentStore()
+	SetStore(store)
+	t.Cleanup(func() { SetStore(previous) })
+	return store
+}
+
+func TestProfileFunctionsUseCurrentStore(t *testing.T) {
+	setupTempHome(t)
+	useMemoryStore(t)
+
+	profile := DefaultServerProfile("mem", "203.0.113.1:51820", "priv", "pub")
+	if err := SaveServerProfile(profile); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+	profile.Endpoint = "mutated:1"
+
+	loaded, err := LoadServerProfile("mem")
+	if err != nil {
+		t.Fatalf("LoadServerProfile: %v", err)
+	}
+	if loaded.Endpoint != "203.0.113.1:51820" {
+		t.Fatalf("memory store should hold a copy, got endpoint %s", loaded.Endpoint)
+	}
+
+	names, err := ListServerProfiles()
+	if err != nil || len(names) != 1 || names[0] != "mem" {
+		t.Fatalf("unexpected names %v (%v)", names, err)
+	}
+
+	if err := DeleteServerProfile("mem"); err != nil {
+		t.Fatalf("DeleteServerProfile: %v", err)
+	}
+	exists, err := ProfileExists("mem")
+	if err != nil || exists {
+		t.Fatalf("expected profile to be gone, exists=%v err=%v", exists, err)
+	}
+	if _, err := LoadServerProfile("mem"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestFileStoreReportsMissingProfiles(t *testing.T) {
+	setupTempHome(t)
+
+	if _, err := (FileStore{}).Load("missing"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound from Load, got %v", err)
+	}
+	if err := (FileStore{}).Delete("missing"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound from Delete, got %v", err)
+	}
+}
+
+func TestLockServerProfileSerializesUpdates(t *testing.T) {
+	setupTempHome(t)
+	if err := SaveServerProfile(DefaultServerProfile("lab", "203.0.113.1:51820", "priv", "pub")); err != nil {
+		t.Fatalf("SaveServerProfile: %v", err)
+	}
+
+	const writers = 8
+	errs := make(chan error, writers)
+	for idx := 0; idx < writers; idx++ {
+		go func(idx int) {
+			unlock, err := LockServerProfile("lab")
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer unlock()
+			profile, err := LoadServerProfile("lab")
+			if err != nil {
+				errs <- err
+				return
+			}
+			profile.Clients = append(profile.Clients, ClientProfile{Name: fmt.Sprintf("client-%d", idx)})
+			errs <- SaveServerProfile(profile)
+		}(idx)
+	}
+	for idx := 0; idx < writers; idx++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("writer failed: %v", err)
+		}
+	}
+
+	profile, err := LoadServerProfile("lab")
+	if err != nil {
+		t.Fatalf("LoadServerProfile: %v", err)
+	}
+	if len(profile.Clients) != writers {
+		t.Fatalf("expected %d clients after concurrent updates, got %d", writers, len(profile.Clients))
+	}
+}
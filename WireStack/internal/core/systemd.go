@@ -0,0 +1,189 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+// Ways a server can be started by systemd.
+const (
+	// SystemdWGQuick installs a drop-in for the stock wg-quick@<iface> unit
+	// that points it at the rendered runtime config.
+	SystemdWGQuick = "wg-quick"
+	// SystemdService installs a dedicated wirestack-<server>.service that runs
+	// wirestack up and down, so lint plugins and the --backend choice apply.
+	SystemdService = "service"
+)
+
+// DefaultSystemdUnitDir is where units are installed unless told otherwise.
+const DefaultSystemdUnitDir = "/etc/systemd/system"
+
+// systemdDropInName is the drop-in file written for the wg-quick@ unit.
+const systemdDropInName = "wirestack.conf"
+
+// SystemdOptions configures BuildSystemdUnit.
+type SystemdOptions struct {
+	Mode    string
+	UnitDir string
+	// Executable is the wirestack binary the dedicated service runs.
+	Executable string
+	// Home is exported to the service so it finds ~/.wirestack.
+	Home string
+	// Args are global flags (e.g. --backend native) passed to wirestack.
+	Args []string
+}
+
+// SystemdUnit is a unit file WireStack installs for a server.
+type SystemdUnit struct {
+	Mode string
+	// Name is the unit systemctl operates on, e.g. wg-quick@prod.service.
+	Name    string
+	Path    string
+	Content string
+}
+
+// BuildSystemdUnit renders the unit file that starts the server's interface at boot.
+func BuildSystemdUnit(profile *ServerProfile, options SystemdOptions) (*SystemdUnit, error) {
+	unit := systemdUnitFor(profile, options.Mode, options.UnitDir)
+	builder := &strings.Builder{}
+	switch unit.Mode {
+	case SystemdWGQuick:
+		if profile.ExternalInterface != "" {
+			return nil, fmt.Errorf("server %s uses external interface %s; use --mode %s", profile.Name, profile.ExternalInterface, SystemdService)
+		}
+		configPath, err := ServerRuntimeConfigPath(profile.Name)
+		if err != nil {
+			return nil, err
+		}
+		wgQuick, err := exec.LookPath("wg-quick")
+		if err != nil {
+			wgQuick = "/usr/bin/wg-quick"
+		}
+		fmt.Fprintf(builder, "# Installed by wirestack systemd install %s.\n", profile.Name)
+		fmt.Fprintf(builder, "[Service]\n")
+		// Empty assignments clear the stock commands, which read /etc/wireguard.
+		fmt.Fprintf(builder, "ExecStart=\nExecStart=%s up %s\n", wgQuick, configPath)
+		fmt.Fprintf(builder, "ExecStop=\nExecStop=%s down %s\n", wgQuick, configPath)
+		fmt.Fprintf(builder, "ExecReload=\nExecReload=/bin/bash -c 'exec wg syncconf %s <(exec wg-quick strip %s)'\n", InterfaceName(profile), configPath)
+	case SystemdService:
+		if options.Executable == "" {
+			return nil, fmt.Errorf("wirestack executable path is empty")
+		}
+		command := strings.Join(append([]string{options.Executable}, options.Args...), " ")
+		fmt.Fprintf(builder, "# Installed by wirestack systemd install %s.\n", profile.Name)
+		fmt.Fprintf(builder, "[Unit]\nDescription=WireStack interface for server %s\n", profile.Name)
+		fmt.Fprintf(builder, "After=network-online.target\nWants=network-online.target\n\n")
+		fmt.Fprintf(builder, "[Service]\nType=oneshot\nRemainAfterExit=yes\n")
+		if options.Home != "" {
+			fmt.Fprintf(builder, "Environment=HOME=%s\n", options.Home)
+		}
+		fmt.Fprintf(builder, "ExecStart=%s up %s\n", command, profile.Name)
+		fmt.Fprintf(builder, "ExecStop=%s down %s\n\n", command, profile.Name)
+		fmt.Fprintf(builder, "[Install]\nWantedBy=multi-user.target\n")
+	default:
+		return nil, fmt.Errorf("unsupported systemd mode %q (want %s or %s)", options.Mode, SystemdWGQuick, SystemdService)
+	}
+	unit.Content = builder.String()
+	return unit, nil
+}
+
+// systemdUnitFor returns the unit name and file path for mode, without content.
+func systemdUnitFor(profile *ServerProfile, mode, unitDir string) *SystemdUnit {
+	if unitDir == "" {
+		unitDir = DefaultSystemdUnitDir
+	}
+	if mode == "" {
+		mode = SystemdWGQuick
+	}
+	if mode == SystemdService {
+		name := fmt.Sprintf("wirestack-%s.service", profile.Name)
+		return &SystemdUnit{Mode: mode, Name: name, Path: filepath.Join(unitDir, name)}
+	}
+	name := fmt.Sprintf("wg-quick@%s.service", InterfaceName(profile))
+	return &SystemdUnit{Mode: mode, Name: name, Path: filepath.Join(unitDir, name+".d", systemdDropInName)}
+}
+
+// InstalledSystemdUnit returns the unit installed for the server in unitDir,
+// or nil when there is none.
+func InstalledSystemdUnit(profile *ServerProfile, unitDir string) (*SystemdUnit, error) {
+	for _, mode := range []string{SystemdWGQuick, SystemdService} {
+		unit := systemdUnitFor(profile, mode, unitDir)
+		data, err := os.ReadFile(unit.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		unit.Content = string(data)
+		return unit, nil
+	}
+	return nil, nil
+}
+
+// InstallSystemdUnit writes the unit, reloads systemd, and optionally enables
+// and starts it.
+func InstallSystemdUnit(unit *SystemdUnit, enable bool) error {
+	if err := utils.WriteFile(unit.Path, []byte(unit.Content), 0o644); err != nil {
+		return err
+	}
+	if _, err := utils.RunCommand("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if enable {
+		if _, err := utils.RunCommand("systemctl", "enable", "--now", unit.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UninstallSystemdUnit disables and stops the unit, then removes its file.
+func UninstallSystemdUnit(unit *SystemdUnit) error {
+	if _, err := utils.RunCommand("systemctl", "disable", "--now", unit.Name); err != nil {
+		return err
+	}
+	if err := os.Remove(unit.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if unit.Mode == SystemdWGQuick {
+		// Leave the drop-in directory if the administrator keeps other files there.
+		_ = os.Remove(filepath.Dir(unit.Path))
+	}
+	_, err := utils.RunCommand("systemctl", "daemon-reload")
+	return err
+}
+
+// SystemdUnitState is what systemctl reports about a unit.
+type SystemdUnitState struct {
+	// Enabled is the unit file state, e.g. "enabled" or "disabled".
+	Enabled string
+	// Active is the activation state, e.g. "active", "inactive", or "failed".
+	Active string
+}
+
+// QuerySystemdUnit asks systemctl for the unit's state.
+func QuerySystemdUnit(name string) (SystemdUnitState, error) {
+	output, err := utils.RunCommand("systemctl", "show", name, "--property=UnitFileState", "--property=ActiveState")
+	if err != nil {
+		return SystemdUnitState{}, err
+	}
+	var state SystemdUnitState
+	for _, line := range strings.Split(output, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "UnitFileState":
+			state.Enabled = value
+		case "ActiveState":
+			state.Active = value
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wirestack/internal/utils"
+)
+
+func TestSystemdUnits(t *testing.T) {
+	home := setupTempHome(t)
+	unitDir := t.TempDir()
+	profile := DefaultServerProfile("prod", "203.0.113.1:51820", "server-priv", "server-pub")
+
+	unit, err := BuildSystemdUnit(profile, SystemdOptions{UnitDir: unitDir})
+	if err != nil {
+		t.Fatalf("BuildSystemdUnit: %v", err)
+	}
+	if unit.Name != "wg-quick@prod.service" || unit.Path != filepath.Join(unitDir, "wg-quick@prod.service.d", "wirestack.conf") {
+		t.Fatalf("unexpected drop-in %s at %s", unit.Name, unit.Path)
+	}
+	configPath := filepath.Join(home, ".wirestack", "runtime", "prod.conf")
+	if !strings.Contains(unit.Content, "ExecStart=\nExecStart=") || !strings.Contains(unit.Content, " up "+configPath+"\n") {
+		t.Fatalf("expected the drop-in to replace ExecStart with the runtime config:\n%s", unit.Content)
+	}
+
+	unit, err = BuildSystemdUnit(profile, SystemdOptions{Mode: SystemdService, UnitDir: unitDir, Executable: "/usr/local/bin/wirestack", Home: home, Args: []string{"--backend", "native"}})
+	if err != nil {
+		t.Fatalf("BuildSystemdUnit service: %v", err)
+	}
+	for _, want := range []string{
+		"Environment=HOME=" + home + "\n",
+		"ExecStart=/usr/local/bin/wirestack --backend native up prod\n",
+		"ExecStop=/usr/local/bin/wirestack --backend native down prod\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit.Content, want) {
+			t.Fatalf("expected %q in service unit:\n%s", want, unit.Content)
+		}
+	}
+
+	installed, err := InstalledSystemdUnit(profile, unitDir)
+	if err != nil || installed != nil {
+		t.Fatalf("expected no installed unit yet, got %+v (%v)", installed, err)
+	}
+	// InstallSystemdUnit needs systemctl; write the file the way it does.
+	if err := utils.WriteFile(unit.Path, []byte(unit.Content), 0o644); err != nil {
+		t.Fatalf("write unit: %v", err)
+	}
+	installed, err = InstalledSystemdUnit(profile, unitDir)
+	if err != nil || installed == nil || installed.Mode != SystemdService || installed.Content != unit.Content {
+		t.Fatalf("expected the service unit to be found, got %+v (%v)", installed, err)
+	}
+
+	profile.ExternalInterface = "wg0"
+	if _, err := BuildSystemdUnit(profile, SystemdOptions{Mode: SystemdWGQuick}); err == nil {
+		t.Fatalf("expected wg-quick mode to be rejected for an external interface")
+	}
+	if _, err := BuildSystemdUnit(profile, SystemdOptions{Mode: "upstart"}); err == nil {
+		t.Fatalf("expected an unknown mode to be rejected")
+	}
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Client platforms supported by BuildClientConfigFor.
+const (
+	TargetLinux   = "linux"
+	TargetMacOS   = "macos"
+	TargetWindows = "windows"
+	TargetAndroid = "android"
+	TargetIOS     = "ios"
+	TargetRouter  = "router"
+)
+
+// ClientTargets lists every supported export target.
+var ClientTargets = []string{TargetLinux, TargetMacOS, TargetWindows, TargetAndroid, TargetIOS, TargetRouter}
+
+// ClientRenderOptions tunes client config output for the device that will import it.
+type ClientRenderOptions struct {
+	Target     string
+	KillSwitch bool
+	// Amnezia renders the server's AmneziaWG parameters for clients that use
+	// the AmneziaWG fork. They are always rendered when the server needs them.
+	Amnezia bool
+}
+
+// targetProfile captures what a platform's WireGuard client supports.
+type targetProfile struct {
+	// hooks reports whether Pre/PostUp style commands are executed.
+	hooks bool
+	// dns reports whether the client applies the DNS key itself.
+	dns bool
+	// mtu is rendered when the client profile does not set its own.
+	mtu int
+	// nameLimit is the longest tunnel or interface name the platform accepts.
+	nameLimit int
+	// killSwitch explains how to block untunneled traffic when hooks are unavailable.
+	killSwitch string
+}
+
+var targetProfiles = map[string]targetProfile{
+	TargetLinux: {hooks: true, dns: true, nameLimit: 15},
+	TargetMacOS: {dns: true, nameLimit: 32,
+		killSwitch: "enable On-Demand in the WireGuard app to keep traffic inside the tunnel"},
+	TargetWindows: {dns: true, nameLimit: 32,
+		killSwitch: "the Windows client blocks untunneled traffic automatically for a single full-tunnel peer without hooks"},
+	TargetAndroid: {dns: true, mtu: 1280, nameLimit: 15,
+		killSwitch: "enable Always-on VPN and Block connections without VPN in Android network settings"},
+	TargetIOS: {dns: true, mtu: 1280, nameLimit: 32,
+		killSwitch: "enable On-Demand for all networks in the WireGuard app"},
+	TargetRouter: {mtu: 1412, nameLimit: 15,
+		killSwitch: "drop forwarding from LAN to WAN in the router firewall so LAN traffic can only leave through the tunnel"},
+}
+
+// linuxKillSwitch rejects traffic that would leave outside the tunnel, as documented in wg-quick(8).
+var linuxKillSwitch = []string{
+	"PostUp = iptables -I OUTPUT ! -o %i -m mark ! --mark $(wg show %i fwmark) -m addrtype ! --dst-type LOCAL -j REJECT && ip6tables -I OUTPUT ! -o %i -m mark ! --mark $(wg show %i fwmark) -m addrtype ! --dst-type LOCAL -j REJECT",
+	"PreDown = iptables -D OUTPUT ! -o %i -m mark ! --mark $(wg show %i fwmark) -m addrtype ! --dst-type LOCAL -j REJECT && ip6tables -D OUTPUT ! -o %i -m mark ! --mark $(wg show %i fwmark) -m addrtype ! --dst-type LOCAL -j REJECT",
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_=+.-]+`)
+
+// BuildClientConfigFor renders a client configuration adjusted for the target platform.
+func BuildClientConfigFor(profile *ServerProfile, client ClientProfile, options ClientRenderOptions) (string, error) {
+	if profile == nil {
+		return "", fmt.Errorf("server profile is nil")
+	}
+	if client.Name == "" {
+		return "", fmt.Errorf("client name is empty")
+	}
+	if options.Target == "" {
+		options.Target = TargetLinux
+	}
+	target, ok := targetProfiles[options.Target]
+	if !ok {
+		return "", fmt.Errorf("unsupported target %q (want one of %s)", options.Target, strings.Join(ClientTargets, ", "))
+	}
+	if options.KillSwitch && ClientMode(client) == ClientModeSplit {
+		return "", fmt.Errorf("the kill switch blocks everything outside the tunnel and cannot be used in %s mode", ClientModeSplit)
+	}
+	if options.Amnezia && profile.Amnezia == nil {
+		return "", fmt.Errorf("server %s has no AmneziaWG parameters; set them with set-amnezia", profile.Name)
+	}
+
+	data := ClientTemplateData{
+		Server:              profile.Name,
+		ServerDescription:   profile.Description,
+		Client:              client.Name,
+		Description:         client.Description,
+		Tags:                client.Tags,
+		Annotations:         client.Annotations,
+		Target:              options.Target,
+		PrivateKey:          client.PrivateKey,
+		Addresses:           ClientAddresses(client),
+		ServerPublicKey:     profile.ServerPublicKey,
+		AllowedIPs:          EffectiveAllowedIPs(profile, client),
+		Endpoint:            profile.Endpoint,
+		PersistentKeepalive: 25,
+	}
+
+	var notes []string
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "[Interface]\n")
+	writeDescription(builder, client.Description)
+	fmt.Fprintf(builder, "PrivateKey = %s\n", client.PrivateKey)
+	fmt.Fprintf(builder, "Address = %s\n", strings.Join(data.Addresses, ", "))
+
+	dns := ClientDNS(profile, client)
+	postUp, postDown := DNSRouteHooks(profile)
+	switch {
+	case len(postUp) > 0 && !target.hooks:
+		var routes []string
+		for _, route := range profile.DNSRoutes {
+			routes = append(routes, route.Domain+" -> "+route.Server)
+		}
+		notes = append(notes, "Split DNS is not applied automatically on this platform; configure: "+strings.Join(routes, ", "))
+		postUp, postDown = nil, nil
+	case len(dns) > 0 && len(postUp) == 0 && target.dns:
+		data.DNS, data.SearchDomains = dns, profile.SearchDomains
+		fmt.Fprintf(builder, "DNS = %s\n", strings.Join(append(append([]string(nil), dns...), profile.SearchDomains...), ", "))
+	case len(dns) > 0 && len(postUp) == 0:
+		note := "Point the router's DNS forwarder at " + strings.Join(dns, ", ") + " to resolve through the tunnel"
+		if len(profile.SearchDomains) > 0 {
+			note += " and add the search domains " + strings.Join(profile.SearchDomains, ", ")
+		}
+		notes = append(notes, note)
+	}
+
+	if mtu := ClientMTU(client, options.Target); mtu > 0 {
+		data.MTU = mtu
+		fmt.Fprintf(builder, "MTU = %d\n", mtu)
+	}
+	if profile.Amnezia != nil && (options.Amnezia || profile.Amnezia.NeedsServer()) {
+		data.Amnezia = profile.Amnezia.Lines()
+		for _, line := range data.Amnezia {
+			fmt.Fprintf(builder, "%s\n", line)
+		}
+	}
+	data.PostUp, data.PostDown = postUp, postDown
+	for _, hook := range postUp {
+		fmt.Fprintf(builder, "PostUp = %s\n", hook)
+	}
+	for _, hook := range postDown {
+		fmt.Fprintf(builder, "PostDown = %s\n", hook)
+	}
+	if options.KillSwitch {
+		if target.hooks {
+			data.KillSwitch = linuxKillSwitch
+			for _, line := range linuxKillSwitch {
+				fmt.Fprintf(builder, "%s\n", line)
+			}
+		} else {
+			notes = append(notes, "Kill switch: "+target.killSwitch)
+		}
+	}
+	data.Extra = strings.TrimSpace(ClientExtraFor(profile, client))
+	if data.Extra != "" {
+		fmt.Fprintf(builder, "%s\n", data.Extra)
+	}
+	fmt.Fprintf(builder, "\n")
+	fmt.Fprintf(builder, "[Peer]\n")
+	writeDescription(builder, profile.Description)
+	fmt.Fprintf(builder, "PublicKey = %s\n", profile.ServerPublicKey)
+	fmt.Fprintf(builder, "AllowedIPs = %s\n", strings.Join(data.AllowedIPs, ", "))
+	fmt.Fprintf(builder, "Endpoint = %s\n", profile.Endpoint)
+	fmt.Fprintf(builder, "PersistentKeepalive = %d\n", data.PersistentKeepalive)
+
+	data.Notes = notes
+	data.Default = builder.String()
+	if len(notes) > 0 {
+		header := &strings.Builder{}
+		fmt.Fprintf(header, "# Target: %s\n", options.Target)
+		for _, note := range notes {
+			fmt.Fprintf(header, "# %s\n", note)
+		}
+		data.Default = header.String() + data.Default
+	}
+	return renderTemplate(ClientTemplateFile, data, data.Default)
+}
+
+// ClientConfigFileName returns a file name whose stem is a valid tunnel or interface
+// name on the target, since most clients name the tunnel after the imported file.
+func ClientConfigFileName(serverName, clientName, target string) string {
+	limit := targetNameLimit(target)
+	stem := unsafeNameChars.ReplaceAllString(serverName+"-"+clientName, "-")
+	if len(stem) > limit {
+		stem = unsafeNameChars.ReplaceAllString(clientName, "-")
+	}
+	if len(stem) > limit {
+		stem = stem[:limit]
+	}
+	return stem + ".conf"
+}
+
+// targetNameLimit returns the longest tunnel name the target accepts.
+func targetNameLimit(target string) int {
+	if profile, ok := targetProfiles[target]; ok {
+		return profile.nameLimit
+	}
+	return 15
+}
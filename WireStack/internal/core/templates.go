@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Template files looked up in TemplatesRoot. When present they replace the
+// built-in rendering of BuildClientConfigFor and BuildServerConfig.
+const (
+	ClientTemplateFile = "client.conf.tmpl"
+	ServerTemplateFile = "server.conf.tmpl"
+)
+
+// ClientTemplateData is the data a client template is executed with.
+type ClientTemplateData struct {
+	Server string
+	// ServerDescription is the server's description, rendered on the [Peer].
+	ServerDescription string
+	Client            string
+	Description       string
+	Tags              []string
+	Annotations       map[string]string
+	// Target is the export platform, e.g. linux or ios.
+	Target     string
+	PrivateKey string
+	Addresses  []string
+	// DNS is empty when the platform cannot apply it or split DNS hooks are used.
+	DNS []string
+	// SearchDomains are rendered after DNS on the same line, and only with it.
+	SearchDomains []string
+	// Amnezia holds "Key = value" AmneziaWG lines for the amnezia flavor.
+	Amnezia []string
+	// MTU is zero when none should be set.
+	MTU      int
+	PostUp   []string
+	PostDown []string
+	// KillSwitch holds complete "Key = value" lines for the Linux kill switch.
+	KillSwitch []string
+	// Extra is the client or server extra lines, trimmed.
+	Extra               string
+	ServerPublicKey     string
+	AllowedIPs          []string
+	Endpoint            string
+	PersistentKeepalive int
+	// Notes are platform notes the built-in rendering writes as comments.
+	Notes []string
+	// Default is the built-in rendering, for templates that only add to it.
+	Default string
+}
+
+// ServerTemplateData is the data a server template is executed with.
+type ServerTemplateData struct {
+	Server      string
+	Description string
+	Annotations map[string]string
+	PrivateKey  string
+	Addresses   []string
+	ListenPort  string
+	// MTU is zero when none should be set.
+	MTU int
+	// Amnezia holds "Key = value" AmneziaWG lines when the server needs them.
+	Amnezia  []string
+	PostUp   []string
+	PostDown []string
+	// Peers are the enabled clients.
+	Peers []PeerTemplateData
+	// Default is the built-in rendering, for templates that only add to it.
+	Default string
+}
+
+// PeerTemplateData is one client in a server template.
+type PeerTemplateData struct {
+	Client      string
+	Description string
+	Tags        []string
+	Annotations map[string]string
+	PublicKey   string
+	AllowedIPs  []string
+}
+
+// templateFuncs are available in config templates in addition to the built-ins.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// renderTemplate executes the named user template with data. It returns the
+// built-in rendering in fallback when the template does not exist. A template
+// that exists while FeatureConfigTemplates is off is an error rather than
+// being ignored, so a config is never rendered differently than expected.
+func renderTemplate(name string, data any, fallback string) (string, error) {
+	root, err := TemplatesRoot()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(root, name)
+	text, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fallback, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := RequireFeature(FeatureConfigTemplates); err != nil {
+		return "", fmt.Errorf("template %s: %w", path, err)
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return "", fmt.Errorf("template %s: %w", path, err)
+	}
+	builder := &strings.Builder{}
+	if err := tmpl.Execute(builder, data); err != nil {
+		return "", fmt.Errorf("template %s: %w", path, err)
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wirestack/internal/utils"
+)
+
+func TestConfigTemplates(t *testing.T) {
+	home := setupTempHome(t)
+	profile := DefaultServerProfile("office", "203.0.113.1:51820", "server-priv", "server-pub")
+	profile.Clients = []ClientProfile{
+		{Name: "alice", PrivateKey: "alice-priv", PublicKey: "alice-pub", Address: "10.0.0.2/32", Annotations: map[string]string{"owner": "it"}},
+		{Name: "old", PrivateKey: "old-priv", PublicKey: "old-pub", Address: "10.0.0.3/32", Disabled: true},
+	}
+	builtin, err := BuildClientConfig(profile, profile.Clients[0])
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+
+	dir := filepath.Join(home, ".wirestack", "templates")
+	writeTemplate := func(name, text string) {
+		t.Helper()
+		if err := utils.WriteFile(filepath.Join(dir, name), []byte(text), 0o600); err != nil {
+			t.Fatalf("write template: %v", err)
+		}
+	}
+	writeTemplate(ClientTemplateFile, "# Owner: {{index .Annotations \"owner\"}}\n{{.Default}}")
+	writeTemplate(ServerTemplateFile, "[Interface]\nPrivateKey = {{.PrivateKey}}\nListenPort = {{.ListenPort}}\n{{range .Peers}}\n[Peer]\n# {{.Client}}\nPublicKey = {{.PublicKey}}\nAllowedIPs = {{join .AllowedIPs \", \"}}\n{{end}}")
+
+	t.Setenv(FeaturesEnv, "")
+	if _, err := BuildClientConfig(profile, profile.Clients[0]); err == nil || !strings.Contains(err.Error(), "features enable config-templates") {
+		t.Fatalf("expected templates to need the config-templates feature, got %v", err)
+	}
+	t.Setenv(FeaturesEnv, FeatureConfigTemplates)
+
+	client, err := BuildClientConfig(profile, profile.Clients[0])
+	if err != nil {
+		t.Fatalf("BuildClientConfig: %v", err)
+	}
+	if client != "# Owner: it\n"+builtin {
+		t.Fatalf("expected the template to wrap the built-in rendering, got:\n%s", client)
+	}
+	server, err := BuildServerConfig(profile)
+	if err != nil {
+		t.Fatalf("BuildServerConfig: %v", err)
+	}
+	if !strings.Contains(server, "# alice\nPublicKey = alice-pub\nAllowedIPs = 10.0.0.2/32") || strings.Contains(server, "old-pub") || !strings.Contains(server, "ListenPort = 51820") {
+		t.Fatalf("unexpected server rendering:\n%s", server)
+	}
+
+	writeTemplate(ClientTemplateFile, "{{.Nope}}")
+	if _, err := BuildClientConfig(profile, profile.Clients[0]); err == nil || !strings.Contains(err.Error(), ClientTemplateFile) {
+		t.Fatalf("expected an error naming the template, got %v", err)
+	}
+	writeTemplate(ClientTemplateFile, "{{if}}")
+	if _, err := BuildClientConfig(profile, profile.Clients[0]); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+}
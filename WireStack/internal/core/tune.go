@@ -0,0 +1,250 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"wirestack/internal/utils"
+)
+
+const (
+	// tuneSocketBuffer is the socket buffer ceiling recommended for
+	// high-throughput UDP, in bytes.
+	tuneSocketBuffer = 16 << 20
+	// tuneBacklog is the recommended per-CPU input queue length.
+	tuneBacklog = 5000
+	// tuneQdisc is the queueing discipline recommended for the egress interface.
+	tuneQdisc = "fq"
+)
+
+// procSysRoot is where sysctls are read from; tests point it elsewhere.
+var procSysRoot = "/proc/sys"
+
+// TuneInspection is what InspectTuning found on this host.
+type TuneInspection struct {
+	// Interface is the egress interface WireGuard's UDP traffic leaves through.
+	Interface string
+	// Sysctls holds the values of the inspected sysctls that could be read.
+	Sysctls map[string]string
+	// Offloads maps ethtool feature names to "on" or "off"; fixed features are left out.
+	Offloads map[string]string
+	// Qdisc is the kind of the root qdisc on Interface, e.g. "fq_codel".
+	Qdisc string
+	// Notes explain what could not be inspected.
+	Notes []string
+}
+
+// TuneRecommendation is one suggested change. Command applies it until the
+// next reboot and is what tune --apply adds as a PostUp hook.
+type TuneRecommendation struct {
+	Setting     string
+	Current     string
+	Recommended string
+	Reason      string
+	Command     string
+}
+
+// tunedSysctls are read by InspectTuning.
+var tunedSysctls = []string{"net.core.rmem_max", "net.core.wmem_max", "net.core.netdev_max_backlog", "net.core.default_qdisc"}
+
+// InspectTuning reads the sysctls, NIC offloads, and qdisc that matter for
+// WireGuard throughput. iface overrides the egress interface, which is
+// otherwise the server's NAT interface or the default route's device. Missing
+// tools are reported in Notes rather than failing.
+func InspectTuning(profile *ServerProfile, iface string) *TuneInspection {
+	if iface == "" {
+		iface = profile.NATInterface
+	}
+	inspection := &TuneInspection{Interface: iface, Sysctls: map[string]string{}, Offloads: map[string]string{}}
+	if iface == "" {
+		var err error
+		if iface, err = defaultRouteInterface(); err != nil {
+			inspection.Notes = append(inspection.Notes, fmt.Sprintf("no egress interface (%v); pass --interface to check offloads and qdisc", err))
+		}
+		inspection.Interface = iface
+	}
+
+	for _, key := range tunedSysctls {
+		value, err := readSysctl(key)
+		if err != nil {
+			inspection.Notes = append(inspection.Notes, fmt.Sprintf("sysctl %s: %v", key, err))
+			continue
+		}
+		inspection.Sysctls[key] = value
+	}
+	if iface == "" {
+		return inspection
+	}
+
+	output, err := utils.RunCommand("ethtool", "-k", iface)
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		inspection.Notes = append(inspection.Notes, "ethtool is not installed; offloads were not checked")
+	case err != nil:
+		inspection.Notes = append(inspection.Notes, fmt.Sprintf("ethtool -k %s: %v", iface, err))
+	default:
+		inspection.Offloads = ParseEthtoolFeatures(output)
+	}
+
+	output, err = utils.RunCommand("tc", "qdisc", "show", "dev", iface, "root")
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		inspection.Notes = append(inspection.Notes, "tc is not installed; the qdisc was not checked")
+	case err != nil:
+		inspection.Notes = append(inspection.Notes, fmt.Sprintf("tc qdisc show dev %s: %v", iface, err))
+	default:
+		inspection.Qdisc = ParseRootQdisc(output)
+	}
+	return inspection
+}
+
+// TuneRecommendations compares an inspection with the recommended settings.
+func TuneRecommendations(inspection *TuneInspection) []TuneRecommendation {
+	var recs []TuneRecommendation
+	for _, key := range []string{"net.core.rmem_max", "net.core.wmem_max"} {
+		if current, ok := inspection.Sysctls[key]; ok && !atLeast(current, tuneSocketBuffer) {
+			recs = append(recs, TuneRecommendation{
+				Setting:     key,
+				Current:     current,
+				Recommended: strconv.Itoa(tuneSocketBuffer),
+				Reason:      "larger UDP socket buffers avoid drops during bursts at high throughput",
+				Command:     fmt.Sprintf("sysctl -w %s=%d", key, tuneSocketBuffer),
+			})
+		}
+	}
+	if current, ok := inspection.Sysctls["net.core.netdev_max_backlog"]; ok && !atLeast(current, tuneBacklog) {
+		recs = append(recs, TuneRecommendation{
+			Setting:     "net.core.netdev_max_backlog",
+			Current:     current,
+			Recommended: strconv.Itoa(tuneBacklog),
+			Reason:      "a longer input queue absorbs bursts of decrypted packets",
+			Command:     fmt.Sprintf("sysctl -w net.core.netdev_max_backlog=%d", tuneBacklog),
+		})
+	}
+	if current, ok := inspection.Sysctls["net.core.default_qdisc"]; ok && current != tuneQdisc && current != "fq_codel" {
+		recs = append(recs, TuneRecommendation{
+			Setting:     "net.core.default_qdisc",
+			Current:     current,
+			Recommended: tuneQdisc,
+			Reason:      "fair queueing keeps latency low for other flows while the tunnel is busy",
+			Command:     "sysctl -w net.core.default_qdisc=" + tuneQdisc,
+		})
+	}
+
+	iface := inspection.Interface
+	for _, offload := range []struct {
+		feature string
+		want    string
+		reason  string
+	}{
+		{"rx-udp-gro-forwarding", "on", "UDP GRO forwarding lets the kernel batch forwarded WireGuard packets (Linux 6.2+)"},
+		{"rx-gro-list", "off", "GRO list mode defeats UDP GRO forwarding"},
+		{"generic-receive-offload", "on", "GRO batches received packets before they reach WireGuard"},
+	} {
+		if current, ok := inspection.Offloads[offload.feature]; ok && current != offload.want {
+			recs = append(recs, TuneRecommendation{
+				Setting:     fmt.Sprintf("%s %s", iface, offload.feature),
+				Current:     current,
+				Recommended: offload.want,
+				Reason:      offload.reason,
+				Command:     fmt.Sprintf("ethtool -K %s %s %s", iface, offload.feature, offload.want),
+			})
+		}
+	}
+
+	switch inspection.Qdisc {
+	case "pfifo_fast", "pfifo", "noqueue":
+		recs = append(recs, TuneRecommendation{
+			Setting:     iface + " qdisc",
+			Current:     inspection.Qdisc,
+			Recommended: tuneQdisc,
+			Reason:      "a plain FIFO lets one busy flow add latency for everything else on the link",
+			Command:     fmt.Sprintf("tc qdisc replace dev %s root %s", iface, tuneQdisc),
+		})
+	}
+	return recs
+}
+
+// AddTuneHooks appends the recommendations' commands to the server's PostUp
+// hooks, skipping ones already present, and returns how many were added.
+func AddTuneHooks(profile *ServerProfile, recs []TuneRecommendation) (int, error) {
+	postUp := append([]string(nil), profile.PostUp...)
+	added := 0
+	for _, rec := range recs {
+		if rec.Command == "" || containsString(postUp, rec.Command) {
+			continue
+		}
+		postUp = append(postUp, rec.Command)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err := SetServerHooks(profile, postUp, profile.PostDown); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// ParseEthtoolFeatures reads `ethtool -k` output into feature states,
+// leaving out features marked [fixed] since they cannot be changed.
+func ParseEthtoolFeatures(output string) map[string]string {
+	features := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		name, rest, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || strings.Contains(rest, "[fixed]") {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) > 0 && (fields[0] == "on" || fields[0] == "off") {
+			features[name] = fields[0]
+		}
+	}
+	return features
+}
+
+// ParseRootQdisc returns the kind of the first qdisc in `tc qdisc show` output.
+func ParseRootQdisc(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "qdisc" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// defaultRouteInterface returns the device of the IPv4 default route.
+func defaultRouteInterface() (string, error) {
+	output, err := utils.RunCommand("ip", "-4", "route", "show", "default")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(output)
+	for idx := 0; idx+1 < len(fields); idx++ {
+		if fields[idx] == "dev" {
+			return fields[idx+1], nil
+		}
+	}
+	return "", fmt.Errorf("no default route")
+}
+
+// readSysctl reads a sysctl such as net.core.rmem_max.
+func readSysctl(key string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(procSysRoot, filepath.FromSlash(strings.ReplaceAll(key, ".", "/"))))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// atLeast reports whether value parses as an integer of at least min.
+func atLeast(value string, min int) bool {
+	number, err := strconv.Atoi(value)
+	return err == nil && number >= min
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTuningOutput(t *testing.T) {
+	features := ParseEthtoolFeatures(`Features for eth0:
+rx-checksumming: on
+generic-receive-offload: on
+rx-gro-list: on
+rx-udp-gro-forwarding: off
+tx-lockless: on [fixed]
+`)
+	if features["rx-udp-gro-forwarding"] != "off" || features["rx-gro-list"] != "on" || features["generic-receive-offload"] != "on" {
+		t.Fatalf("unexpected features %v", features)
+	}
+	if _, ok := features["tx-lockless"]; ok {
+		t.Fatalf("fixed features should be left out")
+	}
+	if qdisc := ParseRootQdisc("qdisc mq 0: root\nqdisc fq_codel 0: parent :1 limit 10240p\n"); qdisc != "mq" {
+		t.Fatalf("unexpected root qdisc %q", qdisc)
+	}
+}
+
+func TestTuneRecommendationsAndHooks(t *testing.T) {
+	procSysRoot = t.TempDir()
+	t.Cleanup(func() { procSysRoot = "/proc/sys" })
+	for key, value := range map[string]string{
+		"net/core/rmem_max":           "212992",
+		"net/core/wmem_max":           "33554432",
+		"net/core/netdev_max_backlog": "1000",
+		"net/core/default_qdisc":      "fq_codel",
+	} {
+		path := filepath.Join(procSysRoot, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	value, err := readSysctl("net.core.rmem_max")
+	if err != nil || value != "212992" {
+		t.Fatalf("readSysctl returned %q, %v", value, err)
+	}
+
+	inspection := &TuneInspection{
+		Interface: "eth0",
+		Sysctls:   map[string]string{"net.core.rmem_max": "212992", "net.core.wmem_max": "33554432", "net.core.netdev_max_backlog": "1000", "net.core.default_qdisc": "fq_codel"},
+		Offloads:  map[string]string{"rx-udp-gro-forwarding": "off", "rx-gro-list": "off", "generic-receive-offload": "on"},
+		Qdisc:     "pfifo_fast",
+	}
+	var commands []string
+	for _, rec := range TuneRecommendations(inspection) {
+		commands = append(commands, rec.Command)
+	}
+	want := []string{
+		"sysctl -w net.core.rmem_max=16777216",
+		"sysctl -w net.core.netdev_max_backlog=5000",
+		"ethtool -K eth0 rx-udp-gro-forwarding on",
+		"tc qdisc replace dev eth0 root fq",
+	}
+	if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected recommendations:\n%s", strings.Join(commands, "\n"))
+	}
+
+	profile := DefaultServerProfile("fast", "203.0.113.1:51820", "priv", "pub")
+	profile.PostUp = []string{"sysctl -w net.core.rmem_max=16777216"}
+	recs := TuneRecommendations(inspection)
+	added, err := AddTuneHooks(profile, recs)
+	if err != nil || added != 3 || len(profile.PostUp) != 4 {
+		t.Fatalf("AddTuneHooks added %d (%v), PostUp %v", added, err, profile.PostUp)
+	}
+	if added, err := AddTuneHooks(profile, recs); err != nil || added != 0 {
+		t.Fatalf("second AddTuneHooks added %d (%v)", added, err)
+	}
+
+	external := DefaultServerProfile("ext", "203.0.113.1:51820", "", "pub")
+	external.ExternalInterface = "wg0"
+	if _, err := AddTuneHooks(external, recs); err == nil {
+		t.Fatalf("expected hooks to be rejected for an external interface")
+	}
+}
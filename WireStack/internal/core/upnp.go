@@ -0,0 +1,208 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ssdpAddress is the multicast group UPnP devices answer discovery on.
+const ssdpAddress = "239.255.255.250:1900"
+
+// upnpServiceTypes are the gateway services that know the external address,
+// in the order they are tried.
+var upnpServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// UPnPGateway is the WAN connection service of an Internet gateway device.
+type UPnPGateway struct {
+	ServiceType string
+	ControlURL  string
+}
+
+// DiscoverUPnPGateway finds the router's WAN connection service with an SSDP
+// search on the local network, waiting until ctx is done for an answer.
+func DiscoverUPnPGateway(ctx context.Context) (*UPnPGateway, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(3 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+	for _, serviceType := range upnpServiceTypes {
+		search := "M-SEARCH * HTTP/1.1\r\nHOST: " + ssdpAddress + "\r\nMAN: \"ssdp:discover\"\r\nMX: 2\r\nST: " + serviceType + "\r\n\r\n"
+		if _, err := conn.WriteTo([]byte(search), group); err != nil {
+			return nil, fmt.Errorf("upnp discovery: %w", err)
+		}
+	}
+
+	buffer := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return nil, fmt.Errorf("no UPnP gateway answered: %w", err)
+		}
+		location := ssdpHeader(string(buffer[:n]), "LOCATION")
+		if location == "" {
+			continue
+		}
+		if gateway, err := upnpGatewayAt(ctx, location); err == nil {
+			return gateway, nil
+		}
+	}
+}
+
+// ssdpHeader returns the value of header in an SSDP response.
+func ssdpHeader(response, header string) string {
+	for _, line := range strings.Split(response, "\r\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), header) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// upnpDevice is the part of a UPnP device description that lists services,
+// nested to any depth.
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// upnpGatewayAt reads the device description at location and returns its
+// first WAN connection service.
+func upnpGatewayAt(ctx context.Context, location string) (*UPnPGateway, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var description struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&description); err != nil {
+		return nil, fmt.Errorf("upnp description %s: %w", location, err)
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if description.URLBase != "" {
+		if base, err = url.Parse(description.URLBase); err != nil {
+			return nil, err
+		}
+	}
+	for _, serviceType := range upnpServiceTypes {
+		if control := findUPnPService(description.Device, serviceType); control != "" {
+			resolved, err := base.Parse(control)
+			if err != nil {
+				return nil, err
+			}
+			return &UPnPGateway{ServiceType: serviceType, ControlURL: resolved.String()}, nil
+		}
+	}
+	return nil, fmt.Errorf("upnp device %s has no WAN connection service", location)
+}
+
+// findUPnPService returns the control URL of serviceType in device or any
+// device nested in it.
+func findUPnPService(device upnpDevice, serviceType string) string {
+	for _, service := range device.Services {
+		if service.ServiceType == serviceType {
+			return service.ControlURL
+		}
+	}
+	for _, child := range device.Devices {
+		if control := findUPnPService(child, serviceType); control != "" {
+			return control
+		}
+	}
+	return ""
+}
+
+// ExternalIP asks the gateway for its WAN address.
+func (g *UPnPGateway) ExternalIP(ctx context.Context) (string, error) {
+	var response struct {
+		Address string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := g.call(ctx, "GetExternalIPAddress", nil, &response); err != nil {
+		return "", err
+	}
+	if response.Address == "" {
+		return "", fmt.Errorf("upnp gateway reported no external address")
+	}
+	return response.Address, nil
+}
+
+// call performs one SOAP action with the given arguments, in order, and
+// decodes the envelope of a successful response into out.
+func (g *UPnPGateway) call(ctx context.Context, action string, args [][2]string, out any) error {
+	body := &bytes.Buffer{}
+	fmt.Fprintf(body, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:%s xmlns:u="%s">`, action, g.ServiceType)
+	for _, arg := range args {
+		fmt.Fprintf(body, "<%s>", arg[0])
+		xml.EscapeText(body, []byte(arg[1]))
+		fmt.Fprintf(body, "</%s>", arg[0])
+	}
+	fmt.Fprintf(body, "</u:%s></s:Body></s:Envelope>", action)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, g.ControlURL, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, g.ServiceType, action))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("upnp %s: %w", action, err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("upnp %s: %w", action, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		var fault struct {
+			Code        string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+			Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+		}
+		if xml.Unmarshal(data, &fault) == nil && fault.Code != "" {
+			return fmt.Errorf("upnp %s failed: error %s: %s", action, fault.Code, fault.Description)
+		}
+		return fmt.Errorf("upnp %s returned %s", action, response.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := xml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("upnp %s: %w", action, err)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package core
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+)
+
+// subnetLowWater is the share of free client addresses below which
+// subnet-exhaustion warns before add-client starts failing.
+const subnetLowWater = 0.1
+
+const (
+	// SeverityError marks findings that make a profile unusable or unsafe.
+	SeverityError = "error"
+	// SeverityWarning marks findings worth fixing that do not break rendering.
+	SeverityWarning = "warning"
+)
+
+// Finding is a single validation problem. Client is empty for server-level findings.
+type Finding struct {
+	Rule     string `json:"rule" yaml:"rule"`
+	Severity string `json:"severity" yaml:"severity"`
+	Server   string `json:"server" yaml:"server"`
+	Client   string `json:"client,omitempty" yaml:"client,omitempty"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+// ValidationRule documents a check performed by ValidateProfiles.
+type ValidationRule struct {
+	ID          string
+	Description string
+}
+
+// ValidationRules lists every rule ValidateProfiles may report.
+var ValidationRules = []ValidationRule{
+	{"subnet-invalid", "Server subnets must be valid CIDRs of the right address family"},
+	{"address-outside-subnet", "Server and client addresses must fall inside the server subnet"},
+	{"duplicate-address", "Tunnel addresses must be unique within a server"},
+	{"duplicate-client", "Client names must be unique within a server"},
+	{"duplicate-public-key", "Public keys must be unique across all peers"},
+	{"key-missing", "Servers and clients need their WireGuard keys"},
+	{"key-malformed", "WireGuard keys must be base64 encoded 32 byte values"},
+	{"subnet-overlap", "Servers must not allocate clients from overlapping subnets"},
+	{"listen-port-conflict", "Servers managed by WireStack must not share a listen port"},
+	{"plugin-failed", "Registered lint plugins must run and return valid findings"},
+	{"endpoint-invalid", "Server endpoints must be host:port with a port between 1 and 65535"},
+	{"allowed-ips-invalid", "Client AllowedIPs must be valid CIDRs"},
+	{"allowed-ips-overlap", "A client's AllowedIPs should not contain overlapping networks"},
+	{"dns-missing", "Full-tunnel clients need DNS servers so name resolution works inside the tunnel"},
+	{"subnet-exhaustion", "Server subnets should have room for more clients"},
+	{"network-invalid", "Routed networks behind a server must be valid CIDRs that do not overlap its subnets or each other"},
+	{"port-forward-invalid", "Client port forwards need NAT on the server and must not share a public port"},
+}
+
+// HasErrors reports whether any finding has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateProfiles checks each profile individually and then for conflicts between profiles.
+func ValidateProfiles(profiles []*ServerProfile) []Finding {
+	var findings []Finding
+	for _, profile := range profiles {
+		findings = append(findings, ValidateProfile(profile)...)
+	}
+	return append(findings, validateAcrossProfiles(profiles)...)
+}
+
+// ValidateProfile checks a single server profile and its clients.
+func ValidateProfile(profile *ServerProfile) []Finding {
+	v := &validator{server: profile.Name}
+
+	network, err := ClientSubnet(profile)
+	if err != nil {
+		v.add("subnet-invalid", SeverityError, "", "%v", err)
+	}
+	var network6 *net.IPNet
+	if profile.Subnet6 != "" {
+		if network6, err = ParseSubnet6(profile.Subnet6); err != nil {
+			v.add("subnet-invalid", SeverityError, "", "%v", err)
+		}
+	}
+
+	v.checkEndpoint(profile.Endpoint)
+	if err := checkServerNetworks(profile, profile.Networks); err != nil {
+		v.add("network-invalid", SeverityError, "", "%v; fix the server's routed networks", err)
+	}
+	if err := checkPortForwards(profile); err != nil {
+		v.add("port-forward-invalid", SeverityError, "", "%v; fix it with edit-client --forward", err)
+	}
+	v.checkAddress(network, "", profile.Address, "server address")
+	if profile.Address6 != "" {
+		v.checkAddress(network6, "", profile.Address6, "server IPv6 address")
+	}
+
+	if profile.ServerPublicKey == "" {
+		v.add("key-missing", SeverityError, "", "server public key is missing")
+	} else {
+		v.checkKey("", profile.ServerPublicKey, "server public key")
+	}
+	if profile.ExternalInterface == "" {
+		if profile.ServerPrivateKey == "" {
+			v.add("key-missing", SeverityError, "", "server private key is missing")
+		} else {
+			v.checkKey("", profile.ServerPrivateKey, "server private key")
+		}
+	}
+
+	names := map[string]bool{}
+	addresses := map[string]string{}
+	keys := map[string]string{profile.ServerPublicKey: "the server"}
+	for _, address := range ServerAddresses(profile) {
+		if ip := parseAddress(address); ip != nil {
+			addresses[ip.String()] = "the server"
+		}
+	}
+	for _, client := range profile.Clients {
+		if names[client.Name] {
+			v.add("duplicate-client", SeverityError, client.Name, "client name %s is used more than once", client.Name)
+		}
+		names[client.Name] = true
+		if client.Group != "" && clientGroup(profile, client) == nil {
+			v.add("group-missing", SeverityError, client.Name, "client is in group %s, which does not exist; recreate it with set-group or move the client with edit-client --group", client.Group)
+		}
+
+		v.checkAddress(network, client.Name, client.Address, "address")
+		if client.Address6 != "" {
+			v.checkAddress(network6, client.Name, client.Address6, "IPv6 address")
+		}
+		for _, address := range ClientAddresses(client) {
+			ip := parseAddress(address)
+			if ip == nil {
+				continue
+			}
+			if owner, taken := addresses[ip.String()]; taken {
+				v.add("duplicate-address", SeverityError, client.Name, "address %s is already assigned to %s", ip, owner)
+				continue
+			}
+			addresses[ip.String()] = "client " + client.Name
+		}
+
+		if client.PublicKey == "" {
+			v.add("key-missing", SeverityError, client.Name, "public key is missing")
+		} else {
+			v.checkKey(client.Name, client.PublicKey, "public key")
+			if owner, taken := keys[client.PublicKey]; taken {
+				v.add("duplicate-public-key", SeverityError, client.Name, "public key is also used by %s", owner)
+			}
+			keys[client.PublicKey] = "client " + client.Name
+		}
+		if client.PrivateKey != "" {
+			v.checkKey(client.Name, client.PrivateKey, "private key")
+		}
+		v.checkAllowedIPs(profile, client)
+	}
+	if network != nil {
+		v.checkCapacity(network, profile.Clients)
+	}
+	return v.findings
+}
+
+// validateAcrossProfiles reports conflicts that only show up when comparing servers.
+func validateAcrossProfiles(profiles []*ServerProfile) []Finding {
+	var findings []Finding
+	type owned struct {
+		server  string
+		network *net.IPNet
+	}
+	var networks []owned
+	ports := map[string]string{}
+	keys := map[string]string{}
+
+	for _, profile := range profiles {
+		for _, cidr := range []string{profile.Subnet, profile.Subnet6} {
+			if cidr == "" {
+				continue
+			}
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			for _, other := range networks {
+				if other.network.Contains(network.IP) || network.Contains(other.network.IP) {
+					findings = append(findings, Finding{
+						Rule:     "subnet-overlap",
+						Severity: SeverityWarning,
+						Server:   profile.Name,
+						Message:  fmt.Sprintf("subnet %s overlaps %s of server %s", network, other.network, other.server),
+					})
+				}
+			}
+			networks = append(networks, owned{server: profile.Name, network: network})
+		}
+
+		if profile.ExternalInterface == "" {
+			if _, port, err := net.SplitHostPort(profile.Endpoint); err == nil {
+				if other, taken := ports[port]; taken {
+					findings = append(findings, Finding{
+						Rule:     "listen-port-conflict",
+						Severity: SeverityWarning,
+						Server:   profile.Name,
+						Message:  fmt.Sprintf("listen port %s is also used by server %s; they cannot run on the same host", port, other),
+					})
+				}
+				ports[port] = profile.Name
+			}
+		}
+
+		peers := []string{profile.ServerPublicKey}
+		for _, client := range profile.Clients {
+			peers = append(peers, client.PublicKey)
+		}
+		for _, key := range peers {
+			if key == "" {
+				continue
+			}
+			if other, taken := keys[key]; taken && other != profile.Name {
+				findings = append(findings, Finding{
+					Rule:     "duplicate-public-key",
+					Severity: SeverityError,
+					Server:   profile.Name,
+					Message:  fmt.Sprintf("public key %s is also used on server %s", key, other),
+				})
+			}
+			keys[key] = profile.Name
+		}
+	}
+	return findings
+}
+
+// validator accumulates findings for one server.
+type validator struct {
+	server   string
+	findings []Finding
+}
+
+func (v *validator) add(rule, severity, client, format string, args ...any) {
+	v.findings = append(v.findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		Server:   v.server,
+		Client:   client,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) checkAddress(network *net.IPNet, client, address, label string) {
+	ip := parseAddress(address)
+	if ip == nil {
+		v.add("address-outside-subnet", SeverityError, client, "%s %q is not a valid IP address", label, address)
+		return
+	}
+	if network != nil && !network.Contains(ip) {
+		v.add("address-outside-subnet", SeverityError, client, "%s %s is outside subnet %s", label, ip, network)
+	}
+}
+
+func (v *validator) checkEndpoint(endpoint string) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil || host == "" {
+		v.add("endpoint-invalid", SeverityError, "", "endpoint %q is not host:port; set it to the public address clients connect to, e.g. vpn.example.com:51820", endpoint)
+		return
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		v.add("endpoint-invalid", SeverityError, "", "endpoint %q has an invalid port; use a UDP port between 1 and 65535", endpoint)
+	}
+}
+
+// checkAllowedIPs checks the AllowedIPs rendered into the client's config,
+// and that full-tunnel clients get DNS servers.
+func (v *validator) checkAllowedIPs(profile *ServerProfile, client ClientProfile) {
+	var networks []*net.IPNet
+	routesAll := false
+	for _, cidr := range EffectiveAllowedIPs(profile, client) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			v.add("allowed-ips-invalid", SeverityError, client.Name, "AllowedIPs entry %q is not a CIDR; fix it with edit-client --allowed-ips or the matching tag policy", cidr)
+			continue
+		}
+		for _, other := range networks {
+			if other.Contains(network.IP) || network.Contains(other.IP) {
+				v.add("allowed-ips-overlap", SeverityWarning, client.Name, "AllowedIPs %s overlaps %s; drop the narrower entry", network, other)
+			}
+		}
+		networks = append(networks, network)
+		if ones, _ := network.Mask.Size(); ones == 0 {
+			routesAll = true
+		}
+	}
+	if routesAll && len(ClientDNS(profile, client)) == 0 && len(profile.DNSRoutes) == 0 {
+		v.add("dns-missing", SeverityWarning, client.Name, "client routes all traffic through the tunnel but has no DNS servers; set them with edit-client --dns or on the server")
+	}
+}
+
+// checkCapacity warns when the IPv4 subnet is full or nearly full.
+func (v *validator) checkCapacity(network *net.IPNet, clients []ClientProfile) {
+	ones, bits := network.Mask.Size()
+	// The network, broadcast, and server addresses are not handed out.
+	capacity := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)), big.NewInt(3))
+	if capacity.Sign() <= 0 || !capacity.IsInt64() {
+		return
+	}
+	used := 0
+	for _, client := range clients {
+		if ip := parseAddress(client.Address); ip != nil && network.Contains(ip) {
+			used++
+		}
+	}
+	free := capacity.Int64() - int64(used)
+	switch {
+	case free <= 0:
+		v.add("subnet-exhaustion", SeverityWarning, "", "subnet %s is full (%d of %d client addresses used); add-client will fail until clients are removed or the server moves to a larger subnet", network, used, capacity.Int64())
+	case float64(free) < float64(capacity.Int64())*subnetLowWater:
+		v.add("subnet-exhaustion", SeverityWarning, "", "subnet %s has only %d of %d client addresses left; plan a move to a larger subnet", network, free, capacity.Int64())
+	}
+}
+
+func (v *validator) checkKey(client, key, label string) {
+	if !ValidKey(key) {
+		v.add("key-malformed", SeverityError, client, "%s is not a base64 encoded 32 byte WireGuard key", label)
+	}
+}
+
+// ValidKey reports whether key is a base64 encoded 32 byte WireGuard key.
+func ValidKey(key string) bool {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(raw) == 32
+}
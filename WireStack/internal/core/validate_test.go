@@ -0,0 +1,92 @@
+package core
+
+import "testing"
+
+const (
+	testKeyA = "YUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUE="
+	testKeyB = "YkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkI="
+	testKeyC = "Y0NDQ0NDQ0NDQ0NDQ0NDQ0NDQ0NDQ0NDQ0NDQ0NDQ0M="
+)
+
+func TestValidateProfileFindings(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:51820", testKeyA, testKeyB)
+	profile.Clients = []ClientProfile{
+		{Name: "alice", PublicKey: testKeyC, Address: "10.0.0.2/32"},
+		{Name: "alice", PublicKey: "short", Address: "10.0.0.2/32"},
+		{Name: "bob", PublicKey: testKeyC, Address: "192.168.1.5/32"},
+	}
+
+	rules := map[string]int{}
+	for _, finding := range ValidateProfile(profile) {
+		rules[finding.Rule]++
+	}
+	for _, rule := range []string{"duplicate-client", "duplicate-address", "key-malformed", "address-outside-subnet", "duplicate-public-key"} {
+		if rules[rule] == 0 {
+			t.Fatalf("expected a %s finding, got %v", rule, rules)
+		}
+	}
+
+	clean := DefaultServerProfile("clean", "203.0.113.1:51820", testKeyA, testKeyB)
+	clean.Clients = []ClientProfile{{Name: "alice", PublicKey: testKeyC, Address: "10.0.0.2/32"}}
+	if findings := ValidateProfile(clean); len(findings) != 0 {
+		t.Fatalf("expected no findings for clean profile, got %+v", findings)
+	}
+}
+
+func TestValidateAcrossProfiles(t *testing.T) {
+	first := DefaultServerProfile("one", "203.0.113.1:51820", testKeyA, testKeyB)
+	second := DefaultServerProfile("two", "203.0.113.1:51820", testKeyA, testKeyC)
+
+	findings := ValidateProfiles([]*ServerProfile{first, second})
+	rules := map[string]bool{}
+	for _, finding := range findings {
+		rules[finding.Rule] = true
+	}
+	if !rules["subnet-overlap"] || !rules["listen-port-conflict"] {
+		t.Fatalf("expected overlap and port conflict findings, got %+v", findings)
+	}
+	if HasErrors(findings) {
+		t.Fatalf("cross-server overlaps should only warn: %+v", findings)
+	}
+}
+
+func TestValidateEndpointRoutingAndCapacity(t *testing.T) {
+	profile := DefaultServerProfile("srv", "203.0.113.1:70000", testKeyA, testKeyB)
+	profile.Subnet = "10.0.0.0/29"
+	profile.DNS = nil
+	profile.Policies = []AccessPolicy{{Tag: "office", AllowedIPs: []string{"10.0.0.0/8", "10.1.0.0/16", "not-a-cidr"}}}
+	profile.Clients = []ClientProfile{
+		{Name: "alice", PublicKey: testKeyC, Address: "10.0.0.2/32", AllowedIPs: []string{"0.0.0.0/0"}},
+		{Name: "bob", Address: "10.0.0.3/32", Tags: []string{"office"}},
+		{Name: "carol", Address: "10.0.0.4/32"},
+		{Name: "dave", Address: "10.0.0.5/32"},
+		{Name: "erin", Address: "10.0.0.6/32"},
+	}
+
+	rules := map[string][]string{}
+	for _, finding := range ValidateProfile(profile) {
+		rules[finding.Rule] = append(rules[finding.Rule], finding.Client)
+	}
+	for rule, client := range map[string]string{
+		"endpoint-invalid":    "",
+		"allowed-ips-invalid": "bob",
+		"allowed-ips-overlap": "bob",
+		"dns-missing":         "alice",
+		"subnet-exhaustion":   "",
+	} {
+		if len(rules[rule]) != 1 || rules[rule][0] != client {
+			t.Fatalf("expected one %s finding for %q, got %v", rule, client, rules)
+		}
+	}
+
+	profile.Endpoint = "vpn.example.com:51820"
+	profile.DNS = []string{"10.0.0.1"}
+	profile.Subnet = "10.0.0.0/24"
+	profile.Policies = nil
+	for _, finding := range ValidateProfile(profile) {
+		switch finding.Rule {
+		case "endpoint-invalid", "dns-missing", "subnet-exhaustion", "allowed-ips-invalid", "allowed-ips-overlap":
+			t.Fatalf("unexpected finding after fixing the profile: %+v", finding)
+		}
+	}
+}
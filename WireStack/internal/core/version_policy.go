@@ -0,0 +1,122 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions taken on clients whose reported app version is below the minimum.
+const (
+	// VersionPolicyWarn only flags outdated clients in status and list-clients.
+	VersionPolicyWarn = "warn"
+	// VersionPolicyDisable also disables them until they report a newer version.
+	VersionPolicyDisable = "disable"
+)
+
+// DisabledOutdated is the DisabledReason of clients disabled by the version policy.
+const DisabledOutdated = "outdated"
+
+// VersionPolicy is the minimum app version a server's clients must report.
+type VersionPolicy struct {
+	MinAppVersion string `json:"min_app_version"`
+	Action        string `json:"action"`
+}
+
+// SetVersionPolicy validates and stores the server's version policy. An empty
+// action means VersionPolicyWarn.
+func SetVersionPolicy(profile *ServerProfile, minAppVersion, action string) error {
+	minAppVersion = strings.TrimSpace(minAppVersion)
+	if minAppVersion == "" {
+		return fmt.Errorf("minimum app version is empty")
+	}
+	if _, err := parseVersion(minAppVersion); err != nil {
+		return err
+	}
+	if action == "" {
+		action = VersionPolicyWarn
+	}
+	if action != VersionPolicyWarn && action != VersionPolicyDisable {
+		return fmt.Errorf("unsupported version policy action %q (want %s or %s)", action, VersionPolicyWarn, VersionPolicyDisable)
+	}
+	profile.VersionPolicy = &VersionPolicy{MinAppVersion: minAppVersion, Action: action}
+	return nil
+}
+
+// OutdatedReason explains why the client's last report is below the server's
+// minimum app version, or returns "" when it is not. Clients that have not
+// reported a version are never outdated.
+func OutdatedReason(profile *ServerProfile, client ClientProfile) string {
+	if profile.VersionPolicy == nil || client.Platform == nil || client.Platform.AppVersion == "" {
+		return ""
+	}
+	if CompareVersions(client.Platform.AppVersion, profile.VersionPolicy.MinAppVersion) >= 0 {
+		return ""
+	}
+	return fmt.Sprintf("app version %s is below the minimum %s", client.Platform.AppVersion, profile.VersionPolicy.MinAppVersion)
+}
+
+// EnforceVersionPolicy applies a disable policy to every client: outdated
+// enabled clients are disabled, and clients it disabled earlier that now
+// report a compliant version are re-enabled unless they have expired by now.
+// It returns the names of both. Warn policies and clients disabled for other
+// reasons are left alone.
+func EnforceVersionPolicy(profile *ServerProfile, now time.Time) (disabled, enabled []string) {
+	policy := profile.VersionPolicy
+	for idx := range profile.Clients {
+		client := &profile.Clients[idx]
+		outdated := policy != nil && policy.Action == VersionPolicyDisable && OutdatedReason(profile, *client) != ""
+		switch {
+		case outdated && !client.Disabled:
+			client.Disabled, client.DisabledReason = true, DisabledOutdated
+			disabled = append(disabled, client.Name)
+		case !outdated && client.Disabled && client.DisabledReason == DisabledOutdated && !ClientExpired(*client, now):
+			client.Disabled, client.DisabledReason = false, ""
+			enabled = append(enabled, client.Name)
+		}
+	}
+	return disabled, enabled
+}
+
+// CompareVersions compares dotted versions such as 1.0.20231018 or v2.1-beta
+// component by component, numerically where both components are numbers. It
+// returns -1, 0, or 1. Missing components count as zero.
+func CompareVersions(a, b string) int {
+	left, errLeft := parseVersion(a)
+	right, errRight := parseVersion(b)
+	if errLeft != nil || errRight != nil {
+		return strings.Compare(a, b)
+	}
+	for idx := 0; idx < len(left) || idx < len(right); idx++ {
+		l, r := "0", "0"
+		if idx < len(left) {
+			l = left[idx]
+		}
+		if idx < len(right) {
+			r = right[idx]
+		}
+		ln, lerr := strconv.ParseUint(l, 10, 64)
+		rn, rerr := strconv.ParseUint(r, 10, 64)
+		switch {
+		case lerr == nil && rerr == nil && ln != rn:
+			if ln < rn {
+				return -1
+			}
+			return 1
+		case (lerr != nil || rerr != nil) && l != r:
+			return strings.Compare(l, r)
+		}
+	}
+	return 0
+}
+
+// parseVersion splits a version into its components, dropping a leading "v".
+func parseVersion(version string) ([]string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	parts := strings.FieldsFunc(trimmed, func(r rune) bool { return r == '.' || r == '-' || r == '+' })
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	return parts, nil
+}
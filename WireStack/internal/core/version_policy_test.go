@@ -0,0 +1,83 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.20231018", "1.0.20230707", 1},
+		{"1.2", "1.10", -1},
+		{"v2.0", "2", 0},
+		{"1.0.0", "1", 0},
+		{"1.0-beta", "1.0-rc", -1},
+		{"0.9", "1.0", -1},
+	}
+	for _, tc := range cases {
+		if got := CompareVersions(tc.a, tc.b); got != tc.want {
+			t.Fatalf("CompareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestEnforceVersionPolicy(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	report := func(version string) *ClientPlatform { return &ClientPlatform{AppVersion: version} }
+	profile := &ServerProfile{Clients: []ClientProfile{
+		{Name: "current", Platform: report("1.2.0")},
+		{Name: "old", Platform: report("1.1.9")},
+		{Name: "unreported"},
+		{Name: "lapsed", Platform: report("1.0"), ExpiresAt: &expired},
+		{Name: "expired", Platform: report("1.0"), Disabled: true},
+	}}
+
+	if err := SetVersionPolicy(profile, "1.2", "block"); err == nil {
+		t.Fatalf("expected an unknown action to be rejected")
+	}
+	if err := SetVersionPolicy(profile, " ", ""); err == nil {
+		t.Fatalf("expected an empty version to be rejected")
+	}
+	if err := SetVersionPolicy(profile, "1.2", ""); err != nil || profile.VersionPolicy.Action != VersionPolicyWarn {
+		t.Fatalf("SetVersionPolicy: %v, %+v", err, profile.VersionPolicy)
+	}
+	if reason := OutdatedReason(profile, profile.Clients[1]); reason != "app version 1.1.9 is below the minimum 1.2" {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+	if OutdatedReason(profile, profile.Clients[0]) != "" || OutdatedReason(profile, profile.Clients[2]) != "" {
+		t.Fatalf("expected current and unreported clients not to be outdated")
+	}
+	if disabled, enabled := EnforceVersionPolicy(profile, now); len(disabled)+len(enabled) != 0 {
+		t.Fatalf("expected a warn policy to change nothing, got %v %v", disabled, enabled)
+	}
+
+	if err := SetVersionPolicy(profile, "1.2", VersionPolicyDisable); err != nil {
+		t.Fatalf("SetVersionPolicy: %v", err)
+	}
+	disabled, enabled := EnforceVersionPolicy(profile, now)
+	if len(disabled) != 2 || disabled[0] != "old" || disabled[1] != "lapsed" || len(enabled) != 0 {
+		t.Fatalf("expected old and lapsed to be disabled, got %v %v", disabled, enabled)
+	}
+	if !profile.Clients[1].Disabled || profile.Clients[1].DisabledReason != DisabledOutdated {
+		t.Fatalf("expected old to be disabled by the policy: %+v", profile.Clients[1])
+	}
+
+	profile.Clients[1].Platform = report("1.3")
+	profile.Clients[3].Platform = report("1.3")
+	profile.Clients[4].Platform = report("1.3")
+	disabled, enabled = EnforceVersionPolicy(profile, now)
+	if len(disabled) != 0 || len(enabled) != 1 || enabled[0] != "old" {
+		t.Fatalf("expected old to be re-enabled and expired clients left alone, got %v %v", disabled, enabled)
+	}
+
+	profile.VersionPolicy = nil
+	profile.Clients[1].Platform = report("1.0")
+	EnforceVersionPolicy(profile, now)
+	if profile.Clients[1].Disabled {
+		t.Fatalf("expected no policy to disable nothing")
+	}
+}
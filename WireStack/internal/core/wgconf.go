@@ -0,0 +1,112 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// ConfigEntry is a single "Key = Value" line of a WireGuard config file.
+type ConfigEntry struct {
+	Key   string
+	Value string
+}
+
+// ConfigSection is an [Interface] or [Peer] section with its entries in file order.
+type ConfigSection struct {
+	Name    string
+	Entries []ConfigEntry
+}
+
+// WGConfig is a parsed wg-quick style configuration file.
+type WGConfig struct {
+	Interface ConfigSection
+	Peers     []ConfigSection
+}
+
+// Get returns the last value for key in the section, matching keys case-insensitively.
+func (s ConfigSection) Get(key string) string {
+	value := ""
+	for _, entry := range s.Entries {
+		if strings.EqualFold(entry.Key, key) {
+			value = entry.Value
+		}
+	}
+	return value
+}
+
+// List returns the comma separated values for key, merged across repeated lines.
+func (s ConfigSection) List(key string) []string {
+	var values []string
+	for _, entry := range s.Entries {
+		if !strings.EqualFold(entry.Key, key) {
+			continue
+		}
+		for _, item := range strings.Split(entry.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// All returns every value for key in file order, without splitting on commas.
+func (s ConfigSection) All(key string) []string {
+	var values []string
+	for _, entry := range s.Entries {
+		if strings.EqualFold(entry.Key, key) {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// ParseConfig parses the text of a WireGuard configuration file.
+func ParseConfig(text string) (*WGConfig, error) {
+	config := &WGConfig{}
+	var current *ConfigSection
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		// Like wg-quick and wg(8), # starts a comment anywhere on a line.
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			switch strings.ToLower(name) {
+			case "interface":
+				config.Interface.Name = "Interface"
+				current = &config.Interface
+			case "peer":
+				config.Peers = append(config.Peers, ConfigSection{Name: "Peer"})
+				current = &config.Peers[len(config.Peers)-1]
+			default:
+				return nil, fmt.Errorf("line %d: unknown section [%s]", lineNumber, name)
+			}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: entry outside of a section", lineNumber)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected Key = Value", lineNumber)
+		}
+		current.Entries = append(current.Entries, ConfigEntry{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if config.Interface.Name == "" {
+		return nil, fmt.Errorf("config has no [Interface] section")
+	}
+	return config, nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigAndStrip(t *testing.T) {
+	text := `[Interface]
+PrivateKey = priv
+Address = 10.0.0.2/32, fd42:1::2/128
+DNS = 1.1.1.1
+PostUp = echo up # trailing comment
+MTU = 1380
+
+[Peer]
+PublicKey = srv-pub
+AllowedIPs = 0.0.0.0/0
+AllowedIPs = ::/0
+Endpoint = 203.0.113.1:51820
+`
+	config, err := ParseConfig(text)
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if got := config.Interface.List("Address"); len(got) != 2 || got[1] != "fd42:1::2/128" {
+		t.Fatalf("unexpected addresses: %v", got)
+	}
+	if got := config.Interface.Get("postup"); got != "echo up" {
+		t.Fatalf("expected case-insensitive lookup without comment, got %q", got)
+	}
+	if len(config.Peers) != 1 || len(config.Peers[0].List("AllowedIPs")) != 2 {
+		t.Fatalf("expected repeated AllowedIPs to merge: %+v", config.Peers)
+	}
+	if !usesDefaultRoute(config) {
+		t.Fatalf("expected default route detection")
+	}
+
+	stripped := StripConfig(config)
+	for _, key := range []string{"Address", "DNS", "PostUp", "MTU"} {
+		if strings.Contains(stripped, key+" =") {
+			t.Fatalf("stripped config still contains %s: %s", key, stripped)
+		}
+	}
+	if !strings.Contains(stripped, "PrivateKey = priv\n") || !strings.Contains(stripped, "Endpoint = 203.0.113.1:51820\n") {
+		t.Fatalf("stripped config lost wg keys: %s", stripped)
+	}
+
+	if _, err := ParseConfig("PrivateKey = x\n"); err == nil {
+		t.Fatalf("expected error for entry outside of a section")
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	if _, err := NewBackend(BackendWGQuick); err != nil {
+		t.Fatalf("NewBackend wg-quick: %v", err)
+	}
+	if _, err := NewBackend("bogus"); err == nil {
+		t.Fatalf("expected unknown backend error")
+	}
+	if name := ConfigInterfaceName("/tmp/runtime/homelab.conf"); name != "homelab" {
+		t.Fatalf("unexpected interface name %s", name)
+	}
+}
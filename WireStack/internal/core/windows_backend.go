@@ -0,0 +1,72 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"wirestack/internal/utils"
+)
+
+// windowsTunnelNameLimit is the longest tunnel name WireGuard for Windows accepts.
+const windowsTunnelNameLimit = 32
+
+// windowsBackend installs each config as a tunnel service with WireGuard for
+// Windows, which is what its GUI does when a tunnel is activated.
+type windowsBackend struct{}
+
+// Up runs `wireguard.exe /installtunnelservice` on the config. The service
+// starts immediately and again at boot until Down removes it.
+func (windowsBackend) Up(configPath string) (string, error) {
+	config, iface, err := loadConfigFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	if UsesAmnezia(config) {
+		return "", fmt.Errorf("%s has AmneziaWG parameters, which WireGuard for Windows does not support; import it into the AmneziaWG app instead", configPath)
+	}
+	if err := validateWindowsTunnelName(iface); err != nil {
+		return "", err
+	}
+	// The service runs as LocalSystem with its own working directory.
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", err
+	}
+	return utils.RunCommand(wireguardExe(), "/installtunnelservice", absPath)
+}
+
+// Down runs `wireguard.exe /uninstalltunnelservice`, which stops the tunnel
+// and removes its service.
+func (windowsBackend) Down(configPath string) (string, error) {
+	iface := ConfigInterfaceName(configPath)
+	if err := validateWindowsTunnelName(iface); err != nil {
+		return "", err
+	}
+	return utils.RunCommand(wireguardExe(), "/uninstalltunnelservice", iface)
+}
+
+// validateWindowsTunnelName checks a tunnel name, taken from the config file
+// name, against the rules WireGuard for Windows enforces.
+func validateWindowsTunnelName(name string) error {
+	if name == "" || len(name) > windowsTunnelNameLimit || unsafeNameChars.MatchString(name) {
+		return fmt.Errorf("tunnel name %q is not valid on Windows: use at most %d letters, digits, and _=+.- (shorten the server or client name)", name, windowsTunnelNameLimit)
+	}
+	return nil
+}
+
+// wireguardExe returns wireguard.exe from PATH, or from the default install
+// directory, which the installer does not add to PATH.
+func wireguardExe() string {
+	if path, err := exec.LookPath("wireguard.exe"); err == nil {
+		return path
+	}
+	if programFiles := os.Getenv("ProgramFiles"); programFiles != "" {
+		path := filepath.Join(programFiles, "WireGuard", "wireguard.exe")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return "wireguard.exe"
+}
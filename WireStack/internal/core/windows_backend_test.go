@@ -0,0 +1,50 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWindowsBackend(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		if _, err := NewBackend(BackendWindows); err == nil {
+			t.Fatalf("expected the windows backend to be refused on %s", runtime.GOOS)
+		}
+	}
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "wireguard.log")
+	script := "#!/bin/sh\necho \"$@\" >> " + logPath + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "wireguard.exe"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake wireguard.exe: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	configPath := filepath.Join(dir, "client-prod-laptop.conf")
+	if err := os.WriteFile(configPath, []byte("[Interface]\nPrivateKey = priv\nAddress = 10.0.0.2/32\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	backend := windowsBackend{}
+	if _, err := backend.Up(configPath); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := backend.Down(configPath); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	got := strings.Join(readLog(t, logPath), "\n")
+	want := "/installtunnelservice " + configPath + "\n/uninstalltunnelservice client-prod-laptop"
+	if got != want {
+		t.Fatalf("unexpected wireguard.exe calls:\n%s\nwant:\n%s", got, want)
+	}
+
+	longPath := filepath.Join(dir, "client-production-eu-west-laptop-of-alex.conf")
+	if err := os.WriteFile(longPath, []byte("[Interface]\nPrivateKey = priv\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := backend.Up(longPath); err == nil || !strings.Contains(err.Error(), "not valid on Windows") {
+		t.Fatalf("expected a tunnel name over 32 characters to be rejected, got %v", err)
+	}
+}
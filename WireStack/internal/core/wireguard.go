@@ -1,83 +1,262 @@
 package core
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"golang.org/x/crypto/curve25519"
+
 	"wirestack/internal/utils"
 )
 
-// GenerateKeyPair uses the system WireGuard tools to produce a key pair.
+// GenerateKeyPair uses the system WireGuard tools to produce a key pair,
+// falling back to generating it in-process when wg is not installed.
 func GenerateKeyPair() (string, string, error) {
-	privateKey, err := utils.RunCommand("wg", "genkey")
+	return GenerateKeyPairContext(context.Background())
+}
+
+// GenerateKeyPairContext is GenerateKeyPair stopping wg when ctx is done.
+func GenerateKeyPairContext(ctx context.Context) (string, string, error) {
+	privateKey, err := utils.RunCommandContext(ctx, "wg", "genkey")
+	if errors.Is(err, exec.ErrNotFound) {
+		return generateKeyPairNative()
+	}
 	if err != nil {
 		return "", "", err
 	}
-	publicKey, err := utils.RunCommandWithInput(privateKey, "wg", "pubkey")
+	publicKey, err := utils.RunCommandWithInputContext(ctx, privateKey, "wg", "pubkey")
 	if err != nil {
 		return "", "", err
 	}
 	return privateKey, publicKey, nil
 }
 
-// BuildClientConfig renders a WireGuard client configuration for the provided client.
+// generateKeyPairNative produces a key pair the same way wg genkey and wg pubkey do.
+func generateKeyPairNative() (string, string, error) {
+	private := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(private); err != nil {
+		return "", "", err
+	}
+	private[0] &= 248
+	private[31] = (private[31] & 127) | 64
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(private), base64.StdEncoding.EncodeToString(public), nil
+}
+
+// InterfaceName returns the WireGuard interface used by the server: the external
+// interface when configured, otherwise the name wg-quick derives from the runtime config.
+func InterfaceName(profile *ServerProfile) string {
+	if profile.ExternalInterface != "" {
+		return profile.ExternalInterface
+	}
+	return profile.Name
+}
+
+// InterfaceIsUp reports whether the named WireGuard interface currently exists.
+func InterfaceIsUp(iface string) bool {
+	return InterfaceIsUpContext(context.Background(), iface)
+}
+
+// InterfaceIsUpContext is InterfaceIsUp stopping wg when ctx is done, which
+// reports the interface as down.
+func InterfaceIsUpContext(ctx context.Context, iface string) bool {
+	_, err := utils.RunCommandContext(ctx, "wg", "show", iface)
+	return err == nil
+}
+
+// RemoveLivePeer drops a peer from a running interface using `wg set`.
+func RemoveLivePeer(iface, publicKey string) error {
+	return RemoveLivePeerContext(context.Background(), iface, publicKey)
+}
+
+// RemoveLivePeerContext is RemoveLivePeer stopping wg when ctx is done.
+func RemoveLivePeerContext(ctx context.Context, iface, publicKey string) error {
+	if publicKey == "" {
+		return fmt.Errorf("peer public key is empty")
+	}
+	_, err := utils.RunCommandContext(ctx, "wg", "set", iface, "peer", publicKey, "remove")
+	return err
+}
+
+// InterfacePublicKey reads the public key of an existing WireGuard interface.
+func InterfacePublicKey(iface string) (string, error) {
+	return InterfacePublicKeyContext(context.Background(), iface)
+}
+
+// InterfacePublicKeyContext is InterfacePublicKey stopping wg when ctx is done.
+func InterfacePublicKeyContext(ctx context.Context, iface string) (string, error) {
+	publicKey, err := utils.RunCommandContext(ctx, "wg", "show", iface, "public-key")
+	if err != nil {
+		return "", err
+	}
+	if publicKey == "" || publicKey == "(none)" {
+		return "", fmt.Errorf("interface %s has no private key configured", iface)
+	}
+	return publicKey, nil
+}
+
+// ApplyLivePeer adds or updates a client peer on a running interface using `wg set`.
+func ApplyLivePeer(iface string, client ClientProfile) error {
+	return ApplyLivePeerContext(context.Background(), iface, client)
+}
+
+// ApplyLivePeerContext is ApplyLivePeer stopping wg when ctx is done.
+func ApplyLivePeerContext(ctx context.Context, iface string, client ClientProfile) error {
+	if client.PublicKey == "" {
+		return fmt.Errorf("client %s has no public key", client.Name)
+	}
+	allowed := strings.Join(serverPeerAllowedIPs(client), ",")
+	_, err := utils.RunCommandContext(ctx, "wg", "set", iface, "peer", client.PublicKey, "allowed-ips", allowed)
+	return err
+}
+
+// RemoveLivePeers drops every client of the profile from its running interface.
+func RemoveLivePeers(profile *ServerProfile) error {
+	iface := InterfaceName(profile)
+	for _, client := range profile.Clients {
+		if err := RemoveLivePeer(iface, client.PublicKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildClientConfig renders a WireGuard client configuration for the provided client,
+// targeting Linux wg-quick.
 func BuildClientConfig(profile *ServerProfile, client ClientProfile) (string, error) {
+	return BuildClientConfigFor(profile, client, ClientRenderOptions{Target: TargetLinux})
+}
+
+// BuildServerConfig renders a WireGuard server configuration including peers.
+func BuildServerConfig(profile *ServerProfile) (string, error) {
 	if profile == nil {
 		return "", fmt.Errorf("server profile is nil")
 	}
-	if client.Name == "" {
-		return "", fmt.Errorf("client name is empty")
+	if profile.ExternalInterface != "" {
+		return "", fmt.Errorf("server %s uses external interface %s; its config is managed elsewhere", profile.Name, profile.ExternalInterface)
+	}
+	host, port, err := net.SplitHostPort(profile.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
+	}
+	if host == "" || port == "" {
+		return "", fmt.Errorf("endpoint must include host and port")
 	}
 
 	builder := &strings.Builder{}
 	fmt.Fprintf(builder, "[Interface]\n")
-	fmt.Fprintf(builder, "PrivateKey = %s\n", client.PrivateKey)
-	fmt.Fprintf(builder, "Address = %s\n", client.Address)
-	if len(profile.DNS) > 0 {
-		fmt.Fprintf(builder, "DNS = %s\n", strings.Join(profile.DNS, ", "))
+	writeDescription(builder, profile.Description)
+	fmt.Fprintf(builder, "Address = %s\n", strings.Join(ServerAddresses(profile), ", "))
+	fmt.Fprintf(builder, "PrivateKey = %s\n", profile.ServerPrivateKey)
+	fmt.Fprintf(builder, "ListenPort = %s\n", port)
+	if profile.MTU > 0 {
+		fmt.Fprintf(builder, "MTU = %d\n", profile.MTU)
+	}
+	var amnezia []string
+	if profile.Amnezia != nil && profile.Amnezia.NeedsServer() {
+		amnezia = profile.Amnezia.Lines()
+		for _, line := range amnezia {
+			fmt.Fprintf(builder, "%s\n", line)
+		}
+	}
+	fmt.Fprintf(builder, "SaveConfig = false\n")
+	postUp, postDown := ServerHooks(profile)
+	for _, hook := range postUp {
+		fmt.Fprintf(builder, "PostUp = %s\n", hook)
+	}
+	for _, hook := range postDown {
+		fmt.Fprintf(builder, "PostDown = %s\n", hook)
 	}
 	fmt.Fprintf(builder, "\n")
-	fmt.Fprintf(builder, "[Peer]\n")
-	fmt.Fprintf(builder, "PublicKey = %s\n", profile.ServerPublicKey)
-	fmt.Fprintf(builder, "AllowedIPs = %s\n", strings.Join(client.AllowedIPs, ", "))
-	fmt.Fprintf(builder, "Endpoint = %s\n", profile.Endpoint)
-	fmt.Fprintf(builder, "PersistentKeepalive = 25\n")
-	return builder.String(), nil
+	writeServerPeers(builder, profile)
+
+	data := ServerTemplateData{
+		Server:      profile.Name,
+		Description: profile.Description,
+		Annotations: profile.Annotations,
+		PrivateKey:  profile.ServerPrivateKey,
+		Addresses:   ServerAddresses(profile),
+		ListenPort:  port,
+		MTU:         profile.MTU,
+		Amnezia:     amnezia,
+		PostUp:      postUp,
+		PostDown:    postDown,
+		Peers:       []PeerTemplateData{},
+		Default:     builder.String(),
+	}
+	for _, client := range profile.Clients {
+		if client.Disabled {
+			continue
+		}
+		data.Peers = append(data.Peers, PeerTemplateData{
+			Client:      client.Name,
+			Description: client.Description,
+			Tags:        client.Tags,
+			Annotations: client.Annotations,
+			PublicKey:   client.PublicKey,
+			AllowedIPs:  serverPeerAllowedIPs(client),
+		})
+	}
+	return renderTemplate(ServerTemplateFile, data, data.Default)
 }
 
-// BuildServerConfig renders a WireGuard server configuration including peers.
-func BuildServerConfig(profile *ServerProfile) (string, error) {
+// BuildServerSyncConfig renders the stripped server configuration accepted by
+// `wg syncconf`, omitting wg-quick only keys such as Address and SaveConfig.
+func BuildServerSyncConfig(profile *ServerProfile) (string, error) {
 	if profile == nil {
 		return "", fmt.Errorf("server profile is nil")
 	}
-	host, port, err := net.SplitHostPort(profile.Endpoint)
+	if profile.ExternalInterface != "" {
+		return "", fmt.Errorf("server %s uses external interface %s; its config is managed elsewhere", profile.Name, profile.ExternalInterface)
+	}
+	_, port, err := net.SplitHostPort(profile.Endpoint)
 	if err != nil {
 		return "", fmt.Errorf("invalid endpoint %s: %w", profile.Endpoint, err)
 	}
-	if host == "" || port == "" {
+	if port == "" {
 		return "", fmt.Errorf("endpoint must include host and port")
 	}
 
 	builder := &strings.Builder{}
 	fmt.Fprintf(builder, "[Interface]\n")
-	fmt.Fprintf(builder, "Address = %s\n", profile.Address)
+	writeDescription(builder, profile.Description)
 	fmt.Fprintf(builder, "PrivateKey = %s\n", profile.ServerPrivateKey)
 	fmt.Fprintf(builder, "ListenPort = %s\n", port)
-	fmt.Fprintf(builder, "SaveConfig = false\n")
 	fmt.Fprintf(builder, "\n")
+	writeServerPeers(builder, profile)
+	return builder.String(), nil
+}
+
+// writeServerPeers renders one [Peer] section per enabled client of the profile.
+func writeServerPeers(builder *strings.Builder, profile *ServerProfile) {
 	for _, client := range profile.Clients {
+		if client.Disabled {
+			continue
+		}
 		fmt.Fprintf(builder, "[Peer]\n")
+		writeDescription(builder, client.Description)
 		fmt.Fprintf(builder, "PublicKey = %s\n", client.PublicKey)
-		allowed := client.AllowedIPs
-		if len(allowed) == 0 {
-			allowed = []string{client.Address}
-		}
-		fmt.Fprintf(builder, "AllowedIPs = %s\n", strings.Join(allowed, ", "))
+		fmt.Fprintf(builder, "AllowedIPs = %s\n", strings.Join(serverPeerAllowedIPs(client), ", "))
 		fmt.Fprintf(builder, "\n")
 	}
-	return builder.String(), nil
+}
+
+// serverPeerAllowedIPs returns the AllowedIPs used for a client on the server side:
+// the client's own tunnel addresses. The client's AllowedIPs describe what it routes
+// into the tunnel and must not be reused here, or full-tunnel clients would overlap.
+func serverPeerAllowedIPs(client ClientProfile) []string {
+	return ClientAddresses(client)
 }
 
 // WriteServerConfig materializes the server config to the runtime directory.
@@ -97,8 +276,13 @@ func WriteServerConfig(profile *ServerProfile) (string, error) {
 }
 
 // WriteClientConfig materializes the client config to the runtime directory.
+// On Windows it is rendered for the Windows client, which runs no hooks.
 func WriteClientConfig(profile *ServerProfile, client ClientProfile) (string, error) {
-	config, err := BuildClientConfig(profile, client)
+	target := TargetLinux
+	if runtime.GOOS == "windows" {
+		target = TargetWindows
+	}
+	config, err := BuildClientConfigFor(profile, client, ClientRenderOptions{Target: target})
 	if err != nil {
 		return "", err
 	}
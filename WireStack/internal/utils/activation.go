@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to a
+// socket-activated service (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// activatedSocket is one socket passed by systemd.
+type activatedSocket struct {
+	name string
+	file *os.File
+	// taken is set once a listener was made from the socket.
+	taken bool
+}
+
+var (
+	activationMu     sync.Mutex
+	activationParsed bool
+	activatedSockets []*activatedSocket
+)
+
+// ActivatedListener returns a listener for a socket systemd passed with
+// socket activation (LISTEN_FDS). An empty name takes the next socket not
+// used yet, in the order the socket unit lists them; otherwise the next one
+// whose FileDescriptorName matches. Each socket can be taken once.
+func ActivatedListener(name string) (net.Listener, error) {
+	activationMu.Lock()
+	defer activationMu.Unlock()
+	if !activationParsed {
+		activatedSockets = parseListenFDs()
+		activationParsed = true
+	}
+	if len(activatedSockets) == 0 {
+		return nil, fmt.Errorf("no sockets were passed by systemd (LISTEN_FDS is not set for this process)")
+	}
+	for _, socket := range activatedSockets {
+		if socket.taken || (name != "" && socket.name != name) {
+			continue
+		}
+		listener, err := net.FileListener(socket.file)
+		if err != nil {
+			return nil, fmt.Errorf("socket %s passed by systemd: %w", socket.name, err)
+		}
+		// The listener holds its own descriptor.
+		socket.file.Close()
+		socket.taken = true
+		return listener, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("every socket passed by systemd is already in use")
+	}
+	return nil, fmt.Errorf("systemd passed no unused socket named %q", name)
+}
+
+// parseListenFDs reads the sockets systemd passed to this process and unsets
+// the variables describing them, so child processes do not see them.
+func parseListenFDs() []*activatedSocket {
+	pid, count, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	// LISTEN_PID guards against variables inherited from a parent.
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return nil
+	}
+	nameList := strings.Split(names, ":")
+	sockets := make([]*activatedSocket, 0, n)
+	for i := 0; i < n; i++ {
+		name := "unknown"
+		if i < len(nameList) && nameList[i] != "" {
+			name = nameList[i]
+		}
+		sockets = append(sockets, &activatedSocket{name: name, file: os.NewFile(uintptr(listenFDsStart+i), name)})
+	}
+	return sockets
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestActivatedListener(t *testing.T) {
+	if os.Getenv("WIRESTACK_ACTIVATION_CHILD") == "1" {
+		// systemd sets LISTEN_PID after forking; the parent cannot know it.
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		os.Exit(serveActivatedSocket())
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("socket activation is a systemd feature")
+	}
+	if _, err := ActivatedListener(""); err == nil {
+		t.Fatalf("expected an error without LISTEN_FDS")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	file, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	defer file.Close()
+
+	child := exec.Command(os.Args[0], "-test.run=^TestActivatedListener$")
+	child.Env = append(os.Environ(), "WIRESTACK_ACTIVATION_CHILD=1", "LISTEN_FDS=1", "LISTEN_FDNAMES=api")
+	child.ExtraFiles = []*os.File{file}
+	output, err := child.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe: %v", err)
+	}
+	if err := child.Start(); err != nil {
+		t.Fatalf("start child: %v", err)
+	}
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	reply, _ := io.ReadAll(conn)
+	logged, _ := io.ReadAll(output)
+	if err := child.Wait(); err != nil {
+		t.Fatalf("child failed: %v\n%s", err, logged)
+	}
+	if string(reply) != "api" {
+		t.Fatalf("expected the activated listener to answer, got %q\n%s", reply, logged)
+	}
+}
+
+// serveActivatedSocket runs in the child process: it answers one connection
+// on the socket named api and checks that the socket cannot be taken twice.
+func serveActivatedSocket() int {
+	if _, err := ActivatedListener("bench"); err == nil {
+		fmt.Println("expected no socket named bench")
+		return 1
+	}
+	listener, err := ActivatedListener("api")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer listener.Close()
+	if _, err := ActivatedListener(""); err == nil {
+		fmt.Println("expected the socket to be taken only once")
+		return 1
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		fmt.Println("expected LISTEN_FDS to be unset")
+		return 1
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "api")
+	return 0
+}
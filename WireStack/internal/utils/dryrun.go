@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	dryRunMu sync.Mutex
+	// dryRunOut receives what would have been done while a dry run is active.
+	dryRunOut io.Writer
+	// dryRunFiles holds files "written" during the dry run so later reads see them.
+	dryRunFiles map[string][]byte
+)
+
+// SetDryRun makes RunCommand, RunCommandWithInput, and WriteFile print what
+// they would do to out instead of doing it; nil turns the dry run off.
+// Commands report success with empty output. Files written during the dry run
+// are readable through ReadFile until it ends.
+func SetDryRun(out io.Writer) {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	dryRunOut = out
+	dryRunFiles = nil
+	if out != nil {
+		dryRunFiles = map[string][]byte{}
+	}
+}
+
+// DryRun reports whether a dry run is active.
+func DryRun() bool {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	return dryRunOut != nil
+}
+
+// dryRunCommand prints the command line when a dry run is active and reports
+// whether it did. Standard input is summarized since it may carry keys.
+func dryRunCommand(input string, name string, args ...string) bool {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	if dryRunOut == nil {
+		return false
+	}
+	line := ShellQuote(append([]string{name}, args...))
+	if input != "" {
+		line += fmt.Sprintf(" <<< (%d bytes on stdin)", len(input))
+	}
+	fmt.Fprintf(dryRunOut, "would run: %s\n", line)
+	return true
+}
+
+// dryRunWrite prints and keeps the file contents when a dry run is active and
+// reports whether it did.
+func dryRunWrite(path string, data []byte, perm os.FileMode) bool {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	if dryRunOut == nil {
+		return false
+	}
+	dryRunFiles[path] = append([]byte(nil), data...)
+	fmt.Fprintf(dryRunOut, "would write %s (mode %04o):\n%s", path, perm, data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		fmt.Fprintln(dryRunOut)
+	}
+	return true
+}
+
+// dryRunRead returns a file written during the dry run.
+func dryRunRead(path string) ([]byte, bool) {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	data, ok := dryRunFiles[path]
+	return data, ok
+}
+
+// ShellQuote joins args into a line that a POSIX shell would split back into
+// the same arguments.
+func ShellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for idx, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,+@%") == "" {
+			quoted[idx] = arg
+			continue
+		}
+		quoted[idx] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
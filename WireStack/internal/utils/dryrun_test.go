@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRunPrintsInsteadOfActing(t *testing.T) {
+	var out bytes.Buffer
+	SetDryRun(&out)
+	t.Cleanup(func() { SetDryRun(nil) })
+
+	path := filepath.Join(t.TempDir(), "wg0.conf")
+	if err := WriteFile(path, []byte("[Interface]\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote %s (%v)", path, err)
+	}
+	data, err := ReadFile(path)
+	if err != nil || string(data) != "[Interface]\n" {
+		t.Fatalf("ReadFile should see the dry run write, got %q (%v)", data, err)
+	}
+	if _, err := RunCommand("definitely-not-installed", "up", "it's here"); err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	if _, err := RunCommandWithInput("secret", "wg", "set", "wg0"); err != nil {
+		t.Fatalf("RunCommandWithInput: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"would write " + path + " (mode 0600):\n[Interface]\n",
+		`would run: definitely-not-installed up 'it'\''s here'`,
+		"would run: wg set wg0 <<< (6 bytes on stdin)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "secret") {
+		t.Fatalf("stdin leaked into the output:\n%s", got)
+	}
+
+	SetDryRun(nil)
+	if DryRun() {
+		t.Fatalf("dry run still active")
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Fatalf("dry run files should be discarded when it ends")
+	}
+}
@@ -2,9 +2,13 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // ExpandPath replaces a leading ~ with the current user's home directory.
@@ -22,7 +26,8 @@ func ExpandPath(path string) (string, error) {
 	return filepath.Join(home, path[1:]), nil
 }
 
-// EnsureDir creates the directory path with restrictive permissions if it does not already exist.
+// EnsureDir creates the directory path with restrictive permissions if it does
+// not already exist. Directories the permission policy shares get its group.
 func EnsureDir(path string) error {
 	if path == "" {
 		return fmt.Errorf("directory path is empty")
@@ -30,28 +35,147 @@ func EnsureDir(path string) error {
 	if err := os.MkdirAll(path, 0o700); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", path, err)
 	}
+	if _, gid := PolicyMode(path, 0o700, true); gid >= 0 {
+		if err := ApplyPermissionPolicy(path, 0o700, true); err != nil {
+			return fmt.Errorf("failed to set permissions of %s: %w", path, err)
+		}
+	}
 	return nil
 }
 
+// tempMarker appears in the names of the temporary files WriteFile renames
+// into place, as in ".server.json.tmp-123456".
+const tempMarker = ".tmp-"
+
 // WriteFile writes data to the given path creating parent directories as needed.
+// The data goes to a temporary file in the same directory, which is synced and
+// then renamed over path, so a crash leaves either the old or the new contents
+// and never a partial file. Paths that are not regular files, such as
+// /dev/stdout, are written directly. The permission policy may add group read
+// to perm.
 func WriteFile(path string, data []byte, perm os.FileMode) error {
 	if path == "" {
 		return fmt.Errorf("file path is empty")
 	}
-	if err := EnsureDir(filepath.Dir(path)); err != nil {
+	if dryRunWrite(path, data, perm) {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		// Replace the link's target rather than the link itself.
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		if err := os.WriteFile(path, data, perm); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+		return nil
+	}
+	dir := filepath.Dir(path)
+	if err := EnsureDir(dir); err != nil {
 		return err
 	}
-	if err := os.WriteFile(path, data, perm); err != nil {
+	if err := writeTempAndRename(path, data, perm); err != nil {
 		return fmt.Errorf("failed to write file %s: %w", path, err)
 	}
+	syncDir(dir)
 	return nil
 }
 
+// writeTempAndRename writes data to a synced temporary file beside path and
+// renames it over path, removing the temporary file on failure.
+func writeTempAndRename(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+tempMarker+"*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+	mode, gid := PolicyMode(path, perm, false)
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if gid >= 0 {
+		if err := tmp.Chown(-1, gid); err != nil {
+			return err
+		}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// syncDir flushes a directory entry change such as a rename. Not every
+// platform or filesystem supports it, so failures are ignored.
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_ = f.Sync()
+}
+
+// IsTempFile reports whether name is one of WriteFile's temporary files.
+func IsTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, tempMarker)
+}
+
+// RemoveStaleTempFiles deletes WriteFile temporary files under root that are
+// older than age, which a crash or kill between writing and renaming leaves
+// behind. The file they were meant to replace still holds its previous
+// contents. Younger files may belong to a write in progress and are kept. It
+// returns the paths removed.
+func RemoveStaleTempFiles(root string, age time.Duration) ([]string, error) {
+	var removed []string
+	cutoff := time.Now().Add(-age)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || !IsTempFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale temporary file %s: %w", path, err)
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	return removed, err
+}
+
 // ReadFile reads the contents of a file.
 func ReadFile(path string) ([]byte, error) {
 	if path == "" {
 		return nil, fmt.Errorf("file path is empty")
 	}
+	if data, ok := dryRunRead(path); ok {
+		return data, nil
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWriteFileReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lab.json")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := WriteFile(path, []byte("new"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Fatalf("expected new contents, got %q (%v)", data, err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+			t.Fatalf("expected mode 0600, got %04o", info.Mode().Perm())
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only lab.json, found %d entries", len(entries))
+	}
+}
+
+func TestWriteFileFollowsSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.conf")
+	link := filepath.Join(dir, "wg0.conf")
+	if err := os.WriteFile(target, []byte("old"), 0o600); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := WriteFile(link, []byte("new"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("link was replaced by a regular file (%v)", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Fatalf("expected the link target to be updated, got %q", data)
+	}
+}
+
+func TestRemoveStaleTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "servers", ".lab.json"+tempMarker+"123")
+	fresh := filepath.Join(dir, ".settings.json"+tempMarker+"456")
+	profile := filepath.Join(dir, "servers", "lab.json")
+	for _, path := range []string{stale, fresh, profile} {
+		if err := WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	removed, err := RemoveStaleTempFiles(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("RemoveStaleTempFiles: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != stale {
+		t.Fatalf("expected only %s removed, got %v", stale, removed)
+	}
+	for _, path := range []string{fresh, profile} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s should be kept: %v", path, err)
+		}
+	}
+	if removed, err := RemoveStaleTempFiles(filepath.Join(dir, "missing"), time.Minute); err != nil || len(removed) != 0 {
+		t.Fatalf("missing root should be a no-op, got %v (%v)", removed, err)
+	}
+}
+
+func TestWriteFileAppliesPermissionPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows files have no Unix group")
+	}
+	root := t.TempDir()
+	gid := os.Getgid()
+	SetPermissionPolicy(&PermissionPolicy{Root: root, GID: gid, Shared: []string{"servers", "config.json"}})
+	t.Cleanup(func() { SetPermissionPolicy(nil) })
+
+	for _, path := range []string{"servers/prod.json", "config.json", "ca/ca.key"} {
+		if err := WriteFile(filepath.Join(root, path), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("WriteFile %s: %v", path, err)
+		}
+	}
+	want := map[string]os.FileMode{
+		".":                 0o710,
+		"servers":           0o750,
+		"servers/prod.json": 0o640,
+		"config.json":       0o640,
+		"ca":                0o700,
+		"ca/ca.key":         0o600,
+	}
+	// EnsureDir only applies the policy to directories it is asked for.
+	if err := EnsureDir(root); err != nil {
+		t.Fatalf("EnsureDir: %v", err)
+	}
+	for path, mode := range want {
+		info, err := os.Stat(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("Stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Fatalf("expected %s to have mode %04o, got %04o", path, mode, info.Mode().Perm())
+		}
+		if mode&0o070 != 0 && FileGroup(info) != gid {
+			t.Fatalf("expected %s to be owned by group %d, got %d", path, gid, FileGroup(info))
+		}
+	}
+	if mode, gid := PolicyMode(filepath.Join(root, "..", "elsewhere"), 0o600, false); mode != 0o600 || gid != -1 {
+		t.Fatalf("expected paths outside the root to be left alone, got %04o and %d", mode, gid)
+	}
+}
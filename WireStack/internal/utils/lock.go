@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned (wrapped) by LockFile when the lock is still held by
+// another process after the timeout.
+var ErrLocked = errors.New("locked by another process")
+
+// errWouldBlock is returned by tryLockFile when another holder has the lock.
+var errWouldBlock = errors.New("lock is held")
+
+// lockPollInterval is how often LockFile retries a held lock.
+const lockPollInterval = 50 * time.Millisecond
+
+// LockFile takes an exclusive advisory lock on path, creating it if needed,
+// and waits up to timeout for other holders. The lock belongs to the open
+// file, so separate calls exclude each other even within one process, and
+// the operating system releases it if the process dies. The returned
+// function releases it.
+func LockFile(path string, timeout time.Duration) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return LockFileContext(ctx, path)
+}
+
+// LockFileContext is LockFile waiting until ctx is done instead of a timeout.
+// A deadline reports ErrLocked; cancellation reports the context error.
+func LockFileContext(ctx context.Context, path string) (func(), error) {
+	if err := EnsureDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+	}
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for {
+		err := tryLockFile(file)
+		if err == nil {
+			return func() {
+				_ = unlockFile(file)
+				_ = file.Close()
+			}, nil
+		}
+		if !errors.Is(err, errWouldBlock) {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		select {
+		case <-ctx.Done():
+			_ = file.Close()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("%s is %w", path, ErrLocked)
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFileExcludesOtherHolders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "lab.lock")
+	unlock, err := LockFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("LockFile: %v", err)
+	}
+	if _, err := LockFile(path, 100*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked while held, got %v", err)
+	}
+
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(released)
+		unlock()
+	}()
+	again, err := LockFile(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("LockFile after release: %v", err)
+	}
+	select {
+	case <-released:
+	default:
+		t.Fatalf("lock was acquired before it was released")
+	}
+	again()
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP (1900) and Unix (1970) epochs.
+const ntpEpochOffset = 2208988800
+
+// ClockOffset queries an NTP server with a single SNTP request and returns how far
+// the local clock is behind (positive) or ahead (negative) of the server.
+func ClockOffset(server string, timeout time.Duration) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach NTP server %s: %w", server, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+
+	request := make([]byte, 48)
+	request[0] = 0x1B // LI 0, version 3, mode 3 (client)
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, fmt.Errorf("failed to query NTP server %s: %w", server, err)
+	}
+	response := make([]byte, 48)
+	if _, err := conn.Read(response); err != nil {
+		return 0, fmt.Errorf("no response from NTP server %s: %w", server, err)
+	}
+	received := time.Now()
+
+	if mode := response[0] & 0x07; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP response mode %d from %s", mode, server)
+	}
+	serverReceive := ntpTime(response[32:40])
+	serverTransmit := ntpTime(response[40:48])
+	if serverTransmit.IsZero() {
+		return 0, fmt.Errorf("NTP server %s returned an empty timestamp", server)
+	}
+	return (serverReceive.Sub(sent) + serverTransmit.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes a 64-bit NTP timestamp.
+func ntpTime(raw []byte) time.Time {
+	seconds := binary.BigEndian.Uint32(raw[0:4])
+	fraction := binary.BigEndian.Uint32(raw[4:8])
+	if seconds == 0 && fraction == 0 {
+		return time.Time{}
+	}
+	nanos := (int64(fraction) * 1e9) >> 32
+	return time.Unix(int64(seconds)-ntpEpochOffset, nanos)
+}
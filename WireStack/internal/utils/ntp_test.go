@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClockOffsetAgainstFakeServer(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	defer conn.Close()
+
+	const skew = 90 * time.Second
+	go func() {
+		buf := make([]byte, 48)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		now := time.Now().Add(skew)
+		reply := make([]byte, 48)
+		reply[0] = 0x1C // version 3, mode 4 (server)
+		putNTPTime(reply[32:40], now)
+		putNTPTime(reply[40:48], now)
+		_, _ = conn.WriteTo(reply, addr)
+	}()
+
+	offset, err := ClockOffset(conn.LocalAddr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("ClockOffset: %v", err)
+	}
+	if diff := offset - skew; diff > time.Second || diff < -time.Second {
+		t.Fatalf("expected offset near %s, got %s", skew, offset)
+	}
+}
+
+func putNTPTime(dst []byte, at time.Time) {
+	binary.BigEndian.PutUint32(dst[0:4], uint32(at.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(dst[4:8], uint32((int64(at.Nanosecond())<<32)/1e9))
+}
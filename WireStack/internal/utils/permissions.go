@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PermissionPolicy shares part of a directory tree with a group. Paths below
+// Root that are Shared, and everything under them, become readable by the
+// group GID, and the directories leading to them become traversable by it.
+// Everything else keeps the owner-only mode its writer chose.
+type PermissionPolicy struct {
+	Root string
+	GID  int
+	// Shared are slash-separated paths relative to Root, such as "servers".
+	Shared []string
+}
+
+var (
+	policyMu sync.RWMutex
+	// policy is applied by WriteFile and EnsureDir; nil keeps writers' modes.
+	policy *PermissionPolicy
+)
+
+// SetPermissionPolicy installs the policy WriteFile and EnsureDir apply; nil
+// removes it.
+func SetPermissionPolicy(p *PermissionPolicy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policy = p
+}
+
+// PolicyMode returns the mode path should have given mode, the owner-only mode
+// its writer chose, and the group that should own it, or -1 when the policy
+// leaves the group alone.
+func PolicyMode(path string, mode os.FileMode, dir bool) (os.FileMode, int) {
+	policyMu.RLock()
+	p := policy
+	policyMu.RUnlock()
+	if p == nil {
+		return mode, -1
+	}
+	rel, err := filepath.Rel(p.Root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return mode, -1
+	}
+	rel = filepath.ToSlash(rel)
+	for _, shared := range p.Shared {
+		if rel == shared || strings.HasPrefix(rel, shared+"/") {
+			if dir {
+				return mode | 0o050, p.GID
+			}
+			// Group read only where the owner can read; never group write.
+			return mode | (mode&0o400)>>3, p.GID
+		}
+	}
+	if dir {
+		for _, shared := range p.Shared {
+			if rel == "." || strings.HasPrefix(shared, rel+"/") {
+				return mode | 0o010, p.GID
+			}
+		}
+	}
+	return mode, -1
+}
+
+// ApplyPermissionPolicy sets the mode and group of an existing path as
+// PolicyMode decides, starting from the owner-only mode base.
+func ApplyPermissionPolicy(path string, base os.FileMode, dir bool) error {
+	mode, gid := PolicyMode(path, base, dir)
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	if gid >= 0 {
+		return os.Chown(path, -1, gid)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileGroup returns the group id owning the file info describes, or -1 when
+// it is unknown.
+func FileGroup(info os.FileInfo) int {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Gid)
+	}
+	return -1
+}
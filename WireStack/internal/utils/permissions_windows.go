@@ -0,0 +1,10 @@
+//go:build windows
+
+package utils
+
+import "os"
+
+// FileGroup returns -1: Windows files have no Unix group.
+func FileGroup(info os.FileInfo) int {
+	return -1
+}
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CommandRecord is one external command run through RunCommand or
+// RunCommandWithInput and what it returned.
+type CommandRecord struct {
+	Command []string `json:"command"`
+	Stdin   string   `json:"stdin,omitempty"`
+	Output  string   `json:"output"`
+	// Error is the error message, empty when the command succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// CommandFixture is a recorded sequence of command interactions. Saved
+// fixtures use ${name} placeholders for machine specific values such as the
+// home directory, which are filled in again when the fixture is loaded.
+type CommandFixture struct {
+	Records []CommandRecord `json:"records"`
+}
+
+var (
+	recordMu sync.Mutex
+	// recording collects interactions while RecordCommands is active.
+	recording *CommandFixture
+	// replaying answers commands from a fixture instead of running them.
+	replaying *CommandReplay
+)
+
+// RecordCommands starts capturing every command run through RunCommand and
+// RunCommandWithInput. The returned function stops recording and returns
+// what was captured.
+func RecordCommands() func() *CommandFixture {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	fixture := &CommandFixture{}
+	recording = fixture
+	return func() *CommandFixture {
+		recordMu.Lock()
+		defer recordMu.Unlock()
+		if recording == fixture {
+			recording = nil
+		}
+		return fixture
+	}
+}
+
+// recordCommand appends an interaction when recording is active.
+func recordCommand(input, output string, err error, name string, args ...string) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	if recording == nil {
+		return
+	}
+	record := CommandRecord{Command: append([]string{name}, args...), Stdin: input, Output: output}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	recording.Records = append(recording.Records, record)
+}
+
+// SaveCommandFixture writes fixture as JSON, replacing each value of vars
+// with its ${name} placeholder. Longer values are replaced first so nested
+// paths keep their most specific placeholder.
+func SaveCommandFixture(path string, fixture *CommandFixture, vars map[string]string) error {
+	return WriteJSON(path, fixture.rewrite(placeholderReplacer(vars, true)), 0o600)
+}
+
+// LoadCommandFixture reads a fixture saved by SaveCommandFixture and expands
+// its ${name} placeholders from vars.
+func LoadCommandFixture(path string, vars map[string]string) (*CommandFixture, error) {
+	var fixture CommandFixture
+	if err := ReadJSON(path, &fixture); err != nil {
+		return nil, err
+	}
+	return fixture.rewrite(placeholderReplacer(vars, false)), nil
+}
+
+// rewrite returns a copy of the fixture with replacer applied to every string.
+func (f *CommandFixture) rewrite(replacer *strings.Replacer) *CommandFixture {
+	out := &CommandFixture{Records: make([]CommandRecord, len(f.Records))}
+	for idx, record := range f.Records {
+		command := make([]string, len(record.Command))
+		for argIdx, arg := range record.Command {
+			command[argIdx] = replacer.Replace(arg)
+		}
+		out.Records[idx] = CommandRecord{
+			Command: command,
+			Stdin:   replacer.Replace(record.Stdin),
+			Output:  replacer.Replace(record.Output),
+			Error:   replacer.Replace(record.Error),
+		}
+	}
+	return out
+}
+
+// placeholderReplacer maps values to ${name} placeholders, or placeholders
+// back to values when toPlaceholder is false.
+func placeholderReplacer(vars map[string]string, toPlaceholder bool) *strings.Replacer {
+	names := make([]string, 0, len(vars))
+	for name, value := range vars {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return len(vars[names[i]]) > len(vars[names[j]]) })
+	var pairs []string
+	for _, name := range names {
+		if toPlaceholder {
+			pairs = append(pairs, vars[name], "${"+name+"}")
+		} else {
+			pairs = append(pairs, "${"+name+"}", vars[name])
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// CommandReplay answers commands from a fixture, in order, without running
+// anything. A command that differs from the next record fails with an error
+// describing both.
+type CommandReplay struct {
+	mu       sync.Mutex
+	records  []CommandRecord
+	next     int
+	mismatch error
+}
+
+// ReplayCommands makes RunCommand and RunCommandWithInput answer from
+// fixture until the returned replay is stopped.
+func ReplayCommands(fixture *CommandFixture) *CommandReplay {
+	replay := &CommandReplay{records: fixture.Records}
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	replaying = replay
+	return replay
+}
+
+// Stop ends the replay and reports the first mismatch, or the records that
+// were never requested.
+func (r *CommandReplay) Stop() error {
+	recordMu.Lock()
+	if replaying == r {
+		replaying = nil
+	}
+	recordMu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mismatch != nil {
+		return r.mismatch
+	}
+	if r.next < len(r.records) {
+		var missing []string
+		for _, record := range r.records[r.next:] {
+			missing = append(missing, ShellQuote(record.Command))
+		}
+		return fmt.Errorf("%d recorded commands were not run: %s", len(missing), strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// answer returns the recorded result for the command.
+func (r *CommandReplay) answer(input string, command []string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line := ShellQuote(command)
+	if r.next >= len(r.records) {
+		err := fmt.Errorf("replay: unexpected command %s after the last record", line)
+		if r.mismatch == nil {
+			r.mismatch = err
+		}
+		return "", err
+	}
+	record := r.records[r.next]
+	if want := ShellQuote(record.Command); want != line || record.Stdin != input {
+		err := fmt.Errorf("replay: record %d expects %s but got %s", r.next+1, want, line)
+		if record.Stdin != input {
+			err = fmt.Errorf("replay: record %d expects different stdin for %s", r.next+1, line)
+		}
+		if r.mismatch == nil {
+			r.mismatch = err
+		}
+		return "", err
+	}
+	r.next++
+	if record.Error != "" {
+		return "", errors.New(record.Error)
+	}
+	return record.Output, nil
+}
+
+// replayCommand answers from the active replay and reports whether one was active.
+func replayCommand(input string, name string, args ...string) (string, bool, error) {
+	recordMu.Lock()
+	replay := replaying
+	recordMu.Unlock()
+	if replay == nil {
+		return "", false, nil
+	}
+	output, err := replay.answer(input, append([]string{name}, args...))
+	return output, true, err
+}
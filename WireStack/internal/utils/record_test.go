@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplayCommands(t *testing.T) {
+	dir := t.TempDir()
+	stop := RecordCommands()
+	if _, err := RunCommand("sh", "-c", "echo "+dir); err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	if _, err := RunCommandWithInput("key", "sh", "-c", "cat; exit 3"); err == nil {
+		t.Fatalf("expected the failing command to fail")
+	}
+	fixture := stop()
+	if len(fixture.Records) != 2 || fixture.Records[1].Stdin != "key" || fixture.Records[1].Error == "" {
+		t.Fatalf("unexpected records %+v", fixture.Records)
+	}
+
+	path := filepath.Join(dir, "fixture.json")
+	if err := SaveCommandFixture(path, fixture, map[string]string{"DIR": dir}); err != nil {
+		t.Fatalf("SaveCommandFixture: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || strings.Contains(string(data), dir) || !strings.Contains(string(data), "${DIR}") {
+		t.Fatalf("fixture should use placeholders:\n%s", data)
+	}
+
+	// Replay on a "different machine" and check nothing actually runs.
+	other := filepath.Join(dir, "elsewhere")
+	loaded, err := LoadCommandFixture(path, map[string]string{"DIR": other})
+	if err != nil {
+		t.Fatalf("LoadCommandFixture: %v", err)
+	}
+	replay := ReplayCommands(loaded)
+	output, err := RunCommand("sh", "-c", "echo "+other)
+	if err != nil || output != other {
+		t.Fatalf("replayed output %q, %v", output, err)
+	}
+	if _, err := RunCommandWithInput("key", "sh", "-c", "cat; exit 3"); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("expected the recorded failure, got %v", err)
+	}
+	if err := replay.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	replay = ReplayCommands(loaded)
+	if _, err := RunCommand("sh", "-c", "echo nope"); err == nil {
+		t.Fatalf("expected a mismatch error")
+	}
+	if err := replay.Stop(); err == nil || !strings.Contains(err.Error(), "expects") {
+		t.Fatalf("Stop should report the mismatch, got %v", err)
+	}
+
+	replay = ReplayCommands(loaded)
+	if err := replay.Stop(); err == nil || !strings.Contains(err.Error(), "2 recorded commands were not run") {
+		t.Fatalf("Stop should report unused records, got %v", err)
+	}
+}
@@ -2,28 +2,88 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 // RunCommand executes the named program with arguments and returns trimmed stdout.
 func RunCommand(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("command %s failed: %w (%s)", name, err, strings.TrimSpace(string(output)))
+	return RunCommandContext(context.Background(), name, args...)
+}
+
+// RunCommandContext is RunCommand killing the program when ctx is done. The
+// error then wraps the context error.
+func RunCommandContext(ctx context.Context, name string, args ...string) (string, error) {
+	if dryRunCommand("", name, args...) {
+		return "", nil
 	}
-	return strings.TrimSpace(string(output)), nil
+	if output, ok, err := replayCommand("", name, args...); ok {
+		return output, err
+	}
+	output, err := runCommand(ctx, "", name, args...)
+	recordCommand("", output, err, name, args...)
+	return output, err
 }
 
 // RunCommandWithInput runs the named program with stdin populated and returns trimmed stdout.
 func RunCommandWithInput(input string, name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = bytes.NewBufferString(input)
+	return RunCommandWithInputContext(context.Background(), input, name, args...)
+}
+
+// RunCommandWithInputContext is RunCommandWithInput killing the program when
+// ctx is done.
+func RunCommandWithInputContext(ctx context.Context, input string, name string, args ...string) (string, error) {
+	if dryRunCommand(input, name, args...) {
+		return "", nil
+	}
+	if output, ok, err := replayCommand(input, name, args...); ok {
+		return output, err
+	}
+	output, err := runCommand(ctx, input, name, args...)
+	recordCommand(input, output, err, name, args...)
+	return output, err
+}
+
+// commandWaitDelay bounds how long a killed program's children may keep its
+// output pipes open before the wait gives up on them.
+const commandWaitDelay = time.Second
+
+// runCommand executes the program, feeding it input when not empty.
+func runCommand(ctx context.Context, input string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = commandWaitDelay
+	if input != "" {
+		cmd.Stdin = bytes.NewBufferString(input)
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("command %s stopped: %w", name, ctx.Err())
+		}
 		return "", fmt.Errorf("command %s failed: %w (%s)", name, err, strings.TrimSpace(string(output)))
 	}
 	return strings.TrimSpace(string(output)), nil
 }
+
+// RunCommandStdout runs the named program with stdin populated and a deadline,
+// returning stdout only. Stderr is included in the error when the program fails.
+func RunCommandStdout(timeout time.Duration, input string, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewBufferString(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("command %s timed out after %s", name, timeout)
+		}
+		return "", fmt.Errorf("command %s failed: %w (%s)", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}